@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// trackNumbersMsg carries the disc and track numbers read for --shuffle
+// album
+type trackNumbersMsg struct {
+	numbers map[string]playlist.DiscTrack
+}
+
+// indexAlbums finds where albums start in the playlist from position from
+// on. Consecutive tracks in the same directory form an album, as for
+// smart shuffle and the album history.
+func (m *PlayerModel) indexAlbums(from int) {
+	m.albumStarts = m.albumStarts[:sort.SearchInts(m.albumStarts, from)]
+	for i := from; i < len(m.playlist); i++ {
+		if i == 0 || playlist.AlbumKey(m.tracks.Path(m.playlist[i])) != playlist.AlbumKey(m.tracks.Path(m.playlist[i-1])) {
+			m.albumStarts = append(m.albumStarts, i)
+		}
+	}
+}
+
+// albumAt returns the album a playlist position belongs to, counting from 0
+func (m *PlayerModel) albumAt(index int) int {
+	return sort.SearchInts(m.albumStarts, index+1) - 1
+}
+
+// albumLabel returns "Album X of Y", or "" while tracks are shuffled one
+// by one and albums don't stay together
+func (m *PlayerModel) albumLabel() string {
+	if m.shuffleMode == playlist.ShuffleTrack && !m.keepOrder || m.weighted != nil || len(m.albumStarts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Album %d of %d", m.albumAt(m.currentIndex)+1, len(m.albumStarts))
+}
+
+// jumpAlbum plays the first track of the album delta albums away,
+// wrapping around at either end
+func (m *PlayerModel) jumpAlbum(delta int) tea.Cmd {
+	if len(m.albumStarts) == 0 {
+		return nil
+	}
+
+	count := len(m.albumStarts)
+	album := ((m.albumAt(m.currentIndex)+delta)%count + count) % count
+
+	m.recordUndo()
+	m.player.Stop()
+	m.leaveTrack()
+	m.queue.Leave()
+	m.currentIndex = m.albumStarts[album]
+	return m.loadCurrentTrack()
+}
+
+// readTrackNumbers reads the disc and track number tags of the upcoming
+// tracks in the background, for laying them out album by album
+func (m *PlayerModel) readTrackNumbers() tea.Cmd {
+	if m.currentIndex+1 >= len(m.playlist) {
+		return nil
+	}
+
+	upcoming := m.tracks.Paths(m.playlist[m.currentIndex+1:])
+	var index *tagIndex
+	if m.tagIndexer != nil {
+		index = m.tagIndexer.index
+	}
+	return m.work.Cmd(func(ctx context.Context) tea.Msg {
+		numbers := make(map[string]playlist.DiscTrack, len(upcoming))
+		for _, path := range upcoming {
+			if ctx.Err() != nil {
+				return nil
+			}
+			numbers[path] = trackNumber(index, path)
+		}
+		return trackNumbersMsg{numbers: numbers}
+	})
+}
+
+// trackNumber returns the disc and track number tags of a file, zero
+// when unknown, from the tag index when there is one
+func trackNumber(index *tagIndex, path string) playlist.DiscTrack {
+	if index != nil {
+		if entry, err := index.Lookup(path); err == nil {
+			return playlist.DiscTrack{Disc: entry.Disc, Track: entry.Track}
+		}
+		return playlist.DiscTrack{}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return playlist.DiscTrack{}
+	}
+	defer file.Close()
+
+	tags, err := tag.ReadFrom(file)
+	if err != nil {
+		return playlist.DiscTrack{}
+	}
+	var number playlist.DiscTrack
+	number.Disc, _ = tags.Disc()
+	number.Track, _ = tags.Track()
+	return number
+}
+
+// albumShuffleUpcoming shuffles the albums after the current track, each
+// in disc and track number order. Played tracks keep their place.
+func (m *PlayerModel) albumShuffleUpcoming(msg trackNumbersMsg) {
+	if m.filterQuery != "" || m.currentIndex+1 >= len(m.playlist) {
+		return
+	}
+
+	upcoming := m.tracks.Paths(m.playlist[m.currentIndex+1:])
+	playlist.ShuffleAlbums(upcoming, msg.numbers)
+
+	playlist := append(m.playlist[:m.currentIndex+1:m.currentIndex+1], m.tracks.AddAll(upcoming)...)
+	m.playlist = playlist
+	m.fullPlaylist = append([]trackID(nil), playlist...)
+	m.indexAlbums(m.currentIndex + 1)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// keepsAlbumsInOrder reports whether the playlist plays albums through in
+// order: sorted by name, or shuffled album by album
+func (m *PlayerModel) keepsAlbumsInOrder() bool {
+	return m.sortMode == playlist.SortName || !m.keepOrder && m.weighted == nil && m.shuffleMode != playlist.ShuffleTrack
+}
+
+// orderAlbums sorts the albums of the playlist that paths belong to by
+// disc and track number, now that their tags are known, and reports
+// whether any track moved. Only the tracks after the current one move, so
+// it keeps its place.
+func (m *PlayerModel) orderAlbums(paths []string) bool {
+	if !m.keepsAlbumsInOrder() || m.filterQuery != "" || len(paths) == 0 {
+		return false
+	}
+
+	albums := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		albums[playlist.AlbumKey(path)] = true
+	}
+
+	moved := false
+	for i, start := range m.albumStarts {
+		end := len(m.playlist)
+		if i+1 < len(m.albumStarts) {
+			end = m.albumStarts[i+1]
+		}
+		start = max(start, m.currentIndex+1)
+		if end-start < 2 || !albums[playlist.AlbumKey(m.tracks.Path(m.playlist[start]))] {
+			continue
+		}
+
+		if tracks := m.playlist[start:end]; !slices.IsSortedFunc(tracks, m.compareAlbumTracks) {
+			slices.SortStableFunc(tracks, m.compareAlbumTracks)
+			moved = true
+		}
+	}
+	if moved {
+		m.fullPlaylist = append([]trackID(nil), m.playlist...)
+	}
+	return moved
+}
+
+// compareAlbumTracks compares two tracks of an album by their known tags,
+// see albumTrackLess
+func (m *PlayerModel) compareAlbumTracks(a, b trackID) int {
+	pa, pb := m.tracks.Path(a), m.tracks.Path(b)
+	na, nb := m.knownTags[pa].number, m.knownTags[pb].number
+	switch {
+	case playlist.AlbumTrackLess(pa, pb, na, nb):
+		return -1
+	case playlist.AlbumTrackLess(pb, pa, nb, na):
+		return 1
+	}
+	return 0
+}
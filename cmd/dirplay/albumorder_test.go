@@ -0,0 +1,103 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// TestModelOrderAlbums plays a two disc album sorted by name, which
+// interleaves the discs, and sends the tags of its tracks as the index
+// reads them: the tracks after the current one are sorted by disc and
+// track number, the current one keeps its place
+func TestModelOrderAlbums(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator)+"music", "album")
+	names := []string{"01 Intro.mp3", "01 Reprise.mp3", "02 Song.mp3", "02 Two.mp3", "Bonus 10.mp3", "Bonus 9.mp3"}
+	numbers := map[string]playlist.DiscTrack{
+		"01 Intro.mp3":   {Disc: 1, Track: 1},
+		"01 Reprise.mp3": {Disc: 2, Track: 1},
+		"02 Song.mp3":    {Disc: 1, Track: 2},
+		"02 Two.mp3":     {Disc: 2, Track: 2},
+	}
+	tests := []struct {
+		name    string
+		sort    string
+		skip    int
+		batches [][]string
+		want    []string
+	}{
+		{
+			name:    "one batch",
+			sort:    playlist.SortName,
+			batches: [][]string{names},
+			want:    []string{"01 Intro.mp3", "02 Song.mp3", "01 Reprise.mp3", "02 Two.mp3", "Bonus 9.mp3", "Bonus 10.mp3"},
+		},
+		{
+			name:    "tags arriving in turn",
+			sort:    playlist.SortName,
+			batches: [][]string{{"02 Two.mp3"}, {"01 Reprise.mp3", "Bonus 9.mp3"}, {"02 Song.mp3"}},
+			want:    []string{"01 Intro.mp3", "02 Song.mp3", "01 Reprise.mp3", "02 Two.mp3", "Bonus 9.mp3", "Bonus 10.mp3"},
+		},
+		{
+			name:    "current keeps its place",
+			sort:    playlist.SortName,
+			skip:    1,
+			batches: [][]string{names},
+			want:    []string{"01 Intro.mp3", "01 Reprise.mp3", "02 Song.mp3", "02 Two.mp3", "Bonus 9.mp3", "Bonus 10.mp3"},
+		},
+		{
+			name:    "only later tracks move",
+			sort:    playlist.SortName,
+			skip:    2,
+			batches: [][]string{names},
+			want:    []string{"01 Intro.mp3", "01 Reprise.mp3", "02 Song.mp3", "02 Two.mp3", "Bonus 9.mp3", "Bonus 10.mp3"},
+		},
+		{
+			name:    "shuffled",
+			sort:    playlist.SortShuffle,
+			batches: [][]string{names},
+			want:    names,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracks []string
+			for _, name := range names {
+				tracks = append(tracks, filepath.Join(dir, name))
+			}
+			h := newHarness(t, tracks...)
+			h.m.SortPlaylist(tt.sort)
+			h.m.shuffleMode = playlist.ShuffleTrack
+			h.m.setPlaylist(h.m.tracks.AddAll(tracks))
+			h.start()
+			for range tt.skip {
+				h.press("right")
+			}
+			current := h.playing()
+
+			for _, batch := range tt.batches {
+				msg := tagIndexedMsg{tags: make(map[string]trackTags)}
+				for _, name := range batch {
+					msg.tags[filepath.Join(dir, name)] = trackTags{title: name, number: numbers[name]}
+				}
+				h.send(msg)
+			}
+
+			var got []string
+			for _, path := range h.m.tracks.Paths(h.m.playlist) {
+				got = append(got, filepath.Base(path))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("playlist = %q, want %q", got, tt.want)
+			}
+			if h.playing() != current || got[h.m.currentIndex] != current {
+				t.Errorf("playing %s at %d, want %s kept in place", h.playing(), h.m.currentIndex, current)
+			}
+			if next := filepath.Base(h.player.preloaded); tt.sort == playlist.SortName && next != got[h.m.currentIndex+1] {
+				t.Errorf("preloaded %s, want %s after the sort", next, got[h.m.currentIndex+1])
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// albumRecord counts how often tracks of an album were started and how
+// often they played through to the end. Previews are counted apart and
+// don't affect the completion ratio.
+type albumRecord struct {
+	Started   int `json:"started"`
+	Completed int `json:"completed"`
+	Previewed int `json:"previewed,omitempty"`
+}
+
+// albumStats is the persistent per-album listening history used to weight
+// smart shuffle. Albums are keyed by their directory, which is what the
+// scanner groups on before any tags have been read.
+type albumStats struct {
+	mu      sync.Mutex
+	path    string
+	Version int                     `json:"version"`
+	Albums  map[string]*albumRecord `json:"albums"`
+}
+
+// albumMigrations upgrade older album history files, see readVersioned
+var albumMigrations = []migration{unversioned}
+
+// loadAlbumStats reads the album history, starting empty if there is none
+func loadAlbumStats() (*albumStats, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &albumStats{
+		path:   filepath.Join(dir, "albums.json"),
+		Albums: make(map[string]*albumRecord),
+	}
+
+	err = readVersioned(stats.path, albumMigrations, stats)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stats.Albums == nil {
+		stats.Albums = make(map[string]*albumRecord)
+	}
+	return stats, nil
+}
+
+// record returns the history for an album, creating it if needed. The
+// caller must hold s.mu.
+func (s *albumStats) record(album string) *albumRecord {
+	rec, ok := s.Albums[album]
+	if !ok {
+		rec = &albumRecord{}
+		s.Albums[album] = rec
+	}
+	return rec
+}
+
+// TrackStarted records that a track of the album started playing
+func (s *albumStats) TrackStarted(track string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(playlist.AlbumKey(track)).Started++
+}
+
+// TrackCompleted records that a track of the album played to the end
+func (s *albumStats) TrackCompleted(track string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(playlist.AlbumKey(track)).Completed++
+}
+
+// TrackPreviewed records that only a preview of a track of the album played
+func (s *albumStats) TrackPreviewed(track string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.record(playlist.AlbumKey(track)).Previewed++
+}
+
+// CompletionRatio returns the share of started tracks that were finished,
+// and false when the album has no history yet
+func (s *albumStats) CompletionRatio(album string) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.Albums[album]
+	if !ok || rec.Started == 0 {
+		return 0, false
+	}
+
+	ratio := float64(rec.Completed) / float64(rec.Started)
+	if ratio > 1 {
+		ratio = 1
+	}
+	return ratio, true
+}
+
+// Weight returns the smart shuffle weight of an album. Albums usually
+// finished weigh up to 1.2, ones usually abandoned drop to 0.2, and albums
+// never heard get a neutral 1.0 so exploration still happens.
+func (s *albumStats) Weight(album string) float64 {
+	ratio, ok := s.CompletionRatio(album)
+	if !ok {
+		return 1.0
+	}
+	return 0.2 + ratio
+}
+
+// Save writes the album history to disk via a temporary file
+func (s *albumStats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Version = len(albumMigrations)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg" // Register decoders for embedded cover art
+	_ "image/png"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dhowden/tag"
+)
+
+// Cover art is downscaled once to this many pixels per side when the track
+// loads, and resampled from there to fit the terminal when rendering
+const artMaxPixels = 48
+
+// Layout limits: the text column needs artTextWidth characters next to the
+// art, and art smaller than artMinColumns isn't worth showing
+const (
+	artTextWidth  = 110
+	artMinColumns = 16
+)
+
+// albumArt is a downscaled cover image ready to be drawn with half-block
+// characters. Each cell shows two vertically stacked pixels.
+type albumArt struct {
+	size   int
+	pixels [][]lipgloss.Color
+}
+
+// artCache keeps decoded cover art per track so revisiting a track doesn't
+// decode the picture again
+type artCache struct {
+	mu    sync.Mutex
+	items map[string]*albumArt
+}
+
+// newArtCache creates an empty art cache
+func newArtCache() *artCache {
+	return &artCache{items: make(map[string]*albumArt)}
+}
+
+// Get returns the cached art of a track. The second result reports whether
+// the track was looked at before, even if it had no art.
+func (c *artCache) Get(track string) (*albumArt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	art, ok := c.items[track]
+	return art, ok
+}
+
+// Put stores the art of a track, nil meaning the track has none
+func (c *artCache) Put(track string, art *albumArt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[track] = art
+}
+
+// decodeAlbumArt decodes and downscales an embedded picture. It returns nil
+// when there is no picture or it can't be decoded.
+func decodeAlbumArt(pic *tag.Picture) *albumArt {
+	if pic == nil || len(pic.Data) == 0 {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(pic.Data))
+	if err != nil {
+		return nil
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil
+	}
+
+	// Box filter: average every source pixel falling into a target pixel
+	size := artMaxPixels
+	art := &albumArt{size: size, pixels: make([][]lipgloss.Color, size)}
+	for y := 0; y < size; y++ {
+		art.pixels[y] = make([]lipgloss.Color, size)
+		y0 := bounds.Min.Y + y*bounds.Dy()/size
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/size
+		for x := 0; x < size; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/size
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/size
+			art.pixels[y][x] = averageColor(img, x0, y0, max(x1, x0+1), max(y1, y0+1))
+		}
+	}
+
+	return art
+}
+
+// averageColor returns the mean color of a rectangle as a hex color
+func averageColor(img image.Image, x0, y0, x1, y1 int) lipgloss.Color {
+	var r, g, b, n uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr >> 8)
+			g += uint64(cg >> 8)
+			b += uint64(cb >> 8)
+			n++
+		}
+	}
+
+	const hex = "0123456789ABCDEF"
+	out := []byte{'#', 0, 0, 0, 0, 0, 0}
+	for i, v := range []uint64{r / n, g / n, b / n} {
+		out[1+i*2] = hex[v>>4]
+		out[2+i*2] = hex[v&0xF]
+	}
+	return lipgloss.Color(out)
+}
+
+// Render draws the art cols characters wide and cols/2 lines high using
+// upper half blocks, the foreground being the top pixel and the background
+// the bottom one. Colors are given as 24-bit hex and lipgloss quantizes
+// them to 256 or 16 colors on terminals without truecolor.
+func (a *albumArt) Render(cols int) string {
+	if cols <= 0 {
+		return ""
+	}
+	if cols > a.size {
+		cols = a.size
+	}
+	rows := cols / 2
+
+	var b strings.Builder
+	for row := 0; row < rows; row++ {
+		top := a.pixels[(row*2)*a.size/cols]
+		bottom := a.pixels[(row*2+1)*a.size/cols]
+		for col := 0; col < cols; col++ {
+			x := col * a.size / cols
+			b.WriteString(lipgloss.NewStyle().
+				Foreground(top[x]).
+				Background(bottom[x]).
+				Render("▀"))
+		}
+		if row < rows-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// artColumns picks the art width for the terminal size, or zero when the
+// terminal is too small to show it without breaking the layout
+func artColumns(width, height int) int {
+	// Leave room for the text column and a couple of spare rows
+	cols := min(width-artTextWidth, (height-2)*2, artMaxPixels)
+	if cols < artMinColumns {
+		return 0
+	}
+	return cols - cols%2
+}
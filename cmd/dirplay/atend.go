@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// What happens after the last track of the playlist
+const (
+	atEndRepeat = "repeat"
+	atEndStop   = "stop"
+	atEndQuit   = "quit"
+	atEndRescan = "rescan"
+
+	// atEndExec runs the command following the prefix, e.g. "exec:systemctl suspend"
+	atEndExec = "exec:"
+)
+
+// rescanMsg carries the result of re-walking the music directories
+type rescanMsg struct {
+	paths []string
+	err   error
+}
+
+// hookDoneMsg reports how the exec: hook finished
+type hookDoneMsg struct {
+	err error
+}
+
+// validateAtEnd checks an --at-end value
+func validateAtEnd(mode string) error {
+	switch {
+	case mode == atEndRepeat, mode == atEndStop, mode == atEndQuit, mode == atEndRescan:
+		return nil
+	case strings.HasPrefix(mode, atEndExec) && strings.TrimSpace(strings.TrimPrefix(mode, atEndExec)) != "":
+		return nil
+	}
+	return fmt.Errorf("invalid --at-end %q (want repeat, stop, quit, rescan or exec:<command>)", mode)
+}
+
+// EnableAtEnd sets what happens after the last track. sources are the
+// command line arguments, expanded again by rescan.
+func (m *PlayerModel) EnableAtEnd(mode string, sources []string) {
+	m.atEnd = mode
+	m.sources = sources
+}
+
+// atPlaylistEnd reports whether the current track is the last one and the
+// playlist shouldn't wrap around after it. Picking with --weights never
+// comes to an end.
+func (m *PlayerModel) atPlaylistEnd() bool {
+	return m.weighted == nil && m.atEnd != "" && m.atEnd != atEndRepeat && m.queueHead() < 0 && m.playlistBase() == len(m.playlist)-1
+}
+
+// finishPlaylist runs the --at-end behavior after the last track
+func (m *PlayerModel) finishPlaylist() tea.Cmd {
+	switch {
+	case m.atEnd == atEndQuit:
+		return m.quit()
+
+	case m.atEnd == atEndRescan:
+		m.stopPlayback()
+		config, sources := m.scanConfig, m.sources
+		return m.work.Cmd(func(ctx context.Context) tea.Msg {
+			paths, warnings := config.Collect(ctx, sources)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if len(paths) == 0 && len(warnings) > 0 {
+				return rescanMsg{err: warnings[0]}
+			}
+			return rescanMsg{paths: paths}
+		})
+
+	case strings.HasPrefix(m.atEnd, atEndExec):
+		m.stopPlayback()
+		return tea.Batch(m.showBanner("End of playlist, running hook"), runHook(strings.TrimPrefix(m.atEnd, atEndExec)))
+	}
+
+	m.stopPlayback()
+	return m.showBanner("End of playlist")
+}
+
+// stopPlayback stops at the end of the playlist, SPACE starts it again
+func (m *PlayerModel) stopPlayback() {
+	m.player.Stop()
+	m.playing = false
+	m.paused = false
+	m.ended = true
+}
+
+// restartPlaylist plays the playlist again from the top after it ended
+func (m *PlayerModel) restartPlaylist() tea.Cmd {
+	m.ended = false
+	m.queue.Leave()
+	m.currentIndex = 0
+	return m.loadCurrentTrack()
+}
+
+// continueWithNew appends tracks found by a rescan and plays the first of
+// them. Nothing new leaves the player stopped.
+func (m *PlayerModel) continueWithNew(msg rescanMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.showBanner(fmt.Sprintf("Rescan failed: %v", msg.err))
+	}
+
+	paths := msg.paths
+	if m.scanFilter != "" {
+		paths = filterPlaylist(paths, nil, m.scanFilter)
+	}
+	paths = m.keepRated(paths)
+	paths = m.keepWeighted(paths)
+	paths, _ = m.dedupeByName(paths)
+
+	first := m.appendTracks(paths)
+	m.indexTags(paths)
+	if first < 0 {
+		return m.showBanner("End of playlist, no new tracks found")
+	}
+
+	m.ended = false
+	m.currentIndex = first
+	return tea.Batch(
+		m.showBanner(fmt.Sprintf("Found %d new tracks", len(m.playlist)-first)),
+		m.loadCurrentTrack(),
+	)
+}
+
+// runHook runs the exec: command through the system shell
+func runHook(command string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+		return hookDoneMsg{err: cmd.Run()}
+	}
+}
+
+// atEndLabel describes a non-default --at-end in the header
+func (m *PlayerModel) atEndLabel() string {
+	if m.atEnd == "" || m.atEnd == atEndRepeat {
+		return ""
+	}
+	if strings.HasPrefix(m.atEnd, atEndExec) {
+		return "at end: run hook"
+	}
+	return "at end: " + m.atEnd
+}
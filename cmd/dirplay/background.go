@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shutdownTimeout is how long quitting waits for background work to stop
+// before exiting anyway, e.g. while a read from a slow network share hangs
+const shutdownTimeout = 2 * time.Second
+
+// backgroundWork tracks the goroutines working for the program. They share
+// ctx, which is cancelled on quit, and check it between files and buffers
+// so they stop promptly. Writes that fail are kept, to be listed once the
+// TUI is gone, as a banner shown while quitting is never seen.
+type backgroundWork struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	failures []error
+}
+
+// newBackgroundWork returns a tracker whose context lives until Stop
+func newBackgroundWork() *backgroundWork {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundWork{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine, counted until it returns
+func (b *backgroundWork) Go(fn func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.ctx)
+	}()
+}
+
+// Cmd wraps fn as a command that is counted from now until it returns.
+// Once the work is stopped it returns nil, so nothing new starts. It must
+// be called from the UI goroutine, which is the one that stops the work.
+func (b *backgroundWork) Cmd(fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	if b.ctx.Err() != nil {
+		return nil
+	}
+
+	b.wg.Add(1)
+	return func() tea.Msg {
+		defer b.wg.Done()
+		return fn(b.ctx)
+	}
+}
+
+// Stop cancels the work and waits up to timeout for it to return,
+// reporting whether everything finished in time
+func (b *backgroundWork) Stop(timeout time.Duration) bool {
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Failed records a write that failed, as "could not <what>: <err>"
+func (b *backgroundWork) Failed(what string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = append(b.failures, fmt.Errorf("could not %s: %w", what, err))
+}
+
+// Failures returns the writes that failed, in order
+func (b *backgroundWork) Failures() []error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures
+}
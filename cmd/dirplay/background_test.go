@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// TestBackgroundWorkStop stops work still running: Stop waits for it up to
+// the timeout, and nothing new starts afterwards
+func TestBackgroundWorkStop(t *testing.T) {
+	tests := []struct {
+		name string
+		// takes is how long the work takes once started
+		takes   time.Duration
+		timeout time.Duration
+		want    bool
+	}{
+		{"done in time", 50 * time.Millisecond, time.Second, true},
+		{"stops when cancelled", time.Hour, time.Second, true},
+		{"too slow", 300 * time.Millisecond, 50 * time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work := newBackgroundWork()
+			done := make(chan struct{})
+			cmd := work.Cmd(func(ctx context.Context) tea.Msg {
+				defer close(done)
+				select {
+				case <-time.After(tt.takes):
+				case <-ctx.Done():
+					if tt.want {
+						return nil
+					}
+					// Work that doesn't check ctx
+					time.Sleep(tt.takes)
+				}
+				return nil
+			})
+			go cmd()
+
+			if got := work.Stop(tt.timeout); got != tt.want {
+				t.Errorf("Stop = %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				select {
+				case <-done:
+				default:
+					t.Error("Stop returned before the work did")
+				}
+			}
+			if work.Cmd(func(context.Context) tea.Msg { return nil }) != nil {
+				t.Error("work started after Stop")
+			}
+		})
+	}
+}
+
+func TestBackgroundWorkFailed(t *testing.T) {
+	errDisk := errors.New("disk full")
+	tests := []struct {
+		name string
+		what []string
+		want []string
+	}{
+		{"none", nil, nil},
+		{"one", []string{"save bookmarks"}, []string{"could not save bookmarks: disk full"}},
+		{"in order", []string{"save the note on /music/01.mp3", "save play counts"}, []string{
+			"could not save the note on /music/01.mp3: disk full", "could not save play counts: disk full",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work := newBackgroundWork()
+			for _, what := range tt.what {
+				work.Failed(what, errDisk)
+			}
+			got := work.Failures()
+			if len(got) != len(tt.want) {
+				t.Fatalf("failures = %v, want %v", got, tt.want)
+			}
+			for i, err := range got {
+				if err.Error() != tt.want[i] || !errors.Is(err, errDisk) {
+					t.Errorf("failure %d = %v, want %s wrapping %v", i, err, tt.want[i], errDisk)
+				}
+			}
+		})
+	}
+}
+
+// TestQuitStopsBackgroundWork quits in the middle of indexing tags and
+// fingerprinting, after changing tracks a few times: once quit returns,
+// every goroutine started for the session has ended
+func TestQuitStopsBackgroundWork(t *testing.T) {
+	// Files holding only an ID3v2 tag with a title
+	music := t.TempDir()
+	paths := make([]string, 3*tagIndexBatch)
+	for i := range paths {
+		paths[i] = filepath.Join(music, fmt.Sprintf("%03d.mp3", i))
+		if err := os.WriteFile(paths[i], []byte("ID3\x03\x00\x00\x00\x00\x00\x0cTIT2\x00\x00\x00\x02\x00\x00\x00x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	baseline := runtime.NumGoroutine()
+	h := newHarness(t, album(5)...).start()
+	index, err := loadTagIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := loadSignatureCache(codec.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.m.StartTagIndex(index)
+	h.m.EnableDedupe(cache)
+	// Nothing takes the indexed tags, so once it has read a batch the
+	// indexer waits to hand it over while reading no further
+	h.m.indexTags(paths)
+	fingerprint := h.m.fingerprint(paths)
+	go fingerprint()
+	for read := 0; read < tagIndexBatch; {
+		time.Sleep(10 * time.Millisecond)
+		index.mu.Lock()
+		read = len(index.Files)
+		index.mu.Unlock()
+	}
+
+	for range 4 {
+		h.advance(time.Second)
+		h.press("right")
+	}
+	if h.playing() != "05.mp3" {
+		t.Fatalf("on %s after four skips, want 05.mp3", h.playing())
+	}
+	h.press("q")
+	if !h.quit {
+		t.Fatal("q didn't quit")
+	}
+	if failures := h.m.work.Failures(); len(failures) > 0 {
+		t.Errorf("quitting failed: %v", failures)
+	}
+
+	// Goroutines that were told to end may not have been scheduled yet
+	deadline := time.Now().Add(time.Second)
+	n := runtime.NumGoroutine()
+	for n > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	if n > baseline {
+		t.Errorf("%d goroutines after quitting, want at most the %d before starting", n, baseline)
+	}
+}
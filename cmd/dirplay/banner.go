@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A banner stays up for bannerDuration, or for bannerMinimum when others
+// are waiting behind it, so messages arriving together are each readable
+// rather than flickering past. At most bannerQueueLimit wait, the oldest
+// making way for newer ones.
+const (
+	bannerDuration   = 3 * time.Second
+	bannerMinimum    = 1500 * time.Millisecond
+	bannerQueueLimit = 3
+)
+
+// bannerQueue holds the banners waiting for the one on screen, and that
+// one as first shown, when it went up and how often it came again since
+type bannerQueue struct {
+	pending []string
+	text    string
+	shownAt time.Time
+	repeats int
+}
+
+// showBanner displays a non-fatal message for a few seconds. A message
+// repeating the one on screen or the last one waiting is counted instead
+// of shown again, and one arriving while the banner on screen is still
+// fresh or others wait takes its turn after them.
+func (m *PlayerModel) showBanner(text string) tea.Cmd {
+	q := &m.banners
+	switch {
+	case m.banner != "" && text == q.text && len(q.pending) == 0:
+		q.repeats++
+		m.banner = fmt.Sprintf("%s (×%d)", text, q.repeats+1)
+		return m.bannerTimer(bannerDuration)
+
+	case len(q.pending) > 0:
+		if q.pending[len(q.pending)-1] == text {
+			return nil
+		}
+		if len(q.pending) == bannerQueueLimit {
+			q.pending = q.pending[1:]
+		}
+		// The banner on screen already makes way at bannerMinimum
+		q.pending = append(q.pending, text)
+		return nil
+
+	case m.banner != "" && m.now().Sub(q.shownAt) < bannerMinimum:
+		q.pending = append(q.pending, text)
+		return m.bannerTimer(bannerMinimum - m.now().Sub(q.shownAt))
+	}
+
+	m.putBanner(text)
+	return m.bannerTimer(bannerDuration)
+}
+
+// putBanner puts text on screen
+func (m *PlayerModel) putBanner(text string) {
+	m.banner = text
+	m.banners.text = text
+	m.banners.shownAt = m.now()
+	m.banners.repeats = 0
+}
+
+// bannerTimer ends the banner on screen after d. Only the newest timer
+// counts, earlier ones are outdated by it.
+func (m *PlayerModel) bannerTimer(d time.Duration) tea.Cmd {
+	m.bannerID++
+	id := m.bannerID
+	return m.ticks.clock.Tick(d, func(time.Time) tea.Msg {
+		return clearBannerMsg{id: id}
+	})
+}
+
+// bannerExpired takes the banner on screen down once its time is up and
+// puts up the next one waiting, if any
+func (m *PlayerModel) bannerExpired(msg clearBannerMsg) tea.Cmd {
+	if msg.id != m.bannerID {
+		return nil
+	}
+
+	q := &m.banners
+	if len(q.pending) == 0 {
+		m.banner = ""
+		return nil
+	}
+	m.putBanner(q.pending[0])
+	q.pending = q.pending[1:]
+	if len(q.pending) > 0 {
+		return m.bannerTimer(bannerMinimum)
+	}
+	return m.bannerTimer(bannerDuration)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// blockConfirm is how long a second press of the block key has to block
+// the track taken out of rotation for good
+const blockConfirm = 5 * time.Second
+
+// blocklist is the persistent blocklist.json: tracks never to play again,
+// by absolute path with symlinks resolved, and when each was blocked.
+// Scans leave them out, whatever directory they were started on.
+type blocklist struct {
+	mu      sync.Mutex
+	path    string
+	Version int                  `json:"version"`
+	Paths   map[string]time.Time `json:"paths"`
+}
+
+// blocklistMigrations upgrade older blocklists, see readVersioned. The
+// file has had one format so far.
+var blocklistMigrations []migration
+
+// blockPending is a track taken out of rotation for this session, which
+// the block key pressed again before until blocks for good
+type blockPending struct {
+	path  string
+	until time.Time
+}
+
+// blockSavedMsg reports the outcome of saving the blocklist
+type blockSavedMsg struct {
+	err error
+}
+
+// loadBlocklist reads the blocklist, starting empty if there is none
+func loadBlocklist() (*blocklist, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &blocklist{
+		path:  filepath.Join(dir, "blocklist.json"),
+		Paths: make(map[string]time.Time),
+	}
+
+	err = readVersioned(list.path, blocklistMigrations, list)
+	if errors.Is(err, os.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if list.Paths == nil {
+		list.Paths = make(map[string]time.Time)
+	}
+	return list, nil
+}
+
+// Add blocks a track
+func (b *blocklist) Add(track string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Paths[scan.CanonicalPath(track)] = time.Now()
+}
+
+// Remove unblocks a track, reporting whether it was blocked. The path is
+// looked up as given too, for a file that no longer resolves.
+func (b *blocklist) Remove(track string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range []string{scan.CanonicalPath(track), track} {
+		if _, ok := b.Paths[key]; ok {
+			delete(b.Paths, key)
+			return true
+		}
+	}
+	return false
+}
+
+// Set returns the blocked paths, for scan.Config.Blocked
+func (b *blocklist) Set() map[string]bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set := make(map[string]bool, len(b.Paths))
+	for path := range b.Paths {
+		set[path] = true
+	}
+	return set
+}
+
+// Save writes the blocklist to disk via a temporary file
+func (b *blocklist) Save() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.Version = len(blocklistMigrations)
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(b.path, data)
+}
+
+// EnableBlocklist lets the block key add tracks to list
+func (m *PlayerModel) EnableBlocklist(list *blocklist) {
+	m.blocklist = list
+}
+
+// blockTrack takes a track out of rotation for this session, skipping to
+// the next one if it is playing, and blocks it for good if the key is
+// pressed again within blockConfirm
+func (m *PlayerModel) blockTrack(id trackID) tea.Cmd {
+	if pending := m.blockPending; pending.path != "" && time.Now().Before(pending.until) {
+		m.blockPending = blockPending{}
+		if m.blocklist == nil {
+			return m.showBanner("The blocklist couldn't be read, the track is only out for this session")
+		}
+		m.blocklist.Add(pending.path)
+		list, work := m.blocklist, m.work
+		return tea.Batch(
+			m.showBanner("Blocked for good, \"dirplay blocklist\" lists and unblocks tracks"),
+			work.Cmd(func(context.Context) tea.Msg {
+				err := list.Save()
+				if err != nil {
+					work.Failed("save the blocklist", err)
+				}
+				return blockSavedMsg{err: err}
+			}))
+	}
+	if id == noTrack {
+		return nil
+	}
+
+	path := m.tracks.Path(id)
+	m.blockPending = blockPending{path: path, until: time.Now().Add(blockConfirm)}
+	banner := m.showBanner(fmt.Sprintf("Out of rotation for this session, %s again to never play it", m.keys.Block.Help().Key))
+
+	_, skipped := m.removeTracks([]string{path})
+	if m.pane.open {
+		// The cursor stays where the track was
+		cursor := m.pane.cursor
+		m.rebuildPane()
+		m.pane.cursor = max(min(cursor, len(m.pane.rows)-1), 0)
+	}
+	switch {
+	case skipped && m.current == noTrack:
+		return banner
+	case skipped:
+		return tea.Batch(banner, m.loadCurrentTrack())
+	case m.playing:
+		return tea.Batch(banner, m.preloadNext())
+	}
+	return banner
+}
+
+// blockSaved reports a blocklist that couldn't be saved
+func (m *PlayerModel) blockSaved(msg blockSavedMsg) tea.Cmd {
+	if msg.err == nil {
+		return nil
+	}
+	return m.showBanner(fmt.Sprintf("Could not save the blocklist: %v", msg.err))
+}
+
+// newBlocklistCommand creates the "blocklist" command, which lists and
+// unblocks the tracks blocked from the player
+func newBlocklistCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "blocklist",
+		Short: "List or unblock the tracks blocked from ever playing",
+		Long: "Tracks blocked in the player with the block key are left out of every scan.\n" +
+			"blocklist list prints them with when each was blocked, and blocklist\n" +
+			"remove lets the files given play again.",
+		Example: "  dirplay blocklist list\n  dirplay blocklist remove ~/Music/Family/song.mp3",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:          "list",
+		Short:        "Print the blocked tracks",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			list, err := loadBlocklist()
+			if err != nil {
+				return err
+			}
+			paths := make([]string, 0, len(list.Paths))
+			for path := range list.Paths {
+				paths = append(paths, path)
+			}
+			slices.Sort(paths)
+			for _, path := range paths {
+				fmt.Printf("%s  %s\n", list.Paths[path].Format("2006-01-02 15:04"), path)
+			}
+			if len(paths) == 0 {
+				fmt.Println("Nothing is blocked")
+			}
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:          "remove <file>...",
+		Short:        "Let blocked tracks play again",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			list, err := loadBlocklist()
+			if err != nil {
+				return err
+			}
+			var missing []string
+			for _, arg := range args {
+				if !list.Remove(arg) {
+					missing = append(missing, arg)
+				}
+			}
+			if len(missing) < len(args) {
+				if err := list.Save(); err != nil {
+					return err
+				}
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("not blocked: %s", strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	})
+	return cmd
+}
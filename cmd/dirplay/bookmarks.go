@@ -0,0 +1,67 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Long tracks, such as mixes, pick up where they were left when they come
+// round again. Positions under bookmarkMinPosition aren't worth keeping.
+const (
+	bookmarkMinLength   = 10 * time.Minute
+	bookmarkMinPosition = 30 * time.Second
+)
+
+// SetBookmarks restores the positions saved with the previous session
+func (m *PlayerModel) SetBookmarks(bookmarks map[string]time.Duration) {
+	m.bookmarks = bookmarks
+}
+
+// rememberPosition keeps the position of a long track playback moves away
+// from part way, and forgets it once the track played to its end
+func (m *PlayerModel) rememberPosition() {
+	if m.current == noTrack || m.previewing() {
+		return
+	}
+
+	path := m.tracks.Path(m.current)
+	if m.duration < bookmarkMinLength || m.position < bookmarkMinPosition || m.position >= m.duration-durationSlack {
+		delete(m.bookmarks, path)
+		return
+	}
+	if m.bookmarks == nil {
+		m.bookmarks = make(map[string]time.Duration)
+	}
+	m.bookmarks[path] = m.position
+}
+
+// restartTrack plays the current track again from its beginning, or from
+// the start of its preview window, forgetting any remembered position
+func (m *PlayerModel) restartTrack() tea.Cmd {
+	if !m.playing || m.current == noTrack {
+		return nil
+	}
+
+	delete(m.bookmarks, m.currentTrack())
+	m.leaveLoop(m.preview.from)
+	if err := m.player.Seek(m.preview.from); err != nil {
+		return m.showBanner("Could not restart: " + err.Error())
+	}
+	m.position = m.preview.from
+	return m.showBanner("Restarted from the beginning")
+}
+
+// bookmarksMS returns the remembered positions in milliseconds for the
+// state file
+func (m *PlayerModel) bookmarksMS() map[string]int64 {
+	if len(m.bookmarks) == 0 {
+		return nil
+	}
+
+	saved := make(map[string]int64, len(m.bookmarks))
+	for path, position := range m.bookmarks {
+		saved[path] = position.Milliseconds()
+	}
+	return saved
+}
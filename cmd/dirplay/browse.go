@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// Letters typed within browseTypeAhead of each other add up to one
+// prefix to jump to, so "rad" finds Radiohead
+const browseTypeAhead = time.Second
+
+// The columns of the browse screen, left to right
+const (
+	browseArtists = iota
+	browseAlbums
+	browseTracks
+)
+
+// browsePane is the browse screen opened with "g": the artists of the
+// playlist, the albums of the selected artist and the tracks of the
+// selected album. The artist and album are kept by key, so the cursors
+// stay put as tags come in and entries are inserted above them.
+type browsePane struct {
+	open    bool
+	column  int
+	artist  string
+	album   string
+	track   int
+	offsets [3]int
+
+	typed   string
+	typedAt time.Time
+}
+
+// catalogTrack files a track by its album artist, falling back to the
+// track artist
+func catalogTrack(path string, tags trackTags) library.CatalogTrack {
+	artist := tags.albumArtist
+	if artist == "" {
+		artist = tags.artist
+	}
+	return library.CatalogTrack{
+		Path:       path,
+		Artist:     artist,
+		SortArtist: tags.sortArtist,
+		Album:      tags.album,
+		Title:      tags.title,
+		Disc:       tags.number.Disc,
+		Track:      tags.number.Track,
+	}
+}
+
+// toggleBrowse opens the browse screen on the current track's artist and
+// album, or closes it. The catalog is built from the tags known so far
+// and grows while the screen is open, see catalogTags.
+func (m *PlayerModel) toggleBrowse() {
+	if m.browse.open {
+		m.browse.open = false
+		return
+	}
+
+	m.catalog = library.NewCatalog()
+	for _, path := range m.tracks.Paths(m.fullPlaylist) {
+		if tags, ok := m.knownTags[path]; ok {
+			m.catalog.Add(catalogTrack(path, tags))
+		}
+	}
+
+	m.browse = browsePane{open: true, column: m.browse.column, artist: m.browse.artist, album: m.browse.album}
+	if artist, album, i, ok := m.catalog.Find(m.currentTrack()); ok {
+		m.browse.artist, m.browse.album, m.browse.track = artist, album, i
+	}
+}
+
+// catalogTags adds tracks whose tags were just read to the open browse
+// screen
+func (m *PlayerModel) catalogTags(paths []string) {
+	if !m.browse.open {
+		return
+	}
+	for _, path := range paths {
+		m.catalog.Add(catalogTrack(path, m.knownTags[path]))
+	}
+}
+
+// browseSelection returns the selected artist and album, falling back to
+// the first ones when nothing, or something since gone, was selected
+func (m *PlayerModel) browseSelection() (*library.CatalogArtist, *library.CatalogAlbum) {
+	artist := m.catalog.Artist(m.browse.artist)
+	if artist == nil {
+		artists := m.catalog.Artists()
+		if len(artists) == 0 {
+			return nil, nil
+		}
+		artist = artists[0]
+	}
+	album := artist.Album(m.browse.album)
+	if album == nil {
+		album = artist.Albums()[0]
+	}
+	return artist, album
+}
+
+// browseColumn returns the names of the entries of a column and which is
+// selected
+func (m *PlayerModel) browseColumn(column int) (names []string, selected int) {
+	artist, album := m.browseSelection()
+	if artist == nil {
+		return nil, 0
+	}
+
+	switch column {
+	case browseArtists:
+		for i, a := range m.catalog.Artists() {
+			names = append(names, a.Name)
+			if a == artist {
+				selected = i
+			}
+		}
+	case browseAlbums:
+		for i, a := range artist.Albums() {
+			names = append(names, a.Name)
+			if a == album {
+				selected = i
+			}
+		}
+	case browseTracks:
+		for _, t := range album.Tracks {
+			names = append(names, browseTrackName(t))
+		}
+		selected = max(min(m.browse.track, len(names)-1), 0)
+	}
+	return names, selected
+}
+
+// browseTrackName returns how a track is listed: its number and title, or
+// its file name
+func browseTrackName(t library.CatalogTrack) string {
+	name := t.Title
+	if name == "" {
+		name = filepath.Base(t.Path)
+	}
+	if t.Track > 0 {
+		return fmt.Sprintf("%2d. %s", t.Track, name)
+	}
+	return name
+}
+
+// selectBrowse selects entry i of the focused column. Picking another
+// artist or album starts the columns to its right from the top.
+func (m *PlayerModel) selectBrowse(i int) {
+	artist, _ := m.browseSelection()
+	if artist == nil {
+		return
+	}
+
+	switch m.browse.column {
+	case browseArtists:
+		artists := m.catalog.Artists()
+		i = max(min(i, len(artists)-1), 0)
+		if artists[i].Key != artist.Key {
+			m.browse.artist, m.browse.album, m.browse.track = artists[i].Key, "", 0
+		}
+	case browseAlbums:
+		albums := artist.Albums()
+		i = max(min(i, len(albums)-1), 0)
+		if albums[i].Key != m.browse.album {
+			m.browse.artist, m.browse.album, m.browse.track = artist.Key, albums[i].Key, 0
+		}
+	case browseTracks:
+		m.browse.track = max(i, 0)
+	}
+}
+
+// updateBrowse handles keys while the browse screen is open. Enter plays
+// the selection now, in place of whatever was queued; "+" or alt+enter
+// queues it after. Letters, digits and spaces jump to the first entry of
+// the focused column starting with what was typed, so only Esc closes.
+func (m *PlayerModel) updateBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	_, selected := m.browseColumn(m.browse.column)
+	page := m.paneHeight() - 1
+
+	k := msg.String()
+	if k != "backspace" && !isTypeAhead(msg) {
+		m.browse.typed = ""
+	}
+	switch {
+	case k == "esc":
+		m.browse.open = false
+	case k == "left":
+		m.browse.column = max(m.browse.column-1, browseArtists)
+	case k == "right":
+		m.browse.column = min(m.browse.column+1, browseTracks)
+	case k == "up":
+		m.selectBrowse(selected - 1)
+	case k == "down":
+		m.selectBrowse(selected + 1)
+	case k == "pgup":
+		m.selectBrowse(selected - page)
+	case k == "pgdown":
+		m.selectBrowse(selected + page)
+	case k == "home":
+		m.selectBrowse(0)
+	case k == "end":
+		names, _ := m.browseColumn(m.browse.column)
+		m.selectBrowse(len(names) - 1)
+	case k == "enter":
+		return m, m.playBrowsed(true)
+	case k == "+" || k == "alt+enter":
+		return m, m.playBrowsed(false)
+	case k == "backspace":
+		if m.browse.typed != "" {
+			_, size := utf8.DecodeLastRuneInString(m.browse.typed)
+			m.browse.typed = m.browse.typed[:len(m.browse.typed)-size]
+			m.browse.typedAt = time.Now()
+		}
+	case isTypeAhead(msg):
+		m.typeAhead(k)
+	}
+	return m, nil
+}
+
+// isTypeAhead reports whether a key types into the jump prefix
+func isTypeAhead(msg tea.KeyMsg) bool {
+	if msg.Type == tea.KeySpace {
+		return true
+	}
+	if msg.Type != tea.KeyRunes || msg.Alt || len(msg.Runes) != 1 {
+		return false
+	}
+	r := msg.Runes[0]
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// typeAhead adds text to the jump prefix, starting over when the last
+// key was a while ago, and selects the first entry that starts with it.
+// Text that leads nowhere isn't kept.
+func (m *PlayerModel) typeAhead(text string) {
+	if time.Since(m.browse.typedAt) > browseTypeAhead {
+		m.browse.typed = ""
+	}
+	m.browse.typedAt = time.Now()
+	prefix := m.browse.typed + text
+	if strings.TrimSpace(prefix) == "" {
+		return
+	}
+
+	artist, album := m.browseSelection()
+	if artist == nil {
+		return
+	}
+	match := -1
+	switch m.browse.column {
+	case browseArtists:
+		for i, a := range m.catalog.Artists() {
+			if library.HasNamePrefix(a.Name, prefix) {
+				match = i
+				break
+			}
+		}
+	case browseAlbums:
+		for i, a := range artist.Albums() {
+			if library.HasNamePrefix(a.Name, prefix) {
+				match = i
+				break
+			}
+		}
+	case browseTracks:
+		for i, t := range album.Tracks {
+			if library.HasNamePrefix(t.Title, prefix) || library.HasNamePrefix(filepath.Base(t.Path), prefix) {
+				match = i
+				break
+			}
+		}
+	}
+	if match >= 0 {
+		m.browse.typed = prefix
+		m.selectBrowse(match)
+	}
+}
+
+// playBrowsed plays the selection in the focused column: everything by
+// the artist, the album, or the album from the selected track on. With
+// replace it plays now, in place of the queue, and the playlist carries
+// on where it was once it is done; otherwise it is queued after what is
+// queued already. Tracks filtered out of the playlist are left out.
+func (m *PlayerModel) playBrowsed(replace bool) tea.Cmd {
+	artist, album := m.browseSelection()
+	if artist == nil {
+		return nil
+	}
+
+	var tracks []library.CatalogTrack
+	var what string
+	switch m.browse.column {
+	case browseArtists:
+		tracks, what = artist.AllTracks(), "everything by "+artist.Name
+	case browseAlbums:
+		tracks, what = album.Tracks, album.Name+" by "+artist.Name
+	case browseTracks:
+		_, selected := m.browseColumn(browseTracks)
+		tracks, what = album.Tracks[selected:], album.Name+" by "+artist.Name
+		if len(tracks) == 1 {
+			what = browseTrackName(tracks[0])
+		}
+	}
+
+	var ids []trackID
+	for _, t := range tracks {
+		if !m.tracks.Known(t.Path) {
+			continue
+		}
+		if id := m.tracks.Add(t.Path); m.indexOf(id) >= 0 && (replace || m.queuedAt(id) == 0) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		if replace {
+			return m.showBanner(fmt.Sprintf("Nothing of %s is in the playlist", what))
+		}
+		return m.showBanner(fmt.Sprintf("%s is queued already", what))
+	}
+
+	m.browse.open = false
+	if replace {
+		m.queue.Replace(ids)
+		return tea.Batch(
+			m.showBanner(fmt.Sprintf("Playing %s, %d tracks", what, len(ids))),
+			m.skip(1))
+	}
+	m.queue.Add(ids...)
+	banner := m.showBanner(fmt.Sprintf("Queued %s, %d tracks, %d up next", what, len(ids), m.queue.Len()))
+	if m.playing {
+		return tea.Batch(banner, m.preloadNext())
+	}
+	return banner
+}
+
+// viewBrowse renders the browse screen in place of the player view, the
+// three columns side by side, each with how many tracks its entries hold
+func (m *PlayerModel) viewBrowse() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle, categoryStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor, m.styles.Category
+	width := m.viewWidth()
+
+	var content strings.Builder
+	header := fmt.Sprintf("Browse · %d artists · %d tracks", len(m.catalog.Artists()), m.catalog.Len())
+	if unread := len(m.fullPlaylist) - m.catalog.Len(); unread > 0 {
+		header += fmt.Sprintf(" · %d without tags read yet", unread)
+	}
+	content.WriteString(headerStyle.Render(fitText(header, width)))
+	content.WriteString("\n")
+
+	artist, album := m.browseSelection()
+	if artist == nil {
+		content.WriteString(dimStyle.Render("No tags read yet"))
+		content.WriteString("\n")
+		content.WriteString(dimStyle.Render("[ESC] Close"))
+		return content.String()
+	}
+
+	// Each column lists names with a count, or the tracks' numbers
+	var columns [3][]string
+	var counts [3][]string
+	var selected [3]int
+	for column := range columns {
+		columns[column], selected[column] = m.browseColumn(column)
+	}
+	for _, a := range m.catalog.Artists() {
+		counts[browseArtists] = append(counts[browseArtists], fmt.Sprint(a.Tracks))
+	}
+	for _, a := range artist.Albums() {
+		counts[browseAlbums] = append(counts[browseAlbums], fmt.Sprint(len(a.Tracks)))
+	}
+	counts[browseTracks] = make([]string, len(album.Tracks))
+
+	// Keep each column's selection inside the visible window
+	height := m.paneHeight() - 1
+	for column := range columns {
+		offset := &m.browse.offsets[column]
+		if selected[column] < *offset {
+			*offset = selected[column]
+		}
+		if selected[column] >= *offset+height {
+			*offset = selected[column] - height + 1
+		}
+	}
+
+	columnWidth := max((width-4)/3, 8)
+	titles := [3]string{"Artists", "Albums", "Tracks"}
+	for column, title := range titles {
+		if column > 0 {
+			content.WriteString("  ")
+		}
+		content.WriteString(categoryStyle.Render(padText(title, columnWidth)))
+	}
+	content.WriteString("\n")
+
+	for row := range height {
+		for column := range columns {
+			if column > 0 {
+				content.WriteString("  ")
+			}
+			i := m.browse.offsets[column] + row
+			if i >= len(columns[column]) {
+				content.WriteString(strings.Repeat(" ", columnWidth))
+				continue
+			}
+
+			count := counts[column][i]
+			if count != "" {
+				count = " " + count
+			}
+			cell := padText(fitText(columns[column][i], columnWidth-lipgloss.Width(count)), columnWidth-lipgloss.Width(count)) + count
+			switch {
+			case i == selected[column] && column == m.browse.column:
+				content.WriteString(cursorStyle.Render(cell))
+			case i == selected[column]:
+				content.WriteString(categoryStyle.Render(cell))
+			default:
+				content.WriteString(rowStyle.Render(cell))
+			}
+		}
+		content.WriteString("\n")
+	}
+
+	help := "[←→] Column  [↑↓] Move  [ENTER] Play  [+] Queue  [A-Z] Jump  [ESC] Close"
+	if m.browse.typed != "" {
+		help = "Jump to: " + m.browse.typed
+	}
+	content.WriteString(dimStyle.Render(fitText(help, width)))
+	return content.String()
+}
+
+// padText fills s with spaces to width cells
+func padText(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// browseLibrary is the library the browse tests play, the first track
+// playing
+var browseLibrary = []struct {
+	path string
+	tags trackTags
+}{
+	{"/music/radiohead/ok/01.mp3", trackTags{artist: "Radiohead", album: "OK Computer", title: "Airbag", number: playlist.DiscTrack{Track: 1}}},
+	{"/music/radiohead/ok/02.mp3", trackTags{artist: "Radiohead", album: "OK Computer", title: "Paranoid Android", number: playlist.DiscTrack{Track: 2}}},
+	{"/music/radiohead/kid/01.mp3", trackTags{artist: "Radiohead", album: "Kid A", title: "Everything in Its Right Place", number: playlist.DiscTrack{Track: 1}}},
+	{"/music/beatles/abbey/02.mp3", trackTags{artist: "The Beatles", album: "Abbey Road", title: "Something", number: playlist.DiscTrack{Track: 2}}},
+	{"/music/beatles/abbey/01.mp3", trackTags{artist: "The Beatles", album: "Abbey Road", title: "Come Together", number: playlist.DiscTrack{Track: 1}}},
+	{"/music/adele/21/01.mp3", trackTags{artist: "Adele", album: "21", title: "Rolling in the Deep", number: playlist.DiscTrack{Track: 1}}},
+	{"/music/comp/01.mp3", trackTags{artist: "Portishead", albumArtist: "Various Artists", album: "Trip Hop", title: "Glory Box", number: playlist.DiscTrack{Track: 1}}},
+}
+
+// newBrowseHarness plays browseLibrary with all its tags read
+func newBrowseHarness(t *testing.T) *harness {
+	t.Helper()
+	var paths []string
+	tags := make(map[string]trackTags)
+	for _, track := range browseLibrary {
+		paths = append(paths, track.path)
+		tags[track.path] = track.tags
+	}
+	h := newHarness(t, paths...).start()
+	h.send(tagIndexedMsg{tags: tags})
+	return h
+}
+
+// browsed returns the selected artist, album and track as "Artist /
+// Album / Track"
+func browsed(h *harness) string {
+	artist, album := h.m.browseSelection()
+	if artist == nil {
+		return ""
+	}
+	names, selected := h.m.browseColumn(browseTracks)
+	return artist.Name + " / " + album.Name + " / " + strings.TrimSpace(names[selected])
+}
+
+// TestModelBrowse moves about the browse screen and plays or queues what
+// is selected
+func TestModelBrowse(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		// selected is what is selected while the screen stays open
+		selected string
+		playing  string
+		queued   []string
+		banner   string
+	}{
+		{"opens on the current track", nil, "Radiohead / OK Computer / 1. Airbag", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"esc closes", []string{"esc"}, "", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"up", []string{"up"}, "The Beatles / Abbey Road / 1. Come Together", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"home", []string{"home"}, "Adele / 21 / 1. Rolling in the Deep", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"end by album artist", []string{"end"}, "Various Artists / Trip Hop / 1. Glory Box", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"past the end", []string{"end", "down", "pgdown"}, "Various Artists / Trip Hop / 1. Glory Box", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"albums", []string{"right", "up"}, "Radiohead / Kid A / 1. Everything in Its Right Place", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"tracks", []string{"right", "right", "down"}, "Radiohead / OK Computer / 2. Paranoid Android", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"type ahead", []string{"a"}, "Adele / 21 / 1. Rolling in the Deep", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"type ahead past the article", []string{"b", "e", "a", "t"}, "The Beatles / Abbey Road / 1. Come Together", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"type ahead leading nowhere", []string{"a", "x"}, "Adele / 21 / 1. Rolling in the Deep", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"type ahead in albums", []string{"right", "k"}, "Radiohead / Kid A / 1. Everything in Its Right Place", "/music/radiohead/ok/01.mp3", nil, ""},
+		{"type ahead in tracks", []string{"right", "right", "p"}, "Radiohead / OK Computer / 2. Paranoid Android", "/music/radiohead/ok/01.mp3", nil, ""},
+		{
+			"play an artist", []string{"up", "enter"}, "", "/music/beatles/abbey/01.mp3",
+			[]string{"/music/beatles/abbey/02.mp3"}, "Playing everything by The Beatles, 2 tracks",
+		},
+		{
+			"play an artist album by album", []string{"enter"}, "", "/music/radiohead/kid/01.mp3",
+			[]string{"/music/radiohead/ok/01.mp3", "/music/radiohead/ok/02.mp3"}, "Playing everything by Radiohead, 3 tracks",
+		},
+		{
+			"play an album", []string{"right", "up", "enter"}, "", "/music/radiohead/kid/01.mp3",
+			nil, "Playing Kid A by Radiohead, 1 tracks",
+		},
+		{
+			"play from a track", []string{"right", "right", "down", "enter"}, "", "/music/radiohead/ok/02.mp3",
+			nil, "Playing  2. Paranoid Android",
+		},
+		{
+			"queue", []string{"up", "+"}, "", "/music/radiohead/ok/01.mp3",
+			[]string{"/music/beatles/abbey/01.mp3", "/music/beatles/abbey/02.mp3"}, "Queued everything by The Beatles, 2 tracks, 2 up next",
+		},
+		{
+			"queue with alt+enter", []string{"home", "alt+enter"}, "", "/music/radiohead/ok/01.mp3",
+			[]string{"/music/adele/21/01.mp3"}, "Queued everything by Adele, 1 tracks, 1 up next",
+		},
+		{
+			"queued after the queue", []string{"up", "+", "wait", "g", "home", "+"}, "", "/music/radiohead/ok/01.mp3",
+			[]string{"/music/beatles/abbey/01.mp3", "/music/beatles/abbey/02.mp3", "/music/adele/21/01.mp3"}, "Queued everything by Adele, 1 tracks, 3 up next",
+		},
+		{
+			"queued already", []string{"up", "+", "wait", "g", "up", "+"}, "The Beatles / Abbey Road / 1. Come Together", "/music/radiohead/ok/01.mp3",
+			[]string{"/music/beatles/abbey/01.mp3", "/music/beatles/abbey/02.mp3"}, "everything by The Beatles is queued already",
+		},
+		{
+			"play replaces the queue", []string{"up", "+", "wait", "g", "home", "enter"}, "", "/music/adele/21/01.mp3",
+			nil, "Playing everything by Adele, 1 tracks",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newBrowseHarness(t)
+			h.press("g")
+			for _, k := range tt.keys {
+				switch k {
+				case "alt+enter":
+					h.send(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+				case "wait":
+					// Until the banner gives way
+					h.advance(bannerDuration)
+				default:
+					h.press(k)
+				}
+			}
+
+			if h.m.browse.open != (tt.selected != "") {
+				t.Fatalf("open = %v, want %v", h.m.browse.open, tt.selected != "")
+			}
+			if got := browsed(h); tt.selected != "" && got != tt.selected {
+				t.Errorf("selected %q, want %q", got, tt.selected)
+			}
+			if got := h.m.currentTrack(); got != tt.playing {
+				t.Errorf("playing %s, want %s", got, tt.playing)
+			}
+			if got := h.m.tracks.Paths(h.m.queue.Tracks()); !slices.Equal(got, tt.queued) {
+				t.Errorf("queued %v, want %v", got, tt.queued)
+			}
+			if !strings.HasPrefix(h.m.banner, tt.banner) {
+				t.Errorf("banner %q, want %q", h.m.banner, tt.banner)
+			}
+		})
+	}
+}
+
+// TestModelBrowseFills opens the browse screen before the tag index read
+// any tags: it fills in as they come, keeping what is selected, and the
+// track playing moves from the tags it loaded with to those read
+func TestModelBrowseFills(t *testing.T) {
+	var paths []string
+	for _, track := range browseLibrary {
+		paths = append(paths, track.path)
+	}
+	h := newHarness(t, paths...).start()
+	h.press("g")
+	if view := ansi.Strip(h.m.View()); !strings.HasPrefix(view, "Browse · 1 artists · 1 tracks · 6 without tags read yet\n") {
+		t.Errorf("view with the tags of the track playing =\n%s", view)
+	}
+
+	steps := []struct {
+		tracks   []int
+		selected string
+		header   string
+	}{
+		{[]int{2}, "Radiohead / Kid A / 1. Everything in Its Right Place", "Browse · 2 artists · 2 tracks · 5 without tags read yet"},
+		{[]int{0, 5}, "Radiohead / Kid A / 1. Everything in Its Right Place", "Browse · 2 artists · 3 tracks · 4 without tags read yet"},
+		{[]int{1, 3, 4, 6}, "Radiohead / Kid A / 1. Everything in Its Right Place", "Browse · 4 artists · 7 tracks"},
+	}
+	for i, step := range steps {
+		tags := make(map[string]trackTags)
+		for _, track := range step.tracks {
+			tags[browseLibrary[track].path] = browseLibrary[track].tags
+		}
+		h.send(tagIndexedMsg{tags: tags})
+		if i == 0 {
+			// Selected by key, Radiohead stays put as Adele goes above
+			h.press("r", "right", "k")
+		}
+		if got := browsed(h); got != step.selected {
+			t.Errorf("step %d: selected %q, want %q", i, got, step.selected)
+		}
+		if view := ansi.Strip(h.m.View()); !strings.HasPrefix(view, step.header+"\n") {
+			t.Errorf("step %d: view =\n%s\nwant the header %q", i, view, step.header)
+		}
+	}
+}
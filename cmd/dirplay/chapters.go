@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// trackChapters returns the chapters of the current track that start
+// within it
+func (m *PlayerModel) trackChapters() []library.Chapter {
+	chapters := m.info.Chapters
+	if m.duration > 0 {
+		for len(chapters) > 0 && chapters[len(chapters)-1].Start >= m.duration {
+			chapters = chapters[:len(chapters)-1]
+		}
+	}
+	if len(chapters) < 2 {
+		return nil
+	}
+	return chapters
+}
+
+// chapterAt returns the chapter playing at pos, or -1 before the first
+func chapterAt(chapters []library.Chapter, pos time.Duration) int {
+	at := -1
+	for i, chapter := range chapters {
+		if chapter.Start > pos {
+			break
+		}
+		at = i
+	}
+	return at
+}
+
+// chapterLabel returns the chapter playing, e.g. "Chapter 3 of 12: Title",
+// or "" for a track without chapters
+func (m *PlayerModel) chapterLabel() string {
+	chapters := m.trackChapters()
+	at := chapterAt(chapters, m.position)
+	if at < 0 {
+		return ""
+	}
+	label := fmt.Sprintf("Chapter %d of %d", at+1, len(chapters))
+	if title := chapters[at].Title; title != "" {
+		label += ": " + title
+	}
+	return label
+}
+
+// next moves on to the next chapter of the current track, or to the next
+// track after the last chapter
+func (m *PlayerModel) next() tea.Cmd {
+	m.recordUndo()
+	chapters := m.trackChapters()
+	if at := chapterAt(chapters, m.position); m.playing && chapters != nil && at+1 < len(chapters) {
+		return m.seekTo(chapters[at+1].Start)
+	}
+	return m.skip(1)
+}
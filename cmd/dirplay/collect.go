@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+)
+
+// Options of "dirplay collect"
+var (
+	collectDest    string
+	collectCopy    bool
+	collectMove    bool
+	collectSymlink bool
+	collectDryRun  bool
+)
+
+// newCollectCommand creates the "collect" command, which gathers the files
+// of the notes still open into one directory
+func newCollectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect --dest <directory> --copy|--move|--symlink",
+		Short: "Copy, move or link the files of open notes into a directory and check the notes off",
+		Long: "collect reads the notes file and copies, moves or links the file of every\n" +
+			"open \"[ ]\" note into Artist/Album directories under --dest, then checks\n" +
+			"the note off as \"[x]\". A name already taken gets a numbered suffix. Notes\n" +
+			"that name no file, such as those written by older versions, and files that\n" +
+			"fail are reported and left open. With --move a note follows its file.",
+		Example:      "  dirplay collect --dest /mnt/phone/music --copy\n  dirplay collect --notes-file ~/notes.md --dest ~/Car --symlink --dry-run",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         runCollect,
+	}
+	cmd.Flags().StringVar(&notesFile, "notes-file", "", "notes file to collect from (default $DIRPLAY_NOTES or ~/track-notes.md)")
+	cmd.Flags().StringVar(&collectDest, "dest", "", "directory to collect the files into")
+	cmd.Flags().BoolVar(&collectCopy, "copy", false, "copy the files")
+	cmd.Flags().BoolVar(&collectMove, "move", false, "move the files, updating their notes")
+	cmd.Flags().BoolVar(&collectSymlink, "symlink", false, "link to the files instead of copying them")
+	cmd.Flags().BoolVar(&collectDryRun, "dry-run", false, "print what would be done without touching any file")
+	cmd.MarkFlagRequired("dest")
+	cmd.MarkFlagsOneRequired("copy", "move", "symlink")
+	cmd.MarkFlagsMutuallyExclusive("copy", "move", "symlink")
+	return cmd
+}
+
+// openNote matches a note not checked off yet, e.g. "[ ] Artist - ...",
+// also as an item of a Markdown list
+var openNote = regexp.MustCompile(`^\s*(?:[-*+] )?\[ \] `)
+
+// parseNote returns the file an open note is about, see noteEntry. Notes
+// checked off, other lines and notes from before notes named their file
+// have none; notes of files gc found missing keep theirs.
+func parseNote(line string) (path string, open bool) {
+	if !openNote.MatchString(line) {
+		return "", false
+	}
+	match := notePath.FindStringSubmatch(line)
+	if match == nil {
+		return "", true
+	}
+	return match[1], true
+}
+
+// checkOff marks an open note as done, and with movedTo set points it at
+// where its file went
+func checkOff(line, movedTo string) string {
+	at := strings.Index(line, "[ ]")
+	line = line[:at] + "[x]" + line[at+3:]
+	if movedTo == "" {
+		return line
+	}
+	match := notePath.FindStringSubmatchIndex(line)
+	return line[:match[0]] + "`" + movedTo + "`"
+}
+
+// collectMode is what collect does with each file
+type collectMode struct {
+	verb string // as reported, e.g. "copied"
+	do   func(source, target string) error
+}
+
+// collectTarget returns where under dest a file goes: a directory for its
+// album artist, or artist, and one for its album, keeping its name
+func collectTarget(dest, path string) string {
+	artist, album := "", ""
+	if file, err := os.Open(fspath.Long(path)); err == nil {
+		if tags, err := tag.ReadFrom(file); err == nil {
+			artist, album = tags.AlbumArtist(), tags.Album()
+			if artist == "" {
+				artist = tags.Artist()
+			}
+		}
+		file.Close()
+	}
+	return filepath.Join(dest, safeName(artist, "Unknown Artist"), safeName(album, "Unknown Album"), filepath.Base(path))
+}
+
+// safeName makes a tag usable as a directory name on any file system the
+// files may be collected to, e.g. a phone's FAT card, or returns fallback
+// when nothing is left of it
+func safeName(s, fallback string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(strings.TrimSpace(s), ". ")
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// freeName returns target, or target with " (2)", " (3)" and so on before
+// its extension when that is taken on disk or by an earlier file of the
+// same run. It fails when target can't be looked up, e.g. when one of
+// its directories is a file.
+func freeName(target string, taken map[string]bool) (string, error) {
+	ext := filepath.Ext(target)
+	stem := strings.TrimSuffix(target, ext)
+	for n := 2; ; n++ {
+		_, err := os.Lstat(fspath.Long(target))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if err != nil && !taken[target] {
+			return target, nil
+		}
+		target = stem + " (" + strconv.Itoa(n) + ")" + ext
+	}
+}
+
+// copyFile copies source to target, which must not exist yet, keeping its
+// modification time. A failed copy leaves no partial file behind.
+func copyFile(source, target string) (err error) {
+	in, err := os.Open(fspath.Long(source))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(fspath.Long(target), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(fspath.Long(target))
+		}
+	}()
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(fspath.Long(target), info.ModTime(), info.ModTime())
+}
+
+// moveFile renames source to target, copying it and removing the source
+// when they are on different file systems
+func moveFile(source, target string) error {
+	if err := os.Rename(fspath.Long(source), fspath.Long(target)); err == nil {
+		return nil
+	}
+	if err := copyFile(source, target); err != nil {
+		return err
+	}
+	return os.Remove(fspath.Long(source))
+}
+
+// linkFile links target to source by its absolute path
+func linkFile(source, target string) error {
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(abs, fspath.Long(target))
+}
+
+// runCollect collects the files of the open notes. Every note is tried,
+// failures are reported at the end.
+func runCollect(cmd *cobra.Command, args []string) error {
+	notes, err := resolveNotesFile(notesFile)
+	if err != nil {
+		return err
+	}
+	dest := expandHome(collectDest)
+
+	mode := collectMode{verb: "copied", do: copyFile}
+	switch {
+	case collectMove:
+		mode = collectMode{verb: "moved", do: moveFile}
+	case collectSymlink:
+		mode = collectMode{verb: "linked", do: linkFile}
+	}
+	if collectDryRun {
+		mode.verb = "would be " + mode.verb
+	}
+
+	data, err := os.ReadFile(notes)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// A file noted more than once is collected once, its other notes are
+	// checked off along with it
+	done := make(map[string]string)
+	taken := make(map[string]bool)
+	collected, failed, unnamed := 0, 0, 0
+	for i, line := range lines {
+		path, open := parseNote(line)
+		if !open {
+			continue
+		}
+		if path == "" {
+			unnamed++
+			fmt.Printf("  no file  line %d: %s\n", i+1, line)
+			continue
+		}
+
+		target, ok := done[path]
+		if !ok {
+			if _, err := os.Stat(fspath.Long(path)); err != nil {
+				failed++
+				fmt.Printf("  failed   %s: %v\n", path, err)
+				continue
+			}
+			target, err = freeName(collectTarget(dest, path), taken)
+			if err != nil {
+				failed++
+				fmt.Printf("  failed   %s: %v\n", path, err)
+				continue
+			}
+			if !collectDryRun {
+				err := os.MkdirAll(fspath.Long(filepath.Dir(target)), 0755)
+				if err == nil {
+					err = mode.do(path, target)
+				}
+				if err != nil {
+					failed++
+					fmt.Printf("  failed   %s: %v\n", path, err)
+					continue
+				}
+			}
+			taken[target] = true
+			done[path] = target
+			collected++
+			fmt.Printf("  %-8s %s -> %s\n", mode.verb, path, target)
+		}
+
+		movedTo := ""
+		if collectMove {
+			movedTo = target
+		}
+		lines[i] = checkOff(line, movedTo)
+	}
+
+	fmt.Printf("%d file(s) %s", collected, mode.verb)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	if unnamed > 0 {
+		fmt.Printf(", %d note(s) name no file", unnamed)
+	}
+	fmt.Println()
+
+	if collected > 0 && !collectDryRun {
+		if err := rewriteWithBackup(notes, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+			return fmt.Errorf("could not check off the notes: %w", err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be collected", failed)
+	}
+	return nil
+}
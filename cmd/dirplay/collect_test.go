@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNote(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		wantPath string
+		wantOpen bool
+	}{
+		{"open", "[ ] great (at 01:02, 2026-01-02 03:04) `/music/01.mp3`", "/music/01.mp3", true},
+		{"list item", "- [ ] great (at 01:02, 2026-01-02 03:04) `/music/01.mp3`", "/music/01.mp3", true},
+		{"indented list item", "  * [ ] great `/music/01.mp3`", "/music/01.mp3", true},
+		{"missing", "[ ] great `/music/01.mp3` (missing)", "/music/01.mp3", true},
+		{"spaces in path", "[ ] great `/music/The Band/01 Song.mp3`", "/music/The Band/01 Song.mp3", true},
+		{"no file", "[ ] great (at 01:02, 2026-01-02 03:04)", "", true},
+		{"checked off", "[x] great `/music/01.mp3`", "", false},
+		{"header", "# DirPlay Track Notes", "", false},
+		{"empty", "", "", false},
+		{"not a checkbox", "see [ ] later `/music/01.mp3`", "", false},
+		{"path not last", "[ ] `/music/01.mp3` was great", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, open := parseNote(tt.line)
+			if path != tt.wantPath || open != tt.wantOpen {
+				t.Errorf("parseNote(%q) = %q, %v, want %q, %v", tt.line, path, open, tt.wantPath, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestCheckOff(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		movedTo string
+		want    string
+	}{
+		{"kept", "[ ] great `/music/01.mp3`", "", "[x] great `/music/01.mp3`"},
+		{"list item", "- [ ] great `/music/01.mp3`", "", "- [x] great `/music/01.mp3`"},
+		{"moved", "[ ] great `/music/01.mp3`", "/car/A/B/01.mp3", "[x] great `/car/A/B/01.mp3`"},
+		{"moved missing", "[ ] great `/music/01.mp3` (missing)", "/car/A/B/01.mp3", "[x] great `/car/A/B/01.mp3`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkOff(tt.line, tt.movedTo); got != tt.want {
+				t.Errorf("checkOff(%q, %q) = %q, want %q", tt.line, tt.movedTo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	dest := t.TempDir()
+	for _, name := range []string{"A/01.mp3", "A/02.mp3", "A/02 (2).mp3", "Blocked"} {
+		path := filepath.Join(dest, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		taken   []string
+		want    string
+		wantErr bool
+	}{
+		{"free", "A/03.mp3", nil, "A/03.mp3", false},
+		{"new directory", "B/01.mp3", nil, "B/01.mp3", false},
+		{"on disk", "A/01.mp3", nil, "A/01 (2).mp3", false},
+		{"numbered on disk", "A/02.mp3", nil, "A/02 (3).mp3", false},
+		{"taken this run", "A/03.mp3", []string{"A/03.mp3", "A/03 (2).mp3"}, "A/03 (3).mp3", false},
+		{"no extension", "A/cover", nil, "A/cover", false},
+		{"directory is a file", "Blocked/Album/01.mp3", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := make(map[string]bool)
+			for _, name := range tt.taken {
+				taken[filepath.Join(dest, filepath.FromSlash(name))] = true
+			}
+			got, err := freeName(filepath.Join(dest, filepath.FromSlash(tt.target)), taken)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("freeName(%s) error = %v, want error %v", tt.target, err, tt.wantErr)
+			}
+			if want := filepath.Join(dest, filepath.FromSlash(tt.want)); !tt.wantErr && got != want {
+				t.Errorf("freeName(%s) = %s, want %s", tt.target, got, want)
+			}
+		})
+	}
+}
+
+// TestRunCollectBlocked collects into a destination where the artist
+// directory is taken by a file: that file fails and its note stays open,
+// the others are collected
+func TestRunCollectBlocked(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music")
+	dest := filepath.Join(dir, "dest")
+	for _, d := range []string{music, dest} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	track := filepath.Join(music, "01.mp3")
+	if err := os.WriteFile(track, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notes := filepath.Join(dir, "notes.md")
+	note := "[ ] great `" + track + "`\n"
+	if err := os.WriteFile(notes, []byte(note), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(file, dest string, copy bool) {
+		notesFile, collectDest, collectCopy = file, dest, copy
+	}(notesFile, collectDest, collectCopy)
+	notesFile, collectDest, collectCopy = notes, dest, true
+
+	tests := []struct {
+		name string
+		// blocked makes the artist directory a file
+		blocked  bool
+		wantErr  bool
+		wantNote string
+	}{
+		{"blocked", true, true, note},
+		{"collected", false, false, "[x] great `" + track + "`\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artist := filepath.Join(dest, "Unknown Artist")
+			os.RemoveAll(artist)
+			if tt.blocked {
+				if err := os.WriteFile(artist, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := runCollect(nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runCollect error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "1 file(s)") {
+				t.Errorf("runCollect error = %v, want the file counted as failed", err)
+			}
+			if data, _ := os.ReadFile(notes); string(data) != tt.wantNote {
+				t.Errorf("notes file holds %q, want %q", data, tt.wantNote)
+			}
+		})
+	}
+}
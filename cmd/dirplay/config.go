@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/pflag"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// fileConfig holds the defaults read from config.toml. Options given on
+// the command line win over it.
+type fileConfig struct {
+	Directories  []string      `toml:"directories"`
+	Shuffle      string        `toml:"shuffle"`
+	AtEnd        string        `toml:"at_end"`
+	NotesFile    string        `toml:"notes_file"`
+	Volume       *float64      `toml:"volume"`
+	TickInterval string        `toml:"tick_interval"`
+	Theme        themeConfig   `toml:"theme"`
+	Presets      []soundPreset `toml:"preset"`
+
+	// path and data locate errors in the file
+	path string
+	data []byte
+}
+
+// themeConfig holds the [theme] colors, as "#RRGGBB" or an ANSI color
+// number
+type themeConfig struct {
+	Accent    string `toml:"accent"`
+	Text      string `toml:"text"`
+	Dim       string `toml:"dim"`
+	Banner    string `toml:"banner"`
+	Error     string `toml:"error"`
+	MeterLoud string `toml:"meter_loud"`
+}
+
+// defaultConfig is the template printed by --write-default-config
+const defaultConfig = `# dirplay configuration. Options given on the command line win over
+# these. Remove the # in front of a setting to change it.
+
+# Directories played when none are given on the command line
+# directories = ["~/Music"]
+
+# Shuffle mode: track, album or smart
+# shuffle = "track"
+
+# After the last track: repeat, stop, quit, rescan or exec:<command>
+# at_end = "repeat"
+
+# File the n and N keys append notes to
+# notes_file = "~/track-notes.md"
+
+# Output level from 0 to 1
+# volume = 1.0
+
+# How often the player view updates while playing, by default as the
+# shown time changes
+# tick_interval = "1s"
+
+# Colors as "#RRGGBB" or an ANSI color number, replacing those of the
+# dark or light theme chosen with --theme. The defaults below are the
+# dark theme's.
+[theme]
+# accent = "#04B575"
+# text = "#FAFAFA"
+# dim = "#626262"
+# banner = "#FFB86C"
+# error = "#FF5F5F"
+# meter_loud = "#F1C40F"
+
+# Presets bundle sound settings to switch between with P, or to start
+# with using --preset. A setting a preset leaves out keeps its value.
+# Speed goes from 0.5 to 3, and the equalizer takes the bass, mid and
+# treble gains from -12 to 12 dB.
+# [[preset]]
+# name = "night"
+# volume = 0.4
+# eq = [-3, 0, -2]
+# replaygain = "album"
+#
+# [[preset]]
+# name = "day"
+# volume = 1.0
+# eq = [0, 0, 0]
+# speed = 1.0
+`
+
+// colorPattern matches the colors lipgloss accepts in a theme
+var colorPattern = regexp.MustCompile(`^(#[0-9A-Fa-f]{6}|#[0-9A-Fa-f]{3}|[0-9]{1,3})$`)
+
+// configPath returns where config.toml is read from
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// loadConfig reads the config file at path, or config.toml in the config
+// directory when path is empty. Only the default file may be missing, in
+// which case the built-in defaults apply.
+func loadConfig(path string) (*fileConfig, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = configPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &fileConfig{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg.data = data
+
+	meta, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, cfg.errorAt(undecoded[0], "unknown setting")
+	}
+	return cfg, cfg.validate()
+}
+
+// validate checks the values the TOML types don't
+func (c *fileConfig) validate() error {
+	if c.Shuffle != "" && c.Shuffle != playlist.ShuffleTrack && c.Shuffle != playlist.ShuffleSmart && c.Shuffle != playlist.ShuffleAlbum {
+		return c.errorAt(toml.Key{"shuffle"}, "want %s, %s or %s", playlist.ShuffleTrack, playlist.ShuffleSmart, playlist.ShuffleAlbum)
+	}
+	if c.AtEnd != "" {
+		if err := validateAtEnd(c.AtEnd); err != nil {
+			return c.errorAt(toml.Key{"at_end"}, "want repeat, stop, quit, rescan or exec:<command>")
+		}
+	}
+	if c.Volume != nil && (*c.Volume < 0 || *c.Volume > 1) {
+		return c.errorAt(toml.Key{"volume"}, "want a level from 0 to 1")
+	}
+	if c.TickInterval != "" {
+		if d, err := time.ParseDuration(c.TickInterval); err != nil || d <= 0 {
+			return c.errorAt(toml.Key{"tick_interval"}, "want a duration such as \"500ms\"")
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, preset := range c.Presets {
+		if preset.Name == "" {
+			return fmt.Errorf("%s: preset: every preset needs a name", c.path)
+		}
+		if names[preset.Name] {
+			return fmt.Errorf("%s: preset %q is defined twice", c.path, preset.Name)
+		}
+		names[preset.Name] = true
+		if err := preset.validate(); err != nil {
+			return fmt.Errorf("%s: preset %q: %w", c.path, preset.Name, err)
+		}
+	}
+
+	colors := map[string]string{
+		"accent":     c.Theme.Accent,
+		"text":       c.Theme.Text,
+		"dim":        c.Theme.Dim,
+		"banner":     c.Theme.Banner,
+		"error":      c.Theme.Error,
+		"meter_loud": c.Theme.MeterLoud,
+	}
+	for name, color := range colors {
+		if color != "" && !colorPattern.MatchString(color) {
+			return c.errorAt(toml.Key{"theme", name}, "want \"#RRGGBB\" or an ANSI color number")
+		}
+	}
+	return nil
+}
+
+// errorAt reports a problem with a setting, naming its line when it can
+// be found
+func (c *fileConfig) errorAt(key toml.Key, format string, args ...any) error {
+	msg := fmt.Sprintf("%s: %s", key, fmt.Sprintf(format, args...))
+	if line := keyLine(c.data, key); line > 0 {
+		return fmt.Errorf("%s:%d: %s", c.path, line, msg)
+	}
+	return fmt.Errorf("%s: %s", c.path, msg)
+}
+
+// keyLine returns the line a key is set on, or 0
+func keyLine(data []byte, key toml.Key) int {
+	if len(key) == 0 {
+		return 0
+	}
+	table := strings.Join(key[:len(key)-1], ".")
+	name := key[len(key)-1]
+
+	current := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			current = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		field, _, ok := strings.Cut(line, "=")
+		if ok && current == table && strings.Trim(strings.TrimSpace(field), `"`) == name {
+			return n
+		}
+	}
+	return 0
+}
+
+// apply sets the flags the config covers unless they were given on the
+// command line. Set flags count as given, so they also win over the
+// toggles remembered from the last session.
+func (c *fileConfig) apply(flags *pflag.FlagSet) error {
+	values := map[string]string{
+		"shuffle":    c.Shuffle,
+		"at-end":     c.AtEnd,
+		"notes-file": expandHome(c.NotesFile),
+	}
+	for name, value := range values {
+		if value == "" || flags.Changed(name) {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTheme sets the [theme] colors on t. They have no effect on the
+// mono theme, which has no colors.
+func (c *fileConfig) applyTheme(t *uiTheme) {
+	if t.Mono {
+		return
+	}
+	for _, color := range []struct {
+		value  string
+		target *lipgloss.TerminalColor
+	}{
+		{c.Theme.Accent, &t.Accent},
+		{c.Theme.Text, &t.Text},
+		{c.Theme.Dim, &t.Dim},
+		{c.Theme.Banner, &t.Banner},
+		{c.Theme.Error, &t.Error},
+		{c.Theme.MeterLoud, &t.MeterLoud},
+	} {
+		if color.value != "" {
+			*color.target = lipgloss.Color(color.value)
+		}
+	}
+}
+
+// sources returns the configured music directories
+func (c *fileConfig) sources() []string {
+	dirs := make([]string, 0, len(c.Directories))
+	for _, dir := range c.Directories {
+		dirs = append(dirs, expandHome(dir))
+	}
+	return dirs
+}
+
+// volume returns the configured output level, 1 by default
+func (c *fileConfig) volume() float64 {
+	if c.Volume == nil {
+		return 1
+	}
+	return *c.Volume
+}
+
+// tickInterval returns the configured tick interval, or zero for the
+// built-in one
+func (c *fileConfig) tickInterval() time.Duration {
+	d, _ := time.ParseDuration(c.TickInterval)
+	return d
+}
+
+// expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + rest
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The control socket takes one command per line, of at most
+// controlMaxLine bytes, and gives up on the player after controlTimeout
+const (
+	controlMaxLine = 4096
+	controlTimeout = 2 * time.Second
+)
+
+// controlCommands are the commands the control socket understands
+var controlCommands = []string{"play", "pause", "next", "prev", "seek", "status", "queue", "quit"}
+
+// controlRequest is a command read from the control socket, either as a
+// line like "seek -10s" or as JSON like {"command":"seek","arg":"-10s"}
+type controlRequest struct {
+	Command string `json:"command"`
+	Arg     string `json:"arg,omitempty"`
+
+	// The seek target, relative to the position when signed
+	offset   time.Duration
+	relative bool
+}
+
+// controlReply answers a request, with the player's state once the
+// command was carried out
+type controlReply struct {
+	OK    bool          `json:"ok"`
+	Error string        `json:"error,omitempty"`
+	State *controlState `json:"state,omitempty"`
+}
+
+// controlState is the playback state reported over the control socket.
+// Times are in seconds.
+type controlState struct {
+	Status   string  `json:"status"`
+	Track    string  `json:"track,omitempty"`
+	Artist   string  `json:"artist,omitempty"`
+	Title    string  `json:"title,omitempty"`
+	Album    string  `json:"album,omitempty"`
+	Position float64 `json:"position"`
+	Duration float64 `json:"duration"`
+	Shuffle  string  `json:"shuffle"`
+	Queued   int     `json:"queued"`
+}
+
+// controlMsg carries a request into the program. The model answers on
+// reply, which has room for the answer so the model never blocks.
+type controlMsg struct {
+	request controlRequest
+	reply   chan<- controlReply
+}
+
+// controlServer accepts connections on the --listen socket and passes
+// their commands to the program
+type controlServer struct {
+	listener net.Listener
+	send     func(tea.Msg)
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// defaultControlAddr is where "dirplay ctl" connects unless told otherwise
+func defaultControlAddr() string {
+	return "unix:" + filepath.Join(controlDir(), "dirplay.sock")
+}
+
+// parseControlAddr splits a --listen address into a network and an
+// address: unix:<path>, or tcp:<host>:<port> on a loopback host
+func parseControlAddr(addr string) (network, address string, err error) {
+	invalid := fmt.Errorf("invalid --listen %q (want unix:<path> or tcp:<host>:<port> on a loopback host)", addr)
+
+	network, address, ok := strings.Cut(addr, ":")
+	if !ok || address == "" {
+		return "", "", invalid
+	}
+	switch network {
+	case "unix":
+		return network, address, nil
+	case "tcp":
+		host, _, err := net.SplitHostPort(address)
+		if err != nil || !isLoopback(host) {
+			return "", "", invalid
+		}
+		return network, address, nil
+	}
+	return "", "", invalid
+}
+
+// isLoopback reports whether host only reaches this machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// startControl listens on addr and serves commands until Close. A Unix
+// socket is only accessible to the user running dirplay; a stale one left
+// by a crash is replaced, a live one is refused.
+func startControl(addr string, send func(tea.Msg)) (*controlServer, error) {
+	network, address, err := parseControlAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var listener net.Listener
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+		listener, err = listenUnix(address)
+	} else {
+		listener, err = net.Listen(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &controlServer{
+		listener: listener,
+		send:     send,
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// removeStaleSocket deletes a socket file nothing listens on any more
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use, is dirplay already running?", path)
+	}
+	return os.Remove(path)
+}
+
+// Close stops listening, hangs up on connected clients and waits for
+// them to finish
+func (s *controlServer) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// serve accepts connections until the listener is closed
+func (s *controlServer) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		// Loopback listeners can still be reached through odd routes
+		if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !tcp.IP.IsLoopback() {
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+// handle answers the commands of one client, a line each, until it hangs
+// up. Malformed commands get an error reply; an overlong line ends the
+// connection.
+func (s *controlServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, controlMaxLine), controlMaxLine)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		request, err := parseControlRequest(line)
+		reply := controlReply{Error: fmt.Sprint(err)}
+		if err == nil {
+			reply = askControl(s.send, request)
+		}
+		if encoder.Encode(reply) != nil {
+			return
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		encoder.Encode(controlReply{Error: fmt.Sprintf("command longer than %d bytes", controlMaxLine)})
+	}
+}
+
+// askControl passes a request to the program with send and waits for its
+// reply
+func askControl(send func(tea.Msg), request controlRequest) controlReply {
+	reply := make(chan controlReply, 1)
+	send(controlMsg{request: request, reply: reply})
+
+	select {
+	case r := <-reply:
+		return r
+	case <-time.After(controlTimeout):
+		return controlReply{Error: "dirplay is not responding"}
+	}
+}
+
+// parseControlRequest reads a command line or JSON object and checks its
+// argument
+func parseControlRequest(line string) (controlRequest, error) {
+	var request controlRequest
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			return request, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		request.Command, request.Arg, _ = strings.Cut(line, " ")
+	}
+	request.Command = strings.ToLower(strings.TrimSpace(request.Command))
+	request.Arg = strings.TrimSpace(request.Arg)
+
+	if !slices.Contains(controlCommands, request.Command) {
+		return request, fmt.Errorf("unknown command %q (want %s)", request.Command, strings.Join(controlCommands, ", "))
+	}
+
+	switch request.Command {
+	case "seek":
+		offset, err := time.ParseDuration(request.Arg)
+		if err != nil {
+			return request, fmt.Errorf("seek wants a duration such as 1m30s, or -10s and +10s to move by it")
+		}
+		request.offset = offset
+		request.relative = strings.HasPrefix(request.Arg, "-") || strings.HasPrefix(request.Arg, "+")
+	case "queue":
+		if request.Arg == "" {
+			return request, fmt.Errorf("queue wants the path of an audio file")
+		}
+	default:
+		if request.Arg != "" {
+			return request, fmt.Errorf("%s takes no argument", request.Command)
+		}
+	}
+	return request, nil
+}
+
+// handleControl carries out a command from the control socket and replies
+// with the state it leaves behind. Track changes it starts are still
+// loading when the reply goes out.
+func (m *PlayerModel) handleControl(msg controlMsg) tea.Cmd {
+	request := msg.request
+
+	var cmd tea.Cmd
+	var err error
+	switch request.Command {
+	case "play":
+		cmd = m.handleRemote(remoteMsg{action: remotePlay})
+	case "pause":
+		cmd = m.handleRemote(remoteMsg{action: remotePause})
+	case "next":
+		cmd = m.handleRemote(remoteMsg{action: remoteNext})
+	case "prev":
+		cmd = m.handleRemote(remoteMsg{action: remotePrevious})
+	case "seek":
+		if !m.playing {
+			err = errors.New("nothing is playing")
+		} else if request.relative {
+			cmd = m.handleRemote(remoteMsg{action: remoteSeek, offset: request.offset})
+		} else {
+			cmd = m.handleRemote(remoteMsg{action: remoteSetPosition, offset: request.offset, track: m.current})
+		}
+	case "queue":
+		var banner string
+		if banner, err = m.queuePath(request.Arg); err == nil {
+			cmd = m.showBanner(banner)
+			if m.playing {
+				cmd = tea.Batch(cmd, m.preloadNext())
+			}
+		}
+	case "quit":
+		cmd = m.handleRemote(remoteMsg{action: remoteQuit})
+	}
+
+	if err != nil {
+		msg.reply <- controlReply{Error: err.Error()}
+		return nil
+	}
+	state := m.controlState()
+	msg.reply <- controlReply{OK: true, State: &state}
+	return cmd
+}
+
+// queuePath queues a file to play next, adding it to the playlist first
+// if it isn't in it, and returns what happened
+func (m *PlayerModel) queuePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if !m.codecs.IsAudioFile(path) {
+		return "", fmt.Errorf("%s is not an audio file", path)
+	}
+	if info, err := os.Stat(path); err != nil {
+		return "", err
+	} else if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+
+	inPlaylist := m.tracks.Known(path) && slices.Contains(m.fullPlaylist, m.tracks.Add(path))
+	if !inPlaylist {
+		m.appendPaths([]string{path})
+	}
+	id := m.tracks.Add(path)
+	if m.indexOf(id) < 0 {
+		return "", fmt.Errorf("%s is filtered out of the playlist", path)
+	}
+	if m.queuedAt(id) > 0 {
+		return "", fmt.Errorf("%s is already queued", path)
+	}
+	return m.toggleQueued(id), nil
+}
+
+// controlState returns the state reported over the control socket
+func (m *PlayerModel) controlState() controlState {
+	playing := m.nowPlaying()
+	shuffle := m.shuffleMode
+	if m.weighted != nil {
+		shuffle = "weighted"
+	}
+	return controlState{
+		Status:   strings.ToLower(playing.status),
+		Track:    playing.path,
+		Artist:   playing.artist,
+		Title:    playing.title,
+		Album:    playing.album,
+		Position: m.position.Seconds(),
+		Duration: m.duration.Seconds(),
+		Shuffle:  shuffle,
+		Queued:   m.queue.Len(),
+	}
+}
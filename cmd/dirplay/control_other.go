@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"net"
+	"os"
+)
+
+// controlDir is the directory the default socket goes in. The temp
+// directory is the user's own on Windows.
+func controlDir() string {
+	return os.TempDir()
+}
+
+// listenUnix listens on a Unix socket at path. Files in the user's
+// profile, where the temp directory is, aren't open to other users.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
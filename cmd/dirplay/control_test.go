@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStartControlSocketIsPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix socket permissions don't apply on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "dirplay.sock")
+	server, err := startControl("unix:"+path, func(tea.Msg) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%s is %v, want a socket", path, info.Mode())
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket permissions = %o, want 600", perm)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the socket", len(entries))
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	conn.Close()
+
+	server.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still there after Close: %v", err)
+	}
+}
+
+func TestStartControlRefusesLiveSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets are checked on Unix only")
+	}
+
+	path := filepath.Join(t.TempDir(), "dirplay.sock")
+	first, err := startControl("unix:"+path, func(tea.Msg) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if second, err := startControl("unix:"+path, func(tea.Msg) {}); err == nil {
+		second.Close()
+		t.Fatal("a second server took over a live socket")
+	}
+}
+
+func TestParseControlAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		address string
+		wantErr bool
+	}{
+		{"unix:/run/user/1000/dirplay.sock", "unix", "/run/user/1000/dirplay.sock", false},
+		{"tcp:127.0.0.1:7700", "tcp", "127.0.0.1:7700", false},
+		{"tcp:localhost:7700", "tcp", "localhost:7700", false},
+		{"tcp:[::1]:7700", "tcp", "[::1]:7700", false},
+		{"tcp:0.0.0.0:7700", "", "", true},
+		{"tcp:192.168.1.2:7700", "", "", true},
+		{"udp:127.0.0.1:7700", "", "", true},
+		{"unix:", "", "", true},
+		{"/tmp/dirplay.sock", "", "", true},
+	}
+	for _, tt := range tests {
+		network, address, err := parseControlAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseControlAddr(%q) error = %v, want error %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if network != tt.network || address != tt.address {
+			t.Errorf("parseControlAddr(%q) = %q, %q, want %q, %q", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// controlDir is the directory the default socket goes in: the runtime
+// directory of the session, or a directory of the user's own under the
+// shared temp directory, which other users can't reach into
+func controlDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("dirplay-%d", os.Getuid()))
+}
+
+// makeControlDir creates dir for the socket if it is the private one of
+// controlDir, refusing one someone else made accessible to others
+func makeControlDir(dir string) error {
+	if dir != controlDir() || os.Getenv("XDG_RUNTIME_DIR") != "" {
+		return nil
+	}
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || info.Mode().Perm()&0077 != 0 || !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s must be a directory only you can access", dir)
+	}
+	return nil
+}
+
+// listenUnix listens on a Unix socket at path that only this user can
+// connect to. The socket is made in a private directory beside path and
+// moved into place once its permissions are set, so it is never reachable
+// with the ones it is created with.
+func listenUnix(path string) (net.Listener, error) {
+	if err := makeControlDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".dirplay-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: listener, path: path}, nil
+}
+
+// unixListener removes its socket file on Close, from where it was moved
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
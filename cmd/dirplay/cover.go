@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// coverSavedMsg reports where "c" wrote the cover of a track, or that an
+// identical file was there already
+type coverSavedMsg struct {
+	path    string
+	existed bool
+	err     error
+}
+
+// folderOpenedMsg reports a file manager that couldn't be started
+type folderOpenedMsg struct {
+	err error
+}
+
+// coverExt returns the file extension for a cover, without the dot
+func coverExt(picture *tag.Picture) string {
+	ext := strings.ToLower(picture.Ext)
+	if ext == "" {
+		ext = "jpg"
+		if picture.MIMEType == "image/png" {
+			ext = "png"
+		}
+	}
+	return ext
+}
+
+// SetArtDir has "c" write covers to dir, named after their album, instead
+// of next to the tracks
+func (m *PlayerModel) SetArtDir(dir string) {
+	m.artDir = dir
+}
+
+// coverTarget returns where the cover of a track goes: cover.jpg or
+// cover.png next to it, or with --art-dir "Artist - Album" there
+func (m *PlayerModel) coverTarget(track string, picture *tag.Picture) string {
+	ext := "." + coverExt(picture)
+	if m.artDir == "" {
+		return filepath.Join(filepath.Dir(track), "cover"+ext)
+	}
+
+	var parts []string
+	for _, part := range []string{m.artist, m.album} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	fallback := strings.TrimSuffix(filepath.Base(track), filepath.Ext(track))
+	return filepath.Join(m.artDir, safeName(strings.Join(parts, " - "), safeName(fallback, "cover"))+ext)
+}
+
+// saveCover writes the embedded cover art of the current track to a file
+// in the background
+func (m *PlayerModel) saveCover() tea.Cmd {
+	track := m.currentTrack()
+	if track == "" || scan.IsStreamURL(track) {
+		return m.showBanner("The current track isn't a file")
+	}
+	picture := m.player.GetPicture()
+	if picture == nil || len(picture.Data) == 0 {
+		return m.showBanner("This track has no embedded cover art")
+	}
+
+	target, data := m.coverTarget(track, picture), picture.Data
+	work := m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		path, existed, err := writeCover(target, data)
+		if err != nil {
+			work.Failed("save the cover of "+track, err)
+		}
+		return coverSavedMsg{path: path, existed: existed, err: err}
+	})
+}
+
+// writeCover writes data to target, creating its directory. A file there
+// already holding the same picture is kept and reported; one holding
+// another picture is left alone too, and the cover goes to target with
+// " (2)", " (3)" and so on before its extension instead.
+func writeCover(target string, data []byte) (path string, existed bool, err error) {
+	if err := os.MkdirAll(fspath.Long(filepath.Dir(target)), 0755); err != nil {
+		return "", false, err
+	}
+
+	ext := filepath.Ext(target)
+	stem := strings.TrimSuffix(target, ext)
+	for n := 1; ; n++ {
+		path = target
+		if n > 1 {
+			path = stem + " (" + strconv.Itoa(n) + ")" + ext
+		}
+
+		existing, err := os.ReadFile(fspath.Long(path))
+		if err == nil {
+			if bytes.Equal(existing, data) {
+				return path, true, nil
+			}
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", false, err
+		}
+
+		file, err := os.OpenFile(fspath.Long(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			// Written in the meantime, look at it again
+			n--
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(fspath.Long(path))
+			return "", false, err
+		}
+		return path, false, nil
+	}
+}
+
+// coverSaved flashes where the cover was written
+func (m *PlayerModel) coverSaved(msg coverSavedMsg) tea.Cmd {
+	switch {
+	case msg.err != nil:
+		return m.showBanner(fmt.Sprintf("Could not save the cover: %v", msg.err))
+	case msg.existed:
+		return m.showBanner("The cover is already in " + msg.path)
+	}
+	return m.showBanner("Saved the cover to " + msg.path)
+}
+
+// openTrackFolder opens the directory of the current track in the file
+// manager
+func (m *PlayerModel) openTrackFolder() tea.Cmd {
+	track := m.currentTrack()
+	if track == "" || scan.IsStreamURL(track) {
+		return m.showBanner("The current track isn't a file")
+	}
+	dir := filepath.Dir(track)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return func() tea.Msg {
+		return folderOpenedMsg{err: openFolder(dir)}
+	}
+}
+
+// folderOpened reports a file manager that couldn't be started
+func (m *PlayerModel) folderOpened(msg folderOpenedMsg) tea.Cmd {
+	if msg.err == nil {
+		return nil
+	}
+	return m.showBanner(fmt.Sprintf("Could not open the folder: %v", msg.err))
+}
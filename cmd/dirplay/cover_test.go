@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+func TestCoverExt(t *testing.T) {
+	tests := []struct {
+		picture tag.Picture
+		want    string
+	}{
+		{tag.Picture{Ext: "png", MIMEType: "image/png"}, "png"},
+		{tag.Picture{Ext: "JPG"}, "jpg"},
+		{tag.Picture{MIMEType: "image/png"}, "png"},
+		{tag.Picture{MIMEType: "image/jpeg"}, "jpg"},
+		{tag.Picture{}, "jpg"},
+	}
+	for _, tt := range tests {
+		if got := coverExt(&tt.picture); got != tt.want {
+			t.Errorf("coverExt(%+v) = %q, want %q", tt.picture, got, tt.want)
+		}
+	}
+}
+
+func TestCoverTarget(t *testing.T) {
+	track := filepath.FromSlash("/music/Ünïcode dir/Album One/01 Song.mp3")
+	artDir := filepath.FromSlash("/art")
+	tests := []struct {
+		name          string
+		artDir        string
+		artist, album string
+		picture       tag.Picture
+		want          string
+	}{
+		{"next to the track", "", "Artist", "Album", tag.Picture{Ext: "jpg"}, "/music/Ünïcode dir/Album One/cover.jpg"},
+		{"png next to the track", "", "Artist", "Album", tag.Picture{MIMEType: "image/png"}, "/music/Ünïcode dir/Album One/cover.png"},
+		{"art dir", artDir, "Artist", "Album", tag.Picture{Ext: "jpg"}, "/art/Artist - Album.jpg"},
+		{"art dir without artist", artDir, "", "Album", tag.Picture{Ext: "jpg"}, "/art/Album.jpg"},
+		{"art dir untagged", artDir, "", "", tag.Picture{Ext: "png"}, "/art/01 Song.png"},
+		{"art dir unsafe names", artDir, "AC/DC", "Who? Me.", tag.Picture{Ext: "jpg"}, "/art/AC_DC - Who_ Me.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &PlayerModel{artDir: tt.artDir, artist: tt.artist, album: tt.album}
+			if got := m.coverTarget(track, &tt.picture); got != filepath.FromSlash(tt.want) {
+				t.Errorf("coverTarget = %q, want %q", got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
+
+func TestWriteCover(t *testing.T) {
+	cover, other := []byte("cover art"), []byte("other art")
+	tests := []struct {
+		name string
+		// existing are the files there first, by name
+		existing map[string][]byte
+		want     string
+		existed  bool
+	}{
+		{"new", nil, "cover.jpg", false},
+		{"same picture there", map[string][]byte{"cover.jpg": cover}, "cover.jpg", true},
+		{"other picture there", map[string][]byte{"cover.jpg": other}, "cover (2).jpg", false},
+		{"same picture second", map[string][]byte{"cover.jpg": other, "cover (2).jpg": cover}, "cover (2).jpg", true},
+		{"two others there", map[string][]byte{"cover.jpg": other, "cover (2).jpg": other}, "cover (3).jpg", false},
+		{"gap", map[string][]byte{"cover (2).jpg": other}, "cover.jpg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "new dir ü")
+			if len(tt.existing) > 0 {
+				if err := os.Mkdir(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, data := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, existed, err := writeCover(filepath.Join(dir, "cover.jpg"), cover)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != filepath.Join(dir, tt.want) || existed != tt.existed {
+				t.Errorf("writeCover = %s, existed %v, want %s, existed %v", path, existed, tt.want, tt.existed)
+			}
+			if data, _ := os.ReadFile(path); string(data) != string(cover) {
+				t.Errorf("%s holds %q, want %q", tt.want, data, cover)
+			}
+			for name, data := range tt.existing {
+				if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != string(data) {
+					t.Errorf("%s changed to %q", name, got)
+				}
+			}
+		})
+	}
+
+	// A directory that can't be made
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := writeCover(filepath.Join(file, "cover.jpg"), cover); err == nil {
+		t.Error("writeCover under a file succeeded, want an error")
+	}
+}
+
+// TestModelSaveCover presses "c" on tracks with and without art
+func TestModelSaveCover(t *testing.T) {
+	cover := &tag.Picture{Ext: "jpg", Data: []byte("cover art")}
+	tests := []struct {
+		name    string
+		picture *tag.Picture
+		stream  bool
+		// presses is how often "c" is pressed
+		presses int
+		banner  string
+		files   []string
+	}{
+		{"saved", cover, false, 1, "Saved the cover to {dir}cover.jpg", []string{"01.mp3", "cover.jpg"}},
+		{"saved again", cover, false, 2, "The cover is already in {dir}cover.jpg", []string{"01.mp3", "cover.jpg"}},
+		{"no art", nil, false, 1, "This track has no embedded cover art", []string{"01.mp3"}},
+		{"empty art", &tag.Picture{Ext: "jpg"}, false, 1, "This track has no embedded cover art", []string{"01.mp3"}},
+		{"stream", cover, true, 1, "The current track isn't a file", []string{"01.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			track := filepath.Join(dir, "01.mp3")
+			if err := os.WriteFile(track, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.stream {
+				track = "http://radio.example/stream"
+			}
+			h := newHarness(t, track)
+			h.player.setTrack(track, fakeTrack{length: 10 * time.Minute, picture: tt.picture})
+			h.start()
+			for i := range tt.presses {
+				if i > 0 {
+					// Until the banner gives way
+					h.advance(bannerDuration)
+				}
+				h.press("c")
+			}
+
+			want := strings.ReplaceAll(tt.banner, "{dir}", dir+string(filepath.Separator))
+			if h.m.banner != want {
+				t.Errorf("banner %q, want %q", h.m.banner, want)
+			}
+			entries, _ := os.ReadDir(dir)
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			if strings.Join(files, " ") != strings.Join(tt.files, " ") {
+				t.Errorf("directory holds %v, want %v", files, tt.files)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// pollCrashes returns a command skipping the current track when its
+// decoder panicked. Crashes of other tracks, e.g. one preloaded but left,
+// only mark them bad.
+func (m *PlayerModel) pollCrashes() tea.Cmd {
+	select {
+	case crash := <-m.player.Crashes():
+		if !m.playing || crash.Path != m.currentTrack() {
+			m.failed[crash.Path] = crash.Err
+			return nil
+		}
+		m.player.Stop()
+		id := m.current
+		return func() tea.Msg {
+			return playErrorMsg{id: id, path: crash.Path, err: crash.Err}
+		}
+	default:
+		return nil
+	}
+}
+
+// crashedBefore returns the error of a file whose decoder panicked earlier
+// this session, or nil
+func (m *PlayerModel) crashedBefore(path string) error {
+	if err := m.failed[path]; errors.Is(err, player.ErrDecoderPanic) {
+		return err
+	}
+	return nil
+}
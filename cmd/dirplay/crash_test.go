@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// TestModelDecoderPanics makes the decoder of the second track panic in
+// different ways: the model skips it, moves on, and doesn't open it again
+// once it crashed
+func TestModelDecoderPanics(t *testing.T) {
+	crashed := fmt.Errorf("%w: index out of range", player.ErrDecoderPanic)
+	tests := []struct {
+		name string
+		// crash reports the track crashed while it plays, early while the
+		// first track plays, as a preloaded track would
+		crash, early bool
+		loadErr      error
+		// loads counts the loads of the track on two visits
+		loads int
+	}{
+		{"crash while playing", true, false, nil, 1},
+		{"crash of the preloaded track", false, true, nil, 0},
+		{"panic loading", false, false, crashed, 1},
+		{"other load error", false, false, errFakeDecode, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := album(3)
+			h := newHarness(t, tracks...)
+			h.player.setTrack(tracks[1], fakeTrack{length: time.Minute, loadErr: tt.loadErr})
+			h.start()
+			h.advance(5 * time.Second)
+
+			if tt.early {
+				h.player.crashes <- player.Crash{Path: tracks[1], Err: crashed}
+				h.advance(time.Second)
+				if h.playing() != "01.mp3" || !h.m.playing {
+					t.Fatalf("on %s, playing = %v after another track crashed, want 01.mp3 playing", h.playing(), h.m.playing)
+				}
+			}
+			h.press("right")
+			if tt.crash {
+				h.advance(time.Second)
+				h.player.crashes <- player.Crash{Path: tracks[1], Err: crashed}
+				h.advance(time.Second)
+			}
+			if h.m.playing || !strings.Contains(h.m.banner, "Skipping 02.mp3") {
+				t.Fatalf("playing = %v with banner %q, want 02.mp3 skipped", h.m.playing, h.m.banner)
+			}
+			h.advance(skipDelay)
+			if h.playing() != "03.mp3" || !h.m.playing {
+				t.Fatalf("on %s, playing = %v after skipDelay, want 03.mp3 playing", h.playing(), h.m.playing)
+			}
+
+			// The second visit
+			h.press("right", "right")
+			if !strings.Contains(h.m.banner, "Skipping 02.mp3") {
+				t.Errorf("banner = %q on the second visit, want 02.mp3 skipped", h.m.banner)
+			}
+			loads := 0
+			for _, call := range h.player.log() {
+				if call == "load 02.mp3" {
+					loads++
+				}
+			}
+			if loads != tt.loads {
+				t.Errorf("02.mp3 loaded %d times, want %d", loads, tt.loads)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ctlAddr is the control socket "dirplay ctl" talks to
+var ctlAddr string
+
+// newCtlCommand creates the "ctl" command, a client for --listen
+func newCtlCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ctl <command> [argument]",
+		Short: "Control a dirplay started with --listen",
+		Long: "ctl sends a command to a running dirplay and prints its reply, a line of\n" +
+			"JSON with the playback state. The commands are play, pause, next, prev,\n" +
+			"seek <duration>, status, queue <path> and quit. seek jumps to a position,\n" +
+			"or moves by the duration when it starts with - or +.",
+		Example:      "  dirplay ctl status\n  dirplay ctl next\n  dirplay ctl seek -10s\n  dirplay ctl --listen tcp:127.0.0.1:7700 queue ~/Music/song.flac",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runCtl,
+	}
+	// "seek -10s" is an argument, not a flag
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().StringVar(&ctlAddr, "listen", defaultControlAddr(), "control socket of the running dirplay, as given to its --listen")
+	return cmd
+}
+
+// runCtl sends one command and prints the reply, failing when the
+// command did
+func runCtl(cmd *cobra.Command, args []string) error {
+	line := strings.Join(args, " ")
+	request, err := parseControlRequest(line)
+	if err != nil {
+		return err
+	}
+	// The player resolves paths against its own working directory
+	if request.Command == "queue" {
+		path, err := filepath.Abs(request.Arg)
+		if err != nil {
+			return err
+		}
+		line = request.Command + " " + path
+	}
+
+	network, address, err := parseControlAddr(ctlAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout(network, address, controlTimeout)
+	if err != nil {
+		return fmt.Errorf("could not reach dirplay, is it running with --listen %s? %w", ctlAddr, err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return errors.New("dirplay hung up without replying")
+	}
+
+	var reply controlReply
+	if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+		return fmt.Errorf("unexpected reply: %w", err)
+	}
+	if !reply.OK {
+		return errors.New(reply.Error)
+	}
+	fmt.Println(scanner.Text())
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Values of --dedupe
+const (
+	dedupeOff  = "off"
+	dedupeDeep = "deep"
+)
+
+// validateDedupe checks the --dedupe mode
+func validateDedupe(mode string) error {
+	switch mode {
+	case dedupeOff, dedupeName, dedupeTags, dedupeDeep:
+		return nil
+	}
+	return fmt.Errorf("invalid --dedupe mode %q (want %s, %s, %s or %s)", mode, dedupeOff, dedupeName, dedupeTags, dedupeDeep)
+}
+
+// signaturesMsg carries the signatures computed for a scan batch
+type signaturesMsg struct {
+	signatures map[string]signature
+}
+
+// duplicateFinder groups tracks whose signatures match. Batches are
+// fingerprinted one at a time in the background, busy holds the turn.
+type duplicateFinder struct {
+	cache      *signatureCache
+	busy       chan struct{}
+	signatures map[trackID]signature
+	groups     map[trackID]*duplicateGroup
+}
+
+// duplicateGroup is a set of tracks that sound the same, in the order
+// they were found
+type duplicateGroup struct {
+	tracks []trackID
+}
+
+// EnableDedupe fingerprints scanned tracks to find duplicates, caching
+// the signatures in cache
+func (m *PlayerModel) EnableDedupe(cache *signatureCache) {
+	m.dupes = &duplicateFinder{
+		cache:      cache,
+		busy:       make(chan struct{}, 1),
+		signatures: make(map[trackID]signature),
+		groups:     make(map[trackID]*duplicateGroup),
+	}
+}
+
+// fingerprint computes the signatures of a scan batch in the background
+func (m *PlayerModel) fingerprint(paths []string) tea.Cmd {
+	if m.dupes == nil || len(paths) == 0 {
+		return nil
+	}
+
+	finder := m.dupes
+	return m.work.Cmd(func(ctx context.Context) tea.Msg {
+		select {
+		case finder.busy <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		defer func() { <-finder.busy }()
+
+		// Files that can't be decoded are reported when they are played.
+		// What was computed before a quit is still cached.
+		signatures := make(map[string]signature)
+		for _, path := range paths {
+			if ctx.Err() != nil {
+				break
+			}
+			if sig, err := finder.cache.Signature(ctx, path); err == nil {
+				signatures[path] = sig
+			}
+		}
+		finder.cache.Save()
+		return signaturesMsg{signatures: signatures}
+	})
+}
+
+// addSignatures groups the new signatures with any matching ones
+func (m *PlayerModel) addSignatures(msg signaturesMsg) {
+	finder := m.dupes
+	for path, sig := range msg.signatures {
+		id := m.tracks.Add(path)
+		for other, otherSig := range finder.signatures {
+			if other != id && sig.distance(otherSig) <= duplicateDistance {
+				finder.join(id, other)
+			}
+		}
+		finder.signatures[id] = sig
+	}
+}
+
+// join puts two tracks in the same group, merging their groups if both
+// already have one
+func (f *duplicateFinder) join(id, other trackID) {
+	group, ok := f.groups[other]
+	if !ok {
+		group = &duplicateGroup{tracks: []trackID{other}}
+		f.groups[other] = group
+	}
+
+	switch own, ok := f.groups[id]; {
+	case !ok:
+		group.tracks = append(group.tracks, id)
+		f.groups[id] = group
+	case own != group:
+		for _, track := range own.tracks {
+			group.tracks = append(group.tracks, track)
+			f.groups[track] = group
+		}
+	}
+}
+
+// duplicateHint names another copy of the current track, or returns ""
+func (m *PlayerModel) duplicateHint() string {
+	if m.dupes == nil {
+		return ""
+	}
+	group, ok := m.dupes.groups[m.current]
+	if !ok {
+		return ""
+	}
+
+	var others []trackID
+	for _, id := range group.tracks {
+		if id != m.current {
+			others = append(others, id)
+		}
+	}
+	hint := "duplicate of " + filepath.Base(m.tracks.Path(others[0]))
+	if len(others) > 1 {
+		hint += fmt.Sprintf(" +%d more", len(others)-1)
+	}
+	return hint
+}
+
+// Duplicates returns the groups of tracks that sound the same, for the
+// report on exit
+func (m *PlayerModel) Duplicates() [][]string {
+	if m.dupes == nil {
+		return nil
+	}
+
+	var groups [][]string
+	reported := make(map[*duplicateGroup]bool)
+	for _, id := range m.fullPlaylist {
+		group, ok := m.dupes.groups[id]
+		if !ok || reported[group] {
+			continue
+		}
+		reported[group] = true
+		groups = append(groups, m.tracks.Paths(group.tracks))
+	}
+	return groups
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateDedupe(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{dedupeOff, false},
+		{dedupeName, false},
+		{dedupeTags, false},
+		{dedupeDeep, false},
+		{"", true},
+		{"Deep", true},
+		{"acoustid", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if err := validateDedupe(tt.mode); (err != nil) != tt.wantErr {
+				t.Errorf("validateDedupe(%q) = %v, want error %v", tt.mode, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testSignature returns a signature with the first n bits set
+func testSignature(n int) signature {
+	sig := make(signature, signatureWords)
+	for bit := range n {
+		sig[bit/64] |= 1 << (bit % 64)
+	}
+	return sig
+}
+
+// TestDuplicateGroups sends the signatures of tracks in batches, as the
+// scan finds them, and checks the groups and the hint of the first track
+func TestDuplicateGroups(t *testing.T) {
+	// near is as many bits as duplicates can differ in
+	threshold := duplicateDistance
+	near := int(threshold * float64(signatureBits))
+	tests := []struct {
+		name    string
+		batches []map[string]signature
+		groups  [][]string
+		hint    string
+	}{
+		{
+			name:    "no duplicates",
+			batches: []map[string]signature{{"01.mp3": testSignature(0), "02.mp3": testSignature(near + 1)}},
+		},
+		{
+			name:    "pair",
+			batches: []map[string]signature{{"01.mp3": testSignature(0)}, {"03.mp3": testSignature(near)}},
+			groups:  [][]string{{"01.mp3", "03.mp3"}},
+			hint:    "duplicate of 03.mp3",
+		},
+		{
+			name: "groups merged by a track matching both",
+			batches: []map[string]signature{
+				{"01.mp3": testSignature(0), "02.mp3": testSignature(2 * near)},
+				{"03.mp3": testSignature(near)},
+			},
+			groups: [][]string{{"01.mp3", "02.mp3", "03.mp3"}},
+			hint:   "duplicate of 02.mp3 +1 more",
+		},
+		{
+			name: "two groups",
+			batches: []map[string]signature{
+				{"01.mp3": testSignature(0), "02.mp3": testSignature(signatureBits)},
+				{"03.mp3": testSignature(1), "04.mp3": testSignature(signatureBits - 1)},
+			},
+			groups: [][]string{{"01.mp3", "03.mp3"}, {"02.mp3", "04.mp3"}},
+			hint:   "duplicate of 03.mp3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(4)...)
+			h.m.EnableDedupe(nil)
+			h.start()
+			for _, batch := range tt.batches {
+				msg := signaturesMsg{signatures: make(map[string]signature)}
+				for name, sig := range batch {
+					msg.signatures[filepath.Join("/music/album", name)] = sig
+				}
+				h.send(msg)
+			}
+
+			var groups [][]string
+			for _, group := range h.m.Duplicates() {
+				var names []string
+				for _, path := range group {
+					names = append(names, filepath.Base(path))
+				}
+				slices.Sort(names)
+				groups = append(groups, names)
+			}
+			if !slices.EqualFunc(groups, tt.groups, slices.Equal) {
+				t.Errorf("Duplicates() = %v, want %v", groups, tt.groups)
+			}
+			if got := h.m.duplicateHint(); got != tt.hint {
+				t.Errorf("duplicateHint() = %q, want %q", got, tt.hint)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// audioStallTimeout is how long the position may stand still while playing
+// before the output is taken to have stopped taking audio, e.g. after
+// Bluetooth headphones disconnected
+const audioStallTimeout = 5 * time.Second
+
+// errAudioStalled reports an output that stopped pulling samples
+var errAudioStalled = errors.New("the output stopped taking audio")
+
+// audioErrorMsg reports that playback failed while a track was playing
+type audioErrorMsg struct {
+	err error
+}
+
+// audioWatch notices playback that stopped moving. progressAt is when the
+// position last changed, tickAt when the last tick arrived.
+type audioWatch struct {
+	err        error
+	position   time.Duration
+	progressAt time.Time
+	tickAt     time.Time
+}
+
+// checkAudio returns an audioErrorMsg command when the stream failed or
+// the position stood still for audioStallTimeout while playing. Gaps
+// between ticks longer than that, such as a suspended laptop, start the
+// watch over instead.
+func (m *PlayerModel) checkAudio() tea.Cmd {
+	now := time.Now()
+	watch := &m.audioWatch
+	defer func() { watch.tickAt = now }()
+
+	if watch.err != nil || !m.playing || m.paused {
+		watch.progressAt = now
+		return nil
+	}
+
+	if err := m.player.Err(); err != nil {
+		return func() tea.Msg { return audioErrorMsg{err: err} }
+	}
+
+	// A stream waiting for the network plays silence, it isn't stuck
+	if m.player.Buffering() {
+		watch.progressAt = now
+		return nil
+	}
+
+	if m.position != watch.position || now.Sub(watch.tickAt) > audioStallTimeout {
+		watch.position = m.position
+		watch.progressAt = now
+		return nil
+	}
+	if now.Sub(watch.progressAt) > audioStallTimeout {
+		return func() tea.Msg { return audioErrorMsg{err: errAudioStalled} }
+	}
+	return nil
+}
+
+// audioFailed pauses playback on an output or stream error, rather than
+// racing through the playlist in silence, until it is retried
+func (m *PlayerModel) audioFailed(msg audioErrorMsg) {
+	if m.audioWatch.err != nil {
+		return
+	}
+	m.audioWatch.err = msg.err
+	if m.playing && !m.paused {
+		m.player.Pause()
+		m.paused = true
+	}
+}
+
+// retryAudio restarts the output and reopens the current track where it
+// stopped
+func (m *PlayerModel) retryAudio() tea.Cmd {
+	if m.audioWatch.err == nil {
+		return nil
+	}
+
+	if err := m.player.RestartOutput(); err != nil {
+		m.audioWatch.err = err
+		return nil
+	}
+	m.audioWatch = audioWatch{}
+	m.player.Stop()
+	m.resumeAt = m.position
+	return m.loadCurrentTrack()
+}
+
+// audioErrorLine describes the failure shown until it is retried, or ""
+func (m *PlayerModel) audioErrorLine() string {
+	if m.audioWatch.err == nil {
+		return ""
+	}
+	return fmt.Sprintf("Audio device error: %v — press %s to retry", m.audioWatch.err, m.keys.Retry.Help().Key)
+}
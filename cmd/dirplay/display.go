@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// defaultWidth is assumed until the terminal reports its size
+const defaultWidth = 80
+
+// viewWidth returns the terminal width the views are fitted to
+func (m *PlayerModel) viewWidth() int {
+	if m.width <= 0 {
+		return defaultWidth
+	}
+	return m.width
+}
+
+// sanitizeText makes names from tags and file systems safe to print:
+// bytes that aren't UTF-8, e.g. from a folder named in another code page,
+// and control characters, which could move the cursor or start an escape
+// sequence, become U+FFFD. Whitespace controls become spaces.
+func sanitizeText(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return ' '
+		case unicode.IsControl(r):
+			return '�'
+		}
+		return r
+	}, s)
+}
+
+// fitText sanitizes s and cuts it to at most width terminal cells, ending
+// in an ellipsis when cut. Wide characters count as two cells and
+// combining characters stay with their base.
+func fitText(s string, width int) string {
+	return ansi.Truncate(sanitizeText(s), max(width, 1), "…")
+}
+
+// fitLines fits each line to the view, leaving out those the terminal has
+// no rows for
+func (m *PlayerModel) fitLines(lines ...string) string {
+	if m.height > 0 && len(lines) > m.height {
+		lines = lines[:m.height]
+	}
+	for i, line := range lines {
+		lines[i] = fitText(line, m.viewWidth())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trackName returns the current track as "Artist - Title", falling back
+// to the file name without its extension when it has no title tag
+func (m *PlayerModel) trackName() string {
+	switch {
+	case m.title != "" && m.artist != "":
+		return m.artist + " - " + m.title
+	case m.title != "":
+		return m.title
+	}
+	name := filepath.Base(m.currentTrack())
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// stateIcon returns the symbol for playing, paused or stopped
+func (m *PlayerModel) stateIcon() string {
+	switch {
+	case m.playing && m.paused:
+		return glyphs.Pause
+	case m.playing:
+		return glyphs.Play
+	}
+	return glyphs.Stop
+}
+
+// timeLabel returns the position and length of the current track, both
+// with hours when either needs them
+func (m *PlayerModel) timeLabel() string {
+	return formatDurationFor(displayPosition(m.position, m.duration), m.duration) + " / " + formatLength(m.duration)
+}
+
+// trackInfoLine returns the playlist position of the current track and
+// what else there is to know about it
+func (m *PlayerModel) trackInfoLine() string {
+	info := fmt.Sprintf("Track %d of %d", m.currentIndex+1, len(m.playlist))
+	if m.weighted != nil {
+		info = m.playsLabel()
+	}
+	if label := m.albumLabel(); label != "" {
+		info += " · " + label
+	}
+	if stars := m.ratingLabel(); stars != "" {
+		info += " · " + stars
+	}
+	if label := m.rotationLabel(); label != "" {
+		info += fmt.Sprintf("  (%s)", label)
+	}
+	if m.filterQuery != "" {
+		info += fmt.Sprintf("  (filter: %s)", m.filterQuery)
+	}
+	if m.scanning {
+		info += fmt.Sprintf("  (scanning… %d files found)", m.scanFound)
+	}
+	if hint := m.duplicateHint(); hint != "" {
+		info += fmt.Sprintf("  (%s)", hint)
+	}
+	return info
+}
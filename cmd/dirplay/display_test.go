@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"plain", "Song", "Song"},
+		{"CP1251 folder", "\xcc\xf3\xe7\xfb\xea\xe0", "�"},
+		{"invalid byte in a name", "Bj\xf6rk", "Bj�rk"},
+		{"escape sequence", "a\x1b[2Jb", "a�[2Jb"},
+		{"whitespace controls", "a\tb\nc\rd", "a b c d"},
+		{"bell and delete", "a\x07b\x7f", "a�b�"},
+		{"CJK", "東京事変", "東京事変"},
+		{"combining", "Cafe\u0301", "Cafe\u0301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeText(tt.s); got != tt.want {
+				t.Errorf("sanitizeText(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitText(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  string
+	}{
+		{"fits", "Song", 10, "Song"},
+		{"exactly", "Song", 4, "Song"},
+		{"cut", "Songs", 4, "Son…"},
+		{"300 characters", long, 20, strings.Repeat("a", 19) + "…"},
+		{"CJK fits", "東京事変", 8, "東京事変"},
+		{"CJK cut between cells", "東京事変", 6, "東京…"},
+		{"combining kept with its base", "Cafe\u0301 Society", 6, "Cafe\u0301 …"},
+		{"sanitized first", "a\x1b[2Jb", 10, "a�[2Jb"},
+		{"no room", "Song", 0, "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitText(tt.s, tt.width)
+			if got != tt.want {
+				t.Errorf("fitText(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+			}
+			if w := lipgloss.Width(got); w > max(tt.width, 1) {
+				t.Errorf("fitText(%q, %d) is %d cells wide", tt.s, tt.width, w)
+			}
+		})
+	}
+}
+
+// TestViewFitsWidth renders the player and its panes on tracks with
+// awkward names at several widths: no line may be wider than the
+// terminal, and the progress bar stays on its row whatever the name
+func TestViewFitsWidth(t *testing.T) {
+	names := []struct {
+		name string
+		path string
+	}{
+		{"plain", "/music/album/01.mp3"},
+		{"300 characters", "/music/album/" + strings.Repeat("long name ", 30) + ".mp3"},
+		{"CJK", "/music/東京事変/" + strings.Repeat("群青日和", 20) + ".flac"},
+		{"combining", "/music/album/" + strings.Repeat("Cafe\u0301 ", 40) + ".mp3"},
+		{"CP1251", "/music/\xcc\xf3\xe7\xfb\xea\xe0/" + strings.Repeat("\xcf\xe5\xf1\xed\xff ", 30) + ".mp3"},
+	}
+	views := []struct {
+		name string
+		keys []string
+	}{
+		{"player", nil},
+		{"playlist", []string{"l"}},
+		{"history", []string{"right", "h"}},
+	}
+
+	// barRow returns the line the progress bar is on
+	barRow := func(view string) int {
+		for i, line := range strings.Split(view, "\n") {
+			if strings.Contains(line, glyphs.Empty+glyphs.Empty+glyphs.Empty) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, width := range []int{30, 80, 200} {
+		plainRow := -1
+		for _, tt := range names {
+			for _, v := range views {
+				t.Run(fmt.Sprint(tt.name, " ", v.name, " ", width), func(t *testing.T) {
+					h := newHarness(t, tt.path, "/music/album/02.mp3")
+					h.send(tea.WindowSizeMsg{Width: width, Height: 40})
+					h.start()
+					h.press(v.keys...)
+
+					view := h.m.View()
+					for i, line := range strings.Split(view, "\n") {
+						if w := ansi.StringWidth(line); w > width {
+							t.Errorf("line %d is %d cells wide at width %d:\n%s", i+1, w, width, line)
+						}
+					}
+					if v.keys != nil {
+						return
+					}
+					row := barRow(view)
+					if row < 0 {
+						t.Fatalf("no progress bar at width %d:\n%s", width, view)
+					}
+					if plainRow < 0 {
+						plainRow = row
+					}
+					if row != plainRow {
+						t.Errorf("progress bar on line %d at width %d, want line %d as for a plain name", row+1, width, plainRow+1)
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestViewRefitsOnResize checks the name is fitted again each time the
+// terminal changes size
+func TestViewRefitsOnResize(t *testing.T) {
+	h := newHarness(t, "/music/album/"+strings.Repeat("long name ", 30)+".mp3").start()
+	for _, width := range []int{200, 30, 120, 20, 80} {
+		h.send(tea.WindowSizeMsg{Width: width, Height: 24})
+		var playing string
+		for _, line := range strings.Split(h.m.View(), "\n") {
+			if strings.Contains(line, "Playing: ") {
+				playing = ansi.Strip(line)
+			}
+		}
+		if w := ansi.StringWidth(playing); w > width || w < width-4 {
+			t.Errorf("track line %d cells wide at width %d, want it cut near the edge: %q", w, width, playing)
+		}
+	}
+}
+
+func TestRenderProgressBar(t *testing.T) {
+	const length = 80 * time.Second
+	tests := []struct {
+		name     string
+		duration time.Duration
+		position time.Duration
+		playing  bool
+		// filled is the share of the bar filled
+		filled float64
+	}{
+		{"start", length, 0, true, 0},
+		{"half way", length, length / 2, true, 0.5},
+		{"end", length, length, true, 1},
+		{"past the end", length, length + 5*time.Second, true, 1},
+		{"unknown length", 0, 10 * time.Second, true, -1},
+		{"unknown length stopped", 0, 10 * time.Second, false, 0},
+	}
+	for _, set := range []struct {
+		name   string
+		glyphs glyphSet
+	}{{"unicode", unicodeGlyphs}, {"ascii", asciiGlyphs}} {
+		for _, width := range []int{0, 1, 7, 38, 118, 238} {
+			for _, tt := range tests {
+				t.Run(fmt.Sprint(set.name, " ", width, " ", tt.name), func(t *testing.T) {
+					defer func(saved glyphSet) { glyphs = saved }(glyphs)
+					glyphs = set.glyphs
+
+					h := newHarness(t)
+					h.m.duration, h.m.position, h.m.playing = tt.duration, tt.position, tt.playing
+					bar := h.m.renderProgressBar(width)
+					if w := ansi.StringWidth(bar); w != width+2 || !strings.HasPrefix(bar, "[") || !strings.HasSuffix(bar, "]") {
+						t.Fatalf("bar %q is %d cells, want %d with brackets", bar, w, width+2)
+					}
+
+					filled := strings.Count(bar, glyphs.Filled)
+					switch {
+					case tt.filled >= 0:
+						if want := int(tt.filled * float64(width)); filled != want {
+							t.Errorf("bar %q has %d cells filled, want %d", bar, filled, want)
+						}
+					case width > 4:
+						if filled != 4 || strings.HasPrefix(bar, "["+glyphs.Filled) {
+							t.Errorf("bar %q, want a block of 4 part way along", bar)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestProgressBarEighths checks the cell being played through fills by
+// eighths with the UTF-8 glyphs, and stays empty with the ASCII ones
+func TestProgressBarEighths(t *testing.T) {
+	tests := []struct {
+		position time.Duration
+		unicode  string
+		ascii    string
+	}{
+		{0, "[──────────]", "[----------]"},
+		{time.Second, "[▏─────────]", "[----------]"},
+		{10*time.Second + 500*time.Millisecond, "[█▎────────]", "[#---------]"},
+		{39 * time.Second, "[████▉─────]", "[####------]"},
+		{80 * time.Second, "[██████████]", "[##########]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.position.String(), func(t *testing.T) {
+			defer func(saved glyphSet) { glyphs = saved }(glyphs)
+			h := newHarness(t)
+			h.m.duration, h.m.position, h.m.playing = 80*time.Second, tt.position, true
+
+			glyphs = unicodeGlyphs
+			if bar := h.m.renderProgressBar(10); bar != tt.unicode {
+				t.Errorf("bar = %q, want %q", bar, tt.unicode)
+			}
+			glyphs = asciiGlyphs
+			if bar := h.m.renderProgressBar(10); bar != tt.ascii {
+				t.Errorf("ASCII bar = %q, want %q", bar, tt.ascii)
+			}
+		})
+	}
+}
+
+// TestProgressBarFitsView resizes the terminal mid-track and checks the
+// bar spans the view at each width
+func TestProgressBarFitsView(t *testing.T) {
+	h := newHarness(t, "/music/album/01.mp3").start()
+	h.advance(time.Minute)
+	for _, width := range []int{200, 50, 120, 30, 80} {
+		h.send(tea.WindowSizeMsg{Width: width, Height: 40})
+		bar := ""
+		for _, line := range strings.Split(h.m.View(), "\n") {
+			if line = ansi.Strip(line); strings.Contains(line, "["+glyphs.Filled) {
+				bar = line
+			}
+		}
+		if w := ansi.StringWidth(bar); w != width {
+			t.Errorf("bar is %d cells wide at width %d, want the view's width: %q", w, width, bar)
+		}
+		if want := h.m.renderProgressBar(width - 2); bar != want {
+			t.Errorf("bar = %q at width %d, want %q", bar, width, want)
+		}
+	}
+}
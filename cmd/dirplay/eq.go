@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// Each band's gain goes from -eqMaxGain to +eqMaxGain dB, set in steps of
+// eqStep
+const (
+	eqMaxGain = 12.0
+	eqStep    = 1.0
+)
+
+// eqPreset is a named set of gains, cycled through with Tab in the
+// equalizer
+type eqPreset struct {
+	name  string
+	gains player.EQGains
+}
+
+// eqPresets come in the order Tab cycles through them
+var eqPresets = []eqPreset{
+	{"flat", player.EQGains{0, 0, 0}},
+	{"bass boost", player.EQGains{6, 0, 0}},
+	{"treble boost", player.EQGains{0, 0, 6}},
+	{"voice", player.EQGains{-4, 3, 1}},
+	{"loudness", player.EQGains{5, 0, 4}},
+}
+
+// eqPane is the equalizer opened with "E", band being the selected one
+type eqPane struct {
+	open bool
+	band int
+}
+
+// EnableEQ restores the gains of an earlier session
+func (m *PlayerModel) EnableEQ(gains player.EQGains) {
+	for band := range gains {
+		gains[band] = min(max(gains[band], -eqMaxGain), eqMaxGain)
+	}
+	m.eq = gains
+	m.player.SetEQ(gains)
+}
+
+// setEQ applies new gains and remembers them
+func (m *PlayerModel) setEQ(gains player.EQGains) tea.Cmd {
+	if gains == m.eq {
+		return nil
+	}
+	m.eq = gains
+	m.player.SetEQ(gains)
+	return m.savePrefs()
+}
+
+// updateEQ handles keys while the equalizer is open: left and right pick
+// a band, up and down change its gain, Tab steps through the presets
+func (m *PlayerModel) updateEQ(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pane := &m.eqPane
+	gains := m.eq
+	switch msg.String() {
+	case "esc", "E":
+		pane.open = false
+		return m, nil
+	case "left":
+		pane.band = max(pane.band-1, 0)
+		return m, nil
+	case "right":
+		pane.band = min(pane.band+1, player.EQBands-1)
+		return m, nil
+	case "up":
+		gains[pane.band] = min(gains[pane.band]+eqStep, eqMaxGain)
+	case "down":
+		gains[pane.band] = max(gains[pane.band]-eqStep, -eqMaxGain)
+	case "tab":
+		gains = eqPresets[(m.eqPresetIndex()+1)%len(eqPresets)].gains
+	default:
+		return m, nil
+	}
+	return m, m.setEQ(gains)
+}
+
+// eqPresetIndex returns the preset the gains match, or -1
+func (m *PlayerModel) eqPresetIndex() int {
+	for i, preset := range eqPresets {
+		if preset.gains == m.eq {
+			return i
+		}
+	}
+	return -1
+}
+
+// eqName names the gains by their preset, or lists them
+func (m *PlayerModel) eqName() string {
+	if i := m.eqPresetIndex(); i >= 0 {
+		return eqPresets[i].name
+	}
+	parts := make([]string, player.EQBands)
+	for band, gain := range m.eq {
+		parts[band] = fmt.Sprintf("%+g", gain)
+	}
+	return strings.Join(parts, "/") + " dB"
+}
+
+// eqLabel returns the equalizer setting for the status line, or "" when
+// it is flat
+func (m *PlayerModel) eqLabel() string {
+	if m.eq == (player.EQGains{}) {
+		return ""
+	}
+	return "EQ " + m.eqName()
+}
+
+// viewEQ renders the equalizer in place of the player view, a slider per
+// band with 0 dB in the middle
+func (m *PlayerModel) viewEQ() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render("Equalizer · " + m.eqName()))
+	content.WriteString("\n\n")
+
+	steps := int(2 * eqMaxGain / eqStep)
+	for band, gain := range m.eq {
+		slider := []rune(strings.Repeat("─", steps+1))
+		slider[steps/2] = '┼'
+		slider[int((gain+eqMaxGain)/eqStep)] = '●'
+
+		info := player.EQBandSpecs[band]
+		freq := fmt.Sprintf("%g Hz", info.Freq)
+		if info.Freq >= 1000 {
+			freq = fmt.Sprintf("%g kHz", info.Freq/1000)
+		}
+		line := fitText(fmt.Sprintf("  %-6s %7s  %s  %+3g dB", info.Name, freq, string(slider), gain), m.viewWidth())
+		if band == m.eqPane.band {
+			content.WriteString(cursorStyle.Render(line))
+		} else {
+			content.WriteString(rowStyle.Render(line))
+		}
+		content.WriteString("\n")
+	}
+
+	content.WriteString("\n")
+	content.WriteString(dimStyle.Render(fitText("[←→] Band  [↑↓] Gain  [TAB] Preset  [ESC] Close", m.viewWidth())))
+	return content.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// remaining returns how long the current track plays on at the current
+// speed, or false while its length is unknown
+func (m *PlayerModel) remaining() (time.Duration, bool) {
+	if m.duration <= 0 {
+		return 0, false
+	}
+	left := m.duration - displayPosition(m.position, m.duration)
+	return time.Duration(float64(left) / m.speed), true
+}
+
+// remainingLabel counts the current track down, e.g. "-3:33", or returns
+// "" while its length is unknown
+func (m *PlayerModel) remainingLabel() string {
+	left, ok := m.remaining()
+	if !ok {
+		return ""
+	}
+	return "-" + formatDurationFor(left, m.duration)
+}
+
+// playlistETA adds up how long the rest of the playlist plays, the
+// current track included, at the current speed. Tracks whose length isn't
+// known yet are counted apart rather than guessed.
+func (m *PlayerModel) playlistETA() (left time.Duration, tracks, unknown int) {
+	if current, ok := m.remaining(); ok {
+		left = current
+	} else if m.playing {
+		unknown++
+	}
+
+	for _, id := range m.playlist[min(m.currentIndex+1, len(m.playlist)):] {
+		tracks++
+		length := m.knownTags[m.tracks.Path(id)].length
+		if length <= 0 {
+			unknown++
+			continue
+		}
+		left += time.Duration(float64(length) / m.speed)
+	}
+	return left, tracks, unknown
+}
+
+// etaLabel returns the time left in the playlist, shown with "d", e.g.
+// "~3h 42m left, 57 tracks (4 of unknown length)"
+func (m *PlayerModel) etaLabel() string {
+	left, tracks, unknown := m.playlistETA()
+	label := fmt.Sprintf("~%s left, %d tracks", formatETA(left), tracks)
+	if tracks == 1 {
+		label = fmt.Sprintf("~%s left, 1 track", formatETA(left))
+	}
+	if unknown > 0 {
+		label += fmt.Sprintf(" (%d of unknown length)", unknown)
+	}
+	return label
+}
+
+// formatETA rounds a long duration to minutes, e.g. "3h 42m"
+func formatETA(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+// recordLength keeps the decoded length of a played track in the tag
+// index, so the ETA knows it in later sessions
+func (m *PlayerModel) recordLength(path string, length time.Duration) {
+	if m.tagIndexer == nil || length <= 0 {
+		return
+	}
+	m.tagIndexer.index.SetLength(path, length)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// playlistExportedMsg reports where "e" wrote the playlist
+type playlistExportedMsg struct {
+	path  string
+	count int
+	err   error
+}
+
+// exportName returns the file name of a playlist exported at t
+func exportName(t time.Time) string {
+	return "dirplay-" + t.Format("20060102-150405") + ".m3u8"
+}
+
+// exportPlaylist writes the playlist, in playing order and with any filter
+// applied, to a timestamped .m3u8 in the working directory. Entries carry
+// the tags read so far, and --playlist plays the file back in the same
+// order.
+func (m *PlayerModel) exportPlaylist() tea.Cmd {
+	entries := make([]library.M3UEntry, 0, len(m.playlist))
+	for _, id := range m.playlist {
+		path := m.tracks.Path(id)
+		tags := m.knownTags[path]
+
+		entry := library.M3UEntry{Path: path, Title: tags.title, Length: tags.length}
+		switch {
+		case tags.title == "":
+			entry.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		case tags.artist != "":
+			entry.Title = tags.artist + " - " + tags.title
+		}
+		entries = append(entries, entry)
+	}
+	name := exportName(time.Now())
+
+	return func() tea.Msg {
+		// Absolute paths keep working wherever the playlist is moved
+		for i := range entries {
+			if abs, err := filepath.Abs(entries[i].Path); err == nil {
+				entries[i].Path = abs
+			}
+		}
+
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return playlistExportedMsg{err: err}
+		}
+		if err := library.WriteM3U(file, entries); err != nil {
+			file.Close()
+			return playlistExportedMsg{err: err}
+		}
+		if err := file.Close(); err != nil {
+			return playlistExportedMsg{err: err}
+		}
+
+		path, err := filepath.Abs(name)
+		if err != nil {
+			path = name
+		}
+		return playlistExportedMsg{path: path, count: len(entries)}
+	}
+}
+
+// playlistExported flashes where the playlist was written
+func (m *PlayerModel) playlistExported(msg playlistExportedMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.showBanner(fmt.Sprintf("Could not export playlist: %v", msg.err))
+	}
+	return m.showBanner(fmt.Sprintf("Exported %d tracks to %s", msg.count, msg.path))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultFade is how long pausing, resuming and skipping ramp the sound
+// down or up, short enough to feel immediate and long enough not to click
+const defaultFade = 150 * time.Millisecond
+
+// validateFade checks --fade
+func validateFade(fade time.Duration) error {
+	if fade < 0 {
+		return fmt.Errorf("invalid --fade %s (want a duration, 0 disables)", fade)
+	}
+	return nil
+}
+
+// SetFade sets how long pausing, resuming and skipping fade, see
+// player.AudioPlayer.SetFade
+func (m *PlayerModel) SetFade(fade time.Duration) {
+	m.player.SetFade(fade)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// trackTags holds the tags read for a track. Tags are only known once a
+// track has been loaded, until then filtering falls back to the path.
+type trackTags struct {
+	artist      string
+	title       string
+	album       string
+	albumArtist string
+	sortArtist  string
+	number      playlist.DiscTrack
+	length      time.Duration // decoded length
+}
+
+// matchesFilter reports whether a track matches a case-insensitive
+// substring query against its path and any known tags
+func matchesFilter(path string, tags trackTags, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	for _, field := range []string{path, tags.artist, tags.title, tags.album} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPlaylist returns the tracks matching query, keeping their order
+func filterPlaylist(playlist []string, tags map[string]trackTags, query string) []string {
+	var matches []string
+	for _, path := range playlist {
+		if matchesFilter(path, tags[path], query) {
+			matches = append(matches, path)
+		}
+	}
+	return matches
+}
+
+// newFilterInput creates the text input used by the "/" filter prompt
+func newFilterInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.Placeholder = "artist, title, album or path"
+	input.CharLimit = 200
+	return input
+}
+
+// openFilter shows the filter prompt, pre-filled with the active query
+func (m *PlayerModel) openFilter() tea.Cmd {
+	m.filtering = true
+	m.filterInput.SetValue(m.filterQuery)
+	m.filterInput.CursorEnd()
+	return m.filterInput.Focus()
+}
+
+// updateFilter handles keys while the filter prompt is open: Enter applies
+// the query, Esc cancels, everything else edits the query
+func (m *PlayerModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.filtering = false
+		m.filterInput.Blur()
+		return m, nil
+
+	case "enter":
+		query := strings.TrimSpace(m.filterInput.Value())
+		matches := m.filterTracks(query)
+		if len(matches) == 0 {
+			return m, m.showBanner(fmt.Sprintf("No tracks match %q", query))
+		}
+		m.filtering = false
+		m.filterInput.Blur()
+		return m, m.applyFilter(query, matches)
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+// applyFilter restricts playback to matches. The current track keeps
+// playing when it matches, otherwise the first match starts.
+func (m *PlayerModel) applyFilter(query string, matches []trackID) tea.Cmd {
+	m.filterQuery = query
+	if m.setPlaylist(matches) {
+		// The track after the current one may have changed
+		return m.preloadNext()
+	}
+
+	m.player.Stop()
+	m.leaveTrack()
+	m.currentIndex = 0
+	return m.loadCurrentTrack()
+}
+
+// filterTracks returns the tracks of the full playlist matching query,
+// keeping their order
+func (m *PlayerModel) filterTracks(query string) []trackID {
+	var matches []trackID
+	for _, id := range m.fullPlaylist {
+		path := m.tracks.Path(id)
+		if matchesFilter(path, m.knownTags[path], query) {
+			matches = append(matches, id)
+		}
+	}
+	return matches
+}
+
+// filterMatchCount returns how many tracks match the query being typed
+func (m *PlayerModel) filterMatchCount() int {
+	return len(m.filterTracks(m.filterInput.Value()))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// gcApply makes gc rewrite the stores instead of only reporting
+var gcApply bool
+
+// newGCCommand creates the "gc" maintenance command
+func newGCCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc <directory>...",
+		Short: "Report, and with --apply clean up, saved entries for files that no longer exist",
+		Long: "gc checks the notes file, saved playback state and album history for files\n" +
+			"inside the given directories that no longer exist. A missing file with a\n" +
+			"unique name elsewhere in the directories is treated as moved and re-linked.\n" +
+			"Nothing is changed without --apply; each rewritten file is backed up to .bak.",
+		Example:      "  dirplay gc ~/Music\n  dirplay gc ~/Music --apply",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runGC,
+	}
+	cmd.Flags().BoolVar(&gcApply, "apply", false, "rewrite the stores instead of only reporting")
+	cmd.Flags().StringVar(&notesFile, "notes-file", "", "notes file to check (default $DIRPLAY_NOTES or ~/track-notes.md)")
+	return cmd
+}
+
+// gcScan knows which files exist under the directories being checked
+type gcScan struct {
+	roots  []string
+	byName map[string][]string
+}
+
+// newGCScan scans the directories among args for relink candidates
+func newGCScan(args []string) (*gcScan, []error) {
+	found := &gcScan{byName: make(map[string][]string)}
+	for _, arg := range args {
+		paths, err := scan.ExpandArg(arg)
+		if err != nil {
+			continue
+		}
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				if abs, err := filepath.Abs(path); err == nil {
+					found.roots = append(found.roots, abs)
+				}
+			}
+		}
+	}
+
+	tracks, warnings := scanConfig(codec.New()).Collect(context.Background(), args)
+	for _, track := range tracks {
+		if abs, err := filepath.Abs(track); err == nil {
+			track = abs
+		}
+		name := filepath.Base(track)
+		found.byName[name] = append(found.byName[name], track)
+	}
+	return found, warnings
+}
+
+// check returns whether path is missing and, if it moved, where to. Paths
+// outside the scanned directories are never reported, their drive may just
+// not be mounted.
+func (s *gcScan) check(path string) (missing bool, movedTo string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, ""
+	}
+
+	covered := false
+	for _, root := range s.roots {
+		if library.Within(abs, root) {
+			covered = true
+			break
+		}
+	}
+	if !covered {
+		return false, ""
+	}
+
+	if _, err := os.Stat(abs); !errors.Is(err, os.ErrNotExist) {
+		return false, ""
+	}
+	if candidates := s.byName[filepath.Base(abs)]; len(candidates) == 1 {
+		return true, candidates[0]
+	}
+	return true, ""
+}
+
+// gcReport counts and prints what gc found
+type gcReport struct {
+	missing int
+	moved   int
+}
+
+// add prints one orphaned entry of a store
+func (r *gcReport) add(path, movedTo string) {
+	if movedTo != "" {
+		r.moved++
+		fmt.Printf("  moved    %s -> %s\n", path, movedTo)
+		return
+	}
+	r.missing++
+	fmt.Printf("  missing  %s\n", path)
+}
+
+// runGC checks every store against the directories
+func runGC(cmd *cobra.Command, args []string) error {
+	scan, warnings := newGCScan(args)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+	}
+	if len(scan.roots) == 0 {
+		return fmt.Errorf("no directories to check in %s", strings.Join(args, ", "))
+	}
+
+	notes, err := resolveNotesFile(notesFile)
+	if err != nil {
+		return err
+	}
+
+	report := &gcReport{}
+	var errs []error
+	if err := gcNotes(notes, scan, report); err != nil {
+		errs = append(errs, fmt.Errorf("notes: %w", err))
+	}
+	if err := gcState(scan, report); err != nil {
+		errs = append(errs, fmt.Errorf("playback state: %w", err))
+	}
+	if err := gcAlbums(scan, report); err != nil {
+		errs = append(errs, fmt.Errorf("album history: %w", err))
+	}
+
+	switch {
+	case report.missing+report.moved == 0:
+		fmt.Println("Nothing to clean up")
+	case gcApply:
+		fmt.Printf("Cleaned up %d missing and re-linked %d moved entries\n", report.missing, report.moved)
+	default:
+		fmt.Printf("%d missing and %d moved entries, run with --apply to clean them up\n", report.missing, report.moved)
+	}
+	return errors.Join(errs...)
+}
+
+// notePath matches the file at the end of a note, see noteEntry, and a
+// "(missing)" mark left by an earlier gc
+var notePath = regexp.MustCompile("`([^`]+)`( \\(missing\\))?$")
+
+// gcNotes marks notes of missing files with "(missing)" and re-links moved
+// ones. Notes are kept, they may hold something worth reading.
+func gcNotes(path string, scan *gcScan, report *gcReport) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	header := false
+	changed := false
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := notePath.FindStringSubmatchIndex(line)
+		if match == nil || match[4] >= 0 {
+			lines = append(lines, line)
+			continue
+		}
+
+		track := line[match[2]:match[3]]
+		missing, movedTo := scan.check(track)
+		if missing {
+			if !header {
+				fmt.Printf("%s:\n", path)
+				header = true
+			}
+			report.add(track, movedTo)
+			changed = true
+			if movedTo != "" {
+				line = line[:match[0]] + "`" + movedTo + "`"
+			} else {
+				line += " (missing)"
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if !changed || !gcApply {
+		return nil
+	}
+	return rewriteWithBackup(path, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// gcState drops missing files from the saved playlists and re-links moved
+// ones. When the saved current track goes, the session resumes with the
+// track after it.
+func gcState(scan *gcScan, report *gcReport) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	sf, err := readStateFile(path)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for key, st := range sf.Directories {
+		var playlist []string
+		index := 0
+		header := false
+		for i, track := range st.Playlist {
+			if i == st.CurrentIndex {
+				index = len(playlist)
+			}
+
+			missing, movedTo := scan.check(track)
+			if !missing {
+				playlist = append(playlist, track)
+				continue
+			}
+
+			if !header {
+				fmt.Printf("%s (%s):\n", path, key)
+				header = true
+			}
+			report.add(track, movedTo)
+			if movedTo != "" {
+				playlist = append(playlist, movedTo)
+			} else if i == st.CurrentIndex {
+				st.PositionMS = 0
+			}
+		}
+		if !header {
+			continue
+		}
+
+		st.Playlist = playlist
+		st.CurrentIndex = min(index, max(len(playlist)-1, 0))
+		sf.Directories[key] = st
+		changed = true
+	}
+
+	if !changed || !gcApply {
+		return nil
+	}
+	data, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return rewriteWithBackup(path, data)
+}
+
+// gcAlbums drops the history of album directories that no longer exist
+func gcAlbums(scan *gcScan, report *gcReport) error {
+	stats, err := loadAlbumStats()
+	if err != nil {
+		return err
+	}
+
+	header := false
+	for album := range stats.Albums {
+		missing, _ := scan.check(album)
+		if !missing {
+			continue
+		}
+		if !header {
+			fmt.Printf("%s:\n", stats.path)
+			header = true
+		}
+		report.add(album, "")
+		delete(stats.Albums, album)
+	}
+
+	if !header || !gcApply {
+		return nil
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return rewriteWithBackup(stats.path, data)
+}
+
+// rewriteWithBackup copies path to path.bak, then replaces it with data via
+// a temporary file so a crash leaves either the old or the new version
+func rewriteWithBackup(path string, data []byte) error {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".bak", old, 0644); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,69 @@
+package main
+
+// glyphSet holds the symbols the player view is drawn with
+type glyphSet struct {
+	Note    string
+	Play    string
+	Pause   string
+	Stop    string
+	Back    string // mouse buttons beside the play state
+	Forward string
+	Filled  string   // played part of the progress bar
+	Partial []string // the cell being played through, by eighths from 1/8 to 7/8
+	Empty   string   // rest of the progress bar
+	Preview string   // preview window on the progress bar
+	Chapter string   // chapter start on the progress bar
+	Loop    string   // ends of an A/B loop on the progress bar
+	Awake   string   // sleep inhibited while playing
+	Arrow   string   // a rate resampled to another
+	Meter   []rune   // level meter, quietest first
+}
+
+// unicodeGlyphs are the symbols used on terminals that show UTF-8
+var unicodeGlyphs = glyphSet{
+	Note:    "♪",
+	Play:    "▶",
+	Pause:   "⏸",
+	Stop:    "■",
+	Back:    "◀◀",
+	Forward: "▶▶",
+	Filled:  "█",
+	Partial: []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉"},
+	Empty:   "─",
+	Preview: "═",
+	Chapter: "│",
+	Loop:    "┃",
+	Awake:   "☕",
+	Arrow:   "→",
+	Meter:   []rune("▁▂▃▄▅▆▇█"),
+}
+
+// asciiGlyphs stand in on terminals that don't, such as conhost with its
+// default code page, where the others show as boxes
+var asciiGlyphs = glyphSet{
+	Note:    "#",
+	Play:    ">",
+	Pause:   "=",
+	Stop:    "x",
+	Back:    "<<",
+	Forward: ">>",
+	Filled:  "#",
+	Empty:   "-",
+	Preview: "=",
+	Chapter: "|",
+	Loop:    "!",
+	Awake:   "(awake)",
+	Arrow:   "->",
+	Meter:   []rune("_.-=+*#@"),
+}
+
+// glyphs is the set in use, chosen at startup
+var glyphs = unicodeGlyphs
+
+// useASCII switches to asciiGlyphs when forced with --ascii or when the
+// terminal doesn't show UTF-8
+func useASCII(forced bool) {
+	if forced || !terminalUTF8() {
+		glyphs = asciiGlyphs
+	}
+}
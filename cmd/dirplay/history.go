@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// The history keeps the last historyLimit tracks that played for at least
+// historyMinPlay, so skipping past a track or a failed load leaves no trace
+const (
+	historyLimit   = 50
+	historyMinPlay = 2 * time.Second
+)
+
+// Previous restarts the track or chapter playing once it has played for
+// more than defaultRestartAfter, unless pressed again within doublePress
+// of the press that restarted it
+const (
+	defaultRestartAfter = 3 * time.Second
+	doublePress         = 600 * time.Millisecond
+)
+
+// historyPane lists the history, newest first, opened with "h"
+type historyPane struct {
+	open   bool
+	cursor int
+	offset int
+}
+
+// newHistory returns an empty history
+func newHistory() *playlist.History[trackID] {
+	return playlist.NewHistory[trackID](historyLimit)
+}
+
+// endTrack records how far the current track played in the listen log
+// and play counts, and remembers the position of a long one. Every way of
+// leaving a track, quitting included, goes through it.
+func (m *PlayerModel) endTrack() {
+	m.endListen()
+	m.endPlayStats()
+	m.rememberPosition()
+}
+
+// leaveTrack records the current track in the history before playback
+// moves on from it, if it played long enough, see endTrack
+func (m *PlayerModel) leaveTrack() {
+	m.endTrack()
+	if m.current != noTrack && m.position-m.preview.from >= historyMinPlay {
+		m.history.Add(m.current, m.startedAt)
+	}
+	// A track that fails to load next must not inherit the position
+	m.position = 0
+}
+
+// leaveTrackBack is leaveTrack for going back to an earlier track. The
+// track left isn't added to the history, or going back again would
+// return to it.
+func (m *PlayerModel) leaveTrackBack() {
+	m.endTrack()
+	m.position = 0
+}
+
+// back returns to the track that played before the current one. Entries
+// filtered out of the playlist are passed over, and with no history left
+// it steps back through the playlist instead, except with --weights.
+func (m *PlayerModel) back() tea.Cmd {
+	for {
+		entry, ok := m.history.Pop()
+		if !ok {
+			break
+		}
+		if index := m.indexOf(entry.Track); index >= 0 && entry.Track != m.current {
+			m.player.Stop()
+			m.leaveTrackBack()
+			m.queue.Leave()
+			m.currentIndex = index
+			return m.loadCurrentTrack()
+		}
+	}
+	if m.weighted != nil {
+		// Picked tracks have no order to step back through
+		return m.showBanner("Nothing earlier to go back to")
+	}
+	return m.skip(-1)
+}
+
+// validateRestartAfter checks --restart-after
+func validateRestartAfter(after time.Duration) error {
+	if after < 0 {
+		return fmt.Errorf("invalid --restart-after %s (want a duration of 0 or more, 0 always going back)", after)
+	}
+	return nil
+}
+
+// SetRestartAfter sets how long a track or chapter has to play before
+// previous restarts it instead of going back, 0 always going back
+func (m *PlayerModel) SetRestartAfter(after time.Duration) {
+	m.restartAfter = after
+}
+
+// previous is the previous key: it restarts the track or chapter playing
+// once it has played for more than restartAfter, and otherwise goes back
+// a chapter or to the track that played before. A second press soon after
+// one that restarted always goes back.
+func (m *PlayerModel) previous() tea.Cmd {
+	now := m.now()
+	again := now.Sub(m.restartedAt) < doublePress
+	m.restartedAt = time.Time{}
+	if !m.playing {
+		return m.back()
+	}
+	m.recordUndo()
+
+	// The player's position is fresher than the last tick's
+	position := m.player.GetPosition()
+	chapters := m.trackChapters()
+	at := chapterAt(chapters, position)
+	start := m.preview.from
+	if at >= 0 {
+		start = max(chapters[at].Start, start)
+	}
+
+	if !again && m.restartAfter > 0 && position-start > m.restartAfter {
+		m.restartedAt = now
+		return m.rewindTo(start)
+	}
+	if at > 0 {
+		return m.seekTo(chapters[at-1].Start)
+	}
+	return m.back()
+}
+
+// rewindTo plays the current track again from start, forgetting any
+// remembered position. A decoder that can't seek has the track loaded
+// again instead.
+func (m *PlayerModel) rewindTo(start time.Duration) tea.Cmd {
+	delete(m.bookmarks, m.currentTrack())
+	m.leaveLoop(start)
+	if err := m.player.Seek(start); err != nil {
+		m.player.Stop()
+		return m.loadCurrentTrack()
+	}
+	m.position = start
+	if m.mpris != nil {
+		m.mpris.Seeked(start)
+	}
+	return nil
+}
+
+// openHistory shows the history pane with the cursor on the newest entry
+func (m *PlayerModel) openHistory() {
+	m.historyPane = historyPane{open: true}
+}
+
+// historyAt returns the entry at a row of the pane, newest first
+func (m *PlayerModel) historyAt(row int) playlist.HistoryEntry[trackID] {
+	return m.history.Recent(row)
+}
+
+// updateHistory handles keys while the history pane has focus
+func (m *PlayerModel) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pane := &m.historyPane
+	switch msg.String() {
+	case "esc", "h":
+		pane.open = false
+	case "up":
+		pane.cursor = max(pane.cursor-1, 0)
+	case "down":
+		pane.cursor = max(min(pane.cursor+1, m.history.Len()-1), 0)
+	case "enter":
+		if m.history.Len() == 0 {
+			return m, nil
+		}
+		entry := m.historyAt(pane.cursor)
+		index := m.indexOf(entry.Track)
+		if index < 0 {
+			return m, m.showBanner("No longer in the playlist")
+		}
+		pane.open = false
+		m.recordUndo()
+		m.player.Stop()
+		m.leaveTrack()
+		m.queue.Leave()
+		m.currentIndex = index
+		return m, m.loadCurrentTrack()
+	}
+	return m, nil
+}
+
+// viewHistory renders the history pane in place of the player view
+func (m *PlayerModel) viewHistory() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor
+
+	pane := &m.historyPane
+	height := m.paneHeight()
+	if pane.cursor < pane.offset {
+		pane.offset = pane.cursor
+	}
+	if pane.cursor >= pane.offset+height {
+		pane.offset = pane.cursor - height + 1
+	}
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render(fitText(fmt.Sprintf("History · last %d tracks", m.history.Len()), m.viewWidth())))
+	content.WriteString("\n")
+
+	if m.history.Len() == 0 {
+		content.WriteString(dimStyle.Render("Nothing played yet"))
+		content.WriteString("\n")
+	}
+
+	end := min(pane.offset+height, m.history.Len())
+	for row := pane.offset; row < end; row++ {
+		entry := m.historyAt(row)
+		line := fitText(fmt.Sprintf("  %s  %s", entry.At.Format("15:04"), m.paneEntryName(m.tracks.Path(entry.Track))), m.viewWidth())
+		if row == pane.cursor {
+			content.WriteString(cursorStyle.Render(line))
+		} else {
+			content.WriteString(rowStyle.Render(line))
+		}
+		content.WriteString("\n")
+	}
+
+	content.WriteString(dimStyle.Render(fitText("[↑↓] Move  [ENTER] Play again  [ESC] Close", m.viewWidth())))
+	return content.String()
+}
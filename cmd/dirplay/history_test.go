@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestModelPrevious presses previous on the second track after it played
+// for a while, and again after a gap: previous restarts a track played for
+// more than the threshold, unless pressed again within doublePress of
+// such a restart
+func TestModelPrevious(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name   string
+		after  time.Duration
+		played time.Duration
+		// gap is the time before the second press, or -1 for none
+		gap   time.Duration
+		first string
+		calls []string
+		then  string
+	}{
+		{"under the threshold", defaultRestartAfter, 2 * time.Second, -1, "01.mp3", []string{"stop 02.mp3", "load 01.mp3", "play 01.mp3"}, ""},
+		{"at the threshold", defaultRestartAfter, defaultRestartAfter, -1, "01.mp3", []string{"stop 02.mp3", "load 01.mp3", "play 01.mp3"}, ""},
+		{"just over the threshold", defaultRestartAfter, defaultRestartAfter + ms, -1, "02.mp3", []string{"seek 00:00"}, ""},
+		{"long played", defaultRestartAfter, time.Minute, -1, "02.mp3", []string{"seek 00:00"}, ""},
+		{"threshold 0", 0, time.Minute, -1, "01.mp3", []string{"stop 02.mp3", "load 01.mp3", "play 01.mp3"}, ""},
+		{"custom threshold", 10 * time.Second, 9 * time.Second, -1, "01.mp3", []string{"stop 02.mp3", "load 01.mp3", "play 01.mp3"}, ""},
+		{"double press", defaultRestartAfter, time.Minute, 300 * ms, "02.mp3", []string{"seek 00:00"}, "01.mp3"},
+		{"double press at once", 100 * ms, time.Minute, 0, "02.mp3", []string{"seek 00:00"}, "01.mp3"},
+		{"double press just in time", 100 * ms, time.Minute, doublePress - ms, "02.mp3", []string{"seek 00:00"}, "01.mp3"},
+		{"second press too late", 100 * ms, time.Minute, doublePress, "02.mp3", []string{"seek 00:00"}, "02.mp3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(3)...)
+			h.m.SetRestartAfter(tt.after)
+			h.start()
+			h.advance(5 * time.Second)
+			h.press("right")
+			h.advance(tt.played)
+			h.player.log()
+
+			h.press("left")
+			if h.playing() != tt.first {
+				t.Errorf("previous went to %s, want %s", h.playing(), tt.first)
+			}
+			if got := h.player.log(); !slices.Equal(got, tt.calls) {
+				t.Errorf("player calls = %v, want %v", got, tt.calls)
+			}
+			if tt.first == "02.mp3" && h.m.position != 0 {
+				t.Errorf("position = %v after the restart, want 0", h.m.position)
+			}
+			if tt.gap < 0 {
+				return
+			}
+
+			h.advance(tt.gap)
+			h.press("left")
+			if h.playing() != tt.then {
+				t.Errorf("the second press went to %s, want %s", h.playing(), tt.then)
+			}
+		})
+	}
+}
+
+func TestValidateRestartAfter(t *testing.T) {
+	tests := []struct {
+		after   time.Duration
+		wantErr bool
+	}{
+		{defaultRestartAfter, false},
+		{0, false},
+		{time.Minute, false},
+		{-time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.after.String(), func(t *testing.T) {
+			if err := validateRestartAfter(tt.after); (err != nil) != tt.wantErr {
+				t.Errorf("validateRestartAfter(%v) = %v, want error %v", tt.after, err, tt.wantErr)
+			}
+		})
+	}
+}
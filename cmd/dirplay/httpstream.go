@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// Each listener of the HTTP stream has listenerBuffer of audio buffered for
+// it; one that falls further behind, or whose connection takes longer
+// than listenerWriteTimeout to take a write, is dropped. Clients asking for
+// ICY metadata get it every icyMetaInt bytes of audio.
+const (
+	listenerBuffer       = 5 * time.Second
+	listenerWriteTimeout = 10 * time.Second
+	icyMetaInt           = 16384
+)
+
+// errSlowListener ends the stream of a listener that fell too far behind
+var errSlowListener = errors.New("listener fell behind")
+
+// pcmRing is the audio buffered for one listener. The speaker writes to
+// it without ever waiting, see write; the listener's connection drains it.
+type pcmRing struct {
+	mu      sync.Mutex
+	buf     []byte
+	start   int
+	size    int
+	dropped bool
+
+	// ready has room for one wakeup, done is closed on drop
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// newPCMRing creates a ring holding up to size bytes
+func newPCMRing(size int) *pcmRing {
+	return &pcmRing{
+		buf:   make([]byte, size),
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// write appends p, or drops the listener if there is no room for it,
+// reporting whether the listener is still there
+func (r *pcmRing) write(p []byte) bool {
+	r.mu.Lock()
+	if r.dropped {
+		r.mu.Unlock()
+		return false
+	}
+	if len(r.buf)-r.size < len(p) {
+		r.drop()
+		r.mu.Unlock()
+		return false
+	}
+	end := (r.start + r.size) % len(r.buf)
+	n := copy(r.buf[end:], p)
+	copy(r.buf, p[n:])
+	r.size += len(p)
+	r.mu.Unlock()
+
+	select {
+	case r.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// drop ends the listener's stream, the caller must hold r.mu
+func (r *pcmRing) drop() {
+	if !r.dropped {
+		r.dropped = true
+		close(r.done)
+	}
+}
+
+// Close drops the listener
+func (r *pcmRing) Close() {
+	r.mu.Lock()
+	r.drop()
+	r.mu.Unlock()
+}
+
+// read takes buffered audio into p, waiting for some if there is none. It
+// fails once the listener was dropped, without handing out what is left,
+// or ctx is done.
+func (r *pcmRing) read(ctx context.Context, p []byte) (int, error) {
+	for {
+		r.mu.Lock()
+		if r.dropped {
+			r.mu.Unlock()
+			return 0, errSlowListener
+		}
+		if r.size > 0 {
+			first := r.buf[r.start:min(r.start+r.size, len(r.buf))]
+			n := copy(p, first)
+			if n == len(first) {
+				// The rest wrapped around to the start
+				n += copy(p[n:], r.buf[:r.size-n])
+			}
+			r.start = (r.start + n) % len(r.buf)
+			r.size -= n
+			r.mu.Unlock()
+			return n, nil
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-r.ready:
+		case <-r.done:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// streamTee copies the audio going to the speaker to the listeners of
+// the HTTP stream, as 16-bit PCM. It costs an atomic load while nobody
+// listens.
+type streamTee struct {
+	listeners atomic.Int32
+
+	mu    sync.Mutex
+	rings map[*pcmRing]bool
+
+	// Only used from the speaker's goroutine, in Stream
+	pcm []byte
+}
+
+// newStreamTee creates a tee with no listeners
+func newStreamTee() *streamTee {
+	return &streamTee{rings: make(map[*pcmRing]bool)}
+}
+
+// add starts copying the audio to ring
+func (t *streamTee) add(ring *pcmRing) {
+	t.mu.Lock()
+	t.rings[ring] = true
+	t.listeners.Store(int32(len(t.rings)))
+	t.mu.Unlock()
+}
+
+// remove stops copying the audio to ring
+func (t *streamTee) remove(ring *pcmRing) {
+	t.mu.Lock()
+	delete(t.rings, ring)
+	t.listeners.Store(int32(len(t.rings)))
+	t.mu.Unlock()
+}
+
+// Close drops every listener
+func (t *streamTee) Close() {
+	t.mu.Lock()
+	for ring := range t.rings {
+		ring.Close()
+	}
+	clear(t.rings)
+	t.listeners.Store(0)
+	t.mu.Unlock()
+}
+
+// Send converts samples to PCM and hands it to every listener. Listeners
+// without room for it are dropped rather than waited for, so a slow
+// connection never holds up the speaker.
+func (t *streamTee) Send(samples [][2]float64) {
+	if t.listeners.Load() == 0 || len(samples) == 0 {
+		return
+	}
+
+	t.pcm = t.pcm[:0]
+	for _, s := range samples {
+		t.pcm = binary.LittleEndian.AppendUint16(t.pcm, uint16(pcmSample(s[0])))
+		t.pcm = binary.LittleEndian.AppendUint16(t.pcm, uint16(pcmSample(s[1])))
+	}
+
+	t.mu.Lock()
+	for ring := range t.rings {
+		if !ring.write(t.pcm) {
+			delete(t.rings, ring)
+		}
+	}
+	t.listeners.Store(int32(len(t.rings)))
+	t.mu.Unlock()
+}
+
+// pcmSample converts a sample to 16 bits, clipping it to full scale
+func pcmSample(v float64) int16 {
+	return int16(math.Round(min(max(v, -1), 1) * math.MaxInt16))
+}
+
+// wavHeader is the header of an endless 16-bit stereo WAV stream. The
+// sizes are left at their maximum, which players take as "until the
+// connection closes".
+func wavHeader(rate beep.SampleRate) []byte {
+	const channels, bits = 2, 16
+	header := make([]byte, 0, 44)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, math.MaxUint32)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, 1) // PCM
+	header = binary.LittleEndian.AppendUint16(header, channels)
+	header = binary.LittleEndian.AppendUint32(header, uint32(rate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(rate)*channels*bits/8)
+	header = binary.LittleEndian.AppendUint16(header, channels*bits/8)
+	header = binary.LittleEndian.AppendUint16(header, bits)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, math.MaxUint32)
+	return header
+}
+
+// icyWriter interleaves ICY metadata with the audio, every icyMetaInt
+// bytes. The title goes out when it changed since the last block, which
+// is otherwise empty, as SHOUTcast does.
+type icyWriter struct {
+	w     io.Writer
+	until int
+	sent  string
+	title func() string
+}
+
+// Write passes p on, with metadata blocks where they fall due
+func (iw *icyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), iw.until)
+		if _, err := iw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+		iw.until -= n
+
+		if iw.until == 0 {
+			if _, err := iw.w.Write(iw.block()); err != nil {
+				return written, err
+			}
+			iw.until = icyMetaInt
+		}
+	}
+	return written, nil
+}
+
+// block returns the next metadata block: a length byte counting 16-byte
+// units, and StreamTitle padded to fill them
+func (iw *icyWriter) block() []byte {
+	title := iw.title()
+	if title == iw.sent {
+		return []byte{0}
+	}
+	iw.sent = title
+
+	meta := []byte(fmt.Sprintf("StreamTitle='%s';", title))
+	meta = meta[:min(len(meta), 255*16)]
+	units := (len(meta) + 15) / 16
+	block := make([]byte, 1+units*16)
+	block[0] = byte(units)
+	copy(block[1:], meta)
+	return block
+}
+
+// httpStreamServer serves what plays over HTTP for --http-stream: the
+// audio at /stream and the state "dirplay ctl status" reports at /status
+type httpStreamServer struct {
+	server *http.Server
+	out    *player.Output
+	send   func(tea.Msg)
+	tee    *streamTee
+
+	mu    sync.Mutex
+	title string
+}
+
+// validateHTTPStream checks --http-stream, a [host]:port to listen on
+func validateHTTPStream(addr string) error {
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return fmt.Errorf("invalid --http-stream %q (want [host]:port, e.g. :8090)", addr)
+	}
+	return nil
+}
+
+// startHTTPStream listens on addr and serves the stream of out until Close
+func startHTTPStream(addr string, out *player.Output, send func(tea.Msg)) (*httpStreamServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &httpStreamServer{out: out, send: send, tee: newStreamTee()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stream", s.serveStream)
+	mux.HandleFunc("GET /status", s.serveStatus)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Close drops the listeners and stops serving
+func (s *httpStreamServer) Close() {
+	s.tee.Close()
+	s.server.Close()
+}
+
+// Publish sets the title sent as ICY metadata from the track playing
+func (s *httpStreamServer) Publish(state nowPlaying) {
+	title := state.title
+	switch {
+	case state.artist != "" && title != "":
+		title = state.artist + " - " + title
+	case title == "" && state.path != "":
+		title = filepath.Base(state.path)
+	}
+
+	s.mu.Lock()
+	s.title = title
+	s.mu.Unlock()
+}
+
+// currentTitle returns the title for ICY metadata
+func (s *httpStreamServer) currentTitle() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.title
+}
+
+// serveStream streams the audio as WAV until the client hangs up or falls
+// behind, with ICY metadata if it asks for it with "Icy-MetaData: 1"
+func (s *httpStreamServer) serveStream(w http.ResponseWriter, r *http.Request) {
+	rate := s.out.SampleRate()
+	if rate == 0 {
+		http.Error(w, "Nothing has played yet, try again once a track started", http.StatusServiceUnavailable)
+		return
+	}
+
+	ring := newPCMRing(rate.N(listenerBuffer) * 4)
+	s.tee.add(ring)
+	defer s.tee.remove(ring)
+	defer ring.Close()
+
+	// ICY headers are lowercase, as clients expecting SHOUTcast look them up
+	header := w.Header()
+	header.Set("Content-Type", "audio/wav")
+	header.Set("Cache-Control", "no-cache, no-store")
+	header["icy-name"] = []string{"dirplay"}
+	var out io.Writer = w
+	if r.Header.Get("Icy-MetaData") == "1" {
+		header["icy-metaint"] = []string{strconv.Itoa(icyMetaInt)}
+		out = &icyWriter{w: w, until: icyMetaInt, title: s.currentTitle}
+	}
+
+	control := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for data := wavHeader(rate); ; {
+		control.SetWriteDeadline(time.Now().Add(listenerWriteTimeout))
+		if _, err := out.Write(data); err != nil {
+			return
+		}
+		if control.Flush() != nil {
+			return
+		}
+
+		n, err := ring.read(r.Context(), buf)
+		if err != nil {
+			return
+		}
+		data = buf[:n]
+	}
+}
+
+// serveStatus answers with the state the control socket reports for
+// "status", as JSON
+func (s *httpStreamServer) serveStatus(w http.ResponseWriter, r *http.Request) {
+	reply := askControl(s.send, controlRequest{Command: "status"})
+	if !reply.OK {
+		http.Error(w, reply.Error, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply.State)
+}
+
+// EnableHTTPStream copies the audio to server's listeners and keeps its
+// ICY title up to date
+func (m *PlayerModel) EnableHTTPStream(server *httpStreamServer) {
+	m.httpStream = server
+	m.player.SetTee(server.tee)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+func TestValidateHTTPStream(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{":8090", false},
+		{"0.0.0.0:8090", false},
+		{"localhost:0", false},
+		{"[::1]:8090", false},
+		{"8090", true},
+		{"localhost", true},
+		{"localhost:", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if err := validateHTTPStream(tt.addr); (err != nil) != tt.wantErr {
+				t.Errorf("validateHTTPStream(%q) = %v, want error %v", tt.addr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPCMRing writes to and reads from a ring of 8 bytes
+func TestPCMRing(t *testing.T) {
+	// A step writes write, which fits or not, or reads up to read bytes
+	type step struct {
+		write string
+		fits  bool
+		read  int
+		want  string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		// dropped is whether the listener ends up dropped
+		dropped bool
+	}{
+		{"in order", []step{{write: "abc", fits: true}, {write: "de", fits: true}, {read: 8, want: "abcde"}}, false},
+		{"short read", []step{{write: "abcde", fits: true}, {read: 2, want: "ab"}, {read: 8, want: "cde"}}, false},
+		{"full", []step{{write: "abcdefgh", fits: true}, {read: 8, want: "abcdefgh"}}, false},
+		{"wraps around", []step{{write: "abcdef", fits: true}, {read: 4, want: "abcd"}, {write: "ghijk", fits: true}, {read: 8, want: "efghijk"}}, false},
+		{"wraps around twice", []step{
+			{write: "abcdef", fits: true}, {read: 6, want: "abcdef"}, {write: "ghijkl", fits: true},
+			{read: 3, want: "ghi"}, {write: "mnopq", fits: true}, {read: 8, want: "jklmnopq"},
+		}, false},
+		{"overflow", []step{{write: "abcdef", fits: true}, {write: "ghi", fits: false}}, true},
+		{"too big", []step{{write: "abcdefghi", fits: false}}, true},
+		{"nothing after a drop", []step{{write: "abcdefghi", fits: false}, {write: "a", fits: false}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newPCMRing(8)
+			for _, step := range tt.steps {
+				if step.write != "" {
+					if got := ring.write([]byte(step.write)); got != step.fits {
+						t.Fatalf("write(%q) = %v, want %v", step.write, got, step.fits)
+					}
+					continue
+				}
+				p := make([]byte, step.read)
+				n, err := ring.read(context.Background(), p)
+				if err != nil || string(p[:n]) != step.want {
+					t.Fatalf("read(%d) = %q, %v, want %q", step.read, p[:n], err, step.want)
+				}
+			}
+
+			if !tt.dropped {
+				return
+			}
+			if _, err := ring.read(context.Background(), make([]byte, 8)); !errors.Is(err, errSlowListener) {
+				t.Errorf("read after the drop = %v, want %v", err, errSlowListener)
+			}
+		})
+	}
+}
+
+// TestPCMRingWaits reads from an empty ring until something ends the wait
+func TestPCMRingWaits(t *testing.T) {
+	tests := []struct {
+		name string
+		// end ends the wait
+		end     func(ring *pcmRing, cancel context.CancelFunc)
+		want    string
+		wantErr error
+	}{
+		{"write", func(ring *pcmRing, _ context.CancelFunc) { ring.write([]byte("abc")) }, "abc", nil},
+		{"close", func(ring *pcmRing, _ context.CancelFunc) { ring.Close() }, "", errSlowListener},
+		{"hang up", func(_ *pcmRing, cancel context.CancelFunc) { cancel() }, "", context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := newPCMRing(8)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			type result struct {
+				data string
+				err  error
+			}
+			done := make(chan result, 1)
+			go func() {
+				p := make([]byte, 8)
+				n, err := ring.read(ctx, p)
+				done <- result{string(p[:n]), err}
+			}()
+
+			select {
+			case r := <-done:
+				t.Fatalf("read returned %q, %v before anything was written", r.data, r.err)
+			case <-time.After(20 * time.Millisecond):
+			}
+			tt.end(ring, cancel)
+			select {
+			case r := <-done:
+				if r.data != tt.want || !errors.Is(r.err, tt.wantErr) {
+					t.Errorf("read = %q, %v, want %q, %v", r.data, r.err, tt.want, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("read still waiting")
+			}
+		})
+	}
+}
+
+func TestPCMSample(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want int16
+	}{
+		{0, 0},
+		{1, math.MaxInt16},
+		{-1, -math.MaxInt16},
+		{0.5, 16384},
+		{-0.5, -16384},
+		{1.5, math.MaxInt16},
+		{-7, -math.MaxInt16},
+		{1e-6, 0},
+	}
+	for _, tt := range tests {
+		if got := pcmSample(tt.in); got != tt.want {
+			t.Errorf("pcmSample(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWAVHeader(t *testing.T) {
+	tests := []struct {
+		rate     beep.SampleRate
+		byteRate uint32
+	}{
+		{44100, 176400},
+		{48000, 192000},
+		{22050, 88200},
+	}
+	for _, tt := range tests {
+		header := wavHeader(tt.rate)
+		le := binary.LittleEndian
+		switch {
+		case len(header) != 44:
+			t.Errorf("rate %d: header is %d bytes, want 44", tt.rate, len(header))
+		case string(header[0:4]) != "RIFF" || string(header[8:16]) != "WAVEfmt " || string(header[36:40]) != "data":
+			t.Errorf("rate %d: header chunks %q", tt.rate, header)
+		case le.Uint32(header[4:]) != math.MaxUint32 || le.Uint32(header[40:]) != math.MaxUint32:
+			t.Errorf("rate %d: sizes %d and %d, want them endless", tt.rate, le.Uint32(header[4:]), le.Uint32(header[40:]))
+		case le.Uint16(header[20:]) != 1 || le.Uint16(header[22:]) != 2 || le.Uint16(header[34:]) != 16:
+			t.Errorf("rate %d: format %d, %d channels, %d bits, want 16-bit stereo PCM", tt.rate, le.Uint16(header[20:]), le.Uint16(header[22:]), le.Uint16(header[34:]))
+		case le.Uint32(header[24:]) != uint32(tt.rate) || le.Uint32(header[28:]) != tt.byteRate || le.Uint16(header[32:]) != 4:
+			t.Errorf("rate %d: rate %d, %d bytes a second, blocks of %d", tt.rate, le.Uint32(header[24:]), le.Uint32(header[28:]), le.Uint16(header[32:]))
+		}
+	}
+}
+
+// TestStreamTee sends to a listener with room for the audio and one
+// without: the second is dropped, the first gets every sample in order
+func TestStreamTee(t *testing.T) {
+	tee := newStreamTee()
+	tee.Send([][2]float64{{1, 1}})
+
+	roomy, cramped := newPCMRing(64), newPCMRing(8)
+	tee.add(roomy)
+	tee.add(cramped)
+	if got := tee.listeners.Load(); got != 2 {
+		t.Fatalf("%d listeners, want 2", got)
+	}
+
+	tee.Send([][2]float64{{0, 1}, {-1, 0.5}})
+	tee.Send(nil)
+	tee.Send([][2]float64{{2, -2}})
+	if got := tee.listeners.Load(); got != 1 {
+		t.Errorf("%d listeners after the cramped one fell behind, want 1", got)
+	}
+	if _, err := cramped.read(context.Background(), make([]byte, 64)); !errors.Is(err, errSlowListener) {
+		t.Errorf("cramped listener read %v, want %v", err, errSlowListener)
+	}
+
+	p := make([]byte, 64)
+	n, err := roomy.read(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int16
+	for i := 0; i+1 < n; i += 2 {
+		got = append(got, int16(binary.LittleEndian.Uint16(p[i:])))
+	}
+	want := []int16{0, math.MaxInt16, -math.MaxInt16, 16384, math.MaxInt16, -math.MaxInt16}
+	if !slices.Equal(got, want) {
+		t.Errorf("roomy listener got %v, want %v", got, want)
+	}
+
+	tee.Close()
+	if _, err := roomy.read(context.Background(), p); !errors.Is(err, errSlowListener) || tee.listeners.Load() != 0 {
+		t.Errorf("after Close read %v with %d listeners, want every listener dropped", err, tee.listeners.Load())
+	}
+}
+
+// TestICYWriter writes three metadata intervals and a bit of audio in
+// chunks of different sizes, changing the title between writes
+func TestICYWriter(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	tests := []struct {
+		name   string
+		chunk  int
+		titles []string
+		// want are the metadata blocks, without their length byte and
+		// padding
+		want []string
+	}{
+		{"one write", 3*icyMetaInt + 10, []string{"A"}, []string{"StreamTitle='A';", "", ""}},
+		{"small writes", 1000, []string{"A"}, []string{"StreamTitle='A';", "", ""}},
+		{"interval writes", icyMetaInt, []string{"A", "A", "B", "B"}, []string{"StreamTitle='A';", "", "StreamTitle='B';"}},
+		{"title changes", icyMetaInt, []string{"A", "B", "A"}, []string{"StreamTitle='A';", "StreamTitle='B';", "StreamTitle='A';"}},
+		{"no title", icyMetaInt, []string{""}, []string{"", "", ""}},
+		{"long title", icyMetaInt, []string{long}, []string{("StreamTitle='" + long)[:255*16], "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio := make([]byte, 3*icyMetaInt+10)
+			for i := range audio {
+				audio[i] = byte(i % 251)
+			}
+			var out bytes.Buffer
+			writes := 0
+			iw := &icyWriter{w: &out, until: icyMetaInt, title: func() string {
+				return tt.titles[min(writes, len(tt.titles)-1)]
+			}}
+			for p := audio; len(p) > 0; writes++ {
+				n := min(len(p), tt.chunk)
+				if written, err := iw.Write(p[:n]); written != n || err != nil {
+					t.Fatalf("Write = %d, %v, want %d", written, err, n)
+				}
+				p = p[n:]
+			}
+
+			var gotAudio []byte
+			var got []string
+			for data := out.Bytes(); len(data) > 0; {
+				n := min(len(data), icyMetaInt)
+				gotAudio = append(gotAudio, data[:n]...)
+				data = data[n:]
+				if len(data) == 0 {
+					break
+				}
+				size := 1 + int(data[0])*16
+				got = append(got, strings.TrimRight(string(data[1:size]), "\x00"))
+				data = data[size:]
+			}
+			if !bytes.Equal(gotAudio, audio) {
+				t.Errorf("the audio came through changed")
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("metadata blocks %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPStreamPublish(t *testing.T) {
+	tests := []struct {
+		name  string
+		state nowPlaying
+		want  string
+	}{
+		{"artist and title", nowPlaying{path: "/music/01.mp3", artist: "Artist", title: "Song"}, "Artist - Song"},
+		{"title only", nowPlaying{path: "/music/01.mp3", title: "Song"}, "Song"},
+		{"untagged", nowPlaying{path: "/music/01.mp3", artist: "Artist"}, "01.mp3"},
+		{"nothing playing", nowPlaying{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &httpStreamServer{title: "stale"}
+			s.Publish(tt.state)
+			if got := s.currentTitle(); got != tt.want {
+				t.Errorf("title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHTTPStreamServe asks the server of a running model for the status
+// and the stream, which it has none of until a track opened the output
+func TestHTTPStreamServe(t *testing.T) {
+	h := newHarness(t, album(2)...)
+	s := &httpStreamServer{out: player.NewOutput(0), send: h.send, tee: newStreamTee()}
+	h.m.EnableHTTPStream(s)
+	h.start()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantType   string
+	}{
+		{"/status", http.StatusOK, "application/json"},
+		{"/stream", http.StatusServiceUnavailable, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			switch tt.path {
+			case "/status":
+				s.serveStatus(rec, request)
+			case "/stream":
+				s.serveStream(rec, request)
+			}
+			if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("%s answered %d %s, want %d %s", tt.path, rec.Code, rec.Header().Get("Content-Type"), tt.wantStatus, tt.wantType)
+			}
+			if tt.path != "/status" {
+				return
+			}
+			var state controlState
+			if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+				t.Fatal(err)
+			}
+			if state.Status != "playing" || state.Track != album(1)[0] {
+				t.Errorf("status %+v, want %s playing", state, album(1)[0])
+			}
+		})
+	}
+	if got := s.currentTitle(); got == "" {
+		t.Errorf("no ICY title published for %s", h.playing())
+	}
+}
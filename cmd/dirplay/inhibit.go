@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// inhibitTimeout bounds a call to take or release the inhibitor, and how
+// long quitting waits for the last one
+const inhibitTimeout = 2 * time.Second
+
+// Inhibitor keeps the system from going to sleep while music plays. Each
+// OS has its own, see newInhibitor; where there is none dirplay plays
+// without one.
+type Inhibitor interface {
+	// Inhibit blocks sleep until Release. Taking it again while held
+	// does nothing.
+	Inhibit() error
+	// Release lets the system sleep again. Releasing it while not held
+	// does nothing.
+	Release() error
+}
+
+// inhibitedMsg reports whether the inhibitor is held after a change, and
+// the error if the change failed
+type inhibitedMsg struct {
+	held bool
+	err  error
+}
+
+// sleepGuard takes and releases an Inhibitor on a goroutine of its own,
+// so a slow D-Bus doesn't hold up the player. The goroutine keeps to one
+// thread, since Windows ties the state to the thread that set it. Only
+// the last state asked for matters; changes in between are dropped.
+type sleepGuard struct {
+	inhibitor Inhibitor
+	send      func(tea.Msg)
+	want      chan bool
+	done      chan struct{}
+}
+
+// startSleepGuard starts the goroutine, which reports each change through
+// send
+func startSleepGuard(inhibitor Inhibitor, send func(tea.Msg)) *sleepGuard {
+	g := &sleepGuard{
+		inhibitor: inhibitor,
+		send:      send,
+		want:      make(chan bool, 1),
+		done:      make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+// run takes and releases the inhibitor as asked until want is closed,
+// then releases it
+func (g *sleepGuard) run() {
+	runtime.LockOSThread()
+	defer close(g.done)
+
+	held := false
+	for hold := range g.want {
+		if hold == held {
+			continue
+		}
+		var err error
+		if hold {
+			err = g.inhibitor.Inhibit()
+		} else {
+			err = g.inhibitor.Release()
+		}
+		if err == nil {
+			held = hold
+		}
+		g.send(inhibitedMsg{held: held, err: err})
+	}
+	if held {
+		g.inhibitor.Release()
+	}
+}
+
+// Set asks for the inhibitor to be held or released, without waiting
+func (g *sleepGuard) Set(hold bool) {
+	// Replace a change the goroutine hasn't picked up yet
+	select {
+	case <-g.want:
+	default:
+	}
+	g.want <- hold
+}
+
+// Close releases the inhibitor and stops the goroutine, waiting at most
+// inhibitTimeout for it
+func (g *sleepGuard) Close() {
+	close(g.want)
+	select {
+	case <-g.done:
+	case <-time.After(inhibitTimeout):
+	}
+}
+
+// EnableInhibit keeps the system awake through g while playback runs
+func (m *PlayerModel) EnableInhibit(g *sleepGuard) {
+	m.inhibit.guard = g
+}
+
+// inhibitState is the sleep inhibitor as the model sees it
+type inhibitState struct {
+	guard *sleepGuard
+	// wanted is what the guard was last asked for, held what it reported
+	wanted bool
+	held   bool
+	failed bool
+}
+
+// inhibitSleep holds the inhibitor while a track plays and releases it
+// once paused, stopped, put to sleep by the timer or quitting. It runs
+// after every update, like publish.
+func (m *PlayerModel) inhibitSleep() {
+	if m.inhibit.guard == nil {
+		return
+	}
+	want := m.playing && !m.paused && !m.ended && !m.quitting
+	if want != m.inhibit.wanted {
+		m.inhibit.wanted = want
+		m.inhibit.guard.Set(want)
+	}
+}
+
+// inhibited records a change of the inhibitor, reporting the first one
+// that failed
+func (m *PlayerModel) inhibited(msg inhibitedMsg) tea.Cmd {
+	m.inhibit.held = msg.held
+	if msg.err == nil || m.inhibit.failed {
+		return nil
+	}
+	m.inhibit.failed = true
+	return m.showBanner(fmt.Sprintf("Couldn't keep the system awake: %v", msg.err))
+}
+
+// awakeLabel returns the indicator shown while the inhibitor is held
+func (m *PlayerModel) awakeLabel() string {
+	if !m.inhibit.held {
+		return ""
+	}
+	return glyphs.Awake
+}
@@ -0,0 +1,50 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// caffeinateInhibitor runs caffeinate, which holds an IOKit power
+// assertion against idle sleep for as long as it runs. It is told to exit
+// with dirplay, so a crash can't leave sleep blocked.
+type caffeinateInhibitor struct {
+	path string
+	cmd  *exec.Cmd
+}
+
+// newInhibitor looks for caffeinate, which macOS ships
+func newInhibitor() (Inhibitor, error) {
+	path, err := exec.LookPath("caffeinate")
+	if err != nil {
+		return nil, err
+	}
+	return &caffeinateInhibitor{path: path}, nil
+}
+
+// Inhibit starts caffeinate
+func (c *caffeinateInhibitor) Inhibit() error {
+	if c.cmd != nil {
+		return nil
+	}
+	cmd := exec.Command(c.path, "-i", "-w", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.cmd = cmd
+	return nil
+}
+
+// Release stops caffeinate
+func (c *caffeinateInhibitor) Release() error {
+	if c.cmd == nil {
+		return nil
+	}
+	err := c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.cmd = nil
+	return err
+}
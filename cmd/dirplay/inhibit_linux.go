@@ -0,0 +1,114 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// D-Bus names of the systemd-logind inhibitor and of the screensaver one
+// desktops offer, see https://systemd.io/INHIBITOR_LOCKS/
+const (
+	logindName         = "org.freedesktop.login1"
+	logindPath         = dbus.ObjectPath("/org/freedesktop/login1")
+	logindInhibit      = "org.freedesktop.login1.Manager.Inhibit"
+	screensaverName    = "org.freedesktop.ScreenSaver"
+	screensaverPath    = dbus.ObjectPath("/org/freedesktop/ScreenSaver")
+	screensaverInhibit = "org.freedesktop.ScreenSaver.Inhibit"
+	screensaverRelease = "org.freedesktop.ScreenSaver.UnInhibit"
+)
+
+// inhibitReason is shown by tools that list what holds up sleep
+const inhibitReason = "Playing music"
+
+// dbusInhibitor takes an idle inhibitor lock from systemd-logind on the
+// system bus, which logind holds until the file descriptor it hands back
+// is closed, or dirplay exits. Without logind, e.g. in a container, it
+// asks the desktop's screensaver on the session bus instead, which drops
+// the inhibition with the connection. Either way a crash can't leave
+// sleep blocked.
+type dbusInhibitor struct {
+	lock   *os.File   // logind lock, while held
+	conn   *dbus.Conn // session bus, while the screensaver is inhibited
+	cookie uint32
+}
+
+// newInhibitor returns the D-Bus inhibitor; buses are only connected to
+// once it is taken
+func newInhibitor() (Inhibitor, error) {
+	return &dbusInhibitor{}, nil
+}
+
+// Inhibit takes the logind lock, or failing that inhibits the screensaver
+func (d *dbusInhibitor) Inhibit() error {
+	if d.lock != nil || d.conn != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), inhibitTimeout)
+	defer cancel()
+
+	lock, logindErr := logindLock(ctx)
+	if logindErr == nil {
+		d.lock = lock
+		return nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("%w, and no session bus: %w", logindErr, err)
+	}
+	call := conn.Object(screensaverName, screensaverPath).CallWithContext(ctx, screensaverInhibit, 0, "dirplay", inhibitReason)
+	if err := call.Store(&d.cookie); err != nil {
+		conn.Close()
+		return fmt.Errorf("%w, and the screensaver refused: %w", logindErr, err)
+	}
+	d.conn = conn
+	return nil
+}
+
+// logindLock asks logind for an idle inhibitor lock, in block mode so
+// idle suspend waits for it. A suspend asked for, e.g. by closing the lid,
+// still goes ahead.
+func logindLock(ctx context.Context) (*os.File, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("no system bus: %w", err)
+	}
+	defer conn.Close()
+
+	var fd dbus.UnixFD
+	call := conn.Object(logindName, logindPath).CallWithContext(ctx, logindInhibit, 0, "idle", "dirplay", inhibitReason, "block")
+	if err := call.Store(&fd); err != nil {
+		return nil, fmt.Errorf("logind refused: %w", err)
+	}
+	if fd < 0 {
+		return nil, errors.New("logind returned no lock")
+	}
+	return os.NewFile(uintptr(fd), "logind-inhibitor"), nil
+}
+
+// Release closes the logind lock or uninhibits the screensaver
+func (d *dbusInhibitor) Release() error {
+	if d.lock != nil {
+		err := d.lock.Close()
+		d.lock = nil
+		return err
+	}
+	if d.conn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), inhibitTimeout)
+		defer cancel()
+		err := d.conn.Object(screensaverName, screensaverPath).CallWithContext(ctx, screensaverRelease, 0, d.cookie).Err
+		// Closing the connection drops the inhibition even if the call
+		// failed
+		d.conn.Close()
+		d.conn = nil
+		return err
+	}
+	return nil
+}
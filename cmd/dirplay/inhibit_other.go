@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// newInhibitor fails where dirplay has no way to keep the system awake,
+// which then sleeps as usual while music plays
+func newInhibitor() (Inhibitor, error) {
+	return nil, errors.New("not supported on this system")
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/sys/windows"
+
+// SetThreadExecutionState flags, see
+// https://learn.microsoft.com/windows/win32/api/winbase/nf-winbase-setthreadexecutionstate
+const (
+	esContinuous     = 0x80000000
+	esSystemRequired = 0x00000001
+)
+
+var setThreadExecutionState = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// executionStateInhibitor tells Windows the system is required through
+// SetThreadExecutionState. The state belongs to the calling thread, which
+// the sleep guard keeps to, and ends with the process, so a crash can't
+// leave sleep blocked.
+type executionStateInhibitor struct{}
+
+// newInhibitor returns the Windows inhibitor
+func newInhibitor() (Inhibitor, error) {
+	if err := setThreadExecutionState.Find(); err != nil {
+		return nil, err
+	}
+	return executionStateInhibitor{}, nil
+}
+
+// Inhibit keeps the system from idling to sleep
+func (executionStateInhibitor) Inhibit() error {
+	return setExecutionState(esContinuous | esSystemRequired)
+}
+
+// Release lets the system idle to sleep again
+func (executionStateInhibitor) Release() error {
+	return setExecutionState(esContinuous)
+}
+
+// setExecutionState sets the execution state of the thread, which
+// returns 0 on failure
+func setExecutionState(flags uintptr) error {
+	if previous, _, err := setThreadExecutionState.Call(flags); previous == 0 {
+		return err
+	}
+	return nil
+}
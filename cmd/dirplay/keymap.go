@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap holds the bindings of the player view. The playlist, history and
+// filter panes keep their own fixed navigation keys. ctrl+c always quits
+// and can't be rebound.
+type keyMap struct {
+	PlayPause     key.Binding
+	Previous      key.Binding
+	Next          key.Binding
+	PreviousAlbum key.Binding
+	NextAlbum     key.Binding
+	Note          key.Binding
+	NoteComment   key.Binding
+	Filter        key.Binding
+	List          key.Binding
+	Browse        key.Binding
+	History       key.Binding
+	Stats         key.Binding
+	Lyrics        key.Binding
+	Block         key.Binding
+	NewRotation   key.Binding
+	Mark          key.Binding
+	Marks         key.Binding
+	Sleep         key.Binding
+	Slower        key.Binding
+	Faster        key.Binding
+	NormalSpeed   key.Binding
+	Retry         key.Binding
+	Preview       key.Binding
+	Full          key.Binding
+	Restart       key.Binding
+	Loop          key.Binding
+	ClearLoop     key.Binding
+	Veto          key.Binding
+	Undo          key.Binding
+	EQ            key.Binding
+	Preset        key.Binding
+	Rate          key.Binding
+	ClearRating   key.Binding
+	Info          key.Binding
+	Meter         key.Binding
+	ETA           key.Binding
+	Mini          key.Binding
+	Export        key.Binding
+	Cover         key.Binding
+	OpenFolder    key.Binding
+	Help          key.Binding
+	Quit          key.Binding
+}
+
+// keyAction describes a binding: its name in keys.conf, the group it is
+// listed under in the help overlay, and what it does
+type keyAction struct {
+	name     string
+	category string
+	desc     string
+	binding  *key.Binding
+}
+
+// actions lists the bindings in footer and help order
+func (k *keyMap) actions() []keyAction {
+	return []keyAction{
+		{"previous", "Playback", "Previous", &k.Previous},
+		{"next", "Playback", "Next", &k.Next},
+		{"previous_album", "Playback", "Prev album", &k.PreviousAlbum},
+		{"next_album", "Playback", "Next album", &k.NextAlbum},
+		{"play_pause", "Playback", "Pause/Play", &k.PlayPause},
+		{"sleep", "Playback", "Sleep", &k.Sleep},
+		{"slower", "Playback", "Slower", &k.Slower},
+		{"faster", "Playback", "Faster", &k.Faster},
+		{"normal_speed", "Playback", "1×", &k.NormalSpeed},
+		{"retry", "Playback", "Retry audio", &k.Retry},
+		{"preview", "Playback", "Preview", &k.Preview},
+		{"play_full", "Playback", "Play in full", &k.Full},
+		{"restart", "Playback", "Restart", &k.Restart},
+		{"loop", "Playback", "A/B loop", &k.Loop},
+		{"clear_loop", "Playback", "Loop off", &k.ClearLoop},
+		{"veto_next", "Playback", "Pass over next", &k.Veto},
+		{"undo", "Playback", "Undo skip", &k.Undo},
+		{"eq", "Playback", "Equalizer", &k.EQ},
+		{"preset", "Playback", "Next preset", &k.Preset},
+		{"rate", "Library", "Rate", &k.Rate},
+		{"clear_rating", "Library", "Clear rating", &k.ClearRating},
+		{"note", "Library", "Note", &k.Note},
+		{"note_comment", "Library", "Note with comment", &k.NoteComment},
+		{"filter", "Library", "Filter", &k.Filter},
+		{"list", "Library", "List", &k.List},
+		{"browse", "Library", "Browse", &k.Browse},
+		{"history", "Library", "History", &k.History},
+		{"lyrics", "Library", "Lyrics", &k.Lyrics},
+		{"block", "Library", "Never play", &k.Block},
+		{"new_rotation", "Library", "New rotation", &k.NewRotation},
+		{"stats", "Library", "Stats", &k.Stats},
+		{"bookmark", "Library", "Bookmark", &k.Mark},
+		{"bookmarks", "Library", "Bookmarks", &k.Marks},
+		{"export", "Library", "Export", &k.Export},
+		{"cover", "Library", "Save cover", &k.Cover},
+		{"open_folder", "Library", "Open folder", &k.OpenFolder},
+		{"info", "Display", "Info", &k.Info},
+		{"meter", "Display", "Meter", &k.Meter},
+		{"eta", "Display", "Time left", &k.ETA},
+		{"mini", "Display", "Mini", &k.Mini},
+		{"help", "Display", "Help", &k.Help},
+		{"quit", "General", "Quit", &k.Quit},
+	}
+}
+
+// defaultKeyMap returns the built-in bindings
+func defaultKeyMap() keyMap {
+	k := keyMap{
+		PlayPause:     key.NewBinding(key.WithKeys(" ")),
+		Previous:      key.NewBinding(key.WithKeys("left")),
+		Next:          key.NewBinding(key.WithKeys("right")),
+		PreviousAlbum: key.NewBinding(key.WithKeys("ctrl+left")),
+		NextAlbum:     key.NewBinding(key.WithKeys("ctrl+right")),
+		Note:          key.NewBinding(key.WithKeys("n")),
+		NoteComment:   key.NewBinding(key.WithKeys("N")),
+		Filter:        key.NewBinding(key.WithKeys("/")),
+		List:          key.NewBinding(key.WithKeys("l")),
+		Browse:        key.NewBinding(key.WithKeys("g")),
+		History:       key.NewBinding(key.WithKeys("h")),
+		Stats:         key.NewBinding(key.WithKeys("S")),
+		Lyrics:        key.NewBinding(key.WithKeys("y")),
+		Block:         key.NewBinding(key.WithKeys("delete", "-")),
+		NewRotation:   key.NewBinding(key.WithKeys("R")),
+		Mark:          key.NewBinding(key.WithKeys("b")),
+		Marks:         key.NewBinding(key.WithKeys("B")),
+		Sleep:         key.NewBinding(key.WithKeys("t")),
+		Slower:        key.NewBinding(key.WithKeys("[")),
+		Faster:        key.NewBinding(key.WithKeys("]")),
+		NormalSpeed:   key.NewBinding(key.WithKeys("=")),
+		Retry:         key.NewBinding(key.WithKeys("r")),
+		Preview:       key.NewBinding(key.WithKeys("p")),
+		Full:          key.NewBinding(key.WithKeys("enter")),
+		Restart:       key.NewBinding(key.WithKeys("home")),
+		Loop:          key.NewBinding(key.WithKeys("a")),
+		ClearLoop:     key.NewBinding(key.WithKeys("A")),
+		Veto:          key.NewBinding(key.WithKeys("x")),
+		Undo:          key.NewBinding(key.WithKeys("u")),
+		EQ:            key.NewBinding(key.WithKeys("E")),
+		Preset:        key.NewBinding(key.WithKeys("P")),
+		Rate:          key.NewBinding(key.WithKeys("1", "2", "3", "4", "5")),
+		ClearRating:   key.NewBinding(key.WithKeys("0")),
+		Info:          key.NewBinding(key.WithKeys("i")),
+		Meter:         key.NewBinding(key.WithKeys("v")),
+		ETA:           key.NewBinding(key.WithKeys("d")),
+		Mini:          key.NewBinding(key.WithKeys("z")),
+		Export:        key.NewBinding(key.WithKeys("e")),
+		Cover:         key.NewBinding(key.WithKeys("c")),
+		OpenFolder:    key.NewBinding(key.WithKeys("o")),
+		Help:          key.NewBinding(key.WithKeys("?")),
+		Quit:          key.NewBinding(key.WithKeys("esc", "q")),
+	}
+	k.describe()
+	return k
+}
+
+// describe sets the help of every binding from its first key
+func (k *keyMap) describe() {
+	for _, action := range k.actions() {
+		keys := action.binding.Keys()
+		switch {
+		case action.binding == &k.Rate && len(keys) > 1:
+			// One key per star, shown as a range
+			action.binding.SetHelp(keyLabel(keys[0])+"-"+keyLabel(keys[len(keys)-1]), action.desc)
+		case len(keys) > 0:
+			action.binding.SetHelp(keyLabel(keys[0]), action.desc)
+		}
+	}
+}
+
+// keyLabel returns how a key is shown in the footer and the help
+func keyLabel(k string) string {
+	if rest, ok := strings.CutPrefix(k, "ctrl+"); ok {
+		return "CTRL+" + keyLabel(rest)
+	}
+
+	switch k {
+	case " ":
+		return "SPACE"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	}
+	// Letters are shown in capitals, so a capital needs telling apart
+	if len(k) == 1 && k[0] >= 'A' && k[0] <= 'Z' {
+		return "SHIFT+" + k
+	}
+	return strings.ToUpper(k)
+}
+
+// keyName turns a key as written in keys.conf into the form bubbletea
+// reports it in
+func keyName(k string) string {
+	if k == "space" {
+		return " "
+	}
+	return k
+}
+
+// loadKeyMap returns the default bindings with the overrides from
+// keys.conf in the config directory applied. Each line reads
+// "action = key, key"; blank lines and lines starting with # are skipped.
+// Unknown actions and keys bound to two actions are reported with their
+// line.
+func loadKeyMap() (keyMap, error) {
+	k := defaultKeyMap()
+
+	dir, err := configDir()
+	if err != nil {
+		return k, err
+	}
+	path := filepath.Join(dir, "keys.conf")
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return k, err
+	}
+	defer file.Close()
+
+	actions := make(map[string]keyAction)
+	for _, action := range k.actions() {
+		actions[action.name] = action
+	}
+
+	// The line each action was rebound on, to report conflicts against
+	lines := make(map[string]int)
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return k, fmt.Errorf("%s:%d: want \"action = key, key\"", path, n)
+		}
+		name = strings.TrimSpace(name)
+		action, ok := actions[name]
+		if !ok {
+			return k, fmt.Errorf("%s:%d: unknown action %q", path, n, name)
+		}
+
+		var keys []string
+		for _, field := range strings.Split(value, ",") {
+			field = strings.Trim(strings.TrimSpace(field), `"`)
+			if field == "" {
+				continue
+			}
+			if field == "ctrl+c" {
+				return k, fmt.Errorf("%s:%d: ctrl+c always quits and can't be rebound", path, n)
+			}
+			if field == "ctrl+z" {
+				return k, fmt.Errorf("%s:%d: ctrl+z always suspends and can't be rebound", path, n)
+			}
+			keys = append(keys, keyName(field))
+		}
+		if len(keys) == 0 {
+			return k, fmt.Errorf("%s:%d: no keys given for %s", path, n, name)
+		}
+
+		action.binding.SetKeys(keys...)
+		lines[name] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return k, err
+	}
+
+	// Every key may only trigger one action
+	owners := make(map[string]string)
+	for _, action := range k.actions() {
+		for _, bound := range action.binding.Keys() {
+			other, taken := owners[bound]
+			if !taken {
+				owners[bound] = action.name
+				continue
+			}
+
+			// Blame the rebinding, the later one if both were rebound
+			line, blamed := lines[action.name], other
+			if lines[other] > line {
+				line, blamed = lines[other], action.name
+			}
+			return k, fmt.Errorf("%s:%d: %s is already bound to %s", path, line, keyLabel(bound), blamed)
+		}
+	}
+
+	k.describe()
+	return k, nil
+}
+
+// SetKeyMap replaces the default key bindings
+func (m *PlayerModel) SetKeyMap(keys keyMap) {
+	m.keys = keys
+}
+
+// controlsLine returns the footer listing every binding
+func (m *PlayerModel) controlsLine() string {
+	parts := []string{"Controls:"}
+	for _, action := range m.keys.actions() {
+		help := action.binding.Help()
+		parts = append(parts, fmt.Sprintf("[%s] %s ", help.Key, help.Desc))
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// viewHelp renders the help overlay in place of the player view
+func (m *PlayerModel) viewHelp() string {
+	headerStyle, categoryStyle, rowStyle, dimStyle := m.styles.Header, m.styles.Category, m.styles.Text, m.styles.Dim
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render("Keys"))
+	content.WriteString("\n")
+
+	category := ""
+	for _, action := range m.keys.actions() {
+		if action.category != category {
+			category = action.category
+			content.WriteString("\n")
+			content.WriteString(categoryStyle.Render(category))
+			content.WriteString("\n")
+		}
+
+		var labels []string
+		for _, k := range action.binding.Keys() {
+			labels = append(labels, keyLabel(k))
+		}
+		content.WriteString(rowStyle.Render(fmt.Sprintf("  %-12s %s", strings.Join(labels, ", "), action.desc)))
+		content.WriteString("\n")
+	}
+
+	content.WriteString("\n")
+	content.WriteString(dimStyle.Render("Rebind keys in keys.conf in the config directory. Press any key to close."))
+	return content.String()
+}
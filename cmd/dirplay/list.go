@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// Values of --format
+const (
+	listText = "text"
+	listJSON = "json"
+)
+
+// listEntry is a line of --list --format json
+type listEntry struct {
+	Path        string `json:"path"`
+	Artist      string `json:"artist,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"album_artist,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	Track       int    `json:"track,omitempty"`
+}
+
+// validateListFormat checks the --format of --list
+func validateListFormat(format string) error {
+	if format != listText && format != listJSON {
+		return fmt.Errorf("invalid --format %q (want %s or %s)", format, listText, listJSON)
+	}
+	return nil
+}
+
+// runList prints the tracks the sources name, in scan order and as they
+// are found, instead of playing them: one path per line, or one JSON
+// object per line with the tags of each file. pathFilter is the --filter
+// query, matched against paths as at startup. Problems go to stderr, and
+// finding nothing is an error.
+func runList(config scan.Config, sources []string, format, pathFilter string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	var writeErr error
+	listed := 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config.Walk(ctx, sources,
+		func(path string, _ os.FileInfo) {
+			if !matchesFilter(path, trackTags{}, pathFilter) {
+				return
+			}
+			listed++
+			if format == listJSON {
+				writeErr = encoder.Encode(readListEntry(path))
+			} else {
+				_, writeErr = fmt.Println(path)
+			}
+			// Stop once the reader went away, e.g. "| head"
+			if writeErr != nil {
+				cancel()
+			}
+		},
+		printWarning)
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if listed == 0 {
+		if pathFilter != "" {
+			return fmt.Errorf("no audio files match filter %q", pathFilter)
+		}
+		return fmt.Errorf("no audio files found in %s", strings.Join(sources, ", "))
+	}
+	return nil
+}
+
+// readListEntry describes a track from its tags, leaving out what can't
+// be read
+func readListEntry(path string) listEntry {
+	entry := listEntry{Path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		return entry
+	}
+	defer file.Close()
+
+	tags, err := tag.ReadFrom(file)
+	if err != nil {
+		return entry
+	}
+	entry.Artist = tags.Artist()
+	entry.Title = tags.Title()
+	entry.Album = tags.Album()
+	entry.AlbumArtist = tags.AlbumArtist()
+	entry.Genre = tags.Genre()
+	entry.Year = tags.Year()
+	entry.Track, _ = tags.Track()
+	return entry
+}
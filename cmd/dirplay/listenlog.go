@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// listenLog collects the listens to append to --listen-log. hearing is
+// set while the current track started playing and isn't logged yet.
+type listenLog struct {
+	path    string
+	pending []library.Listen
+	hearing bool
+}
+
+// listensWrittenMsg reports the outcome of appending to the listen log
+type listensWrittenMsg struct {
+	err error
+}
+
+// EnableListenLog appends a JSON line per listen to path
+func (m *PlayerModel) EnableListenLog(path string) {
+	m.listens.path = path
+}
+
+// startListen notes that the current track started playing
+func (m *PlayerModel) startListen() {
+	m.listens.hearing = m.listens.path != ""
+}
+
+// endListen logs the current track as playback moves on from it.
+// Previews aren't listens and are left out.
+func (m *PlayerModel) endListen() {
+	if !m.listens.hearing {
+		return
+	}
+	m.listens.hearing = false
+	if m.previewing() {
+		return
+	}
+
+	listen := library.NewListen(m.currentTrack(), m.duration, m.position-m.preview.from, m.startedAt)
+	listen.Artist = m.artist
+	listen.Title = m.title
+	listen.Album = m.album
+	m.listens.pending = append(m.listens.pending, listen)
+}
+
+// writeListens appends the listens logged since the last call in the
+// background
+func (m *PlayerModel) writeListens() tea.Cmd {
+	if len(m.listens.pending) == 0 {
+		return nil
+	}
+
+	path, listens := m.listens.path, m.listens.pending
+	m.listens.pending = nil
+	work := m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		err := library.AppendListens(path, listens)
+		if err != nil {
+			work.Failed("write the listen log", err)
+		}
+		return listensWrittenMsg{err: err}
+	})
+}
+
+// listensWritten reports a failed write, the listens are lost but
+// playback goes on
+func (m *PlayerModel) listensWritten(msg listensWrittenMsg) tea.Cmd {
+	if msg.err == nil {
+		return nil
+	}
+	return m.showBanner(fmt.Sprintf("Could not write listen log: %v", msg.err))
+}
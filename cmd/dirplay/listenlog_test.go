@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// TestListenLogLeaves logs a listen however playback leaves a track:
+// moving on, going back and quitting
+func TestListenLogLeaves(t *testing.T) {
+	tracks := album(3)
+	h := newHarness(t, tracks...)
+	path := filepath.Join(t.TempDir(), "listens.jsonl")
+	h.m.EnableListenLog(path)
+	h.start()
+	started := h.clock.now
+
+	h.advance(100 * time.Second)
+	h.press("right")
+	h.advance(2 * time.Second)
+	h.press("left")
+	h.advance(30 * time.Second)
+	h.press("q")
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	listens, err := library.ReadListens(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		track    string
+		listened time.Duration
+		skipped  bool
+	}{
+		{"01.mp3", 99*time.Second + tickEndSlack, false},
+		{"02.mp3", time.Second + tickEndSlack, true},
+		{"01.mp3", 29*time.Second + tickEndSlack, true},
+	}
+	if len(listens) != len(want) {
+		t.Fatalf("logged %+v, want %d listens", listens, len(want))
+	}
+	for i, w := range want {
+		l := listens[i]
+		if filepath.Base(l.Path) != w.track || l.ListenedMS != w.listened.Milliseconds() || l.Skipped != w.skipped {
+			t.Errorf("listen %d = %s for %d ms, skipped %v, want %s for %v, skipped %v",
+				i+1, filepath.Base(l.Path), l.ListenedMS, l.Skipped, w.track, w.listened, w.skipped)
+		}
+		if l.LengthMS != fakeTrackLength.Milliseconds() || l.Artist != "Artist" {
+			t.Errorf("listen %d of %d ms by %q, want the track's length and artist", i+1, l.LengthMS, l.Artist)
+		}
+	}
+	if !listens[0].StartedAt.Equal(started) {
+		t.Errorf("first listen started at %v, want %v", listens[0].StartedAt, started)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// loopMinimum is the shortest stretch "a" loops
+const loopMinimum = time.Second
+
+// abLoop repeats a stretch of the current track, e.g. to practice along
+// with it. "a" marks from, then until, from which point playback jumps
+// back to from on reaching until; "A" clears it. It only lasts as long as
+// the track.
+type abLoop struct {
+	marked bool
+	from   time.Duration
+	until  time.Duration
+}
+
+// looping reports whether the current track is being looped
+func (m *PlayerModel) looping() bool {
+	return m.loop.until > 0
+}
+
+// markLoop marks the start of a loop at the current position, or its end
+// once the start is marked. Marking again after that starts a new loop.
+func (m *PlayerModel) markLoop() tea.Cmd {
+	if !m.playing {
+		return nil
+	}
+	position := m.player.GetPosition()
+
+	if !m.loop.marked || m.looping() {
+		m.resetLoop()
+		m.loop = abLoop{marked: true, from: position}
+		return m.showBanner(fmt.Sprintf("Loop from %s, %s again marks the end", formatDuration(position), m.keys.Loop.Help().Key))
+	}
+
+	if position-m.loop.from < loopMinimum {
+		return m.showBanner(fmt.Sprintf("The loop must end at least %s after %s", loopMinimum, formatDuration(m.loop.from)))
+	}
+	m.loop.until = position
+	m.player.SetLoop(m.loop.from, m.loop.until)
+	if err := m.player.Seek(m.loop.from); err == nil {
+		m.position = m.loop.from
+	}
+	// A looping track never moves on, a preview included
+	return tea.Batch(m.playInFull(), m.showBanner("Looping "+m.loopRange()))
+}
+
+// clearLoop drops the loop, playing on from where it is
+func (m *PlayerModel) clearLoop() tea.Cmd {
+	if !m.loop.marked {
+		return nil
+	}
+	m.resetLoop()
+	return m.showBanner("Loop off")
+}
+
+// resetLoop drops the loop without a word, e.g. when the track changes
+func (m *PlayerModel) resetLoop() {
+	if m.looping() {
+		m.player.SetLoop(0, 0)
+	}
+	m.loop = abLoop{}
+}
+
+// leaveLoop drops the loop when a seek goes to pos outside it
+func (m *PlayerModel) leaveLoop(pos time.Duration) {
+	if m.looping() && (pos < m.loop.from || pos >= m.loop.until) {
+		m.resetLoop()
+	}
+}
+
+// loopRange returns the loop as "1:12–1:38"
+func (m *PlayerModel) loopRange() string {
+	return formatDurationFor(m.loop.from, m.loop.until) + "–" + formatDuration(m.loop.until)
+}
+
+// loopLabel returns the loop shown in the status line, or ""
+func (m *PlayerModel) loopLabel() string {
+	switch {
+	case m.looping():
+		return "Loop " + m.loopRange()
+	case m.loop.marked:
+		return "Loop from " + formatDuration(m.loop.from)
+	}
+	return ""
+}
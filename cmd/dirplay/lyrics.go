@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lyricsPane shows the lyrics of the current track, opened with "y".
+// offset is how far untimed lyrics are scrolled; timed ones follow the
+// position.
+type lyricsPane struct {
+	open   bool
+	offset int
+}
+
+// toggleLyrics opens or closes the lyrics pane, scrolled to the top
+func (m *PlayerModel) toggleLyrics() {
+	m.lyricsPane = lyricsPane{open: !m.lyricsPane.open}
+}
+
+// updateLyrics handles the keys of the lyrics pane, and reports whether
+// it took the key. The others go on to the player, so playback can be
+// controlled while reading along.
+func (m *PlayerModel) updateLyrics(msg tea.KeyMsg) bool {
+	pane := &m.lyricsPane
+	switch {
+	case msg.String() == "esc" || key.Matches(msg, m.keys.Lyrics):
+		pane.open = false
+	case msg.String() == "up":
+		pane.offset = max(pane.offset-1, 0)
+	case msg.String() == "down":
+		pane.offset = max(min(pane.offset+1, len(m.info.Lyrics.Lines)-m.paneHeight()), 0)
+	default:
+		return false
+	}
+	return true
+}
+
+// viewLyrics renders the lyrics pane in place of the player view. Timed
+// lyrics keep the line being sung a third of the way down, highlighted.
+func (m *PlayerModel) viewLyrics() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render(fitText("Lyrics · "+m.trackName(), m.viewWidth())))
+	content.WriteString("\n")
+
+	lyrics := m.info.Lyrics
+	height := m.paneHeight()
+	if len(lyrics.Lines) == 0 {
+		content.WriteString(dimStyle.Render("No lyrics"))
+		content.WriteString("\n")
+	}
+
+	current, start := -1, m.lyricsPane.offset
+	if lyrics.Timed {
+		current = lyrics.LineAt(m.position)
+		start = max(min(current-height/3, len(lyrics.Lines)-height), 0)
+	}
+	end := min(start+height, len(lyrics.Lines))
+	for i := start; i < end; i++ {
+		line := fitText("  "+lyrics.Lines[i].Text, m.viewWidth())
+		switch {
+		case i == current:
+			content.WriteString(cursorStyle.Render(line))
+		case i < current:
+			// Sung already
+			content.WriteString(dimStyle.Render(line))
+		default:
+			content.WriteString(rowStyle.Render(line))
+		}
+		content.WriteString("\n")
+	}
+
+	help := "[ESC] Close"
+	if !lyrics.Timed && len(lyrics.Lines) > height {
+		help = "[↑↓] Scroll  " + help
+	}
+	content.WriteString(dimStyle.Render(help))
+	return content.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// testLyrics returns n lines named "Line 00", "Line 01", ..., timed every
+// 5 seconds from start when timed is set
+func testLyrics(n int, timed bool, start time.Duration) library.Lyrics {
+	lyrics := library.Lyrics{Timed: timed}
+	for i := range n {
+		line := library.LyricLine{Text: fmt.Sprintf("Line %02d", i)}
+		if timed {
+			line.At = start + time.Duration(i)*5*time.Second
+		}
+		lyrics.Lines = append(lyrics.Lines, line)
+	}
+	return lyrics
+}
+
+// TestModelLyrics opens the lyrics pane at 80x24, 20 lines high: timed
+// lyrics keep the line being sung a third of the way down whatever the
+// position, untimed ones scroll
+func TestModelLyrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		lyrics library.Lyrics
+		at     time.Duration
+		keys   []string
+		// top is the first line shown, and current the line on the row a
+		// third of the way down
+		top, current string
+	}{
+		{"none", library.Lyrics{}, 0, nil, "No lyrics", ""},
+		{"timed at the start", testLyrics(40, true, 0), 0, nil, "Line 00", "Line 06"},
+		{"timed before the first line", testLyrics(40, true, 10*time.Second), 5 * time.Second, nil, "Line 00", "Line 06"},
+		{"timed part way", testLyrics(40, true, 0), 47 * time.Second, nil, "Line 03", "Line 09"},
+		{"timed just before a line", testLyrics(40, true, 0), 50*time.Second - time.Millisecond, nil, "Line 03", "Line 09"},
+		{"timed on a line", testLyrics(40, true, 0), 50 * time.Second, nil, "Line 04", "Line 10"},
+		{"timed near the end", testLyrics(40, true, 0), 150 * time.Second, nil, "Line 20", "Line 26"},
+		{"timed past the end", testLyrics(40, true, 0), time.Hour, nil, "Line 20", "Line 26"},
+		{"timed doesn't scroll", testLyrics(40, true, 0), 47 * time.Second, []string{"down", "down"}, "Line 03", "Line 09"},
+		{"untimed", testLyrics(40, false, 0), 47 * time.Second, nil, "Line 00", "Line 06"},
+		{"untimed scrolled", testLyrics(40, false, 0), 0, []string{"down", "down", "down"}, "Line 03", "Line 09"},
+		{"untimed scrolled back", testLyrics(40, false, 0), 0, []string{"down", "down", "up"}, "Line 01", "Line 07"},
+		{"untimed above the top", testLyrics(40, false, 0), 0, []string{"up", "down", "up", "up"}, "Line 00", "Line 06"},
+		{"untimed to the end", testLyrics(40, false, 0), 0, strings.Split(strings.Repeat("down ", 30), " ")[:30], "Line 20", "Line 26"},
+		{"untimed short", testLyrics(5, false, 0), 0, []string{"down"}, "Line 00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := album(1)[0]
+			h := newHarness(t, track)
+			h.player.setTrack(track, fakeTrack{length: 2 * time.Hour, info: player.TrackInfo{Lyrics: tt.lyrics}})
+			h.start()
+			h.run(h.m.seekTo(tt.at))
+			h.press("y")
+			h.press(tt.keys...)
+
+			rows := strings.Split(ansi.Strip(h.m.View()), "\n")
+			if !strings.HasPrefix(rows[0], "Lyrics · ") {
+				t.Fatalf("view starts %q, want the lyrics pane", rows[0])
+			}
+			if got := strings.TrimSpace(rows[1]); got != tt.top {
+				t.Errorf("first line %q, want %q", got, tt.top)
+			}
+			if tt.current != "" {
+				if got := strings.TrimSpace(rows[1+h.m.paneHeight()/3]); got != tt.current {
+					t.Errorf("line a third of the way down %q, want %q", got, tt.current)
+				}
+			}
+			if len(rows) > h.m.height {
+				t.Errorf("pane is %d lines high, want no more than %d", len(rows), h.m.height)
+			}
+		})
+	}
+}
+
+// TestModelLyricsKeys checks which keys the pane takes: the others still
+// control playback
+func TestModelLyricsKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		open    bool
+		playing string
+		paused  bool
+	}{
+		{"y opens", []string{"y"}, true, "01.mp3", false},
+		{"y closes", []string{"y", "y"}, false, "01.mp3", false},
+		{"esc closes", []string{"y", "esc"}, false, "01.mp3", false},
+		{"pause", []string{"y", " "}, true, "01.mp3", true},
+		{"next", []string{"y", "right"}, true, "02.mp3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(2)...).start()
+			h.press(tt.keys...)
+			if h.m.lyricsPane.open != tt.open || h.playing() != tt.playing || h.m.paused != tt.paused {
+				t.Errorf("open = %v on %s, paused = %v, want %v on %s, paused = %v",
+					h.m.lyricsPane.open, h.playing(), h.m.paused, tt.open, tt.playing, tt.paused)
+			}
+		})
+	}
+}
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gopxl/beep"
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/player"
+	"github.com/punkscience/dirplay/pkg/playlist"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// Command line options
+var (
+	freshStart  bool
+	shuffleMode string
+	sortMode    string
+	newerAge    string
+	newerThan   time.Duration
+	weightList  string
+	weights     folderWeights
+	rotate      bool
+	filterQuery string
+	atEnd       string
+	notesFile   string
+	artDir      string
+	listenFile  string
+
+	playlistFile string
+
+	sandboxPlaylists bool
+	allowedRoots     []string
+	allowStreams     bool
+
+	durationTolerance float64
+	dedupeMode        string
+	replayGainMode    string
+	presetName        string
+	enableMPRIS       bool
+	notifyTracks      bool
+
+	listenAddr     string
+	headless       bool
+	httpStreamAddr string
+
+	skipSilence        bool
+	skipLeadingSilence bool
+	silenceThreshold   float64
+	silenceMin         time.Duration
+
+	minRating int
+	writeTags bool
+
+	watchSources    bool
+	followSymlinks  bool
+	maxDepth        int
+	excludePatterns []string
+	noIgnore        bool
+	probeWorkers    int
+	listSkipped     bool
+
+	screensaverAfter time.Duration
+
+	sleepAfter time.Duration
+	sleepQuit  bool
+
+	fadeFor      time.Duration
+	restartAfter time.Duration
+	outputRate   int
+	deviceName   string
+
+	startPaused bool
+	startAt     int
+	startTrack  string
+
+	previewFor    time.Duration
+	previewOffset float64
+
+	configFile         string
+	writeDefaultConfig bool
+
+	miniView  bool
+	asciiOnly bool
+	themeName string
+	noMouse   bool
+	noInhibit bool
+
+	listOnly   bool
+	listFormat string
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:          "dirplay <directory|file|glob|url>... | --playlist <file.m3u>",
+		Short:        "Play music from directories in a minimal TUI",
+		Example:      "  dirplay C:\\Users\\me\\Music\n  dirplay ~/Music --fresh\n  dirplay /mnt/music /media/usb/album 'D:\\Music\\**\\*.flac'\n  dirplay https://radio.example.org/live.mp3\n  dirplay --playlist dirplay-20261015-210405.m3u8",
+		Args:         checkArgs,
+		SilenceUsage: true,
+		RunE:         run,
+	}
+	rootCmd.Flags().BoolVar(&freshStart, "fresh", false, "ignore saved playback state and preferences and start a new shuffle")
+	rootCmd.Flags().StringVar(&playlistFile, "playlist", "", "play an M3U/M3U8 playlist in its own order instead of scanning directories")
+	rootCmd.Flags().BoolVar(&startPaused, "paused", false, "load the first track but wait for space to start playing it")
+	rootCmd.Flags().IntVar(&startAt, "start-at", 0, "begin with the track at this position of the shuffled or sorted playlist, from 1")
+	rootCmd.Flags().StringVar(&startTrack, "start-track", "", "begin with the first track of the playlist whose path or tags contain this text (case-insensitive)")
+	rootCmd.Flags().StringVar(&filterQuery, "filter", "", "only play files whose path contains this text (case-insensitive)")
+	rootCmd.Flags().StringVar(&atEnd, "at-end", atEndRepeat, "after the last track: repeat, stop, quit, rescan, or exec:<command>")
+	rootCmd.Flags().StringVar(&notesFile, "notes-file", "", "file the n and N keys append notes to (default $DIRPLAY_NOTES or ~/track-notes.md)")
+	rootCmd.Flags().StringVar(&artDir, "art-dir", "", "directory c saves covers to, named after their album, instead of cover.jpg next to the track")
+	rootCmd.Flags().StringVar(&listenFile, "listen-log", "", "append a JSON line per track listened to, or skipped, to this file")
+	rootCmd.Flags().Float64Var(&durationTolerance, "duration-tolerance", 10, "warn when a track's decoded length differs from its tagged length by more than this percentage (0 disables)")
+	rootCmd.Flags().StringVar(&replayGainMode, "replaygain", player.ReplayGainOff, "level loudness by ReplayGain tags: off, track, or album to keep the dynamics within an album")
+	rootCmd.Flags().BoolVar(&skipSilence, "skip-silence", false, "move on once a track's last 20% stays silent for --silence-min, skipping dead air and hidden-track gaps")
+	rootCmd.Flags().BoolVar(&skipLeadingSilence, "skip-leading-silence", false, "start tracks where the sound starts, dropping up to 10s of silence")
+	rootCmd.Flags().Float64Var(&silenceThreshold, "silence-threshold", defaultSilenceThreshold, "level in dBFS below which audio counts as silence")
+	rootCmd.Flags().DurationVar(&silenceMin, "silence-min", defaultSilenceMin, "how long trailing silence must last before --skip-silence moves on")
+	rootCmd.Flags().IntVar(&minRating, "min-rating", 0, "only play tracks rated at least this many stars, from 1 to 5 (0 plays everything)")
+	rootCmd.Flags().BoolVar(&writeTags, "write-tags", false, "also store ratings in the files' tags (MP3 only), not just in ratings.json")
+	rootCmd.Flags().StringVar(&dedupeMode, "dedupe", dedupeOff, "duplicate handling: off; name or tags to play one copy of each track, found by file name or by tags and length; deep to flag tracks that sound the same")
+	rootCmd.Flags().BoolVar(&enableMPRIS, "mpris", runtime.GOOS == "linux", "let media keys and desktop widgets control playback over MPRIS (Linux only)")
+	rootCmd.Flags().BoolVar(&notifyTracks, "notify", false, "show a desktop notification with the artist, title, album and cover of each track that starts")
+	rootCmd.Flags().StringVar(&listenAddr, "listen", "", "accept commands from \"dirplay ctl\" on unix:<path>, or tcp:<host>:<port> on a loopback host")
+	rootCmd.Flags().StringVar(&httpStreamAddr, "http-stream", "", "serve what plays as a WAV stream at http://<address>/stream, and its state at /status, e.g. :8090")
+	rootCmd.Flags().BoolVar(&headless, "headless", false, "play without the TUI, e.g. in the background over SSH, controlled through --listen")
+	rootCmd.Flags().BoolVar(&watchSources, "watch", false, "follow the directories while playing: new files join the playlist and deleted ones leave it")
+	rootCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories while scanning")
+	rootCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "descend at most this many directories below each directory argument (0 means no limit)")
+	rootCmd.Flags().StringArrayVar(&excludePatterns, "exclude", nil, "skip paths matching this glob, relative to the directory being scanned, e.g. \"**/live/*\"; can be repeated")
+	rootCmd.Flags().BoolVar(&noIgnore, "no-ignore", false, "scan directories even when they hold a .nomedia or .dirplayignore file")
+	rootCmd.Flags().IntVar(&probeWorkers, "probe-workers", scan.DefaultProbeWorkers, "how many files the scan checks at once; raise it for a library on a network share")
+	rootCmd.Flags().BoolVar(&listSkipped, "skipped", false, "list each file the scan skipped as unreadable, a duplicate, empty or unsupported on exit, not just how many")
+	rootCmd.Flags().BoolVar(&sandboxPlaylists, "sandbox", true, "only play playlist entries inside the playlist's directory or an --allow-root")
+	rootCmd.Flags().StringArrayVar(&allowedRoots, "allow-root", nil, "directory playlist entries may point into, can be repeated")
+	rootCmd.Flags().BoolVar(&allowStreams, "allow-streams", false, "play the http(s) URLs in playlists instead of skipping them")
+	rootCmd.Flags().DurationVar(&screensaverAfter, "screensaver", 0, "show a big clock after this long without a keypress, e.g. 10m (0 disables)")
+	rootCmd.Flags().DurationVar(&sleepAfter, "sleep", 0, "fade out and pause after this long, e.g. 45m (0 disables)")
+	rootCmd.Flags().IntVar(&outputRate, "output-rate", 0, "run the speaker at this sample rate in Hz, e.g. 48000, resampling tracks at other rates (0 takes the first track's)")
+	rootCmd.Flags().StringVar(&deviceName, "device", "", "play to this output device instead of the system's default, as listed by dirplay devices (Linux only)")
+	rootCmd.Flags().DurationVar(&fadeFor, "fade", defaultFade, "how long pausing, resuming and skipping ramp the sound so it doesn't click (0 disables)")
+	rootCmd.Flags().DurationVar(&restartAfter, "restart-after", defaultRestartAfter, "how long a track or chapter has to play before previous restarts it instead of going back (0 always goes back)")
+	rootCmd.Flags().BoolVar(&sleepQuit, "sleep-quit", false, "quit instead of pausing when the sleep timer expires")
+	rootCmd.Flags().DurationVar(&previewFor, "preview", 0, "play only this much of each track, e.g. 20s, then move on (0 disables; p toggles it)")
+	rootCmd.Flags().Float64Var(&previewOffset, "preview-offset", 30, "where previews start, in percent of each track")
+	rootCmd.Flags().BoolVar(&miniView, "mini", false, "show the one line mini view whatever the terminal size (z toggles it)")
+	rootCmd.Flags().BoolVar(&noMouse, "no-mouse", false, "ignore the mouse, leaving it to the terminal for selecting text")
+	rootCmd.Flags().BoolVar(&noInhibit, "no-inhibit", false, "let the system sleep while music plays")
+	rootCmd.Flags().BoolVar(&asciiOnly, "ascii", false, "draw the player with ASCII symbols only, for consoles that show the others as boxes (automatic without a UTF-8 locale or console code page)")
+	rootCmd.Flags().StringVar(&themeName, "theme", themeAuto, "colors: auto to match the terminal's background, dark, light, or mono for bold, faint and reverse text only")
+	rootCmd.Flags().BoolVar(&listOnly, "list", false, "print the tracks the sources name, one per line, instead of playing them")
+	rootCmd.Flags().StringVar(&listFormat, "format", listText, "output of --list: text, or json for a line of JSON with the tags of each track")
+	rootCmd.Flags().StringVar(&configFile, "config", "", "read defaults from this file instead of config.toml in the config directory")
+	rootCmd.Flags().BoolVar(&writeDefaultConfig, "write-default-config", false, "print a commented config.toml with every setting and exit")
+	rootCmd.Flags().StringVar(&presetName, "preset", "", "start with this sound preset from the config file; P switches to the next")
+	rootCmd.Flags().StringVar(&shuffleMode, "shuffle", playlist.ShuffleTrack, "shuffle mode: track, album to keep albums together in track order, or smart to favor albums you usually finish")
+	rootCmd.Flags().StringVar(&sortMode, "sort", playlist.SortShuffle, "playlist order: shuffle, name for case-insensitive path order, mtime for oldest files first or mtime-desc for newest first")
+	rootCmd.Flags().StringVar(&newerAge, "newer-than", "", "only play files modified within this long, e.g. 30d, 2w or 12h")
+	rootCmd.Flags().StringVar(&weightList, "weights", "", "pick tracks so each top-level folder plays this often whatever its size, e.g. jazz=3,podcasts=1,kids=0 (unnamed folders weigh 1, 0 leaves a folder out)")
+	rootCmd.Flags().BoolVar(&rotate, "rotation", false, "remember the tracks played to the end across sessions and pass over them until the whole library has been heard")
+
+	// Directories named like a command can still be played as ./name
+	rootCmd.MarkFlagsMutuallyExclusive("start-at", "start-track")
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(newGCCommand())
+	rootCmd.AddCommand(newCtlCommand())
+	rootCmd.AddCommand(newCollectCommand())
+	rootCmd.AddCommand(newStatsCommand())
+	rootCmd.AddCommand(newDevicesCommand())
+	rootCmd.AddCommand(newBlocklistCommand())
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// checkArgs rejects arguments alongside --playlist. Missing sources are
+// checked once the config file is read, as it can name directories.
+func checkArgs(cmd *cobra.Command, args []string) error {
+	if playlistFile != "" && len(args) > 0 {
+		return fmt.Errorf("--playlist can't be combined with other sources")
+	}
+	return nil
+}
+
+// run scans the music directories and runs the player TUI
+func run(cmd *cobra.Command, args []string) error {
+	if writeDefaultConfig {
+		fmt.Print(defaultConfig)
+		return nil
+	}
+
+	// The config file sets defaults for options not given on the command
+	// line, and the directories to play when none are given
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if err := config.apply(cmd.Flags()); err != nil {
+		return err
+	}
+	if len(args) == 0 && playlistFile == "" {
+		args = config.sources()
+	}
+	if len(args) == 0 && playlistFile == "" {
+		return fmt.Errorf("requires at least 1 directory, file or glob, or directories in %s", config.path)
+	}
+	for i, arg := range args {
+		if !scan.IsStreamURL(arg) {
+			args[i] = normalizeArg(arg)
+		}
+	}
+	if playlistFile != "" {
+		playlistFile = normalizeArg(playlistFile)
+	}
+
+	if err := validateSort(sortMode); err != nil {
+		return err
+	}
+	if newerThan, err = parseAge(newerAge); err != nil {
+		return err
+	}
+	if weights, err = parseWeights(weightList); err != nil {
+		return err
+	}
+	if weights != nil && sortMode != playlist.SortShuffle {
+		return fmt.Errorf("--weights picks tracks at random, it can't be combined with --sort %s", sortMode)
+	}
+
+	// --list only scans, leaving the speaker, the TUI and the saved state
+	// alone
+	codecs := codec.New()
+	scan := scanConfig(codecs)
+	if err := scan.ValidateExcludes(); err != nil {
+		return err
+	}
+	if probeWorkers < 1 {
+		return fmt.Errorf("invalid --probe-workers %d (want at least 1)", probeWorkers)
+	}
+
+	// Blocked tracks are left out of the scan, --list included
+	blocked, err := loadBlocklist()
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring the blocklist: %v\n", err)
+		blocked = nil
+	}
+	if blocked != nil {
+		scan.Blocked = blocked.Set()
+	}
+	if listOnly {
+		if err := validateListFormat(listFormat); err != nil {
+			return err
+		}
+		if playlistFile != "" {
+			args = []string{playlistFile}
+		}
+		return runList(scan, args, listFormat, filterQuery)
+	}
+
+	// Toggles from the last session fill in options not given this time,
+	// on the command line or in the config file
+	prefs, err := loadSessionPrefs()
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring saved preferences: %v\n", err)
+	}
+	if freshStart {
+		prefs = &sessionPrefs{path: prefs.path}
+	}
+	prefs.restore(cmd.Flags())
+
+	if shuffleMode != playlist.ShuffleTrack && shuffleMode != playlist.ShuffleSmart && shuffleMode != playlist.ShuffleAlbum {
+		return fmt.Errorf("invalid --shuffle mode %q (want %s, %s or %s)", shuffleMode, playlist.ShuffleTrack, playlist.ShuffleSmart, playlist.ShuffleAlbum)
+	}
+	if err := validateAtEnd(atEnd); err != nil {
+		return err
+	}
+	if err := validateDedupe(dedupeMode); err != nil {
+		return err
+	}
+	if err := validateReplayGain(replayGainMode); err != nil {
+		return err
+	}
+	if err := validatePreview(previewFor, previewOffset); err != nil {
+		return err
+	}
+	if err := validateMinRating(minRating); err != nil {
+		return err
+	}
+	if err := validateSilence(silenceThreshold, silenceMin); err != nil {
+		return err
+	}
+	if err := validateFade(fadeFor); err != nil {
+		return err
+	}
+	if err := validateStart(startAt, startTrack, weights != nil); err != nil {
+		return err
+	}
+	if err := validateOutputRate(outputRate); err != nil {
+		return err
+	}
+	if err := validateTheme(themeName); err != nil {
+		return err
+	}
+	if err := validateRestartAfter(restartAfter); err != nil {
+		return err
+	}
+	if listenAddr != "" {
+		if _, _, err := parseControlAddr(listenAddr); err != nil {
+			return err
+		}
+	}
+	if httpStreamAddr != "" {
+		if err := validateHTTPStream(httpStreamAddr); err != nil {
+			return err
+		}
+	}
+	if headless && listenAddr == "" {
+		return fmt.Errorf("--headless needs --listen to be controlled")
+	}
+	notes, err := resolveNotesFile(notesFile)
+	if err != nil {
+		return err
+	}
+	keys, err := loadKeyMap()
+	if err != nil {
+		return err
+	}
+	prefs.Save()
+
+	// A playlist given with --playlist is the only source and keeps its order
+	if playlistFile != "" {
+		args = []string{playlistFile}
+	}
+
+	// Saved state is keyed by the absolute paths of the arguments
+	stateKey := sourcesKey(args)
+
+	// Album history feeds smart shuffle and is recorded in every mode
+	stats, err := loadAlbumStats()
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring album history: %v\n", err)
+		stats = nil
+	}
+
+	// Tracks heard this rotation, kept for each set of sources
+	var heard *rotation
+	if rotate {
+		heard, err = loadRotation(stateKey)
+		if errors.Is(err, errNewerFormat) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring the rotation: %v\n", err)
+			heard = nil
+		}
+	}
+
+	// Play counts for the "S" overlay and "dirplay stats"
+	plays, err := loadTrackStats()
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring play counts: %v\n", err)
+		plays = nil
+	}
+
+	// Tags are indexed in the background; without the index they are only
+	// known once a track plays
+	tags, err := loadTagIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Not indexing tags: %v\n", err)
+		tags = nil
+	}
+
+	// Ratings are needed up front when they decide what plays
+	ratings, err := loadRatings()
+	if err != nil && (minRating > 0 || errors.Is(err, errNewerFormat)) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring ratings: %v\n", err)
+		ratings = nil
+	}
+	marks, err := loadMarks()
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring bookmarks: %v\n", err)
+		marks = nil
+	}
+
+	// Pick up the previous session's order if it can be resumed. Either
+	// way the sources are scanned in the background once the TUI is up,
+	// with new files shuffled in as they are found.
+	var playlist []string
+	startIndex := 0
+	var startPos time.Duration
+	var bookmarks map[string]time.Duration
+	resumed := false
+	saved, err := loadResumableState(stateKey)
+	if err != nil {
+		return err
+	}
+	// Without a terminal to ask on, headless sessions always resume. A
+	// start track asked for begins a new session.
+	if saved != nil && startAt == 0 && startTrack == "" && (headless || offerResume(saved)) {
+		// Tags aren't read yet at startup, so --filter matches paths only
+		playlist, startIndex = saved.restore(func(path string) bool {
+			return matchesFilter(path, trackTags{}, filterQuery) && ratings.ratedAtLeast(path, minRating)
+		})
+		startPos = saved.Position()
+		bookmarks = saved.Bookmarks()
+		resumed = true
+	}
+
+	// The theme is chosen before the model builds its styles from it
+	useTheme(themeName)
+	config.applyTheme(&theme)
+
+	// Create and run the TUI application, on the sound device opened at
+	// --output-rate, the --device one or the default
+	out := player.NewOutput(beep.SampleRate(outputRate))
+	if deviceName != "" {
+		if err := out.SetDevice(deviceName); err != nil {
+			return err
+		}
+	}
+	model := NewPlayerModel(playlist, player.NewAudioPlayer(out, codecs), codecs)
+	model.EnableStatePersistence(stateKey, startIndex, startPos)
+	model.SetBookmarks(bookmarks)
+	model.EnableAlbumStats(stats, shuffleMode)
+	model.EnableTrackStats(plays)
+	model.EnableBlocklist(blocked)
+	model.EnableRotation(heard)
+	model.EnableScreensaver(screensaverAfter)
+	model.EnableSleep(sleepAfter, sleepQuit)
+	model.EnablePreview(previewFor, previewOffset)
+	model.EnableAtEnd(atEnd, args)
+	model.SetNotesFile(notes)
+	model.SetArtDir(expandHome(artDir))
+	model.EnableListenLog(listenFile)
+	model.SetKeyMap(keys)
+	model.EnablePrefs(prefs)
+	if miniView {
+		model.SetMini(true)
+	}
+	useASCII(asciiOnly)
+	model.SetDurationTolerance(durationTolerance)
+	model.SetVolume(config.volume())
+	model.SetFade(fadeFor)
+	model.SetRestartAfter(restartAfter)
+	model.SetStart(startAt, startTrack)
+	if startPaused {
+		model.StartPaused()
+	}
+	model.EnableReplayGain(replayGainMode)
+	model.EnablePresets(config.Presets)
+	if presetName != "" {
+		if err := model.SelectPreset(presetName); err != nil {
+			return err
+		}
+	}
+	model.EnableSkipSilence(player.SilenceConfig{
+		SkipEnd:   skipSilence,
+		SkipStart: skipLeadingSilence,
+		Threshold: silenceThreshold,
+		Min:       silenceMin,
+	})
+	if ratings != nil {
+		model.EnableRatings(ratings, writeTags)
+	}
+	if marks != nil {
+		model.EnableMarks(marks)
+	}
+	model.SetMinRating(minRating)
+	if interval := config.tickInterval(); interval > 0 {
+		model.SetTickInterval(interval)
+	}
+	if dedupeMode == dedupeDeep {
+		cache, err := loadSignatureCache(codecs)
+		if err != nil {
+			return fmt.Errorf("could not load signature cache: %w", err)
+		}
+		model.EnableDedupe(cache)
+	}
+	if dedupeMode == dedupeTags && tags == nil {
+		// Without the index tags aren't read in the background
+		fmt.Fprintln(os.Stderr, "Deduping by file name only, the tag index is unavailable")
+		dedupeMode = dedupeName
+	}
+	model.SuppressDuplicates(dedupeMode)
+	if playlistFile != "" {
+		model.KeepScanOrder()
+	}
+	model.SortPlaylist(sortMode)
+	model.EnableWeights(weights, args)
+	if tags != nil {
+		model.StartTagIndex(tags)
+	}
+	model.StartScan(scan, args, filterQuery, resumed)
+	if watchSources {
+		if err := model.StartWatch(); err != nil {
+			fmt.Fprintf(os.Stderr, "Not watching for changes: %v\n", err)
+		}
+	}
+	defer model.StopBackground()
+	// Signals quit through the model, which saves the session first.
+	// Headless, there is no terminal to draw on or read keys from. The
+	// renderer wakes at its frame rate even when nothing changed, and the
+	// view changes a few times a second at most.
+	options := []tea.ProgramOption{tea.WithAltScreen(), tea.WithReportFocus(), tea.WithoutSignalHandler(), tea.WithFPS(renderFPS)}
+	if !noMouse {
+		options = append(options, tea.WithMouseCellMotion())
+		model.EnableMouse()
+	}
+	if headless {
+		options = []tea.ProgramOption{tea.WithoutRenderer(), tea.WithInput(nil), tea.WithoutSignalHandler()}
+	}
+	program := tea.NewProgram(model, options...)
+	signalsDone := make(chan struct{})
+	defer close(signalsDone)
+	handleShutdownSignals(program.Send, signalsDone)
+
+	// "dirplay ctl" drives the program through the control socket
+	if listenAddr != "" {
+		server, err := startControl(listenAddr, program.Send)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %w", listenAddr, err)
+		}
+		defer server.Close()
+	}
+
+	// Browsers and players elsewhere hear what plays
+	if httpStreamAddr != "" {
+		server, err := startHTTPStream(httpStreamAddr, out, program.Send)
+		if err != nil {
+			return fmt.Errorf("could not serve the stream on %s: %w", httpStreamAddr, err)
+		}
+		model.EnableHTTPStream(server)
+		defer server.Close()
+	}
+
+	// Media keys and desktop widgets drive the program through MPRIS
+	if enableMPRIS {
+		server, err := startMPRIS(program.Send)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "MPRIS disabled: %v\n", err)
+		} else {
+			model.EnableMPRIS(server)
+			defer server.Close()
+		}
+	}
+
+	// Track changes show up even with the terminal out of sight
+	if notifyTracks {
+		notifier, err := newNotifier()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Notifications disabled: %v\n", err)
+		} else {
+			model.EnableNotify(notifier)
+			defer notifier.Close()
+		}
+	}
+
+	// The system stays awake while music plays
+	if !noInhibit {
+		inhibitor, err := newInhibitor()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Not keeping the system awake: %v\n", err)
+		} else {
+			guard := startSleepGuard(inhibitor, program.Send)
+			model.EnableInhibit(guard)
+			defer guard.Close()
+		}
+	}
+
+	_, err = program.Run()
+
+	// Writes that failed, e.g. of a note saved just before quitting, were
+	// hidden by the alt screen
+	for _, failure := range model.WriteFailures() {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", failure)
+	}
+	if err != nil {
+		return fmt.Errorf("error running TUI: %w", err)
+	}
+
+	// Report scan problems now that the TUI is gone
+	warnings, err := model.ScanResult()
+	for _, warning := range warnings {
+		printWarning(warning)
+	}
+	if err != nil {
+		return err
+	}
+
+	// List the files that could not be played, or look damaged, now that
+	// the TUI is gone
+	printTrackErrors("Skipped %d track(s) that failed to load:\n", model.Failures())
+	printTrackErrors("%d track(s) may be damaged:\n", model.Warnings())
+	printDuplicates(model.Duplicates())
+
+	return nil
+}
+
+// printWarning prints a scan warning on stderr, with the files skipped
+// under it for --skipped
+func printWarning(warning error) {
+	var skipped *scan.SkippedError
+	if !errors.As(warning, &skipped) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+		return
+	}
+	if !listSkipped {
+		fmt.Fprintf(os.Stderr, "Warning: %v, --skipped lists them\n", warning)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+	for _, file := range skipped.Files {
+		if file.Err != nil {
+			fmt.Fprintf(os.Stderr, "  %s (%s: %v)\n", file.Path, file.Reason, file.Err)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s (%s)\n", file.Path, file.Reason)
+		}
+	}
+}
+
+// printTrackErrors lists per-track errors on stderr, sorted by path, under
+// a heading formatted with their count
+func printTrackErrors(heading string, errs map[string]error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, heading, len(errs))
+	paths := make([]string, 0, len(errs))
+	for path := range errs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(os.Stderr, "  %s: %v\n", path, errs[path])
+	}
+}
+
+// printDuplicates lists groups of tracks that sound the same on stderr
+func printDuplicates(groups [][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d group(s) of duplicates:\n", len(groups))
+	for _, group := range groups {
+		for _, path := range group {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// loadResumableState returns the saved state for the directory unless
+// --fresh was given or there is nothing to resume. Only a state file from
+// a newer dirplay is an error, anything else unreadable is ignored.
+func loadResumableState(key string) (*playbackState, error) {
+	if freshStart {
+		return nil, nil
+	}
+
+	saved, err := loadPlaybackState(key)
+	if errors.Is(err, errNewerFormat) {
+		return nil, err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring saved state: %v\n", err)
+		return nil, nil
+	}
+	return saved, nil
+}
+
+// offerResume asks whether to pick up where the last session stopped
+func offerResume(saved *playbackState) bool {
+	track := "?"
+	if saved.CurrentIndex >= 0 && saved.CurrentIndex < len(saved.Playlist) {
+		track = filepath.Base(saved.Playlist[saved.CurrentIndex])
+	}
+
+	fmt.Printf("Resume %s at %s? [Y/n] ", track, formatDuration(saved.Position()))
+	var answer string
+	fmt.Scanln(&answer)
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
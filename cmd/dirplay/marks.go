@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mark is a bookmark dropped with "b": a position in a track, when it was
+// set and an optional label. Unlike the positions long tracks resume from,
+// marks stay until they are deleted.
+type mark struct {
+	Path       string    `json:"path"`
+	PositionMS int64     `json:"position_ms"`
+	At         time.Time `json:"at"`
+	Label      string    `json:"label,omitempty"`
+}
+
+// markStore is the persistent bookmarks.json
+type markStore struct {
+	mu      sync.Mutex
+	path    string
+	Version int    `json:"version"`
+	Marks   []mark `json:"marks"`
+}
+
+// markMigrations upgrade older bookmark files, see readVersioned
+var markMigrations = []migration{unversioned}
+
+// markSavedMsg reports the outcome of saving the bookmarks
+type markSavedMsg struct {
+	err error
+}
+
+// markPane lists bookmarks, opened with "B": those of the current track,
+// or with all those of every track. missing holds the files that no
+// longer exist, checked when the pane opens.
+type markPane struct {
+	open    bool
+	all     bool
+	cursor  int
+	offset  int
+	rows    []mark
+	missing map[string]bool
+}
+
+// loadMarks reads the bookmarks, starting empty if there are none
+func loadMarks() (*markStore, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &markStore{path: filepath.Join(dir, "bookmarks.json")}
+	err = readVersioned(store.path, markMigrations, store)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// position returns where in its track a bookmark is
+func (b mark) position() time.Duration {
+	return time.Duration(b.PositionMS) * time.Millisecond
+}
+
+// All returns a copy of the bookmarks
+func (s *markStore) All() []mark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.Marks)
+}
+
+// Add stores a bookmark
+func (s *markStore) Add(b mark) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Marks = append(s.Marks, b)
+}
+
+// Remove deletes the bookmarks for which drop reports true and returns
+// how many there were
+func (s *markStore) Remove(drop func(mark) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.Marks)
+	s.Marks = slices.DeleteFunc(s.Marks, drop)
+	return before - len(s.Marks)
+}
+
+// Save writes the bookmarks to disk via a temporary file
+func (s *markStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Version = len(markMigrations)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+// newMarkInput creates the text input the label of a bookmark is typed in
+func newMarkInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Bookmark label: "
+	input.Placeholder = "optional, Enter to save"
+	input.CharLimit = 100
+	return input
+}
+
+// EnableMarks lets "b" drop bookmarks into store and "B" list them
+func (m *PlayerModel) EnableMarks(store *markStore) {
+	m.marks = store
+	m.markInput = newMarkInput()
+}
+
+// openMarkInput asks for the label of a bookmark at the current position,
+// which is taken now rather than once the label is typed
+func (m *PlayerModel) openMarkInput() tea.Cmd {
+	if m.marks == nil || !m.playing {
+		return nil
+	}
+
+	m.marking = &mark{
+		Path:       m.currentTrack(),
+		PositionMS: displayPosition(m.position, m.duration).Milliseconds(),
+	}
+	m.markInput.SetValue("")
+	return m.markInput.Focus()
+}
+
+// updateMarkInput handles keys while the label is typed: Enter saves the
+// bookmark, Esc drops it
+func (m *PlayerModel) updateMarkInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.marking = nil
+		m.markInput.Blur()
+		return m, nil
+
+	case "enter":
+		b := *m.marking
+		b.At = time.Now()
+		b.Label = strings.TrimSpace(m.markInput.Value())
+		m.marking = nil
+		m.markInput.Blur()
+
+		m.marks.Add(b)
+		banner := "Bookmarked at " + formatDuration(b.position())
+		return m, tea.Batch(m.showBanner(banner), m.saveMarks())
+	}
+
+	var cmd tea.Cmd
+	m.markInput, cmd = m.markInput.Update(msg)
+	return m, cmd
+}
+
+// saveMarks writes the bookmarks in the background
+func (m *PlayerModel) saveMarks() tea.Cmd {
+	store, work := m.marks, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		err := store.Save()
+		if err != nil {
+			work.Failed("save bookmarks", err)
+		}
+		return markSavedMsg{err: err}
+	})
+}
+
+// marksSaved reports bookmarks that couldn't be saved
+func (m *PlayerModel) marksSaved(msg markSavedMsg) tea.Cmd {
+	if msg.err == nil {
+		return nil
+	}
+	return m.showBanner(fmt.Sprintf("Could not save bookmarks: %v", msg.err))
+}
+
+// openMarks shows the bookmarks of the current track
+func (m *PlayerModel) openMarks() {
+	if m.marks == nil {
+		return
+	}
+	m.markPane = markPane{open: true}
+	m.refreshMarks()
+}
+
+// refreshMarks lists the bookmarks the pane shows, by track and position,
+// and checks which files are missing
+func (m *PlayerModel) refreshMarks() {
+	pane := &m.markPane
+	pane.rows = nil
+	pane.missing = make(map[string]bool)
+
+	current := m.currentTrack()
+	for _, b := range m.marks.All() {
+		if !pane.all && b.Path != current {
+			continue
+		}
+		if _, seen := pane.missing[b.Path]; !seen {
+			_, err := os.Stat(b.Path)
+			pane.missing[b.Path] = errors.Is(err, os.ErrNotExist)
+		}
+		pane.rows = append(pane.rows, b)
+	}
+	sort.SliceStable(pane.rows, func(a, b int) bool {
+		if pane.rows[a].Path != pane.rows[b].Path {
+			return pane.rows[a].Path < pane.rows[b].Path
+		}
+		return pane.rows[a].PositionMS < pane.rows[b].PositionMS
+	})
+	pane.cursor = max(min(pane.cursor, len(pane.rows)-1), 0)
+}
+
+// updateMarks handles keys while the bookmark list has focus
+func (m *PlayerModel) updateMarks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pane := &m.markPane
+	switch msg.String() {
+	case "esc", "B":
+		pane.open = false
+	case "up":
+		pane.cursor = max(pane.cursor-1, 0)
+	case "down":
+		pane.cursor = max(min(pane.cursor+1, len(pane.rows)-1), 0)
+	case "tab":
+		pane.all = !pane.all
+		pane.cursor = 0
+		m.refreshMarks()
+	case "d", "delete":
+		if len(pane.rows) == 0 {
+			return m, nil
+		}
+		chosen := pane.rows[pane.cursor]
+		m.marks.Remove(func(b mark) bool { return b == chosen })
+		m.refreshMarks()
+		return m, tea.Batch(m.showBanner("Bookmark deleted"), m.saveMarks())
+	case "X":
+		// Every bookmark of a missing file goes, listed or not
+		removed := m.marks.Remove(func(b mark) bool {
+			_, err := os.Stat(b.Path)
+			return errors.Is(err, os.ErrNotExist)
+		})
+		if removed == 0 {
+			return m, m.showBanner("No bookmarks of missing files")
+		}
+		m.refreshMarks()
+		return m, tea.Batch(m.showBanner(fmt.Sprintf("Deleted %d bookmarks of missing files", removed)), m.saveMarks())
+	case "enter":
+		if len(pane.rows) == 0 {
+			return m, nil
+		}
+		cmd, jumped := m.jumpToMark(pane.rows[pane.cursor])
+		if jumped {
+			pane.open = false
+		}
+		return m, cmd
+	}
+	return m, nil
+}
+
+// jumpToMark seeks to a bookmark and reports whether it could. A bookmark
+// on another track switches to it first, and the seek waits in
+// pendingSeek until it has loaded.
+func (m *PlayerModel) jumpToMark(b mark) (tea.Cmd, bool) {
+	if b.Path == m.currentTrack() && m.playing {
+		return m.seekTo(b.position()), true
+	}
+	if m.markPane.missing[b.Path] {
+		return m.showBanner("The file is missing, shift+x deletes the bookmarks of missing files"), false
+	}
+
+	index := -1
+	if m.tracks.Known(b.Path) {
+		index = m.indexOf(m.tracks.Add(b.Path))
+	}
+	if index < 0 {
+		return m.showBanner("Not in the playlist"), false
+	}
+
+	m.recordUndo()
+	m.player.Stop()
+	m.leaveTrack()
+	m.queue.Leave()
+	m.currentIndex = index
+	m.pendingSeek = &b
+	return m.loadCurrentTrack(), true
+}
+
+// seekPending seeks to the bookmark waiting for the track that just
+// loaded, if there is one, and reports whether it did
+func (m *PlayerModel) seekPending(id trackID) bool {
+	b := m.pendingSeek
+	m.pendingSeek = nil
+	if b == nil || m.tracks.Path(id) != b.Path {
+		return false
+	}
+	m.seekTo(b.position())
+	return true
+}
+
+// viewMarks renders the bookmark list in place of the player view
+func (m *PlayerModel) viewMarks() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor
+
+	pane := &m.markPane
+	height := m.paneHeight()
+	if pane.cursor < pane.offset {
+		pane.offset = pane.cursor
+	}
+	if pane.cursor >= pane.offset+height {
+		pane.offset = pane.cursor - height + 1
+	}
+
+	header := "Bookmarks · " + m.paneEntryName(m.currentTrack())
+	if pane.all {
+		header = "Bookmarks · all tracks"
+	}
+	var content strings.Builder
+	content.WriteString(headerStyle.Render(fitText(fmt.Sprintf("%s · %d", header, len(pane.rows)), m.viewWidth())))
+	content.WriteString("\n")
+
+	if len(pane.rows) == 0 {
+		content.WriteString(dimStyle.Render("No bookmarks yet, b drops one while playing"))
+		content.WriteString("\n")
+	}
+
+	end := min(pane.offset+height, len(pane.rows))
+	for row := pane.offset; row < end; row++ {
+		b := pane.rows[row]
+		line := fmt.Sprintf("  %s  %s", formatDuration(b.position()), b.At.Format("2006-01-02 15:04"))
+		if pane.all {
+			line += "  " + m.paneEntryName(b.Path)
+		}
+		if b.Label != "" {
+			line += "  " + b.Label
+		}
+		missing := pane.missing[b.Path]
+		if missing {
+			line += "  (missing)"
+		}
+		line = fitText(line, m.viewWidth())
+
+		switch {
+		case row == pane.cursor:
+			content.WriteString(cursorStyle.Render(line))
+		case missing:
+			content.WriteString(dimStyle.Render(line))
+		default:
+			content.WriteString(rowStyle.Render(line))
+		}
+		content.WriteString("\n")
+	}
+
+	help := "[↑↓] Move  [ENTER] Jump  [D] Delete  [SHIFT+X] Delete missing  [TAB] All tracks  [ESC] Close"
+	if pane.all {
+		help = "[↑↓] Move  [ENTER] Jump  [D] Delete  [SHIFT+X] Delete missing  [TAB] This track  [ESC] Close"
+	}
+	content.WriteString(dimStyle.Render(fitText(help, m.viewWidth())))
+	return content.String()
+}
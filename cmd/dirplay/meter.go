@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// setMeter shows or hides the level meter, which needs fast ticks to
+// scroll smoothly
+func (m *PlayerModel) setMeter(show bool) {
+	m.showMeter = show
+	m.player.Meter().SetEnabled(show)
+	if show {
+		m.ticks.request("meter", fastTickInterval)
+	} else {
+		m.ticks.release("meter")
+	}
+}
+
+// renderMeter draws the levels as a scrolling sparkline, green in the
+// quiet parts through yellow to red near full scale
+func renderMeter(levels []float64, styles viewStyles) string {
+	quiet, loud, peak := styles.Accent, styles.Loud, styles.Error
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", player.MeterHistory-len(levels)))
+	for _, level := range levels {
+		blocks := glyphs.Meter
+		block := string(blocks[min(int(level*float64(len(blocks))), len(blocks)-1)])
+		switch {
+		case level > 0.9:
+			b.WriteString(peak.Render(block))
+		case level > 0.7:
+			b.WriteString(loud.Render(block))
+		default:
+			b.WriteString(quiet.Render(block))
+		}
+	}
+	return b.String()
+}
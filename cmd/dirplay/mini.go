@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Terminals shorter than miniHeight rows, too short for the full player
+// view, get the mini view by itself. Its progress bar gets at most
+// miniBarWidth cells and is left out below miniBarMin.
+const (
+	miniHeight   = 12
+	miniBarWidth = 30
+	miniBarMin   = 5
+)
+
+// SetMini shows the mini view whatever the terminal size, as --mini does
+func (m *PlayerModel) SetMini(mini bool) {
+	m.mini = mini
+}
+
+// showMini reports whether the player view is drawn as the mini view
+func (m *PlayerModel) showMini() bool {
+	return m.mini || m.height > 0 && m.height < miniHeight
+}
+
+// viewMini renders the player in one line, play state, track, time and
+// progress, and on terminals with room for it a second one for banners,
+// the filter prompt or the track count
+func (m *PlayerModel) viewMini() string {
+	width := m.viewWidth()
+	dimStyle, bannerStyle := m.styles.Dim, m.styles.Banner
+
+	// Typing a filter, a bookmark label or a note takes the only line of
+	// a one line terminal
+	prompt := ""
+	if m.filtering {
+		prompt = fitText(m.filterInput.View(), width)
+	}
+	if m.marking != nil {
+		prompt = fitText(m.markInput.View(), width)
+	}
+	if m.noting != nil {
+		prompt = fitText(m.commentInput.View(), width)
+	}
+	if m.height == 1 && prompt != "" {
+		return prompt
+	}
+
+	m.hits.player = true
+	lines := []string{m.miniLine(width)}
+	if m.height == 1 {
+		return lines[0]
+	}
+
+	switch {
+	case prompt != "":
+		lines = append(lines, prompt)
+	case m.audioErrorLine() != "":
+		lines = append(lines, m.styles.Error.Render(fitText(m.audioErrorLine(), width)))
+	case m.banner != "":
+		lines = append(lines, bannerStyle.Render(fitText(m.banner, width)))
+	default:
+		lines = append(lines, dimStyle.Render(fitText(m.trackInfoLine(), width)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// miniLine returns the first line of the mini view. The track name gets
+// what the time and the progress bar leave; on narrow terminals the bar
+// goes first, then the time.
+func (m *PlayerModel) miniLine(width int) string {
+	textStyle, dimStyle, progressStyle := m.styles.Text, m.styles.Dim, m.styles.Accent
+
+	icon := m.stateIcon() + " "
+	if m.mouse {
+		icon = m.recordButton(0, 0, m.stateIcon(), mousePlayPause) + " "
+	}
+	clock := " " + m.timeLabel()
+	rest := width - lipgloss.Width(icon)
+
+	// The bar takes a third of the line at most, brackets and gap included
+	bar := min(miniBarWidth, (rest-lipgloss.Width(clock))/3-3)
+	if bar < miniBarMin {
+		bar = 0
+	}
+	nameWidth := rest - lipgloss.Width(clock)
+	if bar > 0 {
+		nameWidth -= bar + 3
+	}
+	if nameWidth < miniBarMin {
+		clock, bar, nameWidth = "", 0, rest
+	}
+
+	if rest < 1 {
+		return fitText(icon, width)
+	}
+
+	name := fitText(m.trackName(), nameWidth)
+	name += strings.Repeat(" ", max(nameWidth-lipgloss.Width(name), 0))
+	line := icon + textStyle.Render(name)
+	if clock != "" {
+		line += dimStyle.Render(clock)
+	}
+	if bar > 0 {
+		m.recordBar(0, lipgloss.Width(icon)+nameWidth+lipgloss.Width(clock)+1, bar)
+		line += " " + progressStyle.Render(m.renderProgressBar(bar))
+	}
+	return line
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// TestViewSizes renders the player at the sizes it is used at, down to a
+// tmux pane, checking which view it picks, that no line is wider than the
+// terminal and what the mini view fits in
+func TestViewSizes(t *testing.T) {
+	tests := []struct {
+		width, height int
+		mini          bool
+		// maxLines is the most lines the mini view may take
+		maxLines int
+		bar      bool
+		time     bool
+	}{
+		{80, 3, true, 2, true, true},
+		{80, 1, true, 1, true, true},
+		{40, 10, true, 2, true, true},
+		{30, 5, true, 2, false, true},
+		{200, 50, false, 0, true, true},
+		{80, 24, false, 0, true, true},
+		{10, 2, true, 2, false, false},
+		{1, 1, true, 1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d", tt.width, tt.height), func(t *testing.T) {
+			h := newHarness(t, "/music/album/"+strings.Repeat("A Rather Long Title ", 5)+".mp3", "/music/album/02.mp3")
+			h.send(tea.WindowSizeMsg{Width: tt.width, Height: tt.height})
+			h.start()
+			h.advance(time.Minute)
+
+			if h.m.showMini() != tt.mini {
+				t.Errorf("mini view %v, want %v", h.m.showMini(), tt.mini)
+			}
+			view := h.m.View()
+			lines := strings.Split(view, "\n")
+			if tt.mini && len(lines) > tt.maxLines {
+				t.Errorf("view takes %d lines, want at most %d:\n%s", len(lines), tt.maxLines, view)
+			}
+			for i, line := range lines {
+				if w := ansi.StringWidth(line); w > tt.width {
+					t.Errorf("line %d is %d cells wide:\n%s", i+1, w, line)
+				}
+			}
+			if !tt.mini {
+				return
+			}
+			first := ansi.Strip(lines[0])
+			if got := strings.Contains(first, " / 03:00"); got != tt.time {
+				t.Errorf("time shown %v, want %v: %q", got, tt.time, first)
+			}
+			if got := strings.Contains(first, "["); got != tt.bar {
+				t.Errorf("progress bar shown %v, want %v: %q", got, tt.bar, first)
+			}
+		})
+	}
+}
+
+// TestMiniToggle forces the mini view with "z" on a tall terminal, and
+// checks the keys still work in it
+func TestMiniToggle(t *testing.T) {
+	h := newHarness(t, album(3)...).start()
+	steps := []struct {
+		key     string
+		mini    bool
+		playing string
+		paused  bool
+	}{
+		{"z", true, "01.mp3", false},
+		{" ", true, "01.mp3", true},
+		{"right", true, "02.mp3", false},
+		{"z", false, "02.mp3", false},
+		{"z", true, "02.mp3", false},
+	}
+	for _, step := range steps {
+		h.press(step.key)
+		if h.m.showMini() != step.mini || h.playing() != step.playing || h.m.paused != step.paused {
+			t.Fatalf("after %q: mini %v on %s paused %v, want mini %v on %s paused %v",
+				step.key, h.m.showMini(), h.playing(), h.m.paused, step.mini, step.playing, step.paused)
+		}
+		if lines := strings.Count(h.m.View(), "\n") + 1; step.mini && lines > 2 {
+			t.Errorf("after %q: mini view takes %d lines", step.key, lines)
+		}
+	}
+}
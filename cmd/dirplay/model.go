@@ -0,0 +1,1708 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/player"
+	"github.com/punkscience/dirplay/pkg/playlist"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// How long a load error stays on screen before skipping to the next track
+const skipDelay = 1500 * time.Millisecond
+
+// durationSlack is how far playback may run past the reported length
+// before the length is taken to be wrong and shown as unknown
+const durationSlack = 2 * time.Second
+
+// PlayerModel represents the state of the music player TUI
+type PlayerModel struct {
+	tracks       *trackTable
+	playlist     []trackID
+	currentIndex int
+	current      trackID
+	player       player.Player
+	codecs       *codec.Registry
+	playing      bool
+	paused       bool
+	position     time.Duration
+	duration     time.Duration
+	width        int
+	height       int
+	err          error
+	artist       string
+	title        string
+	album        string
+	info         player.TrackInfo
+	resampled    string // see resampleLabel
+	showInfo     bool
+	showMeter    bool
+	showETA      bool
+	eq           player.EQGains
+	eqPane       eqPane
+	mini         bool
+	speed        float64
+	volume       float64
+	replayGain   string
+	presets      []soundPreset
+	preset       int // index into presets, -1 before switching to one
+	ticks        *tickPolicy
+	styles       viewStyles
+	storage      storageOutage
+
+	// Load failures, keyed by playlist entry, so a bad file is skipped
+	// instead of ending the session
+	failed              map[string]error
+	skipped             int
+	warnings            map[string]error
+	durationTolerance   float64
+	consecutiveFailures int
+	banner              string
+	bannerID            int
+	banners             bannerQueue
+
+	// Playback state persistence, keyed by the music directory
+	stateKey string
+	saving   bool // a periodic state save is scheduled, see saveStateTick
+	resumeAt time.Duration
+
+	// Where long tracks were left part way, by path
+	bookmarks map[string]time.Duration
+
+	// Album listening history and the shuffle mode it weights
+	albumStats  *albumStats
+	shuffleMode string
+
+	// Play counts and time listened by track, and what this session
+	// played, shown with "S"
+	trackStats *trackStats
+	session    sessionStats
+	showStats  bool
+
+	// Cover art of the current track, decoded off the UI goroutine
+	art  *albumArt
+	arts *artCache
+
+	// Filtering: playlist holds the tracks being played, fullPlaylist every
+	// scanned track, and knownTags the tags of tracks loaded so far
+	fullPlaylist []trackID
+	knownTags    map[string]trackTags
+	filterInput  textinput.Model
+	filtering    bool
+	filterQuery  string
+
+	// Key bindings, and whether their help is shown
+	keys     keyMap
+	showHelp bool
+
+	// Browsable playlist, opened with "l", and the tracks queued from it
+	pane  playlistPane
+	queue playlist.Queue[trackID]
+
+	// Playlist positions where a new album starts, see album.go
+	albumStarts []int
+
+	// Tracks played before the current one, which started at startedAt,
+	// browsable with "h"
+	history     *playlist.History[trackID]
+	historyPane historyPane
+	startedAt   time.Time
+
+	// Lyrics of the current track, shown with "y"
+	lyricsPane lyricsPane
+
+	// The browse screen, and the artists and albums it lists
+	browse  browsePane
+	catalog *library.Catalog
+
+	// Tracks never to play again, and the one taken out of rotation
+	// waiting for the block key to be pressed again
+	blocklist    *blocklist
+	blockPending blockPending
+
+	// The tracks heard this rotation, passed over until the library has
+	// all been heard, and until when the new rotation key starts one
+	rotation        *rotation
+	rotationCount   rotationCount
+	rotationResetBy time.Time
+
+	// How far in previous restarts instead of going back, and when it
+	// last did
+	restartAfter time.Duration
+	restartedAt  time.Time
+
+	// Where manual navigations left off, undone with "u"
+	undo []undoEntry
+
+	// The track to begin with, and whether it waits for space to start
+	// playing; prepared while it is loaded but not started yet
+	start       startChoice
+	startPaused bool
+	prepared    bool
+
+	// Bookmarks dropped with "b", the one whose label is being typed,
+	// the list opened with "B" and one to seek to once its track loads
+	marks       *markStore
+	markInput   textinput.Model
+	marking     *mark
+	markPane    markPane
+	pendingSeek *mark
+
+	// What happens after the last track, see atend.go
+	atEnd   string
+	sources []string
+	ended   bool
+
+	// Toggles remembered between sessions
+	prefs *sessionPrefs
+
+	// Notes file written by "n" and "N", the tracks noted this session and
+	// the note whose comment is being typed
+	notesFile    string
+	noted        map[string]bool
+	commentInput textinput.Model
+	noting       *noteDraft
+
+	// Background scan of the command line sources, see scan.go
+	scan         <-chan tea.Msg
+	scanConfig   scan.Config
+	scanning     bool
+	scanFound    int
+	scanFilter   string
+	scanResumed  bool
+	keepOrder    bool
+	sortMode     string
+	weighted     *weightedSampler
+	scanSeen     map[string]bool
+	scanWarnings []error
+	scanErr      error
+
+	// Star ratings given with 1-5, and the least a track needs to be
+	// played with --min-rating
+	ratings         *ratingStore
+	writeRatingTags bool
+	minRating       int
+
+	// Tags of scanned tracks, read ahead of playing them
+	tagIndexer *tagIndexer
+
+	// Changes to the source directories while playing, with --watch
+	watch <-chan tea.Msg
+
+	// Acoustic duplicate detection, on with --dedupe=deep
+	dupes *duplicateFinder
+
+	// Copies left out of the playlist, with --dedupe=name or tags
+	suppress *duplicateSuppressor
+
+	// MPRIS server for media keys and desktop widgets, and the state it
+	// last published
+	mpris      *mprisServer
+	httpStream *httpStreamServer
+	published  nowPlaying
+
+	// Whether the mouse is reported, and where the last frame drew what
+	// it acts on
+	mouse bool
+	hits  mouseHits
+
+	// Desktop notifications for --notify, and whether one failed yet
+	notifier     Notifier
+	notifyFailed bool
+
+	// Where "c" writes covers, next to the tracks when empty
+	artDir string
+
+	// Sleep inhibitor held while playing, unless --no-inhibit
+	inhibit inhibitState
+
+	// Listens to append to --listen-log
+	listens listenLog
+
+	// Stream and output failures, see device.go
+	audioWatch audioWatch
+
+	// Sleep timer, set with "t" or --sleep
+	sleep sleepTimer
+
+	// Preview mode, toggled with "p" or set with --preview
+	preview previewMode
+
+	// A/B loop of the current track, set with "a"
+	loop abLoop
+
+	// Goroutines working in the background, stopped on quit, which waits
+	// for them while quitting is set
+	work     *backgroundWork
+	quitting bool
+
+	// Playback paused by ctrl+z, to resume once the program is continued
+	resumeAfterSuspend bool
+
+	// Big clock shown after idleAfter without a keypress
+	idleAfter time.Duration
+	lastInput time.Time
+	idle      bool
+}
+
+// Messages for the TUI
+type tickMsg struct {
+	chain int
+}
+type positionMsg time.Duration
+type trackEndedMsg struct{}
+type playErrorMsg struct {
+	id   trackID
+	path string
+	err  error
+}
+type skipTrackMsg struct {
+	id trackID
+}
+type clearBannerMsg struct {
+	id int
+}
+type saveStateMsg struct{}
+type trackLoadedMsg struct {
+	id          trackID
+	duration    time.Duration
+	artist      string
+	title       string
+	album       string
+	albumArtist string
+	sortArtist  string
+	info        player.TrackInfo
+	art         *albumArt
+	picture     *tag.Picture
+
+	// What the track decodes to and the rate the speaker plays it at
+	format     beep.Format
+	outputRate beep.SampleRate
+
+	// The preview window playback started in, until is zero when the
+	// track plays in full
+	from  time.Duration
+	until time.Duration
+
+	// The remembered position playback picked up from, if any
+	resumed time.Duration
+
+	// Loaded with --paused, the player hasn't started it
+	prepared bool
+}
+type noteSavedMsg struct {
+	success bool
+	already bool
+	path    string
+	error   string
+}
+
+// NewPlayerModel creates a new player model playing playlist on player,
+// with codecs the formats it plays
+func NewPlayerModel(playlist []string, player player.Player, codecs *codec.Registry) *PlayerModel {
+	tracks := newTrackTable()
+	ids := tracks.AddAll(playlist)
+
+	m := &PlayerModel{
+		tracks:       tracks,
+		playlist:     ids,
+		currentIndex: 0,
+		player:       player,
+		codecs:       codecs,
+		ticks:        newTickPolicy(wallClock{}),
+		history:      newHistory(),
+		styles:       newViewStyles(theme),
+		failed:       make(map[string]error),
+		warnings:     make(map[string]error),
+		arts:         newArtCache(),
+		fullPlaylist: append([]trackID(nil), ids...),
+		knownTags:    make(map[string]trackTags),
+		filterInput:  newFilterInput(),
+		noted:        make(map[string]bool),
+		commentInput: newCommentInput(),
+		keys:         defaultKeyMap(),
+		work:         newBackgroundWork(),
+		speed:        1,
+		volume:       1,
+		preset:       -1,
+	}
+	m.indexAlbums(0)
+	return m
+}
+
+// EnableStatePersistence makes the model save its playback state under key
+// and start from the given track and position
+func (m *PlayerModel) EnableStatePersistence(key string, index int, position time.Duration) {
+	m.stateKey = key
+	if index >= 0 && index < len(m.playlist) {
+		m.currentIndex = index
+	}
+	m.resumeAt = position
+}
+
+// EnableAlbumStats records album listening history into stats and shows
+// album completion when smart shuffle is active
+func (m *PlayerModel) EnableAlbumStats(stats *albumStats, mode string) {
+	m.albumStats = stats
+	m.shuffleMode = mode
+}
+
+// Init initializes the model
+func (m *PlayerModel) Init() tea.Cmd {
+	// Start the first track
+	return tea.Batch(
+		m.loadCurrentTrack(),
+		m.tickCmd(),
+		m.saveStateTick(),
+		m.idleCmd(),
+		m.sleepTick(),
+		m.waitForScan(),
+		m.waitForWatch(),
+		m.waitForTagIndex(),
+	)
+}
+
+// Update handles messages and updates the model, then publishes any
+// change to the desktop integrations and writes finished listens and
+// plays
+func (m *PlayerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	m.publish()
+	m.inhibitSleep()
+	return model, tea.Batch(cmd, m.writeListens(), m.saveTrackStats())
+}
+
+// update handles a message
+func (m *PlayerModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.MouseMsg:
+		return m, m.updateMouse(msg)
+
+	case tea.FocusMsg:
+		// Back to the normal rate once the terminal is visible again
+		m.ticks.setFocused(true)
+		if m.playing && !m.paused {
+			return m, m.tickCmd()
+		}
+
+	case tea.BlurMsg:
+		// Nobody is watching, drop to the slow rate
+		m.ticks.setFocused(false)
+
+	case tea.ResumeMsg:
+		return m, m.resumed()
+
+	case shutdownMsg:
+		return m, m.quit()
+
+	case inhibitedMsg:
+		return m, m.inhibited(msg)
+
+	case tea.KeyMsg:
+		// Keys do nothing while quitting waits for background work
+		if m.quitting {
+			return m, nil
+		}
+
+		// ctrl+z suspends from anywhere, like ctrl+c quits
+		if msg.String() == "ctrl+z" {
+			return m, m.suspend()
+		}
+
+		// Only quitting works while waiting for the music directory
+		if m.storageWaiting() {
+			if msg.String() == "ctrl+c" || key.Matches(msg, m.keys.Quit) {
+				return m, m.quit()
+			}
+			return m, nil
+		}
+
+		// Any key wakes the screensaver without doing anything else
+		if m.resetIdle() && msg.String() != "ctrl+c" {
+			return m, nil
+		}
+
+		// The filter prompt takes every key while it is open
+		if m.filtering && msg.String() != "ctrl+c" {
+			return m.updateFilter(msg)
+		}
+
+		// So does the playlist pane
+		if m.pane.open && msg.String() != "ctrl+c" {
+			return m.updatePlaylistPane(msg)
+		}
+
+		// And the browse screen
+		if m.browse.open && msg.String() != "ctrl+c" {
+			return m.updateBrowse(msg)
+		}
+
+		// And the history
+		if m.historyPane.open && msg.String() != "ctrl+c" {
+			return m.updateHistory(msg)
+		}
+
+		// And the equalizer
+		if m.eqPane.open && msg.String() != "ctrl+c" {
+			return m.updateEQ(msg)
+		}
+
+		// And the note being typed, where even space types
+		if m.noting != nil && msg.String() != "ctrl+c" {
+			return m.updateCommentInput(msg)
+		}
+
+		// And the bookmark label prompt and list
+		if m.marking != nil && msg.String() != "ctrl+c" {
+			return m.updateMarkInput(msg)
+		}
+		if m.markPane.open && msg.String() != "ctrl+c" {
+			return m.updateMarks(msg)
+		}
+
+		// Any key closes the help
+		if m.showHelp && msg.String() != "ctrl+c" {
+			m.showHelp = false
+			return m, nil
+		}
+
+		// And the stats
+		if m.showStats && msg.String() != "ctrl+c" {
+			m.showStats = false
+			return m, nil
+		}
+
+		// The lyrics pane only takes the keys that scroll and close it
+		if m.lyricsPane.open && m.updateLyrics(msg) {
+			return m, nil
+		}
+
+		switch {
+		case msg.String() == "ctrl+c" || key.Matches(msg, m.keys.Quit):
+			return m, m.quit()
+
+		case key.Matches(msg, m.keys.PlayPause):
+			return m, m.togglePause()
+
+		case key.Matches(msg, m.keys.Previous):
+			// Restart, or back a chapter or to the track that played before
+			return m, m.previous()
+
+		case key.Matches(msg, m.keys.Next):
+			// Next chapter or track
+			return m, m.next()
+
+		case key.Matches(msg, m.keys.PreviousAlbum):
+			return m, m.jumpAlbum(-1)
+
+		case key.Matches(msg, m.keys.NextAlbum):
+			return m, m.jumpAlbum(1)
+
+		case key.Matches(msg, m.keys.Note):
+			// Save current track to notes
+			if m.playing {
+				return m, m.saveTrackNote()
+			}
+
+		case key.Matches(msg, m.keys.NoteComment):
+			// Note the current track with a comment
+			return m, m.openCommentInput()
+
+		case key.Matches(msg, m.keys.Filter):
+			// Filter the playlist
+			return m, m.openFilter()
+
+		case key.Matches(msg, m.keys.List):
+			// Browse the playlist
+			m.openPlaylistPane()
+
+		case key.Matches(msg, m.keys.History):
+			// Browse the tracks played so far
+			m.openHistory()
+
+		case key.Matches(msg, m.keys.EQ):
+			// Adjust the tone
+			m.eqPane.open = true
+
+		case key.Matches(msg, m.keys.Preset):
+			// Switch to the next sound preset
+			return m, m.cyclePreset()
+
+		case key.Matches(msg, m.keys.Mark):
+			// Bookmark the current position
+			return m, m.openMarkInput()
+
+		case key.Matches(msg, m.keys.Marks):
+			// Browse the bookmarks
+			m.openMarks()
+
+		case key.Matches(msg, m.keys.Sleep):
+			// Cycle the sleep timer
+			return m, m.cycleSleep()
+
+		case key.Matches(msg, m.keys.Slower):
+			return m, m.changeSpeed(-speedStep)
+
+		case key.Matches(msg, m.keys.Faster):
+			return m, m.changeSpeed(speedStep)
+
+		case key.Matches(msg, m.keys.NormalSpeed):
+			return m, m.changeSpeed(0)
+
+		case key.Matches(msg, m.keys.Retry):
+			// Restart the output after an audio error
+			return m, m.retryAudio()
+
+		case key.Matches(msg, m.keys.Preview):
+			// Toggle preview mode
+			return m, m.togglePreview()
+
+		case key.Matches(msg, m.keys.Restart):
+			// Play the track from its beginning
+			return m, m.restartTrack()
+
+		case key.Matches(msg, m.keys.Loop):
+			return m, m.markLoop()
+
+		case key.Matches(msg, m.keys.ClearLoop):
+			return m, m.clearLoop()
+
+		case key.Matches(msg, m.keys.Undo):
+			return m, m.undoNavigation()
+
+		case key.Matches(msg, m.keys.Veto):
+			return m, m.vetoNext()
+
+		case key.Matches(msg, m.keys.Rate):
+			return m, m.rate(m.ratingPressed(msg))
+
+		case key.Matches(msg, m.keys.ClearRating):
+			return m, m.rate(0)
+
+		case key.Matches(msg, m.keys.Full):
+			// Let a previewed track play to its end
+			if m.previewing() {
+				return m, tea.Batch(m.playInFull(), m.showBanner("Playing in full"))
+			}
+
+		case key.Matches(msg, m.keys.Info):
+			// Toggle the extended track info
+			m.showInfo = !m.showInfo
+			return m, m.savePrefs()
+
+		case key.Matches(msg, m.keys.Meter):
+			// Toggle the level meter
+			m.setMeter(!m.showMeter)
+			return m, m.savePrefs()
+
+		case key.Matches(msg, m.keys.ETA):
+			// Toggle the time left in the playlist
+			m.showETA = !m.showETA
+			return m, m.savePrefs()
+
+		case key.Matches(msg, m.keys.Mini):
+			// Toggle the mini view
+			m.mini = !m.mini
+			return m, m.savePrefs()
+
+		case key.Matches(msg, m.keys.Export):
+			// Export the playlist as it plays now
+			return m, m.exportPlaylist()
+
+		case key.Matches(msg, m.keys.Cover):
+			// Write the embedded cover art to a file
+			return m, m.saveCover()
+
+		case key.Matches(msg, m.keys.OpenFolder):
+			// Show the track's directory in the file manager
+			return m, m.openTrackFolder()
+
+		case key.Matches(msg, m.keys.Help):
+			// List every key
+			m.showHelp = true
+
+		case key.Matches(msg, m.keys.Stats):
+			// Most played tracks and artists, and this session
+			m.showStats = true
+
+		case key.Matches(msg, m.keys.Browse):
+			// Artists, their albums and the albums' tracks
+			m.toggleBrowse()
+
+		case key.Matches(msg, m.keys.Lyrics):
+			// Lyrics of the current track
+			m.toggleLyrics()
+
+		case key.Matches(msg, m.keys.Block):
+			// Never play the current track again
+			return m, m.blockTrack(m.current)
+
+		case key.Matches(msg, m.keys.NewRotation):
+			// Forget what was heard this rotation
+			return m, m.newRotation()
+		}
+
+	case tickMsg:
+		// Only the latest chain of ticks carries on
+		if msg.chain != m.ticks.chain {
+			return m, nil
+		}
+
+		// Get current position from player directly
+		m.position = m.player.GetPosition()
+		if m.duration > 0 && m.position > m.duration+durationSlack {
+			m.duration = 0
+		}
+
+		// A decoder that crashed mid-track moves on to the next one
+		if crashed := m.pollCrashes(); crashed != nil {
+			return m, crashed
+		}
+
+		// A failed stream or output pauses instead of moving on
+		if failed := m.checkAudio(); failed != nil {
+			return m, failed
+		}
+		news := m.pollStreamNews()
+
+		// The preloaded track took over, catch up with what is audible
+		if m.playing {
+			if track := m.player.TookOverNext(); track != "" {
+				return m, m.advanceTo(track)
+			}
+		}
+
+		// A preview moves on once its window has played
+		if m.previewOver() {
+			return m, m.endPreview()
+		}
+
+		// Check if track ended using the new HasEnded method
+		if m.playing && m.player.HasEnded() {
+			return m, func() tea.Msg {
+				return trackEndedMsg{}
+			}
+		}
+
+		// Only continue ticking if we're actually playing
+		if m.playing && !m.paused {
+			return m, tea.Batch(news, m.tickCmd())
+		}
+
+		return m, news
+
+	case audioErrorMsg:
+		m.audioFailed(msg)
+
+	case trackEndedMsg:
+		m.position = max(m.position, m.duration)
+		m.leaveTrack()
+		if m.atPlaylistEnd() {
+			finished := tea.Batch(m.recordAlbumProgress(m.currentTrack(), true), m.recordRotation(m.currentTrack()))
+			return m, tea.Batch(finished, m.finishPlaylist())
+		}
+		// No Stop here: LoadTrack stops a different track itself and
+		// rewinds the same one, say a single track on repeat
+		finished := tea.Batch(m.recordAlbumProgress(m.currentTrack(), true), m.recordRotation(m.currentTrack()))
+		m.advance()
+		return m, tea.Batch(finished, m.loadCurrentTrack())
+
+	case skipTrackMsg:
+		// Ignore stale skips if the user already moved on
+		if msg.id != m.current || m.playing {
+			return m, nil
+		}
+		if m.atPlaylistEnd() {
+			return m, m.finishPlaylist()
+		}
+		m.advance()
+		return m, m.loadCurrentTrack()
+
+	case saveStateMsg:
+		// Write a snapshot in the background and schedule the next one
+		// while playing. Paused or stopped the state holds still, and
+		// saving resumes with playback.
+		m.saving = false
+		st := m.snapshotState()
+		key, work := m.stateKey, m.work
+		var next tea.Cmd
+		if m.playing && !m.paused {
+			next = m.saveStateTick()
+		}
+		return m, tea.Batch(
+			work.Cmd(func(context.Context) tea.Msg {
+				if err := savePlaybackState(key, st); err != nil {
+					work.Failed("save the playback state", err)
+				}
+				return nil
+			}),
+			next,
+		)
+
+	case scanBatchMsg:
+		m.indexTags(msg.paths)
+		return m, tea.Batch(m.addScanned(msg), m.fingerprint(msg.paths))
+
+	case signaturesMsg:
+		m.addSignatures(msg)
+
+	case scanDoneMsg:
+		return m, m.finishScan(msg)
+
+	case trackNumbersMsg:
+		m.albumShuffleUpcoming(msg)
+		if m.playing {
+			return m, m.preloadNext()
+		}
+
+	case remoteMsg:
+		return m, m.handleRemote(msg)
+
+	case controlMsg:
+		return m, m.handleControl(msg)
+
+	case rescanMsg:
+		return m, m.continueWithNew(msg)
+
+	case tagIndexedMsg:
+		return m, m.tagsIndexed(msg)
+
+	case playlistChangedMsg:
+		return m, m.playlistChanged(msg)
+
+	case hookDoneMsg:
+		if msg.err != nil {
+			return m, m.showBanner(fmt.Sprintf("End of playlist hook failed: %v", msg.err))
+		}
+		return m, nil
+
+	case idleMsg:
+		return m, m.updateIdle()
+
+	case clearBannerMsg:
+		return m, m.bannerExpired(msg)
+
+	case trackLoadedMsg:
+		// A slow load that finished after a skip is for a track the
+		// player already left
+		if msg.id != m.current {
+			return m, nil
+		}
+		m.consecutiveFailures = 0
+		m.storage.misses = nil
+		m.ended = false
+		if m.weighted != nil {
+			m.weighted.plays++
+		}
+		m.playing = true
+		m.paused = msg.prepared
+		m.prepared = msg.prepared
+		m.resetLoop()
+		m.position = msg.from
+		m.preview.from = msg.from
+		m.preview.until = msg.until
+		m.startedAt = m.now()
+		m.startListen()
+		m.session.hearing = true
+		m.player.Meter().Reset()
+		m.duration = msg.duration
+		m.artist = msg.artist
+		m.title = msg.title
+		m.album = msg.album
+		m.info = msg.info
+		m.lyricsPane.offset = 0
+		m.resampled = resampleLabel(msg.format.SampleRate, msg.outputRate)
+		m.art = msg.art
+		m.knownTags[m.tracks.Path(msg.id)] = trackTags{
+			artist:      msg.artist,
+			title:       msg.title,
+			album:       msg.album,
+			albumArtist: msg.albumArtist,
+			sortArtist:  msg.sortArtist,
+			number:      playlist.DiscTrack{Disc: msg.info.Disc, Track: msg.info.Track},
+			length:      msg.duration,
+		}
+		m.recordLength(m.tracks.Path(msg.id), msg.duration)
+		m.catalogTags([]string{m.tracks.Path(msg.id)})
+
+		// Compare the tagged length with what actually decoded
+		var warning tea.Cmd
+		if msg.resumed > 0 {
+			m.position = msg.resumed
+			warning = m.showBanner("Resumed at " + formatDuration(msg.resumed) + ", " + m.keys.Restart.Help().Key + " restarts")
+		}
+		if m.seekPending(msg.id) {
+			warning = m.showBanner("Jumped to bookmark at " + formatDuration(m.position))
+		}
+		if err := durationMismatch(msg.info.TagDuration, msg.duration, m.durationTolerance); err != nil {
+			path := m.tracks.Path(msg.id)
+			m.warnings[path] = err
+			warning = m.showBanner(fmt.Sprintf("%s: %v", filepath.Base(path), err))
+		}
+
+		// Previews count apart from plays
+		progress := m.recordAlbumProgress(m.currentTrack(), false)
+		if m.previewing() {
+			progress = m.recordPreview(m.currentTrack())
+		}
+
+		// A track loaded paused neither ticks nor preloads until space
+		// starts it
+		if msg.prepared {
+			m.startPaused = false
+			m.position = m.player.GetPosition()
+			return m, tea.Batch(progress, warning, m.notifyTrack(msg.picture))
+		}
+
+		// Restart the tick cycle for position updates
+		return m, tea.Batch(m.tickCmd(), m.saveStateTick(), progress, m.preloadNext(), warning, m.notifyTrack(msg.picture))
+
+	case sleepTickMsg:
+		return m, m.updateSleep(msg)
+
+	case playlistExportedMsg:
+		return m, m.playlistExported(msg)
+
+	case coverSavedMsg:
+		return m, m.coverSaved(msg)
+
+	case folderOpenedMsg:
+		return m, m.folderOpened(msg)
+
+	case listensWrittenMsg:
+		return m, m.listensWritten(msg)
+
+	case markSavedMsg:
+		return m, m.marksSaved(msg)
+
+	case ratingSavedMsg:
+		return m, m.ratingSaved(msg)
+
+	case blockSavedMsg:
+		return m, m.blockSaved(msg)
+
+	case noteSavedMsg:
+		return m, m.noteSaved(msg)
+
+	case notifiedMsg:
+		return m, m.notified(msg)
+
+	case playErrorMsg:
+		// Record the failure against this entry and move on, unless the
+		// player already left it
+		if msg.id != m.current {
+			m.failed[msg.path] = msg.err
+			return m, nil
+		}
+		m.playing = false
+		m.paused = false
+		m.failed[msg.path] = msg.err
+		m.skipped++
+		m.consecutiveFailures++
+
+		// Several files missing in a row may mean their drive is gone
+		look, skip := m.trackMissing(msg)
+		if !skip {
+			return m, look
+		}
+		return m, m.skipFailed(msg)
+
+	case storageMsg:
+		return m, m.storageLooked(msg)
+
+	case positionMsg:
+		m.position = time.Duration(msg)
+	}
+
+	return m, nil
+}
+
+// View renders the TUI
+func (m *PlayerModel) View() string {
+	if m.quitting {
+		return "Saving…"
+	}
+
+	// Only what this frame draws can be clicked
+	m.hits = mouseHits{}
+
+	if m.err != nil {
+		return m.fitLines(fmt.Sprintf("Error: %v", m.err), "Press 'q' or 'esc' to quit")
+	}
+
+	if m.storageWaiting() {
+		return m.fitLines("Music directory unavailable — waiting for it to return", m.storage.waiting, "Press 'q' or 'esc' to quit")
+	}
+
+	if len(m.playlist) == 0 {
+		if m.scanning {
+			return m.fitLines(fmt.Sprintf("Scanning… %d files found", m.scanFound), "Press 'q' or 'esc' to quit")
+		}
+		return m.fitLines("No tracks in playlist", "Press 'q' or 'esc' to quit")
+	}
+
+	if m.idle {
+		return m.viewScreensaver()
+	}
+
+	if m.pane.open {
+		return m.viewPlaylistPane()
+	}
+
+	if m.browse.open {
+		return m.viewBrowse()
+	}
+
+	if m.historyPane.open {
+		return m.viewHistory()
+	}
+
+	if m.markPane.open {
+		return m.viewMarks()
+	}
+
+	if m.eqPane.open {
+		return m.viewEQ()
+	}
+
+	if m.showHelp {
+		return m.viewHelp()
+	}
+
+	if m.showStats {
+		return m.viewStats()
+	}
+
+	if m.lyricsPane.open {
+		return m.viewLyrics()
+	}
+
+	if m.showMini() {
+		return m.viewMini()
+	}
+
+	styles := m.styles
+
+	// Cover art goes to the left when the terminal has room for it, and
+	// the text lines are cut to fit beside it
+	var art string
+	if m.art != nil && !styles.NoArt {
+		if cols := artColumns(m.width, m.height); cols > 0 {
+			art = styles.Art.Render(m.art.Render(cols))
+		}
+	}
+	width := m.viewWidth() - lipgloss.Width(art)
+	m.hits.player = true
+
+	// Build the UI
+	var content strings.Builder
+
+	// Title, with any non-default end of playlist behavior
+	header := glyphs.Note + " dirplay"
+	if label := m.atEndLabel(); label != "" {
+		header += "  · " + label
+	}
+	if label := m.presetLabel(); label != "" {
+		header += "  · " + label
+	}
+	content.WriteString(styles.Title.Render(fitText(header, width)))
+	content.WriteString("\n\n")
+
+	// Current track
+	content.WriteString(styles.Track.Render(fitText("Playing: "+m.trackName(), width)))
+	content.WriteString("\n")
+
+	// Chapter of a single-file album or mix
+	if chapter := m.chapterLabel(); chapter != "" {
+		content.WriteString(styles.Track.Render(fitText(chapter, width)))
+		content.WriteString("\n")
+	}
+
+	// Track info
+	content.WriteString(styles.Status.Render(fitText(m.trackInfoLine(), width)))
+	content.WriteString("\n")
+
+	// The track that plays next, queued or not
+	if next := m.nextLabel(); next != "" {
+		content.WriteString(styles.Status.Render(fitText(next, width)))
+		content.WriteString("\n")
+	}
+
+	// Extended info, toggled with "i"
+	if m.showInfo {
+		for _, line := range []string{infoTags(m.info), infoStream(m.info)} {
+			if line != "" {
+				content.WriteString(styles.Status.UnsetMarginBottom().Render(fitText(line, width)))
+				content.WriteString("\n")
+			}
+		}
+		if m.resampled != "" {
+			content.WriteString(styles.Banner.Render(fitText(m.resampled, width)))
+			content.WriteString("\n")
+		}
+	}
+
+	// Status, led by buttons for the mouse
+	status := m.stateIcon() + " " + m.nowPlaying().status
+	if m.mouse {
+		status = m.transport(strings.Count(content.String(), "\n"), lipgloss.Width(art)) + m.nowPlaying().status
+	}
+	if label := m.awakeLabel(); label != "" {
+		status += " " + label
+	}
+	if label := m.speedLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.sortLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.replayGainLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.eqLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.previewLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.loopLabel(); label != "" {
+		status += "  · " + label
+	}
+	if label := m.sleepLabel(); label != "" {
+		status += "  · " + label
+	}
+	content.WriteString(styles.Status.Render(fitText(status, width)))
+	content.WriteString("\n")
+
+	// Playback is paused on an audio error until it is retried
+	if line := m.audioErrorLine(); line != "" {
+		content.WriteString(styles.Alert.Render(fitText(line, width)))
+		content.WriteString("\n")
+	}
+
+	// Non-fatal problems, e.g. a track that was skipped. The counts stay
+	// in view, the banner is cut to fit beside them.
+	var counts string
+	if m.shuffleMode == playlist.ShuffleSmart && m.albumStats != nil {
+		if ratio, ok := m.albumStats.CompletionRatio(playlist.AlbumKey(m.currentTrack())); ok {
+			counts += fmt.Sprintf("  · album finished %d%%", int(ratio*100))
+		}
+	}
+	if m.skipped > 0 {
+		counts += fmt.Sprintf("  (%d skipped)", m.skipped)
+	}
+	if len(m.warnings) > 0 {
+		counts += fmt.Sprintf("  (%d warnings)", len(m.warnings))
+	}
+	if label := m.suppressedLabel(); label != "" {
+		counts += "  (" + label + ")"
+	}
+	if m.banner != "" {
+		content.WriteString(styles.Banner.Render(fitText(m.banner, width-lipgloss.Width(counts))))
+	}
+	if counts != "" {
+		content.WriteString(styles.Status.Render(fitText(counts, width)))
+	}
+	content.WriteString("\n\n")
+
+	// Level meter, toggled with "v"
+	if m.showMeter {
+		content.WriteString(" " + renderMeter(m.player.Meter().Levels(), styles))
+		content.WriteString("\n")
+	}
+
+	// Progress bar, brackets included as wide as the view, so it follows
+	// the terminal as it is resized
+	barWidth := max(width-2, 0)
+	m.recordBar(strings.Count(content.String(), "\n"), lipgloss.Width(art), barWidth)
+	progressBar := m.renderProgressBar(barWidth)
+	content.WriteString(styles.Accent.Render(progressBar))
+	content.WriteString("\n")
+
+	// Time display, counting down at the current speed
+	clock := m.timeLabel()
+	if left := m.remainingLabel(); left != "" {
+		clock += "  (" + left + ")"
+	}
+	if m.showETA {
+		clock += "  · " + m.etaLabel()
+	}
+	content.WriteString(styles.Status.Render(fitText(clock, width)))
+	content.WriteString("\n")
+
+	// Filter prompt with a live match count
+	if m.marking != nil {
+		content.WriteString(m.markInput.View())
+		content.WriteString("\n")
+	}
+	if m.noting != nil {
+		content.WriteString(m.commentInput.View())
+		content.WriteString("\n")
+	}
+	if m.filtering {
+		content.WriteString(m.filterInput.View())
+		content.WriteString(styles.Status.Render(fmt.Sprintf("  %d matches", m.filterMatchCount())))
+		content.WriteString("\n")
+	}
+
+	// Controls
+	controls := m.controlsLine()
+	content.WriteString(styles.Controls.Width(width).Render(controls))
+
+	if art != "" {
+		return lipgloss.JoinHorizontal(lipgloss.Top, art, content.String())
+	}
+	return content.String()
+}
+
+// renderProgressBar renders a progress bar, or a block moving back and
+// forth while the length of the track is unknown
+func (m *PlayerModel) renderProgressBar(width int) string {
+	width = max(width, 0)
+	if m.duration == 0 {
+		const block = 4
+		if !m.playing || width <= block {
+			return fmt.Sprintf("[%s]", strings.Repeat(glyphs.Empty, width))
+		}
+		at := int(displayPosition(m.position, 0)/(250*time.Millisecond)) % (2 * (width - block))
+		if at > width-block {
+			at = 2*(width-block) - at
+		}
+		return fmt.Sprintf("[%s%s%s]", strings.Repeat(glyphs.Empty, at), strings.Repeat(glyphs.Filled, block), strings.Repeat(glyphs.Empty, width-block-at))
+	}
+
+	exact := func(d time.Duration) float64 {
+		return float64(displayPosition(d, m.duration)) / float64(m.duration) * float64(width)
+	}
+	cell := func(d time.Duration) int {
+		return min(int(exact(d)), width)
+	}
+	filled := cell(m.position)
+
+	// A preview's window is marked within the full track
+	from, until := filled, filled
+	if m.previewing() {
+		from, until = max(cell(m.preview.from), filled), max(cell(m.preview.until), filled)
+	}
+
+	cells := slices.Concat(slices.Repeat([]string{glyphs.Filled}, filled), slices.Repeat([]string{glyphs.Empty}, from-filled),
+		slices.Repeat([]string{glyphs.Preview}, until-from), slices.Repeat([]string{glyphs.Empty}, width-until))
+
+	// The cell being played through fills by eighths where the glyphs
+	// allow, so the bar moves smoothly however wide it is
+	if eighths := int((exact(m.position) - float64(filled)) * 8); eighths > 0 && filled < width && cells[filled] == glyphs.Empty && glyphs.Partial != nil {
+		cells[filled] = glyphs.Partial[eighths-1]
+	}
+
+	// Chapters after the first are ticked where they start
+	for _, chapter := range m.trackChapters() {
+		if at := cell(chapter.Start); chapter.Start > 0 && at < width {
+			cells[at] = glyphs.Chapter
+		}
+	}
+
+	// So are the ends of a loop
+	if m.loop.marked {
+		for _, end := range []time.Duration{m.loop.from, m.loop.until} {
+			if at := cell(end); end > 0 && at < width {
+				cells[at] = glyphs.Loop
+			}
+		}
+	}
+	return fmt.Sprintf("[%s]", strings.Join(cells, ""))
+}
+
+// Failures returns the playlist entries that failed to load this session
+func (m *PlayerModel) Failures() map[string]error {
+	return m.failed
+}
+
+// Warnings returns the tracks that played but look damaged, keyed by path
+func (m *PlayerModel) Warnings() map[string]error {
+	return m.warnings
+}
+
+// SetDurationTolerance sets how far, in percent, the decoded length may
+// differ from the tagged length before a track gets a warning. Zero
+// disables the check.
+func (m *PlayerModel) SetDurationTolerance(percent float64) {
+	m.durationTolerance = percent
+}
+
+// SetVolume sets the output level from 0 to 1, which the sleep fade
+// starts from and returns to
+func (m *PlayerModel) SetVolume(volume float64) {
+	m.volume = volume
+	m.player.SetGain(volume)
+}
+
+// SetTickInterval sets how often the view updates when no feature asks
+// for faster ticks
+func (m *PlayerModel) SetTickInterval(interval time.Duration) {
+	m.ticks.baseline = interval
+}
+
+// currentTrack returns the path of the current track
+func (m *PlayerModel) currentTrack() string {
+	return m.tracks.Path(m.current)
+}
+
+// step moves currentIndex by delta playlist entries, wrapping around at
+// either end
+func (m *PlayerModel) step(delta int) {
+	if len(m.playlist) == 0 {
+		return
+	}
+	m.currentIndex = ((m.currentIndex+delta)%len(m.playlist) + len(m.playlist)) % len(m.playlist)
+}
+
+// indexOf returns the playlist position of a track, or -1
+func (m *PlayerModel) indexOf(id trackID) int {
+	for i, entry := range m.playlist {
+		if entry == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// setPlaylist replaces the active playlist. The current track is followed
+// by its ID, so currentIndex keeps pointing at the song that is playing. It
+// returns false when the current track is no longer part of the playlist,
+// in which case currentIndex points at the entry that should play next.
+func (m *PlayerModel) setPlaylist(ids []trackID) bool {
+	m.playlist = ids
+	m.indexAlbums(0)
+	if index := m.indexOf(m.current); index >= 0 {
+		m.currentIndex = index
+		return true
+	}
+
+	if m.currentIndex >= len(m.playlist) {
+		m.currentIndex = 0
+	}
+	return false
+}
+
+// appendTracks shuffles the files that aren't known yet onto the end of the
+// playlist, or appends them in order with KeepScanOrder, and returns the playlist position of the first one added, or -1
+// if none passes the active filter
+func (m *PlayerModel) appendTracks(paths []string) int {
+	var fresh []string
+	for _, path := range paths {
+		if !m.tracks.Known(path) {
+			fresh = append(fresh, path)
+		}
+	}
+	return m.appendPaths(fresh)
+}
+
+// appendPaths is appendTracks for files known not to be in the playlist,
+// which may have been in it before
+func (m *PlayerModel) appendPaths(paths []string) int {
+	if !m.keepOrder {
+		playlist.Shuffle(paths)
+	}
+
+	first := -1
+	defer m.indexAlbums(len(m.playlist))
+	for _, id := range m.tracks.AddAll(paths) {
+		m.fullPlaylist = append(m.fullPlaylist, id)
+		path := m.tracks.Path(id)
+		if matchesFilter(path, m.knownTags[path], m.filterQuery) {
+			if first < 0 {
+				first = len(m.playlist)
+			}
+			m.playlist = append(m.playlist, id)
+		}
+	}
+	return first
+}
+
+// recordAlbumProgress counts a started or completed track towards its
+// album's history and saves it in the background
+func (m *PlayerModel) recordAlbumProgress(track string, completed bool) tea.Cmd {
+	if m.albumStats == nil || track == "" {
+		return nil
+	}
+	// A preview that ran out didn't play the track
+	if completed && m.previewing() {
+		return nil
+	}
+
+	if completed {
+		m.albumStats.TrackCompleted(track)
+	} else {
+		m.albumStats.TrackStarted(track)
+	}
+
+	stats, work := m.albumStats, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		if err := stats.Save(); err != nil {
+			work.Failed("save album stats", err)
+		}
+		return nil
+	})
+}
+
+// snapshotState captures the playback state worth restoring next session
+func (m *PlayerModel) snapshotState() playbackState {
+	// Save the unfiltered order so a filter doesn't shrink the next session
+	playlist := m.tracks.Paths(m.fullPlaylist)
+
+	index := 0
+	current := m.currentTrack()
+	for i, path := range playlist {
+		if path == current {
+			index = i
+			break
+		}
+	}
+
+	return playbackState{
+		Playlist:     playlist,
+		CurrentIndex: index,
+		PositionMS:   m.position.Milliseconds(),
+		BookmarksMS:  m.bookmarksMS(),
+	}
+}
+
+// saveSnapshot writes a playback state snapshot immediately, used on quit
+func (m *PlayerModel) saveSnapshot(st playbackState) error {
+	if m.stateKey == "" {
+		return nil
+	}
+	return savePlaybackState(m.stateKey, st)
+}
+
+// saveStateTick schedules the next periodic state save, unless one is
+// already
+func (m *PlayerModel) saveStateTick() tea.Cmd {
+	if m.stateKey == "" || m.saving {
+		return nil
+	}
+	m.saving = true
+	return m.ticks.clock.Tick(stateSaveInterval, func(time.Time) tea.Msg {
+		return saveStateMsg{}
+	})
+}
+
+// skipFailed reports a track that failed to load and skips to the next
+// after skipDelay. When every entry failed in a row it waits for the scan
+// to find more, or stops rather than skipping forever.
+func (m *PlayerModel) skipFailed(msg playErrorMsg) tea.Cmd {
+	if m.consecutiveFailures >= len(m.playlist) && m.scanning {
+		m.current = noTrack
+		return m.showBanner(fmt.Sprintf("Skipping %s: %v", filepath.Base(msg.path), msg.err))
+	}
+	if m.consecutiveFailures >= len(m.playlist) {
+		m.err = fmt.Errorf("all %d tracks failed to load", len(m.playlist))
+		return nil
+	}
+
+	return tea.Batch(
+		m.showBanner(fmt.Sprintf("Skipping %s: %v", filepath.Base(msg.path), msg.err)),
+		m.ticks.clock.Tick(skipDelay, func(time.Time) tea.Msg {
+			return skipTrackMsg{id: msg.id}
+		}),
+	)
+}
+
+// tickCmd returns a command to send the next tick. It starts a new chain
+// of ticks, which ends the one running, so starting it again, e.g. on
+// every track, never leaves two chains waking the program.
+func (m *PlayerModel) tickCmd() tea.Cmd {
+	m.ticks.chain++
+	chain := m.ticks.chain
+	return m.ticks.clock.Tick(m.tickWait(), func(time.Time) tea.Msg {
+		return tickMsg{chain: chain}
+	})
+}
+
+// loadCurrentTrack loads and plays the current track
+func (m *PlayerModel) loadCurrentTrack() tea.Cmd {
+	// A restored session starts part way into its first track
+	resumeAt := m.resumeAt
+	m.resumeAt = 0
+	preview := m.preview
+	prepare := m.startPaused
+
+	// From here on the current track is followed by ID, not position
+	if m.currentIndex < 0 || m.currentIndex >= len(m.playlist) {
+		return nil
+	}
+	id := m.playlist[m.currentIndex]
+	track := m.tracks.Path(id)
+	m.current = id
+
+	// A long track left part way earlier picks up there
+	bookmark := m.bookmarks[track]
+	if resumeAt > 0 || preview.on {
+		bookmark = 0
+	}
+
+	// A file that crashed its decoder isn't tried again
+	if err := m.crashedBefore(track); err != nil {
+		return func() tea.Msg {
+			return playErrorMsg{id: id, path: track, err: err}
+		}
+	}
+
+	return m.work.Cmd(func(ctx context.Context) tea.Msg {
+		// Load the track, unless quit came first while the file opened
+		err := m.player.LoadTrack(track)
+		if errors.Is(err, player.ErrLoadSuperseded) {
+			return nil
+		}
+		if err != nil {
+			return playErrorMsg{id: id, path: track, err: err}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Pick up where the previous session or the last visit left off,
+		// or jump to the preview window
+		var from, until time.Duration
+		if resumeAt > 0 {
+			m.player.Seek(resumeAt)
+		} else if bookmark > 0 {
+			// The file may have changed and be shorter now
+			if m.player.Seek(bookmark) != nil {
+				bookmark = 0
+			}
+		} else if preview.on {
+			from, until = preview.window(m.player.GetDuration())
+			if from > 0 {
+				m.player.Seek(from)
+			}
+		}
+
+		// Start playing, unless --paused leaves that to space
+		if prepare {
+			msg := m.loadedMsg(id, track)
+			msg.from, msg.until = from, until
+			msg.prepared = true
+			return msg
+		}
+		if err := m.player.Play(); err != nil {
+			return playErrorMsg{id: id, path: track, err: err}
+		}
+
+		msg := m.loadedMsg(id, track)
+		msg.from, msg.until = from, until
+		msg.resumed = bookmark
+		return msg
+	})
+}
+
+// loadedMsg describes the track the player has just started
+func (m *PlayerModel) loadedMsg(id trackID, track string) trackLoadedMsg {
+	// Decode the cover art here so the UI never waits on it
+	art, seen := m.arts.Get(track)
+	if !seen {
+		art = decodeAlbumArt(m.player.GetPicture())
+		m.arts.Put(track, art)
+	}
+
+	return trackLoadedMsg{
+		id:          id,
+		duration:    m.player.GetDuration(),
+		artist:      m.player.GetArtist(),
+		title:       m.player.GetTitle(),
+		album:       m.player.GetAlbum(),
+		albumArtist: m.player.GetAlbumArtist(),
+		sortArtist:  m.player.GetSortArtist(),
+		info:        m.player.GetInfo(),
+		art:         art,
+		picture:     m.player.GetPicture(),
+		format:      m.player.GetFormat(),
+		outputRate:  m.player.OutputRate(),
+	}
+}
+
+// preloadNext decodes the next playlist entry in the background so it
+// starts without a gap when the current track ends
+func (m *PlayerModel) preloadNext() tea.Cmd {
+	// Previews jump into the next track rather than running into it
+	if len(m.playlist) < 2 || m.currentIndex < 0 || m.atPlaylistEnd() || m.previewing() {
+		return nil
+	}
+
+	id := m.playlist[m.nextIndex()]
+	track := m.tracks.Path(id)
+
+	return m.work.Cmd(func(context.Context) tea.Msg {
+		// A track that fails here is reported when it is loaded normally
+		m.player.Preload(track)
+		return nil
+	})
+}
+
+// advanceTo moves the model on to the preloaded track once the player has
+// switched to it. The tick cycle restarts with its trackLoadedMsg.
+func (m *PlayerModel) advanceTo(track string) tea.Cmd {
+	finished := tea.Batch(m.recordAlbumProgress(m.currentTrack(), true), m.recordRotation(m.currentTrack()))
+
+	// The old track played to its end, the position already belongs to
+	// the new one
+	m.position = m.duration
+	m.leaveTrack()
+
+	// Normally the track preloaded is still the one up next
+	id := m.tracks.Add(track)
+	if next := m.nextIndex(); next < len(m.playlist) && m.playlist[next] == id {
+		m.advance()
+	} else if index := m.indexOf(id); index >= 0 {
+		m.currentIndex = index
+	}
+	m.current = id
+
+	// Preview mode came on after the track was preloaded, jump to its
+	// window
+	if m.preview.on && m.indexOf(id) >= 0 {
+		return tea.Batch(finished, m.loadCurrentTrack())
+	}
+
+	return tea.Batch(finished, func() tea.Msg {
+		return m.loadedMsg(id, track)
+	})
+}
+
+// togglePause pauses or resumes playback, or starts over once the playlist
+// has ended
+func (m *PlayerModel) togglePause() tea.Cmd {
+	if m.ended {
+		return m.restartPlaylist()
+	}
+	if !m.playing {
+		return nil
+	}
+	if m.prepared {
+		return m.startPrepared()
+	}
+
+	if m.paused {
+		m.player.Resume()
+		m.paused = false
+		// Restart ticking when resuming
+		return tea.Batch(m.tickCmd(), m.saveStateTick())
+	}
+
+	// Nothing ticks while paused: the running chain ends here, so the
+	// tick already on its way is dropped
+	m.player.Pause()
+	m.paused = true
+	m.ticks.chain++
+	return nil
+}
+
+// skip moves through the playlist and plays that track. Moving forward
+// takes the next queued track first and records the current one in the
+// history; moving back steps delta entries back.
+func (m *PlayerModel) skip(delta int) tea.Cmd {
+	m.player.Stop()
+	if delta > 0 {
+		m.leaveTrack()
+		m.advance()
+	} else {
+		m.leaveTrackBack()
+		m.step(delta)
+	}
+	return m.loadCurrentTrack()
+}
+
+// quit shuts the player down, then stops the background work and saves
+// the session once it has finished, or after shutdownTimeout
+func (m *PlayerModel) quit() tea.Cmd {
+	if m.quitting {
+		return nil
+	}
+	m.quitting = true
+	m.player.Stop()
+
+	// The track playing may have played far enough to count, and goes
+	// in the listen log, which is written along with this message
+	m.endTrack()
+	stats := m.trackStats
+	if stats != nil && !stats.changed() {
+		stats = nil
+	}
+
+	// Writes still pending, such as a note saved just before, land
+	// before the state and stats are saved and the program exits
+	st := m.snapshotState()
+	work := m.work
+	return func() tea.Msg {
+		if !work.Stop(shutdownTimeout) {
+			work.Failed("finish in time", fmt.Errorf("background work was still running after %v, the last changes may not be saved", shutdownTimeout))
+		}
+		if err := m.saveSnapshot(st); err != nil {
+			work.Failed("save the playback state", err)
+		}
+		if stats != nil {
+			if err := stats.Save(); err != nil {
+				work.Failed("save play counts", err)
+			}
+		}
+		m.player.Close()
+		return tea.QuitMsg{}
+	}
+}
+
+// formatDuration formats a time.Duration as mm:ss, or h:mm:ss from an
+// hour on. Sub-second precision is only dropped here; everything else
+// works with the full Duration. Negative durations, which the position
+// math can briefly give, show as 00:00.
+func formatDuration(d time.Duration) string {
+	return formatDurationFor(d, d)
+}
+
+// formatDurationFor formats d the way a time as long as span is
+// formatted, so a position shows as 0:07:12 beside a length of 1:13:40
+func formatDurationFor(d, span time.Duration) string {
+	total := int(max(d, 0) / time.Second)
+	if span >= time.Hour || total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
+
+// formatLength formats the length of a track, "--:--" while it is unknown
+func formatLength(length time.Duration) string {
+	if length <= 0 {
+		return "--:--"
+	}
+	return formatDuration(length)
+}
+
+// displayPosition clamps the position to the track, so the last buffer
+// of a track can't show a time past its end, nor a position a moment
+// before its start one below zero. A duration of 0 is unknown and only
+// holds the start.
+func displayPosition(position, duration time.Duration) time.Duration {
+	position = max(position, 0)
+	if duration > 0 && position > duration {
+		return duration
+	}
+	return position
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// harness runs a PlayerModel on the fake player and clock the way the
+// Bubble Tea runtime would: every message goes through Update, and the
+// commands it returns run straight away, their messages in turn going
+// through Update. Timers fire only when the clock is moved on.
+type harness struct {
+	t      *testing.T
+	m      *PlayerModel
+	player *fakePlayer
+	clock  *fakeClock
+	quit   bool
+}
+
+// newHarness starts a model on tracks, in that order, with the config
+// and notes file in a temp directory
+func newHarness(t *testing.T, tracks ...string) *harness {
+	t.Helper()
+	dir := useTempConfig(t)
+	clock := newFakeClock()
+	h := &harness{t: t, clock: clock, player: newFakePlayer(clock)}
+	h.m = NewPlayerModel(tracks, h.player, codec.New())
+	h.m.ticks.clock = clock
+	h.m.SetNotesFile(filepath.Join(dir, "notes.md"))
+	h.m.SetRestartAfter(defaultRestartAfter)
+	h.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return h
+}
+
+// start runs Init, which loads and plays the first track
+func (h *harness) start() *harness {
+	h.run(h.m.Init())
+	return h
+}
+
+// send passes msg to Update and runs what it returns
+func (h *harness) send(msg tea.Msg) {
+	h.t.Helper()
+	if h.quit {
+		h.t.Fatalf("%T sent after the program quit", msg)
+	}
+	_, cmd := h.m.Update(msg)
+	h.run(cmd)
+}
+
+// run runs cmd and sends on what it returns. The commands of a batch run
+// at once, as the runtime runs them, and their messages are sent on in
+// the batch's order once all have returned.
+func (h *harness) run(cmd tea.Cmd) {
+	h.t.Helper()
+	if cmd != nil {
+		h.deliver(cmd())
+	}
+}
+
+// deliver sends msg on the way the runtime would
+func (h *harness) deliver(msg tea.Msg) {
+	h.t.Helper()
+	switch msg := msg.(type) {
+	case nil:
+	case tea.BatchMsg:
+		msgs := make([]tea.Msg, len(msg))
+		var wg sync.WaitGroup
+		for i, cmd := range msg {
+			if cmd != nil {
+				wg.Go(func() { msgs[i] = cmd() })
+			}
+		}
+		wg.Wait()
+		for _, msg := range msgs {
+			h.deliver(msg)
+		}
+	case tea.QuitMsg:
+		h.quit = true
+	default:
+		if !h.quit {
+			h.send(msg)
+		}
+	}
+}
+
+// press sends a key the way the terminal reports it, e.g. " ", "n",
+// "left" or "ctrl+c"
+func (h *harness) press(keys ...string) {
+	h.t.Helper()
+	named := map[string]tea.KeyType{
+		" ": tea.KeySpace, "left": tea.KeyLeft, "right": tea.KeyRight,
+		"enter": tea.KeyEnter, "esc": tea.KeyEsc, "ctrl+c": tea.KeyCtrlC,
+	}
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		if typ, ok := named[k]; ok {
+			msg = tea.KeyMsg{Type: typ}
+			if typ == tea.KeySpace {
+				msg.Runes = []rune{' '}
+			}
+		}
+		h.send(msg)
+	}
+}
+
+// advance moves the clock on by d, firing the timers due on the way in
+// order
+func (h *harness) advance(d time.Duration) {
+	h.t.Helper()
+	until := h.clock.now.Add(d)
+	for !h.quit {
+		timer, ok := h.clock.due(until)
+		if !ok {
+			break
+		}
+		h.send(timer.fn(h.clock.now))
+	}
+	h.clock.now = until
+}
+
+// playing returns the file name of the track the model is on
+func (h *harness) playing() string {
+	return filepath.Base(h.m.currentTrack())
+}
+
+// pendingTicks counts the ticks of the running chain waiting on the clock
+func (h *harness) pendingTicks() int {
+	n := 0
+	for _, timer := range h.clock.timers {
+		if msg, ok := timer.fn(timer.at).(tickMsg); ok && msg.chain == h.m.ticks.chain {
+			n++
+		}
+	}
+	return n
+}
+
+// album returns n tracks under /music/album named 01.mp3, 02.mp3, ...
+func album(n int) []string {
+	tracks := make([]string, n)
+	for i := range tracks {
+		tracks[i] = filepath.Join(string(filepath.Separator)+"music", "album", fmt.Sprintf("%02d.mp3", i+1))
+	}
+	return tracks
+}
+
+func TestModelStartsFirstTrack(t *testing.T) {
+	h := newHarness(t, album(3)...).start()
+	if !h.m.playing || h.m.paused {
+		t.Fatalf("playing = %v, paused = %v after Init, want playing", h.m.playing, h.m.paused)
+	}
+	if h.playing() != "01.mp3" || h.m.title != "01" || h.m.duration != fakeTrackLength {
+		t.Errorf("on %s titled %q of %v, want 01.mp3 titled 01 of %v", h.playing(), h.m.title, h.m.duration, fakeTrackLength)
+	}
+	if got := h.player.log(); !slices.Equal(got, []string{"load 01.mp3", "play 01.mp3"}) {
+		t.Errorf("player calls = %v", got)
+	}
+}
+
+// TestModelAutoAdvance plays the playlist through: each track that ends
+// on a tick gives way to the next, and after the last it wraps to the
+// first, unless --at-end says to stop
+func TestModelAutoAdvance(t *testing.T) {
+	tests := []struct {
+		atEnd string
+		want  []string
+		ended bool
+	}{
+		{"", []string{"02.mp3", "03.mp3", "01.mp3", "02.mp3"}, false},
+		{atEndRepeat, []string{"02.mp3", "03.mp3", "01.mp3", "02.mp3"}, false},
+		{atEndStop, []string{"02.mp3", "03.mp3", "03.mp3", "03.mp3"}, true},
+	}
+	for _, tt := range tests {
+		t.Run("at end "+tt.atEnd, func(t *testing.T) {
+			h := newHarness(t, album(3)...)
+			h.m.EnableAtEnd(tt.atEnd, nil)
+			for _, track := range album(3) {
+				h.player.setTrack(track, fakeTrack{length: 10 * time.Second, title: filepath.Base(track)})
+			}
+			h.start()
+
+			// A track's end shows on the tick just after it, so each step
+			// goes a little past the 10 seconds
+			var got []string
+			for range tt.want {
+				h.advance(10*time.Second + 500*time.Millisecond)
+				got = append(got, h.playing())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+			if h.m.ended != tt.ended {
+				t.Errorf("ended = %v, want %v", h.m.ended, tt.ended)
+			}
+			if tt.ended && h.pendingTicks() != 0 {
+				t.Error("still ticking after the end of the playlist")
+			}
+		})
+	}
+}
+
+// TestModelRepeatOne plays a playlist round hundreds of times: the
+// model loads each track without stopping the one before, so the player
+// rewinds a single track on repeat rather than closing and reopening it
+func TestModelRepeatOne(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks int
+	}{
+		{"single track", 1},
+		{"two tracks", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := album(tt.tracks)
+			h := newHarness(t, tracks...)
+			for _, track := range tracks {
+				h.player.setTrack(track, fakeTrack{length: 10 * time.Second, title: filepath.Base(track)})
+			}
+			h.start()
+			h.player.log()
+
+			h.advance(300 * (10*time.Second + tickEndSlack))
+			loads := 0
+			for i, call := range h.player.log() {
+				want := "play "
+				if i%2 == 0 {
+					want = "load "
+					loads++
+				}
+				if !strings.HasPrefix(call, want) {
+					t.Fatalf("call %d is %q, want a %s", i+1, call, strings.TrimSpace(want))
+				}
+			}
+			if loads != 300 {
+				t.Errorf("%d tracks loaded, want 300", loads)
+			}
+		})
+	}
+}
+
+// TestModelWrongLength plays tracks that report the wrong length or none:
+// the time shows "--:--" once the length is known to be wrong, the
+// progress bar has a moving block, and the track plays to its real end
+func TestModelWrongLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		reported time.Duration
+		// what the time shows 15 seconds in
+		time string
+	}{
+		{"right", 20 * time.Second, "00:15 / 00:20"},
+		{"too short", 10 * time.Second, "00:15 / --:--"},
+		{"slightly short", 14 * time.Second, "00:14 / 00:14"},
+		{"too long", time.Minute, "00:15 / 01:00"},
+		{"unknown", 0, "00:15 / --:--"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := album(2)
+			h := newHarness(t, tracks...)
+			h.player.setTrack(tracks[0], fakeTrack{length: 20 * time.Second, reported: tt.reported, lies: true})
+			h.start()
+
+			h.advance(15*time.Second + 500*time.Millisecond)
+			view := h.m.View()
+			if !strings.Contains(view, tt.time) {
+				t.Errorf("time not shown as %q:\n%s", tt.time, view)
+			}
+			bar := h.m.renderProgressBar(40)
+			if moving := strings.Count(bar, glyphs.Filled) == 4 && !strings.HasPrefix(bar, glyphs.Filled); moving != (h.m.duration == 0) {
+				t.Errorf("progress bar %s with length %v", bar, h.m.duration)
+			}
+
+			h.advance(4 * time.Second)
+			if got := h.playing(); got != "01.mp3" {
+				t.Fatalf("on %s before the end of the track, want 01.mp3", got)
+			}
+			h.advance(time.Second)
+			if got := h.playing(); got != "02.mp3" {
+				t.Errorf("on %s after the end of the track, want 02.mp3", got)
+			}
+		})
+	}
+}
+
+// TestModelPauseStopsTicks ends the tick chain on pause, keeping the
+// position, and starts it again on resume
+func TestModelPauseStopsTicks(t *testing.T) {
+	h := newHarness(t, album(2)...).start()
+	h.advance(2500 * time.Millisecond)
+	if h.m.position != 2*time.Second+tickEndSlack {
+		t.Fatalf("position = %v after 2.5 s, want the last tick's %v", h.m.position, 2*time.Second+tickEndSlack)
+	}
+
+	h.press(" ")
+	if !h.m.paused {
+		t.Fatal("space didn't pause")
+	}
+	h.advance(time.Second)
+	if h.pendingTicks() != 0 {
+		t.Fatalf("%d ticks pending while paused, want none", h.pendingTicks())
+	}
+	h.advance(time.Minute)
+	if pos := h.player.GetPosition(); pos != 2500*time.Millisecond {
+		t.Errorf("player position = %v a minute into the pause, want 2.5s", pos)
+	}
+
+	h.press(" ")
+	if h.m.paused || h.pendingTicks() != 1 {
+		t.Fatalf("paused = %v with %d ticks pending after resuming, want one tick", h.m.paused, h.pendingTicks())
+	}
+	h.advance(time.Second)
+	if h.m.position != 3*time.Second+tickEndSlack {
+		t.Errorf("position = %v after resuming, want %v", h.m.position, 3*time.Second+tickEndSlack)
+	}
+}
+
+// TestModelPreviousAtStart goes back a track when previous is pressed at
+// the start of one, through the history and then the playlist order
+func TestModelPreviousAtStart(t *testing.T) {
+	h := newHarness(t, album(3)...).start()
+	h.advance(5 * time.Second)
+	h.press("right")
+	if h.playing() != "02.mp3" {
+		t.Fatalf("next went to %s, want 02.mp3", h.playing())
+	}
+	h.player.log()
+
+	// At 0 previous doesn't restart, it goes back to the track played
+	h.press("left")
+	if h.playing() != "01.mp3" {
+		t.Errorf("previous at 0 went to %s, want 01.mp3 from the history", h.playing())
+	}
+	if got := h.player.log(); !slices.Equal(got, []string{"stop 02.mp3", "load 01.mp3", "play 01.mp3"}) {
+		t.Errorf("player calls = %v, want a stop and the load of 01.mp3", got)
+	}
+
+	// With the history used up it steps back through the playlist
+	h.press("left")
+	if h.playing() != "03.mp3" {
+		t.Errorf("previous with no history went to %s, want 03.mp3 before the first", h.playing())
+	}
+}
+
+// TestModelPreviousRestarts rewinds a track played for longer than the
+// restart threshold instead of going back
+func TestModelPreviousRestarts(t *testing.T) {
+	h := newHarness(t, album(3)...).start()
+	h.press("right")
+	h.advance(10 * time.Second)
+	h.player.log()
+
+	h.press("left")
+	if h.playing() != "02.mp3" || h.m.position != 0 {
+		t.Errorf("on %s at %v, want 02.mp3 restarted", h.playing(), h.m.position)
+	}
+	if got := h.player.log(); !slices.Equal(got, []string{"seek 00:00"}) {
+		t.Errorf("player calls = %v, want a seek to the start", got)
+	}
+}
+
+// TestModelLoadError skips a track that fails to load after skipDelay,
+// with a banner, and gives up once every track failed
+func TestModelLoadError(t *testing.T) {
+	tracks := album(3)
+	h := newHarness(t, tracks...)
+	h.player.setTrack(tracks[1], fakeTrack{loadErr: errFakeDecode})
+	h.start()
+
+	h.press("right")
+	if h.m.playing || !strings.Contains(h.m.banner, "Skipping 02.mp3") {
+		t.Fatalf("playing = %v with banner %q, want the failed load reported", h.m.playing, h.m.banner)
+	}
+	if h.m.failed[tracks[1]] != errFakeDecode {
+		t.Errorf("failed = %v, want the load error recorded", h.m.failed)
+	}
+
+	h.advance(skipDelay - time.Millisecond)
+	if h.playing() != "02.mp3" {
+		t.Fatalf("moved on to %s before skipDelay", h.playing())
+	}
+	h.advance(time.Millisecond)
+	if h.playing() != "03.mp3" || !h.m.playing {
+		t.Errorf("on %s, playing = %v after skipDelay, want 03.mp3 playing", h.playing(), h.m.playing)
+	}
+	if h.m.skipped != 1 || h.m.consecutiveFailures != 0 {
+		t.Errorf("skipped = %d, consecutive failures = %d, want 1 and 0", h.m.skipped, h.m.consecutiveFailures)
+	}
+
+	// Every track failing in a row stops with an error
+	for _, track := range tracks {
+		h.player.setTrack(track, fakeTrack{loadErr: errFakeDecode})
+	}
+	h.press("right")
+	h.advance(time.Minute)
+	if h.m.err == nil || !strings.Contains(h.m.View(), "all 3 tracks failed to load") {
+		t.Errorf("err = %v, want all tracks reported failed", h.m.err)
+	}
+}
+
+// TestModelNote saves the track playing to the notes file with n, once,
+// at the position of the last tick
+func TestModelNote(t *testing.T) {
+	tracks := album(2)
+	h := newHarness(t, tracks...)
+	h.player.setTrack(tracks[0], fakeTrack{length: time.Minute, artist: "Band", title: "Song"})
+	h.start()
+	h.advance(12 * time.Second)
+
+	h.press("n")
+	if h.m.banner != "Saved to notes" {
+		t.Errorf("banner = %q, want the note saved", h.m.banner)
+	}
+	at := h.clock.now
+	h.advance(bannerMinimum)
+	h.press("n")
+	if h.m.banner != "Already noted" {
+		t.Errorf("banner = %q on the second press, want already noted", h.m.banner)
+	}
+
+	data, err := os.ReadFile(h.m.notesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# DirPlay Track Notes\n" + noteEntry("Band -  - Song", 11*time.Second+tickEndSlack, at, tracks[0])
+	if string(data) != want {
+		t.Errorf("notes file holds\n%s\nwant\n%s", data, want)
+	}
+}
+
+// TestModelNoteFails reports a note that couldn't be written and lets it
+// be tried again
+func TestModelNoteFails(t *testing.T) {
+	h := newHarness(t, album(1)...).start()
+	h.m.SetNotesFile(filepath.Join(t.TempDir(), "missing", "notes.md"))
+
+	h.press("n")
+	if !strings.HasPrefix(h.m.banner, "Could not save note") {
+		t.Fatalf("banner = %q, want the write failure", h.m.banner)
+	}
+	if h.m.noted[h.m.currentTrack()] {
+		t.Error("the track counts as noted after the write failed")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		// span is the length the time is shown beside, d itself when 0
+		d, span time.Duration
+		want    string
+	}{
+		{"zero", 0, 0, "00:00"},
+		{"seconds", 59 * time.Second, 0, "00:59"},
+		{"fraction truncated", 59*time.Second + 999*time.Millisecond, 0, "00:59"},
+		{"last minutes style", 59*time.Minute + 59*time.Second, 0, "59:59"},
+		{"an hour", time.Hour, 0, "1:00:00"},
+		{"hour long audiobook", 93*time.Minute + 47*time.Second, 0, "1:33:47"},
+		{"ten hours", 10*time.Hour + 2*time.Minute + 3*time.Second, 0, "10:02:03"},
+		{"a hundred hours", 100 * time.Hour, 0, "100:00:00"},
+		{"negative", -5 * time.Second, 0, "00:00"},
+		{"negative under a second", -time.Millisecond, 0, "00:00"},
+		{"short beside an hour", 7*time.Minute + 12*time.Second, time.Hour + 13*time.Minute + 40*time.Second, "0:07:12"},
+		{"start beside an hour", 0, time.Hour, "0:00:00"},
+		{"negative beside an hour", -time.Second, 2 * time.Hour, "0:00:00"},
+		{"just under an hour span", 7*time.Minute + 12*time.Second, time.Hour - time.Second, "07:12"},
+		{"over its span", time.Hour + time.Second, 30 * time.Minute, "1:00:01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.span == 0 {
+				if got := formatDuration(tt.d); got != tt.want {
+					t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+				}
+				return
+			}
+			if got := formatDurationFor(tt.d, tt.span); got != tt.want {
+				t.Errorf("formatDurationFor(%v, %v) = %q, want %q", tt.d, tt.span, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatLength(t *testing.T) {
+	tests := []struct {
+		length time.Duration
+		want   string
+	}{
+		{0, "--:--"},
+		{-time.Second, "--:--"},
+		{time.Millisecond, "00:00"},
+		{59 * time.Second, "00:59"},
+		{time.Hour + 13*time.Minute + 40*time.Second, "1:13:40"},
+		{10 * time.Hour, "10:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.length.String(), func(t *testing.T) {
+			if got := formatLength(tt.length); got != tt.want {
+				t.Errorf("formatLength(%v) = %q, want %q", tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestModelTimeDisplay shows the time of a track of each kind of length:
+// the position takes the length's style, hours only when either needs them
+func TestModelTimeDisplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		length time.Duration
+		played time.Duration
+		want   string
+	}{
+		{"minutes", 3 * time.Minute, 47 * time.Second, "00:47 / 03:00"},
+		{"hour long", time.Hour + 13*time.Minute + 40*time.Second, 7*time.Minute + 12*time.Second, "0:07:12 / 1:13:40"},
+		{"ten hours", 10 * time.Hour, 5 * time.Second, "0:00:05 / 10:00:00"},
+		{"unknown length", 0, 5 * time.Second, "00:05 / --:--"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(1)...)
+			h.player.setTrack(album(1)[0], fakeTrack{length: tt.length})
+			h.start()
+			// The time shown is the one of the tick just after the second
+			h.advance(tt.played + time.Second/2)
+			h.press(" ")
+			if !strings.Contains(h.m.View(), tt.want) {
+				t.Errorf("view doesn't show %q:\n%s", tt.want, h.m.View())
+			}
+		})
+	}
+}
+
+func TestDisplayPosition(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name               string
+		position, duration time.Duration
+		want               time.Duration
+		// shown is the time display of the clamped position
+		shown string
+	}{
+		{"start", 0, 3 * time.Minute, 0, "00:00 / 03:00"},
+		{"before the start", -300 * ms, 3 * time.Minute, 0, "00:00 / 03:00"},
+		{"inside", 95 * time.Second, 3 * time.Minute, 95 * time.Second, "01:35 / 03:00"},
+		{"just before the end", 3*time.Minute - ms, 3 * time.Minute, 3*time.Minute - ms, "02:59 / 03:00"},
+		{"at the end", 3 * time.Minute, 3 * time.Minute, 3 * time.Minute, "03:00 / 03:00"},
+		{"past the end", 3*time.Minute + 400*ms, 3*time.Minute + 200*ms, 3*time.Minute + 200*ms, "03:00 / 03:00"},
+		{"past the end into a second", 3*time.Minute + 1200*ms, 3*time.Minute + 900*ms, 3*time.Minute + 900*ms, "03:00 / 03:00"},
+		{"past an hour long end", time.Hour + 2*time.Second, time.Hour + 500*ms, time.Hour + 500*ms, "1:00:00 / 1:00:00"},
+		{"unknown length", 95 * time.Second, 0, 95 * time.Second, "01:35 / --:--"},
+		{"unknown length before the start", -time.Second, 0, 0, "00:00 / --:--"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := displayPosition(tt.position, tt.duration); got != tt.want {
+				t.Errorf("displayPosition(%v, %v) = %v, want %v", tt.position, tt.duration, got, tt.want)
+			}
+
+			m := &PlayerModel{position: tt.position, duration: tt.duration, speed: 1, playing: true}
+			if got := m.timeLabel(); got != tt.shown {
+				t.Errorf("time display = %q, want %q", got, tt.shown)
+			}
+			bar := m.renderProgressBar(20)
+			if got := strings.Count(bar, glyphs.Filled) + strings.Count(bar, glyphs.Empty); got > 20 {
+				t.Errorf("progress bar %q is %d cells wide, want at most 20", bar, got)
+			}
+			if left, ok := m.remaining(); ok && (left < 0 || left > tt.duration) {
+				t.Errorf("remaining() = %v, want within the track", left)
+			}
+		})
+	}
+}
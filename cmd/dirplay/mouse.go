@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Each notch of the scroll wheel moves the playlist pane mouseScrollRows
+// rows, or the volume by mouseVolumeStep over the player
+const (
+	mouseScrollRows = 3
+	mouseVolumeStep = 0.05
+)
+
+// mouseAction is what clicking a button does
+type mouseAction int
+
+const (
+	mouseBack mouseAction = iota
+	mousePlayPause
+	mouseNext
+)
+
+// mouseButton is a clickable span of one row, in screen cells
+type mouseButton struct {
+	row, start, end int
+	action          mouseAction
+}
+
+// mouseHits records where the last frame drew what the mouse acts on, so
+// clicks are tested against what is on screen whatever the size or view.
+// View starts each frame with none.
+type mouseHits struct {
+	player   bool // the player view is shown, in full or mini
+	bar      bool
+	barRow   int
+	barStart int // first cell inside the brackets
+	barWidth int
+	buttons  []mouseButton
+}
+
+// EnableMouse makes the player react to the mouse, which the program then
+// has to report. Terminals only allow selecting text with the mouse while
+// it isn't reported, so it stays off with --no-mouse.
+func (m *PlayerModel) EnableMouse() {
+	m.mouse = true
+}
+
+// recordBar notes where a progress bar of width cells, brackets excluded,
+// was drawn with its opening bracket at row and col
+func (m *PlayerModel) recordBar(row, col, width int) {
+	m.hits.bar = true
+	m.hits.barRow = row
+	m.hits.barStart = col + 1
+	m.hits.barWidth = width
+}
+
+// recordButton notes a button drawn at row from col with label, and
+// returns the label
+func (m *PlayerModel) recordButton(row, col int, label string, action mouseAction) string {
+	m.hits.buttons = append(m.hits.buttons, mouseButton{row: row, start: col, end: col + lipgloss.Width(label), action: action})
+	return label
+}
+
+// transport returns the buttons leading the status line with the mouse
+// on: back, the play state and next, recording where they are drawn
+func (m *PlayerModel) transport(row, col int) string {
+	line := m.recordButton(row, col, glyphs.Back, mouseBack) + " "
+	line += m.recordButton(row, col+lipgloss.Width(line), m.stateIcon(), mousePlayPause) + " "
+	line += m.recordButton(row, col+lipgloss.Width(line), glyphs.Forward, mouseNext) + " "
+	return line
+}
+
+// updateMouse handles clicks and the scroll wheel. Clicking the progress
+// bar seeks, the buttons do what their keys do; the wheel scrolls the
+// playlist pane, or changes the volume over the player.
+func (m *PlayerModel) updateMouse(msg tea.MouseMsg) tea.Cmd {
+	if !m.mouse || msg.Action != tea.MouseActionPress {
+		return nil
+	}
+	if m.resetIdle() {
+		return nil
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp, tea.MouseButtonWheelDown:
+		delta := 1
+		if msg.Button == tea.MouseButtonWheelUp {
+			delta = -1
+		}
+		if m.pane.open {
+			m.movePaneCursor(delta * mouseScrollRows)
+			return nil
+		}
+		if m.hits.player {
+			return m.changeVolume(-float64(delta) * mouseVolumeStep)
+		}
+
+	case tea.MouseButtonLeft:
+		hits := m.hits
+		if hits.bar && msg.Y == hits.barRow && msg.X >= hits.barStart && msg.X < hits.barStart+hits.barWidth && m.duration > 0 {
+			share := (float64(msg.X-hits.barStart) + 0.5) / float64(hits.barWidth)
+			target := time.Duration(share * float64(m.duration))
+			return tea.Batch(m.seekTo(target), m.showBanner("Jumped to "+formatDuration(target)))
+		}
+		for _, button := range hits.buttons {
+			if msg.Y != button.row || msg.X < button.start || msg.X >= button.end {
+				continue
+			}
+			switch button.action {
+			case mouseBack:
+				return m.previous()
+			case mousePlayPause:
+				return m.togglePause()
+			case mouseNext:
+				return m.next()
+			}
+		}
+	}
+	return nil
+}
+
+// changeVolume moves the output level by delta, within 0 and 1
+func (m *PlayerModel) changeVolume(delta float64) tea.Cmd {
+	volume := min(max(m.volume+delta, 0), 1)
+	if volume == m.volume {
+		return nil
+	}
+	m.SetVolume(volume)
+	return m.showBanner(fmt.Sprintf("Volume %d%%", int(volume*100+0.5)))
+}
@@ -0,0 +1,258 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// MPRIS names, see https://specifications.freedesktop.org/mpris-spec/latest/
+const (
+	mprisName        = "org.mpris.MediaPlayer2.dirplay"
+	mprisPath        = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	mprisRootIface   = "org.mpris.MediaPlayer2"
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+)
+
+// mprisServer exposes dirplay on the session bus so media keys and desktop
+// widgets can control it. Commands are sent into the bubbletea program,
+// state comes back through Publish and SetPosition.
+type mprisServer struct {
+	mu    sync.Mutex
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// mprisRoot implements the org.mpris.MediaPlayer2 methods
+type mprisRoot struct {
+	send func(tea.Msg)
+}
+
+// Raise is a no-op, a terminal can't be raised
+func (r *mprisRoot) Raise() *dbus.Error {
+	return nil
+}
+
+// Quit quits dirplay
+func (r *mprisRoot) Quit() *dbus.Error {
+	r.send(remoteMsg{action: remoteQuit})
+	return nil
+}
+
+// mprisPlayer implements the org.mpris.MediaPlayer2.Player methods
+type mprisPlayer struct {
+	send func(tea.Msg)
+}
+
+func (p *mprisPlayer) Next() *dbus.Error {
+	p.send(remoteMsg{action: remoteNext})
+	return nil
+}
+
+func (p *mprisPlayer) Previous() *dbus.Error {
+	p.send(remoteMsg{action: remotePrevious})
+	return nil
+}
+
+func (p *mprisPlayer) Pause() *dbus.Error {
+	p.send(remoteMsg{action: remotePause})
+	return nil
+}
+
+func (p *mprisPlayer) PlayPause() *dbus.Error {
+	p.send(remoteMsg{action: remotePlayPause})
+	return nil
+}
+
+func (p *mprisPlayer) Stop() *dbus.Error {
+	p.send(remoteMsg{action: remoteStop})
+	return nil
+}
+
+func (p *mprisPlayer) Play() *dbus.Error {
+	p.send(remoteMsg{action: remotePlay})
+	return nil
+}
+
+// SeekBy implements Seek, moving by offset microseconds. It is exported
+// under the D-Bus name Seek, a Go method named Seek would look like an
+// io.Seeker.
+func (p *mprisPlayer) SeekBy(offset int64) *dbus.Error {
+	p.send(remoteMsg{action: remoteSeek, offset: time.Duration(offset) * time.Microsecond})
+	return nil
+}
+
+// SetPosition moves to position microseconds if track is still current
+func (p *mprisPlayer) SetPosition(track dbus.ObjectPath, position int64) *dbus.Error {
+	var id trackID
+	if _, err := fmt.Sscanf(string(track), "/org/dirplay/track/%d", &id); err != nil {
+		return nil
+	}
+	p.send(remoteMsg{action: remoteSetPosition, offset: time.Duration(position) * time.Microsecond, track: id})
+	return nil
+}
+
+// OpenUri isn't supported, dirplay only plays its own playlist
+func (p *mprisPlayer) OpenUri(uri string) *dbus.Error {
+	return dbus.MakeFailedError(fmt.Errorf("opening URIs is not supported"))
+}
+
+// mprisPlayerMethods maps Go method names to D-Bus ones where they differ
+var mprisPlayerMethods = map[string]string{"SeekBy": "Seek"}
+
+// playerIntrospection lists the player methods under their D-Bus names
+func playerIntrospection(player *mprisPlayer) []introspect.Method {
+	methods := introspect.Methods(player)
+	for i, method := range methods {
+		if name, ok := mprisPlayerMethods[method.Name]; ok {
+			methods[i].Name = name
+		}
+	}
+	return methods
+}
+
+// startMPRIS claims the MPRIS bus name and exports the player. send
+// delivers commands to the program.
+func startMPRIS(send func(tea.Msg)) (*mprisServer, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the session bus: %w", err)
+	}
+
+	reply, err := conn.RequestName(mprisName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("requesting %s: %w", mprisName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("%s is taken, is another dirplay running?", mprisName)
+	}
+
+	root := &mprisRoot{send: send}
+	player := &mprisPlayer{send: send}
+	conn.Export(root, mprisPath, mprisRootIface)
+	conn.ExportWithMap(player, mprisPlayerMethods, mprisPath, mprisPlayerIface)
+
+	props, err := prop.Export(conn, mprisPath, prop.Map{
+		mprisRootIface: {
+			"CanQuit":             {Value: true, Emit: prop.EmitFalse},
+			"CanRaise":            {Value: false, Emit: prop.EmitFalse},
+			"HasTrackList":        {Value: false, Emit: prop.EmitFalse},
+			"Identity":            {Value: "dirplay", Emit: prop.EmitFalse},
+			"SupportedUriSchemes": {Value: []string{}, Emit: prop.EmitFalse},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitFalse},
+		},
+		mprisPlayerIface: {
+			"PlaybackStatus": {Value: "Stopped", Emit: prop.EmitTrue},
+			"Metadata":       {Value: map[string]dbus.Variant{}, Emit: prop.EmitTrue},
+			"Position":       {Value: int64(0), Emit: prop.EmitFalse},
+			"Rate":           {Value: 1.0, Emit: prop.EmitFalse},
+			"MinimumRate":    {Value: 1.0, Emit: prop.EmitFalse},
+			"MaximumRate":    {Value: 1.0, Emit: prop.EmitFalse},
+			"Volume":         {Value: 1.0, Emit: prop.EmitFalse},
+			"CanGoNext":      {Value: true, Emit: prop.EmitFalse},
+			"CanGoPrevious":  {Value: true, Emit: prop.EmitFalse},
+			"CanPlay":        {Value: true, Emit: prop.EmitFalse},
+			"CanPause":       {Value: true, Emit: prop.EmitFalse},
+			"CanSeek":        {Value: true, Emit: prop.EmitFalse},
+			"CanControl":     {Value: true, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("exporting MPRIS properties: %w", err)
+	}
+
+	node := &introspect.Node{
+		Name: string(mprisPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       mprisRootIface,
+				Methods:    introspect.Methods(root),
+				Properties: props.Introspection(mprisRootIface),
+			},
+			{
+				Name:       mprisPlayerIface,
+				Methods:    playerIntrospection(player),
+				Properties: props.Introspection(mprisPlayerIface),
+				Signals: []introspect.Signal{
+					{Name: "Seeked", Args: []introspect.Arg{{Name: "Position", Type: "x"}}},
+				},
+			},
+		},
+	}
+	conn.Export(introspect.NewIntrospectable(node), mprisPath, "org.freedesktop.DBus.Introspectable")
+
+	return &mprisServer{conn: conn, props: props}, nil
+}
+
+// Publish updates the playback status and track metadata, emitting
+// PropertiesChanged so widgets follow along
+func (s *mprisServer) Publish(state nowPlaying) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+
+	metadata := map[string]dbus.Variant{}
+	if state.track != noTrack {
+		metadata["mpris:trackid"] = dbus.MakeVariant(dbus.ObjectPath(fmt.Sprintf("/org/dirplay/track/%d", state.track)))
+		metadata["mpris:length"] = dbus.MakeVariant(state.length.Microseconds())
+		metadata["xesam:url"] = dbus.MakeVariant((&url.URL{Scheme: "file", Path: state.path}).String())
+		metadata["xesam:title"] = dbus.MakeVariant(state.title)
+		metadata["xesam:album"] = dbus.MakeVariant(state.album)
+		if state.artist != "" {
+			metadata["xesam:artist"] = dbus.MakeVariant([]string{state.artist})
+		}
+	}
+
+	s.props.SetMust(mprisPlayerIface, "Metadata", metadata)
+	s.props.SetMust(mprisPlayerIface, "PlaybackStatus", state.status)
+}
+
+// SetPosition updates the Position property, which per the spec is read on
+// demand rather than signalled
+func (s *mprisServer) SetPosition(pos time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.props.SetMust(mprisPlayerIface, "Position", pos.Microseconds())
+	}
+}
+
+// Seeked tells clients the position jumped
+func (s *mprisServer) Seeked(pos time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Emit(mprisPath, mprisPlayerIface+".Seeked", pos.Microseconds())
+	}
+}
+
+// Close releases the bus name and disconnects
+func (s *mprisServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+	s.conn.ReleaseName(mprisName)
+	s.conn.Close()
+	s.conn = nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mprisServer is only implemented on Linux, where MPRIS lives on D-Bus
+type mprisServer struct{}
+
+// startMPRIS always fails outside Linux
+func startMPRIS(send func(tea.Msg)) (*mprisServer, error) {
+	return nil, errors.New("MPRIS is only available on Linux")
+}
+
+func (s *mprisServer) Publish(state nowPlaying)  {}
+func (s *mprisServer) SetPosition(time.Duration) {}
+func (s *mprisServer) Seeked(time.Duration)      {}
+func (s *mprisServer) Close()                    {}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notesEnv names the environment variable that sets the notes file when
+// --notes-file isn't given
+const notesEnv = "DIRPLAY_NOTES"
+
+// resolveNotesFile returns the notes file to write to: the --notes-file
+// flag, then $DIRPLAY_NOTES, then ~/track-notes.md. A leading "~/" is
+// expanded to the home directory.
+func resolveNotesFile(flag string) (string, error) {
+	path := flag
+	if path == "" {
+		path = os.Getenv(notesEnv)
+	}
+	if path == "" {
+		path = "~/track-notes.md"
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not find home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, rest)
+	}
+	return path, nil
+}
+
+// noteDraft is a note being typed after "N": the track and position it
+// was started at
+type noteDraft struct {
+	path     string
+	position time.Duration
+}
+
+// SetNotesFile sets the file "n" and "N" append notes to
+func (m *PlayerModel) SetNotesFile(path string) {
+	m.notesFile = path
+}
+
+// noteText returns what a note says about the current track before any
+// comment, e.g. "Artist - Album - Title"
+func (m *PlayerModel) noteText() string {
+	return fmt.Sprintf("%s - %s - %s", m.artist, m.album, m.title)
+}
+
+// noteEntry formats a note as a Markdown task with the position, the time
+// it was taken and the file, e.g.
+//
+//	[ ] Artist - Album - Title sample at 1:32 (at 02:13, 2026-10-15 21:04) `/music/a.flac`
+func noteEntry(text string, position time.Duration, at time.Time, path string) string {
+	return fmt.Sprintf("[ ] %s (at %s, %s) `%s`\n",
+		text, formatDuration(position), at.Format("2006-01-02 15:04"), path)
+}
+
+// alreadyNoted reports whether the notes file has an entry for path
+func alreadyNoted(notesFile, path string) (bool, error) {
+	file, err := os.Open(notesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	marker := "`" + path + "`"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), marker) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// saveTrackNote appends the current track to the notes file unless it was
+// already noted. The track counts as noted as soon as the write is on its
+// way, so a second press doesn't append it twice, until the write fails.
+func (m *PlayerModel) saveTrackNote() tea.Cmd {
+	path := m.currentTrack()
+	if m.noted[path] {
+		return m.showBanner("Already noted")
+	}
+	m.noted[path] = true
+	return m.saveNote(noteEntry(m.noteText(), m.position, m.now(), path), path, true)
+}
+
+// saveNote appends entry, a note on path, to the notes file in the
+// background. Quitting waits for it, so a note saved just before still
+// lands.
+func (m *PlayerModel) saveNote(entry, path string, once bool) tea.Cmd {
+	notesFile, work := m.notesFile, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		msg := appendNote(notesFile, entry, path, once)
+		if msg.error != "" {
+			work.Failed("save the note on "+path, errors.New(msg.error))
+		}
+		return msg
+	})
+}
+
+// appendNote appends entry, a note on path, to notesFile. With once it is
+// left out when the file has a note on path already.
+func appendNote(notesFile, entry, path string, once bool) noteSavedMsg {
+	if notesFile == "" {
+		return noteSavedMsg{path: path, error: "no notes file configured"}
+	}
+
+	if once {
+		noted, err := alreadyNoted(notesFile, path)
+		if err != nil {
+			return noteSavedMsg{path: path, error: err.Error()}
+		}
+		if noted {
+			return noteSavedMsg{path: path, already: true}
+		}
+	}
+
+	// New files start with a header
+	_, err := os.Stat(notesFile)
+	fileExists := !errors.Is(err, os.ErrNotExist)
+
+	file, err := os.OpenFile(notesFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return noteSavedMsg{path: path, error: err.Error()}
+	}
+	defer file.Close()
+
+	if !fileExists {
+		entry = "# DirPlay Track Notes\n" + entry
+	}
+	if _, err := file.WriteString(entry); err != nil {
+		return noteSavedMsg{path: path, error: err.Error()}
+	}
+
+	return noteSavedMsg{success: true, path: path}
+}
+
+// noteSaved flashes the outcome of saving a note
+func (m *PlayerModel) noteSaved(msg noteSavedMsg) tea.Cmd {
+	switch {
+	case msg.success:
+		m.noted[msg.path] = true
+		return m.showBanner("Saved to notes")
+	case msg.already:
+		m.noted[msg.path] = true
+		return m.showBanner("Already noted")
+	}
+	delete(m.noted, msg.path)
+	return m.showBanner("Could not save note: " + msg.error)
+}
+
+// newCommentInput creates the text input a note with a comment is typed in
+func newCommentInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "Note: "
+	input.CharLimit = 300
+	return input
+}
+
+// openCommentInput starts a note on the current track pre-filled with its
+// artist, album and title, for a comment to be added. The position is
+// taken now rather than once the comment is typed.
+func (m *PlayerModel) openCommentInput() tea.Cmd {
+	if !m.playing {
+		return nil
+	}
+
+	m.noting = &noteDraft{path: m.currentTrack(), position: m.position}
+	m.commentInput.SetValue(m.noteText() + " ")
+	m.commentInput.CursorEnd()
+	return m.commentInput.Focus()
+}
+
+// updateCommentInput handles keys while a note is typed, every other binding
+// being suspended: Enter saves the note, Esc drops it
+func (m *PlayerModel) updateCommentInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.noting = nil
+		m.commentInput.Blur()
+		return m, nil
+
+	case "enter":
+		draft := *m.noting
+		m.noting = nil
+		m.commentInput.Blur()
+
+		text := strings.TrimSpace(m.commentInput.Value())
+		if text == "" {
+			return m, nil
+		}
+		// A comment makes the note worth keeping even if the track has
+		// one already
+		return m, m.saveNote(noteEntry(text, draft.position, m.now(), draft.path), draft.path, false)
+	}
+
+	var cmd tea.Cmd
+	m.commentInput, cmd = m.commentInput.Update(msg)
+	return m, cmd
+}
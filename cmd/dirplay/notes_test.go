@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAppendNote(t *testing.T) {
+	const entry = "[ ] 01 (at 00:05, 2026-01-02 03:04) `/music/01.mp3`\n"
+	tests := []struct {
+		name string
+		// existing is what the notes file holds first, or "-" for no file
+		existing string
+		path     string
+		once     bool
+		want     noteSavedMsg
+		wantFile string
+	}{
+		{"new file", "-", "/music/01.mp3", true, noteSavedMsg{success: true}, "# DirPlay Track Notes\n" + entry},
+		{"appended", "# DirPlay Track Notes\n", "/music/01.mp3", true, noteSavedMsg{success: true}, "# DirPlay Track Notes\n" + entry},
+		{"already noted", "# DirPlay Track Notes\n" + entry, "/music/01.mp3", true, noteSavedMsg{already: true}, "# DirPlay Track Notes\n" + entry},
+		{"again", "# DirPlay Track Notes\n" + entry, "/music/01.mp3", false, noteSavedMsg{success: true}, "# DirPlay Track Notes\n" + entry + entry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notesFile := filepath.Join(t.TempDir(), "notes.md")
+			if tt.existing != "-" {
+				if err := os.WriteFile(notesFile, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.want.path = tt.path
+			if got := appendNote(notesFile, entry, tt.path, tt.once); got != tt.want {
+				t.Errorf("appendNote = %+v, want %+v", got, tt.want)
+			}
+			if data, _ := os.ReadFile(notesFile); string(data) != tt.wantFile {
+				t.Errorf("notes file holds %q, want %q", data, tt.wantFile)
+			}
+		})
+	}
+
+	for _, notesFile := range []string{"", filepath.Join(t.TempDir(), "missing", "notes.md")} {
+		if got := appendNote(notesFile, entry, "/music/01.mp3", true); got.success || got.error == "" {
+			t.Errorf("appendNote to %q = %+v, want an error", notesFile, got)
+		}
+	}
+}
+
+// TestQuitSavesNote notes a track and quits before the note was written:
+// quitting waits for it, and a note that couldn't be written is kept
+// among the write failures
+func TestQuitSavesNote(t *testing.T) {
+	tests := []struct {
+		name string
+		// missing puts the notes file in a directory that isn't there
+		missing bool
+		delay   time.Duration
+	}{
+		{"written at once", false, 0},
+		{"written late", false, 200 * time.Millisecond},
+		{"failed", true, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(2)...)
+			notesFile := filepath.Join(t.TempDir(), "notes.md")
+			if tt.missing {
+				notesFile = filepath.Join(t.TempDir(), "missing", "notes.md")
+			}
+			h.m.SetNotesFile(notesFile)
+			h.start()
+
+			// The note is saved late, as on a slow disk, with quit
+			// already on its way
+			_, note := h.m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+			_, quit := h.m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+			go func() {
+				time.Sleep(tt.delay)
+				runAll(note)
+			}()
+			if !strings.Contains(h.m.View(), "Saving") {
+				t.Errorf("view while quitting = %q, want it saving", h.m.View())
+			}
+			if _, ok := quit().(tea.QuitMsg); !ok {
+				t.Fatal("quit didn't end the program")
+			}
+
+			data, _ := os.ReadFile(notesFile)
+			noted := strings.Contains(string(data), "`"+album(1)[0]+"`")
+			if noted == tt.missing {
+				t.Errorf("notes file holds %q once quit", data)
+			}
+			failures := h.m.WriteFailures()
+			if tt.missing != (len(failures) == 1 && strings.Contains(failures[0].Error(), "could not save the note on "+album(1)[0])) {
+				t.Errorf("write failures = %v", failures)
+			}
+		})
+	}
+}
+
+// runAll runs cmd and the commands of the batches it returns, dropping
+// their messages
+func runAll(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	if batch, ok := cmd().(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			runAll(cmd)
+		}
+	}
+}
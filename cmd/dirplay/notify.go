@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dhowden/tag"
+)
+
+// notifyTimeout bounds a notification, so a hung notification daemon only
+// holds up its own command
+const notifyTimeout = 2 * time.Second
+
+// notice is the desktop notification for a track that started
+type notice struct {
+	title   string
+	body    string
+	picture *tag.Picture
+}
+
+// Notifier shows desktop notifications for --notify. Each OS has its own,
+// see newNotifier.
+type Notifier interface {
+	// Notify shows n, giving up when ctx is done
+	Notify(ctx context.Context, n notice) error
+	Close()
+}
+
+// notifiedMsg reports the outcome of a notification
+type notifiedMsg struct {
+	err error
+}
+
+// EnableNotify announces every track that starts through n
+func (m *PlayerModel) EnableNotify(n Notifier) {
+	m.notifier = n
+}
+
+// notifyTrack announces the track that just started in the background,
+// with its cover if it has one
+func (m *PlayerModel) notifyTrack(picture *tag.Picture) tea.Cmd {
+	if m.notifier == nil {
+		return nil
+	}
+
+	var parts []string
+	for _, part := range []string{m.artist, m.album} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	n := notice{title: m.title, body: strings.Join(parts, " — "), picture: picture}
+	notifier := m.notifier
+	return m.work.Cmd(func(ctx context.Context) tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		defer cancel()
+		return notifiedMsg{err: notifier.Notify(ctx, n)}
+	})
+}
+
+// notified reports the first notification that failed. Later ones are
+// still tried, the daemon may come back, but not reported again.
+func (m *PlayerModel) notified(msg notifiedMsg) tea.Cmd {
+	if msg.err == nil || m.notifyFailed {
+		return nil
+	}
+	m.notifyFailed = true
+	return m.showBanner(fmt.Sprintf("Desktop notification failed: %v", msg.err))
+}
+
+// coverFile writes a cover to the cache directory for a notifier that
+// shows images by path, and returns the path, or "" when there is no
+// cover or it can't be written. Each cover replaces the last one.
+func coverFile(picture *tag.Picture) string {
+	if picture == nil || len(picture.Data) == 0 {
+		return ""
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return ""
+	}
+
+	path := filepath.Join(dir, "cover."+coverExt(picture))
+	if err := os.WriteFile(path, picture.Data, 0644); err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,54 @@
+//go:build darwin
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// macNotifier shows notifications with terminal-notifier when it is
+// installed, which can show the cover, and with osascript otherwise
+type macNotifier struct {
+	terminalNotifier string
+}
+
+// newNotifier looks for terminal-notifier; osascript is always there
+func newNotifier() (Notifier, error) {
+	path, _ := exec.LookPath("terminal-notifier")
+	return &macNotifier{terminalNotifier: path}, nil
+}
+
+// Notify shows n
+func (m *macNotifier) Notify(ctx context.Context, n notice) error {
+	var cmd *exec.Cmd
+	if m.terminalNotifier != "" {
+		// The group makes each notification replace the last
+		args := []string{"-title", n.title, "-message", n.body, "-group", "dirplay"}
+		if path := coverFile(n.picture); path != "" {
+			args = append(args, "-contentImage", path)
+		}
+		cmd = exec.CommandContext(ctx, m.terminalNotifier, args...)
+	} else {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(n.body), appleScriptString(n.title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close does nothing, every notification runs its own command
+func (m *macNotifier) Close() {}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Desktop notification names, see
+// https://specifications.freedesktop.org/notification-spec/latest/
+const (
+	notifyName   = "org.freedesktop.Notifications"
+	notifyPath   = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notifyMethod = "org.freedesktop.Notifications.Notify"
+)
+
+// notifyMarkup escapes the characters notification servers read as markup
+// in the body
+var notifyMarkup = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// dbusNotifier sends notifications to the desktop's notification server
+// over the session bus, the way notify-send does. Each one replaces the
+// last, so skipping through tracks doesn't pile them up.
+type dbusNotifier struct {
+	conn *dbus.Conn
+	mu   sync.Mutex
+	id   uint32
+}
+
+// newNotifier connects to the session bus
+func newNotifier() (Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the session bus: %w", err)
+	}
+	return &dbusNotifier{conn: conn}, nil
+}
+
+// Notify shows n, with the cover as the notification's image
+func (d *dbusNotifier) Notify(ctx context.Context, n notice) error {
+	hints := map[string]dbus.Variant{
+		"category": dbus.MakeVariant("x-dirplay.track"),
+	}
+	if path := coverFile(n.picture); path != "" {
+		hints["image-path"] = dbus.MakeVariant(path)
+	}
+
+	d.mu.Lock()
+	replaces := d.id
+	d.mu.Unlock()
+
+	var id uint32
+	err := d.conn.Object(notifyName, notifyPath).CallWithContext(ctx, notifyMethod, 0,
+		"dirplay", replaces, "audio-x-generic", n.title, notifyMarkup.Replace(n.body),
+		[]string{}, hints, int32(-1)).Store(&id)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.id = id
+	d.mu.Unlock()
+	return nil
+}
+
+// Close disconnects from the session bus
+func (d *dbusNotifier) Close() {
+	d.conn.Close()
+}
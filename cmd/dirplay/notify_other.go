@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package main
+
+import "context"
+
+// silentNotifier stands in where dirplay has no way to notify, e.g. on
+// Windows, so --notify is accepted and does nothing
+type silentNotifier struct{}
+
+// newNotifier returns the silent notifier
+func newNotifier() (Notifier, error) {
+	return silentNotifier{}, nil
+}
+
+func (silentNotifier) Notify(context.Context, notice) error { return nil }
+func (silentNotifier) Close()                               {}
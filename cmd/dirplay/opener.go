@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os/exec"
+	"time"
+)
+
+// openerGrace is how long an opener is watched for failing before it is
+// taken to have worked. Openers hand the folder to the file manager and
+// exit, but one may keep running, and dirplay doesn't wait for it.
+const openerGrace = 2 * time.Second
+
+// startOpener starts an opener with its input and output on the null
+// device, so nothing it prints lands in the TUI, and returns the error it
+// exits with within openerGrace
+func startOpener(cmd *exec.Cmd) error {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, nil, nil
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(openerGrace):
+		return nil
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+// openFolder fails where dirplay doesn't know a file manager
+func openFolder(dir string) error {
+	return errors.New("not supported on this system")
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// openFolder opens dir with open on macOS and xdg-open elsewhere. The
+// opener gets its own session, so the file manager it starts outlives
+// dirplay and the terminal.
+func openFolder(dir string) error {
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+	cmd := exec.Command(name, dir)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return startOpener(cmd)
+}
@@ -0,0 +1,108 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartOpener(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      []string
+		exitCode int
+		// waits is whether it returns only after openerGrace
+		waits bool
+	}{
+		{"opened", []string{"sh", "-c", "echo out; echo err >&2"}, 0, false},
+		{"failed", []string{"sh", "-c", "echo out; echo err >&2; exit 4"}, 4, false},
+		{"still running", []string{"sleep", "10"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := startOpener(exec.Command(tt.cmd[0], tt.cmd[1:]...))
+			var exit *exec.ExitError
+			switch {
+			case tt.exitCode == 0 && err != nil:
+				t.Errorf("startOpener = %v, want it to work", err)
+			case tt.exitCode != 0 && (!errors.As(err, &exit) || exit.ExitCode() != tt.exitCode):
+				t.Errorf("startOpener = %v, want exit status %d", err, tt.exitCode)
+			}
+			if waited := time.Since(start) >= openerGrace; waited != tt.waits {
+				t.Errorf("returned after %v, want waiting for openerGrace %v", time.Since(start), tt.waits)
+			}
+		})
+	}
+
+	if err := startOpener(exec.Command(filepath.Join(t.TempDir(), "missing"))); err == nil {
+		t.Error("startOpener of a missing program worked, want an error")
+	}
+}
+
+// fakeOpener puts an opener on PATH that writes the folder it was asked
+// to open to a file, whose path it returns, and exits with status
+func fakeOpener(t *testing.T, status string) string {
+	t.Helper()
+	bin := t.TempDir()
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+	opened := filepath.Join(bin, "opened")
+	script := "#!/bin/sh\necho opening\necho warning >&2\nprintf %s \"$1\" > '" + opened + "'\nexit " + status + "\n"
+	if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	return opened
+}
+
+// TestModelOpenFolder presses "o" with an opener that works, one that
+// fails and none at all
+func TestModelOpenFolder(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is what the opener exits with, or "" for no opener
+		status string
+		stream bool
+		banner string
+	}{
+		{"opened", "0", false, ""},
+		{"failed", "4", false, "Could not open the folder: exit status 4"},
+		{"no opener", "", false, "Could not open the folder: exec: "},
+		{"stream", "0", true, "The current track isn't a file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "Ünïcode dir")
+			track := filepath.Join(dir, "01.mp3")
+			if tt.stream {
+				track = "http://radio.example/stream"
+			}
+			opened := filepath.Join(t.TempDir(), "opened")
+			if tt.status != "" {
+				opened = fakeOpener(t, tt.status)
+			} else {
+				t.Setenv("PATH", t.TempDir())
+			}
+
+			h := newHarness(t, track).start()
+			h.press("o")
+			if !strings.HasPrefix(h.m.banner, tt.banner) || (tt.banner == "") != (h.m.banner == "") {
+				t.Errorf("banner %q, want %q", h.m.banner, tt.banner)
+			}
+			got, _ := os.ReadFile(opened)
+			if want := dir; tt.status != "" && !tt.stream && string(got) != want {
+				t.Errorf("opener opened %q, want %q", got, want)
+			}
+		})
+	}
+}
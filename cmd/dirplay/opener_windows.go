@@ -0,0 +1,25 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// openFolder opens dir in Explorer, detached from the console so it
+// outlives dirplay
+func openFolder(dir string) error {
+	cmd := exec.Command("explorer", dir)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP}
+
+	// Explorer exits with 1 even when it opened the folder
+	var exit *exec.ExitError
+	if err := startOpener(cmd); err != nil && !errors.As(err, &exit) {
+		return err
+	}
+	return nil
+}
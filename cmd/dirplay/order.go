@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// validateSort checks --sort
+func validateSort(mode string) error {
+	if playlist.ValidateSort(mode) != nil {
+		return fmt.Errorf("invalid --sort %q (want %s, %s, %s or %s)", mode, playlist.SortShuffle, playlist.SortName, playlist.SortMTime, playlist.SortMTimeDesc)
+	}
+	return nil
+}
+
+// ageUnits matches the days and weeks parseAge accepts on top of the
+// units of time.ParseDuration
+var ageUnits = regexp.MustCompile(`([0-9]*\.?[0-9]+)([dw])`)
+
+// parseAge parses --newer-than: a duration as time.ParseDuration takes
+// it, where "d" also stands for days and "w" for weeks, e.g. 30d or 1w12h.
+// "" means no limit.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	hours := ageUnits.ReplaceAllStringFunc(s, func(match string) string {
+		parts := ageUnits.FindStringSubmatch(match)
+		n, _ := strconv.ParseFloat(parts[1], 64)
+		if parts[2] == "w" {
+			n *= 7
+		}
+		return strconv.FormatFloat(n*24, 'f', -1, 64) + "h"
+	})
+	age, err := time.ParseDuration(hours)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid --newer-than %q (want a positive duration, e.g. 30d, 2w or 12h)", s)
+	}
+	return age, nil
+}
+
+// SortPlaylist plays the scanned tracks in the order of a --sort mode
+// other than shuffle. The whole scan is sorted, so playback starts once it
+// is done.
+func (m *PlayerModel) SortPlaylist(mode string) {
+	if mode == playlist.SortShuffle {
+		return
+	}
+	m.sortMode = mode
+	m.keepOrder = true
+}
+
+// sortLabel names the --sort order for the status line, or returns "" when
+// shuffling
+func (m *PlayerModel) sortLabel() string {
+	switch m.sortMode {
+	case playlist.SortName:
+		return "by name"
+	case playlist.SortMTime:
+		return "oldest first"
+	case playlist.SortMTimeDesc:
+		return "newest first"
+	}
+	return ""
+}
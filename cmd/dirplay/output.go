@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// validateOutputRate checks --output-rate
+func validateOutputRate(rate int) error {
+	if rate != 0 && (rate < 8000 || rate > 384000) {
+		return fmt.Errorf("invalid --output-rate %d (want a rate in Hz from 8000 to 384000, e.g. 48000, or 0 to take the first track's)", rate)
+	}
+	return nil
+}
+
+// newDevicesCommand creates the "devices" command, which lists what
+// --device takes
+func newDevicesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "devices",
+		Short: "List the output devices --device can play to",
+		Long: "devices lists the sound devices dirplay can play to with --device: the\n" +
+			"sinks of PulseAudio or PipeWire, or the ALSA cards without a sound server.\n" +
+			"Elsewhere dirplay plays to the system's default device.",
+		Example:      "  dirplay devices\n  dirplay --device alsa_output.usb-FiiO_K3-00.analog-stereo ~/Music",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         runDevices,
+	}
+}
+
+// runDevices prints each device's name and description
+func runDevices(cmd *cobra.Command, args []string) error {
+	devices, err := player.ListDevices()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, d := range devices {
+		fmt.Fprintf(w, "%s\t%s\n", d.Name, d.Description)
+	}
+	return w.Flush()
+}
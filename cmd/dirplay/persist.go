@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Every JSON file dirplay keeps under configDir carries a "version" field.
+// Files from before versioning have none and count as version 0. A format
+// change bumps the file's current version and appends a migration that
+// upgrades a document from the version before.
+
+// migration upgrades a decoded document by one version in place
+type migration func(doc map[string]any) error
+
+// unversioned migrates the files written before the version field was
+// added, which already have the version 1 layout
+func unversioned(doc map[string]any) error {
+	return nil
+}
+
+// errNewerFormat marks files written by a newer dirplay. They are never
+// parsed or overwritten, since fields this version doesn't know would be
+// lost.
+var errNewerFormat = errors.New("written by a newer version of dirplay")
+
+// readVersioned reads the JSON file at path into v, migrating it from an
+// older version first. migrations[i] upgrades version i to i+1, so the
+// current version is len(migrations). Before the first migration of a
+// file a copy is kept as path.v<N>.bak. It returns os.ErrNotExist if the
+// file doesn't exist.
+func readVersioned(path string, migrations []migration, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+
+	current := len(migrations)
+	switch {
+	case header.Version > current:
+		return fmt.Errorf("%s is %w (format version %d, this one reads up to %d); upgrade dirplay or move the file aside",
+			path, errNewerFormat, header.Version, current)
+	case header.Version < current:
+		if data, err = migrate(path, data, header.Version, migrations); err != nil {
+			return fmt.Errorf("upgrading %s from format version %d: %w", path, header.Version, err)
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// migrate backs up an old file and runs the migrations from version on
+func migrate(path string, data []byte, version int, migrations []migration) ([]byte, error) {
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Stat(backup); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(backup, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](doc); err != nil {
+			return nil, err
+		}
+	}
+	doc["version"] = version
+
+	return json.Marshal(doc)
+}
+
+// writeFileAtomic replaces the file at path with data via path.tmp, so a
+// crash leaves either the old or the new version. The data is synced
+// before the rename and the directory after it, so neither is lost to a
+// power cut once it returns.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir syncs the entries of dir to disk. Windows can't open a directory
+// to sync it, and commits a rename to disk itself.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
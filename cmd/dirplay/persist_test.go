@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempConfig points the config and cache directories at a temp
+// directory for the test, on every OS, and returns the config directory
+func useTempConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("AppData", filepath.Join(home, "config"))
+	t.Setenv("LocalAppData", filepath.Join(home, "cache"))
+	dir, err := configDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, content := range []string{`{"version":1}`, `{"version":2,"longer":"than before"}`, `{}`} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("file holds %q, want %q", data, content)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the one written", len(entries))
+	}
+}
+
+func TestWriteFileAtomicFailureKeepsOld(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	if err := writeFileAtomic(path, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	// A directory where the temporary file goes makes the write fail
+	if err := os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err == nil {
+		t.Fatal("writeFileAtomic succeeded without its temporary file")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("file holds %q after a failed write, want the old content", data)
+	}
+}
+
+func TestWriteFileAtomicMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gone", "state.json")
+	if err := writeFileAtomic(path, []byte("data")); err == nil {
+		t.Error("writeFileAtomic succeeded in a directory that doesn't exist")
+	}
+}
+
+// testDoc is a file at version 2 of testMigrations: version 1 renamed
+// "title" to "name", and version 2 added "kind"
+type testDoc struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+}
+
+var testMigrations = []migration{
+	func(doc map[string]any) error {
+		doc["name"] = doc["title"]
+		delete(doc, "title")
+		return nil
+	},
+	func(doc map[string]any) error {
+		if doc["name"] == "broken" {
+			return errors.New("can't upgrade")
+		}
+		doc["kind"] = "track"
+		return nil
+	},
+}
+
+func TestReadVersioned(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		backup  string // already there before the read
+		want    testDoc
+		wantErr error
+		// backups are the files left next to the read one
+		backups map[string]string
+	}{
+		{
+			name:    "unversioned",
+			content: `{"title":"a"}`,
+			want:    testDoc{Version: 2, Name: "a", Kind: "track"},
+			backups: map[string]string{"doc.json.v0.bak": `{"title":"a"}`},
+		},
+		{
+			name:    "one version behind",
+			content: `{"version":1,"name":"a"}`,
+			want:    testDoc{Version: 2, Name: "a", Kind: "track"},
+			backups: map[string]string{"doc.json.v1.bak": `{"version":1,"name":"a"}`},
+		},
+		{
+			name:    "current",
+			content: `{"version":2,"name":"a","kind":"album"}`,
+			want:    testDoc{Version: 2, Name: "a", Kind: "album"},
+		},
+		{
+			name:    "backup kept from the first migration",
+			content: `{"title":"b"}`,
+			backup:  `{"title":"a"}`,
+			want:    testDoc{Version: 2, Name: "b", Kind: "track"},
+			backups: map[string]string{"doc.json.v0.bak": `{"title":"a"}`},
+		},
+		{
+			name:    "newer",
+			content: `{"version":3,"name":"a","colour":"red"}`,
+			wantErr: errNewerFormat,
+		},
+		{
+			name:    "migration fails",
+			content: `{"version":1,"name":"broken"}`,
+			wantErr: errors.New("can't upgrade"),
+			backups: map[string]string{"doc.json.v1.bak": `{"version":1,"name":"broken"}`},
+		},
+		{
+			name:    "not JSON",
+			content: `{"version":`,
+			wantErr: errors.New("unexpected end of JSON input"),
+		},
+		{
+			name:    "missing",
+			wantErr: os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "doc.json")
+			if tt.content != "" {
+				os.WriteFile(path, []byte(tt.content), 0644)
+			}
+			if tt.backup != "" {
+				os.WriteFile(path+".v0.bak", []byte(tt.backup), 0644)
+			}
+
+			var got testDoc
+			err := readVersioned(path, testMigrations, &got)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("readVersioned() = %v", err)
+			case tt.wantErr != nil && (err == nil || !errors.Is(err, tt.wantErr) && !strings.Contains(err.Error(), tt.wantErr.Error())):
+				t.Fatalf("readVersioned() = %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && got != tt.want:
+				t.Errorf("read %+v, want %+v", got, tt.want)
+			}
+
+			// The file itself is only rewritten when saved
+			if data, _ := os.ReadFile(path); string(data) != tt.content {
+				t.Errorf("file holds %s after the read, want %s", data, tt.content)
+			}
+			backups := make(map[string]string)
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				if entry.Name() != "doc.json" {
+					data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+					backups[entry.Name()] = string(data)
+				}
+			}
+			if len(backups) != len(tt.backups) || len(backups) > 0 && !reflect.DeepEqual(backups, tt.backups) {
+				t.Errorf("backups %v, want %v", backups, tt.backups)
+			}
+		})
+	}
+}
+
+// TestMigrateFormats reads each persisted file as dirplay wrote it before
+// the version field, and checks a file from a newer dirplay is refused.
+// A migration added to one of them gets a case here.
+func TestMigrateFormats(t *testing.T) {
+	savedAt := time.Date(2026, 10, 1, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		file    string
+		cache   bool
+		old     string
+		current int
+		load    func() (string, error)
+		want    string
+	}{
+		{
+			name: "state", file: "state.json", current: len(stateMigrations),
+			old: `{"directories":{"/music":{"playlist":["/music/a.mp3","/music/b.mp3","/music/c.mp3","/music/d.mp3"],"current_index":3,"position_ms":61000,"saved_at":"2026-10-01T20:00:00Z"}}}`,
+			load: func() (string, error) {
+				state, err := loadPlaybackState("/music")
+				if err != nil || state == nil {
+					return "", err
+				}
+				return fmt.Sprint(state.CurrentIndex, state.PositionMS, state.SavedAt.Equal(savedAt)), nil
+			},
+			want: "3 61000 true",
+		},
+		{
+			name: "album history", file: "albums.json", current: len(albumMigrations),
+			old: `{"albums":{"/music/a":{"started":4,"completed":2}}}`,
+			load: func() (string, error) {
+				stats, err := loadAlbumStats()
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprint(*stats.Albums["/music/a"]), nil
+			},
+			want: "{4 2 0}",
+		},
+		{
+			name: "prefs", file: "prefs.json", current: len(prefsMigrations),
+			old: `{"shuffle":"album","at_end":"stop","show_info":true}`,
+			load: func() (string, error) {
+				prefs, err := loadSessionPrefs()
+				return fmt.Sprint(prefs.Shuffle, " ", prefs.AtEnd, " ", prefs.ShowInfo), err
+			},
+			want: "album stop true",
+		},
+		{
+			name: "signatures", file: "signatures.json", current: len(signatureMigrations),
+			old: `{"files":{"/music/a.mp3":{"size":10,"mod_time":"2026-10-01T20:00:00Z","bits":[1,2]}}}`,
+			load: func() (string, error) {
+				cache, err := loadSignatureCache(nil)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprint(cache.Files["/music/a.mp3"].Size, cache.Files["/music/a.mp3"].Bits), nil
+			},
+			want: "10 [1 2]",
+		},
+		{
+			name: "tag index", file: "tags.json", cache: true, current: len(tagIndexMigrations),
+			old: `{"files":{"/music/a.mp3":{"size":10,"mod_time":"2026-10-01T20:00:00Z","title":"Song"}}}`,
+			load: func() (string, error) {
+				index, err := loadTagIndex()
+				if err != nil {
+					return "", err
+				}
+				// Indexes from before disc numbers are read again
+				return fmt.Sprint(len(index.Files)), nil
+			},
+			want: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, version := range []int{0, tt.current + 1} {
+				dir := useTempConfig(t)
+				if tt.cache {
+					var err error
+					if dir, err = cacheDir(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				path := filepath.Join(dir, tt.file)
+				content := tt.old
+				if version > 0 {
+					content = fmt.Sprintf(`{"version":%d}`, version)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := tt.load()
+				if version > 0 {
+					if !errors.Is(err, errNewerFormat) {
+						t.Errorf("loading version %d = %v, want %v", version, err, errNewerFormat)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Errorf("read %s, want %s", got, tt.want)
+				}
+				if data, err := os.ReadFile(path + ".v0.bak"); err != nil || string(data) != tt.old {
+					t.Errorf("backup holds %s (%v), want the old file", data, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// fakeTrack is what the fake player knows of a file. It plays for length,
+// but reports reported as its length when lies is set, as some decoders
+// do.
+type fakeTrack struct {
+	length   time.Duration
+	artist   string
+	title    string
+	album    string
+	picture  *tag.Picture
+	loadErr  error
+	reported time.Duration
+	lies     bool
+	info     player.TrackInfo
+}
+
+// fakePlayer is a Player that plays nothing. Time passes for it on its
+// clock: a track that plays is at the position the clock says, and ends
+// once that passes its length. Files it isn't told about load as 3 minute
+// tracks titled after their name. It keeps a log of the calls that change
+// what plays, for tests to check.
+type fakePlayer struct {
+	mu    sync.Mutex
+	clock *fakeClock
+
+	tracks map[string]fakeTrack
+	calls  []string
+
+	loaded  string
+	track   fakeTrack
+	playing bool
+	paused  bool
+	// offset is the position when the clock last read resumedAt
+	offset    time.Duration
+	resumedAt time.Time
+
+	preloaded string
+	gain      float64
+	meter     player.LevelMeter
+	crashes   chan player.Crash
+	news      chan player.StreamNews
+}
+
+var _ player.Player = (*fakePlayer)(nil)
+
+// fakeTrackLength is the length of tracks the fake player isn't told about
+const fakeTrackLength = 3 * time.Minute
+
+func newFakePlayer(clock *fakeClock) *fakePlayer {
+	return &fakePlayer{
+		clock:   clock,
+		tracks:  make(map[string]fakeTrack),
+		gain:    1,
+		crashes: make(chan player.Crash, 1),
+		news:    make(chan player.StreamNews, 1),
+	}
+}
+
+// errFakeDecode is the load error of tracks set to fail
+var errFakeDecode = errors.New("failed to decode audio: bad header")
+
+// setTrack tells the player about a file
+func (p *fakePlayer) setTrack(path string, track fakeTrack) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracks[path] = track
+}
+
+// log returns the calls made so far, e.g. "load a.mp3", and forgets them
+func (p *fakePlayer) log() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := p.calls
+	p.calls = nil
+	return calls
+}
+
+// record logs a call, the caller must hold p.mu
+func (p *fakePlayer) record(call string, path string) {
+	if path != "" {
+		call += " " + filepath.Base(path)
+	}
+	p.calls = append(p.calls, call)
+}
+
+// position returns where playback is, the caller must hold p.mu
+func (p *fakePlayer) position() time.Duration {
+	pos := p.offset
+	if p.playing && !p.paused {
+		pos += p.clock.Now().Sub(p.resumedAt)
+	}
+	if p.track.length > 0 {
+		pos = min(pos, p.track.length)
+	}
+	return pos
+}
+
+func (p *fakePlayer) LoadTrack(filePath string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.record("load", filePath)
+	track, ok := p.tracks[filePath]
+	if !ok {
+		name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		track = fakeTrack{length: fakeTrackLength, artist: "Artist", title: name}
+	}
+	if track.loadErr != nil {
+		return track.loadErr
+	}
+	p.loaded, p.track = filePath, track
+	p.playing, p.paused, p.offset = false, false, 0
+	return nil
+}
+
+func (p *fakePlayer) Preload(filePath string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.preloaded = filePath
+	return nil
+}
+
+func (p *fakePlayer) TookOverNext() string { return "" }
+
+func (p *fakePlayer) Play() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record("play", p.loaded)
+	p.playing, p.paused, p.resumedAt = true, false, p.clock.Now()
+	return nil
+}
+
+func (p *fakePlayer) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record("pause", "")
+	p.offset = p.position()
+	p.paused = true
+}
+
+func (p *fakePlayer) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record("resume", "")
+	p.paused, p.resumedAt = false, p.clock.Now()
+}
+
+func (p *fakePlayer) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded != "" {
+		p.record("stop", p.loaded)
+	}
+	p.loaded, p.playing, p.paused, p.offset = "", false, false, 0
+}
+
+func (p *fakePlayer) Close() {}
+
+func (p *fakePlayer) Seek(pos time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded == "" {
+		return errors.New("nothing loaded")
+	}
+	p.record("seek "+formatDuration(pos), "")
+	p.offset, p.resumedAt = pos, p.clock.Now()
+	return nil
+}
+
+func (p *fakePlayer) SetLoop(from, until time.Duration) {}
+
+func (p *fakePlayer) GetPosition() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+func (p *fakePlayer) GetDuration() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.track.lies {
+		return p.track.reported
+	}
+	return p.track.length
+}
+
+func (p *fakePlayer) HasEnded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.playing && p.track.length > 0 && p.position() >= p.track.length
+}
+
+func (p *fakePlayer) Err() error                   { return nil }
+func (p *fakePlayer) Crashes() <-chan player.Crash { return p.crashes }
+func (p *fakePlayer) GetFormat() beep.Format {
+	return beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}
+}
+func (p *fakePlayer) Buffering() bool                      { return false }
+func (p *fakePlayer) StreamNews() <-chan player.StreamNews { return p.news }
+
+func (p *fakePlayer) SetGain(gain float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gain = gain
+}
+
+func (p *fakePlayer) SetSpeed(speed float64)                     {}
+func (p *fakePlayer) SetReplayGain(mode string)                  {}
+func (p *fakePlayer) SetSkipSilence(config player.SilenceConfig) {}
+func (p *fakePlayer) SetEQ(gains player.EQGains)                 {}
+func (p *fakePlayer) SetFade(fade time.Duration)                 {}
+func (p *fakePlayer) Meter() *player.LevelMeter                  { return &p.meter }
+func (p *fakePlayer) SetTee(tee player.Tee)                      {}
+func (p *fakePlayer) OutputRate() beep.SampleRate                { return 44100 }
+func (p *fakePlayer) RestartOutput() error                       { return nil }
+
+func (p *fakePlayer) GetArtist() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.track.artist
+}
+
+func (p *fakePlayer) GetTitle() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.track.title
+}
+
+func (p *fakePlayer) GetAlbum() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.track.album
+}
+
+func (p *fakePlayer) GetAlbumArtist() string { return "" }
+func (p *fakePlayer) GetSortArtist() string  { return "" }
+
+func (p *fakePlayer) GetPicture() *tag.Picture {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.track.picture
+}
+func (p *fakePlayer) GetInfo() player.TrackInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.track.info
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// paneSort is the order the playlist pane lists tracks in
+type paneSort int
+
+const (
+	sortPlaylist paneSort = iota
+	sortPath
+	sortArtist
+)
+
+// String returns the name of the sort order shown in the pane header
+func (s paneSort) String() string {
+	switch s {
+	case sortPath:
+		return "path"
+	case sortArtist:
+		return "artist"
+	default:
+		return "playlist order"
+	}
+}
+
+// playlistPane is the browsable list of playlist entries opened with "l".
+// rows holds the tracks in display order.
+type playlistPane struct {
+	open   bool
+	sort   paneSort
+	cursor int
+	offset int
+	rows   []trackID
+}
+
+// openPlaylistPane shows the pane with the cursor on the current track
+func (m *PlayerModel) openPlaylistPane() {
+	m.pane.open = true
+	m.rebuildPane()
+	for i, id := range m.pane.rows {
+		if id == m.current {
+			m.pane.cursor = i
+		}
+	}
+}
+
+// rebuildPane recomputes the row order, keeping the cursor on the same
+// playlist entry
+func (m *PlayerModel) rebuildPane() {
+	selected := noTrack
+	if m.pane.cursor >= 0 && m.pane.cursor < len(m.pane.rows) {
+		selected = m.pane.rows[m.pane.cursor]
+	}
+
+	rows := make([]trackID, len(m.playlist))
+	copy(rows, m.playlist)
+
+	switch m.pane.sort {
+	case sortPath:
+		sort.SliceStable(rows, func(a, b int) bool {
+			return strings.ToLower(m.tracks.Path(rows[a])) < strings.ToLower(m.tracks.Path(rows[b]))
+		})
+	case sortArtist:
+		keys := make(map[trackID]string, len(rows))
+		for _, id := range rows {
+			keys[id] = m.artistSortKey(m.tracks.Path(id))
+		}
+		sort.SliceStable(rows, func(a, b int) bool {
+			return keys[rows[a]] < keys[rows[b]]
+		})
+	}
+
+	// Copies left out of the playlist follow the one kept
+	if alternates := m.alternates(); len(alternates) > 0 {
+		withAlternates := make([]trackID, 0, len(rows)+len(alternates))
+		for _, id := range rows {
+			withAlternates = append(withAlternates, id)
+			withAlternates = append(withAlternates, alternates[id]...)
+		}
+		rows = withAlternates
+	}
+
+	m.pane.rows = rows
+	m.pane.cursor = 0
+	for i, id := range rows {
+		if id == selected {
+			m.pane.cursor = i
+		}
+	}
+}
+
+// artistSortKey returns the key a track sorts under by artist. Tracks
+// whose tags haven't been read use the grandparent directory, which is the
+// artist in the common Artist/Album/Track layout.
+func (m *PlayerModel) artistSortKey(path string) string {
+	tags, ok := m.knownTags[path]
+	if !ok {
+		return library.SortKey(filepath.Base(filepath.Dir(filepath.Dir(path))), "")
+	}
+
+	artist := tags.albumArtist
+	if artist == "" {
+		artist = tags.artist
+	}
+	return library.SortKey(artist, tags.sortArtist)
+}
+
+// updatePlaylistPane handles keys while the pane has focus
+func (m *PlayerModel) updatePlaylistPane(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := m.paneHeight()
+
+	// Take the selected track out of rotation, see blockTrack
+	if key.Matches(msg, m.keys.Block) {
+		if len(m.pane.rows) == 0 {
+			return m, m.blockTrack(noTrack)
+		}
+		return m, m.blockTrack(m.pane.rows[m.pane.cursor])
+	}
+
+	switch key := msg.String(); key {
+	case "esc":
+		m.pane.open = false
+	case "up":
+		m.movePaneCursor(-1)
+	case "down":
+		m.movePaneCursor(1)
+	case "pgup":
+		m.movePaneCursor(-page)
+	case "pgdown":
+		m.movePaneCursor(page)
+	case "home":
+		m.movePaneCursor(-len(m.pane.rows))
+	case "end":
+		m.movePaneCursor(len(m.pane.rows))
+	case "tab":
+		m.pane.sort = (m.pane.sort + 1) % 3
+		m.rebuildPane()
+	case "enter":
+		if len(m.pane.rows) == 0 {
+			return m, nil
+		}
+		id := m.pane.rows[m.pane.cursor]
+		index := m.indexOf(id)
+		if m.isAlternate(id) {
+			// Play a hidden copy in place of the one kept
+			index = m.keepAlternate(id)
+		}
+		if index < 0 {
+			return m, nil
+		}
+		m.pane.open = false
+		m.recordUndo()
+		m.player.Stop()
+		m.leaveTrack()
+		m.queue.Leave()
+		m.currentIndex = index
+		return m, m.loadCurrentTrack()
+	case "a":
+		// Queue the track to play next, or take it off the queue
+		if len(m.pane.rows) == 0 {
+			return m, nil
+		}
+		banner := m.showBanner(m.toggleQueued(m.pane.rows[m.pane.cursor]))
+		if m.playing {
+			return m, tea.Batch(banner, m.preloadNext())
+		}
+		return m, banner
+	default:
+		// Letters jump alphabetically, but only when sorted by artist
+		if m.pane.sort == sortArtist && utf8.RuneCountInString(key) == 1 {
+			m.jumpToInitial([]rune(key)[0])
+		}
+	}
+
+	return m, nil
+}
+
+// movePaneCursor moves the cursor by delta rows, clamped to the list
+func (m *PlayerModel) movePaneCursor(delta int) {
+	m.pane.cursor += delta
+	if m.pane.cursor >= len(m.pane.rows) {
+		m.pane.cursor = len(m.pane.rows) - 1
+	}
+	if m.pane.cursor < 0 {
+		m.pane.cursor = 0
+	}
+}
+
+// jumpToInitial moves the cursor to the first entry whose artist sort key
+// begins with letter
+func (m *PlayerModel) jumpToInitial(letter rune) {
+	want := library.Initial(string(letter))
+	if want == 0 {
+		return
+	}
+
+	for i, id := range m.pane.rows {
+		if library.Initial(m.artistSortKey(m.tracks.Path(id))) == want {
+			m.pane.cursor = i
+			return
+		}
+	}
+}
+
+// paneHeight returns how many entries fit on screen
+func (m *PlayerModel) paneHeight() int {
+	if m.height <= 0 {
+		return 20
+	}
+	return max(m.height-4, 3)
+}
+
+// paneEntryName returns how a playlist entry is listed in the pane
+func (m *PlayerModel) paneEntryName(path string) string {
+	if tags, ok := m.knownTags[path]; ok && tags.title != "" {
+		if tags.artist != "" {
+			return tags.artist + " - " + tags.title
+		}
+		return tags.title
+	}
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// viewPlaylistPane renders the pane in place of the player view
+func (m *PlayerModel) viewPlaylistPane() string {
+	headerStyle, rowStyle, dimStyle, cursorStyle := m.styles.Header, m.styles.Text, m.styles.Dim, m.styles.Cursor
+
+	// Keep the cursor inside the visible window
+	height := m.paneHeight()
+	if m.pane.cursor < m.pane.offset {
+		m.pane.offset = m.pane.cursor
+	}
+	if m.pane.cursor >= m.pane.offset+height {
+		m.pane.offset = m.pane.cursor - height + 1
+	}
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render(fitText(fmt.Sprintf("Playlist · %d tracks · sorted by %s", len(m.playlist), m.pane.sort), m.viewWidth())))
+	content.WriteString("\n")
+
+	// Playlist positions, shown next to each entry
+	positions := make(map[trackID]int, len(m.playlist))
+	for i, id := range m.playlist {
+		positions[id] = i + 1
+	}
+
+	end := min(m.pane.offset+height, len(m.pane.rows))
+	for i := m.pane.offset; i < end; i++ {
+		id := m.pane.rows[i]
+		path := m.tracks.Path(id)
+		marker := "  "
+		if id == m.current {
+			marker = glyphs.Play + " "
+		}
+
+		// The name gives way so the markers stay visible
+		prefix := fmt.Sprintf("%s%4d  ", marker, positions[id])
+		alternate := m.isAlternate(id)
+		if alternate {
+			prefix = marker + "      "
+		}
+		var suffix string
+		if alternate {
+			suffix = "  (duplicate)"
+		}
+		if n := m.queuedAt(id); n > 0 {
+			suffix = fmt.Sprintf("  (queued #%d)", n)
+		}
+		if m.failed[path] != nil && i != m.pane.cursor {
+			suffix += "  (failed)"
+		}
+		name := m.paneEntryName(path)
+		if alternate {
+			// Copies share their tags, the file tells them apart
+			name = filepath.Base(path)
+		}
+		name = fitText(name, m.viewWidth()-lipgloss.Width(prefix+suffix))
+		line := prefix + name + suffix
+		switch {
+		case i == m.pane.cursor:
+			content.WriteString(cursorStyle.Render(line))
+		case m.failed[path] != nil, alternate:
+			content.WriteString(dimStyle.Render(line))
+		default:
+			content.WriteString(rowStyle.Render(line))
+		}
+		content.WriteString("\n")
+	}
+
+	help := "[↑↓] Move  [ENTER] Play  [A] Queue  [TAB] Sort  [ESC] Close"
+	if m.pane.sort == sortArtist {
+		help += "  [A-Z] Jump"
+	}
+	content.WriteString(dimStyle.Render(fitText(help, m.viewWidth())))
+
+	return content.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/pflag"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// sessionPrefs remembers runtime toggles between sessions in prefs.json,
+// apart from playback state since these follow the listener rather than a
+// music directory. Options given on the command line win over saved ones
+// and are remembered in turn.
+type sessionPrefs struct {
+	mu        sync.Mutex
+	path      string
+	Version   int       `json:"version"`
+	Shuffle   string    `json:"shuffle,omitempty"`
+	AtEnd     string    `json:"at_end,omitempty"`
+	ShowInfo  bool      `json:"show_info"`
+	ShowMeter bool      `json:"show_meter,omitempty"`
+	ShowETA   bool      `json:"show_eta,omitempty"`
+	EQ        []float64 `json:"eq,omitempty"`
+	Mini      bool      `json:"mini,omitempty"`
+}
+
+// prefsMigrations upgrade older prefs files, see readVersioned
+var prefsMigrations = []migration{unversioned}
+
+// loadSessionPrefs reads the saved toggles. On error the returned prefs
+// are empty but can still be saved.
+func loadSessionPrefs() (*sessionPrefs, error) {
+	dir, err := configDir()
+	if err != nil {
+		return &sessionPrefs{}, err
+	}
+
+	prefs := &sessionPrefs{path: filepath.Join(dir, "prefs.json")}
+
+	err = readVersioned(prefs.path, prefsMigrations, prefs)
+	if errors.Is(err, os.ErrNotExist) {
+		return prefs, nil
+	}
+	if err != nil {
+		return &sessionPrefs{path: prefs.path}, err
+	}
+	return prefs, nil
+}
+
+// restore applies saved toggles to options not set on the command line,
+// then records the options in effect
+func (p *sessionPrefs) restore(flags *pflag.FlagSet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !flags.Changed("shuffle") && p.Shuffle != "" {
+		shuffleMode = p.Shuffle
+	}
+	if !flags.Changed("at-end") && p.AtEnd != "" {
+		atEnd = p.AtEnd
+	}
+
+	p.Shuffle = shuffleMode
+	p.AtEnd = atEnd
+}
+
+// Save writes the toggles to disk via a temporary file
+func (p *sessionPrefs) Save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.path == "" {
+		return nil
+	}
+
+	p.Version = len(prefsMigrations)
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(p.path, data)
+}
+
+// EnablePrefs restores the UI toggles and remembers later changes
+func (m *PlayerModel) EnablePrefs(prefs *sessionPrefs) {
+	m.prefs = prefs
+	m.showInfo = prefs.ShowInfo
+	m.setMeter(prefs.ShowMeter)
+	m.showETA = prefs.ShowETA
+	if len(prefs.EQ) == player.EQBands {
+		m.EnableEQ(player.EQGains(prefs.EQ))
+	}
+	m.mini = prefs.Mini
+}
+
+// savePrefs records the current toggles in the background
+func (m *PlayerModel) savePrefs() tea.Cmd {
+	if m.prefs == nil {
+		return nil
+	}
+
+	prefs := m.prefs
+	prefs.mu.Lock()
+	prefs.ShowInfo = m.showInfo
+	prefs.ShowMeter = m.showMeter
+	prefs.ShowETA = m.showETA
+	prefs.EQ = nil
+	if m.eq != (player.EQGains{}) {
+		prefs.EQ = slices.Clone(m.eq[:])
+	}
+	prefs.Mini = m.mini
+	prefs.mu.Unlock()
+
+	work := m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		if err := prefs.Save(); err != nil {
+			work.Failed("save display preferences", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSessionPrefsRoundTrip saves prefs and loads them back as the next
+// session does
+func TestSessionPrefsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(p *sessionPrefs)
+	}{
+		{"empty", func(p *sessionPrefs) {}},
+		{"modes", func(p *sessionPrefs) { p.Shuffle, p.AtEnd = "album", "stop" }},
+		{"toggles", func(p *sessionPrefs) { p.ShowInfo, p.ShowMeter, p.ShowETA, p.Mini = true, true, true, true }},
+		{"eq", func(p *sessionPrefs) { p.Shuffle, p.EQ = "track", []float64{-3, 0, 4.5} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useTempConfig(t)
+
+			// Without a file the prefs start empty, ready to be saved
+			prefs, err := loadSessionPrefs()
+			if err != nil {
+				t.Fatalf("loading without a file = %v", err)
+			}
+			if want := filepath.Join(dir, "prefs.json"); prefs.path != want {
+				t.Fatalf("prefs go to %s, want %s", prefs.path, want)
+			}
+
+			tt.set(prefs)
+			if err := prefs.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(prefs.path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("the temporary file is left behind (%v)", err)
+			}
+
+			loaded, err := loadSessionPrefs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := json.Marshal(prefs)
+			got, _ := json.Marshal(loaded)
+			if string(got) != string(want) || loaded.path != prefs.path {
+				t.Errorf("loaded %s from %s, want %s from %s", got, loaded.path, want, prefs.path)
+			}
+		})
+	}
+}
+
+// TestSessionPrefsNewer loads prefs a newer dirplay saved: they are
+// refused and the file is left alone
+func TestSessionPrefsNewer(t *testing.T) {
+	dir := useTempConfig(t)
+	path := filepath.Join(dir, "prefs.json")
+	content := fmt.Sprintf(`{"version":%d,"shuffle":"album","show_info":true}`, len(prefsMigrations)+1)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prefs, err := loadSessionPrefs()
+	if !errors.Is(err, errNewerFormat) {
+		t.Errorf("loading = %v, want %v", err, errNewerFormat)
+	}
+	if prefs.Shuffle != "" || prefs.ShowInfo {
+		t.Errorf("loaded shuffle %q and show info %v from a newer file, want none", prefs.Shuffle, prefs.ShowInfo)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("file holds %s after loading, want %s", data, content)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// soundPreset is a [[preset]] of config.toml: a named bundle of sound
+// settings, switched to with "P". Settings it leaves out keep their value.
+type soundPreset struct {
+	Name       string    `toml:"name"`
+	Volume     *float64  `toml:"volume"`
+	EQ         []float64 `toml:"eq"`
+	ReplayGain string    `toml:"replaygain"`
+	Speed      *float64  `toml:"speed"`
+}
+
+// validate checks a preset's settings, returning what is wrong with the
+// first one that is out of range
+func (p soundPreset) validate() error {
+	if p.Volume != nil && (*p.Volume < 0 || *p.Volume > 1) {
+		return fmt.Errorf("volume: want a level from 0 to 1")
+	}
+	if p.EQ != nil {
+		if len(p.EQ) != player.EQBands {
+			return fmt.Errorf("eq: want %d gains, for bass, mid and treble", player.EQBands)
+		}
+		for _, gain := range p.EQ {
+			if gain < -eqMaxGain || gain > eqMaxGain {
+				return fmt.Errorf("eq: want gains from %g to %+g dB", -eqMaxGain, eqMaxGain)
+			}
+		}
+	}
+	if p.ReplayGain != "" {
+		if err := validateReplayGain(p.ReplayGain); err != nil {
+			return fmt.Errorf("replaygain: want %s, %s or %s", player.ReplayGainOff, player.ReplayGainTrack, player.ReplayGainAlbum)
+		}
+	}
+	if p.Speed != nil && (*p.Speed < player.MinSpeed || *p.Speed > player.MaxSpeed) {
+		return fmt.Errorf("speed: want a speed from %g to %g", player.MinSpeed, player.MaxSpeed)
+	}
+	return nil
+}
+
+// eqGains returns the preset's equalizer gains
+func (p soundPreset) eqGains() player.EQGains {
+	var gains player.EQGains
+	copy(gains[:], p.EQ)
+	return gains
+}
+
+// EnablePresets makes presets available to switch between with "P"
+func (m *PlayerModel) EnablePresets(presets []soundPreset) {
+	m.presets = presets
+	m.preset = -1
+}
+
+// presetIndex returns the index of the preset called name, or -1
+func (m *PlayerModel) presetIndex(name string) int {
+	return slices.IndexFunc(m.presets, func(p soundPreset) bool { return p.Name == name })
+}
+
+// SelectPreset switches to the preset called name, e.g. the one given
+// with --preset
+func (m *PlayerModel) SelectPreset(name string) error {
+	i := m.presetIndex(name)
+	if i < 0 {
+		return fmt.Errorf("no preset %q in the config file", name)
+	}
+	m.applyPreset(i)
+	return nil
+}
+
+// cyclePreset switches to the preset after the current one, or the first
+func (m *PlayerModel) cyclePreset() tea.Cmd {
+	if len(m.presets) == 0 {
+		return m.showBanner("No presets in config.toml")
+	}
+	cmd := m.applyPreset((m.preset + 1) % len(m.presets))
+	return tea.Batch(cmd, m.showBanner("Preset "+m.presets[m.preset].Name))
+}
+
+// applyPreset changes the settings preset i names, on the fly. ReplayGain
+// applies from the next track.
+func (m *PlayerModel) applyPreset(i int) tea.Cmd {
+	m.preset = i
+	preset := m.presets[i]
+
+	var cmd tea.Cmd
+	if preset.Volume != nil {
+		m.SetVolume(*preset.Volume)
+	}
+	if preset.EQ != nil {
+		cmd = m.setEQ(preset.eqGains())
+	}
+	if preset.ReplayGain != "" {
+		m.EnableReplayGain(preset.ReplayGain)
+	}
+	if preset.Speed != nil {
+		m.speed = *preset.Speed
+		m.player.SetSpeed(m.speed)
+	}
+	return cmd
+}
+
+// presetChanged reports whether a setting of the current preset was
+// changed since switching to it
+func (m *PlayerModel) presetChanged() bool {
+	preset := m.presets[m.preset]
+	return preset.Volume != nil && *preset.Volume != m.volume ||
+		preset.EQ != nil && preset.eqGains() != m.eq ||
+		preset.ReplayGain != "" && preset.ReplayGain != m.replayGain ||
+		preset.Speed != nil && *preset.Speed != m.speed
+}
+
+// presetLabel returns the current preset for the header, marked when its
+// settings were changed since, or "" before switching to one
+func (m *PlayerModel) presetLabel() string {
+	if m.preset < 0 {
+		return ""
+	}
+	label := "preset " + m.presets[m.preset].Name
+	if m.presetChanged() {
+		label += " (changed)"
+	}
+	return label
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+func TestLoadPresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    []string
+		wantErr string
+	}{
+		{"none", "", nil, ""},
+		{"two", "[[preset]]\nname = \"night\"\nvolume = 0.4\neq = [-3, 0, -2]\nreplaygain = \"album\"\n\n[[preset]]\nname = \"day\"\nspeed = 1.25\n", []string{"night", "day"}, ""},
+		{"only a name", "[[preset]]\nname = \"as is\"\n", []string{"as is"}, ""},
+		{"no name", "[[preset]]\nvolume = 0.5\n", nil, "needs a name"},
+		{"twice", "[[preset]]\nname = \"a\"\n[[preset]]\nname = \"a\"\n", nil, "defined twice"},
+		{"loud", "[[preset]]\nname = \"a\"\nvolume = 1.5\n", nil, `preset "a": volume`},
+		{"two bands", "[[preset]]\nname = \"a\"\neq = [1, 2]\n", nil, "eq: want 3 gains"},
+		{"gain too high", "[[preset]]\nname = \"a\"\neq = [0, 13, 0]\n", nil, "eq: want gains"},
+		{"replaygain", "[[preset]]\nname = \"a\"\nreplaygain = \"loud\"\n", nil, "replaygain"},
+		{"fast", "[[preset]]\nname = \"a\"\nspeed = 4.0\n", nil, "speed"},
+		{"unknown", "[[preset]]\nname = \"a\"\ncrossfeed = true\n", nil, "unknown setting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := loadConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadConfig error = %v, want one about %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, preset := range cfg.Presets {
+				names = append(names, preset.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("presets = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+// TestDefaultConfigPresets uncomments the presets of the template, which
+// must load
+func TestDefaultConfigPresets(t *testing.T) {
+	_, example, _ := strings.Cut(defaultConfig, "# [[preset]]")
+	example = strings.ReplaceAll("# [[preset]]"+example, "# ", "")
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(example), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig of the template's presets: %v", err)
+	}
+	if len(cfg.Presets) != 2 {
+		t.Errorf("template has %d presets, want 2", len(cfg.Presets))
+	}
+}
+
+// TestModelPresets switches presets with P while a track plays: each sets
+// what it names and leaves the rest, and the header names it until a
+// setting is changed by hand
+func TestModelPresets(t *testing.T) {
+	quiet, slow := 0.4, 0.75
+	presets := []soundPreset{
+		{Name: "night", Volume: &quiet, EQ: []float64{-3, 0, -2}, ReplayGain: player.ReplayGainAlbum},
+		{Name: "podcast", Speed: &slow},
+	}
+	type settings struct {
+		volume     float64
+		eq         player.EQGains
+		replayGain string
+		speed      float64
+	}
+	tests := []struct {
+		name string
+		// keys are pressed in turn, with change run after them when set
+		keys   []string
+		change func(m *PlayerModel)
+		want   settings
+		header string
+	}{
+		{"none yet", nil, nil, settings{1, player.EQGains{}, player.ReplayGainOff, 1}, ""},
+		{"first", []string{"P"}, nil, settings{0.4, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 1}, "preset night"},
+		{"second keeps the rest", []string{"P", "P"}, nil, settings{0.4, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 0.75}, "preset podcast"},
+		{"wraps around", []string{"P", "P", "P"}, nil, settings{0.4, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 0.75}, "preset night"},
+		{"volume changed", []string{"P"}, func(m *PlayerModel) { m.changeVolume(0.1) }, settings{0.5, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 1}, "preset night (changed)"},
+		{"speed changed", []string{"P", "P", "]"}, nil, settings{0.4, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 1}, "preset podcast (changed)"},
+		{"unnamed setting changed", []string{"P", "P", "E", "up", "esc"}, nil, settings{0.4, player.EQGains{-2, 0, -2}, player.ReplayGainAlbum, 0.75}, "preset podcast"},
+		{"switching back drops the change", []string{"P", "]", "P", "P"}, nil, settings{0.4, player.EQGains{-3, 0, -2}, player.ReplayGainAlbum, 0.75}, "preset night"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(3)...)
+			h.m.EnableReplayGain(player.ReplayGainOff)
+			h.m.EnablePresets(presets)
+			h.start()
+			for _, k := range tt.keys {
+				h.press(k)
+				h.advance(bannerDuration)
+			}
+			if tt.change != nil {
+				tt.change(h.m)
+			}
+
+			got := settings{h.m.volume, h.m.eq, h.m.replayGain, h.m.speed}
+			if got != tt.want {
+				t.Errorf("settings = %+v, want %+v", got, tt.want)
+			}
+			if h.player.gain != got.volume {
+				t.Errorf("player gain = %v, want %v", h.player.gain, got.volume)
+			}
+			if !h.m.playing || h.playing() != "01.mp3" {
+				t.Errorf("on %s, playing = %v, want 01.mp3 to play on", h.playing(), h.m.playing)
+			}
+			if label := h.m.presetLabel(); label != tt.header {
+				t.Errorf("presetLabel() = %q, want %q", label, tt.header)
+			}
+			title := strings.SplitN(h.m.View(), "\n", 2)[0]
+			if tt.header != "" && !strings.Contains(title, tt.header) {
+				t.Errorf("header %q doesn't name %q", title, tt.header)
+			}
+		})
+	}
+}
+
+func TestSelectPreset(t *testing.T) {
+	loud := 0.9
+	h := newHarness(t, album(3)...)
+	h.m.EnablePresets([]soundPreset{{Name: "kitchen", Volume: &loud}})
+	if err := h.m.SelectPreset("garage"); err == nil {
+		t.Error("SelectPreset of an unknown preset = nil, want an error")
+	}
+	if err := h.m.SelectPreset("kitchen"); err != nil || h.m.volume != loud || h.m.presetLabel() != "preset kitchen" {
+		t.Errorf("SelectPreset = %v, volume %v, label %q, want kitchen at %v", err, h.m.volume, h.m.presetLabel(), loud)
+	}
+
+	// Without presets P says how to get some
+	h = newHarness(t, album(3)...).start()
+	h.advance(time.Second)
+	h.press("P")
+	if !strings.Contains(h.m.banner, "No presets") {
+		t.Errorf("banner = %q, want no presets", h.m.banner)
+	}
+}
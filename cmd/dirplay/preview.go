@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// previewLength is how much of each track "p" plays when --preview didn't
+// set a length
+const previewLength = 20 * time.Second
+
+// previewMode plays only a window of each track, then moves on. The
+// current track plays from "from"; until is the end of its window, zero
+// once it plays in full.
+type previewMode struct {
+	on     bool
+	length time.Duration
+	offset float64
+	from   time.Duration
+	until  time.Duration
+}
+
+// validatePreview checks --preview and --preview-offset
+func validatePreview(length time.Duration, offset float64) error {
+	if length < 0 {
+		return fmt.Errorf("invalid --preview %s (want a positive length, or 0 to disable)", length)
+	}
+	if offset < 0 || offset >= 100 {
+		return fmt.Errorf("invalid --preview-offset %v (want 0 to 99)", offset)
+	}
+	return nil
+}
+
+// EnablePreview sets the preview offset in percent of each track, and
+// starts in preview mode when length is positive
+func (m *PlayerModel) EnablePreview(length time.Duration, offset float64) {
+	m.preview.on = length > 0
+	m.preview.length = previewLength
+	if length > 0 {
+		m.preview.length = length
+	}
+	m.preview.offset = offset
+}
+
+// window returns where the preview of a track of the given length starts
+// and ends. It starts offset percent in, earlier if the track would end
+// first, and tracks shorter than the preview play whole. Tracks of unknown
+// length are previewed from the start.
+func (p previewMode) window(duration time.Duration) (from, until time.Duration) {
+	if duration <= 0 {
+		return 0, p.length
+	}
+	from = time.Duration(float64(duration) * p.offset / 100)
+	from = max(min(from, duration-p.length), 0)
+	return from, min(from+p.length, duration)
+}
+
+// previewing reports whether the current track only plays its window
+func (m *PlayerModel) previewing() bool {
+	return m.preview.until > 0
+}
+
+// previewOver reports whether playback has reached the end of the window
+func (m *PlayerModel) previewOver() bool {
+	return m.playing && m.previewing() && m.position >= m.preview.until
+}
+
+// endPreview moves on to the next track once the window has played
+func (m *PlayerModel) endPreview() tea.Cmd {
+	if m.atPlaylistEnd() {
+		m.leaveTrack()
+		return m.finishPlaylist()
+	}
+	return m.skip(1)
+}
+
+// togglePreview switches preview mode. Switched on, the current track is
+// cut to a window from here on; switched off, it plays to its real end.
+func (m *PlayerModel) togglePreview() tea.Cmd {
+	m.preview.on = !m.preview.on
+	if !m.preview.on {
+		return tea.Batch(m.playInFull(), m.showBanner("Preview off"))
+	}
+
+	banner := m.showBanner(fmt.Sprintf("Previewing %s of each track", formatDuration(m.preview.length)))
+	if !m.playing || m.previewing() || m.looping() {
+		return banner
+	}
+
+	// Jump ahead to the window, or preview the next stretch when it has
+	// already passed
+	from, until := m.preview.window(m.duration)
+	if m.position < from {
+		m.player.Seek(from)
+		m.position = from
+	} else {
+		until = m.position + m.preview.length
+		if m.duration > 0 {
+			until = min(until, m.duration)
+		}
+	}
+	m.preview.until = until
+	return banner
+}
+
+// playInFull lets the current track play to its end. It counts as a
+// start in the album history from here, and the next track is preloaded
+// again.
+func (m *PlayerModel) playInFull() tea.Cmd {
+	if !m.previewing() {
+		return nil
+	}
+	m.preview.until = 0
+	return tea.Batch(m.recordAlbumProgress(m.currentTrack(), false), m.preloadNext())
+}
+
+// recordPreview counts a previewed track towards its album's history,
+// apart from the tracks that played
+func (m *PlayerModel) recordPreview(track string) tea.Cmd {
+	if m.albumStats == nil || track == "" {
+		return nil
+	}
+
+	m.albumStats.TrackPreviewed(track)
+
+	stats, work := m.albumStats, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		if err := stats.Save(); err != nil {
+			work.Failed("save album stats", err)
+		}
+		return nil
+	})
+}
+
+// previewLabel returns the preview state shown in the status line, or ""
+func (m *PlayerModel) previewLabel() string {
+	switch {
+	case !m.preview.on:
+		return ""
+	case m.previewing():
+		return fmt.Sprintf("preview, %s plays in full", m.keys.Full.Help().Key)
+	}
+	return "preview mode"
+}
@@ -0,0 +1,76 @@
+package main
+
+import "fmt"
+
+// The queue holds tracks picked with "a" in the playlist pane, or from the
+// browse pane, to play next, ahead of the playlist order
+
+// queuedAt returns the 1-based queue position of a track, or 0
+func (m *PlayerModel) queuedAt(id trackID) int {
+	return m.queue.Position(id)
+}
+
+// toggleQueued queues a track to play next, after those already queued,
+// or takes it off the queue again
+func (m *PlayerModel) toggleQueued(id trackID) string {
+	if !m.queue.Toggle(id) {
+		return "Removed from the queue"
+	}
+	return fmt.Sprintf("Queued %s, %d up next", m.paneEntryName(m.tracks.Path(id)), m.queue.Len())
+}
+
+// queueHead returns the playlist position of the first queued track, or
+// -1. Queued tracks filtered out of the playlist are passed over.
+func (m *PlayerModel) queueHead() int {
+	for _, id := range m.queue.Tracks() {
+		if index := m.indexOf(id); index >= 0 {
+			return index
+		}
+	}
+	return -1
+}
+
+// playlistBase returns the playlist position the playlist order carries
+// on from, which is the track that played before the queue took over
+func (m *PlayerModel) playlistBase() int {
+	if returnTo := m.queue.ReturnTo(); returnTo != noTrack {
+		if index := m.indexOf(returnTo); index >= 0 {
+			return index
+		}
+	}
+	return m.currentIndex
+}
+
+// nextIndex returns the playlist position that plays after the current
+// track: the head of the queue, or the next entry in playlist order, or
+// the track picked with --weights
+func (m *PlayerModel) nextIndex() int {
+	if index := m.queueHead(); index >= 0 {
+		return index
+	}
+	if m.weighted != nil {
+		return m.weightedNext()
+	}
+	if len(m.playlist) == 0 {
+		return m.currentIndex
+	}
+	return m.nextInRotation(m.playlistBase())
+}
+
+// advance moves currentIndex on to the next track, taking it off the
+// queue if it was queued
+func (m *PlayerModel) advance() {
+	if id, ok := m.queue.Next(m.current, func(id trackID) bool { return m.indexOf(id) >= 0 }); ok {
+		m.currentIndex = m.indexOf(id)
+		return
+	}
+
+	if m.weighted != nil {
+		m.currentIndex = m.weightedNext()
+		m.weighted.next = noTrack
+		return
+	}
+	if len(m.playlist) > 0 {
+		m.currentIndex = m.nextInRotation(m.playlistBase())
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bogem/id3v2/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxRating is the most stars a track can get
+const maxRating = 5
+
+// popmEmail identifies the POPM frame written with --write-tags. Windows
+// Media Player's is the one most players read.
+const popmEmail = "Windows Media Player 9 Series"
+
+// popmRatings maps stars to the POPM rating byte the way Windows Media
+// Player does
+var popmRatings = [maxRating + 1]uint8{0, 1, 64, 128, 196, 255}
+
+// ratingStore is the persistent ratings.json: star ratings from 1 to 5,
+// keyed by file path
+type ratingStore struct {
+	mu      sync.Mutex
+	path    string
+	Version int            `json:"version"`
+	Ratings map[string]int `json:"ratings"`
+}
+
+// ratingMigrations upgrade older ratings files, see readVersioned
+var ratingMigrations = []migration{unversioned}
+
+// ratingSavedMsg reports the outcome of saving a rating
+type ratingSavedMsg struct {
+	err error
+}
+
+// validateMinRating checks --min-rating
+func validateMinRating(stars int) error {
+	if stars < 0 || stars > maxRating {
+		return fmt.Errorf("invalid --min-rating %d (want 0 to %d)", stars, maxRating)
+	}
+	return nil
+}
+
+// loadRatings reads the ratings, starting empty if there are none
+func loadRatings() (*ratingStore, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store := &ratingStore{
+		path:    filepath.Join(dir, "ratings.json"),
+		Ratings: make(map[string]int),
+	}
+
+	err = readVersioned(store.path, ratingMigrations, store)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if store.Ratings == nil {
+		store.Ratings = make(map[string]int)
+	}
+	return store, nil
+}
+
+// Get returns the stars of a track, 0 if unrated
+func (s *ratingStore) Get(track string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Ratings[track]
+}
+
+// Set rates a track, 0 clearing its rating
+func (s *ratingStore) Set(track string, stars int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stars == 0 {
+		delete(s.Ratings, track)
+		return
+	}
+	s.Ratings[track] = stars
+}
+
+// Save writes the ratings to disk via a temporary file
+func (s *ratingStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Version = len(ratingMigrations)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+// writeRatingTag stores a rating in the file's own tags. Only MP3 is
+// supported, as a POPM frame; other formats keep their rating in
+// ratings.json alone.
+func writeRatingTag(track string, stars int) error {
+	if strings.ToLower(filepath.Ext(track)) != ".mp3" {
+		return nil
+	}
+
+	tags, err := id3v2.Open(track, id3v2.Options{Parse: true})
+	if err != nil {
+		return err
+	}
+	defer tags.Close()
+
+	// Only our own frame is replaced, other players' ratings stay
+	popm := tags.CommonID("Popularimeter")
+	frames := tags.GetFrames(popm)
+	tags.DeleteFrames(popm)
+	for _, frame := range frames {
+		if other, ok := frame.(id3v2.PopularimeterFrame); ok && other.Email != popmEmail {
+			tags.AddFrame(popm, other)
+		}
+	}
+	if stars > 0 {
+		tags.AddFrame(popm, id3v2.PopularimeterFrame{Email: popmEmail, Rating: popmRatings[stars], Counter: big.NewInt(0)})
+	}
+	return tags.Save()
+}
+
+// EnableRatings lets 1-5 rate tracks into store, and with writeTags into
+// the files' tags too
+func (m *PlayerModel) EnableRatings(store *ratingStore, writeTags bool) {
+	m.ratings = store
+	m.writeRatingTags = writeTags
+}
+
+// rate gives the current track a number of stars, 0 clearing its rating,
+// and saves it in the background
+func (m *PlayerModel) rate(stars int) tea.Cmd {
+	if m.ratings == nil || !m.playing {
+		return nil
+	}
+
+	track := m.currentTrack()
+	m.ratings.Set(track, stars)
+
+	store, writeTags, work := m.ratings, m.writeRatingTags, m.work
+	save := work.Cmd(func(context.Context) tea.Msg {
+		if err := store.Save(); err != nil {
+			work.Failed("save ratings", err)
+			return ratingSavedMsg{err: err}
+		}
+		if writeTags {
+			if err := writeRatingTag(track, stars); err != nil {
+				err = fmt.Errorf("%s: %w", filepath.Base(track), err)
+				work.Failed("write the rating tag", err)
+				return ratingSavedMsg{err: err}
+			}
+		}
+		return ratingSavedMsg{}
+	})
+
+	banner := "Rating cleared"
+	if stars > 0 {
+		banner = "Rated " + ratingStars(stars)
+	}
+	return tea.Batch(m.showBanner(banner), save)
+}
+
+// ratingPressed returns the stars a key of the rate binding stands for,
+// by its place among the binding's keys
+func (m *PlayerModel) ratingPressed(msg tea.KeyMsg) int {
+	return min(slices.Index(m.keys.Rate.Keys(), msg.String())+1, maxRating)
+}
+
+// ratingSaved reports a rating that couldn't be saved
+func (m *PlayerModel) ratingSaved(msg ratingSavedMsg) tea.Cmd {
+	if msg.err == nil {
+		return nil
+	}
+	return m.showBanner(fmt.Sprintf("Could not save rating: %v", msg.err))
+}
+
+// ratingLabel returns the current track's rating as stars, or ""
+func (m *PlayerModel) ratingLabel() string {
+	if m.ratings == nil || m.current == noTrack {
+		return ""
+	}
+	if stars := m.ratings.Get(m.currentTrack()); stars > 0 {
+		return ratingStars(stars)
+	}
+	return ""
+}
+
+// ratingStars draws a rating, e.g. "★★★☆☆"
+func ratingStars(stars int) string {
+	return strings.Repeat("★", stars) + strings.Repeat("☆", maxRating-stars)
+}
+
+// ratedAtLeast reports whether a track has at least the given stars.
+// Unrated tracks only pass when no minimum is set.
+func (s *ratingStore) ratedAtLeast(track string, stars int) bool {
+	return stars == 0 || s != nil && s.Get(track) >= stars
+}
+
+// SetMinRating only plays tracks rated at least stars, 0 playing all
+func (m *PlayerModel) SetMinRating(stars int) {
+	m.minRating = stars
+}
+
+// keepRated drops the paths rated below --min-rating
+func (m *PlayerModel) keepRated(paths []string) []string {
+	if m.minRating == 0 {
+		return paths
+	}
+
+	var kept []string
+	for _, path := range paths {
+		if m.ratings.ratedAtLeast(path, m.minRating) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// remoteAction is a playback command from outside the TUI, such as a media
+// key handled by the desktop
+type remoteAction int
+
+const (
+	remotePlayPause remoteAction = iota
+	remotePlay
+	remotePause
+	remoteStop
+	remoteNext
+	remotePrevious
+	remoteSeek
+	remoteSetPosition
+	remoteQuit
+)
+
+// remoteMsg is injected into the program by integrations with
+// program.Send. offset is relative for remoteSeek and absolute for
+// remoteSetPosition, which only applies while track is current.
+type remoteMsg struct {
+	action remoteAction
+	offset time.Duration
+	track  trackID
+}
+
+// nowPlaying is the state integrations publish. It only changes on track
+// changes and pause/resume, the position is published separately.
+type nowPlaying struct {
+	status string
+	track  trackID
+	path   string
+	artist string
+	title  string
+	album  string
+	length time.Duration
+}
+
+// EnableMPRIS publishes playback state to the MPRIS server
+func (m *PlayerModel) EnableMPRIS(server *mprisServer) {
+	m.mpris = server
+}
+
+// handleRemote carries out a command from an integration the same way the
+// matching key would
+func (m *PlayerModel) handleRemote(msg remoteMsg) tea.Cmd {
+	switch msg.action {
+	case remotePlayPause:
+		return m.togglePause()
+	case remotePlay:
+		if m.paused || m.ended {
+			return m.togglePause()
+		}
+	case remotePause, remoteStop:
+		if m.playing && !m.paused {
+			return m.togglePause()
+		}
+	case remoteNext:
+		return m.next()
+	case remotePrevious:
+		return m.previous()
+	case remoteSeek:
+		return m.seekTo(m.position + msg.offset)
+	case remoteSetPosition:
+		if msg.track == m.current {
+			return m.seekTo(msg.offset)
+		}
+	case remoteQuit:
+		return m.quit()
+	}
+	return nil
+}
+
+// seekTo moves playback to pos within the current track. Seeking past the
+// end moves on to the next track.
+func (m *PlayerModel) seekTo(pos time.Duration) tea.Cmd {
+	if !m.playing {
+		return nil
+	}
+	if m.duration > 0 && pos >= m.duration {
+		return m.skip(1)
+	}
+	pos = max(pos, 0)
+	m.leaveLoop(pos)
+
+	if err := m.player.Seek(pos); err != nil {
+		if errors.Is(err, player.ErrStreamNotSeekable) {
+			return m.showBanner("This stream can't seek")
+		}
+		return nil
+	}
+	m.position = pos
+	if m.mpris != nil {
+		m.mpris.Seeked(pos)
+	}
+	return nil
+}
+
+// nowPlaying returns the state integrations should show
+func (m *PlayerModel) nowPlaying() nowPlaying {
+	status := "Stopped"
+	if m.playing {
+		status = "Playing"
+		if m.paused {
+			status = "Paused"
+		}
+	}
+
+	return nowPlaying{
+		status: status,
+		track:  m.current,
+		path:   m.currentTrack(),
+		artist: m.artist,
+		title:  m.title,
+		album:  m.album,
+		length: m.duration,
+	}
+}
+
+// publish pushes state changes to the integrations after each update
+func (m *PlayerModel) publish() {
+	if m.mpris == nil && m.httpStream == nil {
+		return
+	}
+
+	if state := m.nowPlaying(); state != m.published {
+		m.published = state
+		if m.mpris != nil {
+			m.mpris.Publish(state)
+		}
+		if m.httpStream != nil {
+			m.httpStream.Publish(state)
+		}
+	}
+	if m.mpris != nil {
+		m.mpris.SetPosition(m.position)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// validateReplayGain checks the --replaygain mode
+func validateReplayGain(mode string) error {
+	if mode != player.ReplayGainOff && mode != player.ReplayGainTrack && mode != player.ReplayGainAlbum {
+		return fmt.Errorf("invalid --replaygain mode %q (want %s, %s or %s)", mode, player.ReplayGainOff, player.ReplayGainTrack, player.ReplayGainAlbum)
+	}
+	return nil
+}
+
+// EnableReplayGain levels tracks by their ReplayGain tags, see
+// player.AudioPlayer.SetReplayGain
+func (m *PlayerModel) EnableReplayGain(mode string) {
+	m.replayGain = mode
+	m.player.SetReplayGain(mode)
+}
+
+// replayGainLabel returns the gain applied to the current track for the
+// status line, or "" when ReplayGain is off
+func (m *PlayerModel) replayGainLabel() string {
+	if m.replayGain == "" || m.replayGain == player.ReplayGainOff {
+		return ""
+	}
+	db, ok := m.info.ReplayGain.Gain(m.replayGain == player.ReplayGainAlbum)
+	if !ok {
+		return "RG untagged"
+	}
+	return fmt.Sprintf("RG %+.1f dB", db)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+func TestValidateReplayGain(t *testing.T) {
+	for _, tt := range []struct {
+		mode    string
+		wantErr bool
+	}{
+		{player.ReplayGainOff, false},
+		{player.ReplayGainTrack, false},
+		{player.ReplayGainAlbum, false},
+		{"", true},
+		{"Track", true},
+		{"on", true},
+	} {
+		if err := validateReplayGain(tt.mode); (err != nil) != tt.wantErr {
+			t.Errorf("validateReplayGain(%q) = %v, want error %v", tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReplayGainLabel(t *testing.T) {
+	tagged := library.ReplayGain{TrackGain: -6.24, AlbumGain: 2.04, HasTrack: true, HasAlbum: true}
+	tests := []struct {
+		name string
+		mode string
+		rg   library.ReplayGain
+		want string
+	}{
+		{"not enabled", "", tagged, ""},
+		{"off", player.ReplayGainOff, tagged, ""},
+		{"track", player.ReplayGainTrack, tagged, "RG -6.2 dB"},
+		{"album", player.ReplayGainAlbum, tagged, "RG +2.0 dB"},
+		{"zero", player.ReplayGainTrack, library.ReplayGain{HasTrack: true}, "RG +0.0 dB"},
+		{"untagged", player.ReplayGainAlbum, library.ReplayGain{}, "RG untagged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &PlayerModel{replayGain: tt.mode, info: player.TrackInfo{ReplayGain: tt.rg}}
+			if got := m.replayGainLabel(); got != tt.want {
+				t.Errorf("replayGainLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// rotationConfirm is how long a second press of the new rotation key has
+// to start one
+const rotationConfirm = 5 * time.Second
+
+// rotation is the persistent rotation.json: for each library, keyed like
+// the saved state, the tracks played to the end since its rotation
+// started. Tracks are stored as 64-bit hashes of their paths, which keeps
+// the file small for large libraries; a collision only means a track
+// counts as heard early.
+type rotation struct {
+	mu        sync.Mutex
+	path      string
+	key       string
+	Version   int                        `json:"version"`
+	Libraries map[string]*rotationRecord `json:"libraries"`
+
+	// This library's tracks, as the hashes of its record
+	heard   map[uint64]bool
+	started time.Time
+}
+
+// rotationRecord is the rotation of one library
+type rotationRecord struct {
+	Started time.Time `json:"started"`
+	Heard   []string  `json:"heard"`
+}
+
+// rotationMigrations upgrade older rotation files, see readVersioned. The
+// file has had one format so far.
+var rotationMigrations []migration
+
+// rotationCount is how many tracks of the library were heard, counted
+// again when a track is heard or the library changes size
+type rotationCount struct {
+	library int
+	heard   int
+	valid   bool
+}
+
+// loadRotation reads the rotation of the library key names, starting one
+// if there is none
+func loadRotation(key string) (*rotation, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rotation{
+		path:      filepath.Join(dir, "rotation.json"),
+		key:       key,
+		Libraries: make(map[string]*rotationRecord),
+		heard:     make(map[uint64]bool),
+		started:   time.Now(),
+	}
+
+	err = readVersioned(r.path, rotationMigrations, r)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Libraries == nil {
+		r.Libraries = make(map[string]*rotationRecord)
+	}
+	if record := r.Libraries[key]; record != nil {
+		r.started = record.Started
+		for _, hash := range record.Heard {
+			if n, err := strconv.ParseUint(hash, 16, 64); err == nil {
+				r.heard[n] = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// trackHash is the hash a track is stored by
+func trackHash(track string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(track))
+	return h.Sum64()
+}
+
+// Heard reports whether a track was played to the end this rotation
+func (r *rotation) Heard(track string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.heard[trackHash(track)]
+}
+
+// Hear counts a track as heard, reporting false if it already was
+func (r *rotation) Hear(track string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash := trackHash(track)
+	if r.heard[hash] {
+		return false
+	}
+	r.heard[hash] = true
+	return true
+}
+
+// Count returns how many of tracks were heard
+func (r *rotation) Count(tracks []string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, track := range tracks {
+		if r.heard[trackHash(track)] {
+			n++
+		}
+	}
+	return n
+}
+
+// Prune forgets the heard tracks that aren't among tracks, e.g. files
+// since deleted
+func (r *rotation) Prune(tracks []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keep := make(map[uint64]bool, len(tracks))
+	for _, track := range tracks {
+		keep[trackHash(track)] = true
+	}
+	for hash := range r.heard {
+		if !keep[hash] {
+			delete(r.heard, hash)
+		}
+	}
+}
+
+// Reset starts a new rotation, with nothing heard
+func (r *rotation) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.heard)
+	r.started = time.Now()
+}
+
+// Save writes the rotations to disk via a temporary file, keeping what
+// is saved for other libraries
+func (r *rotation) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	heard := make([]string, 0, len(r.heard))
+	for hash := range r.heard {
+		heard = append(heard, fmt.Sprintf("%016x", hash))
+	}
+	slices.Sort(heard)
+
+	// Another dirplay may have saved the rotation of another library since
+	var current rotation
+	if err := readVersioned(r.path, rotationMigrations, &current); err == nil {
+		for key, record := range current.Libraries {
+			r.Libraries[key] = record
+		}
+	}
+	r.Libraries[r.key] = &rotationRecord{Started: r.started, Heard: heard}
+
+	r.Version = len(rotationMigrations)
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(r.path, data)
+}
+
+// EnableRotation passes over the tracks heard this rotation when picking
+// the next one
+func (m *PlayerModel) EnableRotation(r *rotation) {
+	m.rotation = r
+}
+
+// heardThisRotation reports whether a track is passed over for being heard
+// this rotation
+func (m *PlayerModel) heardThisRotation(id trackID) bool {
+	return m.rotation != nil && m.rotation.Heard(m.tracks.Path(id))
+}
+
+// nextInRotation returns the playlist position after index, passing over
+// the tracks heard this rotation. Queued tracks and the ones chosen by hand
+// play whether heard or not.
+func (m *PlayerModel) nextInRotation(index int) int {
+	n := len(m.playlist)
+	if m.rotation != nil {
+		for i := 1; i < n; i++ {
+			if next := (index + i) % n; !m.heardThisRotation(m.playlist[next]) {
+				return next
+			}
+		}
+	}
+	return (index + 1) % n
+}
+
+// libraryComplete reports whether fullPlaylist holds every track of the
+// sources, so what isn't in it is gone
+func (m *PlayerModel) libraryComplete() bool {
+	return !m.scanning && m.scanFilter == "" && m.minRating == 0 && m.weighted == nil
+}
+
+// rotationProgress returns how many tracks of the library were heard this
+// rotation, and how many it has
+func (m *PlayerModel) rotationProgress() (heard, total int) {
+	if !m.rotationCount.valid || m.rotationCount.library != len(m.fullPlaylist) {
+		m.rotationCount = rotationCount{
+			library: len(m.fullPlaylist),
+			heard:   m.rotation.Count(m.tracks.Paths(m.fullPlaylist)),
+			valid:   true,
+		}
+	}
+	return m.rotationCount.heard, m.rotationCount.library
+}
+
+// recordRotation counts a track played to its end as heard, starting a new
+// rotation once the whole library was, and saves it in the background. A
+// track heard again, say chosen by hand, isn't counted twice.
+func (m *PlayerModel) recordRotation(track string) tea.Cmd {
+	if m.rotation == nil || track == "" || m.previewing() {
+		return nil
+	}
+	if !m.rotation.Hear(track) {
+		return nil
+	}
+	m.rotationCount.valid = false
+
+	var banner tea.Cmd
+	if heard, total := m.rotationProgress(); !m.scanning && total > 0 && heard >= total {
+		m.rotation.Reset()
+		m.rotationCount.valid = false
+		banner = m.showBanner(fmt.Sprintf("Heard all %s tracks, a new rotation starts", library.FormatCount(total)))
+	}
+	return tea.Batch(banner, m.saveRotation())
+}
+
+// saveRotation saves the rotation in the background, first forgetting the
+// tracks no longer in the library when the whole library is known
+func (m *PlayerModel) saveRotation() tea.Cmd {
+	if m.libraryComplete() {
+		m.rotation.Prune(m.tracks.Paths(m.fullPlaylist))
+	}
+	r, work := m.rotation, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		if err := r.Save(); err != nil {
+			work.Failed("save the rotation", err)
+		}
+		return nil
+	})
+}
+
+// newRotation starts a new rotation if the key is pressed again within
+// rotationConfirm
+func (m *PlayerModel) newRotation() tea.Cmd {
+	if m.rotation == nil {
+		return m.showBanner("Rotations are off, --rotation keeps track of what was heard")
+	}
+	if time.Now().After(m.rotationResetBy) {
+		m.rotationResetBy = time.Now().Add(rotationConfirm)
+		return m.showBanner(fmt.Sprintf("%s again to start a new rotation and forget what was heard", m.keys.NewRotation.Help().Key))
+	}
+
+	m.rotationResetBy = time.Time{}
+	m.rotation.Reset()
+	m.rotationCount.valid = false
+	return tea.Batch(m.showBanner("New rotation started"), m.saveRotation(), m.preloadNext())
+}
+
+// rotationLabel returns how much of the library was heard this rotation
+func (m *PlayerModel) rotationLabel() string {
+	if m.rotation == nil {
+		return ""
+	}
+	heard, total := m.rotationProgress()
+	return fmt.Sprintf("%s / %s heard this rotation", library.FormatCount(heard), library.FormatCount(total))
+}
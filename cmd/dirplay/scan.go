@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/playlist"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// The scanner hands discovered files to the UI in batches of at most
+// scanBatchSize, and at least every scanBatchInterval while it finds any
+const (
+	scanBatchSize     = 500
+	scanBatchInterval = 200 * time.Millisecond
+)
+
+// scanBatchMsg carries files found by the background scan
+type scanBatchMsg struct {
+	paths []string
+}
+
+// scanDoneMsg reports that the background scan finished
+type scanDoneMsg struct {
+	warnings []error
+}
+
+// startScan walks the sources as background work and returns the channel
+// its batches arrive on. The channel is closed once the scan finishes or
+// the work is stopped, so the goroutine never outlives the program. With
+// a --sort mode other than shuffle every track is sent in one batch at the
+// end, sorted.
+func startScan(work *backgroundWork, config scan.Config, sources []string, sortMode string) <-chan tea.Msg {
+	ch := make(chan tea.Msg)
+
+	work.Go(func(ctx context.Context) {
+		defer close(ch)
+
+		send := func(msg tea.Msg) bool {
+			select {
+			case ch <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Even the first batch collects for a moment, so playback doesn't
+		// always start with the first file on disk
+		var batch []string
+		var sorted []playlist.File
+		var warnings []error
+		sent := time.Now()
+		config.Walk(ctx, sources,
+			func(path string, info os.FileInfo) {
+				if sortMode != "" {
+					file := playlist.File{Path: path}
+					if info != nil {
+						file.ModTime = info.ModTime()
+					}
+					sorted = append(sorted, file)
+					return
+				}
+				batch = append(batch, path)
+				if len(batch) >= scanBatchSize || time.Since(sent) >= scanBatchInterval {
+					if send(scanBatchMsg{paths: batch}) {
+						batch = nil
+						sent = time.Now()
+					}
+				}
+			},
+			func(err error) { warnings = append(warnings, err) })
+
+		if sorted != nil && ctx.Err() == nil {
+			batch = playlist.Sort(sorted, sortMode)
+		}
+		if len(batch) > 0 && !send(scanBatchMsg{paths: batch}) {
+			return
+		}
+		send(scanDoneMsg{warnings: warnings})
+	})
+
+	return ch
+}
+
+// StartScan scans the sources in the background once the program starts.
+// pathFilter is the --filter query, matched against paths. A resumed
+// session keeps its saved order and drops saved tracks the scan doesn't
+// find; otherwise new tracks are shuffled in as they arrive.
+func (m *PlayerModel) StartScan(config scan.Config, sources []string, pathFilter string, resumed bool) {
+	m.sources = sources
+	m.scanConfig = config
+	m.scan = startScan(m.work, config, sources, m.sortMode)
+	m.scanning = true
+	m.scanFilter = pathFilter
+	m.scanResumed = resumed
+	m.scanSeen = make(map[string]bool)
+}
+
+// KeepScanOrder plays scanned files in the order they are found instead of
+// shuffling them, used for --playlist
+func (m *PlayerModel) KeepScanOrder() {
+	m.keepOrder = true
+}
+
+// StopBackground cancels the scan and any other background work still
+// running, without waiting for it
+func (m *PlayerModel) StopBackground() {
+	m.work.cancel()
+}
+
+// WriteFailures returns the notes, ratings, stats and other files that
+// couldn't be written, including while quitting
+func (m *PlayerModel) WriteFailures() []error {
+	return m.work.Failures()
+}
+
+// ScanResult returns the problems the scan ran into, and an error if it
+// found nothing to play
+func (m *PlayerModel) ScanResult() ([]error, error) {
+	return m.scanWarnings, m.scanErr
+}
+
+// waitForScan delivers the next message from the background scan
+func (m *PlayerModel) waitForScan() tea.Cmd {
+	if m.scan == nil {
+		return nil
+	}
+
+	scan := m.scan
+	return func() tea.Msg {
+		msg, ok := <-scan
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// addScanned adds a batch of scanned files and starts playback with the
+// first of them if nothing is playing yet
+func (m *PlayerModel) addScanned(msg scanBatchMsg) tea.Cmd {
+	m.scanFound += len(msg.paths)
+	for _, path := range msg.paths {
+		m.scanSeen[path] = true
+	}
+
+	paths := msg.paths
+	if m.scanFilter != "" {
+		paths = filterPlaylist(paths, nil, m.scanFilter)
+	}
+	paths = m.keepRated(paths)
+	paths = m.keepWeighted(paths)
+	paths, hidden := m.dedupeByName(paths)
+
+	wasLast := m.currentIndex == len(m.playlist)-1
+	first := m.appendTracks(paths)
+
+	switch {
+	case first < 0:
+		return tea.Batch(hidden, m.waitForScan())
+	case m.current == noTrack && !m.start.chosen():
+		m.currentIndex = first
+		if m.weighted != nil {
+			m.currentIndex = m.weightedNext()
+			m.weighted.next = noTrack
+		}
+		return tea.Batch(m.loadCurrentTrack(), m.waitForScan())
+	case m.playing && wasLast:
+		// The current track is no longer the last one
+		return tea.Batch(m.preloadNext(), m.waitForScan())
+	}
+	return tea.Batch(hidden, m.waitForScan())
+}
+
+// finishScan settles the playlist once every source has been walked
+func (m *PlayerModel) finishScan(msg scanDoneMsg) tea.Cmd {
+	m.scanning = false
+	m.scan = nil
+	m.scanWarnings = msg.warnings
+
+	if len(m.fullPlaylist) == 0 {
+		m.scanErr = fmt.Errorf("no audio files found in %s", strings.Join(m.sources, ", "))
+		if m.scanFilter != "" {
+			m.scanErr = fmt.Errorf("no audio files match filter %q", m.scanFilter)
+		}
+		if m.minRating > 0 {
+			m.scanErr = fmt.Errorf("no audio files rated %d stars or more", m.minRating)
+		}
+		if m.weighted != nil && m.scanFound > 0 {
+			m.scanErr = fmt.Errorf("no audio files in folders weighted above 0")
+		}
+		return m.quit()
+	}
+
+	var numbers tea.Cmd
+	switch {
+	case m.scanResumed:
+		m.pruneUnseen()
+	case m.keepOrder:
+	case m.shuffleMode == playlist.ShuffleSmart && m.albumStats != nil:
+		m.smartShuffleUpcoming()
+		// Tags indexed during the scan came before the albums were laid out
+		m.orderAlbums(m.tracks.Paths(m.playlist))
+	case m.shuffleMode == playlist.ShuffleAlbum:
+		// Laid out once the track numbers are read
+		numbers = m.readTrackNumbers()
+	}
+	m.scanSeen = nil
+
+	// Missing and refused playlist entries are listed on exit
+	var banner tea.Cmd
+	if skipped := countSkippedEntries(msg.warnings); skipped > 0 {
+		banner = m.showBanner(fmt.Sprintf("Skipped %d playlist entries, listed on exit", skipped))
+	} else if skipped := skippedFiles(msg.warnings); skipped != nil {
+		banner = m.showBanner(fmt.Sprintf("%s tracks (%s)", library.FormatCount(len(m.fullPlaylist)), skipped.Summary()))
+	} else if dirs, files := countIgnored(msg.warnings); dirs+files > 0 {
+		banner = m.showBanner(fmt.Sprintf("Ignored %d directories and %d files by .nomedia and .dirplayignore", dirs, files))
+	}
+
+	if m.start.chosen() {
+		return tea.Batch(banner, numbers, m.beginAtStart())
+	}
+	if m.playing {
+		return tea.Batch(banner, numbers, m.preloadNext())
+	}
+	return tea.Batch(banner, numbers)
+}
+
+// countSkippedEntries counts the warnings about unusable playlist entries
+func countSkippedEntries(warnings []error) int {
+	count := 0
+	for _, warning := range warnings {
+		var entryErr *library.EntryError
+		if errors.As(warning, &entryErr) {
+			count++
+		}
+	}
+	return count
+}
+
+// skippedFiles returns what the probe left out of the scan, or nil
+func skippedFiles(warnings []error) *scan.SkippedError {
+	for _, warning := range warnings {
+		var skipped *scan.SkippedError
+		if errors.As(warning, &skipped) {
+			return skipped
+		}
+	}
+	return nil
+}
+
+// countIgnored adds up what ignore markers left out of the scan
+func countIgnored(warnings []error) (dirs, files int) {
+	for _, warning := range warnings {
+		var ignored *scan.IgnoredError
+		if errors.As(warning, &ignored) {
+			dirs += ignored.Dirs
+			files += ignored.Files
+		}
+	}
+	return dirs, files
+}
+
+// pruneUnseen drops restored tracks that the scan didn't find, which were
+// removed since the last session. The current track stays either way.
+func (m *PlayerModel) pruneUnseen() {
+	keep := func(ids []trackID) []trackID {
+		var kept []trackID
+		for _, id := range ids {
+			if id == m.current || m.scanSeen[m.tracks.Path(id)] {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	}
+
+	m.fullPlaylist = keep(m.fullPlaylist)
+	m.setPlaylist(keep(m.playlist))
+}
+
+// smartShuffleUpcoming orders the tracks after the current one by album
+// now that every album is known. Played tracks keep their place.
+func (m *PlayerModel) smartShuffleUpcoming() {
+	if m.filterQuery != "" || m.currentIndex+1 >= len(m.playlist) {
+		return
+	}
+
+	upcoming := m.tracks.Paths(m.playlist[m.currentIndex+1:])
+	playlist.ShuffleWeighted(upcoming, m.albumStats.Weight)
+
+	playlist := append(m.playlist[:m.currentIndex+1:m.currentIndex+1], m.tracks.AddAll(upcoming)...)
+	m.playlist = playlist
+	m.fullPlaylist = append([]trackID(nil), playlist...)
+	m.indexAlbums(m.currentIndex + 1)
+}
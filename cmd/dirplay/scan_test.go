@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// TestModelScanBanner finishes a scan of three tracks with warnings, and
+// checks the banner sums up what was left out
+func TestModelScanBanner(t *testing.T) {
+	skipped := &scan.SkippedError{Files: []scan.SkippedFile{
+		{Path: "/music/a.mp3", Reason: scan.Unreadable},
+		{Path: "/music/b.mp3", Reason: scan.Duplicate},
+		{Path: "/music/c.mp3", Reason: scan.Duplicate},
+		{Path: "/music/d.mp3", Reason: scan.Empty},
+	}}
+	tests := []struct {
+		name     string
+		warnings []error
+		want     string
+	}{
+		{"none", nil, ""},
+		{"other warnings", []error{errors.New("no audio files found in /elsewhere")}, ""},
+		{"skipped files", []error{errors.New("no audio files found in /elsewhere"), skipped}, "3 tracks (skipped 4: 1 unreadable, 2 duplicate, 1 empty)"},
+		{"wrapped", []error{fmt.Errorf("scanning: %w", skipped)}, "3 tracks (skipped 4: 1 unreadable, 2 duplicate, 1 empty)"},
+		{"ignored too", []error{&scan.IgnoredError{Dirs: 2}, skipped}, "3 tracks (skipped 4: 1 unreadable, 2 duplicate, 1 empty)"},
+		{"playlist entries first", []error{skipped, &library.EntryError{Entry: "gone.mp3"}}, "Skipped 1 playlist entries, listed on exit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			scanning(h, "")
+			h.send(scanBatchMsg{paths: album(3)})
+			h.send(scanDoneMsg{warnings: tt.warnings})
+			if h.m.banner != tt.want {
+				t.Errorf("banner %q, want %q", h.m.banner, tt.want)
+			}
+		})
+	}
+}
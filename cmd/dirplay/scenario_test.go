@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A step is one thing a user does, or one thing checked after it. It
+// reports what's wrong rather than failing, so the scenario can show
+// where it went wrong along with the screen at that point.
+type step struct {
+	name string
+	do   func(h *harness) error
+}
+
+// keys presses keys in turn, see harness.press
+func keys(k ...string) step {
+	return step{"press " + strings.Join(k, " "), func(h *harness) error {
+		h.press(k...)
+		return nil
+	}}
+}
+
+// wait lets d pass, with the ticks and timers due on the way
+func wait(d time.Duration) step {
+	return step{"wait " + d.String(), func(h *harness) error {
+		h.advance(d)
+		return nil
+	}}
+}
+
+// see checks the screen shows each of texts
+func see(texts ...string) step {
+	return step{fmt.Sprintf("see %q", texts), func(h *harness) error {
+		view := h.m.View()
+		for _, text := range texts {
+			if !strings.Contains(view, text) {
+				return fmt.Errorf("%q not on screen", text)
+			}
+		}
+		return nil
+	}}
+}
+
+// gone checks the screen no longer shows text
+func gone(text string) step {
+	return step{fmt.Sprintf("no %q", text), func(h *harness) error {
+		if strings.Contains(h.m.View(), text) {
+			return fmt.Errorf("%q still on screen", text)
+		}
+		return nil
+	}}
+}
+
+// check checks something of the model, the player or what they left behind
+func check(name string, ok func(h *harness) error) step {
+	return step{name, ok}
+}
+
+// runScenario starts h and takes the steps, stopping at the first that
+// fails
+func runScenario(t *testing.T, h *harness, steps []step) {
+	t.Helper()
+	h.start()
+	for i, s := range steps {
+		if err := s.do(h); err != nil {
+			t.Fatalf("step %d, %s: %v\n%s", i+1, s.name, err, h.m.View())
+		}
+	}
+}
+
+// TestScenarios plays through user flows from start to end, checking the
+// screen along the way
+func TestScenarios(t *testing.T) {
+	tracks := album(3)
+	tests := []struct {
+		name  string
+		setup func(h *harness)
+		steps []step
+	}{
+		{
+			name: "play through",
+			setup: func(h *harness) {
+				for i, track := range tracks {
+					h.player.setTrack(track, fakeTrack{length: 10 * time.Second, artist: "Band", title: fmt.Sprintf("Song %d", i+1)})
+				}
+			},
+			steps: []step{
+				see("Playing: Band - Song 1", "Track 1 of 3", "Next: 02", "▶ Playing", "00:00 / 00:10"),
+				wait(5 * time.Second),
+				see("00:04 / 00:10"),
+				// The end shows on the tick just after it
+				wait(5500 * time.Millisecond),
+				see("Playing: Band - Song 2", "Track 2 of 3", "00:00 / 00:10"),
+				wait(10500 * time.Millisecond),
+				see("Playing: Band - Song 3", "Track 3 of 3"),
+				wait(10500 * time.Millisecond),
+				see("Playing: Band - Song 1", "Track 1 of 3", "▶ Playing"),
+			},
+		},
+		{
+			name: "pause and resume",
+			steps: []step{
+				wait(2500 * time.Millisecond),
+				see("00:02 / 03:00", "▶ Playing"),
+				keys(" "),
+				see("⏸ Paused", "00:02 / 03:00"),
+				wait(time.Minute),
+				see("⏸ Paused", "00:02 / 03:00"),
+				check("the player kept its place", func(h *harness) error {
+					if pos := h.player.GetPosition(); pos != 2500*time.Millisecond {
+						return fmt.Errorf("player at %v, want 2.5s", pos)
+					}
+					return nil
+				}),
+				keys(" "),
+				see("▶ Playing", "00:02 / 03:00"),
+				wait(time.Second),
+				see("00:03 / 03:00"),
+				wait(time.Minute),
+				see("01:03 / 03:00"),
+			},
+		},
+		{
+			name: "skip a track that fails to decode",
+			setup: func(h *harness) {
+				h.player.setTrack(tracks[1], fakeTrack{loadErr: errFakeDecode})
+			},
+			steps: []step{
+				wait(3 * time.Second),
+				keys("right"),
+				see("Track 2 of 3", "■ Stopped", "Skipping 02.mp3: failed to decode audio: bad header", "(1 skipped)"),
+				wait(skipDelay),
+				see("Playing: Artist - 03", "Track 3 of 3", "▶ Playing", "(1 skipped)"),
+				wait(bannerDuration),
+				gone("Skipping 02.mp3"),
+				check("the skip stuck", func(h *harness) error {
+					if h.m.failed[tracks[1]] == nil {
+						return fmt.Errorf("02.mp3 not marked failed")
+					}
+					return nil
+				}),
+				// Coming round again it's tried again, in case the file was
+				// fixed, and skipped again
+				keys("right"),
+				see("Track 1 of 3"),
+				keys("right"),
+				see("Skipping 02.mp3", "(2 skipped)"),
+				wait(skipDelay),
+				see("Playing: Artist - 03", "Track 3 of 3", "▶ Playing"),
+			},
+		},
+		{
+			name: "take a note",
+			steps: []step{
+				wait(42 * time.Second),
+				keys("n"),
+				see("Saved to notes"),
+				check("the note is in the file", func(h *harness) error {
+					data, err := os.ReadFile(h.m.notesFile)
+					if err != nil {
+						return err
+					}
+					if !strings.Contains(string(data), "Artist -  - 01") || !strings.Contains(string(data), tracks[0]) {
+						return fmt.Errorf("notes file holds\n%s", data)
+					}
+					return nil
+				}),
+				wait(bannerDuration),
+				gone("Saved to notes"),
+				keys("n"),
+				see("Already noted"),
+				wait(bannerDuration),
+				gone("Already noted"),
+			},
+		},
+		{
+			name: "quit while playing",
+			setup: func(h *harness) {
+				h.m.EnableStatePersistence("/music/album", 0, 0)
+			},
+			steps: []step{
+				keys("right"),
+				wait(30 * time.Second),
+				see("Playing: Artist - 02", "00:29 / 03:00"),
+				keys("q"),
+				see("Saving…"),
+				check("the program quit with the player stopped", func(h *harness) error {
+					if !h.quit {
+						return fmt.Errorf("no quit message")
+					}
+					if h.player.playing {
+						return fmt.Errorf("the player still plays")
+					}
+					return nil
+				}),
+				check("the place was saved", func(h *harness) error {
+					st, err := loadPlaybackState("/music/album")
+					if err != nil || st == nil {
+						return fmt.Errorf("no state saved: %v", err)
+					}
+					if st.CurrentIndex != 1 || st.Position() < 29*time.Second {
+						return fmt.Errorf("saved track %d at %v, want 02.mp3 at 29s or so", st.CurrentIndex+1, st.Position())
+					}
+					return nil
+				}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, tracks...)
+			if tt.setup != nil {
+				tt.setup(h)
+			}
+			runScenario(t, h, tt.steps)
+		})
+	}
+}
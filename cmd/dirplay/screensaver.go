@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/punkscience/dirplay/internal/bigtext"
+)
+
+const (
+	// Redraw rate of the idle clock
+	screensaverRefresh = time.Second
+
+	// How often the idle view moves to avoid burning in
+	screensaverShift = 3 * time.Minute
+)
+
+// screensaverOffsets are the positions the idle view cycles through, as
+// column and line offsets from the centre
+var screensaverOffsets = [][2]int{{0, 0}, {3, 1}, {-3, 1}, {3, -1}, {-3, -1}, {0, 2}, {0, -2}}
+
+// idleMsg checks whether the player has gone idle, and redraws the clock
+// while it is
+type idleMsg struct{}
+
+// EnableScreensaver switches to the big clock after the given time without
+// a keypress. Zero disables it.
+func (m *PlayerModel) EnableScreensaver(after time.Duration) {
+	m.idleAfter = after
+	m.lastInput = m.now()
+}
+
+// idleCmd schedules the next idle check
+func (m *PlayerModel) idleCmd() tea.Cmd {
+	if m.idleAfter <= 0 {
+		return nil
+	}
+
+	wait := screensaverRefresh
+	if !m.idle {
+		wait = max(m.idleAfter-m.now().Sub(m.lastInput), screensaverRefresh)
+	}
+	return m.ticks.clock.Tick(wait, func(time.Time) tea.Msg {
+		return idleMsg{}
+	})
+}
+
+// updateIdle enters the screensaver once enough time has passed since the
+// last key
+func (m *PlayerModel) updateIdle() tea.Cmd {
+	if !m.idle && m.now().Sub(m.lastInput) >= m.idleAfter {
+		m.idle = true
+	}
+	return m.idleCmd()
+}
+
+// resetIdle restarts the idle timer on a keypress or click and reports
+// whether it only woke the screensaver, in which case the input shouldn't
+// do anything else
+func (m *PlayerModel) resetIdle() bool {
+	m.lastInput = m.now()
+	if m.idle {
+		m.idle = false
+		return true
+	}
+	return false
+}
+
+// viewScreensaver renders the big clock with the current track and a thin
+// progress strip, nudged around the screen every few minutes
+func (m *PlayerModel) viewScreensaver() string {
+	bigStyle, trackStyle := m.styles.Accent, m.styles.Dim
+
+	now := m.now()
+	lines := []string{bigStyle.Render(bigtext.Render(now.Format("15:04"))), ""}
+
+	track := m.title
+	if m.artist != "" && m.title != "" {
+		track = m.artist + " - " + m.title
+	}
+	if track != "" {
+		// Draw the track big too when it fits, otherwise in plain text
+		if bigtext.Width(track) <= m.width-8 {
+			lines = append(lines, trackStyle.Render(bigtext.Render(track)))
+		} else {
+			lines = append(lines, trackStyle.Render(track))
+		}
+		lines = append(lines, "")
+	}
+
+	const stripWidth = 40
+	filled := 0
+	if m.duration > 0 {
+		filled = min(int(float64(stripWidth)*float64(m.position)/float64(m.duration)), stripWidth)
+	}
+	lines = append(lines, trackStyle.Render(strings.Repeat("━", filled)+strings.Repeat("─", stripWidth-filled)))
+
+	content := lipgloss.JoinVertical(lipgloss.Center, lines...)
+	if m.width <= 0 || m.height <= 0 {
+		return content
+	}
+
+	offset := screensaverOffsets[int(now.Unix()/int64(screensaverShift.Seconds()))%len(screensaverOffsets)]
+	view := lipgloss.Place(m.width-6, m.height-4, lipgloss.Center, lipgloss.Center, content)
+	return lipgloss.NewStyle().
+		MarginLeft(3 + offset[0]).
+		MarginTop(2 + offset[1]).
+		Render(view)
+}
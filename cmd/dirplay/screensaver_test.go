@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/internal/bigtext"
+)
+
+// idle checks whether the screensaver is showing
+func idle(want bool) step {
+	return check("screensaver shown", func(h *harness) error {
+		if h.m.idle != want {
+			return errors.New("screensaver not in the expected state")
+		}
+		return nil
+	})
+}
+
+// bigClock checks the screen shows the time in block letters, line by
+// line since the view centres each
+func bigClock() step {
+	return check("big clock shown", func(h *harness) error {
+		view := h.m.View()
+		for _, line := range strings.Split(bigtext.Render(h.clock.now.Format("15:04")), "\n") {
+			if !strings.Contains(view, line) {
+				return errors.New("the time isn't on screen")
+			}
+		}
+		return nil
+	})
+}
+
+func TestScreensaver(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"comes on when idle", []step{
+			wait(9 * time.Minute), idle(false),
+			wait(time.Minute + screensaverRefresh), idle(true),
+			bigClock(),
+		}},
+		{"a key puts it off", []step{
+			keys("n"), wait(5 * time.Minute), keys("n"), wait(9 * time.Minute), idle(false),
+			wait(time.Minute + screensaverRefresh), idle(true),
+		}},
+		{"waking key does nothing else", []step{
+			wait(10*time.Minute + screensaverRefresh), idle(true),
+			keys(" "), idle(false),
+			check("still playing", func(h *harness) error {
+				if h.m.paused {
+					return errors.New("the key that woke the screensaver paused")
+				}
+				return nil
+			}),
+			keys(" "),
+			check("paused", func(h *harness) error {
+				if !h.m.paused {
+					return errors.New("the next key didn't pause")
+				}
+				return nil
+			}),
+		}},
+		{"comes on again", []step{
+			wait(10*time.Minute + screensaverRefresh), keys("x"), idle(false),
+			wait(10*time.Minute + screensaverRefresh), idle(true),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, album(10)...)
+			h.m.EnableScreensaver(10 * time.Minute)
+			runScenario(t, h, tt.steps)
+		})
+	}
+}
+
+// TestScreensaverShifts checks the idle view moves every few minutes
+func TestScreensaverShifts(t *testing.T) {
+	h := newHarness(t, album(20)...)
+	h.m.EnableScreensaver(time.Minute)
+	h.start()
+	h.advance(time.Minute + screensaverRefresh)
+
+	// where returns the line and column of the top left of the clock
+	where := func() [2]int {
+		for i, line := range strings.Split(h.m.View(), "\n") {
+			if col := strings.Index(line, "█"); col >= 0 {
+				return [2]int{i, len([]rune(line[:col]))}
+			}
+		}
+		t.Fatalf("no clock on screen:\n%s", h.m.View())
+		return [2]int{}
+	}
+
+	seen := map[[2]int]bool{}
+	for range len(screensaverOffsets) {
+		at := where()
+		h.advance(screensaverShift / 2)
+		if where() != at {
+			t.Errorf("the view moved after %v", screensaverShift/2)
+		}
+		seen[at] = true
+		h.advance(screensaverShift / 2)
+	}
+	if len(seen) != len(screensaverOffsets) {
+		t.Errorf("the view took %d places, want %d", len(seen), len(screensaverOffsets))
+	}
+}
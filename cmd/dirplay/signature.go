@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// Acoustic signatures for --dedupe=deep. The mono mix of the first
+// signatureLength of audio, after any leading silence, is split into
+// signatureFrame long frames and signatureBandCount octave bands. Each bit
+// records whether a band got louder than in the frame before. Comparing
+// energies rather than storing them makes the signature independent of
+// gain, and coarse bands and frames survive lossy re-encoding, while
+// different recordings agree on about half the bits.
+const (
+	signatureLength    = 30 * time.Second
+	signatureFrame     = 500 * time.Millisecond
+	signatureFrames    = int(signatureLength / signatureFrame)
+	signatureBandCount = 7
+	signatureBits      = (signatureFrames - 1) * signatureBandCount
+	signatureWords     = (signatureBits + 63) / 64
+
+	// Leading samples below signatureLeadIn, -40 dBFS, are skipped for up
+	// to signatureMaxLeadIn so rips with different padding or hiss line up.
+	// Tracks averaging below signatureSilence aren't fingerprinted.
+	signatureLeadIn    = 0.01
+	signatureMaxLeadIn = 10 * time.Second
+	signatureSilence   = 1e-3
+
+	// Signatures differing in at most this share of bits are duplicates.
+	// In synthetic trials, copies with gain changes, 8-bit quantization,
+	// hiss, low-passing and added leading silence stayed under 0.08, while
+	// unrelated tracks landed between 0.4 and 0.6. Copies cut to start at
+	// a different point don't line up and aren't caught.
+	duplicateDistance = 0.2
+)
+
+// signatureBands are the crossover frequencies between the bands in Hz.
+// Content above the last one is left out, that is where encoders differ.
+var signatureBands = [signatureBandCount]float64{150, 300, 600, 1200, 2400, 4800, 9600}
+
+// errNoSignature is returned for tracks too short or quiet to compare
+var errNoSignature = errors.New("too short or quiet to fingerprint")
+
+// signature is the acoustic fingerprint of a track
+type signature []uint64
+
+// distance returns the share of bits in which two signatures differ
+func (s signature) distance(other signature) float64 {
+	if len(s) != signatureWords || len(other) != signatureWords {
+		return 1
+	}
+
+	differ := 0
+	for i := range s {
+		differ += bits.OnesCount64(s[i] ^ other[i])
+	}
+	return float64(differ) / float64(signatureBits)
+}
+
+// computeSignature decodes the start of a track with codecs and
+// fingerprints it, giving up when ctx is cancelled. A panic of the decoder
+// on a malformed file is returned as an error.
+func computeSignature(ctx context.Context, codecs *codec.Registry, path string) (sig signature, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sig, err = nil, fmt.Errorf("%w: %v", player.ErrDecoderPanic, r)
+		}
+	}()
+
+	streamer, format, err := codecs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer streamer.Close()
+
+	// Chained one-pole low-pass filters; the difference between
+	// neighbouring outputs is the band between their cutoffs
+	rate := float64(format.SampleRate)
+	var coeffs, lows [signatureBandCount]float64
+	for i, cutoff := range signatureBands {
+		coeffs[i] = 1 - math.Exp(-2*math.Pi*cutoff/rate)
+	}
+
+	var energy [signatureFrames][signatureBandCount]float64
+	frameLen := format.SampleRate.N(signatureFrame)
+	leadIn := format.SampleRate.N(signatureMaxLeadIn)
+	frame, inFrame, skipped := 0, 0, 0
+	total := 0.0
+
+	buf := make([][2]float64, 4096)
+	for frame < signatureFrames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, ok := streamer.Stream(buf)
+		for _, sample := range buf[:n] {
+			x := (sample[0] + sample[1]) / 2
+			if frame == 0 && inFrame == 0 && skipped < leadIn && math.Abs(x) < signatureLeadIn {
+				skipped++
+				continue
+			}
+
+			prev := 0.0
+			for b, a := range coeffs {
+				lows[b] += a * (x - lows[b])
+				band := lows[b] - prev
+				energy[frame][b] += band * band
+				prev = lows[b]
+			}
+			total += x * x
+
+			inFrame++
+			if inFrame == frameLen {
+				frame++
+				inFrame = 0
+				if frame == signatureFrames {
+					break
+				}
+			}
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return nil, err
+	}
+
+	// Short tracks would be mostly padding, and silence matches silence
+	if frame < signatureFrames || total/float64(signatureFrames*frameLen) < signatureSilence*signatureSilence {
+		return nil, errNoSignature
+	}
+
+	sig = make(signature, signatureWords)
+	bit := 0
+	for f := 1; f < signatureFrames; f++ {
+		for b := range signatureBandCount {
+			if energy[f][b] > energy[f-1][b] {
+				sig[bit/64] |= 1 << (bit % 64)
+			}
+			bit++
+		}
+	}
+	return sig, nil
+}
+
+// cachedSignature is a stored signature and the file it was computed
+// from. Bits is empty for files that can't be fingerprinted, so they
+// aren't decoded again either.
+type cachedSignature struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Bits    signature `json:"bits,omitempty"`
+}
+
+// signatureCache keeps signatures between sessions in signatures.json, so
+// only new and changed files are decoded, with codecs
+type signatureCache struct {
+	mu      sync.Mutex
+	path    string
+	codecs  *codec.Registry
+	Version int                         `json:"version"`
+	Files   map[string]*cachedSignature `json:"files"`
+}
+
+// signatureMigrations upgrade older signature caches, see readVersioned
+var signatureMigrations = []migration{unversioned}
+
+// loadSignatureCache reads the stored signatures, starting empty if there
+// are none, to decode new files with codecs
+func loadSignatureCache(codecs *codec.Registry) (*signatureCache, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &signatureCache{
+		path:   filepath.Join(dir, "signatures.json"),
+		codecs: codecs,
+		Files:  make(map[string]*cachedSignature),
+	}
+
+	err = readVersioned(cache.path, signatureMigrations, cache)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cache.Files == nil {
+		cache.Files = make(map[string]*cachedSignature)
+	}
+	return cache, nil
+}
+
+// Signature returns the signature of path, computing and caching it unless
+// the file is unchanged since it was cached
+func (c *signatureCache) Signature(ctx context.Context, path string) (signature, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	cached, ok := c.Files[path]
+	c.mu.Unlock()
+	if ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		if cached.Bits == nil {
+			return nil, errNoSignature
+		}
+		return cached.Bits, nil
+	}
+
+	sig, err := computeSignature(ctx, c.codecs, path)
+	if err != nil && !errors.Is(err, errNoSignature) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.Files[path] = &cachedSignature{Size: info.Size(), ModTime: info.ModTime(), Bits: sig}
+	c.mu.Unlock()
+	return sig, err
+}
+
+// Save writes the signatures to disk via a temporary file
+func (c *signatureCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Version = len(signatureMigrations)
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.path, data)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// fixtureRate is the sample rate of the fixture recordings
+const fixtureRate = beep.SampleRate(22050)
+
+// fixtureCodec decodes .fix files into synthetic recordings. A file holds
+// a recording number, a length in seconds and the changes made to the
+// copy, e.g. "3 32 gain hiss": recordings of the same number are the same
+// music, a chord changing every quarter second.
+func fixtureCodec() *codec.Registry {
+	codecs := codec.New()
+	codecs.Register(".fix", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		defer rc.Close()
+		var recording, seconds int
+		if _, err := fmt.Fscan(rc, &recording, &seconds); err != nil {
+			return nil, beep.Format{}, err
+		}
+		samples := fixtureRecording(recording, seconds)
+		for {
+			var change string
+			if _, err := fmt.Fscan(rc, &change); err != nil {
+				break
+			}
+			samples = fixtureChange(samples, change)
+		}
+
+		format := beep.Format{SampleRate: fixtureRate, NumChannels: 2, Precision: 2}
+		buf := beep.NewBuffer(format)
+		buf.Append(&samplesStreamer{samples: samples})
+		return nopCloser{buf.Streamer(0, buf.Len())}, format, nil
+	})
+	return codecs
+}
+
+// fixtureRecording synthesizes seconds of recording, the same for the
+// same number
+func fixtureRecording(recording, seconds int) [][2]float64 {
+	if recording == 0 {
+		return make([][2]float64, fixtureRate.N(time.Duration(seconds)*time.Second))
+	}
+
+	rng := rand.New(rand.NewSource(int64(recording)))
+	chord := fixtureRate.N(250 * time.Millisecond)
+	samples := make([][2]float64, fixtureRate.N(time.Duration(seconds)*time.Second))
+	var freqs [3]float64
+	var amp float64
+	for i := range samples {
+		if i%chord == 0 {
+			for j := range freqs {
+				freqs[j] = 80 * math.Pow(2, rng.Float64()*6.5)
+			}
+			amp = 0.05 + 0.1*rng.Float64()
+		}
+		x := 0.0
+		for _, f := range freqs {
+			x += amp * math.Sin(2*math.Pi*f*float64(i)/float64(fixtureRate))
+		}
+		samples[i] = [2]float64{x, x}
+	}
+	return samples
+}
+
+// fixtureChange makes a copy of samples the way re-encoding or another rip
+// would change them
+func fixtureChange(samples [][2]float64, change string) [][2]float64 {
+	out := make([][2]float64, 0, len(samples))
+	switch change {
+	case "padded":
+		out = append(out, make([][2]float64, fixtureRate.N(2*time.Second))...)
+		return append(out, samples...)
+	case "clipped":
+		// The first few seconds are cut off, which isn't caught
+		return append(out, samples[fixtureRate.N(3*time.Second):]...)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	low := 0.0
+	for _, s := range samples {
+		x := s[0]
+		switch change {
+		case "gain":
+			x *= 0.5
+		case "quantized":
+			x = math.Round(x*127) / 127
+		case "hiss":
+			x += 0.005 * (2*rng.Float64() - 1)
+		case "lowpass":
+			low += (1 - math.Exp(-2*math.Pi*5000/float64(fixtureRate))) * (x - low)
+			x = low
+		}
+		out = append(out, [2]float64{x, x})
+	}
+	return out
+}
+
+// samplesStreamer streams samples once
+type samplesStreamer struct {
+	samples [][2]float64
+}
+
+func (s *samplesStreamer) Stream(samples [][2]float64) (int, bool) {
+	n := copy(samples, s.samples)
+	s.samples = s.samples[n:]
+	return n, n > 0
+}
+
+func (s *samplesStreamer) Err() error { return nil }
+
+// nopCloser makes a beep.StreamSeeker a beep.StreamSeekCloser
+type nopCloser struct {
+	beep.StreamSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// writeFixture writes a .fix file holding spec and returns its path
+func writeFixture(t *testing.T, dir, name, spec string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".fix")
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestSignatureFixtures compares labelled pairs of fixture recordings
+// against duplicateDistance: copies changed the way re-encodes are must
+// match, different recordings must not
+func TestSignatureFixtures(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"1 32", "1 32", true},
+		{"1 32", "1 32 gain", true},
+		{"1 32", "1 32 quantized", true},
+		{"1 32", "1 32 hiss", true},
+		{"1 32", "1 32 lowpass", true},
+		{"1 32", "1 32 padded", true},
+		{"1 32", "1 40", true},
+		{"2 32", "2 32 gain quantized hiss lowpass", true},
+		{"1 32", "2 32", false},
+		{"2 32", "3 32 gain", false},
+		{"3 32", "4 32 lowpass", false},
+		{"1 32", "5 32 padded", false},
+		{"1 40", "1 40 clipped", false},
+	}
+
+	dir := t.TempDir()
+	codecs := fixtureCodec()
+	for i, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, err := computeSignature(context.Background(), codecs, writeFixture(t, dir, fmt.Sprint(i, "a"), tt.a))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := computeSignature(context.Background(), codecs, writeFixture(t, dir, fmt.Sprint(i, "b"), tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := a.distance(b); (d <= duplicateDistance) != tt.same {
+				t.Errorf("distance = %.2f, want duplicates %v at %.2f", d, tt.same, duplicateDistance)
+			}
+		})
+	}
+}
+
+func TestSignatureUnusable(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want error
+	}{
+		{"too short", "1 20", errNoSignature},
+		{"silent", "0 40", errNoSignature},
+		{"broken file", "one", nil},
+	}
+	dir := t.TempDir()
+	codecs := fixtureCodec()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := computeSignature(context.Background(), codecs, writeFixture(t, dir, fmt.Sprint(i), tt.spec))
+			if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("computeSignature() = %v, want %v", err, tt.want)
+			}
+			if sig != nil {
+				t.Errorf("signature = %v, want none", sig)
+			}
+		})
+	}
+}
+
+func TestSignatureDistance(t *testing.T) {
+	full := make(signature, signatureWords)
+	for i := range full {
+		full[i] = math.MaxUint64
+	}
+	full[len(full)-1] >>= 64*signatureWords - signatureBits
+
+	oneBit := make(signature, signatureWords)
+	oneBit[0] = 1
+
+	tests := []struct {
+		name string
+		a, b signature
+		want float64
+	}{
+		{"same", full, full, 0},
+		{"opposite", full, make(signature, signatureWords), 1},
+		{"one bit", oneBit, make(signature, signatureWords), 1 / float64(signatureBits)},
+		{"missing", full, nil, 1},
+		{"wrong length", make(signature, 1), make(signature, 1), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.distance(tt.b); got != tt.want {
+				t.Errorf("distance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSignatureCache checks signatures are stored, computed again only
+// for changed files, and read back in the next session
+func TestSignatureCache(t *testing.T) {
+	useTempConfig(t)
+	codecs := fixtureCodec()
+	dir := t.TempDir()
+	track := writeFixture(t, dir, "track", "1 32")
+	short := writeFixture(t, dir, "short", "1 5")
+
+	cache, err := loadSignatureCache(codecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := cache.Signature(context.Background(), track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Signature(context.Background(), short); !errors.Is(err, errNoSignature) {
+		t.Fatalf("Signature() of a short track = %v, want %v", err, errNoSignature)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached signatures are used whatever the file holds now, as long as
+	// its size and time are unchanged
+	info, err := os.Stat(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(track, []byte("2 32"), 0o644)
+	os.Chtimes(track, info.ModTime(), info.ModTime())
+
+	cache, err = loadSignatureCache(codecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		modTime time.Time
+		same    bool
+		err     error
+	}{
+		{"unchanged", track, info.ModTime(), true, nil},
+		{"unusable unchanged", short, time.Time{}, false, errNoSignature},
+		{"changed", track, info.ModTime().Add(time.Second), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.modTime.IsZero() {
+				os.Chtimes(tt.path, tt.modTime, tt.modTime)
+			}
+			sig, err := cache.Signature(context.Background(), tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Signature() = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && (sig.distance(first) == 0) != tt.same {
+				t.Errorf("got the cached signature %v, want %v", !tt.same, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// Defaults of --silence-threshold and --silence-min
+const (
+	defaultSilenceThreshold = -50.0
+	defaultSilenceMin       = 3 * time.Second
+)
+
+// validateSilence checks --silence-threshold and --silence-min
+func validateSilence(threshold float64, min time.Duration) error {
+	if threshold >= 0 || threshold < -120 {
+		return fmt.Errorf("invalid --silence-threshold %g (want a level in dBFS from -120 to below 0)", threshold)
+	}
+	if min <= 0 {
+		return fmt.Errorf("invalid --silence-min %s (want a positive duration)", min)
+	}
+	return nil
+}
+
+// EnableSkipSilence skips silence at the ends of tracks, see
+// player.AudioPlayer.SetSkipSilence
+func (m *PlayerModel) EnableSkipSilence(config player.SilenceConfig) {
+	m.player.SetSkipSilence(config)
+}
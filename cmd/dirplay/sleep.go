@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sleepPresets are the timer lengths "t" cycles through, after which it
+// switches the timer off
+var sleepPresets = []time.Duration{15 * time.Minute, 30 * time.Minute, 60 * time.Minute, 90 * time.Minute}
+
+// The fade out when the timer expires, in sleepFadeSteps steps over
+// sleepFade
+const (
+	sleepFade      = 10 * time.Second
+	sleepFadeSteps = 40
+)
+
+// sleepTimer stops playback at a set time. id invalidates the ticks of a
+// timer that was changed or cancelled.
+type sleepTimer struct {
+	length time.Duration
+	at     time.Time
+	quit   bool
+	fading bool
+	id     int
+}
+
+// sleepTickMsg advances the sleep timer countdown, or the fade once it has
+// expired
+type sleepTickMsg struct {
+	id int
+}
+
+// EnableSleep starts a sleep timer of the given length. With quit set,
+// dirplay quits when it expires instead of pausing.
+func (m *PlayerModel) EnableSleep(length time.Duration, quit bool) {
+	m.sleep.quit = quit
+	if length > 0 {
+		m.sleep.length = length
+		m.sleep.at = m.now().Add(length)
+	}
+}
+
+// setSleep restarts the timer with a new length, zero switching it off.
+// A fade in progress is called off.
+func (m *PlayerModel) setSleep(length time.Duration) tea.Cmd {
+	if m.sleep.fading {
+		m.player.SetGain(m.volume)
+	}
+
+	m.sleep.id++
+	m.sleep.fading = false
+	m.sleep.length = length
+	m.sleep.at = time.Time{}
+	if length <= 0 {
+		return m.showBanner("Sleep timer off")
+	}
+
+	m.sleep.at = m.now().Add(length)
+	return tea.Batch(
+		m.showBanner(fmt.Sprintf("Sleeping in %d minutes", int(length/time.Minute))),
+		m.sleepTick(),
+	)
+}
+
+// cycleSleep moves the timer to the next preset longer than the current
+// length, or off after the longest
+func (m *PlayerModel) cycleSleep() tea.Cmd {
+	for _, preset := range sleepPresets {
+		if preset > m.sleep.length {
+			return m.setSleep(preset)
+		}
+	}
+	return m.setSleep(0)
+}
+
+// sleepTick schedules the next countdown update, every second while
+// counting down and every fade step while fading
+func (m *PlayerModel) sleepTick() tea.Cmd {
+	if m.sleep.at.IsZero() {
+		return nil
+	}
+
+	wait := time.Second
+	if m.sleep.fading {
+		wait = sleepFade / sleepFadeSteps
+	}
+	id := m.sleep.id
+	return m.ticks.clock.Tick(wait, func(time.Time) tea.Msg {
+		return sleepTickMsg{id: id}
+	})
+}
+
+// updateSleep fades the volume out once the timer expires, then pauses,
+// or quits with --sleep-quit. The level is back to the set volume for
+// whenever playback resumes.
+func (m *PlayerModel) updateSleep(msg sleepTickMsg) tea.Cmd {
+	if msg.id != m.sleep.id || m.sleep.at.IsZero() {
+		return nil
+	}
+
+	past := m.now().Sub(m.sleep.at)
+	if past < 0 {
+		return m.sleepTick()
+	}
+
+	m.sleep.fading = m.playing && !m.paused
+	if m.sleep.fading && past < sleepFade {
+		m.player.SetGain(m.volume * (1 - float64(past)/float64(sleepFade)))
+		return m.sleepTick()
+	}
+
+	m.sleep.fading = false
+	m.sleep.length = 0
+	m.sleep.at = time.Time{}
+	if m.sleep.quit {
+		return m.quit()
+	}
+	if m.playing && !m.paused {
+		m.togglePause()
+	}
+	m.player.SetGain(m.volume)
+	return m.showBanner("Sleep timer expired, SPACE resumes")
+}
+
+// sleepLabel returns the countdown shown in the status line, or ""
+func (m *PlayerModel) sleepLabel() string {
+	switch {
+	case m.sleep.at.IsZero():
+		return ""
+	case m.sleep.fading:
+		return "sleeping…"
+	}
+	return "sleep in " + formatDuration(m.sleep.at.Sub(m.now()).Round(time.Second))
+}
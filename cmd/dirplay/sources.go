@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/playlist"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// scanConfig returns the scan options given on the command line, finding
+// the files codecs plays
+func scanConfig(codecs *codec.Registry) scan.Config {
+	return scan.Config{
+		Codecs:         codecs,
+		FollowSymlinks: followSymlinks,
+		MaxDepth:       maxDepth,
+		Excludes:       excludePatterns,
+		Sandbox:        sandboxPlaylists,
+		AllowedRoots:   allowedRoots,
+		AllowStreams:   allowStreams,
+		NewerThan:      newerThan,
+		ModTimes:       sortMode == playlist.SortMTime || sortMode == playlist.SortMTimeDesc,
+		NoIgnore:       noIgnore,
+		ProbeWorkers:   probeWorkers,
+	}
+}
+
+// sourcesKey identifies a set of command line arguments in the state file.
+// A single directory keeps using its absolute path.
+func sourcesKey(args []string) string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = arg
+		if abs, err := filepath.Abs(arg); err == nil {
+			keys[i] = abs
+		}
+	}
+	return strings.Join(keys, string(os.PathListSeparator))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// speedStep is what "[" and "]" change the speed by
+const speedStep = 0.25
+
+// changeSpeed steps the playback speed by delta, zero resetting it
+func (m *PlayerModel) changeSpeed(delta float64) tea.Cmd {
+	speed := 1.0
+	if delta != 0 {
+		speed = min(max(m.speed+delta, player.MinSpeed), player.MaxSpeed)
+	}
+	if speed == m.speed {
+		return nil
+	}
+
+	m.speed = speed
+	m.player.SetSpeed(speed)
+	return m.showBanner(fmt.Sprintf("Speed %s", speedName(speed)))
+}
+
+// speedName formats a speed such as 1.25×
+func speedName(speed float64) string {
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "×"
+}
+
+// speedLabel returns the speed shown in the status line, or "" at normal
+// speed
+func (m *PlayerModel) speedLabel() string {
+	if m.speed == 1 {
+		return ""
+	}
+	return speedName(m.speed)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startChoice is the track --start-at or --start-track asks playback to
+// begin with. It is picked once the scan is done, from the playlist as
+// shuffled or sorted, so nothing plays before then.
+type startChoice struct {
+	index int    // 1-based, 0 when not given
+	match string // path or tag text, as with --filter
+}
+
+// chosen reports whether a start track was asked for
+func (c startChoice) chosen() bool {
+	return c.index > 0 || c.match != ""
+}
+
+// validateStart checks --start-at and --start-track
+func validateStart(index int, match string, weighted bool) error {
+	switch {
+	case index < 0:
+		return fmt.Errorf("invalid --start-at %d (want a playlist position from 1)", index)
+	case (index > 0 || match != "") && weighted:
+		return fmt.Errorf("--weights picks every track at random, it can't be combined with --start-at or --start-track")
+	}
+	return nil
+}
+
+// SetStart begins playback at the index-th track of the playlist, or the
+// first one whose path or known tags contain match
+func (m *PlayerModel) SetStart(index int, match string) {
+	m.start = startChoice{index: index, match: match}
+}
+
+// StartPaused loads the first track without playing it, see prepared
+func (m *PlayerModel) StartPaused() {
+	m.startPaused = true
+}
+
+// pickStart returns the playlist index of the start track, or an error
+// naming what couldn't be found
+func (m *PlayerModel) pickStart() (int, error) {
+	if m.start.index > 0 {
+		if m.start.index > len(m.playlist) {
+			return -1, fmt.Errorf("--start-at %d is past the end of the playlist, which has %d tracks", m.start.index, len(m.playlist))
+		}
+		return m.start.index - 1, nil
+	}
+	for index, id := range m.playlist {
+		path := m.tracks.Path(id)
+		if matchesFilter(path, m.knownTags[path], m.start.match) {
+			return index, nil
+		}
+	}
+	return -1, fmt.Errorf("no track matches --start-track %q", m.start.match)
+}
+
+// beginAtStart plays the start track once the scan is done, or quits
+// with an error when there is none
+func (m *PlayerModel) beginAtStart() tea.Cmd {
+	index, err := m.pickStart()
+	m.start = startChoice{}
+	if err != nil {
+		m.scanErr = err
+		return m.quit()
+	}
+	m.currentIndex = index
+	return m.loadCurrentTrack()
+}
+
+// startPrepared begins playing the track loaded paused with --paused,
+// which the player hasn't started yet
+func (m *PlayerModel) startPrepared() tea.Cmd {
+	m.prepared = false
+	if err := m.player.Play(); err != nil {
+		id, path := m.current, m.currentTrack()
+		return func() tea.Msg {
+			return playErrorMsg{id: id, path: path, err: err}
+		}
+	}
+	m.paused = false
+	return tea.Batch(m.tickCmd(), m.saveStateTick(), m.preloadNext())
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How often playback state is written while playing, so a crash loses at
+// most this much progress
+const stateSaveInterval = 30 * time.Second
+
+// playbackState is what gets restored when dirplay is relaunched on the
+// same music directory
+type playbackState struct {
+	Playlist     []string         `json:"playlist"`
+	CurrentIndex int              `json:"current_index"`
+	PositionMS   int64            `json:"position_ms"`
+	BookmarksMS  map[string]int64 `json:"bookmarks_ms,omitempty"`
+	SavedAt      time.Time        `json:"saved_at"`
+}
+
+// stateFile is the on-disk layout, holding one playbackState per music
+// directory keyed by its absolute path
+type stateFile struct {
+	Version     int                      `json:"version"`
+	Directories map[string]playbackState `json:"directories"`
+}
+
+// stateMigrations upgrade older state files, see readVersioned
+var stateMigrations = []migration{unversioned}
+
+// stateMu serializes the read-modify-write of the state file between the
+// periodic save and the save on quit
+var stateMu sync.Mutex
+
+// configDir returns dirplay's configuration directory, creating it if needed
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, "dirplay")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// cacheDir returns dirplay's cache directory, for data it can rebuild,
+// creating it if needed
+func cacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, "dirplay")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// statePath returns the location of the state file
+func statePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// readStateFile reads the whole state file, returning an empty one if it
+// doesn't exist yet
+func readStateFile(path string) (*stateFile, error) {
+	sf := &stateFile{Directories: make(map[string]playbackState)}
+
+	err := readVersioned(path, stateMigrations, sf)
+	if errors.Is(err, os.ErrNotExist) {
+		return sf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sf.Directories == nil {
+		sf.Directories = make(map[string]playbackState)
+	}
+	return sf, nil
+}
+
+// loadPlaybackState returns the saved state for a music directory, or nil
+// if there is none
+func loadPlaybackState(key string) (*playbackState, error) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	path, err := statePath()
+	if err != nil {
+		return nil, err
+	}
+
+	sf, err := readStateFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	st, ok := sf.Directories[key]
+	if !ok || len(st.Playlist) == 0 {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+// savePlaybackState stores the state for a music directory. The file is
+// written to a temporary file and renamed so a crash can't truncate it.
+func savePlaybackState(key string, st playbackState) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+
+	sf, err := readStateFile(path)
+	if errors.Is(err, errNewerFormat) {
+		return err
+	}
+	if err != nil {
+		// Don't let a corrupt file block saving fresh state
+		sf = &stateFile{Directories: make(map[string]playbackState)}
+	}
+
+	st.SavedAt = time.Now()
+	sf.Version = len(stateMigrations)
+	sf.Directories[key] = st
+
+	data, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// Position returns the saved position within the current track
+func (st *playbackState) Position() time.Duration {
+	return time.Duration(st.PositionMS) * time.Millisecond
+}
+
+// Bookmarks returns the saved positions of long tracks left part way
+func (st *playbackState) Bookmarks() map[string]time.Duration {
+	bookmarks := make(map[string]time.Duration, len(st.BookmarksMS))
+	for path, ms := range st.BookmarksMS {
+		bookmarks[path] = time.Duration(ms) * time.Millisecond
+	}
+	return bookmarks
+}
+
+// restore returns the saved playlist, keeping only the paths keep accepts,
+// and the index of the saved current track in it. Files that disappeared
+// since are dropped once the background scan finishes.
+func (st *playbackState) restore(keep func(path string) bool) (playlist []string, index int) {
+	var current string
+	if st.CurrentIndex >= 0 && st.CurrentIndex < len(st.Playlist) {
+		current = st.Playlist[st.CurrentIndex]
+	}
+
+	known := make(map[string]bool, len(st.Playlist))
+	for _, path := range st.Playlist {
+		if known[path] || !keep(path) {
+			continue
+		}
+		known[path] = true
+		if path == current {
+			index = len(playlist)
+		}
+		playlist = append(playlist, path)
+	}
+	return playlist, index
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// A track counts as played once it played to playThreshold of its
+// length. The report lists the statsTop most played tracks and artists.
+// Files that are gone and weren't played for statsAgeOut are dropped from
+// the stats when they are saved, so a moved file's old entry ages out.
+const (
+	playThreshold = 0.5
+	statsTop      = 20
+	statsAgeOut   = 180 * 24 * time.Hour
+)
+
+// trackRecord counts the plays of a track and how long they lasted.
+// Artist is the tag as the track last played, for files that aren't in
+// the tag index.
+type trackRecord struct {
+	Plays      int       `json:"plays"`
+	ListenedMS int64     `json:"listened_ms"`
+	Artist     string    `json:"artist,omitempty"`
+	LastPlayed time.Time `json:"last_played"`
+}
+
+// trackStats keeps play counts in stats.json in the config directory, by
+// absolute file path
+type trackStats struct {
+	mu      sync.Mutex
+	path    string
+	dirty   bool
+	Version int                     `json:"version"`
+	Tracks  map[string]*trackRecord `json:"tracks"`
+}
+
+// trackStatsMigrations upgrade older stats files, see readVersioned. The
+// file has had one format so far.
+var trackStatsMigrations []migration
+
+// sessionStats counts what this session played, for the "S" overlay.
+// hearing is set while the current track started playing and isn't
+// counted yet.
+type sessionStats struct {
+	played   int
+	skipped  int
+	listened time.Duration
+	hearing  bool
+}
+
+// statsRow is a track or an artist in the report
+type statsRow struct {
+	name     string
+	plays    int
+	listened time.Duration
+}
+
+// statsReport is what the "S" overlay and "dirplay stats" show
+type statsReport struct {
+	tracks   []statsRow
+	artists  []statsRow
+	plays    int
+	listened time.Duration
+}
+
+// loadTrackStats reads the play counts, starting empty if there are none
+func loadTrackStats() (*trackStats, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &trackStats{
+		path:   filepath.Join(dir, "stats.json"),
+		Tracks: make(map[string]*trackRecord),
+	}
+
+	err = readVersioned(stats.path, trackStatsMigrations, stats)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stats.Tracks == nil {
+		stats.Tracks = make(map[string]*trackRecord)
+	}
+	return stats, nil
+}
+
+// countsAsPlay reports whether listening this long to a track of length
+// is a play. Tracks of unknown length, such as live streams, never are.
+func countsAsPlay(listened, length time.Duration) bool {
+	return length > 0 && float64(listened) >= float64(length)*playThreshold
+}
+
+// Record counts a play of the track at path
+func (s *trackStats) Record(path, artist string, listened time.Duration, at time.Time) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.Tracks[path]
+	if !ok {
+		rec = &trackRecord{}
+		s.Tracks[path] = rec
+	}
+	rec.Plays++
+	rec.ListenedMS += listened.Milliseconds()
+	if artist != "" {
+		rec.Artist = artist
+	}
+	rec.LastPlayed = at
+	s.dirty = true
+}
+
+// changed reports whether a play was recorded since the last call
+func (s *trackStats) changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.dirty
+	s.dirty = false
+	return changed
+}
+
+// Save writes the stats to disk via a temporary file
+func (s *trackStats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path, rec := range s.Tracks {
+		if time.Since(rec.LastPlayed) > statsAgeOut {
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				delete(s.Tracks, path)
+			}
+		}
+	}
+
+	s.Version = len(trackStatsMigrations)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+// Report adds up the plays of the tracks keep accepts, by track and by the
+// artist artistOf gives, falling back to the artist recorded with the
+// plays
+func (s *trackStats) Report(keep func(path string) bool, artistOf func(path string) string) statsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var report statsReport
+	artists := make(map[string]*statsRow)
+	for path, rec := range s.Tracks {
+		if rec.Plays == 0 || !keep(path) {
+			continue
+		}
+		listened := time.Duration(rec.ListenedMS) * time.Millisecond
+		report.tracks = append(report.tracks, statsRow{name: path, plays: rec.Plays, listened: listened})
+		report.plays += rec.Plays
+		report.listened += listened
+
+		artist := artistOf(path)
+		if artist == "" {
+			artist = rec.Artist
+		}
+		if artist == "" {
+			artist = "Unknown artist"
+		}
+		row, ok := artists[artist]
+		if !ok {
+			row = &statsRow{name: artist}
+			artists[artist] = row
+		}
+		row.plays += rec.Plays
+		row.listened += listened
+	}
+	for _, row := range artists {
+		report.artists = append(report.artists, *row)
+	}
+
+	report.tracks = topStats(report.tracks)
+	report.artists = topStats(report.artists)
+	return report
+}
+
+// topStats sorts rows by plays, then time listened and name, and keeps
+// the first statsTop
+func topStats(rows []statsRow) []statsRow {
+	slices.SortFunc(rows, func(a, b statsRow) int {
+		if c := cmp.Compare(b.plays, a.plays); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.listened, a.listened); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+	return rows[:min(len(rows), statsTop)]
+}
+
+// lines returns the report as text, naming tracks with name
+func (r statsReport) lines(name func(path string) string) (tracks, artists []string) {
+	for i, row := range r.tracks {
+		tracks = append(tracks, fmt.Sprintf("%3d. %4d×  %7s  %s", i+1, row.plays, formatETA(row.listened), name(row.name)))
+	}
+	for i, row := range r.artists {
+		artists = append(artists, fmt.Sprintf("%3d. %4d×  %7s  %s", i+1, row.plays, formatETA(row.listened), row.name))
+	}
+	return tracks, artists
+}
+
+// write prints the report for "dirplay stats"
+func (r statsReport) write(w io.Writer) {
+	if len(r.tracks) == 0 {
+		fmt.Fprintln(w, "Nothing played yet")
+		return
+	}
+	tracks, artists := r.lines(func(path string) string { return path })
+	fmt.Fprintf(w, "%d plays, %s listened\n\nMost played tracks\n", r.plays, formatETA(r.listened))
+	for _, line := range tracks {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "\nMost played artists")
+	for _, line := range artists {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// EnableTrackStats counts plays in stats, shown with "S"
+func (m *PlayerModel) EnableTrackStats(stats *trackStats) {
+	m.trackStats = stats
+}
+
+// endPlayStats counts the current track towards the session and the
+// stats as playback moves on from it. Previews don't count.
+func (m *PlayerModel) endPlayStats() {
+	if !m.session.hearing || m.current == noTrack || m.previewing() {
+		m.session.hearing = false
+		return
+	}
+	m.session.hearing = false
+
+	listened := max(m.position-m.preview.from, 0)
+	m.session.listened += listened
+	switch {
+	case countsAsPlay(listened, m.duration):
+		m.session.played++
+		if m.trackStats != nil && !scan.IsStreamURL(m.currentTrack()) {
+			m.trackStats.Record(m.currentTrack(), m.artist, listened, m.now())
+		}
+	case m.duration > 0:
+		m.session.skipped++
+	}
+}
+
+// saveTrackStats writes the stats in the background after a play was
+// recorded
+func (m *PlayerModel) saveTrackStats() tea.Cmd {
+	if m.trackStats == nil || !m.trackStats.changed() {
+		return nil
+	}
+	stats, work := m.trackStats, m.work
+	return work.Cmd(func(context.Context) tea.Msg {
+		if err := stats.Save(); err != nil {
+			work.Failed("save play counts", err)
+		}
+		return nil
+	})
+}
+
+// viewStats renders the "S" overlay in place of the player view: the
+// most played tracks and artists of the library being played, and what
+// this session played
+func (m *PlayerModel) viewStats() string {
+	headerStyle, categoryStyle, rowStyle, dimStyle := m.styles.Header, m.styles.Category, m.styles.Text, m.styles.Dim
+	width := m.viewWidth()
+
+	var content strings.Builder
+	content.WriteString(headerStyle.Render("Stats"))
+	content.WriteString("\n\n")
+
+	session := m.session
+	content.WriteString(categoryStyle.Render("This session"))
+	content.WriteString("\n")
+	content.WriteString(rowStyle.Render(fitText(fmt.Sprintf("  %d tracks played, %d skipped, %s listened", session.played, session.skipped, formatETA(session.listened)), width)))
+	content.WriteString("\n")
+
+	if m.trackStats == nil {
+		content.WriteString("\n")
+		content.WriteString(dimStyle.Render("Play counts aren't kept, see the warning at startup. Press any key to close."))
+		return content.String()
+	}
+
+	// The stats know tracks by absolute path, the playlist as scanned
+	scanned := make(map[string]string, len(m.fullPlaylist))
+	for _, path := range m.tracks.Paths(m.fullPlaylist) {
+		if abs, err := filepath.Abs(path); err == nil {
+			scanned[abs] = path
+		}
+	}
+	report := m.trackStats.Report(
+		func(path string) bool { return scanned[path] != "" },
+		func(path string) string { return m.knownTags[scanned[path]].artist })
+	tracks, artists := report.lines(func(path string) string { return m.paneEntryName(scanned[path]) })
+
+	// Both lists share the rows there are, tracks first
+	rows := max(m.paneHeight()-6, 2)
+	tracks = tracks[:min(len(tracks), rows-rows/2)]
+	artists = artists[:min(len(artists), rows/2)]
+
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"Most played tracks", tracks}, {"Most played artists", artists}} {
+		content.WriteString("\n")
+		content.WriteString(categoryStyle.Render(section.title))
+		content.WriteString("\n")
+		if len(section.lines) == 0 {
+			content.WriteString(dimStyle.Render("  Nothing played to half way yet"))
+			content.WriteString("\n")
+		}
+		for _, line := range section.lines {
+			content.WriteString(rowStyle.Render(fitText(line, width)))
+			content.WriteString("\n")
+		}
+	}
+
+	content.WriteString("\n")
+	content.WriteString(dimStyle.Render("Press any key to close."))
+	return content.String()
+}
+
+// newStatsCommand creates the "stats" command, which prints the report of
+// the "S" overlay
+func newStatsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats [directory]...",
+		Short: "Print the most played tracks and artists",
+		Long: "stats prints the tracks and artists played most, by how often they were\n" +
+			"played at least half way, and how long. Given directories, only tracks\n" +
+			"inside them count.",
+		Example:      "  dirplay stats\n  dirplay stats ~/Music/Jazz",
+		SilenceUsage: true,
+		RunE:         runStats,
+	}
+}
+
+// runStats prints the report, artists taken from the tag index where it
+// knows the files
+func runStats(cmd *cobra.Command, args []string) error {
+	stats, err := loadTrackStats()
+	if err != nil {
+		return err
+	}
+	index, err := loadTagIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring tag index: %v\n", err)
+		index = nil
+	}
+
+	keep := func(path string) bool {
+		if len(args) == 0 {
+			return true
+		}
+		for _, dir := range args {
+			if library.Within(path, dir) {
+				return true
+			}
+		}
+		return false
+	}
+	artistOf := func(path string) string {
+		if index == nil {
+			return ""
+		}
+		if entry := index.Files[path]; entry != nil {
+			return entry.Artist
+		}
+		return ""
+	}
+	stats.Report(keep, artistOf).write(cmd.OutOrStdout())
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountsAsPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		listened time.Duration
+		length   time.Duration
+		want     bool
+	}{
+		{"exactly half", 90 * time.Second, 3 * time.Minute, true},
+		{"just under half", 90*time.Second - time.Millisecond, 3 * time.Minute, false},
+		{"all of it", 3 * time.Minute, 3 * time.Minute, true},
+		{"more than its length", 4 * time.Minute, 3 * time.Minute, true},
+		{"none of it", 0, 3 * time.Minute, false},
+		{"odd length half", 500 * time.Millisecond, time.Second + time.Nanosecond, false},
+		{"unknown length", time.Hour, 0, false},
+		{"live stream", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countsAsPlay(tt.listened, tt.length); got != tt.want {
+				t.Errorf("countsAsPlay(%v, %v) = %v, want %v", tt.listened, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackStatsReportArtists(t *testing.T) {
+	stats := &trackStats{Tracks: make(map[string]*trackRecord)}
+	at := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+	plays := []struct {
+		path   string
+		artist string
+		times  int
+	}{
+		{"/music/a/1.mp3", "Recorded A", 3},
+		{"/music/a/2.mp3", "", 2},
+		{"/music/b/1.mp3", "Recorded B", 1},
+		{"/music/c/1.mp3", "", 4},
+		{"/elsewhere/1.mp3", "Recorded A", 10},
+	}
+	for _, p := range plays {
+		for range p.times {
+			stats.Record(p.path, p.artist, time.Minute, at)
+		}
+	}
+
+	// The tag index knows the first two tracks, both by Artist A
+	indexed := map[string]string{"/music/a/1.mp3": "Artist A", "/music/a/2.mp3": "Artist A"}
+	report := stats.Report(
+		func(path string) bool { return strings.HasPrefix(path, "/music/") },
+		func(path string) string { return indexed[path] })
+
+	if report.plays != 10 {
+		t.Errorf("plays = %d, want 10", report.plays)
+	}
+	if report.listened != 10*time.Minute {
+		t.Errorf("listened = %v, want 10m", report.listened)
+	}
+	want := []statsRow{
+		{name: "Artist A", plays: 5, listened: 5 * time.Minute},
+		{name: "Unknown artist", plays: 4, listened: 4 * time.Minute},
+		{name: "Recorded B", plays: 1, listened: time.Minute},
+	}
+	if len(report.artists) != len(want) {
+		t.Fatalf("artists = %+v, want %+v", report.artists, want)
+	}
+	for i := range want {
+		if report.artists[i] != want[i] {
+			t.Errorf("artists[%d] = %+v, want %+v", i, report.artists[i], want[i])
+		}
+	}
+	if len(report.tracks) != 4 || report.tracks[0].name != "/music/c/1.mp3" {
+		t.Errorf("tracks = %+v, want the 4 under /music, most played first", report.tracks)
+	}
+}
+
+func TestTopStatsOrderAndLimit(t *testing.T) {
+	var rows []statsRow
+	for i := range statsTop + 5 {
+		rows = append(rows, statsRow{name: string(rune('a' + i)), plays: i % 3, listened: time.Duration(i) * time.Second})
+	}
+	rows = append(rows, statsRow{name: "tie-b", plays: 9}, statsRow{name: "tie-a", plays: 9})
+
+	top := topStats(rows)
+	if len(top) != statsTop {
+		t.Fatalf("topStats kept %d rows, want %d", len(top), statsTop)
+	}
+	if top[0].name != "tie-a" || top[1].name != "tie-b" {
+		t.Errorf("ties sort by name: got %s, %s first", top[0].name, top[1].name)
+	}
+	for i := 1; i < len(top); i++ {
+		a, b := top[i-1], top[i]
+		if a.plays < b.plays || a.plays == b.plays && a.listened < b.listened {
+			t.Errorf("rows %d and %d out of order: %+v, %+v", i-1, i, a, b)
+		}
+	}
+}
+
+func TestTrackStatsSaveLoad(t *testing.T) {
+	useTempConfig(t)
+	stats, err := loadTrackStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/song.mp3"
+	at := time.Now().Truncate(time.Second)
+	stats.Record(path, "Artist", 2*time.Minute, at)
+	if !stats.changed() {
+		t.Error("changed() = false after a play was recorded")
+	}
+	if stats.changed() {
+		t.Error("changed() = true twice for one play")
+	}
+	if err := stats.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadTrackStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := loaded.Tracks[path]
+	if rec == nil {
+		t.Fatalf("%s missing after loading, have %v", path, loaded.Tracks)
+	}
+	if rec.Plays != 1 || rec.ListenedMS != 120000 || rec.Artist != "Artist" || !rec.LastPlayed.Equal(at) {
+		t.Errorf("loaded %+v, want 1 play of 2m by Artist at %v", rec, at)
+	}
+}
+
+// TestModelPlayStats counts a track left past half way as a play and one
+// left before as a skip, whether playback moves on, goes back or quits
+func TestModelPlayStats(t *testing.T) {
+	tracks := album(3)
+	h := newHarness(t, tracks...)
+	stats, err := loadTrackStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.m.EnableTrackStats(stats)
+	h.start()
+
+	h.advance(100 * time.Second)
+	h.press("right")
+	h.advance(2 * time.Second)
+	h.press("left")
+	if h.playing() != "01.mp3" {
+		t.Fatalf("previous went to %s, want back to 01.mp3", h.playing())
+	}
+	h.advance(100 * time.Second)
+	leftAt := h.clock.now
+	h.press("right")
+
+	rec := stats.Tracks[tracks[0]]
+	if rec == nil || rec.Plays != 2 || rec.ListenedMS != 2*(99*time.Second+tickEndSlack).Milliseconds() || !rec.LastPlayed.Equal(leftAt) {
+		t.Errorf("01.mp3 recorded as %+v, want 2 plays of 99 s, the last at %v", rec, leftAt)
+	}
+	if rec := stats.Tracks[tracks[1]]; rec != nil {
+		t.Errorf("02.mp3 recorded as %+v after 2 s of 3 minutes, want no play", rec)
+	}
+
+	h.advance(95 * time.Second)
+	h.press("q")
+	if h.m.session.played != 3 || h.m.session.skipped != 1 {
+		t.Errorf("session played %d and skipped %d, want 3 and 1", h.m.session.played, h.m.session.skipped)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// When storageMisses tracks in a row fail to open because their files
+// are gone, and their music directory is too, playback waits for it,
+// looking every storagePoll
+const (
+	storageMisses = 3
+	storagePoll   = 3 * time.Second
+)
+
+// storageOutage follows tracks failing to open for files that are gone,
+// and whether playback waits for their music directory to come back,
+// e.g. a USB drive unplugged or an NFS share dropped. A single deleted
+// file is still skipped. Where the run of failures started is kept, so
+// playback picks up there, with the queue as it was then.
+type storageOutage struct {
+	misses  []string
+	index   int
+	current trackID
+	queue   playlist.Queue[trackID]
+
+	// waiting is the directory playback waits for, or ""
+	waiting string
+}
+
+// storageMsg reports a look at a music directory. After a run of missing
+// files failed is the last of them, to be skipped if the directory is
+// fine.
+type storageMsg struct {
+	root      string
+	available bool
+	failed    *playErrorMsg
+}
+
+// storageGone reports whether a track failed to open because its file,
+// or the file system it lives on, isn't there
+func storageGone(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EIO, syscall.ENODEV, syscall.ENXIO, syscall.ESTALE, syscall.ENOTCONN} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// storageRoot returns the music directory a track lies under, or its own
+// directory for tracks from a playlist outside them
+func (m *PlayerModel) storageRoot(path string) string {
+	if root := rootOf(m.sources, path); root != "" && root != path {
+		return root
+	}
+	return filepath.Dir(path)
+}
+
+// storageAvailable reports whether dir can be read and holds anything.
+// An unmounted drive often leaves its mount point behind, empty.
+func storageAvailable(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	return err == nil || !errors.Is(err, io.EOF) && !storageGone(err)
+}
+
+// trackMissing counts a track that failed to open towards a run of
+// missing files. Once the run is long enough it returns the command that
+// looks at their music directory, off the update loop as a dead network
+// share can hang; skip is false then, and while waiting for it.
+func (m *PlayerModel) trackMissing(msg playErrorMsg) (look tea.Cmd, skip bool) {
+	if m.storageWaiting() {
+		return nil, false
+	}
+	if !storageGone(msg.err) {
+		m.storage.misses = nil
+		return nil, true
+	}
+
+	if len(m.storage.misses) == 0 {
+		m.storage.index = m.currentIndex
+		m.storage.current = msg.id
+		m.storage.queue = m.queue.Clone()
+	}
+	m.storage.misses = append(m.storage.misses, msg.path)
+	if len(m.storage.misses) < min(storageMisses, len(m.playlist)) {
+		return nil, true
+	}
+	return m.lookForStorage(m.storageRoot(msg.path), 0, &msg), false
+}
+
+// lookForStorage looks at root after wait
+func (m *PlayerModel) lookForStorage(root string, wait time.Duration, failed *playErrorMsg) tea.Cmd {
+	look := func() tea.Msg {
+		return storageMsg{root: root, available: storageAvailable(root), failed: failed}
+	}
+	if wait == 0 {
+		return look
+	}
+	return m.ticks.clock.Tick(wait, func(time.Time) tea.Msg {
+		return look()
+	})
+}
+
+// storageLooked starts waiting when the directory of a run of missing
+// files is gone too, and otherwise skips on as for any failed track.
+// Once it is back, playback picks up at the first track that failed,
+// and the failures of the outage are forgotten.
+func (m *PlayerModel) storageLooked(msg storageMsg) tea.Cmd {
+	if m.quitting {
+		return nil
+	}
+
+	if msg.failed != nil {
+		// The user moved on while the directory was looked at
+		if msg.failed.id != m.current || m.playing || m.storageWaiting() {
+			return nil
+		}
+		if msg.available {
+			// Files deleted one after another, the directory is fine
+			return m.skipFailed(*msg.failed)
+		}
+
+		// Stand still at the first track that failed, which is also
+		// what a session saved meanwhile resumes
+		m.storage.waiting = msg.root
+		m.queue = m.storage.queue
+		if index := m.indexOf(m.storage.current); index >= 0 {
+			m.currentIndex = index
+		} else if m.storage.index < len(m.playlist) {
+			m.currentIndex = m.storage.index
+		}
+		m.current = m.storage.current
+		return m.lookForStorage(msg.root, storagePoll, nil)
+	}
+
+	if msg.root != m.storage.waiting {
+		return nil
+	}
+	if !msg.available {
+		return m.lookForStorage(msg.root, storagePoll, nil)
+	}
+
+	for _, path := range m.storage.misses {
+		if m.failed[path] != nil {
+			delete(m.failed, path)
+			m.skipped--
+		}
+	}
+	m.consecutiveFailures = 0
+	m.storage = storageOutage{}
+	return tea.Batch(m.showBanner("The music directory is back, playing on"), m.loadCurrentTrack())
+}
+
+// storageWaiting reports whether playback waits for the music directory
+func (m *PlayerModel) storageWaiting() bool {
+	return m.storage.waiting != ""
+}
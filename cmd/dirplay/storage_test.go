@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStorageGone(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"not exist", fs.ErrNotExist, true},
+		{"wrapped ENOENT", fmt.Errorf("failed to open file: %w", &fs.PathError{Op: "open", Path: "/music/01.mp3", Err: syscall.ENOENT}), true},
+		{"EIO", &fs.PathError{Op: "read", Path: "/music/01.mp3", Err: syscall.EIO}, true},
+		{"ESTALE", &fs.PathError{Op: "open", Path: "/music/01.mp3", Err: syscall.ESTALE}, true},
+		{"ENOTCONN", syscall.ENOTCONN, true},
+		{"permission", &fs.PathError{Op: "open", Path: "/music/01.mp3", Err: syscall.EACCES}, false},
+		{"decode", errFakeDecode, false},
+		{"none", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storageGone(tt.err); got != tt.want {
+				t.Errorf("storageGone(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorageAvailable(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "full")
+	empty := filepath.Join(dir, "empty")
+	for _, d := range []string{full, empty} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(full, "01.mp3"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want bool
+	}{
+		{"holds files", full, true},
+		{"empty mount point", empty, false},
+		{"gone", filepath.Join(dir, "gone"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storageAvailable(tt.dir); got != tt.want {
+				t.Errorf("storageAvailable(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestModelStorageGone fails the tracks after the first the way files on
+// an unplugged drive or deleted files do: playback waits for the music
+// directory at the first track that failed, or skips on when it is still
+// there
+func TestModelStorageGone(t *testing.T) {
+	unplugged := func(path string) error {
+		return fmt.Errorf("failed to open file: %w", &fs.PathError{Op: "open", Path: path, Err: syscall.ENOENT})
+	}
+	tests := []struct {
+		name string
+		err  func(path string) error
+		// gone takes the directory away, empty leaves it behind empty as
+		// a mount point
+		gone, empty bool
+		wait        bool
+	}{
+		{"drive unplugged", unplugged, true, false, true},
+		{"mount point left", unplugged, true, true, true},
+		{"share dropped", func(path string) error { return &fs.PathError{Op: "open", Path: path, Err: syscall.ESTALE} }, true, false, true},
+		{"files deleted", unplugged, false, false, false},
+		{"files broken", func(string) error { return errFakeDecode }, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "music")
+			if err := os.Mkdir(root, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			cover := filepath.Join(root, "cover.jpg")
+			if err := os.WriteFile(cover, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var tracks []string
+			for i := range 5 {
+				tracks = append(tracks, filepath.Join(root, fmt.Sprintf("%02d.mp3", i+1)))
+			}
+
+			h := newHarness(t, tracks...)
+			h.m.sources = []string{root}
+			h.start()
+			h.advance(5 * time.Second)
+			history := h.m.history.Len()
+
+			// The drive goes, taking the files after the first with it
+			for _, track := range tracks[1:4] {
+				h.player.setTrack(track, fakeTrack{loadErr: tt.err(track)})
+			}
+			switch {
+			case tt.empty:
+				os.Remove(cover)
+			case tt.gone:
+				os.RemoveAll(root)
+			}
+			h.press("right")
+			h.advance(3 * skipDelay)
+
+			if !tt.wait {
+				if h.m.storageWaiting() || h.playing() != "05.mp3" || !h.m.playing {
+					t.Fatalf("on %s, playing = %v, waiting = %v, want the failed tracks skipped", h.playing(), h.m.playing, h.m.storageWaiting())
+				}
+				return
+			}
+			if !h.m.storageWaiting() || h.m.playing || h.playing() != "02.mp3" {
+				t.Fatalf("on %s, playing = %v, waiting = %v, want to wait at 02.mp3", h.playing(), h.m.playing, h.m.storageWaiting())
+			}
+			if !strings.Contains(h.m.View(), "Music directory unavailable") {
+				t.Errorf("view doesn't say the directory is unavailable:\n%s", h.m.View())
+			}
+
+			// Nothing is tried while waiting, however long it takes
+			h.player.log()
+			h.advance(10 * storagePoll)
+			if got := h.player.log(); len(got) > 0 {
+				t.Errorf("player calls = %v while waiting, want none", got)
+			}
+
+			// The drive comes back
+			os.MkdirAll(root, 0o755)
+			os.WriteFile(cover, nil, 0o644)
+			for _, track := range tracks[1:4] {
+				h.player.setTrack(track, fakeTrack{length: time.Minute})
+			}
+			h.advance(storagePoll)
+			if h.m.storageWaiting() || !h.m.playing || h.playing() != "02.mp3" {
+				t.Fatalf("on %s, playing = %v, waiting = %v, want 02.mp3 playing again", h.playing(), h.m.playing, h.m.storageWaiting())
+			}
+			if h.m.skipped != 0 || len(h.m.failed) != 0 {
+				t.Errorf("skipped = %d, failed = %v, want the outage forgotten", h.m.skipped, h.m.failed)
+			}
+			if h.m.history.Len() != history+1 {
+				t.Errorf("history holds %d tracks, want %d", h.m.history.Len(), history+1)
+			}
+		})
+	}
+}
+
+// TestStorageGoneShortPlaylist waits for the directory of a playlist
+// shorter than storageMisses before every track fails
+func TestStorageGoneShortPlaylist(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "music")
+	tracks := []string{filepath.Join(root, "01.mp3"), filepath.Join(root, "02.mp3")}
+	h := newHarness(t, tracks...)
+	h.m.sources = []string{root}
+	for _, track := range tracks {
+		h.player.setTrack(track, fakeTrack{loadErr: &fs.PathError{Op: "open", Path: track, Err: syscall.ENOENT}})
+	}
+	h.start()
+	h.advance(2 * skipDelay)
+	if !h.m.storageWaiting() || h.m.err != nil {
+		t.Errorf("waiting = %v with error %v, want to wait for the directory", h.m.storageWaiting(), h.m.err)
+	}
+}
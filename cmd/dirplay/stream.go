@@ -0,0 +1,26 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// pollStreamNews shows what the stream playing had to say since the last
+// tick: a new title replaces the artist and title shown, notices show as
+// banners. News of other tracks, e.g. one preloaded, is dropped.
+func (m *PlayerModel) pollStreamNews() tea.Cmd {
+	var cmds []tea.Cmd
+	for {
+		select {
+		case news := <-m.player.StreamNews():
+			if !m.playing || news.Path != m.currentTrack() {
+				continue
+			}
+			if news.Title != "" {
+				m.artist, m.title = news.Artist, news.Title
+			}
+			if news.Notice != "" {
+				cmds = append(cmds, m.showBanner(news.Notice))
+			}
+		default:
+			return tea.Batch(cmds...)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Values of --dedupe that leave copies out of the playlist
+const (
+	dedupeName = "name"
+	dedupeTags = "tags"
+)
+
+// duplicateLengthSlack is how far the lengths of two copies of a track
+// may differ for --dedupe=tags
+const duplicateLengthSlack = 2 * time.Second
+
+// copyRanks orders the formats a track is kept in when there are copies,
+// lossless first
+var copyRanks = map[string]int{
+	".flac": 0,
+	".wav":  1,
+	".mp3":  2,
+	".ogg":  3,
+	".opus": 4,
+}
+
+// duplicateSuppressor keeps one copy of each track in the playlist for
+// --dedupe=name and --dedupe=tags. names and tags map the keys copies
+// share to the copies kept, hidden maps each copy left out to the one
+// kept instead.
+type duplicateSuppressor struct {
+	mode   string
+	names  map[string]string
+	tags   map[string][]string
+	hidden map[string]string
+}
+
+// SuppressDuplicates leaves copies of the same track out of the playlist.
+// With dedupeName files in one directory that differ only in extension or
+// case are copies, with dedupeTags also files with the same artist, album
+// and title and about the same length, once their tags are indexed.
+func (m *PlayerModel) SuppressDuplicates(mode string) {
+	if mode != dedupeName && mode != dedupeTags {
+		return
+	}
+	m.suppress = &duplicateSuppressor{
+		mode:   mode,
+		names:  make(map[string]string),
+		tags:   make(map[string][]string),
+		hidden: make(map[string]string),
+	}
+}
+
+// nameKey returns what copies of a file share for --dedupe=name: its
+// directory and its name without extension, ignoring case
+func nameKey(path string) string {
+	base := filepath.Base(path)
+	return strings.ToLower(filepath.Join(filepath.Dir(path), strings.TrimSuffix(base, filepath.Ext(base))))
+}
+
+// tagKey returns what copies of a track share for --dedupe=tags, its
+// artist, album and title compared by letters and digits only, or "" if
+// it has no title
+func tagKey(tags trackTags) string {
+	if tags.title == "" {
+		return ""
+	}
+	normalize := func(s string) string {
+		words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		return strings.Join(words, " ")
+	}
+	return normalize(tags.artist) + "\x00" + normalize(tags.album) + "\x00" + normalize(tags.title)
+}
+
+// hide leaves path out in favour of kept, moving copies hidden in favour
+// of path over to kept as well
+func (s *duplicateSuppressor) hide(path, kept string) {
+	for other, instead := range s.hidden {
+		if instead == path {
+			s.hidden[other] = kept
+		}
+	}
+	s.hidden[path] = kept
+}
+
+// preferCopy reports whether a is kept over b: the current track stays,
+// then the better format wins, then the higher bitrate
+func (m *PlayerModel) preferCopy(a, b string) bool {
+	switch m.currentTrack() {
+	case a:
+		return true
+	case b:
+		return false
+	}
+
+	rankA, rankB := copyRank(a), copyRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	return m.bitrate(a) > m.bitrate(b)
+}
+
+// copyRank returns where a file's format is in copyRanks, unknown formats
+// last
+func copyRank(path string) int {
+	if rank, ok := copyRanks[strings.ToLower(filepath.Ext(path))]; ok {
+		return rank
+	}
+	return len(copyRanks)
+}
+
+// bitrate estimates the bitrate of a file from its size and length, or
+// returns its size when the length isn't known, which orders copies of
+// the same track the same way
+func (m *PlayerModel) bitrate(path string) float64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	if length := m.knownTags[path].length; length > 0 {
+		return float64(info.Size()) / length.Seconds()
+	}
+	return float64(info.Size())
+}
+
+// dedupeByName drops the scanned files that are copies of ones already in
+// the playlist, and takes copies out of the playlist that the files
+// replace
+func (m *PlayerModel) dedupeByName(paths []string) ([]string, tea.Cmd) {
+	s := m.suppress
+	if s == nil {
+		return paths, nil
+	}
+
+	var displaced []string
+	for _, path := range paths {
+		if _, ok := s.hidden[path]; ok {
+			continue
+		}
+		key := nameKey(path)
+		other, ok := s.names[key]
+		switch {
+		case !ok || other == path:
+			s.names[key] = path
+		case m.preferCopy(path, other):
+			s.names[key] = path
+			s.hide(other, path)
+			displaced = append(displaced, other)
+		default:
+			s.hide(path, other)
+		}
+	}
+
+	var kept []string
+	for _, path := range paths {
+		if _, ok := s.hidden[path]; !ok {
+			kept = append(kept, path)
+		}
+	}
+	return kept, m.hideTracks(displaced)
+}
+
+// dedupeByTags compares newly indexed tracks of the playlist with the
+// ones kept so far and takes the copies out
+func (m *PlayerModel) dedupeByTags(paths []string) tea.Cmd {
+	s := m.suppress
+	if s == nil || s.mode != dedupeTags {
+		return nil
+	}
+
+	// Batches arrive as maps, sorted so the same library dedupes the
+	// same way every time
+	sort.Strings(paths)
+
+	var displaced []string
+	for _, path := range paths {
+		if _, ok := s.hidden[path]; ok || !m.tracks.Known(path) {
+			continue
+		}
+		tags := m.knownTags[path]
+		key := tagKey(tags)
+		if key == "" || tags.length <= 0 {
+			continue
+		}
+
+		// Copies hidden by name since they were kept are let go
+		var kept []string
+		match := ""
+		for _, other := range s.tags[key] {
+			if _, ok := s.hidden[other]; ok || other == path {
+				continue
+			}
+			kept = append(kept, other)
+			if match == "" && (tags.length-m.knownTags[other].length).Abs() <= duplicateLengthSlack {
+				match = other
+			}
+		}
+
+		switch {
+		case match == "":
+			kept = append(kept, path)
+		case m.preferCopy(path, match):
+			for i, other := range kept {
+				if other == match {
+					kept[i] = path
+				}
+			}
+			s.hide(match, path)
+			displaced = append(displaced, match)
+		default:
+			s.hide(path, match)
+			displaced = append(displaced, path)
+		}
+		s.tags[key] = kept
+	}
+	return m.hideTracks(displaced)
+}
+
+// hideTracks takes copies out of the playlist, preloading the track
+// that follows the current one now
+func (m *PlayerModel) hideTracks(paths []string) tea.Cmd {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	hidden := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		hidden[path] = true
+	}
+	keep := func(ids []trackID) []trackID {
+		var kept []trackID
+		for _, id := range ids {
+			if !hidden[m.tracks.Path(id)] {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	}
+
+	m.fullPlaylist = keep(m.fullPlaylist)
+	m.setPlaylist(keep(m.playlist))
+	if m.playing {
+		return m.preloadNext()
+	}
+	return nil
+}
+
+// alternates returns the hidden copies of each kept track, for the
+// playlist pane
+func (m *PlayerModel) alternates() map[trackID][]trackID {
+	if m.suppress == nil || len(m.suppress.hidden) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(m.suppress.hidden))
+	for path := range m.suppress.hidden {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	alternates := make(map[trackID][]trackID)
+	for _, path := range paths {
+		kept := m.tracks.Add(m.suppress.hidden[path])
+		alternates[kept] = append(alternates[kept], m.tracks.Add(path))
+	}
+	return alternates
+}
+
+// isAlternate reports whether a track is a copy left out of the playlist
+func (m *PlayerModel) isAlternate(id trackID) bool {
+	if m.suppress == nil {
+		return false
+	}
+	_, ok := m.suppress.hidden[m.tracks.Path(id)]
+	return ok
+}
+
+// keepAlternate puts a hidden copy in the place of the copy kept instead
+// of it, which is hidden in turn, and returns its playlist position or -1
+// if the kept copy is filtered out
+func (m *PlayerModel) keepAlternate(id trackID) int {
+	s := m.suppress
+	path := m.tracks.Path(id)
+	kept, ok := s.hidden[path]
+	if !ok {
+		return -1
+	}
+
+	delete(s.hidden, path)
+	s.hide(kept, path)
+	for key, other := range s.names {
+		if other == kept {
+			s.names[key] = path
+		}
+	}
+	for key, others := range s.tags {
+		for i, other := range others {
+			if other == kept {
+				others[i] = path
+			}
+		}
+		s.tags[key] = others
+	}
+
+	keptID := m.tracks.Add(kept)
+	for _, ids := range [][]trackID{m.fullPlaylist, m.playlist} {
+		for i, other := range ids {
+			if other == keptID {
+				ids[i] = id
+			}
+		}
+	}
+	m.indexAlbums(0)
+	return m.indexOf(id)
+}
+
+// suppressedLabel counts the copies left out of the playlist, or returns
+// ""
+func (m *PlayerModel) suppressedLabel() string {
+	if m.suppress == nil || len(m.suppress.hidden) == 0 {
+		return ""
+	}
+	if len(m.suppress.hidden) == 1 {
+		return "1 duplicate hidden"
+	}
+	return fmt.Sprintf("%d duplicates hidden", len(m.suppress.hidden))
+}
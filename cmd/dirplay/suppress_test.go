@@ -0,0 +1,248 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeCopies writes files of the given sizes under dir, named by their
+// paths relative to it, and returns their full paths by name
+func writeCopies(t *testing.T, dir string, sizes map[string]int) map[string]string {
+	t.Helper()
+	paths := make(map[string]string, len(sizes))
+	for name, size := range sizes {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths[name] = path
+	}
+	return paths
+}
+
+// scanning makes the model take scan batches as a scan started with
+// --playlist would, in the order they arrive
+func scanning(h *harness, mode string) {
+	h.m.SuppressDuplicates(mode)
+	h.m.KeepScanOrder()
+	h.m.scanning = true
+	h.m.scanSeen = make(map[string]bool)
+}
+
+func TestNameKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"/music/a/01 Song.mp3", "/music/a/01 Song.flac", true},
+		{"/music/a/01 Song.mp3", "/music/a/01 SONG.MP3", true},
+		{"/music/a/01 Song.mp3", "/music/b/01 Song.mp3", false},
+		{"/music/a/01 Song.mp3", "/music/a/02 Song.mp3", false},
+		{"/music/a/Song.live.mp3", "/music/a/Song.flac", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := nameKey(tt.a) == nameKey(tt.b); got != tt.same {
+				t.Errorf("nameKey(%q) == nameKey(%q) is %v, want %v", tt.a, tt.b, got, tt.same)
+			}
+		})
+	}
+}
+
+func TestTagKey(t *testing.T) {
+	song := trackTags{artist: "The Band", album: "Songs", title: "Song"}
+	tests := []struct {
+		name string
+		tags trackTags
+		same bool
+	}{
+		{"same", song, true},
+		{"case", trackTags{artist: "THE BAND", album: "songs", title: "SONG"}, true},
+		{"punctuation", trackTags{artist: "The Band!", album: "Songs", title: "Song."}, true},
+		{"spaces", trackTags{artist: " The  Band", album: "Songs ", title: "Song"}, true},
+		{"other title", trackTags{artist: "The Band", album: "Songs", title: "Song 2"}, false},
+		{"other album", trackTags{artist: "The Band", album: "Best of", title: "Song"}, false},
+		{"other artist", trackTags{artist: "Band", album: "Songs", title: "Song"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagKey(tt.tags) == tagKey(song); got != tt.same {
+				t.Errorf("tagKey(%+v) matches is %v, want %v", tt.tags, got, tt.same)
+			}
+		})
+	}
+
+	if key := tagKey(trackTags{artist: "The Band", album: "Songs"}); key != "" {
+		t.Errorf("tagKey() = %q without a title, want none", key)
+	}
+}
+
+// TestPreferCopy checks which of two copies is kept: the better format,
+// then the higher bitrate, and always the one playing
+func TestPreferCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		sizes   []int
+		lengths []time.Duration
+		want    bool
+	}{
+		{"flac over mp3", "a.flac", "a.mp3", []int{100, 900}, nil, true},
+		{"mp3 over m4a", "a.mp3", "a.m4a", []int{100, 900}, nil, true},
+		{"flac over m4a", "a.flac", "a.m4a", []int{100, 900}, nil, true},
+		{"m4a under flac", "a.m4a", "a.flac", []int{900, 100}, nil, false},
+		{"wav over mp3", "a.wav", "a.mp3", []int{100, 900}, nil, true},
+		{"case of the extension", "a.FLAC", "a.mp3", []int{100, 900}, nil, true},
+		{"higher bitrate", "a.mp3", "b.mp3", []int{900, 100}, nil, true},
+		{"lower bitrate", "a.mp3", "b.mp3", []int{100, 900}, nil, false},
+		{"bitrate by length", "a.mp3", "b.mp3", []int{100, 150}, []time.Duration{time.Second, 2 * time.Second}, true},
+		{"playing stays", "a.mp3", "a.flac", []int{100, 900}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := writeCopies(t, dir, map[string]int{tt.a: tt.sizes[0]})[tt.a]
+			b := writeCopies(t, dir, map[string]int{tt.b: tt.sizes[1]})[tt.b]
+
+			h := newHarness(t)
+			if tt.name == "playing stays" {
+				h.m.current = h.m.tracks.Add(a)
+			}
+			for i, length := range tt.lengths {
+				h.m.knownTags[[]string{a, b}[i]] = trackTags{length: length}
+			}
+			if got := h.m.preferCopy(a, b); got != tt.want {
+				t.Errorf("preferCopy(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDedupeByName scans batches of files with --dedupe=name and checks
+// which are played and which are hidden
+func TestDedupeByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches [][]string
+		want    []string
+		hidden  int
+	}{
+		{"no copies", [][]string{{"a/01.mp3", "a/02.mp3", "b/01.flac"}}, []string{"a/01.mp3", "a/02.mp3", "b/01.flac"}, 0},
+		{"same batch", [][]string{{"a/01.mp3", "a/01.flac", "a/01.m4a"}}, []string{"a/01.flac"}, 2},
+		{"better copy later", [][]string{{"a/02.mp3", "a/01.mp3"}, {"a/01.flac"}}, []string{"a/02.mp3", "a/01.flac"}, 1},
+		{"playing copy stays", [][]string{{"a/01.mp3", "a/02.mp3"}, {"a/01.flac"}}, []string{"a/01.mp3", "a/02.mp3"}, 1},
+		{"worse copy later", [][]string{{"a/01.flac"}, {"a/01.mp3"}}, []string{"a/01.flac"}, 1},
+		{"case", [][]string{{"a/Song.mp3", "a/SONG.flac"}}, []string{"a/SONG.flac"}, 1},
+		{"scanned again", [][]string{{"a/01.mp3", "a/01.flac"}, {"a/01.mp3", "a/01.flac"}}, []string{"a/01.flac"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sizes := make(map[string]int)
+			for _, batch := range tt.batches {
+				for _, name := range batch {
+					sizes[name] = 100
+				}
+			}
+			paths := writeCopies(t, dir, sizes)
+
+			h := newHarness(t)
+			scanning(h, dedupeName)
+			for _, batch := range tt.batches {
+				msg := scanBatchMsg{}
+				for _, name := range batch {
+					msg.paths = append(msg.paths, paths[name])
+				}
+				h.send(msg)
+			}
+
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, paths[name])
+			}
+			if got := h.m.tracks.Paths(h.m.playlist); !slices.Equal(got, want) {
+				t.Errorf("playlist = %v, want %v", got, want)
+			}
+			if len(h.m.suppress.hidden) != tt.hidden {
+				t.Errorf("%d copies hidden, want %d", len(h.m.suppress.hidden), tt.hidden)
+			}
+		})
+	}
+}
+
+// TestDedupeByTags indexes the tags of copies in different directories
+// and checks copies are matched by their tags and about the same length
+func TestDedupeByTags(t *testing.T) {
+	song := trackTags{artist: "Band", album: "Songs", title: "Song", length: 3 * time.Minute}
+	tests := []struct {
+		name   string
+		other  trackTags
+		hidden bool
+	}{
+		{"same length", song, true},
+		{"a second longer", trackTags{artist: "Band", album: "Songs", title: "Song", length: 3*time.Minute + time.Second}, true},
+		{"two seconds shorter", trackTags{artist: "Band", album: "Songs", title: "Song", length: 3*time.Minute - 2*time.Second}, true},
+		{"just over two seconds", trackTags{artist: "Band", album: "Songs", title: "Song", length: 3*time.Minute + 2*time.Second + time.Millisecond}, false},
+		{"another take", trackTags{artist: "Band", album: "Songs", title: "Song", length: 4 * time.Minute}, false},
+		{"tags spelled differently", trackTags{artist: "BAND", album: "Songs!", title: "song", length: 3 * time.Minute}, true},
+		{"other title", trackTags{artist: "Band", album: "Songs", title: "Other", length: 3 * time.Minute}, false},
+		{"no title", trackTags{artist: "Band", album: "Songs", length: 3 * time.Minute}, false},
+		{"no length", trackTags{artist: "Band", album: "Songs", title: "Song"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := writeCopies(t, t.TempDir(), map[string]int{"rip/01.flac": 100, "best of/07.mp3": 100, "rip/02.flac": 100})
+			flac, mp3 := paths["rip/01.flac"], paths["best of/07.mp3"]
+
+			h := newHarness(t)
+			scanning(h, dedupeTags)
+			h.send(scanBatchMsg{paths: []string{paths["rip/02.flac"], mp3, flac}})
+			h.send(tagIndexedMsg{tags: map[string]trackTags{flac: song, mp3: tt.other}})
+
+			want := []string{paths["rip/02.flac"], mp3, flac}
+			label := ""
+			if tt.hidden {
+				// The lossless copy is kept
+				want = []string{paths["rip/02.flac"], flac}
+				label = "1 duplicate hidden"
+			}
+			if got := h.m.tracks.Paths(h.m.playlist); !slices.Equal(got, want) {
+				t.Errorf("playlist = %v, want %v", got, want)
+			}
+			if got := h.m.suppressedLabel(); got != label {
+				t.Errorf("suppressedLabel() = %q, want %q", got, label)
+			}
+		})
+	}
+}
+
+// TestPlayAlternate plays a hidden copy from the playlist pane, which
+// takes the place of the copy kept
+func TestPlayAlternate(t *testing.T) {
+	paths := writeCopies(t, t.TempDir(), map[string]int{"a/01.mp3": 100, "a/01.flac": 100, "a/02.mp3": 100})
+	h := newHarness(t)
+	scanning(h, dedupeName)
+	h.send(scanBatchMsg{paths: []string{paths["a/01.mp3"], paths["a/01.flac"], paths["a/02.mp3"]}})
+	if h.playing() != "01.flac" {
+		t.Fatalf("playing %s, want 01.flac", h.playing())
+	}
+
+	// The copy follows the one kept, greyed out
+	h.press("l", "down", "enter")
+	if h.playing() != "01.mp3" {
+		t.Fatalf("playing %s after choosing the copy, want 01.mp3", h.playing())
+	}
+	want := []string{paths["a/01.mp3"], paths["a/02.mp3"]}
+	if got := h.m.tracks.Paths(h.m.playlist); !slices.Equal(got, want) {
+		t.Errorf("playlist = %v, want %v", got, want)
+	}
+	if got := h.m.suppress.hidden[paths["a/01.flac"]]; got != paths["a/01.mp3"] {
+		t.Errorf("01.flac hidden in favour of %q, want 01.mp3", got)
+	}
+}
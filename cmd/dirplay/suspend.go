@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shutdownMsg asks the program to quit the way "q" does, sent when the
+// terminal closes or the process is told to terminate
+type shutdownMsg struct{}
+
+// suspend pauses playback and hands the terminal back to the shell, as
+// ctrl+z does in other programs. Bubble Tea stops the process and
+// restores the screen once it is continued, then sends a ResumeMsg.
+func (m *PlayerModel) suspend() tea.Cmd {
+	if m.playing && !m.paused {
+		m.togglePause()
+		m.resumeAfterSuspend = true
+	}
+	return tea.Suspend
+}
+
+// resumed carries on after a suspend: playback picks up where it paused
+// and the screen is drawn from scratch
+func (m *PlayerModel) resumed() tea.Cmd {
+	var resume tea.Cmd
+	if m.resumeAfterSuspend && m.paused {
+		resume = m.togglePause()
+	}
+	m.resumeAfterSuspend = false
+	return tea.Batch(tea.ClearScreen, resume)
+}
+
+// handleShutdownSignals turns SIGTERM, SIGHUP and SIGINT into a normal
+// quit, so closing the terminal window stops the audio, saves the session
+// and restores the terminal instead of leaving playback running. It
+// stops listening when done is closed.
+func handleShutdownSignals(send func(tea.Msg), done <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP, os.Interrupt)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				send(shutdownMsg{})
+			case <-done:
+				return
+			}
+		}
+	}()
+}
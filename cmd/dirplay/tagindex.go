@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/player"
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// Tags are read from at most tagIndexRate files a second, so indexing a
+// big library doesn't starve playback on a spinning disk. Cached tags
+// cost a stat only. The model gets them in batches of up to
+// tagIndexBatch, and the index is saved every tagIndexSaveEvery while
+// files are being read.
+const (
+	tagIndexRate      = 200
+	tagIndexBatch     = 100
+	tagIndexSaveEvery = 30 * time.Second
+)
+
+// indexedTags are the tags of a file and the file they were read from.
+// LengthMS is the length from the tags, or once the track was played the
+// decoded one. Failed marks files whose tags can't be parsed, so they
+// aren't read again either.
+type indexedTags struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Artist      string    `json:"artist,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Album       string    `json:"album,omitempty"`
+	AlbumArtist string    `json:"album_artist,omitempty"`
+	SortArtist  string    `json:"sort_artist,omitempty"`
+	Disc        int       `json:"disc,omitempty"`
+	Track       int       `json:"track,omitempty"`
+	LengthMS    int64     `json:"length_ms,omitempty"`
+	Failed      bool      `json:"failed,omitempty"`
+}
+
+// tagIndex keeps tags between sessions in tags.json in the cache
+// directory, so only new and changed files are read
+type tagIndex struct {
+	mu      sync.Mutex
+	path    string
+	dirty   bool
+	Version int                     `json:"version"`
+	Files   map[string]*indexedTags `json:"files"`
+}
+
+// tagIndexMigrations upgrade older tag indexes, see readVersioned
+var tagIndexMigrations = []migration{unversioned, forgetIndexedFiles}
+
+// forgetIndexedFiles drops the files of an index from before disc numbers
+// were kept, so they are read again
+func forgetIndexedFiles(doc map[string]any) error {
+	delete(doc, "files")
+	return nil
+}
+
+// tagIndexedMsg carries a batch of tags read by the indexer
+type tagIndexedMsg struct {
+	tags map[string]trackTags
+}
+
+// tagIndexer reads the tags of the files handed to it in the background,
+// in the order they arrive. With lengths, the formats to decode, it also
+// learns the length of every file it can, for --dedupe=tags.
+type tagIndexer struct {
+	index   *tagIndex
+	lengths *codec.Registry
+	out     chan tea.Msg
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	pending []string
+}
+
+// loadTagIndex reads the stored tags, starting empty if there are none
+func loadTagIndex() (*tagIndex, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &tagIndex{
+		path:  filepath.Join(dir, "tags.json"),
+		Files: make(map[string]*indexedTags),
+	}
+
+	err = readVersioned(index.path, tagIndexMigrations, index)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]*indexedTags)
+	}
+	return index, nil
+}
+
+// cached returns the tags of path if they were read since the file last
+// changed
+func (x *tagIndex) cached(path string, info os.FileInfo) (*indexedTags, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	entry, ok := x.Files[path]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// read reads the tags of path and stores them in the index. With
+// lengths, files whose tags have none are decoded for it by those codecs.
+func (x *tagIndex) read(path string, info os.FileInfo, lengths *codec.Registry) *indexedTags {
+	entry := &indexedTags{Size: info.Size(), ModTime: info.ModTime()}
+	if err := readIndexedTags(path, entry); err != nil {
+		entry.Failed = true
+	}
+	if lengths != nil && entry.LengthMS == 0 {
+		if length, err := decodedLength(lengths, path); err == nil {
+			entry.LengthMS = length.Milliseconds()
+		}
+	}
+
+	x.mu.Lock()
+	x.Files[path] = entry
+	x.dirty = true
+	x.mu.Unlock()
+	return entry
+}
+
+// readIndexedTags fills entry with the tags of a file
+func readIndexedTags(path string, entry *indexedTags) error {
+	file, err := os.Open(fspath.Long(path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tags, err := tag.ReadFrom(file)
+	if err != nil {
+		return err
+	}
+	entry.Artist = tags.Artist()
+	entry.Title = tags.Title()
+	entry.Album = tags.Album()
+	entry.AlbumArtist = tags.AlbumArtist()
+	entry.SortArtist = library.SortArtistTag(tags)
+	entry.Disc, _ = tags.Disc()
+	entry.Track, _ = tags.Track()
+	entry.LengthMS = library.TagLength(tags).Milliseconds()
+	return nil
+}
+
+// decodedLength returns the length of a file as its decoder reports it
+func decodedLength(codecs *codec.Registry, path string) (length time.Duration, err error) {
+	file, err := os.Open(fspath.Long(path))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			length, err = 0, fmt.Errorf("%w: %v", player.ErrDecoderPanic, r)
+		}
+	}()
+
+	streamer, format, err := codecs.Decode(file, strings.ToLower(filepath.Ext(path)))
+	if err != nil {
+		return 0, err
+	}
+	defer streamer.Close()
+	return format.SampleRate.D(streamer.Len()), nil
+}
+
+// Lookup returns the tags of path, reading them unless the index has
+// them for the file as it is now
+func (x *tagIndex) Lookup(path string) (*indexedTags, error) {
+	info, err := os.Stat(fspath.Long(path))
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := x.cached(path, info); ok {
+		return entry, nil
+	}
+	return x.read(path, info, nil), nil
+}
+
+// Save writes the index to disk via a temporary file, if anything was
+// read since it was loaded or last saved
+func (x *tagIndex) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.dirty {
+		return nil
+	}
+	x.Version = len(tagIndexMigrations)
+	data, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(x.path, data); err != nil {
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// SetLength records the decoded length of a file the index has. The
+// entry is replaced rather than changed, as the indexer may be reading it.
+func (x *tagIndex) SetLength(path string, length time.Duration) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	entry, ok := x.Files[path]
+	if !ok || entry.LengthMS == length.Milliseconds() {
+		return
+	}
+	updated := *entry
+	updated.LengthMS = length.Milliseconds()
+	x.Files[path] = &updated
+	x.dirty = true
+}
+
+// trackTags returns the tags as the model keeps them
+func (t *indexedTags) trackTags() trackTags {
+	return trackTags{
+		artist:      t.Artist,
+		title:       t.Title,
+		album:       t.Album,
+		albumArtist: t.AlbumArtist,
+		sortArtist:  t.SortArtist,
+		number:      playlist.DiscTrack{Disc: t.Disc, Track: t.Track},
+		length:      time.Duration(t.LengthMS) * time.Millisecond,
+	}
+}
+
+// StartTagIndex reads the tags of scanned files in the background, from
+// index where it has them, so the playlist pane and the filter know them
+// before the tracks are played
+func (m *PlayerModel) StartTagIndex(index *tagIndex) {
+	indexer := &tagIndexer{
+		index: index,
+		out:   make(chan tea.Msg),
+		wake:  make(chan struct{}, 1),
+	}
+	if m.suppress != nil && m.suppress.mode == dedupeTags {
+		indexer.lengths = m.codecs
+	}
+	m.tagIndexer = indexer
+	m.work.Go(indexer.run)
+}
+
+// indexTags hands files to the indexer
+func (m *PlayerModel) indexTags(paths []string) {
+	if m.tagIndexer == nil || len(paths) == 0 {
+		return
+	}
+
+	indexer := m.tagIndexer
+	indexer.mu.Lock()
+	indexer.pending = append(indexer.pending, paths...)
+	indexer.mu.Unlock()
+
+	select {
+	case indexer.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run indexes the files handed over until ctx is cancelled, then saves
+// what it read
+func (ix *tagIndexer) run(ctx context.Context) {
+	defer close(ix.out)
+	defer ix.index.Save()
+
+	limit := time.NewTicker(time.Second / tagIndexRate)
+	defer limit.Stop()
+	lastSave := time.Now()
+
+	for {
+		ix.mu.Lock()
+		paths := ix.pending
+		ix.pending = nil
+		ix.mu.Unlock()
+
+		if len(paths) == 0 {
+			ix.index.Save()
+			select {
+			case <-ix.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		batch := make(map[string]trackTags)
+		for _, path := range paths {
+			info, err := os.Stat(fspath.Long(path))
+			if err != nil {
+				continue
+			}
+			entry, ok := ix.index.cached(path, info)
+			if ok && ix.lengths != nil && entry.LengthMS == 0 && ix.lengths.IsAudioFile(path) {
+				// Indexed without lengths in an earlier session
+				ok = false
+			}
+			if !ok {
+				select {
+				case <-limit.C:
+				case <-ctx.Done():
+					return
+				}
+				entry = ix.index.read(path, info, ix.lengths)
+			}
+			if !entry.Failed {
+				batch[path] = entry.trackTags()
+			}
+
+			if len(batch) >= tagIndexBatch {
+				if !ix.send(ctx, batch) {
+					return
+				}
+				batch = make(map[string]trackTags)
+			}
+			if time.Since(lastSave) >= tagIndexSaveEvery {
+				ix.index.Save()
+				lastSave = time.Now()
+			}
+		}
+		if len(batch) > 0 && !ix.send(ctx, batch) {
+			return
+		}
+	}
+}
+
+// send hands a batch to the model, reporting false once ctx is cancelled
+func (ix *tagIndexer) send(ctx context.Context, batch map[string]trackTags) bool {
+	select {
+	case ix.out <- tagIndexedMsg{tags: batch}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitForTagIndex delivers the next batch of tags from the indexer
+func (m *PlayerModel) waitForTagIndex() tea.Cmd {
+	if m.tagIndexer == nil {
+		return nil
+	}
+
+	out := m.tagIndexer.out
+	return func() tea.Msg {
+		msg, ok := <-out
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// tagsIndexed records a batch of tags. A length decoded while playing
+// wins over an indexed one. Albums played in order are sorted by their
+// track numbers. With --dedupe=tags copies found by their tags leave the
+// playlist, and with a filter active, tracks now matching by their tags
+// join it.
+func (m *PlayerModel) tagsIndexed(msg tagIndexedMsg) tea.Cmd {
+	paths := make([]string, 0, len(msg.tags))
+	for path, tags := range msg.tags {
+		if known, ok := m.knownTags[path]; ok && known.length > 0 {
+			tags.length = known.length
+		}
+		m.knownTags[path] = tags
+		paths = append(paths, path)
+	}
+
+	m.catalogTags(paths)
+
+	cmds := []tea.Cmd{m.waitForTagIndex(), m.dedupeByTags(paths)}
+	if m.orderAlbums(paths) && m.playing {
+		cmds = append(cmds, m.preloadNext())
+	}
+	if m.filterQuery != "" {
+		matches := m.filterTracks(m.filterQuery)
+		if len(matches) != len(m.playlist) && m.setPlaylist(matches) && m.playing {
+			cmds = append(cmds, m.preloadNext())
+		}
+	}
+	return tea.Batch(cmds...)
+}
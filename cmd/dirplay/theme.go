@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Values of --theme
+const (
+	themeAuto  = "auto"
+	themeDark  = "dark"
+	themeLight = "light"
+	themeMono  = "mono"
+)
+
+// uiTheme holds the colors the views are drawn in. A mono theme has no
+// colors and draws with bold, faint and reverse text only.
+type uiTheme struct {
+	Accent    lipgloss.TerminalColor
+	Text      lipgloss.TerminalColor
+	Dim       lipgloss.TerminalColor
+	Banner    lipgloss.TerminalColor
+	Error     lipgloss.TerminalColor
+	MeterLoud lipgloss.TerminalColor
+	Mono      bool
+}
+
+// The built-in palettes spell out the 256 and 16 color fallbacks, rather
+// than leaving lipgloss to pick the nearest, which lands on muddy or
+// unreadable colors in terminals without true color
+var (
+	darkTheme = uiTheme{
+		Accent:    lipgloss.CompleteColor{TrueColor: "#04B575", ANSI256: "35", ANSI: "2"},
+		Text:      lipgloss.CompleteColor{TrueColor: "#FAFAFA", ANSI256: "255", ANSI: "15"},
+		Dim:       lipgloss.CompleteColor{TrueColor: "#626262", ANSI256: "241", ANSI: "8"},
+		Banner:    lipgloss.CompleteColor{TrueColor: "#FFB86C", ANSI256: "215", ANSI: "3"},
+		Error:     lipgloss.CompleteColor{TrueColor: "#FF5F5F", ANSI256: "203", ANSI: "9"},
+		MeterLoud: lipgloss.CompleteColor{TrueColor: "#F1C40F", ANSI256: "220", ANSI: "11"},
+	}
+	lightTheme = uiTheme{
+		Accent:    lipgloss.CompleteColor{TrueColor: "#00875F", ANSI256: "29", ANSI: "2"},
+		Text:      lipgloss.CompleteColor{TrueColor: "#1C1C1C", ANSI256: "234", ANSI: "0"},
+		Dim:       lipgloss.CompleteColor{TrueColor: "#6C6C6C", ANSI256: "242", ANSI: "8"},
+		Banner:    lipgloss.CompleteColor{TrueColor: "#AF5F00", ANSI256: "130", ANSI: "3"},
+		Error:     lipgloss.CompleteColor{TrueColor: "#D70000", ANSI256: "160", ANSI: "1"},
+		MeterLoud: lipgloss.CompleteColor{TrueColor: "#AF8700", ANSI256: "136", ANSI: "3"},
+	}
+	monoTheme = uiTheme{Mono: true}
+)
+
+// theme is the theme in use, chosen with --theme and with any [theme]
+// colors from the config file applied at startup
+var theme = darkTheme
+
+// validateTheme checks --theme
+func validateTheme(name string) error {
+	switch name {
+	case themeAuto, themeDark, themeLight, themeMono:
+		return nil
+	}
+	return fmt.Errorf("invalid --theme %q (want %s, %s, %s or %s)", name, themeAuto, themeDark, themeLight, themeMono)
+}
+
+// useTheme switches to the named theme. auto asks the terminal for its
+// background color, taking dark when it doesn't answer.
+func useTheme(name string) {
+	switch name {
+	case themeDark:
+		theme = darkTheme
+	case themeLight:
+		theme = lightTheme
+	case themeMono:
+		theme = monoTheme
+	default:
+		if lipgloss.HasDarkBackground() {
+			theme = darkTheme
+		} else {
+			theme = lightTheme
+		}
+	}
+}
+
+// viewStyles are the styles the views are drawn with, built once from the
+// theme after the config file had its say rather than on every frame
+type viewStyles struct {
+	Accent   lipgloss.Style // progress bars, the big clock
+	Text     lipgloss.Style
+	Dim      lipgloss.Style
+	Banner   lipgloss.Style
+	Error    lipgloss.Style
+	Loud     lipgloss.Style // meter levels near full scale
+	Title    lipgloss.Style // the player's heading
+	Track    lipgloss.Style // the track and chapter lines
+	Status   lipgloss.Style // status lines, a line apart
+	Alert    lipgloss.Style // audio errors in the player
+	Controls lipgloss.Style
+	Art      lipgloss.Style // the cover, beside the text
+	Header   lipgloss.Style // pane headings
+	Category lipgloss.Style // help groups
+	Cursor   lipgloss.Style
+
+	// NoArt leaves out the cover, which is drawn in its own colors
+	NoArt bool
+}
+
+// newViewStyles builds the styles for t
+func newViewStyles(t uiTheme) viewStyles {
+	if t.Mono {
+		return monoViewStyles()
+	}
+	return viewStyles{
+		Accent:   lipgloss.NewStyle().Foreground(t.Accent),
+		Text:     lipgloss.NewStyle().Foreground(t.Text),
+		Dim:      lipgloss.NewStyle().Foreground(t.Dim),
+		Banner:   lipgloss.NewStyle().Foreground(t.Banner),
+		Error:    lipgloss.NewStyle().Foreground(t.Error),
+		Loud:     lipgloss.NewStyle().Foreground(t.MeterLoud),
+		Title:    lipgloss.NewStyle().Bold(true).Foreground(t.Accent).MarginBottom(1),
+		Track:    lipgloss.NewStyle().Foreground(t.Text).MarginBottom(1),
+		Status:   lipgloss.NewStyle().Foreground(t.Dim).MarginBottom(1),
+		Alert:    lipgloss.NewStyle().Bold(true).Foreground(t.Error),
+		Controls: lipgloss.NewStyle().Foreground(t.Dim).MarginTop(2),
+		Art:      lipgloss.NewStyle().MarginRight(2),
+		Header:   lipgloss.NewStyle().Bold(true).Foreground(t.Accent),
+		Category: lipgloss.NewStyle().Bold(true).Foreground(t.Text),
+		Cursor:   lipgloss.NewStyle().Reverse(true),
+	}
+}
+
+// monoViewStyles builds the styles of the mono theme: what stands out is
+// bold, what recedes is faint, and errors are reversed
+func monoViewStyles() viewStyles {
+	return viewStyles{
+		Accent:   lipgloss.NewStyle().Bold(true),
+		Text:     lipgloss.NewStyle(),
+		Dim:      lipgloss.NewStyle().Faint(true),
+		Banner:   lipgloss.NewStyle().Bold(true),
+		Error:    lipgloss.NewStyle().Reverse(true),
+		Loud:     lipgloss.NewStyle().Bold(true),
+		Title:    lipgloss.NewStyle().Bold(true).MarginBottom(1),
+		Track:    lipgloss.NewStyle().MarginBottom(1),
+		Status:   lipgloss.NewStyle().Faint(true).MarginBottom(1),
+		Alert:    lipgloss.NewStyle().Bold(true).Reverse(true),
+		Controls: lipgloss.NewStyle().Faint(true).MarginTop(2),
+		Art:      lipgloss.NewStyle().MarginRight(2),
+		Header:   lipgloss.NewStyle().Bold(true),
+		Category: lipgloss.NewStyle().Bold(true),
+		Cursor:   lipgloss.NewStyle().Reverse(true),
+		NoArt:    true,
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestValidateTheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{themeAuto, false},
+		{themeDark, false},
+		{themeLight, false},
+		{themeMono, false},
+		{"", true},
+		{"Dark", true},
+		{"solarized", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTheme(tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("validateTheme(%q) = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestThemeGolden renders the player at 80x24 in each theme, in a true
+// color and a 16 color terminal, and compares it with
+// testdata/theme_<theme>_<profile>.golden. Run with -update to rewrite
+// them after a deliberate change to the palettes or the view.
+func TestThemeGolden(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	themes := []struct {
+		name  string
+		theme uiTheme
+	}{
+		{themeDark, darkTheme},
+		{themeLight, lightTheme},
+		{themeMono, monoTheme},
+	}
+	profiles := []struct {
+		name    string
+		profile termenv.Profile
+	}{
+		{"truecolor", termenv.TrueColor},
+		{"ansi", termenv.ANSI},
+	}
+	for _, th := range themes {
+		for _, p := range profiles {
+			name := "theme_" + th.name + "_" + p.name
+			t.Run(name, func(t *testing.T) {
+				lipgloss.SetColorProfile(p.profile)
+				defer func(saved glyphSet) { glyphs = saved }(glyphs)
+				glyphs = unicodeGlyphs
+				h := newHarness(t, album(3)...)
+				h.m.styles = newViewStyles(th.theme)
+				h.player.setTrack(album(1)[0], fakeTrack{length: 3 * time.Minute, artist: "Artist", title: "First Song"})
+				h.start()
+				h.advance(65 * time.Second)
+				h.run(h.m.showBanner("Saved the cover to /music/album/cover.jpg"))
+
+				compareGolden(t, filepath.Join("testdata", name+".golden"), h.m.View())
+			})
+		}
+	}
+}
+
+// compareGolden compares got with the golden file at path, or rewrites
+// the file with -update
+func compareGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	for i := range max(len(gotLines), len(wantLines)) {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("line %d differs from %s:\ngot  %q\nwant %q", i+1, path, g, w)
+		}
+	}
+}
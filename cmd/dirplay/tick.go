@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tick rates used by the tick policy. The baseline is the rate of the
+// shown time, see tickWait.
+const (
+	baselineTickInterval  = time.Second
+	fastTickInterval      = 100 * time.Millisecond
+	unfocusedTickInterval = time.Second
+)
+
+// renderFPS caps how often the terminal is redrawn, enough for the fast
+// tick rate
+const renderFPS = 20
+
+// clock is the time as the model sees it, and its timers. The program
+// runs on wallClock; tests step a fake one through a session without
+// waiting.
+type clock interface {
+	Now() time.Time
+	// Tick sends fn's message once d has passed, like tea.Tick
+	Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd
+}
+
+// wallClock is the real time
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+func (wallClock) Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd {
+	return tea.Tick(d, fn)
+}
+
+// tickPolicy decides how often the model ticks. Features that need smoother
+// updates (visualizer, meter, marquee, scrubbing) request a faster rate while
+// they are active instead of scheduling their own ticks.
+type tickPolicy struct {
+	baseline  time.Duration
+	unfocused time.Duration
+	focused   bool
+	requests  map[string]time.Duration
+
+	// clock times the ticks, and the model's other timers
+	clock clock
+
+	// chain numbers the running chain of ticks, see tickCmd
+	chain int
+}
+
+// newTickPolicy creates a tick policy running at the baseline rate on
+// clock
+func newTickPolicy(clock clock) *tickPolicy {
+	return &tickPolicy{
+		baseline:  baselineTickInterval,
+		unfocused: unfocusedTickInterval,
+		focused:   true,
+		requests:  make(map[string]time.Duration),
+		clock:     clock,
+	}
+}
+
+// now returns the time by the model's clock
+func (m *PlayerModel) now() time.Time {
+	return m.ticks.clock.Now()
+}
+
+// request asks for ticks at least as often as rate while feature is active
+func (p *tickPolicy) request(feature string, rate time.Duration) {
+	p.requests[feature] = rate
+}
+
+// release drops the rate requested by feature
+func (p *tickPolicy) release(feature string) {
+	delete(p.requests, feature)
+}
+
+// setFocused records whether the terminal currently has focus
+func (p *tickPolicy) setFocused(focused bool) {
+	p.focused = focused
+}
+
+// interval returns the effective tick interval. Unfocused terminals always
+// get the slow rate since nobody is watching the fast-moving features.
+func (p *tickPolicy) interval() time.Duration {
+	if !p.focused {
+		return p.unfocused
+	}
+
+	rate := p.baseline
+	for _, r := range p.requests {
+		if r > 0 && r < rate {
+			rate = r
+		}
+	}
+	return rate
+}
+
+// tickEndSlack is how long after the shown time changes a tick lands,
+// so it sees the new second rather than the end of the old one
+const tickEndSlack = 10 * time.Millisecond
+
+// tickWait returns how long until the next tick. Ticks every second, the
+// rate of the shown time, land just after it changes, so it moves on
+// exactly once a second whatever the position. Other rates, asked for by
+// a feature or set with tick_interval, are kept as they are. In the last
+// second of a track or preview it checks at the fast rate, so the next
+// track starts promptly when it wasn't preloaded.
+func (m *PlayerModel) tickWait() time.Duration {
+	interval := m.ticks.interval()
+	if interval != time.Second || !m.playing || m.speed <= 0 {
+		return interval
+	}
+
+	position := m.player.GetPosition()
+	end := m.duration
+	if m.previewing() {
+		end = m.preview.until
+	}
+	if end > 0 && end-position < time.Second {
+		return fastTickInterval
+	}
+	// The time shown is played time, which passes faster at higher speeds
+	untilNext := time.Second - position%time.Second
+	return time.Duration(math.Ceil(float64(untilNext)/m.speed)) + tickEndSlack
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// fakeClock is a clock that only moves when told to. Its timers wait to
+// be taken with due, in the order they fire.
+type fakeClock struct {
+	now    time.Time
+	timers []fakeTimer
+}
+
+// fakeTimer is a pending Tick
+type fakeTimer struct {
+	at time.Time
+	fn func(time.Time) tea.Msg
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// Tick starts the timer straight away; the command has nothing to do
+func (c *fakeClock) Tick(d time.Duration, fn func(time.Time) tea.Msg) tea.Cmd {
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), fn: fn})
+	return func() tea.Msg { return nil }
+}
+
+// due takes the first timer to fire by until off the clock and moves the
+// time on to when it fires, reporting false when none is due by then
+func (c *fakeClock) due(until time.Time) (fakeTimer, bool) {
+	first := -1
+	for i, timer := range c.timers {
+		if !timer.at.After(until) && (first < 0 || timer.at.Before(c.timers[first].at)) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return fakeTimer{}, false
+	}
+	timer := c.timers[first]
+	c.timers = append(c.timers[:first], c.timers[first+1:]...)
+	c.now = timer.at
+	return timer, true
+}
+
+// clockPlayer is a player whose position is the time passed on its
+// clock since start, at speed 1
+type clockPlayer struct {
+	player.Player
+	clock *fakeClock
+	start time.Time
+}
+
+func (p *clockPlayer) GetPosition() time.Duration { return p.clock.now.Sub(p.start) }
+
+func TestTickPolicyInterval(t *testing.T) {
+	p := newTickPolicy(newFakeClock())
+	steps := []struct {
+		name string
+		do   func()
+		want time.Duration
+	}{
+		{"baseline", func() {}, time.Second},
+		{"meter requested", func() { p.request("meter", fastTickInterval) }, fastTickInterval},
+		{"slower request too", func() { p.request("marquee", 500*time.Millisecond) }, fastTickInterval},
+		{"unfocused", func() { p.setFocused(false) }, unfocusedTickInterval},
+		{"focused again", func() { p.setFocused(true) }, fastTickInterval},
+		{"meter released", func() { p.release("meter") }, 500 * time.Millisecond},
+		{"all released", func() { p.release("marquee") }, time.Second},
+	}
+	for _, step := range steps {
+		step.do()
+		if got := p.interval(); got != step.want {
+			t.Errorf("%s: interval() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestTickWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		position time.Duration
+		duration time.Duration
+		speed    float64
+		request  time.Duration
+		want     time.Duration
+	}{
+		{"on a second", 4 * time.Second, time.Minute, 1, 0, time.Second + tickEndSlack},
+		{"part way into a second", 3250 * time.Millisecond, time.Minute, 1, 0, 750*time.Millisecond + tickEndSlack},
+		{"at double speed", 3250 * time.Millisecond, time.Minute, 2, 0, 375*time.Millisecond + tickEndSlack},
+		{"unknown length", 3250 * time.Millisecond, 0, 1, 0, 750*time.Millisecond + tickEndSlack},
+		{"last second", 59500 * time.Millisecond, time.Minute, 1, 0, fastTickInterval},
+		{"fast rate requested", 3250 * time.Millisecond, time.Minute, 1, fastTickInterval, fastTickInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			m := &PlayerModel{
+				ticks:    newTickPolicy(clock),
+				player:   &clockPlayer{clock: clock, start: clock.now.Add(-tt.position)},
+				playing:  true,
+				duration: tt.duration,
+				speed:    tt.speed,
+			}
+			if tt.request > 0 {
+				m.ticks.request("meter", tt.request)
+			}
+			if got := m.tickWait(); got != tt.want {
+				t.Errorf("tickWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTickCadence follows a chain of ticks through a 5 second track
+// started 300 ms into a second: they land just after each second of the
+// track, then every 100 ms in its last second
+func TestTickCadence(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.now.Add(-300 * time.Millisecond)
+	m := &PlayerModel{
+		ticks:    newTickPolicy(clock),
+		player:   &clockPlayer{clock: clock, start: start},
+		playing:  true,
+		duration: 5 * time.Second,
+		speed:    1,
+	}
+
+	var at []time.Duration
+	m.tickCmd()
+	for len(at) < 14 {
+		timer, ok := clock.due(clock.now.Add(time.Hour))
+		if !ok {
+			t.Fatal("the tick chain stopped")
+		}
+		if msg := timer.fn(clock.now).(tickMsg); msg.chain != m.ticks.chain {
+			t.Fatalf("tick of chain %d, want the running chain %d", msg.chain, m.ticks.chain)
+		}
+		at = append(at, clock.now.Sub(start))
+		m.tickCmd()
+	}
+
+	want := []time.Duration{1010, 2010, 3010, 4010, 4110, 4210, 4310, 4410, 4510, 4610, 4710, 4810, 4910, 5010}
+	for i := range want {
+		if at[i] != want[i]*time.Millisecond {
+			t.Fatalf("ticks landed at %v, want %v ms into the track", at, want)
+		}
+	}
+
+	// Each tick only schedules the next one
+	if len(clock.timers) != 1 {
+		t.Errorf("%d timers pending, want the one tick of the chain", len(clock.timers))
+	}
+}
+
+// TestModelTickRate toggles the features of a playing model that want
+// faster ticks and follows the rate of its ticks
+func TestModelTickRate(t *testing.T) {
+	h := newHarness(t, album(1)...).start()
+	h.advance(2250 * time.Millisecond)
+
+	steps := []struct {
+		name string
+		msg  tea.Msg
+		want time.Duration
+	}{
+		{"plain time", nil, 750*time.Millisecond + tickEndSlack},
+		{"meter shown", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")}, fastTickInterval},
+		{"unfocused", tea.BlurMsg{}, 750*time.Millisecond + tickEndSlack},
+		{"focused again", tea.FocusMsg{}, fastTickInterval},
+		{"meter hidden", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")}, 750*time.Millisecond + tickEndSlack},
+	}
+	for _, step := range steps {
+		if step.msg != nil {
+			h.send(step.msg)
+		}
+		if got := h.m.tickWait(); got != step.want {
+			t.Errorf("%s: tickWait() = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+// wakeups moves the clock on by d like advance, counting the timers that
+// fire on the way
+func (h *harness) wakeups(d time.Duration) int {
+	h.t.Helper()
+	n := 0
+	until := h.clock.now.Add(d)
+	for {
+		timer, ok := h.clock.due(until)
+		if !ok {
+			break
+		}
+		n++
+		h.send(timer.fn(h.clock.now))
+	}
+	h.clock.now = until
+	return n
+}
+
+// TestTickPolicyPaused counts the timers a model with its state saved
+// sets off in a minute: paused with no timer of its own running, such as
+// the sleep timer, nothing wakes it once the timers set before pausing
+// are through, and ticks and saves pick up again on resume
+func TestTickPolicyPaused(t *testing.T) {
+	tests := []struct {
+		name string
+		// keys are pressed after playing for 5 seconds
+		keys []string
+		// want is how many timers fire in the second minute, with the
+		// time shown and the state saved twice while playing
+		want int
+	}{
+		{"playing", nil, 62},
+		{"paused", []string{" "}, 0},
+		{"resumed", []string{" ", " "}, 62},
+		{"paused with the sleep timer", []string{" ", "t"}, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			track := filepath.Join(string(filepath.Separator)+"music", "long.mp3")
+			h.player.setTrack(track, fakeTrack{length: time.Hour})
+			h.m = NewPlayerModel([]string{track}, h.player, codec.New())
+			h.m.ticks.clock = h.clock
+			h.m.EnableStatePersistence("paused", -1, 0)
+			h.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+			h.start()
+			h.advance(5 * time.Second)
+			for _, k := range tt.keys {
+				h.press(k)
+			}
+
+			// The tick and state save on their way when pausing fire
+			// once, and set no more
+			h.advance(time.Minute)
+			if got := h.wakeups(time.Minute); got != tt.want {
+				t.Errorf("%d timers fired in a minute, want %d", got, tt.want)
+			}
+			if !h.m.paused {
+				return
+			}
+			if h.pendingTicks() != 0 {
+				t.Errorf("%d ticks pending while paused, want none", h.pendingTicks())
+			}
+
+			h.press(" ")
+			if h.pendingTicks() != 1 || !h.m.saving {
+				t.Errorf("resumed with %d ticks pending, saving = %v, want a tick and a save", h.pendingTicks(), h.m.saving)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+// infoTags returns the tag line of the info panel, e.g. "1997 · Trip Hop ·
+// Disc 1 · Track 04/12". The disc is left out of albums that say they have
+// only one.
+func infoTags(i player.TrackInfo) string {
+	var parts []string
+	if i.Year > 0 {
+		parts = append(parts, fmt.Sprint(i.Year))
+	}
+	if i.Genre != "" {
+		parts = append(parts, i.Genre)
+	}
+	if i.Disc > 0 && i.DiscTotal != 1 {
+		parts = append(parts, fmt.Sprintf("Disc %d", i.Disc))
+	}
+	switch {
+	case i.Track > 0 && i.TrackTotal > 0:
+		parts = append(parts, fmt.Sprintf("Track %02d/%02d", i.Track, i.TrackTotal))
+	case i.Track > 0:
+		parts = append(parts, fmt.Sprintf("Track %02d", i.Track))
+	}
+	if i.Composer != "" {
+		parts = append(parts, "composed by "+i.Composer)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// infoStream returns the file line of the info panel, e.g.
+// "FLAC · 44.1 kHz · 24-bit · stereo · 912 kbps · 31.2 MB"
+func infoStream(i player.TrackInfo) string {
+	var parts []string
+	if i.Format != "" {
+		parts = append(parts, i.Format)
+	}
+	if i.SampleRate > 0 {
+		parts = append(parts, formatRate(beep.SampleRate(i.SampleRate)))
+	}
+	if i.BitDepth > 0 {
+		parts = append(parts, fmt.Sprintf("%d-bit", i.BitDepth))
+	}
+	switch i.Channels {
+	case 0:
+	case 1:
+		parts = append(parts, "mono")
+	case 2:
+		parts = append(parts, "stereo")
+	default:
+		parts = append(parts, fmt.Sprintf("%d channels", i.Channels))
+	}
+	if i.Bitrate > 0 {
+		parts = append(parts, fmt.Sprintf("%d kbps", i.Bitrate))
+	}
+	if i.Size > 0 {
+		parts = append(parts, formatSize(i.Size))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatRate formats a sample rate in kHz, e.g. "44.1 kHz"
+func formatRate(rate beep.SampleRate) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(rate)/1000), ".0") + " kHz"
+}
+
+// resampleLabel returns e.g. "44.1 kHz → 48 kHz (resampled)" when a track
+// decoded at rate plays on a speaker running at another one, or ""
+func resampleLabel(rate, output beep.SampleRate) string {
+	if rate == 0 || output == 0 || rate == output {
+		return ""
+	}
+	return formatRate(rate) + " " + glyphs.Arrow + " " + formatRate(output) + " (resampled)"
+}
+
+// formatSize formats a file size in KB or MB
+func formatSize(size int64) string {
+	if size < 1<<20 {
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
+
+// durationMismatch returns a warning when the tagged length and the decoded
+// length differ by more than tolerance percent, or nil
+func durationMismatch(tagged, decoded time.Duration, tolerance float64) error {
+	if tagged <= 0 || decoded <= 0 || tolerance <= 0 {
+		return nil
+	}
+
+	diff := tagged - decoded
+	if diff < 0 {
+		diff = -diff
+	}
+	if float64(diff) <= float64(tagged)*tolerance/100 {
+		return nil
+	}
+
+	if decoded < tagged {
+		return fmt.Errorf("file appears truncated: tag says %s, stream is %s", formatDuration(tagged), formatDuration(decoded))
+	}
+	return fmt.Errorf("stream is longer than its tag: tag says %s, stream is %s", formatDuration(tagged), formatDuration(decoded))
+}
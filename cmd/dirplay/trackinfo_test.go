@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/punkscience/dirplay/pkg/player"
+)
+
+func TestInfoTags(t *testing.T) {
+	tests := []struct {
+		name string
+		info player.TrackInfo
+		want string
+	}{
+		{"nothing", player.TrackInfo{}, ""},
+		{"all", player.TrackInfo{Year: 1997, Genre: "Trip Hop", Disc: 1, DiscTotal: 2, Track: 4, TrackTotal: 12}, "1997 · Trip Hop · Disc 1 · Track 04/12"},
+		{"disc without a total", player.TrackInfo{Disc: 2, Track: 4, TrackTotal: 12}, "Disc 2 · Track 04/12"},
+		{"only disc", player.TrackInfo{Disc: 1, DiscTotal: 1, Track: 4, TrackTotal: 12}, "Track 04/12"},
+		{"track without a total", player.TrackInfo{Track: 7}, "Track 07"},
+		{"total without a track", player.TrackInfo{TrackTotal: 12}, ""},
+		{"composer", player.TrackInfo{Track: 1, Composer: "Satie"}, "Track 01 · composed by Satie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := infoTags(tt.info); got != tt.want {
+				t.Errorf("infoTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
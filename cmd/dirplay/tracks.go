@@ -0,0 +1,84 @@
+package main
+
+// trackID identifies a scanned file for the whole session. The playlist is
+// a sequence of IDs so that adding, removing or filtering entries can never
+// make the current position point at a different song.
+type trackID int
+
+// noTrack is the zero ID, never assigned to a file
+const noTrack trackID = 0
+
+// trackTable maps track IDs to file paths. IDs are handed out in scan order
+// and never reused, even after a file leaves the playlist.
+type trackTable struct {
+	paths map[trackID]string
+	ids   map[string]trackID
+	next  trackID
+}
+
+// newTrackTable creates an empty track table
+func newTrackTable() *trackTable {
+	return &trackTable{
+		paths: make(map[trackID]string),
+		ids:   make(map[string]trackID),
+		next:  1,
+	}
+}
+
+// Add returns the ID of path, assigning a new one if it isn't known yet
+func (t *trackTable) Add(path string) trackID {
+	if id, ok := t.ids[path]; ok {
+		return id
+	}
+
+	id := t.next
+	t.next++
+	t.paths[id] = path
+	t.ids[path] = id
+	return id
+}
+
+// Known reports whether path has been assigned an ID
+func (t *trackTable) Known(path string) bool {
+	_, ok := t.ids[path]
+	return ok
+}
+
+// Rename moves a known track to a new path, keeping its ID. A track
+// already at the new path was replaced, and its ID resolves to no path
+// from now on.
+func (t *trackTable) Rename(from, to string) {
+	id, ok := t.ids[from]
+	if !ok || from == to {
+		return
+	}
+	if replaced, ok := t.ids[to]; ok {
+		delete(t.paths, replaced)
+	}
+	delete(t.ids, from)
+	t.paths[id] = to
+	t.ids[to] = id
+}
+
+// AddAll returns the IDs of every path, in order
+func (t *trackTable) AddAll(paths []string) []trackID {
+	ids := make([]trackID, len(paths))
+	for i, path := range paths {
+		ids[i] = t.Add(path)
+	}
+	return ids
+}
+
+// Path returns the file path of a track, or "" for an unknown ID
+func (t *trackTable) Path(id trackID) string {
+	return t.paths[id]
+}
+
+// Paths returns the file paths of the given tracks, in order
+func (t *trackTable) Paths(ids []trackID) []string {
+	paths := make([]string, len(ids))
+	for i, id := range ids {
+		paths[i] = t.paths[id]
+	}
+	return paths
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestTrackTableProperties runs random sequences of adds, reshuffles,
+// filters, removals and renames on a table and the playlist of its IDs,
+// checking after each that every ID still resolves to its own file, that
+// no two IDs resolve to the same one, and that no ID is handed out twice
+func TestTrackTableProperties(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			table := newTrackTable()
+			var playlist []trackID
+			// want is the path each ID handed out resolves to, "" once
+			// the file was replaced
+			want := make(map[trackID]string)
+			files := 0
+			var ops []string
+
+			// known returns the paths with IDs, removed from the
+			// playlist or not
+			known := func() []string {
+				var paths []string
+				for id := trackID(1); id <= trackID(len(want)); id++ {
+					if want[id] != "" {
+						paths = append(paths, want[id])
+					}
+				}
+				return paths
+			}
+
+			for range 200 {
+				switch op := rng.Intn(5); {
+				case op == 0 || len(playlist) < 2:
+					var paths []string
+					for range 1 + rng.Intn(3) {
+						files++
+						paths = append(paths, fmt.Sprintf("/music/%03d.mp3", files))
+					}
+					if existing := known(); len(existing) > 0 {
+						paths = append(paths, existing[rng.Intn(len(existing))])
+					}
+					ops = append(ops, fmt.Sprintf("add %v", paths))
+					for i, id := range table.AddAll(paths) {
+						if _, ok := want[id]; !ok {
+							if id <= trackID(len(want)) {
+								t.Fatalf("after %v: %s got ID %d, handed out before", ops, paths[i], id)
+							}
+							want[id] = paths[i]
+						}
+						// A removed file coming back keeps its ID
+						if !slices.Contains(playlist, id) {
+							playlist = append(playlist, id)
+						}
+					}
+
+				case op == 1:
+					// As the album and smart shuffles do, via the paths
+					ops = append(ops, "reshuffle")
+					shuffled := slices.Clone(playlist)
+					rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+					playlist = table.AddAll(table.Paths(shuffled))
+					if !slices.Equal(playlist, shuffled) {
+						t.Fatalf("after %v: IDs %v, want %v", ops, playlist, shuffled)
+					}
+
+				case op == 2:
+					digit := fmt.Sprint(rng.Intn(10))
+					ops = append(ops, "filter "+digit)
+					var matches []trackID
+					for _, id := range playlist {
+						if strings.Contains(table.Path(id), digit+".mp3") {
+							matches = append(matches, id)
+						}
+					}
+					if len(matches) > 0 {
+						playlist = matches
+					}
+
+				case op == 3:
+					i := rng.Intn(len(playlist))
+					ops = append(ops, fmt.Sprintf("remove %s", table.Path(playlist[i])))
+					playlist = slices.Delete(playlist, i, i+1)
+
+				case op == 4:
+					id := playlist[rng.Intn(len(playlist))]
+					from := table.Path(id)
+					files++
+					to := fmt.Sprintf("/music/renamed/%03d.mp3", files)
+					other := playlist[rng.Intn(len(playlist))]
+					if rng.Intn(2) == 0 && other != id {
+						// Over another track
+						to = table.Path(other)
+						want[other] = ""
+						playlist = slices.DeleteFunc(playlist, func(id trackID) bool { return id == other })
+					}
+					ops = append(ops, fmt.Sprintf("rename %s to %s", from, to))
+					table.Rename(from, to)
+					want[id] = to
+				}
+
+				for id, path := range want {
+					if got := table.Path(id); got != path {
+						t.Fatalf("after %v: ID %d resolves to %q, want %q", ops, id, got, path)
+					}
+					if path != "" && table.ids[path] != id {
+						t.Fatalf("after %v: %s has ID %d, want %d", ops, path, table.ids[path], id)
+					}
+				}
+				if len(table.ids) != len(table.paths) {
+					t.Fatalf("after %v: %d paths have IDs, but %d IDs have paths", ops, len(table.ids), len(table.paths))
+				}
+				for i, id := range playlist {
+					if table.Path(id) == "" || slices.Contains(playlist[:i], id) {
+						t.Fatalf("after %v: playlist %v holds ID %d twice or with no file", ops, playlist, id)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestWatchRenameOverTrack renames tracks over others in the playlist:
+// the one replaced leaves it, and the renamed one keeps its place
+func TestWatchRenameOverTrack(t *testing.T) {
+	tracks := album(4)
+	tests := []struct {
+		name string
+		// skips are the tracks skipped to before the rename
+		skips   int
+		from    string
+		to      string
+		want    []string
+		playing string
+		banner  string
+	}{
+		{"upcoming over upcoming", 0, tracks[1], tracks[2], []string{"01.mp3", "03.mp3", "04.mp3"}, "01.mp3", "1 tracks removed"},
+		{"upcoming over played", 2, tracks[3], tracks[0], []string{"02.mp3", "03.mp3", "01.mp3"}, "03.mp3", "1 tracks removed"},
+		{"over the current track", 0, tracks[1], tracks[0], []string{"01.mp3", "03.mp3", "04.mp3"}, "01.mp3", "current track was deleted"},
+		{"the current track over another", 0, tracks[0], tracks[3], []string{"04.mp3", "02.mp3", "03.mp3"}, "04.mp3", "1 tracks removed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, tracks...).start()
+			for range tt.skips {
+				h.press("right")
+			}
+			current := h.m.current
+			fromID := h.m.tracks.Add(tt.from)
+
+			h.send(playlistChangedMsg{renamed: map[string]string{tt.from: tt.to}})
+
+			var names []string
+			seen := make(map[string]bool)
+			for _, path := range h.m.tracks.Paths(h.m.playlist) {
+				if seen[path] {
+					t.Errorf("two tracks of the playlist resolve to %s", path)
+				}
+				seen[path] = true
+				names = append(names, path[len(path)-6:])
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("playlist %v, want %v", names, tt.want)
+			}
+			if id := h.m.tracks.Add(tt.to); id != fromID {
+				t.Errorf("%s has ID %d, want %d of the renamed track", tt.to, id, fromID)
+			}
+			if h.playing() != tt.playing {
+				t.Errorf("playing %s, want %s", h.playing(), tt.playing)
+			}
+			if tt.from != tracks[0] && tt.to != tracks[0] && h.m.current != current {
+				t.Errorf("current track changed from %d to %d", current, h.m.current)
+			}
+			if !strings.Contains(h.m.banner, tt.banner) {
+				t.Errorf("banner %q, want %q", h.m.banner, tt.banner)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// "u" undoes the last undoLimit manual navigations made within
+// undoWindow of it
+const (
+	undoLimit  = 5
+	undoWindow = 30 * time.Second
+)
+
+// undoEntry is where playback was before a manual navigation, and when it
+// left. Tracks moving on by themselves aren't recorded, and the entries
+// only last the session.
+type undoEntry struct {
+	id       trackID
+	position time.Duration
+	at       time.Time
+}
+
+// recordUndo remembers the track playing and its position before the
+// user moves away from it with next, previous, an album jump or a pick
+// from a pane
+func (m *PlayerModel) recordUndo() {
+	if !m.playing || m.current == noTrack {
+		return
+	}
+	m.undo = append(m.undo, undoEntry{id: m.current, position: m.player.GetPosition(), at: m.now()})
+	if len(m.undo) > undoLimit {
+		m.undo = append(m.undo[:0], m.undo[len(m.undo)-undoLimit:]...)
+	}
+}
+
+// undoNavigation returns to where the last manual navigation left, the
+// track and its position. Pressed again it goes further back. Entries for
+// tracks filtered out of the playlist are passed over.
+func (m *PlayerModel) undoNavigation() tea.Cmd {
+	for len(m.undo) > 0 {
+		entry := m.undo[len(m.undo)-1]
+		m.undo = m.undo[:len(m.undo)-1]
+		if m.now().Sub(entry.at) > undoWindow {
+			// The rest are older still
+			m.undo = nil
+			break
+		}
+
+		index := m.indexOf(entry.id)
+		if index < 0 {
+			continue
+		}
+		banner := m.showBanner("Restored: " + m.paneEntryName(m.tracks.Path(entry.id)) + " at " + formatDuration(entry.position))
+		if entry.id == m.current && m.playing {
+			return tea.Batch(m.seekTo(entry.position), banner)
+		}
+
+		m.player.Stop()
+		m.leaveTrack()
+		m.queue.Leave()
+		m.currentIndex = index
+		m.resumeAt = entry.position
+		return tea.Batch(m.loadCurrentTrack(), banner)
+	}
+	return m.showBanner("Nothing to undo")
+}
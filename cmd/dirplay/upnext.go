@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/punkscience/dirplay/pkg/playlist"
+)
+
+// vetoPicks bounds how often --weights draws again for a track other
+// than the one passed over
+const vetoPicks = 8
+
+// nextLabel names the track that plays after the current one, or returns
+// "" when none does, e.g. at the end of the playlist or while looping. It
+// asks nextIndex, which advancing follows too, so the line changes with
+// the queue, the filter or the playlist order.
+func (m *PlayerModel) nextLabel() string {
+	if !m.playing || m.looping() || len(m.playlist) < 2 || m.atPlaylistEnd() {
+		return ""
+	}
+
+	label := "Next: " + m.paneEntryName(m.tracks.Path(m.playlist[m.nextIndex()]))
+	if m.queueHead() >= 0 {
+		if queued := m.queue.Len(); queued > 1 {
+			label += fmt.Sprintf(" (queued, +%d more)", queued-1)
+		} else {
+			label += " (queued)"
+		}
+	}
+	return label
+}
+
+// vetoNext passes over the track that would play next without touching
+// the one playing: a queued track comes off the queue, --weights draws
+// again, and otherwise the track trades places with a later one, picked
+// at random when the playlist is shuffled track by track, or the one
+// after it when the order means something. The track passed over still
+// plays later in the playlist.
+func (m *PlayerModel) vetoNext() tea.Cmd {
+	if !m.playing {
+		return nil
+	}
+	if m.looping() {
+		return m.showBanner(fmt.Sprintf("Looping %s, no track comes next until %s ends the loop", m.loopRange(), m.keys.ClearLoop.Help().Key))
+	}
+	if len(m.playlist) < 2 || m.atPlaylistEnd() {
+		return m.showBanner("No track comes next")
+	}
+
+	next := m.nextIndex()
+	vetoed := m.playlist[next]
+	switch {
+	case m.queueHead() >= 0:
+		m.queue.Remove(vetoed)
+	case m.weighted != nil:
+		if !m.repickWeighted(vetoed) {
+			return m.showBanner("Nothing else to pick instead")
+		}
+	default:
+		if !m.swapNext(next) {
+			return m.showBanner("Nothing else left to play instead")
+		}
+	}
+
+	banner := "Passed over " + m.paneEntryName(m.tracks.Path(vetoed))
+	if label := m.nextLabel(); label != "" {
+		banner += ", " + label
+	}
+	return tea.Batch(m.showBanner(banner), m.preloadNext())
+}
+
+// repickWeighted draws the next track with --weights again, until it is
+// one other than vetoed
+func (m *PlayerModel) repickWeighted(vetoed trackID) bool {
+	s := m.weighted
+	for range vetoPicks {
+		index := s.pick(m.playlist, m.tracks.Path, m.current)
+		if index >= 0 && m.playlist[index] != vetoed {
+			s.next = m.playlist[index]
+			return true
+		}
+	}
+	return false
+}
+
+// swapNext trades the playlist entry at next with one still to come
+// after it, in the full playlist as well so the filter keeps the order.
+// Without --at-end repeat the tracks before the current one have played
+// and are left alone.
+func (m *PlayerModel) swapNext(next int) bool {
+	n := len(m.playlist)
+	base := m.playlistBase()
+	wraps := m.atEnd == "" || m.atEnd == atEndRepeat
+
+	var later []int
+	for k := 1; k < n; k++ {
+		j := (next + k) % n
+		if j == base || j < next && !wraps {
+			break
+		}
+		later = append(later, j)
+	}
+	if len(later) == 0 {
+		return false
+	}
+
+	other := later[0]
+	if m.shuffleMode == playlist.ShuffleTrack && !m.keepOrder {
+		other = later[rand.Intn(len(later))]
+	}
+
+	a, b := m.playlist[next], m.playlist[other]
+	m.playlist[next], m.playlist[other] = b, a
+	i, j := slices.Index(m.fullPlaylist, a), slices.Index(m.fullPlaylist, b)
+	if i >= 0 && j >= 0 {
+		m.fullPlaylist[i], m.fullPlaylist[j] = b, a
+	}
+	m.indexAlbums(min(next, other))
+	return true
+}
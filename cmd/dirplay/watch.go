@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// Changes seen by --watch are collected until nothing happened for
+// watchSettle, so a file still being copied isn't picked up half written,
+// but for no longer than watchMaxDelay during a long copy
+const (
+	watchSettle   = time.Second
+	watchMaxDelay = 5 * time.Second
+)
+
+// playlistChangedMsg carries a batch of changes to the watched
+// directories. Removed paths may be directories, taking every track below
+// them along.
+type playlistChangedMsg struct {
+	added   []string
+	removed []string
+	renamed map[string]string
+}
+
+// watchBatch collects changes to the files codecs plays until they are
+// sent
+type watchBatch struct {
+	codecs  *codec.Registry
+	added   map[string]bool
+	removed map[string]bool
+	renamed map[string]string
+
+	// Paths renamed away, waiting for the create of their new name
+	renaming []string
+	first    time.Time
+}
+
+// newWatchBatch returns an empty batch
+func newWatchBatch(codecs *codec.Registry) *watchBatch {
+	return &watchBatch{
+		codecs:  codecs,
+		added:   make(map[string]bool),
+		removed: make(map[string]bool),
+		renamed: make(map[string]string),
+	}
+}
+
+// empty reports whether the batch holds no changes
+func (b *watchBatch) empty() bool {
+	return len(b.added) == 0 && len(b.removed) == 0 && len(b.renamed) == 0 && len(b.renaming) == 0
+}
+
+// msg returns the changes in the batch. Renames whose new name never
+// showed up, e.g. moved out of the watched directories, count as removed.
+func (b *watchBatch) msg() playlistChangedMsg {
+	for _, path := range b.renaming {
+		b.removed[path] = true
+	}
+
+	msg := playlistChangedMsg{renamed: b.renamed}
+	for path := range b.added {
+		msg.added = append(msg.added, path)
+	}
+	for path := range b.removed {
+		msg.removed = append(msg.removed, path)
+	}
+	return msg
+}
+
+// add records a new file. Right after a rename it is taken to be the new
+// name of the renamed file, which is how file systems report a rename.
+func (b *watchBatch) add(path string) {
+	if len(b.renaming) > 0 && b.codecs.IsAudioFile(b.renaming[0]) == b.codecs.IsAudioFile(path) {
+		b.renamed[b.renaming[0]] = path
+		b.renaming = b.renaming[1:]
+		return
+	}
+	delete(b.removed, path)
+	b.added[path] = true
+}
+
+// remove records a file or directory that is gone
+func (b *watchBatch) remove(path string) {
+	delete(b.added, path)
+	b.removed[path] = true
+}
+
+// startWatch watches the directories among the sources, and every
+// directory below them, as background work and returns the channel its
+// batches of changes arrive on. Directories created later are watched
+// as they appear.
+func startWatch(work *backgroundWork, config scan.Config, sources []string) (<-chan tea.Msg, error) {
+	if config.Codecs == nil {
+		config.Codecs = codec.New()
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	roots := watchRoots(sources)
+	if len(roots) == 0 {
+		watcher.Close()
+		return nil, fmt.Errorf("no directories to watch")
+	}
+	for _, root := range roots {
+		watchTree(config, watcher, root, root, nil)
+	}
+
+	ch := make(chan tea.Msg)
+	work.Go(func(ctx context.Context) {
+		defer close(ch)
+		defer watcher.Close()
+
+		batch := newWatchBatch(config.Codecs)
+		timer := time.NewTimer(watchSettle)
+		timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case _, ok := <-watcher.Errors:
+				// An overflowing event queue loses changes, which only a
+				// restart or rescan picks up
+				if !ok {
+					return
+				}
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !handleWatchEvent(config, watcher, roots, batch, event) {
+					continue
+				}
+				if batch.first.IsZero() {
+					batch.first = time.Now()
+				}
+				timer.Reset(min(watchSettle, max(watchMaxDelay-time.Since(batch.first), 0)))
+
+			case <-timer.C:
+				if batch.empty() {
+					continue
+				}
+				select {
+				case ch <- batch.msg():
+					batch = newWatchBatch(config.Codecs)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	})
+	return ch, nil
+}
+
+// watchRoots returns the sources that are directories
+func watchRoots(sources []string) []string {
+	var roots []string
+	for _, source := range sources {
+		if info, err := os.Stat(source); err == nil && info.IsDir() {
+			roots = append(roots, source)
+		}
+	}
+	return roots
+}
+
+// rootOf returns the watched root a path lies under
+func rootOf(roots []string, path string) string {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return root
+		}
+	}
+	return ""
+}
+
+// watchTree adds dir and the directories below it to the watcher, as the
+// scan would walk them, passing the audio files already in them to found
+func watchTree(c scan.Config, watcher *fsnotify.Watcher, root, dir string, found func(path string)) {
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != root && c.Excluded(root, path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			if found != nil && c.Codecs.IsAudioFile(path) {
+				found(path)
+			}
+			return nil
+		}
+
+		if rel, err := filepath.Rel(root, path); err == nil && c.MaxDepth > 0 && rel != "." && len(strings.Split(rel, string(filepath.Separator))) > c.MaxDepth {
+			return filepath.SkipDir
+		}
+		watcher.Add(path)
+		return nil
+	})
+}
+
+// handleWatchEvent adds an event to the batch, reporting whether it was
+// one that matters. Writes count too: they hold the batch back while a
+// file is still being copied.
+func handleWatchEvent(c scan.Config, watcher *fsnotify.Watcher, roots []string, batch *watchBatch, event fsnotify.Event) bool {
+	path := event.Name
+	root := rootOf(roots, path)
+	if root == "" || c.Excluded(root, path) {
+		return false
+	}
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		if info.IsDir() {
+			// Files can land in a new directory before it is watched
+			watchTree(c, watcher, root, path, batch.add)
+			return true
+		}
+		if !c.Codecs.IsAudioFile(path) {
+			return false
+		}
+		batch.add(path)
+		return true
+
+	case event.Has(fsnotify.Rename):
+		batch.renaming = append(batch.renaming, path)
+		return true
+
+	case event.Has(fsnotify.Remove):
+		batch.remove(path)
+		return true
+
+	case event.Has(fsnotify.Write):
+		return batch.added[path]
+	}
+	return false
+}
+
+// StartWatch follows changes to the directories among the sources while
+// playing, see startWatch
+func (m *PlayerModel) StartWatch() error {
+	watch, err := startWatch(m.work, m.scanConfig, m.sources)
+	if err != nil {
+		return err
+	}
+	m.watch = watch
+	return nil
+}
+
+// waitForWatch delivers the next batch of changes from the watcher
+func (m *PlayerModel) waitForWatch() tea.Cmd {
+	if m.watch == nil {
+		return nil
+	}
+
+	watch := m.watch
+	return func() tea.Msg {
+		msg, ok := <-watch
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// playlistChanged applies a batch of changes from the watcher: renamed
+// tracks keep their place, removed ones leave the playlist, skipping on
+// if the current track is among them, and new ones are shuffled in at
+// the end
+func (m *PlayerModel) playlistChanged(msg playlistChangedMsg) tea.Cmd {
+	// A track renamed over another one, not moved away itself, replaces
+	// it, so that one leaves the playlist while its path still tells the
+	// two apart
+	var replaced []string
+	for from, to := range msg.renamed {
+		_, moved := msg.renamed[to]
+		if from != to && !moved && m.tracks.Known(from) && m.tracks.Known(to) {
+			replaced = append(replaced, to)
+		}
+	}
+	removed, skipped := m.removeTracks(replaced)
+
+	for from, to := range msg.renamed {
+		if !m.renameTrack(from, to) {
+			msg.added = append(msg.added, to)
+		}
+	}
+
+	// A music directory that went away takes its files along, which
+	// isn't a reason to forget them
+	if m.storageWaiting() {
+		msg.removed = nil
+	}
+
+	cmds := []tea.Cmd{m.waitForWatch()}
+	gone, skippedGone := m.removeTracks(msg.removed)
+	removed += gone
+	skipped = skipped || skippedGone
+	switch {
+	case skipped && m.current == noTrack:
+		cmds = append(cmds, m.showBanner("The current track was deleted, nothing left to play"))
+	case skipped:
+		cmds = append(cmds, m.showBanner("The current track was deleted, skipped to the next"), m.loadCurrentTrack())
+	case removed > 0:
+		cmds = append(cmds, m.showBanner(fmt.Sprintf("%d tracks removed from the playlist", removed)))
+	}
+
+	// Tracks going back into an emptied playlist start playing
+	return tea.Batch(append(cmds, m.addWatched(msg.added))...)
+}
+
+// renameTrack moves a track to its new path, reporting whether it was in
+// the playlist
+func (m *PlayerModel) renameTrack(from, to string) bool {
+	if !m.tracks.Known(from) {
+		return false
+	}
+
+	m.tracks.Rename(from, to)
+	if tags, ok := m.knownTags[from]; ok {
+		delete(m.knownTags, from)
+		m.knownTags[to] = tags
+	}
+	if position, ok := m.bookmarks[from]; ok {
+		delete(m.bookmarks, from)
+		m.bookmarks[to] = position
+	}
+	return true
+}
+
+// removeTracks drops the tracks at or below the removed paths from the
+// playlist and returns how many left it. If the current track is gone,
+// the one that followed it becomes current, to be loaded by the caller,
+// or none if the playlist is empty now.
+func (m *PlayerModel) removeTracks(removed []string) (count int, skipped bool) {
+	if len(removed) == 0 {
+		return 0, false
+	}
+
+	gone := func(path string) bool {
+		for _, r := range removed {
+			if path == r || strings.HasPrefix(path, r+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	keep := func(ids []trackID) []trackID {
+		var kept []trackID
+		for _, id := range ids {
+			if !gone(m.tracks.Path(id)) {
+				kept = append(kept, id)
+			}
+		}
+		return kept
+	}
+
+	before := len(m.fullPlaylist)
+	m.fullPlaylist = keep(m.fullPlaylist)
+	if before == len(m.fullPlaylist) {
+		return 0, false
+	}
+
+	// The first track after the current one that is still there
+	next := noTrack
+	skipped = m.current != noTrack && gone(m.currentTrack())
+	if skipped {
+		for i := 1; i <= len(m.playlist); i++ {
+			if id := m.playlist[(m.currentIndex+i)%len(m.playlist)]; !gone(m.tracks.Path(id)) {
+				next = id
+				break
+			}
+		}
+		m.player.Stop()
+		m.leaveTrack()
+		delete(m.bookmarks, m.currentTrack())
+		m.playing = false
+		m.paused = false
+		m.current = next
+	}
+
+	m.setPlaylist(keep(m.playlist))
+	return before - len(m.fullPlaylist), skipped
+}
+
+// addWatched shuffles new files onto the end of the playlist, and starts
+// playing the first of them if nothing plays
+func (m *PlayerModel) addWatched(paths []string) tea.Cmd {
+	inPlaylist := make(map[string]bool, len(m.fullPlaylist))
+	for _, id := range m.fullPlaylist {
+		inPlaylist[m.tracks.Path(id)] = true
+	}
+
+	var fresh []string
+	for _, path := range paths {
+		if !inPlaylist[path] && matchesFilter(path, trackTags{}, m.scanFilter) {
+			fresh = append(fresh, path)
+		}
+	}
+	fresh = m.keepRated(fresh)
+	fresh = m.keepWeighted(fresh)
+	fresh, hidden := m.dedupeByName(fresh)
+	if len(fresh) == 0 {
+		return hidden
+	}
+
+	wasLast := m.currentIndex == len(m.playlist)-1
+	first := m.appendPaths(fresh)
+	m.indexTags(fresh)
+
+	cmds := []tea.Cmd{m.showBanner(fmt.Sprintf("Found %d new tracks", len(fresh))), m.fingerprint(fresh), hidden}
+	switch {
+	case first < 0:
+	case m.current == noTrack:
+		m.currentIndex = first
+		cmds = append(cmds, m.loadCurrentTrack())
+	case m.playing && wasLast:
+		cmds = append(cmds, m.preloadNext())
+	}
+	return tea.Batch(cmds...)
+}
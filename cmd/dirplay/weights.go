@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// folderWeights are the --weights of the top-level folders under the scan
+// roots, keyed by lower-cased folder name. Folders not named weigh 1, and
+// a weight of 0 leaves a folder out.
+type folderWeights map[string]float64
+
+// parseWeights parses --weights, e.g. "jazz=3,podcasts=1,kids=0". ""
+// means no weights, which plays the shuffled playlist as usual.
+func parseWeights(s string) (folderWeights, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	invalid := fmt.Errorf("invalid --weights %q (want folder=weight pairs, e.g. jazz=3,podcasts=1,kids=0)", s)
+	weights := make(folderWeights)
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, `/\`) {
+			return nil, invalid
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 || weight > 1e6 {
+			return nil, invalid
+		}
+		weights[strings.ToLower(name)] = weight
+	}
+	return weights, nil
+}
+
+// of returns the weight of a folder
+func (w folderWeights) of(folder string) float64 {
+	if weight, ok := w[strings.ToLower(folder)]; ok {
+		return weight
+	}
+	return 1
+}
+
+// folderOf returns the first path component of path below the root it was
+// scanned from, or "" for files right in a root or given on their own
+func folderOf(roots []string, path string) string {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if folder, _, ok := strings.Cut(rel, string(filepath.Separator)); ok {
+			return folder
+		}
+		return ""
+	}
+	return ""
+}
+
+// weightedSampler picks the track after the current one at random with
+// --weights: first a folder, with a chance in proportion to its weight
+// whatever number of tracks it holds, then one of its tracks. Nothing is
+// shuffled ahead; the pick is made when something asks what plays next and
+// kept until playback gets there, so the preloaded track is the one that
+// plays.
+type weightedSampler struct {
+	weights folderWeights
+	roots   []string
+	rand    *rand.Rand
+
+	// next is the track picked to play next, or noTrack
+	next trackID
+	// plays counts the tracks started, shown in place of the playlist
+	// position
+	plays int
+}
+
+// newWeightedSampler returns a sampler for the folders under roots
+func newWeightedSampler(weights folderWeights, roots []string) *weightedSampler {
+	return &weightedSampler{
+		weights: weights,
+		roots:   roots,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		next:    noTrack,
+	}
+}
+
+// excluded reports whether path lies in a folder weighted 0
+func (s *weightedSampler) excluded(path string) bool {
+	return s.weights.of(folderOf(s.roots, path)) == 0
+}
+
+// pick draws a track from the playlist, avoiding current when its folder
+// has another one. It returns -1 when no track has a weight above 0.
+func (s *weightedSampler) pick(playlist []trackID, path func(trackID) string, current trackID) int {
+	var folders []string
+	members := make(map[string][]int)
+	for i, id := range playlist {
+		folder := folderOf(s.roots, path(id))
+		if _, ok := members[folder]; !ok {
+			folders = append(folders, folder)
+		}
+		members[folder] = append(members[folder], i)
+	}
+
+	total := 0.0
+	for _, folder := range folders {
+		total += s.weights.of(folder)
+	}
+	if total == 0 {
+		return -1
+	}
+
+	// Folders are walked in the order they were first met, so the same
+	// draw always lands on the same folder
+	draw := s.rand.Float64() * total
+	folder := folders[len(folders)-1]
+	for _, candidate := range folders {
+		if draw < s.weights.of(candidate) {
+			folder = candidate
+			break
+		}
+		draw -= s.weights.of(candidate)
+	}
+	for s.weights.of(folder) == 0 {
+		// Rounding carried the draw past the last folder with a weight
+		folders = folders[:len(folders)-1]
+		folder = folders[len(folders)-1]
+	}
+
+	tracks := members[folder]
+	index := tracks[s.rand.Intn(len(tracks))]
+	if playlist[index] == current && len(tracks) > 1 {
+		// Another of the folder's tracks, each as likely
+		other := s.rand.Intn(len(tracks) - 1)
+		if tracks[other] == index {
+			other = len(tracks) - 1
+		}
+		index = tracks[other]
+	}
+	return index
+}
+
+// EnableWeights picks tracks with --weights for the folders below the
+// directory sources instead of playing through a shuffled playlist
+func (m *PlayerModel) EnableWeights(weights folderWeights, sources []string) {
+	if weights == nil {
+		return
+	}
+	m.weighted = newWeightedSampler(weights, watchRoots(sources))
+	// The playlist order no longer matters, the pane lists it as scanned
+	m.keepOrder = true
+}
+
+// keepWeighted drops the paths in folders weighted 0
+func (m *PlayerModel) keepWeighted(paths []string) []string {
+	if m.weighted == nil {
+		return paths
+	}
+
+	var kept []string
+	for _, path := range paths {
+		if !m.weighted.excluded(path) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// weightedNext returns the playlist position of the track picked to play
+// next, picking one if there is none or it has left the playlist
+func (m *PlayerModel) weightedNext() int {
+	s := m.weighted
+	if index := m.indexOf(s.next); s.next != noTrack && index >= 0 {
+		return index
+	}
+
+	index := s.pick(m.playlist, m.tracks.Path, m.current)
+	if index < 0 {
+		return m.currentIndex
+	}
+	s.next = m.playlist[index]
+	return index
+}
+
+// playsLabel returns the number of the current play, which takes the
+// place of the playlist position with --weights
+func (m *PlayerModel) playsLabel() string {
+	return fmt.Sprintf("Play %d · weighted by folder", max(m.weighted.plays, 1))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    folderWeights
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"jazz=3,podcasts=1,kids=0", folderWeights{"jazz": 3, "podcasts": 1, "kids": 0}, false},
+		{" Jazz = 0.5 , ROCK=2", folderWeights{"jazz": 0.5, "rock": 2}, false},
+		{"jazz", nil, true},
+		{"=3", nil, true},
+		{"jazz=-1", nil, true},
+		{"jazz=lots", nil, true},
+		{"jazz=1e7", nil, true},
+		{"jazz/bebop=2", nil, true},
+		{"jazz=3,", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseWeights(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWeights(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWeights(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFolderOf(t *testing.T) {
+	roots := []string{filepath.FromSlash("/music"), filepath.FromSlash("/media/usb")}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/music/jazz/Miles/01.mp3", "jazz"},
+		{"/music/Kids/01.mp3", "Kids"},
+		{"/media/usb/podcasts/ep1.mp3", "podcasts"},
+		{"/music/loose.mp3", ""},
+		{"/elsewhere/jazz/01.mp3", ""},
+		{"/musicals/jazz/01.mp3", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := folderOf(roots, filepath.FromSlash(tt.path)); got != tt.want {
+				t.Errorf("folderOf(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWeightedFrequencies picks 10,000 tracks from folders of very
+// different sizes and checks each folder's share of the picks is within
+// tolerance of its share of the weights
+func TestWeightedFrequencies(t *testing.T) {
+	sizes := map[string]int{"jazz": 500, "podcasts": 20, "kids": 300, "rare": 1, "": 3}
+	tests := []struct {
+		weights string
+		want    map[string]float64
+	}{
+		{"jazz=3,podcasts=1,kids=0", map[string]float64{"jazz": 3.0 / 6, "podcasts": 1.0 / 6, "rare": 1.0 / 6, "": 1.0 / 6}},
+		{"jazz=1,podcasts=1,kids=1,rare=1", map[string]float64{"jazz": 0.2, "podcasts": 0.2, "kids": 0.2, "rare": 0.2, "": 0.2}},
+		{"JAZZ=0.5,kids=0,rare=0,podcasts=8", map[string]float64{"jazz": 0.5 / 9.5, "podcasts": 8 / 9.5, "": 1 / 9.5}},
+	}
+
+	root := filepath.FromSlash("/music")
+	tracks := newTrackTable()
+	var playlist []trackID
+	for _, folder := range []string{"jazz", "podcasts", "kids", "rare", ""} {
+		for i := range sizes[folder] {
+			playlist = append(playlist, tracks.Add(filepath.Join(root, folder, fmt.Sprintf("%03d.mp3", i))))
+		}
+	}
+
+	const picks = 10000
+	for _, tt := range tests {
+		t.Run(tt.weights, func(t *testing.T) {
+			weights, err := parseWeights(tt.weights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := newWeightedSampler(weights, []string{root})
+			s.rand = rand.New(rand.NewSource(1))
+
+			counts := make(map[string]int)
+			current := noTrack
+			for range picks {
+				index := s.pick(playlist, tracks.Path, current)
+				current = playlist[index]
+				counts[folderOf(s.roots, tracks.Path(current))]++
+			}
+			for folder := range sizes {
+				// Four standard deviations of the share
+				want := tt.want[folder]
+				tolerance := 4*math.Sqrt(want*(1-want)/picks) + 1e-9
+				if got := float64(counts[folder]) / picks; math.Abs(got-want) > tolerance {
+					t.Errorf("folder %q got %.3f of the picks, want %.3f ± %.3f", folder, got, want, tolerance)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedPick(t *testing.T) {
+	root := filepath.FromSlash("/music")
+	tests := []struct {
+		name    string
+		weights folderWeights
+		tracks  []string
+		current int
+		want    []int
+	}{
+		{"avoids the current track", folderWeights{}, []string{"jazz/1.mp3", "jazz/2.mp3"}, 0, []int{1}},
+		{"only track of its folder", folderWeights{"rock": 0}, []string{"jazz/1.mp3", "rock/1.mp3"}, 0, []int{0}},
+		{"all weighted 0", folderWeights{"jazz": 0}, []string{"jazz/1.mp3", "jazz/2.mp3"}, 0, []int{-1}},
+		{"empty", folderWeights{}, nil, -1, []int{-1}},
+		{"any of three", folderWeights{}, []string{"a.mp3", "b.mp3", "c.mp3"}, 1, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := newTrackTable()
+			var playlist []trackID
+			for _, name := range tt.tracks {
+				playlist = append(playlist, table.Add(filepath.Join(root, filepath.FromSlash(name))))
+			}
+			current := noTrack
+			if tt.current >= 0 {
+				current = playlist[tt.current]
+			}
+
+			s := newWeightedSampler(tt.weights, []string{root})
+			s.rand = rand.New(rand.NewSource(1))
+			seen := make(map[int]bool)
+			for range 100 {
+				seen[s.pick(playlist, table.Path, current)] = true
+			}
+			var got []int
+			for index := range seen {
+				got = append(got, index)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestModelWeighted plays with --weights: the track preloaded is the one
+// that plays, the status counts plays, and previous only walks the
+// history
+func TestModelWeighted(t *testing.T) {
+	root := t.TempDir()
+	var tracks []string
+	for _, name := range []string{"jazz/01.mp3", "jazz/02.mp3", "rock/01.mp3", "kids/01.mp3"} {
+		tracks = append(tracks, filepath.Join(root, filepath.FromSlash(name)))
+	}
+	h := newHarness(t)
+	for _, track := range tracks {
+		h.player.setTrack(track, fakeTrack{length: 10 * time.Second})
+	}
+	weights, _ := parseWeights("jazz=3,kids=0")
+	h.m.EnableWeights(weights, []string{root})
+	scanning(h, "")
+	h.send(scanBatchMsg{paths: tracks})
+
+	if got := h.m.tracks.Paths(h.m.playlist); !slices.Equal(got, tracks[:3]) {
+		t.Fatalf("playlist = %v, want the tracks outside kids", got)
+	}
+	for i := range 5 {
+		if !strings.Contains(h.m.trackInfoLine(), fmt.Sprintf("Play %d ", i+1)) {
+			t.Errorf("info line = %q, want play %d", h.m.trackInfoLine(), i+1)
+		}
+		preloaded := h.player.preloaded
+		h.advance(10*time.Second + tickEndSlack)
+		if h.m.currentTrack() != preloaded {
+			t.Fatalf("played %s, want %s preloaded", h.m.currentTrack(), preloaded)
+		}
+	}
+
+	// Back through the history, then nothing earlier than its start
+	h = newHarness(t)
+	h.m.EnableWeights(weights, []string{root})
+	scanning(h, "")
+	h.send(scanBatchMsg{paths: tracks})
+	first := h.m.currentTrack()
+	h.advance(5 * time.Second)
+	h.press("right")
+	h.press("left")
+	if h.m.currentTrack() != first {
+		t.Errorf("previous went to %s, want %s from the history", h.m.currentTrack(), first)
+	}
+	h.press("left")
+	if h.m.currentTrack() != first || h.m.banner != "Nothing earlier to go back to" {
+		t.Errorf("on %s with banner %q, want nothing earlier", h.m.currentTrack(), h.m.banner)
+	}
+}
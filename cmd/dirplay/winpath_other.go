@@ -0,0 +1,27 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// normalizeArg returns arg, whose backslashes are part of file names
+// outside Windows
+func normalizeArg(arg string) string {
+	return arg
+}
+
+// terminalUTF8 reports whether the locale, the first of LC_ALL, LC_CTYPE
+// and LANG that is set, asks for UTF-8. Without any it is assumed, as
+// most terminals default to it.
+func terminalUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+)
+
+// cpUTF8 is the UTF-8 console code page
+const cpUTF8 = 65001
+
+// normalizeArg turns the forward slashes of a command line argument into
+// backslashes, so "//nas/music" is the share \\nas\music and "D:/Music"
+// matches the paths the scan finds
+func normalizeArg(arg string) string {
+	arg = fspath.Short(arg)
+	if rest, ok := strings.CutPrefix(arg, `~\`); ok {
+		arg = "~/" + rest
+	}
+	if home, ok := strings.CutPrefix(arg, "~/"); ok {
+		return "~/" + filepath.FromSlash(home)
+	}
+	return filepath.FromSlash(arg)
+}
+
+// terminalUTF8 reports whether the console shows UTF-8. Windows Terminal
+// always does; conhost only with the UTF-8 code page, chcp 65001.
+func terminalUTF8() bool {
+	if os.Getenv("WT_SESSION") != "" {
+		return true
+	}
+	cp, err := windows.GetConsoleOutputCP()
+	return err != nil || cp == cpUTF8
+}
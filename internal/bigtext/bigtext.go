@@ -0,0 +1,103 @@
+// Package bigtext renders short strings as large block letters for
+// display from across the room.
+package bigtext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Height is the number of lines every rendered string takes
+const Height = 5
+
+// glyphs are drawn with '#' for a filled cell. Every row of a glyph has the
+// same width.
+var glyphs = map[rune][Height]string{
+	'0':  {"###", "# #", "# #", "# #", "###"},
+	'1':  {" # ", "## ", " # ", " # ", "###"},
+	'2':  {"###", "  #", "###", "#  ", "###"},
+	'3':  {"###", "  #", " ##", "  #", "###"},
+	'4':  {"# #", "# #", "###", "  #", "  #"},
+	'5':  {"###", "#  ", "###", "  #", "###"},
+	'6':  {"###", "#  ", "###", "# #", "###"},
+	'7':  {"###", "  #", "  #", " # ", " # "},
+	'8':  {"###", "# #", "###", "# #", "###"},
+	'9':  {"###", "# #", "###", "  #", "###"},
+	'A':  {" # ", "# #", "###", "# #", "# #"},
+	'B':  {"## ", "# #", "## ", "# #", "## "},
+	'C':  {"###", "#  ", "#  ", "#  ", "###"},
+	'D':  {"## ", "# #", "# #", "# #", "## "},
+	'E':  {"###", "#  ", "## ", "#  ", "###"},
+	'F':  {"###", "#  ", "## ", "#  ", "#  "},
+	'G':  {"###", "#  ", "# #", "# #", "###"},
+	'H':  {"# #", "# #", "###", "# #", "# #"},
+	'I':  {"###", " # ", " # ", " # ", "###"},
+	'J':  {"  #", "  #", "  #", "# #", "###"},
+	'K':  {"# #", "# #", "## ", "# #", "# #"},
+	'L':  {"#  ", "#  ", "#  ", "#  ", "###"},
+	'M':  {"#   #", "## ##", "# # #", "#   #", "#   #"},
+	'N':  {"#  #", "## #", "# ##", "#  #", "#  #"},
+	'O':  {"###", "# #", "# #", "# #", "###"},
+	'P':  {"###", "# #", "###", "#  ", "#  "},
+	'Q':  {"### ", "# # ", "# # ", "### ", "   #"},
+	'R':  {"## ", "# #", "## ", "# #", "# #"},
+	'S':  {"###", "#  ", "###", "  #", "###"},
+	'T':  {"###", " # ", " # ", " # ", " # "},
+	'U':  {"# #", "# #", "# #", "# #", "###"},
+	'V':  {"# #", "# #", "# #", "# #", " # "},
+	'W':  {"#   #", "#   #", "# # #", "## ##", "#   #"},
+	'X':  {"# #", "# #", " # ", "# #", "# #"},
+	'Y':  {"# #", "# #", " # ", " # ", " # "},
+	'Z':  {"###", "  #", " # ", "#  ", "###"},
+	':':  {" ", "#", " ", "#", " "},
+	'.':  {" ", " ", " ", " ", "#"},
+	',':  {"  ", "  ", "  ", " #", "# "},
+	'!':  {"#", "#", "#", " ", "#"},
+	'?':  {"###", "  #", " ##", "   ", " # "},
+	'\'': {"#", "#", " ", " ", " "},
+	'-':  {"   ", "   ", "###", "   ", "   "},
+	'&':  {" # ", "# #", " # ", "# #", " ##"},
+	'/':  {"  #", "  #", " # ", "#  ", "#  "},
+	' ':  {"  ", "  ", "  ", "  ", "  "},
+}
+
+// Width returns the number of columns s takes when rendered
+func Width(s string) int {
+	width := 0
+	for i, r := range []rune(s) {
+		if i > 0 {
+			width++
+		}
+		width += len(glyph(r)[0])
+	}
+	return width
+}
+
+// Render returns s drawn in block letters, Height lines joined by "\n".
+// Letters are drawn in upper case and characters without a glyph as "?".
+func Render(s string) string {
+	var rows [Height]strings.Builder
+	for i, r := range []rune(s) {
+		g := glyph(r)
+		for row := range rows {
+			if i > 0 {
+				rows[row].WriteByte(' ')
+			}
+			rows[row].WriteString(strings.ReplaceAll(g[row], "#", "█"))
+		}
+	}
+
+	lines := make([]string, Height)
+	for row := range rows {
+		lines[row] = rows[row].String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// glyph returns the glyph for r, falling back to "?"
+func glyph(r rune) [Height]string {
+	if g, ok := glyphs[unicode.ToUpper(r)]; ok {
+		return g
+	}
+	return glyphs['?']
+}
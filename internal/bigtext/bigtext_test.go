@@ -0,0 +1,71 @@
+package bigtext
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{"digit", "1", []string{" █ ", "██ ", " █ ", " █ ", "███"}},
+		{"time", "1:0", []string{" █    ███", "██  █ █ █", " █    █ █", " █  █ █ █", "███   ███"}},
+		{"lower case", "l", []string{"█  ", "█  ", "█  ", "█  ", "███"}},
+		{"no glyph", "é", []string{"███", "  █", " ██", "   ", " █ "}},
+		{"empty", "", []string{"", "", "", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Split(Render(tt.s), "\n")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render(%q) =\n%s\nwant\n%s", tt.s, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"1", 3},
+		{"12", 7},
+		{"21:45", 17},
+		{"MW", 11},
+		{"a b", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := Width(tt.s); got != tt.want {
+				t.Errorf("Width(%q) = %d, want %d", tt.s, got, tt.want)
+			}
+			// Every line of the rendering is as wide
+			lines := strings.Split(Render(tt.s), "\n")
+			if len(lines) != Height {
+				t.Fatalf("Render(%q) has %d lines, want %d", tt.s, len(lines), Height)
+			}
+			for i, line := range lines {
+				if n := utf8.RuneCountInString(line); n != tt.want {
+					t.Errorf("line %d of Render(%q) is %d wide, want %d", i+1, tt.s, n, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestGlyphs checks every glyph is Height rows of one width
+func TestGlyphs(t *testing.T) {
+	for r, g := range glyphs {
+		for row := range g {
+			if len(g[row]) != len(g[0]) {
+				t.Errorf("row %d of %q is %d wide, want %d", row+1, r, len(g[row]), len(g[0]))
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+// Package fspath holds the path handling that differs between Windows and
+// other systems.
+package fspath
+
+// Long returns path, which only needs an extended-length form on Windows
+func Long(path string) string {
+	return path
+}
+
+// Short returns path, see Long
+func Short(path string) string {
+	return path
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package fspath
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLongShort checks paths pass through unchanged, however long
+func TestLongShort(t *testing.T) {
+	for _, path := range []string{"/music/a.mp3", "music/a.mp3", "/" + strings.Repeat("d/", 300) + "a.mp3", `\\?\C:\a.mp3`} {
+		if got := Long(path); got != path {
+			t.Errorf("Long(%s) = %s", path, got)
+		}
+		if got := Short(path); got != path {
+			t.Errorf("Short(%s) = %s", path, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+// Package fspath holds the path handling that differs between Windows and
+// other systems.
+package fspath
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Windows refuses paths of maxPath characters or more unless they are
+// given in extended-length form, which for directories starts to matter
+// at maxPath-12
+const maxPath = 260
+
+// Prefixes of extended-length paths, for drive letters and UNC shares
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+)
+
+// Long returns the path to open a file by, in extended-length form when it
+// is too long for the Windows API otherwise. Paths shown and stored keep
+// their usual form, see Short.
+func Long(path string) string {
+	if strings.HasPrefix(path, extendedPrefix) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxPath-12 {
+		return path
+	}
+	if share, ok := strings.CutPrefix(abs, `\\`); ok {
+		return extendedUNCPrefix + share
+	}
+	return extendedPrefix + abs
+}
+
+// Short undoes Long, e.g. for a path the user typed in extended-length
+// form
+func Short(path string) string {
+	if share, ok := strings.CutPrefix(path, extendedUNCPrefix); ok {
+		return `\\` + share
+	}
+	return strings.TrimPrefix(path, extendedPrefix)
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+package fspath
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// longAs returns prefix followed by directories and a file name making
+// it n characters long
+func longAs(prefix string, n int) string {
+	path := prefix
+	for len(path)+50+len("a.mp3") <= n {
+		path += strings.Repeat("d", 49) + `\`
+	}
+	return path + strings.Repeat("a", n-len(path)-len(".mp3")) + ".mp3"
+}
+
+func TestLong(t *testing.T) {
+	limit := maxPath - 12
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"short", `C:\Music\a.mp3`, `C:\Music\a.mp3`},
+		{"just short enough", longAs(`C:\Music\`, limit-1), longAs(`C:\Music\`, limit-1)},
+		{"long", longAs(`C:\Music\`, limit), extendedPrefix + longAs(`C:\Music\`, limit)},
+		{"very long", longAs(`C:\Music\`, 1000), extendedPrefix + longAs(`C:\Music\`, 1000)},
+		{"long with slashes", strings.ReplaceAll(longAs(`C:\Music\`, 300), `\`, "/"), extendedPrefix + longAs(`C:\Music\`, 300)},
+		{"short UNC", `\\server\share\a.mp3`, `\\server\share\a.mp3`},
+		{"long UNC", longAs(`\\server\share\`, 300), extendedUNCPrefix + strings.TrimPrefix(longAs(`\\server\share\`, 300), `\\`)},
+		{"already extended", extendedPrefix + `C:\Music\a.mp3`, extendedPrefix + `C:\Music\a.mp3`},
+		{"already extended UNC", extendedUNCPrefix + `server\share\a.mp3`, extendedUNCPrefix + `server\share\a.mp3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Long(tt.path); got != tt.want {
+				t.Errorf("Long(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLongRelative makes a long relative path absolute before extending
+// it, as extended-length paths are never relative
+func TestLongRelative(t *testing.T) {
+	path := longAs(`Music\`, 300)
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Long(path); got != extendedPrefix+abs {
+		t.Errorf("Long(%s) = %s, want %s", path, got, extendedPrefix+abs)
+	}
+}
+
+func TestShort(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"plain", `C:\Music\a.mp3`, `C:\Music\a.mp3`},
+		{"plain UNC", `\\server\share\a.mp3`, `\\server\share\a.mp3`},
+		{"extended", extendedPrefix + `C:\Music\a.mp3`, `C:\Music\a.mp3`},
+		{"extended UNC", extendedUNCPrefix + `server\share\a.mp3`, `\\server\share\a.mp3`},
+		{"long", extendedPrefix + longAs(`C:\Music\`, 300), longAs(`C:\Music\`, 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Short(tt.path); got != tt.want {
+				t.Errorf("Short(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Short undoes Long
+	for _, path := range []string{`C:\Music\a.mp3`, longAs(`C:\Music\`, 300), `\\server\share\a.mp3`, longAs(`\\server\share\`, 300)} {
+		if got := Short(Long(path)); got != path {
+			t.Errorf("Short(Long(%s)) = %s", path, got)
+		}
+	}
+}
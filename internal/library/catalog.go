@@ -0,0 +1,254 @@
+package library
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Names that tracks without an artist or album tag are filed under. They
+// sort after every named artist and album.
+const (
+	UnknownArtist = "Unknown artist"
+	UnknownAlbum  = "Unknown album"
+)
+
+// CatalogTrack is a track as the catalog files it. Artist is the album
+// artist where the tags have one, so compilations stay together.
+type CatalogTrack struct {
+	Path       string
+	Artist     string
+	SortArtist string
+	Album      string
+	Title      string
+	Disc       int
+	Track      int
+}
+
+// Catalog groups tracks by artist and album, for browsing a library the
+// way a jukebox does. Artists, their albums and the albums' tracks are
+// kept sorted as tracks are added, so it can be filled a batch at a time
+// while tags are read.
+type Catalog struct {
+	artists map[string]*CatalogArtist
+	keys    []string
+
+	// Where each track is filed, so adding it again moves it
+	filed map[string]catalogPlace
+}
+
+// CatalogArtist is an artist of the catalog and their albums
+type CatalogArtist struct {
+	Name   string
+	Key    string
+	Tracks int
+
+	sortKey string
+	albums  map[string]*CatalogAlbum
+	keys    []string
+}
+
+// CatalogAlbum is an album of an artist, its tracks in album order
+type CatalogAlbum struct {
+	Name   string
+	Key    string
+	Tracks []CatalogTrack
+
+	sortKey string
+}
+
+// catalogPlace is the artist and album a track is filed under
+type catalogPlace struct {
+	artist string
+	album  string
+}
+
+// NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{
+		artists: make(map[string]*CatalogArtist),
+		filed:   make(map[string]catalogPlace),
+	}
+}
+
+// catalogKey returns the key names are told apart by, which ignores case
+// and surrounding space
+func catalogKey(name string) string {
+	return folder.String(strings.TrimSpace(name))
+}
+
+// catalogSortKey returns the key a name sorts by, unknown ones last
+func catalogSortKey(name, sortTag, unknown string) string {
+	if name == unknown {
+		return "\U0010FFFF"
+	}
+	return SortKey(name, sortTag)
+}
+
+// Add files a track under its artist and album, moving it if it was filed
+// before under other tags
+func (c *Catalog) Add(track CatalogTrack) {
+	c.Remove(track.Path)
+
+	name := strings.TrimSpace(track.Artist)
+	if name == "" {
+		name = UnknownArtist
+	}
+	artist, ok := c.artists[catalogKey(name)]
+	if !ok {
+		artist = &CatalogArtist{
+			Name:    name,
+			Key:     catalogKey(name),
+			sortKey: catalogSortKey(name, track.SortArtist, UnknownArtist),
+			albums:  make(map[string]*CatalogAlbum),
+		}
+		c.artists[artist.Key] = artist
+		c.keys = insertSorted(c.keys, artist.Key, func(a, b string) int {
+			return compareSorted(c.artists[a].sortKey, a, c.artists[b].sortKey, b)
+		})
+	}
+
+	title := strings.TrimSpace(track.Album)
+	if title == "" {
+		title = UnknownAlbum
+	}
+	album, ok := artist.albums[catalogKey(title)]
+	if !ok {
+		album = &CatalogAlbum{
+			Name:    title,
+			Key:     catalogKey(title),
+			sortKey: catalogSortKey(title, "", UnknownAlbum),
+		}
+		artist.albums[album.Key] = album
+		artist.keys = insertSorted(artist.keys, album.Key, func(a, b string) int {
+			return compareSorted(artist.albums[a].sortKey, a, artist.albums[b].sortKey, b)
+		})
+	}
+
+	i, _ := slices.BinarySearchFunc(album.Tracks, track, compareTracks)
+	album.Tracks = slices.Insert(album.Tracks, i, track)
+	artist.Tracks++
+	c.filed[track.Path] = catalogPlace{artist: artist.Key, album: album.Key}
+}
+
+// Remove takes a track out of the catalog, and its album and artist with
+// it when they have no other tracks
+func (c *Catalog) Remove(path string) {
+	place, ok := c.filed[path]
+	if !ok {
+		return
+	}
+	delete(c.filed, path)
+
+	artist := c.artists[place.artist]
+	album := artist.albums[place.album]
+	album.Tracks = slices.DeleteFunc(album.Tracks, func(t CatalogTrack) bool { return t.Path == path })
+	artist.Tracks--
+
+	if len(album.Tracks) == 0 {
+		delete(artist.albums, album.Key)
+		artist.keys = slices.DeleteFunc(artist.keys, func(key string) bool { return key == album.Key })
+	}
+	if artist.Tracks == 0 {
+		delete(c.artists, artist.Key)
+		c.keys = slices.DeleteFunc(c.keys, func(key string) bool { return key == artist.Key })
+	}
+}
+
+// Find returns the keys of the artist and album a track is filed under,
+// and its position in the album
+func (c *Catalog) Find(path string) (artist, album string, index int, ok bool) {
+	place, ok := c.filed[path]
+	if !ok {
+		return "", "", 0, false
+	}
+	tracks := c.artists[place.artist].albums[place.album].Tracks
+	index = slices.IndexFunc(tracks, func(t CatalogTrack) bool { return t.Path == path })
+	return place.artist, place.album, index, true
+}
+
+// Len returns how many tracks are filed
+func (c *Catalog) Len() int {
+	return len(c.filed)
+}
+
+// Artists returns the artists in alphabetical order, by their sort tag or
+// their name without a leading article
+func (c *Catalog) Artists() []*CatalogArtist {
+	artists := make([]*CatalogArtist, len(c.keys))
+	for i, key := range c.keys {
+		artists[i] = c.artists[key]
+	}
+	return artists
+}
+
+// Artist returns the artist with key, or nil
+func (c *Catalog) Artist(key string) *CatalogArtist {
+	return c.artists[key]
+}
+
+// Albums returns the artist's albums in alphabetical order
+func (a *CatalogArtist) Albums() []*CatalogAlbum {
+	albums := make([]*CatalogAlbum, len(a.keys))
+	for i, key := range a.keys {
+		albums[i] = a.albums[key]
+	}
+	return albums
+}
+
+// Album returns the artist's album with key, or nil
+func (a *CatalogArtist) Album(key string) *CatalogAlbum {
+	return a.albums[key]
+}
+
+// AllTracks returns the artist's tracks album by album
+func (a *CatalogArtist) AllTracks() []CatalogTrack {
+	tracks := make([]CatalogTrack, 0, a.Tracks)
+	for _, key := range a.keys {
+		tracks = append(tracks, a.albums[key].Tracks...)
+	}
+	return tracks
+}
+
+// HasNamePrefix reports whether name starts with prefix, ignoring case and
+// a leading article, so "rad" finds Radiohead and "beat" The Beatles
+func HasNamePrefix(name, prefix string) bool {
+	prefix = folder.String(prefix)
+	return strings.HasPrefix(folder.String(name), prefix) || strings.HasPrefix(SortKey(name, ""), prefix)
+}
+
+// insertSorted inserts key into keys, kept in the order of compare
+func insertSorted(keys []string, key string, compare func(a, b string) int) []string {
+	i, _ := slices.BinarySearchFunc(keys, key, compare)
+	return slices.Insert(keys, i, key)
+}
+
+// compareSorted orders by sort key, then by key so equal sort keys have a
+// fixed order
+func compareSorted(sortA, keyA, sortB, keyB string) int {
+	if c := cmp.Compare(sortA, sortB); c != 0 {
+		return c
+	}
+	return cmp.Compare(keyA, keyB)
+}
+
+// compareTracks orders the tracks of an album: numbered tracks by disc,
+// then track number, before unnumbered ones, which follow by path
+func compareTracks(a, b CatalogTrack) int {
+	if (a.Track == 0) != (b.Track == 0) {
+		if b.Track == 0 {
+			return -1
+		}
+		return 1
+	}
+	if c := cmp.Compare(max(a.Disc, 1), max(b.Disc, 1)); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Track, b.Track); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(strings.ToLower(a.Path), strings.ToLower(b.Path)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Path, b.Path)
+}
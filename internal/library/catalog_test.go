@@ -0,0 +1,249 @@
+package library
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// catalogLines lists a catalog as "Artist (tracks) / Album: track, ...",
+// one line per album, the tracks by base name
+func catalogLines(c *Catalog) []string {
+	var lines []string
+	for _, artist := range c.Artists() {
+		for _, album := range artist.Albums() {
+			var names []string
+			for _, t := range album.Tracks {
+				names = append(names, path.Base(t.Path))
+			}
+			lines = append(lines, fmt.Sprintf("%s (%d) / %s: %s", artist.Name, artist.Tracks, album.Name, strings.Join(names, ", ")))
+		}
+	}
+	return lines
+}
+
+func TestCatalogAdd(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []CatalogTrack
+		want   []string
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+		},
+		{
+			"names merged ignoring case and space",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "Radiohead", Album: "OK Computer", Track: 1},
+				{Path: "/m/2.mp3", Artist: "radiohead ", Album: "ok computer", Track: 2},
+			},
+			[]string{"Radiohead (2) / OK Computer: 1.mp3, 2.mp3"},
+		},
+		{
+			"articles and sort tags",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "The Beatles", Album: "Abbey Road"},
+				{Path: "/m/2.mp3", Artist: "Air", Album: "Moon Safari"},
+				{Path: "/m/3.mp3", Artist: "Zappa", SortArtist: "Aaa", Album: "Hot Rats"},
+				{Path: "/m/4.mp3", Artist: "Cure", Album: "Disintegration"},
+			},
+			[]string{
+				"Zappa (1) / Hot Rats: 3.mp3", "Air (1) / Moon Safari: 2.mp3",
+				"The Beatles (1) / Abbey Road: 1.mp3", "Cure (1) / Disintegration: 4.mp3",
+			},
+		},
+		{
+			"unknown last",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3"},
+				{Path: "/m/2.mp3", Artist: "Zz Top"},
+				{Path: "/m/3.mp3", Artist: "Zz Top", Album: "Eliminator"},
+			},
+			[]string{
+				"Zz Top (2) / Eliminator: 3.mp3", "Zz Top (2) / Unknown album: 2.mp3",
+				"Unknown artist (1) / Unknown album: 1.mp3",
+			},
+		},
+		{
+			"albums in order",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "Bowie", Album: "Low"},
+				{Path: "/m/2.mp3", Artist: "Bowie", Album: "Heroes"},
+				{Path: "/m/3.mp3", Artist: "Bowie", Album: "The Next Day"},
+			},
+			[]string{"Bowie (3) / Heroes: 2.mp3", "Bowie (3) / Low: 1.mp3", "Bowie (3) / The Next Day: 3.mp3"},
+		},
+		{
+			"disc and track order",
+			[]CatalogTrack{
+				{Path: "/m/e.mp3", Artist: "A", Album: "B"},
+				{Path: "/m/d.mp3", Artist: "A", Album: "B", Disc: 2, Track: 1},
+				{Path: "/m/c.mp3", Artist: "A", Album: "B", Disc: 1, Track: 10},
+				{Path: "/m/b.mp3", Artist: "A", Album: "B", Track: 2},
+				{Path: "/m/a.mp3", Artist: "A", Album: "B"},
+			},
+			[]string{"A (5) / B: b.mp3, c.mp3, d.mp3, a.mp3, e.mp3"},
+		},
+		{
+			"retagged track moves",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "A", Album: "B", Track: 1},
+				{Path: "/m/2.mp3", Artist: "A", Album: "B", Track: 2},
+				{Path: "/m/1.mp3", Artist: "C", Album: "D", Track: 1},
+			},
+			[]string{"A (1) / B: 2.mp3", "C (1) / D: 1.mp3"},
+		},
+		{
+			"retagged last track takes its album and artist",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "A", Album: "B"},
+				{Path: "/m/1.mp3", Artist: "A", Album: "C"},
+				{Path: "/m/1.mp3", Artist: "D", Album: "C"},
+			},
+			[]string{"D (1) / C: 1.mp3"},
+		},
+		{
+			"renumbered track moves within its album",
+			[]CatalogTrack{
+				{Path: "/m/1.mp3", Artist: "A", Album: "B", Track: 1},
+				{Path: "/m/2.mp3", Artist: "A", Album: "B", Track: 2},
+				{Path: "/m/1.mp3", Artist: "A", Album: "B", Track: 3},
+			},
+			[]string{"A (2) / B: 2.mp3, 1.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCatalog()
+			for _, track := range tt.tracks {
+				c.Add(track)
+			}
+			if got := catalogLines(c); !slices.Equal(got, tt.want) {
+				t.Errorf("catalog =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCatalogRemove(t *testing.T) {
+	tracks := []CatalogTrack{
+		{Path: "/m/1.mp3", Artist: "A", Album: "B", Track: 1},
+		{Path: "/m/2.mp3", Artist: "A", Album: "B", Track: 2},
+		{Path: "/m/3.mp3", Artist: "A", Album: "C"},
+		{Path: "/m/4.mp3", Artist: "D", Album: "E"},
+	}
+	tests := []struct {
+		name   string
+		remove []string
+		want   []string
+	}{
+		{"one of an album", []string{"/m/1.mp3"}, []string{"A (2) / B: 2.mp3", "A (2) / C: 3.mp3", "D (1) / E: 4.mp3"}},
+		{"an album", []string{"/m/3.mp3"}, []string{"A (2) / B: 1.mp3, 2.mp3", "D (1) / E: 4.mp3"}},
+		{"an artist", []string{"/m/4.mp3"}, []string{"A (3) / B: 1.mp3, 2.mp3", "A (3) / C: 3.mp3"}},
+		{"unknown track", []string{"/m/5.mp3"}, []string{"A (3) / B: 1.mp3, 2.mp3", "A (3) / C: 3.mp3", "D (1) / E: 4.mp3"}},
+		{"twice", []string{"/m/4.mp3", "/m/4.mp3"}, []string{"A (3) / B: 1.mp3, 2.mp3", "A (3) / C: 3.mp3"}},
+		{"everything", []string{"/m/1.mp3", "/m/2.mp3", "/m/3.mp3", "/m/4.mp3"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCatalog()
+			for _, track := range tracks {
+				c.Add(track)
+			}
+			for _, path := range tt.remove {
+				c.Remove(path)
+			}
+			if got := catalogLines(c); !slices.Equal(got, tt.want) {
+				t.Errorf("catalog =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			filed := 0
+			for _, artist := range c.Artists() {
+				filed += artist.Tracks
+			}
+			if c.Len() != filed {
+				t.Errorf("Len = %d, want the %d tracks filed", c.Len(), filed)
+			}
+		})
+	}
+}
+
+func TestCatalogFind(t *testing.T) {
+	c := NewCatalog()
+	for _, track := range []CatalogTrack{
+		{Path: "/m/2.mp3", Artist: "The Beatles", Album: "Abbey Road", Track: 2},
+		{Path: "/m/1.mp3", Artist: "the beatles", Album: "abbey road", Track: 1},
+		{Path: "/m/3.mp3"},
+	} {
+		c.Add(track)
+	}
+	tests := []struct {
+		path          string
+		artist, album string
+		index         int
+		ok            bool
+	}{
+		{"/m/1.mp3", "the beatles", "abbey road", 0, true},
+		{"/m/2.mp3", "the beatles", "abbey road", 1, true},
+		{"/m/3.mp3", "unknown artist", "unknown album", 0, true},
+		{"/m/4.mp3", "", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			artist, album, index, ok := c.Find(tt.path)
+			if artist != tt.artist || album != tt.album || index != tt.index || ok != tt.ok {
+				t.Errorf("Find = %q, %q, %d, %v, want %q, %q, %d, %v", artist, album, index, ok, tt.artist, tt.album, tt.index, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got := c.Artist(artist).Album(album).Tracks[index].Path; got != tt.path {
+				t.Errorf("found %s, want %s", got, tt.path)
+			}
+		})
+	}
+}
+
+func TestAllTracks(t *testing.T) {
+	c := NewCatalog()
+	for _, track := range []CatalogTrack{
+		{Path: "/m/low2.mp3", Artist: "Bowie", Album: "Low", Track: 2},
+		{Path: "/m/heroes1.mp3", Artist: "Bowie", Album: "Heroes", Track: 1},
+		{Path: "/m/low1.mp3", Artist: "Bowie", Album: "Low", Track: 1},
+		{Path: "/m/other.mp3", Artist: "Eno", Album: "Another Green World"},
+	} {
+		c.Add(track)
+	}
+	var got []string
+	for _, track := range c.Artist("bowie").AllTracks() {
+		got = append(got, track.Path)
+	}
+	if want := []string{"/m/heroes1.mp3", "/m/low1.mp3", "/m/low2.mp3"}; !slices.Equal(got, want) {
+		t.Errorf("AllTracks = %v, want %v", got, want)
+	}
+}
+
+func TestHasNamePrefix(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		want         bool
+	}{
+		{"Radiohead", "rad", true},
+		{"Radiohead", "RAD", true},
+		{"Radiohead", "head", false},
+		{"The Beatles", "beat", true},
+		{"The Beatles", "the b", true},
+		{"A Tribe Called Quest", "tribe", true},
+		{"Straße", "strass", true},
+		{"2Pac", "2", true},
+		{"Radiohead", "", true},
+	}
+	for _, tt := range tests {
+		if got := HasNamePrefix(tt.name, tt.prefix); got != tt.want {
+			t.Errorf("HasNamePrefix(%q, %q) = %v, want %v", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package library
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/pkg/tags"
+)
+
+// Chapter is a point within a file where a part of it starts, see
+// tags.Chapter
+type Chapter = tags.Chapter
+
+// cueFrames is the number of CD frames to the second in cue sheet times
+const cueFrames = 75
+
+// cueIndex matches the time of a cue sheet INDEX line, mm:ss:ff
+var cueIndex = regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})$`)
+
+// vorbisChapter matches the CHAPTERnnn comments of the Vorbis chapter
+// extension, whose names are CHAPTERnnnNAME
+var vorbisChapter = regexp.MustCompile(`^chapter(\d+)$`)
+
+// ReadChapters picks chapters out of the raw tags of a file: ID3v2 CHAP
+// frames, a cue sheet embedded as a CUESHEET Vorbis comment, or CHAPTERnnn
+// Vorbis comments. It returns nil when the file has fewer than two, which
+// mark nothing within it.
+func ReadChapters(tags tag.Metadata) []Chapter {
+	raw := tags.Raw()
+	if sheet, ok := raw["cuesheet"].(string); ok {
+		if chapters, err := ParseCue(strings.NewReader(sheet), ""); err == nil && chapters != nil {
+			return chapters
+		}
+	}
+
+	var chapters []Chapter
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []byte:
+			if key != "CHAP" && !strings.HasPrefix(key, "CHAP_") {
+				continue
+			}
+			if chapter, err := parseCHAP(v, tags.Format()); err == nil {
+				chapters = append(chapters, chapter)
+			}
+		case string:
+			match := vorbisChapter.FindStringSubmatch(key)
+			if match == nil {
+				continue
+			}
+			start, err := parseChapterTime(v)
+			if err != nil {
+				continue
+			}
+			title, _ := raw[key+"name"].(string)
+			chapters = append(chapters, Chapter{Start: start, Title: strings.TrimSpace(title)})
+		}
+	}
+	return tidyChapters(chapters)
+}
+
+// tidyChapters sorts chapters by start and drops those starting at the
+// same time as the one before, returning nil for fewer than two
+func tidyChapters(chapters []Chapter) []Chapter {
+	sort.SliceStable(chapters, func(a, b int) bool {
+		return chapters[a].Start < chapters[b].Start
+	})
+
+	var tidy []Chapter
+	for _, chapter := range chapters {
+		if len(tidy) > 0 && tidy[len(tidy)-1].Start == chapter.Start {
+			continue
+		}
+		tidy = append(tidy, chapter)
+	}
+	if len(tidy) < 2 {
+		return nil
+	}
+	return tidy
+}
+
+// parseCHAP reads an ID3v2 CHAP frame: an element ID, the start and end
+// in milliseconds, byte offsets, then frames of its own such as TIT2 for
+// its title
+func parseCHAP(b []byte, format tag.Format) (Chapter, error) {
+	id, rest, ok := bytes.Cut(b, []byte{0})
+	if !ok || len(rest) < 16 {
+		return Chapter{}, errors.New("short CHAP frame")
+	}
+	chapter := Chapter{Start: time.Duration(binary.BigEndian.Uint32(rest)) * time.Millisecond}
+
+	frames := rest[16:]
+	for len(frames) >= 10 {
+		name := string(frames[:4])
+		size := int(binary.BigEndian.Uint32(frames[4:8]))
+		if format == tag.ID3v2_4 {
+			size = syncsafe(frames[4:8])
+		}
+		if size < 0 || 10+size > len(frames) {
+			break
+		}
+		if name == "TIT2" {
+			chapter.Title = decodeID3Text(frames[10 : 10+size])
+		}
+		frames = frames[10+size:]
+	}
+	if chapter.Title == "" {
+		chapter.Title = string(id)
+	}
+	return chapter, nil
+}
+
+// syncsafe decodes a 4 byte ID3v2.4 size, 7 bits to the byte
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// decodeID3Text decodes the body of an ID3v2 text frame: an encoding byte
+// followed by ISO-8859-1, UTF-16 with a byte order mark, UTF-16BE or UTF-8
+func decodeID3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	encoding, text := b[0], b[1:]
+
+	var s string
+	switch encoding {
+	case 0:
+		runes := make([]rune, len(text))
+		for i, c := range text {
+			runes[i] = rune(c)
+		}
+		s = string(runes)
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		if encoding == 1 && len(text) >= 2 {
+			if text[0] == 0xff && text[1] == 0xfe {
+				order = binary.LittleEndian
+			}
+			if text[0] == 0xff && text[1] == 0xfe || text[0] == 0xfe && text[1] == 0xff {
+				text = text[2:]
+			}
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = order.Uint16(text[2*i:])
+		}
+		s = string(utf16.Decode(units))
+	default:
+		s = string(text)
+	}
+	// Several strings are separated by NULs, only the first is the title
+	s, _, _ = strings.Cut(s, "\x00")
+	return strings.TrimSpace(s)
+}
+
+// parseChapterTime parses the hh:mm:ss.sss start of a Vorbis chapter
+func parseChapterTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid chapter time %q", s)
+	}
+	var seconds float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid chapter time %q", s)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ParseCue reads the tracks of a cue sheet as chapters, starting at their
+// INDEX 01. When file is given and the sheet names more than one FILE,
+// only the tracks of the FILE with the same name as file, extension aside,
+// are read, since the others belong to other files.
+func ParseCue(r io.Reader, file string) ([]Chapter, error) {
+	var sheets [][]Chapter
+	var names []string
+	var current *Chapter
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		command, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+
+		switch strings.ToUpper(command) {
+		case "FILE":
+			// The name is followed by the type of the file, e.g. WAVE
+			if i := strings.LastIndex(args, " "); i > 0 {
+				args = strings.TrimSpace(args[:i])
+			}
+			sheets = append(sheets, nil)
+			names = append(names, cueString(args))
+			current = nil
+		case "TRACK":
+			if len(sheets) == 0 {
+				sheets, names = append(sheets, nil), append(names, "")
+			}
+			number, _, _ := strings.Cut(args, " ")
+			last := len(sheets) - 1
+			sheets[last] = append(sheets[last], Chapter{Start: -1, Title: "Track " + number})
+			current = &sheets[last][len(sheets[last])-1]
+		case "TITLE":
+			if current != nil {
+				current.Title = cueString(args)
+			}
+		case "INDEX":
+			number, at, _ := strings.Cut(args, " ")
+			if current == nil || strings.TrimLeft(number, "0") != "1" {
+				continue
+			}
+			match := cueIndex.FindStringSubmatch(strings.TrimSpace(at))
+			if match == nil {
+				return nil, fmt.Errorf("invalid cue sheet index %q", at)
+			}
+			minutes, _ := strconv.Atoi(match[1])
+			seconds, _ := strconv.Atoi(match[2])
+			frames, _ := strconv.Atoi(match[3])
+			current.Start = time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second +
+				time.Duration(frames)*time.Second/cueFrames
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var chapters []Chapter
+	for i, sheet := range sheets {
+		if file != "" && len(sheets) > 1 && !sameStem(names[i], file) {
+			continue
+		}
+		for _, chapter := range sheet {
+			if chapter.Start >= 0 {
+				chapters = append(chapters, chapter)
+			}
+		}
+	}
+	return tidyChapters(chapters), nil
+}
+
+// cueString unquotes a cue sheet string, which may be bare
+func cueString(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// sameStem reports whether two file names match once the directory and
+// extension are dropped, so a sheet written for the .wav of a rip still
+// fits the .flac
+func sameStem(a, b string) bool {
+	stem := func(name string) string {
+		name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return strings.EqualFold(stem(a), stem(b))
+}
+
+// FLAC metadata block types read for the binary cue sheet, and the track
+// number of the lead-out track that ends a CD's cue sheet
+const (
+	flacStreamInfo = 0
+	flacCueSheet   = 5
+	flacLeadOut    = 170
+)
+
+// ReadFLACCueSheet reads the tracks of the CUESHEET metadata block of a
+// FLAC file as chapters. The block holds no titles, the tracks are named
+// by number. It returns nil when there is no such block.
+func ReadFLACCueSheet(r io.ReadSeeker) ([]Chapter, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	var sampleRate int
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch header[0] & 0x7f {
+		case flacStreamInfo:
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return nil, err
+			}
+			if len(block) >= 13 {
+				sampleRate = int(block[10])<<12 | int(block[11])<<4 | int(block[12])>>4
+			}
+		case flacCueSheet:
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return nil, err
+			}
+			return parseFLACCueSheet(block, sampleRate)
+		default:
+			if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+		if last {
+			return nil, nil
+		}
+	}
+}
+
+// parseFLACCueSheet reads the tracks out of a CUESHEET block, whose
+// offsets count samples at sampleRate
+func parseFLACCueSheet(block []byte, sampleRate int) ([]Chapter, error) {
+	// Catalog number, lead-in samples, CD flag and reserved bits
+	const header = 128 + 8 + 1 + 258
+	if sampleRate <= 0 || len(block) < header+1 {
+		return nil, errors.New("invalid FLAC cue sheet")
+	}
+	count := int(block[header])
+	b := block[header+1:]
+
+	var chapters []Chapter
+	for range count {
+		// Offset, number, ISRC, flags, reserved and index point count
+		if len(b) < 36 {
+			return nil, errors.New("invalid FLAC cue sheet")
+		}
+		offset := binary.BigEndian.Uint64(b)
+		number := int(b[8])
+		points := int(b[35])
+		b = b[36:]
+		if len(b) < 12*points {
+			return nil, errors.New("invalid FLAC cue sheet")
+		}
+
+		start := int64(-1)
+		for i := range points {
+			point := b[12*i:]
+			if point[8] == 1 {
+				start = int64(offset + binary.BigEndian.Uint64(point))
+			}
+		}
+		b = b[12*points:]
+
+		if number == flacLeadOut || number == 255 || start < 0 {
+			continue
+		}
+		chapters = append(chapters, Chapter{
+			Start: time.Duration(float64(start) / float64(sampleRate) * float64(time.Second)),
+			Title: fmt.Sprintf("Track %02d", number),
+		})
+	}
+	return tidyChapters(chapters), nil
+}
@@ -0,0 +1,15 @@
+package library
+
+import "strconv"
+
+// FormatCount formats n with thousands separated by commas, as in 8,931
+func FormatCount(n int) string {
+	if n < 0 {
+		return "-" + FormatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
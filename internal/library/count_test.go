@@ -0,0 +1,22 @@
+package library
+
+import "testing"
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{8931, "8,931"},
+		{1234567, "1,234,567"},
+		{-12345, "-12,345"},
+	}
+	for _, tt := range tests {
+		if got := FormatCount(tt.n); got != tt.want {
+			t.Errorf("FormatCount(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package library
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Listen is one line of a listen log: a track that started playing and
+// how much of it was heard before playback moved on
+type Listen struct {
+	Path       string    `json:"path"`
+	Artist     string    `json:"artist,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Album      string    `json:"album,omitempty"`
+	LengthMS   int64     `json:"length_ms"`
+	StartedAt  time.Time `json:"started_at"`
+	ListenedMS int64     `json:"listened_ms"`
+	Skipped    bool      `json:"skipped"`
+}
+
+// NewListen describes a listen of listened out of length. Less than half
+// of the track heard counts as skipped, anything more as completed.
+func NewListen(path string, length, listened time.Duration, startedAt time.Time) Listen {
+	return Listen{
+		Path:       path,
+		LengthMS:   length.Milliseconds(),
+		StartedAt:  startedAt,
+		ListenedMS: listened.Milliseconds(),
+		Skipped:    listened*2 < length,
+	}
+}
+
+// AppendListens adds listens to the log at path, one JSON line each. The
+// file is opened for appending and each line goes out in a single write,
+// so a crash can't leave half a line behind another one.
+func AppendListens(path string, listens []Listen) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, listen := range listens {
+		line, err := json.Marshal(listen)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// ReadListens returns the listens of a log written by AppendListens,
+// skipping blank lines
+func ReadListens(r io.Reader) ([]Listen, error) {
+	var listens []Listen
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var listen Listen
+		if err := json.Unmarshal(scanner.Bytes(), &listen); err != nil {
+			return listens, fmt.Errorf("line %d: %w", n, err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, scanner.Err()
+}
@@ -0,0 +1,125 @@
+package library
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewListen(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   time.Duration
+		listened time.Duration
+		skipped  bool
+	}{
+		{"exactly half", 3 * time.Minute, 90 * time.Second, false},
+		{"just under half", 3 * time.Minute, 90*time.Second - time.Millisecond, true},
+		{"just over half", 3 * time.Minute, 90*time.Second + time.Millisecond, false},
+		{"odd length half", time.Second + time.Nanosecond, 500 * time.Millisecond, true},
+		{"all of it", 3 * time.Minute, 3 * time.Minute, false},
+		{"none of it", 3 * time.Minute, 0, true},
+		{"unknown length", 0, time.Minute, false},
+	}
+	at := time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listen := NewListen("/music/a.mp3", tt.length, tt.listened, at)
+			if listen.Skipped != tt.skipped {
+				t.Errorf("Skipped = %v for %v of %v, want %v", listen.Skipped, tt.listened, tt.length, tt.skipped)
+			}
+			if listen.LengthMS != tt.length.Milliseconds() || listen.ListenedMS != tt.listened.Milliseconds() {
+				t.Errorf("length %d ms, listened %d ms, want %d and %d", listen.LengthMS, listen.ListenedMS, tt.length.Milliseconds(), tt.listened.Milliseconds())
+			}
+		})
+	}
+}
+
+// TestListenSchema checks the fields of each line and their JSON types,
+// which scripts reading the log rely on
+func TestListenSchema(t *testing.T) {
+	at := time.Date(2026, 10, 15, 21, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tagged := NewListen("/music/a.mp3", 3*time.Minute, 2*time.Minute, at)
+	tagged.Artist, tagged.Title, tagged.Album = "Band", "Song", "Record"
+	bare := NewListen("/music/b.mp3", 4*time.Minute, 10*time.Second, at)
+
+	path := filepath.Join(t.TempDir(), "listens.jsonl")
+	if err := AppendListens(path, []Listen{tagged}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendListens(path, []Listen{bare}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []map[string]any{
+		{
+			"path": "/music/a.mp3", "artist": "Band", "title": "Song", "album": "Record",
+			"length_ms": 180000.0, "started_at": "2026-10-15T21:00:00+02:00", "listened_ms": 120000.0, "skipped": false,
+		},
+		// Missing tags are left out
+		{
+			"path":      "/music/b.mp3",
+			"length_ms": 240000.0, "started_at": "2026-10-15T21:00:00+02:00", "listened_ms": 10000.0, "skipped": true,
+		},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("log holds %d lines, want %d:\n%s", len(lines), len(want), data)
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d isn't a JSON object: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("line %d = %v, want %v", i+1, got, want[i])
+		}
+	}
+
+	listens, err := ReadListens(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listens) != 2 || !listens[0].StartedAt.Equal(at) || listens[0].Title != "Song" || !listens[1].Skipped {
+		t.Errorf("ReadListens = %+v, want the listens appended", listens)
+	}
+}
+
+func TestReadListens(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		paths   []string
+		wantErr string
+	}{
+		{"empty", "", nil, ""},
+		{"blank lines", "\n{\"path\":\"a\"}\n\n{\"path\":\"b\"}\n", []string{"a", "b"}, ""},
+		{"no final newline", "{\"path\":\"a\"}", []string{"a"}, ""},
+		{"torn line", "{\"path\":\"a\"}\n{\"path\":\"b\n", []string{"a"}, "line 2"},
+		{"wrong type", "{\"path\":\"a\",\"skipped\":\"no\"}\n", nil, "line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listens, err := ReadListens(strings.NewReader(tt.log))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			var paths []string
+			for _, listen := range listens {
+				paths = append(paths, listen.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("read %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package library
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/tags"
+)
+
+// LyricLine is a line of lyrics, see tags.LyricLine
+type LyricLine = tags.LyricLine
+
+// Lyrics are the words of a track, see tags.Lyrics
+type Lyrics = tags.Lyrics
+
+// lrcTag matches a bracketed tag at the start of an LRC line: a time
+// stamp, [mm:ss], [mm:ss.xx] or [mm:ss:xx], or a metadata tag such as
+// [ar:Artist] or [offset:+250]
+var lrcTag = regexp.MustCompile(`^\[([^\]]*)\]`)
+
+// lrcTime matches the time stamp in a tag
+var lrcTime = regexp.MustCompile(`^(\d+):(\d{1,2})(?:[.:](\d{1,3}))?$`)
+
+// lrcWordTime matches the per-word time stamps of enhanced LRC, <mm:ss.xx>
+var lrcWordTime = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`)
+
+// ParseLRC reads timed lyrics in the LRC format. A line may carry several
+// time stamps, for a chorus sung more than once, and is listed at each.
+// The [offset:] tag moves every line, in milliseconds, a positive offset
+// making the lines come sooner. Other metadata tags, lines without a time
+// stamp and per-word time stamps are passed over. It returns no lines
+// when there are no time stamps, e.g. for plain text.
+func ParseLRC(r io.Reader) ([]LyricLine, error) {
+	var lines []LyricLine
+	var offset time.Duration
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rest := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+
+		var stamps []time.Duration
+		for {
+			match := lrcTag.FindStringSubmatch(rest)
+			if match == nil {
+				break
+			}
+			rest = rest[len(match[0]):]
+
+			if at, ok := parseLRCTime(match[1]); ok {
+				stamps = append(stamps, at)
+				continue
+			}
+			name, value, _ := strings.Cut(match[1], ":")
+			if strings.EqualFold(strings.TrimSpace(name), "offset") {
+				if ms, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+					offset = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+
+		text := strings.TrimSpace(lrcWordTime.ReplaceAllString(rest, ""))
+		for _, at := range stamps {
+			lines = append(lines, LyricLine{At: at, Text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range lines {
+		lines[i].At = max(lines[i].At-offset, 0)
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].At < lines[j].At })
+	return lines, nil
+}
+
+// parseLRCTime parses the inside of a time stamp tag. Fractions of one or
+// two digits are tenths or hundredths, of three milliseconds.
+func parseLRCTime(s string) (time.Duration, bool) {
+	match := lrcTime.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, false
+	}
+	minutes, _ := strconv.Atoi(match[1])
+	seconds, _ := strconv.Atoi(match[2])
+	if seconds >= 60 {
+		return 0, false
+	}
+
+	at := time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	if fraction := match[3]; fraction != "" {
+		n, _ := strconv.Atoi(fraction)
+		for range 3 - len(fraction) {
+			n *= 10
+		}
+		at += time.Duration(n) * time.Millisecond
+	}
+	return at, true
+}
+
+// ParseLyrics reads lyrics as a tag holds them: timed when they are in
+// the LRC format, as some taggers write them, and otherwise line by line
+func ParseLyrics(text string) Lyrics {
+	if lines, err := ParseLRC(strings.NewReader(text)); err == nil && len(lines) > 0 {
+		return Lyrics{Lines: lines, Timed: true}
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return Lyrics{}
+	}
+	var lyrics Lyrics
+	for _, line := range strings.Split(text, "\n") {
+		lyrics.Lines = append(lyrics.Lines, LyricLine{Text: strings.TrimRight(line, " \t\r")})
+	}
+	return lyrics
+}
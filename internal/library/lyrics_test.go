@@ -0,0 +1,98 @@
+package library
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLRC(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name string
+		in   string
+		want []LyricLine
+	}{
+		{"empty", "", nil},
+		{"plain text", "Just words\n[Chorus]\nMore words", nil},
+		{
+			"stamps",
+			"[00:01]One\n[00:02.5]Two\n[00:03.25]Three\n[00:04.125]Four\n[01:05:50]Five",
+			[]LyricLine{{At: 1000 * ms, Text: "One"}, {At: 2500 * ms, Text: "Two"}, {At: 3250 * ms, Text: "Three"}, {At: 4125 * ms, Text: "Four"}, {At: 65500 * ms, Text: "Five"}},
+		},
+		{
+			"repeated stamps",
+			"[00:10.00][00:30.00]Chorus\n[00:20.00]Verse",
+			[]LyricLine{{At: 10 * time.Second, Text: "Chorus"}, {At: 20 * time.Second, Text: "Verse"}, {At: 30 * time.Second, Text: "Chorus"}},
+		},
+		{
+			"metadata",
+			"[ar:Artist]\n[ti:Title]\n[al:Album]\n[by:someone]\n[00:01.00]Line",
+			[]LyricLine{{At: time.Second, Text: "Line"}},
+		},
+		{
+			"offset sooner",
+			"[offset:+250]\n[00:01.00]One\n[00:00.10]Zero",
+			[]LyricLine{{At: 0, Text: "Zero"}, {At: 750 * ms, Text: "One"}},
+		},
+		{
+			"offset later, anywhere in the file",
+			"[00:01.00]One\n[offset:-500]",
+			[]LyricLine{{At: 1500 * ms, Text: "One"}},
+		},
+		{
+			"word stamps stripped",
+			"[00:01.00]<00:01.00>One <00:01.50>word",
+			[]LyricLine{{At: time.Second, Text: "One word"}},
+		},
+		{
+			"invalid stamps",
+			"[00:61.00]Bad seconds\n[aa:bb]Letters\n[00:02.00]Good",
+			[]LyricLine{{At: 2 * time.Second, Text: "Good"}},
+		},
+		{"BOM", "\uFEFF[00:01.00]One", []LyricLine{{At: time.Second, Text: "One"}}},
+		{"empty line kept", "[00:01.00]\n[00:02.00]Two", []LyricLine{{At: time.Second, Text: ""}, {At: 2 * time.Second, Text: "Two"}}},
+		{"CRLF and spaces", "[00:01.00] One \r\n", []LyricLine{{At: time.Second, Text: "One"}}},
+		{"sorted", "[00:03]C\n[00:01]A\n[00:02]B", []LyricLine{{At: time.Second, Text: "A"}, {At: 2 * time.Second, Text: "B"}, {At: 3 * time.Second, Text: "C"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLRC(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLRC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLyrics(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Lyrics
+	}{
+		{"none", "", Lyrics{}},
+		{"blank", " \n\n", Lyrics{}},
+		{
+			"untimed",
+			"\nFirst line  \r\n\r\n[Chorus]\nLast\n",
+			Lyrics{Lines: []LyricLine{{Text: "First line"}, {Text: ""}, {Text: "[Chorus]"}, {Text: "Last"}}},
+		},
+		{
+			"timed in a tag",
+			"[00:01.00]One\n[00:02.00]Two",
+			Lyrics{Lines: []LyricLine{{At: time.Second, Text: "One"}, {At: 2 * time.Second, Text: "Two"}}, Timed: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseLyrics(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLyrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package library
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Reasons a playlist entry is skipped
+var (
+	ErrURL            = errors.New("URL entries are not fetched")
+	ErrOutsideSandbox = errors.New("outside the playlist's directory and the allowed roots")
+	ErrForeignPath    = errors.New("absolute path for another operating system")
+)
+
+// urlPattern matches entries with a URL scheme such as http:// or file://.
+// A single letter before the colon is a Windows drive, not a scheme.
+var urlPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]+:`)
+
+// drivePattern matches Windows drive-letter paths such as C:\Music
+var drivePattern = regexp.MustCompile(`^[A-Za-z]:([\\/]|$)`)
+
+// ReadM3U returns the entries of an M3U or M3U8 playlist, skipping blank
+// lines and #EXTINF style comments
+func ReadM3U(r io.Reader) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// ResolveEntry turns a playlist entry into a local file path. Relative
+// entries are resolved against dir, the playlist's own directory. When
+// sandboxed, the resolved file, after following symlinks, must lie within
+// dir or one of roots. URLs are refused, except http(s) URLs with streams
+// set, which are returned as they are. Windows drive-letter and UNC paths
+// are only accepted on Windows.
+func ResolveEntry(entry, dir string, roots []string, sandboxed, streams bool) (string, error) {
+	if urlPattern.MatchString(entry) && !drivePattern.MatchString(entry) {
+		if streams && isHTTP(entry) {
+			return entry, nil
+		}
+		return "", ErrURL
+	}
+
+	foreign := drivePattern.MatchString(entry) || strings.HasPrefix(entry, `\\`) || strings.HasPrefix(entry, "//")
+	if foreign && runtime.GOOS != "windows" {
+		return "", ErrForeignPath
+	}
+
+	// Playlists written on Windows separate relative entries with backslashes
+	if runtime.GOOS != "windows" {
+		entry = strings.ReplaceAll(entry, `\`, "/")
+	}
+
+	path := filepath.FromSlash(entry)
+	if !filepath.IsAbs(path) && !foreign {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	// Follow symlinks so a link inside the sandbox can't lead out of it
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !sandboxed {
+		return path, nil
+	}
+	for _, root := range append([]string{dir}, roots...) {
+		if Within(resolved, root) {
+			return path, nil
+		}
+	}
+	return "", ErrOutsideSandbox
+}
+
+// isHTTP reports whether entry is an http(s) URL, which can be streamed
+func isHTTP(entry string) bool {
+	lower := strings.ToLower(entry)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Within reports whether path lies inside root, both compared with
+// symlinks resolved
+func Within(path, root string) bool {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// EntryError describes a skipped playlist entry
+type EntryError struct {
+	Playlist string
+	Entry    string
+	Err      error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s: skipping %s: %v", e.Playlist, e.Entry, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// LoadM3U reads a playlist file and resolves its entries with
+// ResolveEntry, returning the usable paths and an EntryError for each
+// skipped entry
+func LoadM3U(playlist string, roots []string, sandboxed, streams bool) ([]string, []error, error) {
+	file, err := os.Open(playlist)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	entries, err := ReadM3U(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(playlist))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var paths []string
+	var skipped []error
+	for _, entry := range entries {
+		path, err := ResolveEntry(entry, dir, roots, sandboxed, streams)
+		if err != nil {
+			skipped = append(skipped, &EntryError{Playlist: playlist, Entry: entry, Err: err})
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, skipped, nil
+}
+
+// M3UEntry is a track written by WriteM3U. Title becomes the #EXTINF
+// display name and Length its duration, -1 when unknown.
+type M3UEntry struct {
+	Path   string
+	Title  string
+	Length time.Duration
+}
+
+// WriteM3U writes an extended M3U playlist, readable back with ReadM3U
+func WriteM3U(w io.Writer, entries []M3UEntry) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "#EXTM3U")
+	for _, entry := range entries {
+		seconds := -1
+		if entry.Length > 0 {
+			seconds = int(entry.Length.Round(time.Second) / time.Second)
+		}
+		// A line break in a tag would end the directive early
+		title := strings.NewReplacer("\r", " ", "\n", " ").Replace(entry.Title)
+		fmt.Fprintf(out, "#EXTINF:%d,%s\n%s\n", seconds, title, entry.Path)
+	}
+	return out.Flush()
+}
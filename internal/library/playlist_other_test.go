@@ -0,0 +1,35 @@
+//go:build !windows
+
+package library
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestResolveForeignEntry resolves Windows absolute entries, which name
+// no file here whether sandboxed or not
+func TestResolveForeignEntry(t *testing.T) {
+	dir := filepath.Join(playlistTree(t), "lists")
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"drive letter", `C:\Music\a.mp3`},
+		{"drive letter with slashes", "C:/Music/a.mp3"},
+		{"lower case drive", `d:\a.mp3`},
+		{"drive root", "C:"},
+		{"UNC", `\\server\share\a.mp3`},
+		{"UNC with slashes", "//server/share/a.mp3"},
+		{"extended length", `\\?\C:\Music\a.mp3`},
+	}
+	for _, tt := range tests {
+		for _, sandboxed := range []bool{true, false} {
+			got, err := ResolveEntry(tt.entry, dir, nil, sandboxed, true)
+			if !errors.Is(err, ErrForeignPath) {
+				t.Errorf("%s: ResolveEntry(%s), sandboxed %v = %q, %v, want %v", tt.name, tt.entry, sandboxed, got, err, ErrForeignPath)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package library
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// playlistTree writes a playlist's directory, lists, with tracks in and
+// next to it and symlinks leading in and out of it, returning the
+// directory holding it all
+func playlistTree(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	for _, name := range []string{"lists/a.mp3", "lists/sub/b.mp3", "outside/c.mp3", "allowed/d.mp3"} {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"lists/escape.mp3": "../outside/c.mp3",
+		"lists/inside.mp3": "sub/b.mp3",
+		"lists/elsewhere":  "../outside",
+	}
+	for link, target := range links {
+		if err := os.Symlink(filepath.FromSlash(target), filepath.Join(base, filepath.FromSlash(link))); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+	return base
+}
+
+func TestResolveEntry(t *testing.T) {
+	base := playlistTree(t)
+	dir := filepath.Join(base, "lists")
+	abs := func(name string) string { return filepath.Join(base, filepath.FromSlash(name)) }
+
+	tests := []struct {
+		name  string
+		entry string
+		// roots are allowed besides dir
+		roots     []string
+		sandboxed bool
+		streams   bool
+		// want is the path returned, relative to base, or the URL
+		want    string
+		wantErr error
+	}{
+		{"relative", "a.mp3", nil, true, false, "lists/a.mp3", nil},
+		{"subdirectory", "sub/b.mp3", nil, true, false, "lists/sub/b.mp3", nil},
+		{"backslashes", `sub\b.mp3`, nil, true, false, "lists/sub/b.mp3", nil},
+		{"dot segments inside", "sub/../a.mp3", nil, true, false, "lists/a.mp3", nil},
+		{"absolute inside", abs("lists/a.mp3"), nil, true, false, "lists/a.mp3", nil},
+		{"dot dot escape", "../outside/c.mp3", nil, true, false, "", ErrOutsideSandbox},
+		{"deep dot dot escape", "sub/../../outside/c.mp3", nil, true, false, "", ErrOutsideSandbox},
+		{"absolute outside", abs("outside/c.mp3"), nil, true, false, "", ErrOutsideSandbox},
+		{"symlink escape", "escape.mp3", nil, true, false, "", ErrOutsideSandbox},
+		{"symlinked directory escape", "elsewhere/c.mp3", nil, true, false, "", ErrOutsideSandbox},
+		{"symlink inside", "inside.mp3", nil, true, false, "lists/inside.mp3", nil},
+		{"allowed root", abs("allowed/d.mp3"), []string{abs("allowed")}, true, false, "allowed/d.mp3", nil},
+		{"allowed root relative", "../allowed/d.mp3", []string{abs("allowed")}, true, false, "allowed/d.mp3", nil},
+		{"symlink into allowed root", "escape.mp3", []string{abs("outside")}, true, false, "lists/escape.mp3", nil},
+		{"other root not allowed", "../outside/c.mp3", []string{abs("allowed")}, true, false, "", ErrOutsideSandbox},
+		{"root prefix is not a root", "../allowed/d.mp3", []string{abs("allow")}, true, false, "", ErrOutsideSandbox},
+		{"unsandboxed dot dot", "../outside/c.mp3", nil, false, false, "outside/c.mp3", nil},
+		{"unsandboxed absolute", abs("outside/c.mp3"), nil, false, false, "outside/c.mp3", nil},
+		{"unsandboxed symlink", "escape.mp3", nil, false, false, "lists/escape.mp3", nil},
+		{"missing", "gone.mp3", nil, true, false, "", fs.ErrNotExist},
+		{"missing unsandboxed", "../gone.mp3", nil, false, false, "", fs.ErrNotExist},
+		{"http", "http://radio.example/live", nil, true, false, "", ErrURL},
+		{"http unsandboxed", "http://radio.example/live", nil, false, false, "", ErrURL},
+		{"file URL", "file:///etc/passwd", nil, false, true, "", ErrURL},
+		{"other scheme", "ftp://example.com/a.mp3", nil, true, true, "", ErrURL},
+		{"http allowed", "http://radio.example/live", nil, true, true, "http://radio.example/live", nil},
+		{"https allowed unsandboxed", "HTTPS://example.com/a.mp3", nil, false, true, "HTTPS://example.com/a.mp3", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveEntry(tt.entry, dir, tt.roots, tt.sandboxed, tt.streams)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ResolveEntry(%s) = %q, %v, want %v", tt.entry, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveEntry(%s) error = %v", tt.entry, err)
+			}
+			want := tt.want
+			if !strings.Contains(want, "://") {
+				want = abs(want)
+			}
+			if got != want {
+				t.Errorf("ResolveEntry(%s) = %s, want %s", tt.entry, got, want)
+			}
+		})
+	}
+}
+
+func TestWithin(t *testing.T) {
+	root := filepath.FromSlash("/music")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/music/a.mp3", true},
+		{"/music/rock/a.mp3", true},
+		{"/music", true},
+		{"/music/..a.mp3", true},
+		{"/musical/a.mp3", false},
+		{"/", false},
+		{"/other/music/a.mp3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := filepath.Abs(filepath.FromSlash(tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Within(path, root); got != tt.want {
+				t.Errorf("Within(%s, %s) = %v, want %v", path, root, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadM3U(t *testing.T) {
+	base := playlistTree(t)
+	playlist := filepath.Join(base, "lists", "mix.m3u8")
+	data := "\ufeff#EXTM3U\n#EXTINF:3,A\na.mp3\n\n  sub/b.mp3  \n../outside/c.mp3\nhttp://radio.example/live\n"
+	if err := os.WriteFile(playlist, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		sandboxed bool
+		streams   bool
+		want      []string
+		// skipped are the entries skipped
+		skipped []string
+	}{
+		{"sandboxed", true, false, []string{"lists/a.mp3", "lists/sub/b.mp3"}, []string{"../outside/c.mp3", "http://radio.example/live"}},
+		{"unsandboxed", false, false, []string{"lists/a.mp3", "lists/sub/b.mp3", "outside/c.mp3"}, []string{"http://radio.example/live"}},
+		{"streams", true, true, []string{"lists/a.mp3", "lists/sub/b.mp3", "http://radio.example/live"}, []string{"../outside/c.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, skipped, err := LoadM3U(playlist, nil, tt.sandboxed, tt.streams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, path := range tt.want {
+				if !strings.Contains(path, "://") {
+					path = filepath.Join(base, filepath.FromSlash(path))
+				}
+				want = append(want, path)
+			}
+			if !slices.Equal(paths, want) {
+				t.Errorf("paths = %v, want %v", paths, want)
+			}
+			var entries []string
+			for _, err := range skipped {
+				var entry *EntryError
+				if !errors.As(err, &entry) || entry.Playlist != playlist {
+					t.Errorf("skipped %v, want an EntryError of the playlist", err)
+					continue
+				}
+				entries = append(entries, entry.Entry)
+			}
+			if !slices.Equal(entries, tt.skipped) {
+				t.Errorf("skipped %v, want %v", entries, tt.skipped)
+			}
+		})
+	}
+
+	if _, _, err := LoadM3U(filepath.Join(base, "gone.m3u"), nil, true, false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadM3U of a missing playlist = %v, want it not to exist", err)
+	}
+}
+
+func TestWriteM3U(t *testing.T) {
+	var out strings.Builder
+	err := WriteM3U(&out, []M3UEntry{
+		{Path: "/music/01.mp3", Title: "Artist - One", Length: 61400 * time.Millisecond},
+		{Path: "/music/02.mp3", Title: "Two\nlines", Length: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n#EXTINF:61,Artist - One\n/music/01.mp3\n#EXTINF:-1,Two lines\n/music/02.mp3\n"
+	if out.String() != want {
+		t.Errorf("WriteM3U wrote %q, want %q", out.String(), want)
+	}
+	entries, err := ReadM3U(strings.NewReader(out.String()))
+	if err != nil || !slices.Equal(entries, []string{"/music/01.mp3", "/music/02.mp3"}) {
+		t.Errorf("read back %v, %v", entries, err)
+	}
+}
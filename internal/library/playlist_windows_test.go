@@ -0,0 +1,56 @@
+//go:build windows
+
+package library
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResolveWindowsEntry resolves drive-letter and UNC entries, which are
+// held to the sandbox like any other absolute path
+func TestResolveWindowsEntry(t *testing.T) {
+	base := playlistTree(t)
+	dir := filepath.Join(base, "lists")
+	inside := filepath.Join(dir, "a.mp3")
+	outside := filepath.Join(base, "outside", "c.mp3")
+	tests := []struct {
+		name      string
+		entry     string
+		roots     []string
+		sandboxed bool
+		want      string
+		// wantErr is any error when set without a want
+		wantErr error
+	}{
+		{"drive letter inside", inside, nil, true, inside, nil},
+		{"drive letter with slashes", filepath.ToSlash(inside), nil, true, inside, nil},
+		{"lower case drive", strings.ToLower(inside[:1]) + inside[1:], nil, true, strings.ToLower(inside[:1]) + inside[1:], nil},
+		{"drive letter outside", outside, nil, true, "", ErrOutsideSandbox},
+		{"drive letter allowed", outside, []string{filepath.Dir(outside)}, true, outside, nil},
+		{"drive letter unsandboxed", outside, nil, false, outside, nil},
+		{"dot dot escape", `..\outside\c.mp3`, nil, true, "", ErrOutsideSandbox},
+		{"missing drive", `Q:\Music\a.mp3`, nil, false, "", nil},
+		{"UNC", `\\server.invalid\share\a.mp3`, nil, true, "", nil},
+		{"UNC with slashes", "//server.invalid/share/a.mp3", nil, false, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveEntry(tt.entry, dir, tt.roots, tt.sandboxed, false)
+			switch {
+			case tt.want != "":
+				if err != nil || got != tt.want {
+					t.Errorf("ResolveEntry(%s) = %q, %v, want %s", tt.entry, got, err, tt.want)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ResolveEntry(%s) = %q, %v, want %v", tt.entry, got, err, tt.wantErr)
+				}
+			case err == nil:
+				t.Errorf("ResolveEntry(%s) = %q, want an error", tt.entry, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package library
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/pkg/tags"
+)
+
+// ReplayGain holds the loudness adjustments tagged on a track, see
+// tags.ReplayGain
+type ReplayGain = tags.ReplayGain
+
+// ReadReplayGain picks the ReplayGain values out of the raw tags of a
+// file: Vorbis comments such as REPLAYGAIN_TRACK_GAIN=-6.20 dB, or ID3
+// TXXX frames with the same names as their description. Names are matched
+// regardless of case, and values that don't parse are ignored.
+func ReadReplayGain(raw map[string]interface{}) ReplayGain {
+	var rg ReplayGain
+	for key, value := range raw {
+		name, text := key, ""
+		switch v := value.(type) {
+		case string:
+			text = v
+		case *tag.Comm:
+			name, text = v.Description, v.Text
+		default:
+			continue
+		}
+
+		number, ok := parseGainValue(text)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "replaygain_track_gain":
+			rg.TrackGain, rg.HasTrack = number, true
+		case "replaygain_track_peak":
+			rg.TrackPeak = number
+		case "replaygain_album_gain":
+			rg.AlbumGain, rg.HasAlbum = number, true
+		case "replaygain_album_peak":
+			rg.AlbumPeak = number
+		}
+	}
+	return rg
+}
+
+// parseGainValue parses a tag value such as "-6.20 dB" or "0.988547"
+func parseGainValue(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && strings.EqualFold(text[len(text)-2:], "db") {
+		text = strings.TrimSpace(text[:len(text)-2])
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,130 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+// id3Tag returns an ID3v2.3 tag holding a TXXX frame for each pair of
+// description and value
+func id3Tag(pairs ...string) []byte {
+	var frames bytes.Buffer
+	for i := 0; i < len(pairs); i += 2 {
+		body := append([]byte{0}, pairs[i]...)
+		body = append(body, 0)
+		body = append(body, pairs[i+1]...)
+		frames.WriteString("TXXX")
+		binary.Write(&frames, binary.BigEndian, uint32(len(body)))
+		frames.Write([]byte{0, 0})
+		frames.Write(body)
+	}
+
+	size := frames.Len()
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, frames.Bytes()...)
+}
+
+// flacTag returns the start of a FLAC file whose Vorbis comment block
+// holds comments
+func flacTag(comments ...string) []byte {
+	var block bytes.Buffer
+	vendor := "test"
+	binary.Write(&block, binary.LittleEndian, uint32(len(vendor)))
+	block.WriteString(vendor)
+	binary.Write(&block, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&block, binary.LittleEndian, uint32(len(c)))
+		block.WriteString(c)
+	}
+
+	size := block.Len()
+	data := append([]byte("fLaC"), 0x80|4, byte(size>>16), byte(size>>8), byte(size))
+	return append(data, block.Bytes()...)
+}
+
+func TestReadReplayGain(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want ReplayGain
+	}{
+		{
+			name: "ID3 track and album",
+			data: id3Tag(
+				"REPLAYGAIN_TRACK_GAIN", "-6.20 dB", "REPLAYGAIN_TRACK_PEAK", "0.988547",
+				"REPLAYGAIN_ALBUM_GAIN", "-7.05 dB", "REPLAYGAIN_ALBUM_PEAK", "1.000000",
+			),
+			want: ReplayGain{TrackGain: -6.2, TrackPeak: 0.988547, AlbumGain: -7.05, AlbumPeak: 1, HasTrack: true, HasAlbum: true},
+		},
+		{
+			name: "ID3 lower case, track only",
+			data: id3Tag("replaygain_track_gain", "+2.5 dB", "replaygain_track_peak", "0.5"),
+			want: ReplayGain{TrackGain: 2.5, TrackPeak: 0.5, HasTrack: true},
+		},
+		{
+			name: "ID3 other TXXX frames",
+			data: id3Tag("MusicBrainz Album Id", "abc", "REPLAYGAIN_TRACK_GAIN", "-1 dB"),
+			want: ReplayGain{TrackGain: -1, HasTrack: true},
+		},
+		{
+			name: "Vorbis track and album",
+			data: flacTag(
+				"TITLE=Song", "REPLAYGAIN_TRACK_GAIN=-3.10 dB", "REPLAYGAIN_TRACK_PEAK=0.891251",
+				"REPLAYGAIN_ALBUM_GAIN=-4.00 dB", "REPLAYGAIN_ALBUM_PEAK=0.999969",
+			),
+			want: ReplayGain{TrackGain: -3.1, TrackPeak: 0.891251, AlbumGain: -4, AlbumPeak: 0.999969, HasTrack: true, HasAlbum: true},
+		},
+		{
+			name: "Vorbis without unit",
+			data: flacTag("replaygain_album_gain=1.25"),
+			want: ReplayGain{AlbumGain: 1.25, HasAlbum: true},
+		},
+		{
+			name: "Vorbis values that don't parse",
+			data: flacTag("REPLAYGAIN_TRACK_GAIN=loud", "REPLAYGAIN_TRACK_PEAK=NaN", "REPLAYGAIN_ALBUM_GAIN=inf dB"),
+		},
+		{
+			name: "untagged",
+			data: flacTag("TITLE=Song"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tag.ReadFrom(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ReadReplayGain(m.Raw()); got != tt.want {
+				t.Errorf("ReadReplayGain() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGainValue(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+		ok   bool
+	}{
+		{"-6.20 dB", -6.2, true},
+		{"+3.5dB", 3.5, true},
+		{" 0.988547 ", 0.988547, true},
+		{"-1 DB", -1, true},
+		{"dB", 0, false},
+		{"", 0, false},
+		{"NaN", 0, false},
+		{"-Inf dB", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := parseGainValue(tt.text)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseGainValue(%q) = %v, %v, want %v, %v", tt.text, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
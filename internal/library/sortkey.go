@@ -0,0 +1,46 @@
+// Package library holds dirplay's music library logic that doesn't depend
+// on audio playback or the terminal UI.
+package library
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+)
+
+// Leading articles ignored when a name has no explicit sort tag, so that
+// "The Beatles" files under B
+var articles = []string{"the ", "a ", "an "}
+
+// folder performs Unicode case folding, which unlike ToLower treats
+// characters such as "ß" and "ss" as equal
+var folder = cases.Fold()
+
+// SortKey returns the key used to order a name alphabetically. An explicit
+// sort tag (TSOP, ARTISTSORT, ALBUMARTISTSORT) wins, otherwise leading
+// articles are stripped from the name. The result is case folded.
+func SortKey(name, sortTag string) string {
+	if key := strings.TrimSpace(sortTag); key != "" {
+		return folder.String(key)
+	}
+
+	key := folder.String(strings.TrimSpace(name))
+	for _, article := range articles {
+		if rest := strings.TrimPrefix(key, article); rest != key && rest != "" {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return key
+}
+
+// Initial returns the upper-cased first letter or digit of a sort key, or
+// zero when it has none
+func Initial(key string) rune {
+	for _, r := range key {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+	}
+	return 0
+}
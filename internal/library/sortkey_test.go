@@ -0,0 +1,30 @@
+package library
+
+import "testing"
+
+func TestSortKey(t *testing.T) {
+	tests := []struct {
+		name, sortTag string
+		want          string
+		initial       rune
+	}{
+		{"The Beatles", "", "beatles", 'B'},
+		{"A Tribe Called Quest", "", "tribe called quest", 'T'},
+		{"The", "", "the", 'T'},
+		{"Theatre of Tragedy", "", "theatre of tragedy", 'T'},
+		{"The Beatles", "Beatles, The", "beatles, the", 'B'},
+		{"Straße", "", "strasse", 'S'},
+		{"...And You Will Know Us", "", "...and you will know us", 'A'},
+		{"2Pac", "", "2pac", '2'},
+		{"!!!", "", "!!!", 0},
+	}
+	for _, tt := range tests {
+		key := SortKey(tt.name, tt.sortTag)
+		if key != tt.want {
+			t.Errorf("SortKey(%q, %q) = %q, want %q", tt.name, tt.sortTag, key, tt.want)
+		}
+		if got := Initial(key); got != tt.initial {
+			t.Errorf("Initial(%q) = %q, want %q", key, got, tt.initial)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package library
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// TagLength returns the length stored in the ID3v2 TLEN (v2.2 TLE) frame,
+// which holds milliseconds, or zero if there is none
+func TagLength(tags tag.Metadata) time.Duration {
+	raw := tags.Raw()
+	for _, key := range []string{"TLEN", "TLE"} {
+		value, ok := raw[key].(string)
+		if !ok {
+			continue
+		}
+		if ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// SortArtistTag returns the artist sort order tag, trying the ID3v2
+// (TSOP, TSO2), Vorbis comment and MP4 spellings
+func SortArtistTag(tags tag.Metadata) string {
+	raw := tags.Raw()
+	for _, name := range []string{"TSOP", "artistsort", "soar", "TSO2", "albumartistsort", "soaa"} {
+		if value, ok := raw[name].(string); ok && strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
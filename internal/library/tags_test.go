@@ -0,0 +1,57 @@
+package library
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// rawTags is tag.Metadata with only the raw frames set
+type rawTags struct {
+	tag.Metadata
+	raw map[string]interface{}
+}
+
+func (r rawTags) Raw() map[string]interface{} { return r.raw }
+
+func TestTagLength(t *testing.T) {
+	tests := []struct {
+		raw  map[string]interface{}
+		want time.Duration
+	}{
+		{map[string]interface{}{"TLEN": "215000"}, 215 * time.Second},
+		{map[string]interface{}{"TLE": " 1500 "}, 1500 * time.Millisecond},
+		{map[string]interface{}{"TLEN": "0", "TLE": "2000"}, 2 * time.Second},
+		{map[string]interface{}{"TLEN": "3:35"}, 0},
+		{map[string]interface{}{"TLEN": "-5"}, 0},
+		{map[string]interface{}{"TLEN": 215000}, 0},
+		{map[string]interface{}{}, 0},
+	}
+	for _, tt := range tests {
+		if got := TagLength(rawTags{raw: tt.raw}); got != tt.want {
+			t.Errorf("TagLength(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSortArtistTag(t *testing.T) {
+	tests := []struct {
+		raw  map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"TSOP": "Beatles, The"}, "Beatles, The"},
+		{map[string]interface{}{"artistsort": "Bowie, David"}, "Bowie, David"},
+		{map[string]interface{}{"soar": "Cure, The"}, "Cure, The"},
+		// The artist's sort order wins over the album artist's
+		{map[string]interface{}{"TSO2": "Various", "TSOP": "Doors, The"}, "Doors, The"},
+		{map[string]interface{}{"albumartistsort": "Eagles, The"}, "Eagles, The"},
+		{map[string]interface{}{"TSOP": "  ", "soaa": "Fall, The"}, "Fall, The"},
+		{map[string]interface{}{"TPE1": "Genesis"}, ""},
+	}
+	for _, tt := range tests {
+		if got := SortArtistTag(rawTags{raw: tt.raw}); got != tt.want {
+			t.Errorf("SortArtistTag(%v) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package codec decodes the audio formats dirplay plays: MP3, WAV, FLAC,
+// Ogg Vorbis and Ogg Opus, chosen by file extension. A Registry holds the
+// decoders; programs can register more of their own.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/flac"
+	"github.com/gopxl/beep/mp3"
+	"github.com/gopxl/beep/vorbis"
+	"github.com/gopxl/beep/wav"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+)
+
+// DecodeFunc decodes an opened audio file, or a stream, from its start.
+// Decoders seek when the reader can.
+type DecodeFunc func(r io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error)
+
+// ErrUnsupportedContainer is what files in a container no decoder reads
+// fail with: MP4, which holds AAC, ALAC and at times Opus, under any
+// extension
+var ErrUnsupportedContainer = errors.New("unsupported container")
+
+// Registry maps the file extensions played, lower case with the dot, to
+// the decoders of their formats. Scans only pick up files with an
+// extension it has, so every track found has a decoder. The zero value
+// has no formats; New returns one with dirplay's. A Registry must not be
+// changed once in use.
+type Registry struct {
+	decoders map[string]DecodeFunc
+	// unsupported maps the extensions of files in containers that aren't
+	// played to the container's name. Scans report such files as skipped
+	// rather than passing them over as they do other files.
+	unsupported map[string]string
+}
+
+// New returns a Registry of the formats dirplay plays
+func New() *Registry {
+	r := &Registry{unsupported: map[string]string{
+		".m4a": "MP4",
+		".m4b": "MP4",
+	}}
+	r.Register(".mp3", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		return mp3.Decode(rc)
+	})
+	r.Register(".wav", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		return wav.Decode(rc)
+	})
+	r.Register(".flac", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		return flac.Decode(rc)
+	})
+	r.Register(".ogg", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		return vorbis.Decode(rc)
+	})
+	r.Register(".opus", decodeOpus)
+	return r
+}
+
+// Register has files with extension ext, e.g. ".aac", decoded by decode,
+// replacing the decoder it had. A container reported as unsupported
+// before is played from then on.
+func (r *Registry) Register(ext string, decode DecodeFunc) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if r.decoders == nil {
+		r.decoders = make(map[string]DecodeFunc)
+	}
+	r.decoders[ext] = decode
+	delete(r.unsupported, ext)
+}
+
+// IsAudioFile reports whether path has the extension of a format played
+func (r *Registry) IsAudioFile(path string) bool {
+	_, ok := r.decoders[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// UnsupportedError returns the error for path if its extension is that of
+// a container that isn't played, or nil
+func (r *Registry) UnsupportedError(path string) error {
+	if name, ok := r.unsupported[strings.ToLower(filepath.Ext(path))]; ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedContainer, name)
+	}
+	return nil
+}
+
+// Extensions returns the extensions played, sorted
+func (r *Registry) Extensions() []string {
+	exts := make([]string, 0, len(r.decoders))
+	for ext := range r.decoders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// Decode decodes a file or stream by its extension, e.g. ".mp3". The
+// streamer closes rc when it is closed.
+func (r *Registry) Decode(rc io.ReadCloser, ext string) (beep.StreamSeekCloser, beep.Format, error) {
+	if err := r.UnsupportedError(ext); err != nil {
+		return nil, beep.Format{}, err
+	}
+	decode, ok := r.decoders[strings.ToLower(ext)]
+	if !ok {
+		return nil, beep.Format{}, fmt.Errorf("unsupported audio format: %s (want one of %s)", ext, strings.Join(r.Extensions(), ", "))
+	}
+	streamer, format, err := decode(rc)
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	return streamer, format, nil
+}
+
+// Open opens and decodes an audio file. Closing the streamer closes the
+// file. An MP4 file named as one of the formats of New, e.g. Opus in MP4
+// named .opus, is refused as an unsupported container.
+func (r *Registry) Open(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	file, err := os.Open(fspath.Long(path))
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if builtIn(filepath.Ext(path)) && isMP4(file) {
+		file.Close()
+		return nil, beep.Format{}, fmt.Errorf("%w: MP4", ErrUnsupportedContainer)
+	}
+	streamer, format, err := r.Decode(file, filepath.Ext(path))
+	if err != nil {
+		file.Close()
+		return nil, beep.Format{}, err
+	}
+	return streamer, format, nil
+}
+
+// builtIn reports whether ext is that of one of the formats of New, none
+// of which comes in MP4
+func builtIn(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".mp3", ".wav", ".flac", ".ogg", ".opus":
+		return true
+	}
+	return false
+}
+
+// isMP4 reports whether file starts with the ftyp box of an MP4 file
+func isMP4(file *os.File) bool {
+	var head [8]byte
+	if _, err := file.ReadAt(head[:], 0); err != nil {
+		return false
+	}
+	return string(head[4:]) == "ftyp"
+}
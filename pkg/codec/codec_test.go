@@ -0,0 +1,315 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+	"github.com/thesyncim/gopus"
+	"github.com/thesyncim/gopus/container/ogg"
+)
+
+// testSeconds is how long each fixture plays
+const testSeconds = 2
+
+// tone returns sample i of a 440 Hz tone at rate
+func tone(i, rate int) float64 {
+	return 0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(rate))
+}
+
+// writeWAV writes a 16-bit stereo WAV file of the tone
+func writeWAV(t *testing.T, path string) {
+	const rate = 44100
+	n := rate * testSeconds
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+n*4))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, struct {
+		Size             uint32
+		Format, Channels uint16
+		Rate, ByteRate   uint32
+		Align, Bits      uint16
+	}{16, 1, 2, rate, rate * 4, 4, 16})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(n*4))
+	for i := range n {
+		v := int16(tone(i, rate) * math.MaxInt16)
+		binary.Write(&b, binary.LittleEndian, []int16{v, v})
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeMP3 writes an MP3 file of silent 128 kbps frames, which have no
+// audio data to decode, 1152 samples each
+func writeMP3(t *testing.T, path string) {
+	frames := 44100 * testSeconds / 1152
+	var b bytes.Buffer
+	for range frames {
+		frame := make([]byte, 144*128000/44100)
+		binary.BigEndian.PutUint32(frame, 0xfffb9000)
+		b.Write(frame)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFLAC writes a 16-bit mono FLAC file of the tone, stored verbatim
+func writeFLAC(t *testing.T, path string) {
+	const rate, block = 44100, 4410
+	n := rate * testSeconds
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &meta.StreamInfo{BlockSizeMin: block, BlockSizeMax: block, SampleRate: rate, NChannels: 1, BitsPerSample: 16, NSamples: uint64(n)}
+	enc, err := flac.NewEncoder(file, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start, num := 0, 0; start < n; start, num = start+block, num+1 {
+		size := min(block, n-start)
+		samples := make([]int32, size)
+		for i := range samples {
+			samples[i] = int32(tone(start+i, rate) * math.MaxInt16)
+		}
+		f := &frame.Frame{
+			Header: frame.Header{HasFixedBlockSize: true, BlockSize: uint16(size), SampleRate: rate, Channels: frame.ChannelsMono, BitsPerSample: 16, Num: uint64(num)},
+			Subframes: []*frame.Subframe{{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   samples,
+				NSamples:  size,
+			}},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeOpus writes a stereo Ogg Opus file of the tone, in 20 ms packets
+func writeOpus(t *testing.T, path string) {
+	const frameSize = 960
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	enc, err := gopus.NewEncoder(gopus.EncoderConfig{SampleRate: opusRate, Channels: 2, Application: gopus.ApplicationAudio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ogg.NewWriter(file, opusRate, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := make([]float32, frameSize*2)
+	packet := make([]byte, 4000)
+	for start := 0; start < opusRate*testSeconds; start += frameSize {
+		for i := range frameSize {
+			v := float32(tone(start+i, opusRate))
+			pcm[2*i], pcm[2*i+1] = v, v
+		}
+		n, err := enc.Encode(pcm, packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WritePacket(packet[:n], frameSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOpenRoundTrip decodes a fixture of each format and checks it plays
+// for as long as it was written to, within 1%
+func TestOpenRoundTrip(t *testing.T) {
+	tests := []struct {
+		ext   string
+		write func(*testing.T, string)
+		rate  int
+	}{
+		{".wav", writeWAV, 44100},
+		{".mp3", writeMP3, 44100},
+		{".flac", writeFLAC, 44100},
+		{".opus", writeOpus, opusRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "track"+tt.ext)
+			tt.write(t, path)
+
+			streamer, format, err := New().Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer streamer.Close()
+			if int(format.SampleRate) != tt.rate {
+				t.Errorf("sample rate = %d, want %d", format.SampleRate, tt.rate)
+			}
+
+			want := float64(tt.rate * testSeconds)
+			if length := streamer.Len(); math.Abs(float64(length)-want) > want/100 {
+				t.Errorf("Len() = %d, want %v within 1%%", length, want)
+			}
+
+			decoded := 0
+			buf := make([][2]float64, 4096)
+			for {
+				n, ok := streamer.Stream(buf)
+				decoded += n
+				if !ok {
+					break
+				}
+			}
+			if err := streamer.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(float64(decoded)-want) > want/100 {
+				t.Errorf("decoded %d samples, want %v within 1%%", decoded, want)
+			}
+		})
+	}
+}
+
+// TestOpenSeek seeks to the middle of each fixture and checks decoding
+// carries on from there. FLAC seeks to the start of the frame the
+// position is in, so where a seek lands may be up to 100 ms early.
+func TestOpenSeek(t *testing.T) {
+	for ext, write := range map[string]func(*testing.T, string){".wav": writeWAV, ".flac": writeFLAC, ".opus": writeOpus} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "track"+ext)
+			write(t, path)
+			streamer, format, err := New().Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer streamer.Close()
+
+			middle := streamer.Len() / 2
+			if err := streamer.Seek(middle); err != nil {
+				t.Fatal(err)
+			}
+			if pos := streamer.Position(); pos > middle || pos < middle-format.SampleRate.N(100*time.Millisecond) {
+				t.Errorf("Position() = %d after seeking to %d", pos, middle)
+			}
+			buf := make([][2]float64, 1000)
+			if n, ok := streamer.Stream(buf); !ok || n != len(buf) {
+				t.Errorf("Stream() = %d, %v after seeking to the middle", n, ok)
+			}
+		})
+	}
+}
+
+func TestUnsupportedError(t *testing.T) {
+	tests := []struct {
+		path        string
+		unsupported bool
+	}{
+		{"song.m4a", true},
+		{"Book.M4B", true},
+		{"/music/album/01 track.m4a", true},
+		{"song.mp3", false},
+		{"song.opus", false},
+		{"song.aac", false},
+		{"notes.txt", false},
+		{"m4a", false},
+	}
+	for _, tt := range tests {
+		err := New().UnsupportedError(tt.path)
+		if got := errors.Is(err, ErrUnsupportedContainer); got != tt.unsupported {
+			t.Errorf("UnsupportedError(%q) = %v, want unsupported %v", tt.path, err, tt.unsupported)
+		}
+		if tt.unsupported && New().IsAudioFile(tt.path) {
+			t.Errorf("IsAudioFile(%q) = true for an unsupported container", tt.path)
+		}
+	}
+}
+
+// TestOpenMP4 opens MP4 files, named as such and as a format played
+func TestOpenMP4(t *testing.T) {
+	mp4 := append([]byte{0, 0, 0, 0x20}, "ftypM4A \x00\x00\x00\x00M4A mp42isom\x00\x00\x00\x00"...)
+	for _, name := range []string{"song.m4a", "book.m4b", "song.opus", "song.mp3"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, mp4, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := New().Open(path); !errors.Is(err, ErrUnsupportedContainer) {
+			t.Errorf("Open(%s) error = %v, want %v", name, err, ErrUnsupportedContainer)
+		}
+	}
+
+	if _, _, err := New().Decode(io.NopCloser(bytes.NewReader(mp4)), ".m4a"); !errors.Is(err, ErrUnsupportedContainer) {
+		t.Errorf("Decode(.m4a) error = %v, want %v", err, ErrUnsupportedContainer)
+	}
+}
+
+func TestDecodeUnknownExtension(t *testing.T) {
+	_, _, err := New().Decode(io.NopCloser(bytes.NewReader(nil)), ".xyz")
+	if err == nil || errors.Is(err, ErrUnsupportedContainer) {
+		t.Errorf("Decode(.xyz) error = %v, want an unsupported format error", err)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	want := []string{".flac", ".mp3", ".ogg", ".opus", ".wav"}
+	got := New().Extensions()
+	if len(got) != len(want) {
+		t.Fatalf("Extensions() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Extensions() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	decoded := false
+	decode := func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		decoded = true
+		return nil, beep.Format{}, errors.New("not really")
+	}
+
+	r := New()
+	r.Register("M4A", decode)
+	r.Register(".xyz", decode)
+	for _, path := range []string{"song.m4a", "song.XYZ", "song.mp3"} {
+		if !r.IsAudioFile(path) {
+			t.Errorf("IsAudioFile(%q) = false after registering", path)
+		}
+	}
+	if err := r.UnsupportedError("song.m4a"); err != nil {
+		t.Errorf("UnsupportedError(song.m4a) = %v after registering a decoder", err)
+	}
+	if _, _, err := r.Decode(io.NopCloser(bytes.NewReader(nil)), ".M4A"); !decoded || err == nil {
+		t.Errorf("Decode(.M4A) = %v, decoded %v, want the registered decoder's error", err, decoded)
+	}
+
+	// Other registries keep their own formats
+	if New().IsAudioFile("song.xyz") {
+		t.Error("registering changed the formats of a new Registry")
+	}
+	var empty Registry
+	if empty.IsAudioFile("song.mp3") || len(empty.Extensions()) != 0 {
+		t.Error("the zero Registry has formats")
+	}
+}
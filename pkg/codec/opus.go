@@ -0,0 +1,212 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gopxl/beep"
+	"github.com/thesyncim/gopus"
+	"github.com/thesyncim/gopus/container/ogg"
+)
+
+// Opus always decodes at 48 kHz, in packets of up to 120 ms. A seek
+// decodes from opusPreRoll before the target and drops that, so the
+// decoder has settled by the target, as RFC 7845 recommends.
+const (
+	opusRate     = 48000
+	opusMaxFrame = 5760
+	opusPreRoll  = 3840
+)
+
+// opusTailSize is how much of the end of a file is searched for the last
+// Ogg page, whose granule position gives the length
+const opusTailSize = 64 << 10
+
+// opusStreamer plays an Ogg Opus file. It decodes with gopus, the one
+// decoder in pure Go of all of Opus, SILK, CELT and hybrid, as the ones
+// binding libopus would need cgo to build. Positions count samples from the
+// start of the audio, after the encoder delay the header says to skip;
+// the Ogg granule positions the reader returns include it.
+type opusStreamer struct {
+	file    *os.File
+	reader  *ogg.Reader
+	decoder *gopus.Decoder
+
+	channels int
+	preSkip  int64
+	length   int
+
+	pcm     []float32
+	buf     [][2]float64
+	pending [][2]float64 // decoded, not streamed yet
+	granule int64        // of the first sample of the next packet, -1 after a seek
+	pos     int
+	err     error
+}
+
+// decodeOpus opens an Ogg Opus file, mono or stereo. Its length is read
+// from the end of the file, so streams can't be played.
+func decodeOpus(r io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	file, ok := r.(*os.File)
+	if !ok {
+		return nil, beep.Format{}, errors.New("Opus can only be played from a file")
+	}
+	reader, err := ogg.NewReader(file)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	head := reader.Header
+	if head.MappingFamily != 0 || head.Channels < 1 || head.Channels > 2 {
+		return nil, beep.Format{}, fmt.Errorf("Opus with %d channels isn't supported", head.Channels)
+	}
+
+	decoder, err := gopus.NewDecoder(gopus.DefaultDecoderConfig(opusRate, int(head.Channels)))
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	if head.OutputGain != 0 {
+		if err := decoder.SetGain(int(head.OutputGain)); err != nil {
+			return nil, beep.Format{}, err
+		}
+	}
+
+	s := &opusStreamer{
+		file:     file,
+		reader:   reader,
+		decoder:  decoder,
+		channels: int(head.Channels),
+		preSkip:  int64(head.PreSkip),
+		pcm:      make([]float32, opusMaxFrame*int(head.Channels)),
+		granule:  -1,
+	}
+	if last, err := lastGranule(file); err == nil && last > s.preSkip {
+		s.length = int(last - s.preSkip)
+	}
+
+	format := beep.Format{SampleRate: opusRate, NumChannels: int(head.Channels), Precision: 2}
+	return s, format, nil
+}
+
+// lastGranule returns the granule position of the last Ogg page of file,
+// which is where its audio ends
+func lastGranule(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	start := max(size-opusTailSize, 0)
+	tail := make([]byte, size-start)
+	if _, err := file.ReadAt(tail, start); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	for at := bytes.LastIndex(tail, []byte("OggS")); at >= 0; at = bytes.LastIndex(tail[:at], []byte("OggS")) {
+		if at+14 <= len(tail) {
+			if granule := int64(binary.LittleEndian.Uint64(tail[at+6:])); granule >= 0 {
+				return granule, nil
+			}
+		}
+	}
+	return 0, errors.New("no Ogg page found")
+}
+
+// Stream fills samples with the audio from the current position
+func (s *opusStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if len(s.pending) == 0 && !s.decodePacket() {
+			break
+		}
+		copied := copy(samples[n:], s.pending)
+		s.pending = s.pending[copied:]
+		s.pos += copied
+		n += copied
+	}
+	return n, n > 0
+}
+
+// decodePacket decodes the next packet into pending, dropping what lies
+// before the position, e.g. the encoder delay or the pre-roll of a seek.
+// It returns false at the end of the stream or on an error.
+func (s *opusStreamer) decodePacket() bool {
+	for len(s.pending) == 0 {
+		packet, end, err := s.reader.ReadPacket()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+		count, err := s.decoder.Decode(packet, s.pcm)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		// The last packet of the stream is cut short to where the
+		// granule position of its page says the audio ends
+		start := s.granule
+		if start < 0 {
+			start = int64(end) - int64(count)
+		}
+		keep := count
+		if int64(end) >= start && int64(end)-start < int64(count) {
+			keep = int(int64(end) - start)
+		}
+		s.granule = start + int64(keep)
+
+		out := s.buf[:0]
+		from := max(s.preSkip+int64(s.pos)-start, 0)
+		for i := int(from); i < keep; i++ {
+			left := float64(s.pcm[i*s.channels])
+			right := left
+			if s.channels == 2 {
+				right = float64(s.pcm[i*s.channels+1])
+			}
+			out = append(out, [2]float64{left, right})
+		}
+		s.buf, s.pending = out, out
+	}
+	return true
+}
+
+// Err returns the error that ended the stream early, if any
+func (s *opusStreamer) Err() error {
+	return s.err
+}
+
+// Len returns the length in samples, 0 when it couldn't be found
+func (s *opusStreamer) Len() int {
+	return s.length
+}
+
+// Position returns the current position in samples
+func (s *opusStreamer) Position() int {
+	return s.pos
+}
+
+// Seek moves to sample p, decoding from a little before it
+func (s *opusStreamer) Seek(p int) error {
+	if p < 0 || s.length > 0 && p > s.length {
+		return fmt.Errorf("opus: seek position %d out of range [0, %d]", p, s.length)
+	}
+	target := max(s.preSkip+int64(p)-opusPreRoll-opusMaxFrame, 0)
+	if err := s.reader.SeekGranule(uint64(target)); err != nil {
+		return err
+	}
+	s.decoder.Reset()
+	s.pending = nil
+	s.granule = -1
+	s.pos = p
+	s.err = nil
+	return nil
+}
+
+// Close closes the file
+func (s *opusStreamer) Close() error {
+	return s.file.Close()
+}
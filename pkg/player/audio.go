@@ -0,0 +1,731 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+	"github.com/punkscience/dirplay/pkg/scan"
+)
+
+// CompletionStreamer wraps a streamer to detect when it completes
+type CompletionStreamer struct {
+	beep.Streamer
+	completed bool
+}
+
+func (cs *CompletionStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = cs.Streamer.Stream(samples)
+	if !ok {
+		cs.completed = true
+	}
+	return n, ok
+}
+
+func (cs *CompletionStreamer) Err() error {
+	if s, ok := cs.Streamer.(interface{ Err() error }); ok {
+		return s.Err()
+	}
+	return nil
+}
+
+func (cs *CompletionStreamer) IsCompleted() bool {
+	return cs.completed
+}
+
+// AudioPlayer manages audio playback. The mutex guards the loaded track
+// against Stop/LoadTrack running in a command goroutine while the UI polls
+// the position from a tick.
+type AudioPlayer struct {
+	mu               sync.Mutex
+	out              *Output
+	codecs           *codec.Registry
+	path             string
+	streamer         beep.StreamSeekCloser
+	ctrl             *beep.Ctrl
+	format           beep.Format
+	playing          bool
+	file             io.Closer
+	duration         time.Duration
+	artist           string
+	title            string
+	album            string
+	albumArtist      string
+	sortArtist       string
+	picture          *tag.Picture
+	info             TrackInfo
+	hasEnded         bool
+	completionStream *CompletionStreamer
+	volume           *fader
+	gain             float64
+	fade             time.Duration
+	replayGain       string
+	silence          SilenceConfig
+	eq               *equalizer
+	meter            LevelMeter
+
+	// Copies the output to a Tee, e.g. for HTTP stream listeners, nil without
+	tee Tee
+
+	// Tracks whose decoder panicked while playing, see guardedStreamer
+	crashes chan Crash
+
+	// Titles and connection notices of streams, see StreamNews
+	news chan StreamNews
+
+	// Playback speed, applied by resampling. Swapping speeder for a
+	// pitch-preserving stretcher would keep voices natural.
+	speeder *beep.Resampler
+	speed   float64
+	rate    beep.SampleRate
+
+	outputAcquired bool
+
+	// Gapless playback: the next track is preloaded into the gapless
+	// streamer, and generation invalidates loads and preloads started
+	// before a stop
+	gapless    *gaplessStreamer
+	preloaded  *loadedTrack
+	advanced   string
+	generation int
+}
+
+// NewAudioPlayer creates a new audio player instance, playing through out
+// the tracks it decodes with codecs
+func NewAudioPlayer(out *Output, codecs *codec.Registry) *AudioPlayer {
+	return &AudioPlayer{out: out, codecs: codecs, eq: &equalizer{}, gain: 1, speed: 1, replayGain: ReplayGainOff, crashes: make(chan Crash, 4), news: make(chan StreamNews, 8)}
+}
+
+// loadedTrack is an opened and decoded track that isn't installed in the
+// player yet, either being loaded or preloaded for a gapless transition
+type loadedTrack struct {
+	path        string
+	file        io.Closer
+	streamer    beep.StreamSeekCloser
+	format      beep.Format
+	artist      string
+	title       string
+	album       string
+	albumArtist string
+	sortArtist  string
+	picture     *tag.Picture
+	info        TrackInfo
+}
+
+// Close releases the decoder and the file or connection
+func (lt *loadedTrack) Close() {
+	lt.streamer.Close()
+	lt.file.Close()
+}
+
+// openTrack opens, tags and decodes an audio file, or a stream for an
+// http(s) URL, see openStream. A panic of the tag reader or decoder on a
+// malformed file is returned as an error, and the decoder is guarded
+// against panics later, see guardedStreamer.
+func openTrack(codecs *codec.Registry, filePath string, crashes chan<- Crash, news chan<- StreamNews) (lt *loadedTrack, err error) {
+	if scan.IsStreamURL(filePath) {
+		return openStream(codecs, filePath, crashes, news)
+	}
+
+	// Open the audio file
+	file, err := os.Open(fspath.Long(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			file.Close()
+			lt, err = nil, decoderPanic(r)
+		}
+	}()
+
+	lt = &loadedTrack{path: filePath, file: file}
+
+	// The tags are read through a handle of their own while the decoder
+	// sets up, so neither waits for the other, and the decoder doesn't
+	// have to seek back over them, a round trip on a network share
+	tagged := make(chan fileTags, 1)
+	go func() { tagged <- readFileTags(filePath) }()
+
+	// Decode based on file extension
+	ext := strings.ToLower(filepath.Ext(filePath))
+	streamer, format, err := codecs.Decode(file, ext)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	ft := <-tagged
+	if ft.err != nil {
+		streamer.Close()
+		file.Close()
+		return nil, ft.err
+	}
+	if ft.tags != nil {
+		lt.readTags(ft.tags)
+	} else {
+		// Fallback to filename if no tags
+		lt.title = filepath.Base(filePath)
+		lt.artist = "Unknown Artist"
+		lt.album = "Unknown Album"
+	}
+	lt.info.Chapters = ft.chapters
+	lt.info.Lyrics = ft.lyrics
+	lt.streamer = &loopStreamer{StreamSeekCloser: &guardedStreamer{StreamSeekCloser: streamer, path: filePath, crashes: crashes}}
+	lt.format = format
+
+	// Describe the stream for the info panel
+	lt.info.Format = strings.ToUpper(strings.TrimPrefix(ext, "."))
+	lt.info.SampleRate = int(lt.format.SampleRate)
+	lt.info.Channels = lt.format.NumChannels
+	lt.info.BitDepth = lt.format.Precision * 8
+	if stat, err := file.Stat(); err == nil {
+		lt.info.Size = stat.Size()
+		if seconds := lt.format.SampleRate.D(lt.streamer.Len()).Seconds(); seconds > 0 {
+			lt.info.Bitrate = int(float64(lt.info.Size*8) / seconds / 1000)
+		}
+	}
+
+	return lt, nil
+}
+
+// readTags takes the metadata of a track from its tags
+func (lt *loadedTrack) readTags(tags tag.Metadata) {
+	lt.artist = tags.Artist()
+	lt.title = tags.Title()
+	lt.album = tags.Album()
+	lt.albumArtist = tags.AlbumArtist()
+	lt.sortArtist = library.SortArtistTag(tags)
+	lt.picture = tags.Picture()
+	lt.info.Genre = tags.Genre()
+	lt.info.Year = tags.Year()
+	lt.info.Disc, lt.info.DiscTotal = tags.Disc()
+	lt.info.Track, lt.info.TrackTotal = tags.Track()
+	lt.info.Composer = tags.Composer()
+	lt.info.TagDuration = library.TagLength(tags)
+	lt.info.ReplayGain = library.ReadReplayGain(tags.Raw())
+}
+
+// install makes lt the current track, closing the one it replaces. The
+// caller must hold ap.mu.
+func (ap *AudioPlayer) install(lt *loadedTrack) {
+	if ap.streamer != nil {
+		ap.streamer.Close()
+	}
+	if ap.file != nil {
+		ap.file.Close()
+	}
+
+	ap.path = lt.path
+	ap.file = lt.file
+	ap.streamer = lt.streamer
+	ap.format = lt.format
+	ap.artist = lt.artist
+	ap.title = lt.title
+	ap.album = lt.album
+	ap.albumArtist = lt.albumArtist
+	ap.sortArtist = lt.sortArtist
+	ap.picture = lt.picture
+	ap.info = lt.info
+
+	// Calculate duration
+	ap.duration = lt.format.SampleRate.D(lt.streamer.Len())
+}
+
+// ErrLoadSuperseded reports a load that a stop or a later load overtook
+// while the file opened. The track it opened is closed again.
+var ErrLoadSuperseded = errors.New("load superseded by a later one")
+
+// LoadTrack loads an audio file for playback. Opening and decoding happen
+// outside the lock so position polling isn't blocked by slow storage. Of
+// loads that overlap, the last one started wins, the others return
+// ErrLoadSuperseded.
+func (ap *AudioPlayer) LoadTrack(filePath string) error {
+	// The same track again, e.g. on repeat, is rewound rather than closed
+	// and reopened, which Windows can refuse while the handle is released
+	if ap.rewind(filePath) {
+		return nil
+	}
+
+	// Stop any current playback and reset state. Stopping moves the
+	// generation on, which tells this load from those started before and
+	// after it.
+	ap.mu.Lock()
+	ap.stop()
+	generation := ap.generation
+	ap.mu.Unlock()
+
+	lt, err := openTrack(ap.codecs, filePath, ap.crashes, ap.news)
+	if err != nil {
+		return err
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if generation != ap.generation {
+		lt.Close()
+		return ErrLoadSuperseded
+	}
+	ap.install(lt)
+
+	// Request the shared speaker the first time this player needs it
+	if !ap.outputAcquired {
+		if err := ap.out.Acquire(lt.format.SampleRate); err != nil {
+			return err
+		}
+		ap.outputAcquired = true
+	}
+
+	return nil
+}
+
+// rewind stops playback and seeks back to the start if filePath is the
+// loaded track, reporting whether it was
+func (ap *AudioPlayer) rewind(filePath string) bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+	if ap.streamer == nil || ap.path != filePath {
+		return false
+	}
+
+	ap.detach(nil)
+	ap.setLoop(0, 0)
+	if err := ap.streamer.Seek(0); err != nil {
+		ap.stop()
+		return false
+	}
+	return true
+}
+
+// Play starts or resumes playback
+func (ap *AudioPlayer) Play() error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.streamer == nil {
+		return fmt.Errorf("no track loaded")
+	}
+
+	if ap.playing {
+		return nil // Already playing
+	}
+
+	// Play through the gapless streamer so a preloaded next track can
+	// take over without a gap, and detect completion after it
+	ap.gapless = &gaplessStreamer{current: ap.levelled(ap.out.Resample(ap.trimmed(ap.streamer, ap.format.SampleRate), ap.format.SampleRate), ap.info)}
+
+	// The speed carries over to a preloaded track too. Positions are read
+	// from the decoder, so they stay in track time at any speed.
+	// Tracks reach the equalizer at the output rate once the speaker runs
+	ap.rate = ap.out.SampleRate()
+	if ap.rate == 0 {
+		ap.rate = ap.format.SampleRate
+	}
+	ap.speeder = beep.ResampleRatio(4, ap.speed, ap.equalized(ap.gapless, ap.rate))
+	ap.completionStream = &CompletionStreamer{
+		Streamer: ap.speeder,
+	}
+
+	// The gain carries over from track to track
+	ap.volume = newFader(ap.completionStream, ap.gain, ap.fade, ap.rate)
+
+	// Create control wrapper for pause/resume functionality. The meter
+	// sits inside it, so it freezes while paused.
+	ap.ctrl = &beep.Ctrl{
+		Streamer: &meterTap{Streamer: ap.volume, meter: &ap.meter},
+		Paused:   false,
+	}
+
+	// Start playback, through the tee if the output is streamed
+	if ap.tee != nil {
+		ap.out.Play(&teeTap{Streamer: ap.ctrl, tee: ap.tee})
+	} else {
+		ap.out.Play(ap.ctrl)
+	}
+	ap.playing = true
+
+	return nil
+}
+
+// Pause pauses playback. With a fade the sound ramps down first and the
+// stream pauses once it is quiet, but it counts as paused right away.
+func (ap *AudioPlayer) Pause() {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.ctrl != nil && ap.playing {
+		ap.out.Lock()
+		if ap.fade > 0 && !ap.ctrl.Paused {
+			ap.volume.target = 0
+			ap.volume.pause = ap.ctrl
+		} else {
+			ap.ctrl.Paused = true
+		}
+		ap.out.Unlock()
+	}
+}
+
+// Resume resumes playback, ramping the sound back up from wherever a
+// pause left it
+func (ap *AudioPlayer) Resume() {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.ctrl != nil && ap.playing {
+		ap.out.Lock()
+		ap.ctrl.Paused = false
+		ap.volume.pause = nil
+		ap.volume.target = 1
+		if ap.fade <= 0 {
+			ap.volume.level = 1
+		}
+		ap.out.Unlock()
+	}
+}
+
+// SetGain scales the output, 1 being full level and 0 silence. It lasts
+// across tracks until changed.
+func (ap *AudioPlayer) SetGain(gain float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.gain = min(max(gain, 0), 1)
+	if ap.volume != nil {
+		ap.out.Lock()
+		ap.applyGain()
+		ap.out.Unlock()
+	}
+}
+
+// applyGain sets the volume stage from ap.gain. The caller must hold ap.mu
+// and, once the stage is playing, the speaker lock.
+func (ap *AudioPlayer) applyGain() {
+	ap.volume.gain = ap.gain
+}
+
+// SetTee copies the output to tee from the next track on
+func (ap *AudioPlayer) SetTee(tee Tee) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.tee = tee
+}
+
+// Meter returns the level meter of the output
+func (ap *AudioPlayer) Meter() *LevelMeter {
+	return &ap.meter
+}
+
+// IsPaused returns true if playback is paused
+func (ap *AudioPlayer) IsPaused() bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.isPaused()
+}
+
+// isPaused reports the pause state, the caller must hold ap.mu
+func (ap *AudioPlayer) isPaused() bool {
+	if ap.ctrl == nil {
+		return false
+	}
+	ap.out.Lock()
+	paused := ap.ctrl.Paused || ap.volume.pause != nil
+	ap.out.Unlock()
+	return paused
+}
+
+// Stop stops playback
+func (ap *AudioPlayer) Stop() {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.stop()
+}
+
+// stop stops playback and releases the track, the caller must hold ap.mu.
+// A track fading out is closed once the fade has played, see fadeOut,
+// otherwise the file is closed by the time it returns.
+func (ap *AudioPlayer) stop() {
+	// What plays may be the preloaded track already
+	ap.adoptSwitched()
+
+	streamer, file := ap.streamer, ap.file
+	ap.streamer, ap.file, ap.path = nil, nil, ""
+	release := func() {
+		if streamer != nil {
+			streamer.Close()
+		}
+		if file != nil {
+			file.Close()
+		}
+	}
+	if !ap.detach(release) {
+		release()
+	}
+}
+
+// detach stops playback but keeps the track open, the caller must hold
+// ap.mu. Given release, a playing track is left to fade out, and detach
+// reports whether it is, in which case release is called once it has.
+// Without, the track stops abruptly, e.g. to be rewound.
+func (ap *AudioPlayer) detach(release func()) bool {
+	faded := false
+	if ap.playing {
+		// The preloaded track must not take over while the old one fades
+		if ap.gapless != nil {
+			ap.out.Lock()
+			ap.gapless.next = nil
+			ap.gapless.nextStream = nil
+			ap.out.Unlock()
+		}
+		if release != nil {
+			faded = ap.fadeOut(release)
+		}
+
+		// Detach our streamer so the speaker drops it without touching
+		// anything other players are streaming. The speaker only streams
+		// while holding its lock, so once this returns nothing reads from
+		// the old streamer and it is safe to close.
+		if !faded && ap.ctrl != nil {
+			ap.out.Lock()
+			ap.ctrl.Streamer = nil
+			ap.out.Unlock()
+		}
+
+		ap.playing = false
+		ap.hasEnded = false
+	}
+
+	// Anything preloaded in the background is for a track that won't follow
+	ap.generation++
+	if ap.preloaded != nil {
+		ap.preloaded.Close()
+		ap.preloaded = nil
+	}
+
+	// Clear references to prevent accumulation
+	ap.ctrl = nil
+	ap.completionStream = nil
+	ap.volume = nil
+	ap.speeder = nil
+	ap.gapless = nil
+	ap.advanced = ""
+	return faded
+}
+
+// Close closes the audio player and releases resources
+func (ap *AudioPlayer) Close() {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.stop()
+
+	// Hand the shared speaker back, other players keep using it
+	if ap.outputAcquired {
+		ap.out.Release()
+		ap.outputAcquired = false
+	}
+}
+
+// Shutdown completely shuts down the audio player
+func (ap *AudioPlayer) Shutdown() {
+	ap.Close()
+}
+
+// GetDuration returns the total duration of the current track
+func (ap *AudioPlayer) GetDuration() time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.duration
+}
+
+// GetPosition returns the current playback position, read from the
+// streamer's sample position so it stays in step with what is audible
+// across pauses, underruns and system suspend.
+func (ap *AudioPlayer) GetPosition() time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+
+	if ap.streamer == nil {
+		return 0
+	}
+	return ap.format.SampleRate.D(ap.samplePosition())
+}
+
+// samplePosition returns the streamer position in samples, the caller must
+// hold ap.mu
+func (ap *AudioPlayer) samplePosition() int {
+	if ap.streamer == nil {
+		return 0
+	}
+
+	ap.out.Lock()
+	defer ap.out.Unlock()
+	return ap.streamer.Position()
+}
+
+// Seek seeks to a specific position in the track
+func (ap *AudioPlayer) Seek(pos time.Duration) error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.streamer == nil {
+		return fmt.Errorf("no track loaded")
+	}
+
+	// Convert time to sample position
+	samples := ap.format.SampleRate.N(pos)
+
+	// Seek to position while the speaker isn't reading from the streamer
+	ap.out.Lock()
+	err := ap.streamer.Seek(samples)
+	ap.out.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to seek: %w", err)
+	}
+
+	return nil
+}
+
+// IsPlaying returns true if audio is currently playing
+func (ap *AudioPlayer) IsPlaying() bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.playing && !ap.isPaused()
+}
+
+// GetArtist returns the artist of the current track
+func (ap *AudioPlayer) GetArtist() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.artist
+}
+
+// GetTitle returns the title of the current track
+func (ap *AudioPlayer) GetTitle() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.title
+}
+
+// GetAlbum returns the album of the current track
+func (ap *AudioPlayer) GetAlbum() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.album
+}
+
+// GetAlbumArtist returns the album artist of the current track
+func (ap *AudioPlayer) GetAlbumArtist() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.albumArtist
+}
+
+// GetSortArtist returns the artist sort tag of the current track, if any
+func (ap *AudioPlayer) GetSortArtist() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.sortArtist
+}
+
+// GetPicture returns the embedded cover art of the current track, or nil
+func (ap *AudioPlayer) GetPicture() *tag.Picture {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.picture
+}
+
+// GetFormat returns the format the current track decodes to
+func (ap *AudioPlayer) GetFormat() beep.Format {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+	return ap.format
+}
+
+// OutputRate returns the rate the speaker runs at, zero before the first
+// track loaded
+func (ap *AudioPlayer) OutputRate() beep.SampleRate {
+	return ap.out.SampleRate()
+}
+
+// RestartOutput reopens the sound device after it failed
+func (ap *AudioPlayer) RestartOutput() error {
+	return ap.out.Restart()
+}
+
+// GetInfo returns the extended metadata of the current track
+func (ap *AudioPlayer) GetInfo() TrackInfo {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	return ap.info
+}
+
+// Err returns the error that cut the current track short, or nil
+func (ap *AudioPlayer) Err() error {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.completionStream == nil {
+		return nil
+	}
+	ap.out.Lock()
+	defer ap.out.Unlock()
+	return ap.completionStream.Err()
+}
+
+// HasEnded returns true if the current track has finished playing
+func (ap *AudioPlayer) HasEnded() bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+
+	if ap.streamer == nil || !ap.playing {
+		return false
+	}
+
+	// Only the stream running dry ends a track. Lengths reported by the
+	// decoder can be missing or wrong, e.g. for some VBR MP3s, so the
+	// position is never compared against them. A stream that stopped on
+	// an error hasn't ended, see Err.
+	if ap.completionStream != nil {
+		ap.out.Lock()
+		ended := ap.completionStream.IsCompleted() && ap.completionStream.Err() == nil
+		ap.out.Unlock()
+		if ended {
+			ap.hasEnded = true
+			return true
+		}
+	}
+
+	return ap.hasEnded
+}
@@ -0,0 +1,637 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// testDevice stands in for the sound card: a goroutine pulls from what it
+// plays as fast as it can, under its lock as the speaker does
+type testDevice struct {
+	mu    sync.Mutex
+	mixer beep.Mixer
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func (d *testDevice) init(rate beep.SampleRate, bufferSize int) error {
+	d.quit, d.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(d.done)
+		buf := make([][2]float64, 512)
+		for {
+			select {
+			case <-d.quit:
+				return
+			default:
+			}
+			d.mu.Lock()
+			d.mixer.Stream(buf)
+			d.mu.Unlock()
+			runtime.Gosched()
+		}
+	}()
+	return nil
+}
+
+func (d *testDevice) play(s beep.Streamer) {
+	d.mu.Lock()
+	d.mixer.Add(s)
+	d.mu.Unlock()
+}
+
+func (d *testDevice) lock()          { d.mu.Lock() }
+func (d *testDevice) unlock()        { d.mu.Unlock() }
+func (d *testDevice) clear()         { d.lock(); d.mixer.Clear(); d.unlock() }
+func (d *testDevice) suspend() error { return nil }
+func (d *testDevice) resume() error  { return nil }
+
+// close stops the pulling, if it started
+func (d *testDevice) close() {
+	if d.quit != nil {
+		close(d.quit)
+		<-d.done
+	}
+}
+
+// newTestOutput returns an Output playing through a testDevice at rate,
+// or the first track's rate when zero
+func newTestOutput(t testing.TB, rate beep.SampleRate) *Output {
+	dev := &testDevice{}
+	t.Cleanup(dev.close)
+	return &Output{dev: dev, fixedRate: rate}
+}
+
+// testCodec decodes .tst files, which hold a sample rate and a length in
+// samples, into a ramp of that length. A third number is the length the
+// streamer reports instead, as decoders that lie about it do. It keeps
+// every streamer it decodes and counts uses of those already closed.
+type testCodec struct {
+	mu     sync.Mutex
+	opened []*trackedStreamer
+	misuse atomic.Int32
+}
+
+func (c *testCodec) decode(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	var rate, n int
+	if _, err := fmt.Fscan(rc, &rate, &n); err != nil {
+		rc.Close()
+		return nil, beep.Format{}, err
+	}
+	s := &trackedStreamer{testStreamer: newTestStreamer(n), rc: rc, misuse: &c.misuse, reported: -1}
+	fmt.Fscan(rc, &s.reported)
+	c.mu.Lock()
+	c.opened = append(c.opened, s)
+	c.mu.Unlock()
+	return s, beep.Format{SampleRate: beep.SampleRate(rate), NumChannels: 2, Precision: 2}, nil
+}
+
+// unclosed counts the streamers decoded so far that weren't closed, and
+// those closed more than once
+func (c *testCodec) unclosed() (open, twice int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.opened {
+		switch s.closed.Load() {
+		case 0:
+			open++
+		case 1:
+		default:
+			twice++
+		}
+	}
+	return open, twice
+}
+
+// trackedStreamer is a testStreamer that counts its uses after Close.
+// It reports its length as reported unless that is negative.
+type trackedStreamer struct {
+	*testStreamer
+	rc       io.Closer
+	closed   atomic.Int32
+	misuse   *atomic.Int32
+	reported int
+}
+
+func (s *trackedStreamer) Len() int {
+	if s.reported >= 0 {
+		return s.reported
+	}
+	return s.testStreamer.Len()
+}
+
+func (s *trackedStreamer) check() {
+	if s.closed.Load() > 0 {
+		s.misuse.Add(1)
+	}
+}
+
+func (s *trackedStreamer) Stream(samples [][2]float64) (int, bool) {
+	s.check()
+	return s.testStreamer.Stream(samples)
+}
+
+func (s *trackedStreamer) Seek(p int) error {
+	s.check()
+	return s.testStreamer.Seek(p)
+}
+
+func (s *trackedStreamer) Position() int {
+	s.check()
+	return s.testStreamer.Position()
+}
+
+func (s *trackedStreamer) Close() error {
+	s.closed.Add(1)
+	return s.rc.Close()
+}
+
+// testTrack is a .tst file written by writeTestTracks
+type testTrack struct {
+	path string
+	rate beep.SampleRate
+	n    int
+}
+
+// writeTestTracks writes n .tst tracks at the usual sample rates in turn,
+// a few thousand samples long
+func writeTestTracks(t *testing.T, n int) []testTrack {
+	t.Helper()
+	rates := []beep.SampleRate{44100, 48000, 22050, 96000, 32000}
+	dir := t.TempDir()
+	tracks := make([]testTrack, n)
+	for i := range tracks {
+		tr := testTrack{
+			path: filepath.Join(dir, fmt.Sprintf("%03d.tst", i)),
+			rate: rates[i%len(rates)],
+			n:    1000 + 37*i,
+		}
+		if err := os.WriteFile(tr.path, fmt.Appendf(nil, "%d %d", tr.rate, tr.n), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		tracks[i] = tr
+	}
+	return tracks
+}
+
+// newTestPlayer returns a player of .tst tracks on a testDevice
+func newTestPlayer(t *testing.T) (*AudioPlayer, *testCodec) {
+	tc := &testCodec{}
+	codecs := codec.New()
+	codecs.Register(".tst", tc.decode)
+	return NewAudioPlayer(newTestOutput(t, 0), codecs), tc
+}
+
+// TestLoadStopStress loads, plays and stops 200 tracks at mixed sample
+// rates while the device streams and the UI polls, with preloads, seeks,
+// pauses, equalizer changes and overlapping loads along the way. Run it
+// with -race: every decoder must be closed exactly once, and never used
+// after.
+func TestLoadStopStress(t *testing.T) {
+	for _, fade := range []time.Duration{0, 5 * time.Millisecond} {
+		t.Run(fmt.Sprintf("fade %v", fade), func(t *testing.T) {
+			tracks := writeTestTracks(t, 200)
+			ap, tc := newTestPlayer(t)
+			ap.SetFade(fade)
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Go(func() {
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					ap.GetPosition()
+					ap.HasEnded()
+					ap.TookOverNext()
+					ap.Err()
+					ap.IsPlaying()
+				}
+			})
+
+			for i, tr := range tracks {
+				if err := ap.LoadTrack(tr.path); err != nil && !errors.Is(err, ErrLoadSuperseded) {
+					t.Fatalf("LoadTrack(%s): %v", filepath.Base(tr.path), err)
+				}
+				// An overlapping load may have just stopped this track
+				ap.Play()
+
+				switch {
+				case i%5 == 0:
+					ap.Preload(tracks[(i+1)%len(tracks)].path)
+				case i%7 == 0:
+					next := tracks[(i+3)%len(tracks)].path
+					wg.Go(func() { ap.LoadTrack(next) })
+				case i%3 == 0:
+					ap.Seek(tr.rate.D(tr.n / 2))
+					ap.Pause()
+					ap.Resume()
+				case i%4 == 1:
+					ap.SetEQ(EQGains{float64(i % 7), 0, -3})
+					ap.SetGain(float64(i%10) / 10)
+				}
+				if i%2 == 0 {
+					ap.Stop()
+				}
+			}
+			close(stop)
+			wg.Wait()
+			ap.Close()
+
+			// Tracks fading out are released once the fade has played
+			deadline := time.Now().Add(5 * time.Second)
+			open, twice := tc.unclosed()
+			for open > 0 && fade > 0 && time.Now().Before(deadline) {
+				runtime.Gosched()
+				open, twice = tc.unclosed()
+			}
+			if open > 0 || twice > 0 {
+				t.Errorf("of %d decoders %d were left open and %d closed twice", len(tc.opened), open, twice)
+			}
+			if n := tc.misuse.Load(); n > 0 {
+				t.Errorf("decoders were used %d times after they were closed", n)
+			}
+			select {
+			case crash := <-ap.Crashes():
+				t.Errorf("a decoder crashed: %v", crash)
+			default:
+			}
+		})
+	}
+}
+
+// TestPlayerProperties runs random sequences of operations on a player
+// and checks after each that what it reports holds together
+func TestPlayerProperties(t *testing.T) {
+	tracks := writeTestTracks(t, 10)
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			ap, tc := newTestPlayer(t)
+			var loaded *testTrack
+			var ops []string
+
+			for range 100 {
+				var op string
+				switch rng.Intn(6) {
+				case 0:
+					tr := &tracks[rng.Intn(len(tracks))]
+					op = "load " + filepath.Base(tr.path)
+					if err := ap.LoadTrack(tr.path); err != nil {
+						t.Fatalf("%v: %v", append(ops, op), err)
+					}
+					loaded = tr
+					if got := ap.GetFormat().SampleRate; got != tr.rate {
+						t.Fatalf("%v: rate %d, want the track's %d", append(ops, op), got, tr.rate)
+					}
+					if got, want := ap.GetDuration(), tr.rate.D(tr.n); got != want {
+						t.Fatalf("%v: duration %v, want %v", append(ops, op), got, want)
+					}
+					if pos := ap.GetPosition(); pos != 0 {
+						t.Fatalf("%v: at %v after loading, want the start", append(ops, op), pos)
+					}
+				case 1:
+					op = "play"
+					err := ap.Play()
+					if (err == nil) != (loaded != nil) {
+						t.Fatalf("%v: Play() = %v with %v loaded", append(ops, op), err, loaded)
+					}
+				case 2:
+					op = "pause"
+					ap.Pause()
+					if ap.IsPlaying() {
+						t.Fatalf("%v: still playing", append(ops, op))
+					}
+					before := ap.GetPosition()
+					runtime.Gosched()
+					if after := ap.GetPosition(); after != before {
+						t.Fatalf("%v: moved from %v to %v while paused", append(ops, op), before, after)
+					}
+				case 3:
+					op = "resume"
+					ap.Resume()
+				case 4:
+					if loaded == nil {
+						continue
+					}
+					at := loaded.rate.D(rng.Intn(loaded.n))
+					op = "seek " + at.String()
+					if err := ap.Seek(at); err != nil {
+						t.Fatalf("%v: %v", append(ops, op), err)
+					}
+				case 5:
+					op = "stop"
+					ap.Stop()
+					loaded = nil
+					if ap.IsPlaying() || ap.GetPosition() != 0 {
+						t.Fatalf("%v: playing = %v at %v after stopping", append(ops, op), ap.IsPlaying(), ap.GetPosition())
+					}
+				}
+				ops = append(ops, op)
+
+				if pos, d := ap.GetPosition(), ap.GetDuration(); loaded != nil && (pos < 0 || pos > d) {
+					t.Fatalf("%v: at %v of %v", ops, pos, d)
+				}
+			}
+
+			ap.Close()
+			if open, twice := tc.unclosed(); open > 0 || twice > 0 {
+				t.Errorf("%d decoders left open and %d closed twice", open, twice)
+			}
+			if n := tc.misuse.Load(); n > 0 {
+				t.Errorf("decoders were used %d times after they were closed", n)
+			}
+		})
+	}
+}
+
+// TestRepeatOne loads the same track hundreds of times in quick
+// succession, as repeat does: loads of the loaded track rewind it without
+// opening it again, and every decoder opened is closed once. Windows
+// refuses to remove a file while a handle to it is open, so the track is
+// removed at the end.
+func TestRepeatOne(t *testing.T) {
+	tests := []struct {
+		name   string
+		fade   time.Duration
+		stop   bool
+		wait   bool
+		opened int
+	}{
+		{"while playing", 0, false, false, 1},
+		{"while fading", 5 * time.Millisecond, false, false, 1},
+		{"once ended", 0, false, true, 1},
+		{"after stopping", 0, true, false, 300},
+		{"after stopping with a fade", 5 * time.Millisecond, true, false, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := writeTestTracks(t, 1)[0]
+			ap, tc := newTestPlayer(t)
+			ap.SetFade(tt.fade)
+
+			for i := range 300 {
+				if err := ap.LoadTrack(tr.path); err != nil {
+					t.Fatalf("load %d: %v", i+1, err)
+				}
+				if pos := ap.GetPosition(); pos != 0 {
+					t.Fatalf("load %d: at %v, want the start", i+1, pos)
+				}
+				if err := ap.Play(); err != nil {
+					t.Fatalf("play %d: %v", i+1, err)
+				}
+				if tt.wait {
+					for !ap.HasEnded() {
+						runtime.Gosched()
+					}
+				}
+				if tt.stop {
+					ap.Stop()
+				}
+			}
+			ap.Close()
+
+			deadline := time.Now().Add(5 * time.Second)
+			open, twice := tc.unclosed()
+			for open > 0 && tt.fade > 0 && time.Now().Before(deadline) {
+				runtime.Gosched()
+				open, twice = tc.unclosed()
+			}
+			if len(tc.opened) != tt.opened {
+				t.Errorf("the track was opened %d times, want %d", len(tc.opened), tt.opened)
+			}
+			if open > 0 || twice > 0 {
+				t.Errorf("%d decoders left open and %d closed twice", open, twice)
+			}
+			if n := tc.misuse.Load(); n > 0 {
+				t.Errorf("decoders were used %d times after they were closed", n)
+			}
+			if err := os.Remove(tr.path); err != nil {
+				t.Errorf("the track can't be removed: %v", err)
+			}
+		})
+	}
+}
+
+// TestTrackChangesEndGoroutines changes tracks every way the UI does,
+// with and without a fade: once the player is closed and the device
+// stopped, none of the goroutines started for the tracks is left
+func TestTrackChangesEndGoroutines(t *testing.T) {
+	for _, fade := range []time.Duration{0, 5 * time.Millisecond} {
+		t.Run(fmt.Sprintf("fade %v", fade), func(t *testing.T) {
+			tracks := writeTestTracks(t, 20)
+			baseline := runtime.NumGoroutine()
+
+			tc := &testCodec{}
+			codecs := codec.New()
+			codecs.Register(".tst", tc.decode)
+			dev := &testDevice{}
+			ap := NewAudioPlayer(&Output{dev: dev}, codecs)
+			ap.SetFade(fade)
+			for i, tr := range tracks {
+				if err := ap.LoadTrack(tr.path); err != nil {
+					t.Fatalf("LoadTrack(%s): %v", filepath.Base(tr.path), err)
+				}
+				if err := ap.Play(); err != nil {
+					t.Fatal(err)
+				}
+				switch i % 4 {
+				case 0:
+					ap.Preload(tracks[(i+1)%len(tracks)].path)
+				case 1:
+					for !ap.HasEnded() {
+						runtime.Gosched()
+					}
+				case 2:
+					ap.Pause()
+					ap.Resume()
+				case 3:
+					ap.Stop()
+				}
+			}
+			ap.Close()
+			dev.close()
+
+			// Fades and tag reads end on their own, soon after
+			deadline := time.Now().Add(5 * time.Second)
+			n := runtime.NumGoroutine()
+			for n > baseline && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				n = runtime.NumGoroutine()
+			}
+			if n > baseline {
+				t.Errorf("%d goroutines after %d tracks, want at most the %d before", n, len(tracks), baseline)
+			}
+		})
+	}
+}
+
+// TestWrongLength plays tracks whose decoder reports too short, too long
+// or no length: each ends only once its stream runs dry
+func TestWrongLength(t *testing.T) {
+	const n = 100000
+	tests := []struct {
+		name     string
+		reported int
+	}{
+		{"right", n},
+		{"too short", 1000},
+		{"too long", 10 * n},
+		{"unknown", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "track.tst")
+			if err := os.WriteFile(path, fmt.Appendf(nil, "44100 %d %d", n, tt.reported), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			ap, tc := newTestPlayer(t)
+			defer ap.Close()
+			if err := ap.LoadTrack(path); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := ap.GetDuration(), beep.SampleRate(44100).D(tt.reported); got != want {
+				t.Errorf("duration %v, want the reported %v", got, want)
+			}
+			if err := ap.Play(); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for !ap.HasEnded() {
+				if time.Now().After(deadline) {
+					t.Fatal("the track never ended")
+				}
+				runtime.Gosched()
+			}
+			ap.out.Lock()
+			played := tc.opened[0].pos
+			ap.out.Unlock()
+			if played != n {
+				t.Errorf("ended after %d of %d samples", played, n)
+			}
+		})
+	}
+}
+
+// TestResampleProperties plays ramps through Output.Resample between
+// random pairs of rates: what comes out lasts as long as what went in,
+// and follows the ramp at the device rate, so it plays at the right pitch
+func TestResampleProperties(t *testing.T) {
+	rates := []beep.SampleRate{8000, 11025, 22050, 32000, 44100, 48000, 88200, 96000}
+	rng := rand.New(rand.NewSource(1))
+	for range 100 {
+		from, to := rates[rng.Intn(len(rates))], rates[rng.Intn(len(rates))]
+		n := 1000 + rng.Intn(9000)
+		out := &Output{initialized: true, sampleRate: to}
+
+		got := streamAll(out.Resample(newTestStreamer(n), from), 512, math.MaxInt)
+		ratio := float64(to) / float64(from)
+		if want := float64(n) * ratio; math.Abs(float64(len(got))-want) > ratio+1 {
+			t.Errorf("%d samples at %d Hz came out as %d at %d Hz, want %.0f", n, from, len(got), to, want)
+			continue
+		}
+
+		// The ramp is followed exactly away from its ends, where the
+		// interpolation runs short of samples
+		margin := int(8*max(ratio, 1)) + 1
+		for k := margin; k < len(got)-margin; k++ {
+			if want := 1 + float64(k)/ratio; math.Abs(got[k]-want) > 1e-6 {
+				t.Errorf("%d Hz to %d Hz: sample %d is %v, want %v", from, to, k, got[k], want)
+				break
+			}
+		}
+	}
+}
+
+// slowReader delays every read, as storage on a network share does. It
+// waits busily: how soon a sleeping goroutine wakes again depends on what
+// else the scheduler has to do, which would swamp the delay.
+type slowReader struct {
+	io.ReadSeekCloser
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	for start := time.Now(); time.Since(start) < r.delay; {
+	}
+	return r.ReadSeekCloser.Read(p)
+}
+
+// slowCodecs returns codecs whose decoders read files through a
+// slowReader
+func slowCodecs(delay time.Duration) *codec.Registry {
+	base, codecs := codec.New(), codec.New()
+	for _, ext := range base.Extensions() {
+		codecs.Register(ext, func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return base.Decode(&slowReader{ReadSeekCloser: rc.(io.ReadSeekCloser), delay: delay}, ext)
+		})
+	}
+	return codecs
+}
+
+// loadSequential is the load path openTrack replaced, for comparison: the
+// tags are read, then the decoder sets up
+func loadSequential(codecs *codec.Registry, path string) error {
+	ft := readFileTags(path)
+	if ft.err != nil {
+		return ft.err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	streamer, _, err := codecs.Decode(file, filepath.Ext(path))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	streamer.Close()
+	file.Close()
+	return nil
+}
+
+// BenchmarkLoadTrack loads the FLAC files of dummy_music in turn, with
+// the decoder reading them from fast and slow storage. The sequential
+// runs read the tags before setting up the decoder, as loads used to.
+func BenchmarkLoadTrack(b *testing.B) {
+	paths, _ := filepath.Glob(filepath.Join("..", "..", "dummy_music", "*.flac"))
+	if len(paths) < 2 {
+		b.Skip("dummy_music holds no FLAC files")
+	}
+	for _, delay := range []time.Duration{0, 100 * time.Microsecond} {
+		codecs := slowCodecs(delay)
+		b.Run(fmt.Sprintf("delay=%v", delay), func(b *testing.B) {
+			ap := NewAudioPlayer(newTestOutput(b, 0), codecs)
+			defer ap.Close()
+			for i := 0; b.Loop(); i++ {
+				if err := ap.LoadTrack(paths[i%len(paths)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("sequential/delay=%v", delay), func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				if err := loadSequential(codecs, paths[i%len(paths)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
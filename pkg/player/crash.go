@@ -0,0 +1,115 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gopxl/beep"
+)
+
+// ErrDecoderPanic marks the errors of files whose decoder panicked. dirplay
+// skips such a file for the rest of the session without opening it again.
+var ErrDecoderPanic = errors.New("decoder crashed")
+
+// decoderPanic turns a value recovered from a decoder into an error
+func decoderPanic(r any) error {
+	return fmt.Errorf("%w: %v", ErrDecoderPanic, r)
+}
+
+// Crash reports a track whose decoder panicked while it played
+type Crash struct {
+	Path string
+	Err  error
+}
+
+// guardedStreamer keeps a decoder that panics on a malformed file from
+// taking the player down. A panic while playing is sent to crashes and
+// the rest of the track is silence until it is stopped; with no
+// crashes channel the stream ends with the panic as its error instead.
+// Seeking a crashed decoder does nothing.
+type guardedStreamer struct {
+	beep.StreamSeekCloser
+	path     string
+	crashes  chan<- Crash
+	err      error
+	reported bool
+}
+
+// Stream streams from the decoder unless it has crashed
+func (g *guardedStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if g.err != nil {
+		if g.crashes == nil {
+			return 0, false
+		}
+		g.report()
+		clear(samples)
+		return len(samples), true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			g.crashed(r)
+			n, ok = g.Stream(samples)
+		}
+	}()
+	return g.StreamSeekCloser.Stream(samples)
+}
+
+// Seek seeks the decoder unless it has crashed
+func (g *guardedStreamer) Seek(p int) (err error) {
+	if g.err != nil {
+		return g.err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			g.crashed(r)
+			err = g.err
+		}
+	}()
+	return g.StreamSeekCloser.Seek(p)
+}
+
+// Close closes the decoder, which may be left in any state by a panic
+func (g *guardedStreamer) Close() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = decoderPanic(r)
+		}
+	}()
+	return g.StreamSeekCloser.Close()
+}
+
+// Err returns the decoder's error. A panic is only returned when there
+// is no crashes channel to report it to.
+func (g *guardedStreamer) Err() error {
+	if g.err != nil && g.crashes == nil {
+		return g.err
+	}
+	return g.StreamSeekCloser.Err()
+}
+
+// crashed records a panic and reports it
+func (g *guardedStreamer) crashed(r any) {
+	g.err = decoderPanic(r)
+	g.report()
+}
+
+// report sends the crash on without blocking the speaker. While
+// the channel is full it is tried again on every Stream call.
+func (g *guardedStreamer) report() {
+	if g.crashes == nil || g.reported {
+		return
+	}
+	select {
+	case g.crashes <- Crash{Path: g.path, Err: g.err}:
+		g.reported = true
+	default:
+	}
+}
+
+// Crashes returns the channel tracks whose decoder panicked while playing
+// are reported on
+func (ap *AudioPlayer) Crashes() <-chan Crash {
+	return ap.crashes
+}
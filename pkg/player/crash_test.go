@@ -0,0 +1,224 @@
+package player
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// panickyStreamer is a testStreamer whose decoder panics in Stream once
+// it would read past at, and in Seek and Close when set to
+type panickyStreamer struct {
+	*testStreamer
+	at          int
+	seek, close bool
+}
+
+func (s *panickyStreamer) Stream(samples [][2]float64) (int, bool) {
+	if s.at >= 0 && s.pos+len(samples) > s.at {
+		panic("index out of range in frame header")
+	}
+	return s.testStreamer.Stream(samples)
+}
+
+func (s *panickyStreamer) Seek(p int) error {
+	if s.seek {
+		panic("seek table corrupt")
+	}
+	return s.testStreamer.Seek(p)
+}
+
+func (s *panickyStreamer) Close() error {
+	if s.close {
+		panic("close of a broken decoder")
+	}
+	return s.testStreamer.Close()
+}
+
+// TestGuardedStream streams a decoder that panics after 100 samples
+func TestGuardedStream(t *testing.T) {
+	tests := []struct {
+		name string
+		// room is the crashes channel's free space, -1 for no channel
+		room     int
+		played   int
+		reported bool
+		err      bool
+	}{
+		{"reported", 1, 300, true, false},
+		{"channel full", 0, 300, false, false},
+		{"no channel", -1, 100, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var crashes chan Crash
+			if tt.room >= 0 {
+				crashes = make(chan Crash, 1)
+				if tt.room == 0 {
+					crashes <- Crash{Path: "other.flac"}
+				}
+			}
+			g := &guardedStreamer{
+				StreamSeekCloser: &panickyStreamer{testStreamer: newTestStreamer(1000), at: 100},
+				path:             "bad.flac",
+				crashes:          crashes,
+			}
+
+			played := streamAll(g, 50, 300)
+			if len(played) != tt.played {
+				t.Fatalf("played %d samples, want %d", len(played), tt.played)
+			}
+			if !slices.Equal(played[:100], streamAll(newTestStreamer(100), 50, 100)) {
+				t.Error("the samples before the panic weren't played")
+			}
+			if slices.ContainsFunc(played[100:], func(x float64) bool { return x != 0 }) {
+				t.Error("the rest of the track isn't silence")
+			}
+			if err := g.Err(); errors.Is(err, ErrDecoderPanic) != tt.err {
+				t.Errorf("Err() = %v, want the panic %v", err, tt.err)
+			}
+
+			select {
+			case crash := <-crashes:
+				if tt.reported != (crash.Path == "bad.flac") {
+					t.Errorf("reported %+v, want the crash reported %v", crash, tt.reported)
+				}
+			default:
+				if tt.reported {
+					t.Error("the crash wasn't reported")
+				}
+			}
+			if tt.room == 0 {
+				// Once there is room the crash is reported on the next
+				// Stream call
+				streamAll(g, 50, 50)
+				if crash := <-crashes; crash.Path != "bad.flac" || !errors.Is(crash.Err, ErrDecoderPanic) {
+					t.Errorf("reported %+v, want the crash", crash)
+				}
+			}
+		})
+	}
+}
+
+// TestGuardedSeekClose makes the decoder panic in Seek and Close
+func TestGuardedSeekClose(t *testing.T) {
+	tests := []struct {
+		name        string
+		seek, close bool
+		seekErr     bool
+		closeErr    bool
+		played      bool
+	}{
+		{"no panic", false, false, false, false, true},
+		{"seek", true, false, true, false, false},
+		{"close", false, true, false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crashes := make(chan Crash, 1)
+			g := &guardedStreamer{
+				StreamSeekCloser: &panickyStreamer{testStreamer: newTestStreamer(1000), at: -1, seek: tt.seek, close: tt.close},
+				path:             "bad.flac",
+				crashes:          crashes,
+			}
+			if err := g.Seek(500); errors.Is(err, ErrDecoderPanic) != tt.seekErr {
+				t.Errorf("Seek() = %v, want the panic %v", err, tt.seekErr)
+			}
+			// A decoder that crashed seeking plays silence from then on
+			played := streamAll(g, 50, 100)
+			if got := played[0] != 0; got != tt.played {
+				t.Errorf("played %v after the seek, want the track %v", played[0], tt.played)
+			}
+			if err := g.Close(); errors.Is(err, ErrDecoderPanic) != tt.closeErr {
+				t.Errorf("Close() = %v, want the panic %v", err, tt.closeErr)
+			}
+			if got := len(crashes) > 0; got != tt.seekErr {
+				t.Errorf("crash reported %v, want %v", got, tt.seekErr)
+			}
+		})
+	}
+}
+
+// TestDecoderPanics loads files whose decoder panics as it opens and
+// while it plays. The player carries on with the next track either way.
+func TestDecoderPanics(t *testing.T) {
+	ap, tc := newTestPlayer(t)
+	ap.codecs.Register(".bad", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		panic("malformed header")
+	})
+	ap.codecs.Register(".crash", func(rc io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		rc.Close()
+		s := &panickyStreamer{testStreamer: newTestStreamer(10000), at: 1000}
+		return s, beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}, nil
+	})
+	defer ap.Close()
+
+	dir := t.TempDir()
+	bad, crash := filepath.Join(dir, "bad.bad"), filepath.Join(dir, "crash.crash")
+	for _, path := range []string{bad, crash} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := writeTestTracks(t, 1)[0]
+	ended := func() bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for !ap.HasEnded() {
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return true
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"panics opening", bad},
+		{"panics playing", crash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ap.LoadTrack(tt.path)
+			if tt.path == bad {
+				if !errors.Is(err, ErrDecoderPanic) {
+					t.Fatalf("LoadTrack() = %v, want %v", err, ErrDecoderPanic)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				ap.Play()
+				select {
+				case crash := <-ap.Crashes():
+					if crash.Path != tt.path || !errors.Is(crash.Err, ErrDecoderPanic) {
+						t.Errorf("reported %+v, want the crash of %s", crash, tt.path)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("the crash wasn't reported")
+				}
+				ap.Stop()
+			}
+
+			if err := ap.LoadTrack(next.path); err != nil {
+				t.Fatal(err)
+			}
+			ap.Play()
+			if !ended() {
+				t.Error("the next track didn't play")
+			}
+			ap.Stop()
+		})
+	}
+	if open, twice := tc.unclosed(); open > 0 || twice > 0 {
+		t.Errorf("%d decoders left open and %d closed twice", open, twice)
+	}
+}
@@ -0,0 +1,81 @@
+package player
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// OutputDevice is a sound device the system can play to
+type OutputDevice struct {
+	// Name is what SetDevice takes, e.g. a PulseAudio sink or an ALSA card
+	Name        string
+	Description string
+
+	// env is the variable that points the default device at this one
+	env string
+}
+
+// ErrNoDeviceChoice reports a system where the Output only plays to the
+// default device
+var ErrNoDeviceChoice = errors.New("choosing the output device isn't supported on this system, change the default device instead")
+
+// SetDevice makes the Output play to the device called name, one of
+// ListDevices, instead of the system's default. beep's speaker can't be
+// opened a second time, so it must be called before the first Acquire.
+func (o *Output) SetDevice(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.initialized {
+		return errors.New("the output device is open already")
+	}
+	devices, err := ListDevices()
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		if d.Name == name {
+			return useDevice(d)
+		}
+	}
+	return fmt.Errorf("no output device %q (dirplay devices lists them)", name)
+}
+
+// parseSinks reads the sinks of `pactl list sinks`, run in the C locale
+func parseSinks(r io.Reader) ([]OutputDevice, error) {
+	var sinks []OutputDevice
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Sink #"):
+			sinks = append(sinks, OutputDevice{env: "PULSE_SINK"})
+		case len(sinks) == 0:
+		case strings.HasPrefix(line, "Name: "):
+			sinks[len(sinks)-1].Name = strings.TrimPrefix(line, "Name: ")
+		case strings.HasPrefix(line, "Description: "):
+			sinks[len(sinks)-1].Description = strings.TrimPrefix(line, "Description: ")
+		}
+	}
+	return sinks, scanner.Err()
+}
+
+// cardLine matches the first line of a card in /proc/asound/cards, e.g.
+// " 1 [Device         ]: USB-Audio - USB Audio Device"
+var cardLine = regexp.MustCompile(`^\s*\d+\s+\[(\S+)\s*\]:\s*(?:\S+\s+-\s+)?(.*)$`)
+
+// parseCards reads the cards of /proc/asound/cards
+func parseCards(r io.Reader) ([]OutputDevice, error) {
+	var cards []OutputDevice
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if match := cardLine.FindStringSubmatch(scanner.Text()); match != nil {
+			cards = append(cards, OutputDevice{Name: match[1], Description: strings.TrimSpace(match[2]), env: "ALSA_CARD"})
+		}
+	}
+	return cards, scanner.Err()
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package player
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// ListDevices returns the devices the default device can be pointed at.
+// oto plays to ALSA's default device, which goes through PulseAudio or
+// PipeWire where they run, so their sinks are listed, by pactl. Without a
+// sound server the ALSA cards are listed instead.
+func ListDevices() ([]OutputDevice, error) {
+	cmd := exec.Command("pactl", "list", "sinks")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	if out, err := cmd.Output(); err == nil {
+		sinks, err := parseSinks(bytes.NewReader(out))
+		if err == nil && len(sinks) > 0 {
+			return sinks, nil
+		}
+	}
+
+	f, err := os.Open("/proc/asound/cards")
+	if err != nil {
+		return nil, errors.New("found neither a PulseAudio or PipeWire server nor ALSA sound cards")
+	}
+	defer f.Close()
+	return parseCards(f)
+}
+
+// useDevice points the default device at d for when the speaker opens it:
+// the pulse plugin honors PULSE_SINK, and ALSA's own default ALSA_CARD
+func useDevice(d OutputDevice) error {
+	return os.Setenv(d.env, d.Name)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package player
+
+// ListDevices fails where oto has no way to choose the device, e.g. on
+// macOS and Windows, which play to the system's default device
+func ListDevices() ([]OutputDevice, error) {
+	return nil, ErrNoDeviceChoice
+}
+
+func useDevice(d OutputDevice) error {
+	return ErrNoDeviceChoice
+}
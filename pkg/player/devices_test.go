@@ -0,0 +1,73 @@
+package player
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []OutputDevice
+	}{
+		{"none", "", nil},
+		{
+			"two sinks",
+			"Sink #54\n\tState: SUSPENDED\n\tName: alsa_output.pci-0000_00_1f.3.analog-stereo\n\tDescription: Built-in Audio Analog Stereo\n\tDriver: PipeWire\n" +
+				"\tProperties:\n\t\tdevice.description = \"Built-in Audio\"\n\n" +
+				"Sink #61\n\tState: RUNNING\n\tName: alsa_output.usb-FiiO_K3-00.analog-stereo\n\tDescription: FiiO K3 Analog Stereo\n",
+			[]OutputDevice{
+				{Name: "alsa_output.pci-0000_00_1f.3.analog-stereo", Description: "Built-in Audio Analog Stereo", env: "PULSE_SINK"},
+				{Name: "alsa_output.usb-FiiO_K3-00.analog-stereo", Description: "FiiO K3 Analog Stereo", env: "PULSE_SINK"},
+			},
+		},
+		{"name before any sink", "Name: stray\nSink #1\n\tName: null\n", []OutputDevice{{Name: "null", env: "PULSE_SINK"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSinks(strings.NewReader(tt.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSinks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCards(t *testing.T) {
+	cards := " 0 [PCH            ]: HDA-Intel - HDA Intel PCH\n" +
+		"                      HDA Intel PCH at 0xf7f10000 irq 32\n" +
+		" 1 [Device         ]: USB-Audio - USB Audio Device\n" +
+		"                      C-Media USB Audio Device at usb-0000:00:14.0-2, full speed\n" +
+		"10 [Loopback       ]: Loopback\n"
+	want := []OutputDevice{
+		{Name: "PCH", Description: "HDA Intel PCH", env: "ALSA_CARD"},
+		{Name: "Device", Description: "USB Audio Device", env: "ALSA_CARD"},
+		{Name: "Loopback", Description: "Loopback", env: "ALSA_CARD"},
+	}
+	got, err := parseCards(strings.NewReader(cards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCards() = %+v, want %+v", got, want)
+	}
+
+	if got, _ := parseCards(strings.NewReader("--- no soundcards ---\n")); got != nil {
+		t.Errorf("parseCards() = %+v without cards, want none", got)
+	}
+}
+
+func TestSetDeviceOnceOpen(t *testing.T) {
+	out := newTestOutput(t, 0)
+	if err := out.Acquire(44100); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.SetDevice("anything"); err == nil {
+		t.Error("SetDevice() succeeded with the device open")
+	}
+}
@@ -0,0 +1,164 @@
+package player
+
+import (
+	"math"
+
+	"github.com/gopxl/beep"
+)
+
+// The equalizer has a low shelf, a mid peak and a high shelf
+const EQBands = 3
+
+// EQGains are the gains of the bands in dB, low to high
+type EQGains [EQBands]float64
+
+// EQBand is a band's filter: its kind, corner or centre frequency and Q
+type EQBand struct {
+	Name string
+	Kind int
+	Freq float64
+	Q    float64
+}
+
+// Kinds of EQBand
+const (
+	eqLowShelf = iota
+	eqPeak
+	eqHighShelf
+)
+
+// EQBandSpecs are the filters the gains apply to. Shelves use a slope of 1.
+var EQBandSpecs = [EQBands]EQBand{
+	{Name: "Bass", Kind: eqLowShelf, Freq: 120},
+	{Name: "Mid", Kind: eqPeak, Freq: 1000, Q: 0.9},
+	{Name: "Treble", Kind: eqHighShelf, Freq: 8000},
+}
+
+// biquad holds the normalized coefficients of a second order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// biquadState is a filter's memory of one channel
+type biquadState struct {
+	x1, x2, y1, y2 float64
+}
+
+// newBiquad computes a band's filter for a gain and sample rate, after
+// the Audio EQ Cookbook
+func newBiquad(band EQBand, gain float64, rate beep.SampleRate) biquad {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * band.Freq / float64(rate)
+	cos, sin := math.Cos(w0), math.Sin(w0)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch band.Kind {
+	case eqPeak:
+		alpha := sin / (2 * band.Q)
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case eqLowShelf:
+		k := 2 * math.Sqrt(a) * sin / math.Sqrt2
+		b0 = a * ((a + 1) - (a-1)*cos + k)
+		b1 = 2 * a * ((a - 1) - (a+1)*cos)
+		b2 = a * ((a + 1) - (a-1)*cos - k)
+		a0 = (a + 1) + (a-1)*cos + k
+		a1 = -2 * ((a - 1) + (a+1)*cos)
+		a2 = (a + 1) + (a-1)*cos - k
+	case eqHighShelf:
+		k := 2 * math.Sqrt(a) * sin / math.Sqrt2
+		b0 = a * ((a + 1) + (a-1)*cos + k)
+		b1 = -2 * a * ((a - 1) + (a+1)*cos)
+		b2 = a * ((a + 1) + (a-1)*cos - k)
+		a0 = (a + 1) - (a-1)*cos + k
+		a1 = 2 * ((a - 1) - (a+1)*cos)
+		a2 = (a + 1) - (a-1)*cos - k
+	}
+	return biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// process filters one sample
+func (f *biquad) process(s *biquadState, x float64) float64 {
+	y := f.b0*x + f.b1*s.x1 + f.b2*s.x2 - f.a1*s.y1 - f.a2*s.y2
+	s.x2, s.x1 = s.x1, x
+	s.y2, s.y1 = s.y1, y
+	return y
+}
+
+// equalizer filters the stream between the decoders and the output. Each
+// Play puts a new one in place, since the one before may still be fading
+// out a track, and it lives on across gapless transitions, so its filters
+// run on from track to track. Bands at 0 dB are skipped, and with all of
+// them there it passes the stream through untouched.
+type equalizer struct {
+	beep.Streamer
+	gains   EQGains
+	rate    beep.SampleRate
+	filters [EQBands]biquad
+	active  [EQBands]bool
+	state   [EQBands][2]biquadState
+}
+
+// Stream filters samples in place
+func (e *equalizer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = e.Streamer.Stream(samples)
+	for band := range e.filters {
+		if !e.active[band] {
+			continue
+		}
+		f, state := &e.filters[band], &e.state[band]
+		for i := range samples[:n] {
+			samples[i][0] = f.process(&state[0], samples[i][0])
+			samples[i][1] = f.process(&state[1], samples[i][1])
+		}
+	}
+	return n, ok
+}
+
+// eqFilters computes the filters for gains at rate, and which of them
+// change the sound at all
+func eqFilters(gains EQGains, rate beep.SampleRate) (filters [EQBands]biquad, active [EQBands]bool) {
+	for band, gain := range gains {
+		active[band] = gain != 0 && rate > 0
+		if active[band] {
+			filters[band] = newBiquad(EQBandSpecs[band], gain, rate)
+		}
+	}
+	return filters, active
+}
+
+// SetEQ sets the equalizer gains, live on the playing stream. The filters
+// are computed first and swapped in under the speaker lock; their memory
+// is kept, so the change doesn't click.
+func (ap *AudioPlayer) SetEQ(gains EQGains) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	filters, active := eqFilters(gains, ap.eq.rate)
+
+	// The equalizer may still be fading out a track after a stop
+	ap.out.Lock()
+	defer ap.out.Unlock()
+
+	// A band coming back from 0 dB starts from silence, not from where
+	// it stopped
+	for band := range active {
+		if active[band] && !ap.eq.active[band] {
+			ap.eq.state[band] = [2]biquadState{}
+		}
+	}
+	ap.eq.gains, ap.eq.filters, ap.eq.active = gains, filters, active
+}
+
+// equalized puts a new equalizer in front of s, which streams at rate,
+// with the gains of the last one. The filters are recomputed when the rate
+// differs from the last track's. The caller must hold ap.mu.
+func (ap *AudioPlayer) equalized(s beep.Streamer, rate beep.SampleRate) beep.Streamer {
+	eq := &equalizer{Streamer: s, gains: ap.eq.gains, rate: ap.eq.rate, filters: ap.eq.filters, active: ap.eq.active}
+	if rate != eq.rate {
+		eq.rate = rate
+		eq.filters, eq.active = eqFilters(eq.gains, rate)
+	}
+	ap.eq = eq
+	return eq
+}
@@ -0,0 +1,59 @@
+package player
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gopxl/beep"
+)
+
+// TestEqualizerFlat passes the stream through untouched at 0 dB
+func TestEqualizerFlat(t *testing.T) {
+	e := &equalizer{Streamer: newTestStreamer(100), rate: 44100}
+	e.filters, e.active = eqFilters(EQGains{}, e.rate)
+	buf := make([][2]float64, 100)
+	e.Stream(buf)
+	for i, sample := range buf {
+		if sample[0] != float64(i+1) {
+			t.Fatalf("sample %d = %v, want %d", i, sample[0], i+1)
+		}
+	}
+}
+
+// TestEqualizerGain measures the gain of each band on a tone at its
+// frequency and one far from it, once the filters have settled
+func TestEqualizerGain(t *testing.T) {
+	const rate = 44100
+	tests := []struct {
+		gains     EQGains
+		freq      float64
+		wantDB    float64
+		tolerance float64
+	}{
+		{EQGains{6, 0, 0}, 30, 6, 0.5},
+		{EQGains{6, 0, 0}, 15000, 0, 0.5},
+		{EQGains{0, -6, 0}, 1000, -6, 0.5},
+		{EQGains{0, 0, 9}, 18000, 9, 0.5},
+		{EQGains{0, 0, 9}, 60, 0, 0.5},
+	}
+	for _, tt := range tests {
+		tone := &testStreamer{samples: make([][2]float64, rate)}
+		for i := range tone.samples {
+			v := 0.25 * math.Sin(2*math.Pi*tt.freq*float64(i)/rate)
+			tone.samples[i] = [2]float64{v, v}
+		}
+		e := &equalizer{Streamer: tone, rate: beep.SampleRate(rate)}
+		e.filters, e.active = eqFilters(tt.gains, e.rate)
+
+		buf := make([][2]float64, rate)
+		e.Stream(buf)
+		in, out := 0.0, 0.0
+		for i := rate / 2; i < rate; i++ {
+			in += tone.samples[i][0] * tone.samples[i][0]
+			out += buf[i][0] * buf[i][0]
+		}
+		if db := 10 * math.Log10(out/in); math.Abs(db-tt.wantDB) > tt.tolerance {
+			t.Errorf("gains %v at %v Hz: %.2f dB, want %v", tt.gains, tt.freq, db, tt.wantDB)
+		}
+	}
+}
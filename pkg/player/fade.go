@@ -0,0 +1,146 @@
+package player
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// fadeGrace is how much longer than the fade a stopped track is given to
+// play out before it is closed anyway, e.g. when the output is suspended
+// and never plays it
+const fadeGrace = 200 * time.Millisecond
+
+// fader is the volume stage at the end of the player's chain. It scales
+// the stream by the gain and by a level that ramps between 0 and 1,
+// sample by sample, when playback pauses, resumes or stops. Its fields
+// are guarded by the speaker lock once it plays.
+type fader struct {
+	beep.Streamer
+	gain   float64
+	level  float64
+	target float64
+	step   float64 // change of level per sample, 1 for no ramp
+
+	// pause is paused once the level reaches 0 when fading out to pause.
+	// When fading out to stop, stopped is called instead, in a goroutine
+	// of its own, and the stream ends.
+	pause   *beep.Ctrl
+	stopped func()
+	ended   bool
+}
+
+// newFader puts a fader in front of s at full level
+func newFader(s beep.Streamer, gain float64, fade time.Duration, rate beep.SampleRate) *fader {
+	f := &fader{Streamer: s, gain: gain, level: 1, target: 1}
+	f.setFade(fade, rate)
+	return f
+}
+
+// setFade sets how long a ramp from silence to full level takes
+func (f *fader) setFade(fade time.Duration, rate beep.SampleRate) {
+	f.step = 1
+	if n := rate.N(fade); n > 1 {
+		f.step = 1 / float64(n)
+	}
+}
+
+// Stream scales samples in place. While fading out it reads only as far
+// as the ramp goes, so a pause resumes exactly where the sound stopped;
+// once quiet it reads nothing.
+func (f *fader) Stream(samples [][2]float64) (n int, ok bool) {
+	if f.ended {
+		return 0, false
+	}
+	if f.level == 0 && f.target == 0 {
+		f.quiet()
+		clear(samples)
+		return len(samples), true
+	}
+
+	want := len(samples)
+	if f.target == 0 {
+		want = min(want, int(f.level/f.step+0.5)+1)
+	}
+	n, ok = f.Streamer.Stream(samples[:want])
+	for i := range samples[:n] {
+		switch {
+		case f.level < f.target:
+			f.level = min(f.level+f.step, f.target)
+		case f.level > f.target:
+			f.level = max(f.level-f.step, f.target)
+		}
+		gain := f.gain * f.level
+		samples[i][0] *= gain
+		samples[i][1] *= gain
+	}
+	if !ok || n < want || want == len(samples) {
+		return n, ok
+	}
+
+	// The ramp ended before the buffer did
+	if f.level == 0 {
+		f.quiet()
+	}
+	clear(samples[n:])
+	return len(samples), true
+}
+
+// quiet pauses or ends the stream, whichever the fade out was for
+func (f *fader) quiet() {
+	if f.pause != nil {
+		f.pause.Paused = true
+		f.pause = nil
+	}
+	if f.stopped != nil {
+		f.ended = true
+		go f.stopped()
+		f.stopped = nil
+	}
+}
+
+// SetFade sets how long pausing, resuming and skipping ramp the sound,
+// 0 switching abruptly
+func (ap *AudioPlayer) SetFade(fade time.Duration) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.fade = max(fade, 0)
+	if ap.volume != nil {
+		ap.out.Lock()
+		ap.volume.setFade(ap.fade, ap.rate)
+		ap.out.Unlock()
+	}
+}
+
+// fadeOut leaves the playing track to the speaker to ramp down, reporting
+// whether it does. Once the ramp is played out, or fadeGrace after it
+// should have been, the track is taken off the speaker and release is
+// called. Nothing waits for it, so a skip doesn't hold up the UI. A paused
+// or finished track isn't faded. The caller must hold ap.mu.
+func (ap *AudioPlayer) fadeOut(release func()) bool {
+	if ap.fade <= 0 || ap.volume == nil {
+		return false
+	}
+
+	ap.out.Lock()
+	defer ap.out.Unlock()
+
+	if ap.ctrl.Paused || ap.completionStream.IsCompleted() {
+		return false
+	}
+
+	var once sync.Once
+	ctrl := ap.ctrl
+	ap.volume.target = 0
+	ap.volume.pause = nil
+	ap.volume.stopped = func() { once.Do(release) }
+	time.AfterFunc(ap.fade+fadeGrace, func() {
+		ap.out.Lock()
+		ctrl.Streamer = nil
+		ap.out.Unlock()
+		once.Do(release)
+	})
+	return true
+}
@@ -0,0 +1,86 @@
+package player
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// constStreamer plays a constant 1 forever
+type constStreamer struct{ read int }
+
+func (c *constStreamer) Stream(samples [][2]float64) (int, bool) {
+	for i := range samples {
+		samples[i] = [2]float64{1, 1}
+	}
+	c.read += len(samples)
+	return len(samples), true
+}
+
+func (c *constStreamer) Err() error { return nil }
+
+func TestFaderGain(t *testing.T) {
+	f := newFader(&constStreamer{}, 0.5, 0, beep.SampleRate(1000))
+	buf := make([][2]float64, 4)
+	f.Stream(buf)
+	for i, sample := range buf {
+		if sample != [2]float64{0.5, 0.5} {
+			t.Fatalf("sample %d = %v, want the gain of 0.5", i, sample)
+		}
+	}
+}
+
+// TestFaderPause ramps down over 10 ms at 1 kHz, reading no further than
+// the ramp, pauses and ramps up again
+func TestFaderPause(t *testing.T) {
+	source := &constStreamer{}
+	ctrl := &beep.Ctrl{}
+	f := newFader(source, 1, 10*time.Millisecond, beep.SampleRate(1000))
+	f.target, f.pause = 0, ctrl
+
+	buf := make([][2]float64, 32)
+	if n, ok := f.Stream(buf); n != len(buf) || !ok {
+		t.Fatalf("Stream() = %d, %v, want a full buffer", n, ok)
+	}
+	if source.read != 11 {
+		t.Errorf("read %d samples fading out, want the 11 of the ramp", source.read)
+	}
+	for i := 1; i < 11; i++ {
+		if buf[i][0] >= buf[i-1][0] {
+			t.Fatalf("sample %d = %v, not below the one before", i, buf[i][0])
+		}
+	}
+	for i, sample := range buf[10:] {
+		if sample[0] != 0 {
+			t.Fatalf("sample %d = %v after the ramp, want silence", i+10, sample[0])
+		}
+	}
+	if !ctrl.Paused {
+		t.Error("the stream isn't paused once quiet")
+	}
+
+	f.target = 1
+	f.Stream(buf)
+	if buf[0][0] <= 0 || buf[0][0] >= buf[15][0] || buf[15][0] != 1 {
+		t.Errorf("resuming played %v .. %v, want a ramp up to 1", buf[0][0], buf[15][0])
+	}
+}
+
+// TestFaderStop calls stopped once the ramp is played out and ends
+func TestFaderStop(t *testing.T) {
+	f := newFader(&constStreamer{}, 1, 5*time.Millisecond, beep.SampleRate(1000))
+	done := make(chan struct{})
+	f.target, f.stopped = 0, func() { close(done) }
+
+	buf := make([][2]float64, 16)
+	f.Stream(buf)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stopped wasn't called after fading out")
+	}
+	if n, ok := f.Stream(buf); n != 0 || ok {
+		t.Errorf("Stream() = %d, %v after stopping, want the end", n, ok)
+	}
+}
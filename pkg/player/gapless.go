@@ -0,0 +1,123 @@
+package player
+
+import "github.com/gopxl/beep"
+
+// gaplessStreamer plays the current track and, when it runs out, carries
+// on with the preloaded next track inside the same Stream call so there is
+// no silence between them. A track that stops on an error ends the stream
+// with err instead. Its fields are guarded by the speaker lock.
+type gaplessStreamer struct {
+	current    beep.Streamer
+	next       *loadedTrack
+	nextStream beep.Streamer
+	switched   *loadedTrack
+	err        error
+}
+
+// Stream fills samples from the current track, switching to the next one
+// when the current track is exhausted
+func (g *gaplessStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) && g.current != nil {
+		k, more := g.current.Stream(samples[n:])
+		n += k
+		if more {
+			if k == 0 {
+				break
+			}
+			continue
+		}
+
+		if err := g.current.Err(); err != nil || g.next == nil {
+			g.err = err
+			g.current = nil
+			break
+		}
+		g.switched = g.next
+		g.current = g.nextStream
+		g.next = nil
+		g.nextStream = nil
+	}
+	return n, n > 0 || g.current != nil
+}
+
+// Err returns the error of the track being streamed, or the one that
+// ended the stream
+func (g *gaplessStreamer) Err() error {
+	if g.current == nil {
+		return g.err
+	}
+	return g.current.Err()
+}
+
+// Preload opens and decodes the track that follows the current one so it
+// can start the moment the current one ends. Only one track is kept
+// preloaded; a stop or a newer preload discards it.
+func (ap *AudioPlayer) Preload(filePath string) error {
+	ap.mu.Lock()
+	generation := ap.generation
+	ap.mu.Unlock()
+
+	lt, err := openTrack(ap.codecs, filePath, ap.crashes, ap.news)
+	if err != nil {
+		return err
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if generation != ap.generation || ap.gapless == nil {
+		lt.Close()
+		return nil
+	}
+
+	stream := ap.levelled(ap.out.Resample(ap.trimmed(lt.streamer, lt.format.SampleRate), lt.format.SampleRate), lt.info)
+	ap.out.Lock()
+	if ap.gapless.switched != nil {
+		// The current track already ran out, this preload is too late
+		ap.out.Unlock()
+		lt.Close()
+		return nil
+	}
+	ap.gapless.next = lt
+	ap.gapless.nextStream = stream
+	ap.out.Unlock()
+
+	if ap.preloaded != nil {
+		ap.preloaded.Close()
+	}
+	ap.preloaded = lt
+	return nil
+}
+
+// adoptSwitched makes the preloaded track current once the speaker has
+// moved on to it, the caller must hold ap.mu
+func (ap *AudioPlayer) adoptSwitched() {
+	if ap.gapless == nil {
+		return
+	}
+
+	ap.out.Lock()
+	switched := ap.gapless.switched
+	ap.gapless.switched = nil
+	ap.out.Unlock()
+
+	if switched == nil {
+		return
+	}
+
+	ap.install(switched)
+	ap.preloaded = nil
+	ap.advanced = switched.path
+}
+
+// TookOverNext returns the path of the preloaded track if playback moved on
+// to it since the last call, or ""
+func (ap *AudioPlayer) TookOverNext() string {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+	advanced := ap.advanced
+	ap.advanced = ""
+	return advanced
+}
@@ -0,0 +1,65 @@
+package player
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestGaplessStreamer switches to the preloaded track inside the Stream
+// call the current one runs out in
+func TestGaplessStreamer(t *testing.T) {
+	next := &loadedTrack{path: "next.flac"}
+	second := newTestStreamer(3)
+	for i := range second.samples {
+		second.samples[i] = [2]float64{float64(10 + i), float64(10 + i)}
+	}
+	g := &gaplessStreamer{current: newTestStreamer(3), next: next, nextStream: second}
+
+	buf := make([][2]float64, 5)
+	n, ok := g.Stream(buf)
+	if n != 5 || !ok {
+		t.Fatalf("Stream() = %d, %v, want a full buffer", n, ok)
+	}
+	var got []float64
+	for _, sample := range buf {
+		got = append(got, sample[0])
+	}
+	if want := []float64{1, 2, 3, 10, 11}; !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+	if g.switched != next || g.next != nil {
+		t.Error("the preloaded track wasn't recorded as switched to")
+	}
+
+	n, ok = g.Stream(buf)
+	if n != 1 || !ok {
+		t.Errorf("Stream() = %d, %v, want the last sample", n, ok)
+	}
+	if n, ok = g.Stream(buf); n != 0 || ok {
+		t.Errorf("Stream() = %d, %v with nothing left, want the end", n, ok)
+	}
+}
+
+// TestGaplessStreamerError ends the stream on a track that fails rather
+// than carrying on with the next one
+func TestGaplessStreamerError(t *testing.T) {
+	errDecode := errors.New("bad frame")
+	current := newTestStreamer(2)
+	current.err = errDecode
+	g := &gaplessStreamer{current: current, next: &loadedTrack{}, nextStream: newTestStreamer(4)}
+
+	buf := make([][2]float64, 8)
+	if n, ok := g.Stream(buf); n != 2 || !ok {
+		t.Errorf("Stream() = %d, %v, want the 2 samples before the error", n, ok)
+	}
+	if n, ok := g.Stream(buf); n != 0 || ok {
+		t.Errorf("Stream() = %d, %v after the error, want the end", n, ok)
+	}
+	if !errors.Is(g.Err(), errDecode) {
+		t.Errorf("Err() = %v, want %v", g.Err(), errDecode)
+	}
+	if g.switched != nil {
+		t.Error("switched to the next track after an error")
+	}
+}
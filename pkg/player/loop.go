@@ -0,0 +1,69 @@
+package player
+
+import (
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// loopStreamer repeats a stretch of a track's decoder: the moment its
+// position reaches to it seeks back to from within the same Stream call,
+// so the jump leaves no gap. to is 0 while not looping. The bounds are
+// samples, guarded by the speaker lock.
+type loopStreamer struct {
+	beep.StreamSeekCloser
+	from int
+	to   int
+}
+
+// Stream fills samples, going round the loop as often as it takes
+func (l *loopStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if l.to <= 0 {
+		return l.StreamSeekCloser.Stream(samples)
+	}
+
+	for n < len(samples) {
+		position := l.Position()
+		if position >= l.to {
+			if err := l.Seek(l.from); err != nil {
+				// Play on without the loop
+				l.to = 0
+				k, ok := l.StreamSeekCloser.Stream(samples[n:])
+				return n + k, ok || n > 0
+			}
+			position = l.from
+		}
+
+		k, more := l.StreamSeekCloser.Stream(samples[n:min(len(samples), n+l.to-position)])
+		n += k
+		if !more {
+			return n, n > 0
+		}
+		if k == 0 {
+			break
+		}
+	}
+	return n, true
+}
+
+// SetLoop repeats the current track from from to until, or plays it on
+// when until is 0. Loading a track, or the same one again, drops the loop.
+func (ap *AudioPlayer) SetLoop(from, until time.Duration) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+	ap.setLoop(ap.format.SampleRate.N(from), ap.format.SampleRate.N(until))
+}
+
+// setLoop sets the loop of the current track in samples, the caller must
+// hold ap.mu
+func (ap *AudioPlayer) setLoop(from, to int) {
+	loop, ok := ap.streamer.(*loopStreamer)
+	if !ok {
+		return
+	}
+	ap.out.Lock()
+	loop.from, loop.to = from, to
+	ap.out.Unlock()
+}
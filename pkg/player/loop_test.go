@@ -0,0 +1,41 @@
+package player
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoopStreamer(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		size     int
+		want     []float64
+	}{
+		{"no loop", 0, 0, 4, []float64{1, 2, 3, 4, 5, 6}},
+		{"loop in one buffer", 1, 3, 8, []float64{1, 2, 3, 2, 3, 2, 3, 2, 3, 2}},
+		{"loop across buffers", 2, 5, 2, []float64{1, 2, 3, 4, 5, 3, 4, 5, 3, 4}},
+		{"loop of one sample", 3, 4, 3, []float64{1, 2, 3, 4, 4, 4, 4, 4, 4, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop := &loopStreamer{StreamSeekCloser: newTestStreamer(6), from: tt.from, to: tt.to}
+			if got := streamAll(loop, tt.size, 10); !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoopStreamerSeekFails plays on past the loop when the decoder
+// can't seek back
+func TestLoopStreamerSeekFails(t *testing.T) {
+	loop := &loopStreamer{StreamSeekCloser: newTestStreamer(5), from: -1, to: 2}
+	want := []float64{1, 2, 3, 4, 5}
+	if got := streamAll(loop, 4, 10); !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+	if loop.to != 0 {
+		t.Errorf("loop to = %d after a failed seek, want it dropped", loop.to)
+	}
+}
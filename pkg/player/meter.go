@@ -0,0 +1,90 @@
+package player
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopxl/beep"
+)
+
+// The level meter keeps the RMS level of the last MeterHistory buffers
+// sent to the speaker, shown from meterFloor dBFS up to full scale
+const (
+	MeterHistory = 40
+	meterFloor   = -48.0
+)
+
+// LevelMeter records output levels while enabled. Stream calls cost an
+// atomic load when it is off.
+type LevelMeter struct {
+	enabled atomic.Bool
+
+	mu     sync.Mutex
+	levels [MeterHistory]float64
+	next   int
+	count  int
+}
+
+// meterTap passes audio through, measuring it on the way
+type meterTap struct {
+	beep.Streamer
+	meter *LevelMeter
+}
+
+// Stream fills samples and records their level
+func (t *meterTap) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = t.Streamer.Stream(samples)
+	if n > 0 && t.meter.enabled.Load() {
+		t.meter.add(samples[:n])
+	}
+	return n, ok
+}
+
+// add records the RMS level of a buffer
+func (lm *LevelMeter) add(samples [][2]float64) {
+	sum := 0.0
+	for _, s := range samples {
+		sum += s[0]*s[0] + s[1]*s[1]
+	}
+	rms := math.Sqrt(sum / float64(2*len(samples)))
+
+	lm.mu.Lock()
+	lm.levels[lm.next] = rms
+	lm.next = (lm.next + 1) % MeterHistory
+	lm.count = min(lm.count+1, MeterHistory)
+	lm.mu.Unlock()
+}
+
+// Reset forgets the levels recorded so far, e.g. on a track change
+func (lm *LevelMeter) Reset() {
+	lm.mu.Lock()
+	lm.next = 0
+	lm.count = 0
+	lm.mu.Unlock()
+}
+
+// SetEnabled switches measuring on or off, off also clearing the levels
+func (lm *LevelMeter) SetEnabled(enabled bool) {
+	lm.enabled.Store(enabled)
+	if !enabled {
+		lm.Reset()
+	}
+}
+
+// Levels returns the recorded levels, oldest first, scaled to 0..1
+func (lm *LevelMeter) Levels() []float64 {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	levels := make([]float64, lm.count)
+	for i := range levels {
+		rms := lm.levels[(lm.next-lm.count+i+MeterHistory)%MeterHistory]
+		db := meterFloor
+		if rms > 0 {
+			db = max(20*math.Log10(rms), meterFloor)
+		}
+		levels[i] = 1 - db/meterFloor
+	}
+	return levels
+}
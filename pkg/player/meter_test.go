@@ -0,0 +1,56 @@
+package player
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevelMeter(t *testing.T) {
+	var meter LevelMeter
+	tap := &meterTap{Streamer: &constStreamer{}, meter: &meter}
+	buf := make([][2]float64, 64)
+
+	tap.Stream(buf)
+	if levels := meter.Levels(); len(levels) != 0 {
+		t.Errorf("Levels() = %v while disabled, want none", levels)
+	}
+
+	meter.SetEnabled(true)
+	for range MeterHistory + 5 {
+		tap.Stream(buf)
+	}
+	levels := meter.Levels()
+	if len(levels) != MeterHistory {
+		t.Fatalf("got %d levels, want the last %d", len(levels), MeterHistory)
+	}
+	for i, level := range levels {
+		if math.Abs(level-1) > 1e-9 {
+			t.Fatalf("level %d = %v for full scale, want 1", i, level)
+		}
+	}
+
+	meter.SetEnabled(false)
+	if levels := meter.Levels(); len(levels) != 0 {
+		t.Errorf("Levels() = %v after disabling, want them cleared", levels)
+	}
+}
+
+// TestLevelMeterScale places levels between meterFloor dBFS and full scale
+func TestLevelMeterScale(t *testing.T) {
+	tests := []struct {
+		amplitude float64
+		want      float64
+	}{
+		{0, 0},
+		{1e-4, 0},
+		{math.Pow(10, meterFloor/40), 0.5},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		var meter LevelMeter
+		meter.add([][2]float64{{tt.amplitude, -tt.amplitude}, {-tt.amplitude, tt.amplitude}})
+		if got := meter.Levels()[0]; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("level of amplitude %v = %v, want %v", tt.amplitude, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package player plays audio through the sound device. An Output owns the
+// device, and streamers are played through it with Play. An AudioPlayer
+// plays tracks through an Output: files and http(s) streams, decoded by a
+// codec.Registry, with gapless transitions, fades, ReplayGain, an
+// equalizer and silence skipping.
+package player
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/speaker"
+)
+
+// Output owns the sound device, the system's default or the one chosen
+// with SetDevice. beep's speaker can only be initialized once per
+// process, so a program creates one Output and its players acquire and
+// release the device through it, instead of initializing it themselves.
+type Output struct {
+	mu          sync.Mutex
+	dev         device
+	refs        int
+	initialized bool
+	sampleRate  beep.SampleRate
+
+	// The rate the device is opened at, zero to take the first player's
+	fixedRate beep.SampleRate
+}
+
+// NewOutput returns an Output that opens the device at rate, or at the
+// rate of the first Acquire when rate is zero. Nothing is opened until
+// then.
+func NewOutput(rate beep.SampleRate) *Output {
+	return &Output{dev: speakerDevice{}, fixedRate: rate}
+}
+
+// device is what an Output plays through: beep's speaker, or a stand-in
+// that plays nowhere in tests. It streams what it plays only while
+// holding its lock.
+type device interface {
+	init(rate beep.SampleRate, bufferSize int) error
+	play(s beep.Streamer)
+	lock()
+	unlock()
+	clear()
+	suspend() error
+	resume() error
+}
+
+// speakerDevice is the sound card, through beep's speaker
+type speakerDevice struct{}
+
+func (speakerDevice) init(rate beep.SampleRate, bufferSize int) error {
+	return speaker.Init(rate, bufferSize)
+}
+
+func (speakerDevice) play(s beep.Streamer) { speaker.Play(s) }
+func (speakerDevice) lock()                { speaker.Lock() }
+func (speakerDevice) unlock()              { speaker.Unlock() }
+func (speakerDevice) clear()               { speaker.Clear() }
+func (speakerDevice) suspend() error       { return speaker.Suspend() }
+func (speakerDevice) resume() error        { return speaker.Resume() }
+
+// Acquire registers a user of the device, initializing it at sampleRate on
+// first use, or at the rate fixed with SetRate. Later callers share the
+// rate chosen by the first one.
+func (o *Output) Acquire(sampleRate beep.SampleRate) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.fixedRate != 0 {
+		sampleRate = o.fixedRate
+	}
+	if !o.initialized {
+		if err := o.dev.init(sampleRate, sampleRate.N(time.Second/10)); err != nil {
+			return fmt.Errorf("failed to initialize speaker: %w", err)
+		}
+		o.initialized = true
+		o.sampleRate = sampleRate
+	}
+
+	o.refs++
+	return nil
+}
+
+// Release drops a user of the device. When the last user goes away any
+// leftover streamers are cleared, but the device stays initialized since
+// beep can't initialize it a second time.
+func (o *Output) Release() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.refs == 0 {
+		return
+	}
+
+	o.refs--
+	if o.refs == 0 && o.initialized {
+		o.dev.clear()
+	}
+}
+
+// SampleRate returns the rate the device was initialized with, or zero
+// before the first Acquire
+func (o *Output) SampleRate() beep.SampleRate {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.sampleRate
+}
+
+// SetRate fixes the rate the device is initialized at, instead of the
+// first player's, so tracks at that rate play without resampling. It has
+// no effect once the device is open.
+func (o *Output) SetRate(rate beep.SampleRate) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.fixedRate = rate
+}
+
+// Restart suspends and resumes the device, which makes the driver reopen
+// its stream, e.g. after Bluetooth headphones reconnected. beep can't
+// initialize the speaker a second time, so this is as close to a fresh
+// start as it gets.
+func (o *Output) Restart() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.initialized {
+		return nil
+	}
+	if err := o.dev.suspend(); err != nil {
+		return err
+	}
+	return o.dev.resume()
+}
+
+// Play mixes s into what the device plays. The Output must have been
+// acquired, and s should run at its SampleRate; see Resample.
+func (o *Output) Play(s beep.Streamer) {
+	o.dev.play(s)
+}
+
+// Lock stops the device from pulling samples until Unlock, so streamers
+// being played can be changed safely. Hold it as briefly as possible.
+func (o *Output) Lock() {
+	o.dev.lock()
+}
+
+// Unlock lets the device pull samples again after Lock
+func (o *Output) Unlock() {
+	o.dev.unlock()
+}
+
+// Resample converts s from rate to the rate the device runs at, so tracks
+// whose rate differs from the first one don't play at the wrong pitch. It
+// returns s itself when no conversion is needed, or the device isn't open.
+func (o *Output) Resample(s beep.Streamer, rate beep.SampleRate) beep.Streamer {
+	out := o.SampleRate()
+	if out == 0 || rate == out {
+		return s
+	}
+	return beep.Resample(4, rate, out, s)
+}
@@ -0,0 +1,124 @@
+package player
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// countingDevice counts what is done to it, and fails a second init the
+// way beep's speaker can't be initialized twice
+type countingDevice struct {
+	testDevice
+	inits, clears int
+}
+
+func (d *countingDevice) init(rate beep.SampleRate, bufferSize int) error {
+	d.inits++
+	if d.inits > 1 {
+		return errors.New("speaker initialized twice")
+	}
+	return nil
+}
+
+func (d *countingDevice) clear() {
+	d.clears++
+	d.testDevice.clear()
+}
+
+func TestOutputAcquireRelease(t *testing.T) {
+	tests := []struct {
+		name  string
+		fixed beep.SampleRate
+		// ops are the rates acquired at in turn, zero for a release
+		ops    []beep.SampleRate
+		refs   int
+		rate   beep.SampleRate
+		clears int
+		inits  int
+	}{
+		{"nothing", 0, nil, 0, 0, 0, 0},
+		{"one user", 0, []beep.SampleRate{44100}, 1, 44100, 0, 1},
+		{"create close", 0, []beep.SampleRate{44100, 0}, 0, 44100, 1, 1},
+		{"create close create", 0, []beep.SampleRate{44100, 0, 48000}, 1, 44100, 1, 1},
+		{"second user shares the rate", 0, []beep.SampleRate{44100, 48000}, 2, 44100, 0, 1},
+		{"one of two closes", 0, []beep.SampleRate{44100, 48000, 0}, 1, 44100, 0, 1},
+		{"both close", 0, []beep.SampleRate{44100, 48000, 0, 0}, 0, 44100, 1, 1},
+		{"release too often", 0, []beep.SampleRate{44100, 0, 0, 0}, 0, 44100, 1, 1},
+		{"release before acquire", 0, []beep.SampleRate{0, 22050}, 1, 22050, 0, 1},
+		{"fixed rate", 48000, []beep.SampleRate{44100}, 1, 48000, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &countingDevice{}
+			out := &Output{dev: dev, fixedRate: tt.fixed}
+			for _, rate := range tt.ops {
+				if rate == 0 {
+					out.Release()
+				} else if err := out.Acquire(rate); err != nil {
+					t.Fatalf("Acquire(%d): %v", rate, err)
+				}
+			}
+			if out.refs != tt.refs || out.SampleRate() != tt.rate {
+				t.Errorf("%d users at %d Hz, want %d at %d Hz", out.refs, out.SampleRate(), tt.refs, tt.rate)
+			}
+			if dev.inits != tt.inits || dev.clears != tt.clears {
+				t.Errorf("device initialized %d times and cleared %d, want %d and %d", dev.inits, dev.clears, tt.inits, tt.clears)
+			}
+		})
+	}
+}
+
+// TestCloseKeepsOtherPlayer closes one of two players sharing an Output:
+// the other plays on, and a new player can take the first one's place
+func TestCloseKeepsOtherPlayer(t *testing.T) {
+	tracks := writeTestTracks(t, 3)
+	out := newTestOutput(t, 0)
+	tc := &testCodec{}
+	codecs := codec.New()
+	codecs.Register(".tst", tc.decode)
+
+	play := func(path string) *AudioPlayer {
+		t.Helper()
+		ap := NewAudioPlayer(out, codecs)
+		if err := ap.LoadTrack(path); err != nil {
+			t.Fatal(err)
+		}
+		ap.Play()
+		return ap
+	}
+	ended := func(ap *AudioPlayer) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for !ap.HasEnded() {
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return true
+	}
+
+	first := play(tracks[0].path)
+	second := play(tracks[1].path)
+	first.Close()
+	if !ended(second) {
+		t.Fatal("the second player stopped playing when the first was closed")
+	}
+	third := play(tracks[2].path)
+	if !ended(third) {
+		t.Fatal("a player created after a close doesn't play")
+	}
+	second.Close()
+	third.Close()
+
+	if out.refs != 0 {
+		t.Errorf("%d users left once all players closed", out.refs)
+	}
+	if open, twice := tc.unclosed(); open > 0 || twice > 0 {
+		t.Errorf("%d decoders left open and %d closed twice", open, twice)
+	}
+}
@@ -0,0 +1,59 @@
+package player
+
+import (
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
+)
+
+// Player is a playback engine. AudioPlayer plays through the speaker;
+// dirplay's terminal UI only relies on this interface, so it can run
+// against another engine, e.g. one that plays nothing.
+type Player interface {
+	// Loading and transport
+	LoadTrack(filePath string) error
+	Preload(filePath string) error
+	TookOverNext() string
+	Play() error
+	Pause()
+	Resume()
+	Stop()
+	Close()
+	Seek(pos time.Duration) error
+	SetLoop(from, until time.Duration)
+
+	// Playback state
+	GetPosition() time.Duration
+	GetDuration() time.Duration
+	HasEnded() bool
+	Err() error
+	Crashes() <-chan Crash
+	GetFormat() beep.Format
+	Buffering() bool
+	StreamNews() <-chan StreamNews
+
+	// Output
+	SetGain(gain float64)
+	SetSpeed(speed float64)
+	SetReplayGain(mode string)
+	SetSkipSilence(config SilenceConfig)
+	SetEQ(gains EQGains)
+	SetFade(fade time.Duration)
+	Meter() *LevelMeter
+	SetTee(tee Tee)
+	OutputRate() beep.SampleRate
+	RestartOutput() error
+
+	// Metadata of the current track
+	GetArtist() string
+	GetTitle() string
+	GetAlbum() string
+	GetAlbumArtist() string
+	GetSortArtist() string
+	GetPicture() *tag.Picture
+	GetInfo() TrackInfo
+}
+
+// AudioPlayer is the Player used for real playback
+var _ Player = (*AudioPlayer)(nil)
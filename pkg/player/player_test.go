@@ -0,0 +1,76 @@
+package player
+
+import (
+	"errors"
+
+	"github.com/gopxl/beep"
+)
+
+// testStreamer plays samples from memory, as a decoder would. It reports
+// err once it runs out, if set, and counts Close calls.
+type testStreamer struct {
+	samples [][2]float64
+	pos     int
+	err     error
+	closed  int
+}
+
+// newTestStreamer returns a mono ramp 1, 2, ... n on both channels
+func newTestStreamer(n int) *testStreamer {
+	s := &testStreamer{samples: make([][2]float64, n)}
+	for i := range s.samples {
+		s.samples[i] = [2]float64{float64(i + 1), float64(i + 1)}
+	}
+	return s
+}
+
+func (s *testStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.pos >= len(s.samples) {
+		return 0, false
+	}
+	n = copy(samples, s.samples[s.pos:])
+	s.pos += n
+	return n, true
+}
+
+func (s *testStreamer) Err() error {
+	if s.pos >= len(s.samples) {
+		return s.err
+	}
+	return nil
+}
+
+func (s *testStreamer) Len() int      { return len(s.samples) }
+func (s *testStreamer) Position() int { return s.pos }
+
+func (s *testStreamer) Seek(p int) error {
+	if p < 0 || p > len(s.samples) {
+		return errors.New("seek out of range")
+	}
+	s.pos = p
+	return nil
+}
+
+func (s *testStreamer) Close() error {
+	s.closed++
+	return nil
+}
+
+var _ beep.StreamSeekCloser = (*testStreamer)(nil)
+
+// streamAll reads s to its end, or limit samples, in buffers of size,
+// returning the left channel of what it played
+func streamAll(s beep.Streamer, size, limit int) []float64 {
+	var played []float64
+	buf := make([][2]float64, size)
+	for len(played) < limit {
+		n, ok := s.Stream(buf)
+		for _, sample := range buf[:n] {
+			played = append(played, sample[0])
+		}
+		if !ok {
+			break
+		}
+	}
+	return played[:min(len(played), limit)]
+}
@@ -0,0 +1,34 @@
+package player
+
+import (
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/effects"
+)
+
+// Modes of SetReplayGain
+const (
+	ReplayGainOff   = "off"
+	ReplayGainTrack = "track"
+	ReplayGainAlbum = "album"
+)
+
+// SetReplayGain levels tracks by their ReplayGain tags in the given mode,
+// from the next track that starts on
+func (ap *AudioPlayer) SetReplayGain(mode string) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.replayGain = mode
+}
+
+// levelled applies the ReplayGain of a track to its stream. The caller
+// must hold ap.mu.
+func (ap *AudioPlayer) levelled(s beep.Streamer, info TrackInfo) beep.Streamer {
+	if ap.replayGain == ReplayGainOff {
+		return s
+	}
+	db, ok := info.ReplayGain.Gain(ap.replayGain == ReplayGainAlbum)
+	if !ok || db == 0 {
+		return s
+	}
+	return &effects.Volume{Streamer: s, Base: 10, Volume: db / 20}
+}
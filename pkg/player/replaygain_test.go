@@ -0,0 +1,47 @@
+package player
+
+import (
+	"math"
+	"testing"
+
+	"github.com/punkscience/dirplay/pkg/tags"
+)
+
+func TestLevelled(t *testing.T) {
+	tagged := TrackInfo{ReplayGain: tags.ReplayGain{TrackGain: -6, AlbumGain: -12, HasTrack: true, HasAlbum: true}}
+	tests := []struct {
+		name string
+		mode string // empty to leave the player's default
+		info TrackInfo
+		db   float64
+	}{
+		{"off by default", "", tagged, 0},
+		{"off", ReplayGainOff, tagged, 0},
+		{"track", ReplayGainTrack, tagged, -6},
+		{"album", ReplayGainAlbum, tagged, -12},
+		{"untagged", ReplayGainTrack, TrackInfo{}, 0},
+		{"held by the peak", ReplayGainTrack, TrackInfo{ReplayGain: tags.ReplayGain{TrackGain: 10, TrackPeak: 0.5, HasTrack: true}}, -20 * math.Log10(0.5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ap, _ := newTestPlayer(t)
+			if tt.mode != "" {
+				ap.SetReplayGain(tt.mode)
+			}
+			ap.mu.Lock()
+			s := ap.levelled(newTestStreamer(100), tt.info)
+			ap.mu.Unlock()
+
+			played := streamAll(s, 16, 100)
+			want := math.Pow(10, tt.db/20)
+			for i, sample := range played {
+				if got := sample / float64(i+1); math.Abs(got-want) > 1e-9 {
+					t.Fatalf("sample %d scaled by %v, want %v (%+.2f dB)", i, got, want, tt.db)
+				}
+			}
+			if len(played) != 100 {
+				t.Errorf("played %d samples, want 100", len(played))
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// Trailing silence only counts within the last silenceWindow of a track,
+// so quiet passages earlier on never end it, and at most silenceMaxLead
+// of leading silence is dropped
+const (
+	silenceWindow  = 0.2
+	silenceMaxLead = 10 * time.Second
+)
+
+// SilenceConfig says which silence to skip. Threshold is the level in
+// dBFS below which audio counts as silent, Min how long it has to last at
+// the end of a track to end it.
+type SilenceConfig struct {
+	SkipEnd   bool
+	SkipStart bool
+	Threshold float64
+	Min       time.Duration
+}
+
+// silenceTap passes a track's decoded audio through, dropping silence at
+// its start and ending it early once the audio near its end stays silent.
+// Positions are read from the decoder, so seeking is accounted for.
+type silenceTap struct {
+	beep.StreamSeeker
+	config SilenceConfig
+	rate   beep.SampleRate
+
+	// level is the threshold as an amplitude, minRun the silence in
+	// samples that ends the track, run the silence heard so far
+	level  float64
+	minRun int
+	run    int
+
+	started bool
+	leading bool
+	ended   bool
+}
+
+// newSilenceTap wraps a decoder streaming at rate
+func newSilenceTap(s beep.StreamSeeker, rate beep.SampleRate, config SilenceConfig) *silenceTap {
+	return &silenceTap{
+		StreamSeeker: s,
+		config:       config,
+		rate:         rate,
+		level:        math.Pow(10, config.Threshold/20),
+		minRun:       rate.N(config.Min),
+	}
+}
+
+// Stream fills samples, skipping leading silence on the first call when
+// the track starts from its beginning
+func (t *silenceTap) Stream(samples [][2]float64) (n int, ok bool) {
+	if !t.started {
+		t.started = true
+		t.leading = t.config.SkipStart && t.Position() == 0
+	}
+	if t.ended {
+		return 0, false
+	}
+	if t.leading {
+		return t.skipLeading(samples)
+	}
+
+	n, ok = t.StreamSeeker.Stream(samples)
+	if t.config.SkipEnd && n > 0 {
+		t.watchEnd(samples[:n])
+	}
+	return n, ok
+}
+
+// skipLeading reads on until a sample reaches the threshold and returns
+// the audio from there. After silenceMaxLead it gives up and plays on.
+func (t *silenceTap) skipLeading(samples [][2]float64) (int, bool) {
+	maxLead := t.rate.N(silenceMaxLead)
+	for {
+		n, ok := t.StreamSeeker.Stream(samples)
+		for i, s := range samples[:n] {
+			if math.Abs(s[0]) >= t.level || math.Abs(s[1]) >= t.level {
+				t.leading = false
+				return copy(samples, samples[i:n]), true
+			}
+		}
+		if !ok || n == 0 || t.Position() >= maxLead {
+			t.leading = false
+			return n, ok
+		}
+	}
+}
+
+// watchEnd adds a buffer to the silence heard in the last part of the
+// track, ending the track once it lasted config.min
+func (t *silenceTap) watchEnd(samples [][2]float64) {
+	length := t.Len()
+	if length <= 0 || float64(t.Position()) < float64(length)*(1-silenceWindow) {
+		t.run = 0
+		return
+	}
+
+	sum := 0.0
+	for _, s := range samples {
+		sum += s[0]*s[0] + s[1]*s[1]
+	}
+	if math.Sqrt(sum/float64(2*len(samples))) >= t.level {
+		t.run = 0
+		return
+	}
+	t.run += len(samples)
+	if t.run >= t.minRun {
+		t.ended = true
+	}
+}
+
+// SetSkipSilence trims silence from the tracks that start from now on
+func (ap *AudioPlayer) SetSkipSilence(config SilenceConfig) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.silence = config
+}
+
+// trimmed applies the silence skipping to a track's decoder. The caller
+// must hold ap.mu.
+func (ap *AudioPlayer) trimmed(s beep.StreamSeeker, rate beep.SampleRate) beep.Streamer {
+	if !ap.silence.SkipEnd && !ap.silence.SkipStart {
+		return s
+	}
+	return newSilenceTap(s, rate, ap.silence)
+}
@@ -0,0 +1,170 @@
+package player
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+)
+
+// silenceRate is the sample rate of the synthetic tracks, low to keep
+// them small
+const silenceRate = beep.SampleRate(1000)
+
+// segment is a stretch of a synthetic track at a level in dBFS, or
+// silent at -inf
+type segment struct {
+	length time.Duration
+	db     float64
+}
+
+// silenceTrack returns a streamer of segments in turn, a square wave at
+// each segment's level
+func silenceTrack(segments ...segment) *testStreamer {
+	s := &testStreamer{}
+	for _, seg := range segments {
+		level := math.Pow(10, seg.db/20)
+		for i := range silenceRate.N(seg.length) {
+			x := level
+			if i%2 == 1 {
+				x = -level
+			}
+			s.samples = append(s.samples, [2]float64{x, x})
+		}
+	}
+	return s
+}
+
+func TestSilenceTap(t *testing.T) {
+	silent := math.Inf(-1)
+	both := SilenceConfig{SkipEnd: true, SkipStart: true, Threshold: -50, Min: 3 * time.Second}
+	tests := []struct {
+		name     string
+		config   SilenceConfig
+		segments []segment
+		// start is where playback starts
+		start time.Duration
+		// played is how much of the track plays, within a buffer
+		played time.Duration
+		// first is the level of the first sample played
+		first float64
+	}{
+		{
+			name:     "silent tail",
+			config:   both,
+			segments: []segment{{20 * time.Second, -10}, {8 * time.Second, silent}},
+			// The silence only counts from 80% in, at 22.4s
+			played: 25400 * time.Millisecond,
+			first:  -10,
+		},
+		{
+			name:     "tail below the threshold",
+			config:   both,
+			segments: []segment{{20 * time.Second, -10}, {8 * time.Second, -60}},
+			played:   25400 * time.Millisecond,
+			first:    -10,
+		},
+		{
+			name:     "tail above the threshold",
+			config:   both,
+			segments: []segment{{20 * time.Second, -10}, {8 * time.Second, -40}},
+			played:   28 * time.Second,
+			first:    -10,
+		},
+		{
+			name:     "quiet passage mid-track",
+			config:   both,
+			segments: []segment{{10 * time.Second, -10}, {8 * time.Second, silent}, {10 * time.Second, -10}},
+			played:   28 * time.Second,
+			first:    -10,
+		},
+		{
+			name:     "short gap near the end",
+			config:   both,
+			segments: []segment{{25 * time.Second, -10}, {2 * time.Second, silent}, {3 * time.Second, -10}},
+			played:   30 * time.Second,
+			first:    -10,
+		},
+		{
+			name:     "silent tail kept",
+			config:   SilenceConfig{SkipStart: true, Threshold: -50, Min: 3 * time.Second},
+			segments: []segment{{20 * time.Second, -10}, {8 * time.Second, silent}},
+			played:   28 * time.Second,
+			first:    -10,
+		},
+		{
+			name:     "leading silence",
+			config:   both,
+			segments: []segment{{4 * time.Second, silent}, {10 * time.Second, -10}},
+			played:   10 * time.Second,
+			first:    -10,
+		},
+		{
+			name:     "leading silence kept",
+			config:   SilenceConfig{SkipEnd: true, Threshold: -50, Min: 3 * time.Second},
+			segments: []segment{{4 * time.Second, silent}, {10 * time.Second, -10}},
+			played:   14 * time.Second,
+			first:    silent,
+		},
+		{
+			name:     "leading silence too long to skip",
+			config:   both,
+			segments: []segment{{12 * time.Second, silent}, {10 * time.Second, -10}},
+			// Up to silenceMaxLead is dropped, the rest plays
+			played: 12 * time.Second,
+			first:  silent,
+		},
+		{
+			name:     "started after the beginning",
+			config:   both,
+			segments: []segment{{4 * time.Second, silent}, {10 * time.Second, -10}},
+			start:    time.Second,
+			played:   13 * time.Second,
+			first:    silent,
+		},
+	}
+	const buffer = 100
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := silenceTrack(tt.segments...)
+			track.Seek(silenceRate.N(tt.start))
+			tap := newSilenceTap(track, silenceRate, tt.config)
+
+			played := streamAll(tap, buffer, len(track.samples)+1)
+			if got, want := len(played), silenceRate.N(tt.played); got < want-buffer || got > want+buffer {
+				t.Errorf("played %v, want %v", silenceRate.D(got), tt.played)
+			}
+			first := math.Inf(-1)
+			if len(played) > 0 && played[0] != 0 {
+				first = math.Round(20 * math.Log10(math.Abs(played[0])))
+			}
+			if first != tt.first {
+				t.Errorf("first sample at %v dBFS, want %v", first, tt.first)
+			}
+		})
+	}
+}
+
+// TestTrimmed checks tracks are only wrapped when silence is skipped
+func TestTrimmed(t *testing.T) {
+	tests := []struct {
+		config  SilenceConfig
+		wrapped bool
+	}{
+		{SilenceConfig{}, false},
+		{SilenceConfig{Threshold: -50, Min: time.Second}, false},
+		{SilenceConfig{SkipEnd: true}, true},
+		{SilenceConfig{SkipStart: true}, true},
+	}
+	for _, tt := range tests {
+		ap, _ := newTestPlayer(t)
+		ap.SetSkipSilence(tt.config)
+		ap.mu.Lock()
+		_, wrapped := ap.trimmed(newTestStreamer(10), silenceRate).(*silenceTap)
+		ap.mu.Unlock()
+		if wrapped != tt.wrapped {
+			t.Errorf("wrapped %v with %+v, want %v", wrapped, tt.config, tt.wrapped)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package player
+
+// Playback speed range
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 3.0
+)
+
+// SetSpeed plays faster or slower, 1 being normal speed, clamped to
+// MinSpeed..MaxSpeed. Audio is resampled, so the pitch changes with it.
+// It lasts across tracks until changed.
+func (ap *AudioPlayer) SetSpeed(speed float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.speed = min(max(speed, MinSpeed), MaxSpeed)
+	if ap.speeder != nil {
+		ap.out.Lock()
+		ap.speeder.SetRatio(ap.speed)
+		ap.out.Unlock()
+	}
+}
@@ -0,0 +1,709 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dhowden/tag"
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// A stream must answer within streamTimeout and never go quiet for longer
+// while playing. A dropped connection is tried again streamRetries times,
+// streamRetryDelay apart. streamBuffer of audio is decoded ahead, in
+// chunks of streamChunk samples. Tags past the first streamTagPrefix bytes
+// of a file, as large cover art can push them, aren't read.
+const (
+	streamTimeout    = 10 * time.Second
+	streamRetries    = 3
+	streamRetryDelay = 2 * time.Second
+	streamBuffer     = 5 * time.Second
+	streamChunk      = 4096
+	streamTagPrefix  = 512 << 10
+)
+
+var (
+	// ErrStreamNotSeekable is returned when seeking a live stream, or a
+	// file on a server that doesn't serve ranges
+	ErrStreamNotSeekable = errors.New("the stream can't seek")
+
+	// errStreamClosed is returned by reads cut short by Close
+	errStreamClosed = errors.New("the stream was closed")
+)
+
+// streamTypes maps the content types of streams whose URL has no audio
+// extension to the extension of their format
+var streamTypes = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/ogg":       ".ogg",
+	"audio/vorbis":    ".ogg",
+	"application/ogg": ".ogg",
+	"audio/flac":      ".flac",
+	"audio/x-flac":    ".flac",
+	"audio/wav":       ".wav",
+	"audio/wave":      ".wav",
+	"audio/x-wav":     ".wav",
+	"audio/opus":      ".opus",
+}
+
+// streamClient fetches streams. Compression is off as it would break
+// ranges, and a server that doesn't answer is given up on.
+var streamClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+	transport.ResponseHeaderTimeout = streamTimeout
+	return &http.Client{Transport: transport}
+}()
+
+// StreamNews is what a stream tells while it plays: the artist
+// and title an internet radio station announces, or a notice about the
+// connection
+type StreamNews struct {
+	Path   string
+	Artist string
+	Title  string
+	Notice string
+}
+
+// sendNews passes news on without blocking the stream; news that finds the
+// channel full is dropped
+func sendNews(news chan<- StreamNews, n StreamNews) {
+	if news == nil {
+		return
+	}
+	select {
+	case news <- n:
+	default:
+	}
+}
+
+// httpSource reads a stream over HTTP. Files on servers that serve byte
+// ranges can seek, and a connection that drops is resumed where it broke
+// off; live streams are reconnected from whatever plays now. Reads that
+// stall for streamTimeout count as a dropped connection. Read and Seek
+// must not be called concurrently, Close may be called at any time.
+type httpSource struct {
+	url      string
+	news     chan<- StreamNews
+	header   http.Header // of the first response
+	seekable bool
+	size     int64
+	offset   int64 // of the next byte read
+
+	body io.Reader
+	conn io.Closer
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newHTTPSource connects to url
+func newHTTPSource(url string, news chan<- StreamNews) (*httpSource, error) {
+	s := &httpSource{url: url, news: news, closed: make(chan struct{})}
+	if err := s.dial(0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// dial connects to the stream, from offset onwards when that isn't 0
+func (s *httpSource) dial(offset int64) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		cancel()
+		return errStreamClosed
+	}
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+	req.Header.Set("User-Agent", "dirplay")
+	req.Header.Set("Icy-MetaData", "1")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		cancel()
+		return err
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent || resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("the server answered %s", resp.Status)
+	}
+
+	// Metadata mixed into the audio would throw byte ranges off
+	metaint, _ := strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	if s.header == nil {
+		s.header = resp.Header
+		s.size = resp.ContentLength
+		s.seekable = resp.Header.Get("Accept-Ranges") == "bytes" && s.size > 0 && metaint == 0
+	}
+
+	reader := bufio.NewReaderSize(&stallGuard{r: resp.Body, cancel: cancel}, 32<<10)
+	s.body = reader
+	if metaint > 0 {
+		s.body = &icyReader{r: reader, metaint: metaint, left: metaint, title: func(title string) {
+			artist, title := splitStreamTitle(title)
+			sendNews(s.news, StreamNews{Path: s.url, Artist: artist, Title: title})
+		}}
+	}
+	s.conn = resp.Body
+	s.offset = offset
+	return nil
+}
+
+// Read fills p as reading a file would. Decoders such as the WAV one
+// take a short read to be the end, or lose the frame it cuts through.
+func (s *httpSource) Read(p []byte) (n int, err error) {
+	for n < len(p) && err == nil {
+		var k int
+		k, err = s.readSome(p[n:])
+		n += k
+	}
+	return n, err
+}
+
+// readSome reads what arrived of the stream, connecting again when the
+// connection drops
+func (s *httpSource) readSome(p []byte) (int, error) {
+	for {
+		if s.seekable && s.offset >= s.size {
+			return 0, io.EOF
+		}
+
+		var err error
+		if s.body == nil {
+			// Seeked, the connection is made on the first read
+			err = s.dial(s.offset)
+		} else {
+			var n int
+			n, err = s.body.Read(p)
+			s.offset += int64(n)
+			if n > 0 || err == nil {
+				return n, nil
+			}
+			if err == io.EOF && !s.seekable {
+				// The station ended the stream
+				return 0, io.EOF
+			}
+		}
+
+		if err == nil {
+			continue
+		}
+		if s.isClosed() {
+			return 0, errStreamClosed
+		}
+		if err := s.reconnect(err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reconnect connects again after the connection failed with cause: a file
+// from where it broke off, a live stream from what plays now
+func (s *httpSource) reconnect(cause error) error {
+	s.hangUp()
+	sendNews(s.news, StreamNews{Path: s.url, Notice: "Stream interrupted, reconnecting"})
+
+	err := cause
+	for attempt := 1; attempt <= streamRetries; attempt++ {
+		select {
+		case <-s.closed:
+			return errStreamClosed
+		case <-time.After(streamRetryDelay):
+		}
+
+		offset := s.offset
+		if !s.seekable {
+			offset = 0
+		}
+		if err = s.dial(offset); err == nil {
+			sendNews(s.news, StreamNews{Path: s.url, Notice: "Stream reconnected"})
+			return nil
+		}
+	}
+	return fmt.Errorf("stream lost, %d attempts to reconnect failed: %w", streamRetries, err)
+}
+
+// Seek moves to offset, connecting again on the next read
+func (s *httpSource) Seek(offset int64, whence int) (int64, error) {
+	if !s.seekable {
+		return s.offset, ErrStreamNotSeekable
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 {
+		return s.offset, errors.New("seek before the start of the stream")
+	}
+	if offset != s.offset {
+		s.hangUp()
+		s.offset = offset
+	}
+	return offset, nil
+}
+
+// hangUp drops the connection, it must not be called during a read
+func (s *httpSource) hangUp() {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.body, s.conn = nil, nil
+}
+
+// Close ends any read under way and keeps new connections from being made
+func (s *httpSource) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		close(s.closed)
+		if s.cancel != nil {
+			s.cancel()
+		}
+		s.mu.Unlock()
+	})
+	return nil
+}
+
+// isClosed reports whether Close was called
+func (s *httpSource) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// stallGuard cuts a connection off when a read gets nothing for
+// streamTimeout
+type stallGuard struct {
+	r      io.Reader
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+// Read reads with the guard armed
+func (g *stallGuard) Read(p []byte) (int, error) {
+	if g.timer == nil {
+		g.timer = time.AfterFunc(streamTimeout, g.cancel)
+	} else {
+		g.timer.Reset(streamTimeout)
+	}
+	defer g.timer.Stop()
+	return g.r.Read(p)
+}
+
+// icyReader takes out the metadata an internet radio station sends every
+// metaint bytes of audio, passing the titles in it on
+type icyReader struct {
+	r       *bufio.Reader
+	metaint int
+	left    int // bytes of audio before the next metadata
+	title   func(string)
+}
+
+// Read reads audio up to the next metadata
+func (ic *icyReader) Read(p []byte) (int, error) {
+	if ic.left == 0 {
+		if err := ic.readMeta(); err != nil {
+			return 0, err
+		}
+		ic.left = ic.metaint
+	}
+	n, err := ic.r.Read(p[:min(len(p), ic.left)])
+	ic.left -= n
+	return n, err
+}
+
+// readMeta reads a metadata block: its length in 16 byte units, then the
+// metadata, none when the length is 0
+func (ic *icyReader) readMeta() error {
+	size, err := ic.r.ReadByte()
+	if err != nil || size == 0 {
+		return err
+	}
+	meta := make([]byte, int(size)*16)
+	if _, err := io.ReadFull(ic.r, meta); err != nil {
+		return err
+	}
+	if title, ok := icyTitle(string(meta)); ok {
+		ic.title(title)
+	}
+	return nil
+}
+
+// icyTitle returns the StreamTitle of metadata such as
+// "StreamTitle='Artist - Title';StreamUrl=”;", padded with zeros.
+// Stations that don't send UTF-8 mostly send Latin-1.
+func icyTitle(meta string) (string, bool) {
+	_, rest, ok := strings.Cut(meta, "StreamTitle='")
+	if !ok {
+		return "", false
+	}
+	title, _, ok := strings.Cut(rest, "';")
+	if !ok {
+		title = strings.TrimRight(rest, "\x00';")
+	}
+	if !utf8.ValidString(title) {
+		runes := make([]rune, len(title))
+		for i := range len(title) {
+			runes[i] = rune(title[i])
+		}
+		title = string(runes)
+	}
+	return strings.TrimSpace(title), true
+}
+
+// splitStreamTitle splits the "Artist - Title" most stations announce
+func splitStreamTitle(title string) (artist, name string) {
+	if artist, name, ok := strings.Cut(title, " - "); ok {
+		return strings.TrimSpace(artist), strings.TrimSpace(name)
+	}
+	return "", title
+}
+
+// netStreamer decodes a stream ahead of the speaker in a goroutine of its
+// own, so the speaker never waits on the network: while nothing has
+// arrived it plays silence. Seeks happen in that goroutine too, Position
+// reports where one goes straight away.
+type netStreamer struct {
+	decoder beep.StreamSeekCloser
+	source  *httpSource
+	length  int // 0 for live streams
+	limit   int // samples decoded ahead at most
+
+	mu       sync.Mutex
+	wake     *sync.Cond
+	buf      [][2]float64
+	pos      int
+	seek     int // sample to seek to, or -1
+	starved  bool
+	done     bool
+	err      error
+	closed   bool
+	finished chan struct{}
+}
+
+// newNetStreamer starts decoding ahead
+func newNetStreamer(decoder beep.StreamSeekCloser, source *httpSource, format beep.Format) *netStreamer {
+	s := &netStreamer{
+		decoder:  decoder,
+		source:   source,
+		limit:    format.SampleRate.N(streamBuffer),
+		seek:     -1,
+		finished: make(chan struct{}),
+	}
+	if source.seekable {
+		s.length = decoder.Len()
+	}
+	s.wake = sync.NewCond(&s.mu)
+	go s.fill()
+	return s
+}
+
+// fill decodes until the stream ends or is closed, waiting while the
+// buffer is full. A decoder panic ends the stream with it as its error.
+func (s *netStreamer) fill() {
+	defer close(s.finished)
+	defer func() {
+		if r := recover(); r != nil {
+			s.mu.Lock()
+			s.done, s.err = true, decoderPanic(r)
+			s.mu.Unlock()
+		}
+	}()
+
+	chunk := make([][2]float64, streamChunk)
+	for {
+		s.mu.Lock()
+		for !s.closed && s.seek < 0 && (s.done || len(s.buf) >= s.limit) {
+			s.wake.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		seek := s.seek
+		s.seek = -1
+		s.mu.Unlock()
+
+		if seek >= 0 {
+			err := s.decoder.Seek(seek)
+			s.mu.Lock()
+			if s.seek < 0 {
+				s.buf = s.buf[:0]
+				s.done, s.err = err != nil, err
+			}
+			s.mu.Unlock()
+			continue
+		}
+
+		n, ok := s.decoder.Stream(chunk)
+		s.mu.Lock()
+		// What was decoded before a seek came in is dropped
+		if s.seek < 0 {
+			s.buf = append(s.buf, chunk[:n]...)
+			if !ok {
+				s.done, s.err = true, s.decoder.Err()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stream streams what was decoded, and silence while waiting for more
+func (s *netStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n = copy(samples, s.buf)
+	s.buf = s.buf[n:]
+	s.pos += n
+	s.wake.Signal()
+	if n == len(samples) {
+		s.starved = false
+		return n, true
+	}
+	if s.done {
+		return n, n > 0
+	}
+	s.starved = true
+	clear(samples[n:])
+	return len(samples), true
+}
+
+// Len returns the length in samples, 0 for live streams
+func (s *netStreamer) Len() int {
+	return s.length
+}
+
+// Position returns the position in samples, not counting silence played
+// while waiting for the stream
+func (s *netStreamer) Position() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pos
+}
+
+// Seek seeks to sample p, see netStreamer
+func (s *netStreamer) Seek(p int) error {
+	if !s.source.seekable {
+		return ErrStreamNotSeekable
+	}
+	if p < 0 || p > s.length {
+		return fmt.Errorf("seek position %v out of range [%v, %v]", p, 0, s.length)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seek, s.pos = p, p
+	s.buf = s.buf[:0]
+	s.done, s.err = false, nil
+	s.wake.Signal()
+	return nil
+}
+
+// Err returns the error the stream ended with, once what was decoded
+// before it has played
+func (s *netStreamer) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) > 0 {
+		return nil
+	}
+	return s.err
+}
+
+// buffering reports whether the speaker is waiting for the stream
+func (s *netStreamer) buffering() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.starved && !s.done
+}
+
+// Close stops decoding and closes the connection
+func (s *netStreamer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.wake.Signal()
+	s.mu.Unlock()
+
+	s.source.Close()
+	<-s.finished
+	s.source.hangUp()
+	return s.decoder.Close()
+}
+
+// openStream opens and decodes the stream at url. Tags are only read from
+// files that can seek, from their first streamTagPrefix bytes; for live
+// streams the station's name stands in until it announces a title. The
+// length of an MP3 file is worked out from its size, see mp3Stream, where
+// its decoder would download it in full to count its frames.
+func openStream(codecs *codec.Registry, url string, crashes chan<- Crash, news chan<- StreamNews) (lt *loadedTrack, err error) {
+	source, err := newHTTPSource(url, news)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			lt, err = nil, decoderPanic(r)
+		}
+		if err != nil {
+			source.Close()
+			source.hangUp()
+		}
+	}()
+
+	ext, err := streamExt(codecs, url, source.header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	lt = &loadedTrack{path: url, file: source, title: streamName(url)}
+	if name := source.header.Get("Icy-Name"); name != "" {
+		lt.title = name
+	}
+	lt.info.Genre = source.header.Get("Icy-Genre")
+
+	var r io.ReadCloser = struct{ io.ReadCloser }{source}
+	var prefix []byte
+	if source.seekable {
+		prefix, err = io.ReadAll(io.LimitReader(source, streamTagPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+		if tags, err := tag.ReadFrom(bytes.NewReader(prefix)); err == nil {
+			lt.readTags(tags)
+		}
+		if _, err := source.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek stream: %w", err)
+		}
+		r = source
+	}
+
+	var decoder beep.StreamSeekCloser
+	var format beep.Format
+	if source.seekable && ext == ".mp3" {
+		decoder, format, err = decodeMP3Stream(source, prefix)
+	} else {
+		decoder, format, err = codecs.Decode(r, ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stream := newNetStreamer(decoder, source, format)
+	lt.streamer = &loopStreamer{StreamSeekCloser: &guardedStreamer{StreamSeekCloser: stream, path: url, crashes: crashes}}
+	lt.format = format
+
+	lt.info.Format = strings.ToUpper(strings.TrimPrefix(ext, "."))
+	lt.info.SampleRate = int(format.SampleRate)
+	lt.info.Channels = format.NumChannels
+	lt.info.BitDepth = format.Precision * 8
+	if source.seekable {
+		lt.info.Size = source.size
+		if seconds := format.SampleRate.D(stream.Len()).Seconds(); seconds > 0 {
+			lt.info.Bitrate = int(float64(lt.info.Size*8) / seconds / 1000)
+		}
+	} else if kbps, err := strconv.Atoi(source.header.Get("Icy-Br")); err == nil {
+		lt.info.Bitrate = kbps
+	}
+	return lt, nil
+}
+
+// streamExt returns the extension of the format of a stream: the one of
+// its URL when that is an audio file's, or else the one its content type
+// stands for
+func streamExt(codecs *codec.Registry, rawURL, contentType string) (string, error) {
+	if u, err := url.Parse(rawURL); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); codecs.IsAudioFile(ext) {
+			return ext, nil
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := streamTypes[mediaType]; ok {
+		return ext, nil
+	}
+	return "", fmt.Errorf("unsupported stream type: %q", contentType)
+}
+
+// streamName returns the file name a URL ends in, or its host
+func streamName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return u.Host
+}
+
+// netStream returns the stream the current track plays from, or nil for a
+// file, the caller must hold ap.mu
+func (ap *AudioPlayer) netStream() *netStreamer {
+	loop, ok := ap.streamer.(*loopStreamer)
+	if !ok {
+		return nil
+	}
+	guarded, ok := loop.StreamSeekCloser.(*guardedStreamer)
+	if !ok {
+		return nil
+	}
+	stream, _ := guarded.StreamSeekCloser.(*netStreamer)
+	return stream
+}
+
+// Buffering reports whether the current track is a stream that has run
+// dry, e.g. while it reconnects
+func (ap *AudioPlayer) Buffering() bool {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.adoptSwitched()
+	stream := ap.netStream()
+	return stream != nil && stream.buffering()
+}
+
+// StreamNews returns the channel the titles and connection notices of
+// streams are sent on
+func (ap *AudioPlayer) StreamNews() <-chan StreamNews {
+	return ap.news
+}
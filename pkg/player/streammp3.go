@@ -0,0 +1,222 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gopxl/beep"
+	"github.com/gopxl/beep/mp3"
+)
+
+// MP3 bitrates in kbps by bitrate index, for Layer III of MPEG-1 and of
+// MPEG-2 and 2.5
+var (
+	mp3Bitrates1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3Bitrates2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+)
+
+// mp3SampleRates are the sample rates of MPEG-1 by index; MPEG-2 halves
+// them and MPEG-2.5 quarters them
+var mp3SampleRates = [3]int{44100, 48000, 32000}
+
+// mp3Layout is what the start of an MP3 file tells of the rest: where
+// the audio starts after the ID3v2 tag, and the bitrate and sample rate of
+// its first frame. A Xing or Info header in that frame counts the frames,
+// which is exact where the bitrate of a VBR file is not.
+type mp3Layout struct {
+	dataStart       int64
+	kbps            int
+	sampleRate      int
+	samplesPerFrame int
+	frames          int64 // 0 when not known
+}
+
+// readMP3Layout reads the layout of an MP3 file from the first bytes of it
+func readMP3Layout(prefix []byte) (mp3Layout, error) {
+	var l mp3Layout
+	if len(prefix) >= 10 && string(prefix[:3]) == "ID3" {
+		size := int64(prefix[6]&0x7f)<<21 | int64(prefix[7]&0x7f)<<14 | int64(prefix[8]&0x7f)<<7 | int64(prefix[9]&0x7f)
+		l.dataStart = 10 + size
+		if prefix[5]&0x10 != 0 {
+			l.dataStart += 10 // footer
+		}
+	}
+
+	for at := l.dataStart; at+4 <= int64(len(prefix)); at++ {
+		header := binary.BigEndian.Uint32(prefix[at:])
+		if !l.readFrameHeader(header) {
+			continue
+		}
+		l.dataStart = at
+		l.frames = xingFrames(prefix[at:], header)
+		return l, nil
+	}
+	return l, errors.New("no MP3 frame found at the start of the stream")
+}
+
+// readFrameHeader fills in l from the header of a Layer III frame,
+// reporting whether header is one
+func (l *mp3Layout) readFrameHeader(header uint32) bool {
+	if header>>21 != 0x7ff {
+		return false
+	}
+	version := header >> 19 & 3 // 0 MPEG-2.5, 2 MPEG-2, 3 MPEG-1
+	layer := header >> 17 & 3   // 1 Layer III
+	bitrate := header >> 12 & 15
+	rate := header >> 10 & 3
+	if version == 1 || layer != 1 || bitrate == 0 || bitrate == 15 || rate == 3 {
+		return false
+	}
+
+	switch version {
+	case 3:
+		l.kbps = mp3Bitrates1[bitrate]
+		l.sampleRate = mp3SampleRates[rate]
+		l.samplesPerFrame = 1152
+	case 2:
+		l.kbps = mp3Bitrates2[bitrate]
+		l.sampleRate = mp3SampleRates[rate] / 2
+		l.samplesPerFrame = 576
+	default:
+		l.kbps = mp3Bitrates2[bitrate]
+		l.sampleRate = mp3SampleRates[rate] / 4
+		l.samplesPerFrame = 576
+	}
+	return true
+}
+
+// xingFrames returns the number of frames a Xing or Info header in the
+// frame at the start of frame gives, or 0 if it has none
+func xingFrames(frame []byte, header uint32) int64 {
+	mono := header>>6&3 == 3
+	offset := 4 + 17
+	switch {
+	case header>>19&3 == 3 && !mono:
+		offset = 4 + 32
+	case header>>19&3 != 3 && mono:
+		offset = 4 + 9
+	}
+	if len(frame) < offset+12 {
+		return 0
+	}
+	if id := string(frame[offset : offset+4]); id != "Xing" && id != "Info" {
+		return 0
+	}
+	if binary.BigEndian.Uint32(frame[offset+4:])&1 == 0 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint32(frame[offset+8:]))
+}
+
+// length returns the length in samples of a file of size bytes: counted
+// from its frames when the Xing header gives them, or else worked out
+// from the bitrate of its first frame
+func (l mp3Layout) length(size int64) int {
+	if l.frames > 0 {
+		return int(l.frames * int64(l.samplesPerFrame))
+	}
+	if size <= l.dataStart {
+		return 0
+	}
+	return int((size - l.dataStart) * 8 * int64(l.sampleRate) / int64(l.kbps*1000))
+}
+
+// mp3Stream plays an MP3 file over HTTP that can seek. The decoder finds
+// the length of a file it can seek by reading every frame of it, which
+// would download it in full before it plays, so it's given a reader that
+// can't seek, the length comes from the size of the file and its layout,
+// and a seek starts a new decoder at the byte it works out the position
+// to be at. That's exact for files of a constant bitrate and close for
+// the rest.
+type mp3Stream struct {
+	source  *httpSource
+	layout  mp3Layout
+	len     int
+	decoder beep.StreamSeekCloser // nil once seeked to the end
+	pos     int
+}
+
+// decodeMP3Stream decodes source, an MP3 file of which prefix is the
+// start. One whose first frame is past prefix, behind a large tag, is
+// left to the decoder to scan.
+func decodeMP3Stream(source *httpSource, prefix []byte) (beep.StreamSeekCloser, beep.Format, error) {
+	layout, err := readMP3Layout(prefix)
+	if err != nil {
+		decoder, format, err := mp3.Decode(source)
+		if err != nil {
+			return nil, beep.Format{}, fmt.Errorf("failed to decode audio: %w", err)
+		}
+		return decoder, format, nil
+	}
+	s := &mp3Stream{source: source, layout: layout, len: layout.length(source.size)}
+	format, err := s.open()
+	if err != nil {
+		return nil, beep.Format{}, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	return s, format, nil
+}
+
+// open starts a decoder where the source is
+func (s *mp3Stream) open() (beep.Format, error) {
+	decoder, format, err := mp3.Decode(io.NopCloser(struct{ io.Reader }{s.source}))
+	if err != nil {
+		return beep.Format{}, err
+	}
+	s.decoder = decoder
+	return format, nil
+}
+
+func (s *mp3Stream) Stream(samples [][2]float64) (n int, ok bool) {
+	if s.decoder == nil {
+		return 0, false
+	}
+	n, ok = s.decoder.Stream(samples)
+	s.pos += n
+	return n, ok
+}
+
+func (s *mp3Stream) Err() error {
+	if s.decoder == nil {
+		return nil
+	}
+	return s.decoder.Err()
+}
+
+func (s *mp3Stream) Len() int {
+	return s.len
+}
+
+func (s *mp3Stream) Position() int {
+	return s.pos
+}
+
+// Seek starts decoding from the frame at or after where sample p would be
+// if the file had a constant bitrate
+func (s *mp3Stream) Seek(p int) error {
+	if p < 0 || p > s.len {
+		return fmt.Errorf("seek position %v out of range [%v, %v]", p, 0, s.len)
+	}
+	if p == s.len {
+		s.decoder, s.pos = nil, p
+		return nil
+	}
+	offset := s.layout.dataStart
+	if s.len > 0 {
+		offset += (s.source.size - s.layout.dataStart) * int64(p) / int64(s.len)
+	}
+	if _, err := s.source.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s.open(); err != nil {
+		return err
+	}
+	s.pos = p
+	return nil
+}
+
+// Close closes the source
+func (s *mp3Stream) Close() error {
+	return s.source.Close()
+}
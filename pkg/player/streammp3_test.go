@@ -0,0 +1,192 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// A silent MPEG-1 Layer III frame, 128 kbps at 44.1 kHz in stereo: its
+// side information is all zeroes, so it has no audio data to decode
+const (
+	testMP3Header    = 0xfffb9000
+	testMP3FrameSize = 144 * 128000 / 44100
+)
+
+// testMP3 returns an MP3 file of frames silent frames, behind an ID3v2
+// tag of tagSize bytes if that isn't 0, with a Xing header counting
+// xing frames in the first if that isn't 0
+func testMP3(tagSize, frames, xing int) []byte {
+	var b bytes.Buffer
+	if tagSize > 0 {
+		size := tagSize - 10
+		b.Write([]byte{'I', 'D', '3', 4, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)})
+		b.Write(make([]byte, size))
+	}
+	for i := range frames {
+		frame := make([]byte, testMP3FrameSize)
+		binary.BigEndian.PutUint32(frame, testMP3Header)
+		if i == 0 && xing > 0 {
+			copy(frame[36:], "Xing")
+			binary.BigEndian.PutUint32(frame[40:], 1)
+			binary.BigEndian.PutUint32(frame[44:], uint32(xing))
+		}
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func TestReadMP3Layout(t *testing.T) {
+	mpeg2 := make([]byte, 64)
+	binary.BigEndian.PutUint32(mpeg2, 0xfff3_8000|1<<6) // MPEG-2, 64 kbps, 22.05 kHz
+
+	tests := []struct {
+		name   string
+		prefix []byte
+		want   mp3Layout
+		size   int64
+		length int
+	}{
+		{
+			name:   "constant bitrate",
+			prefix: testMP3(0, 2, 0),
+			want:   mp3Layout{kbps: 128, sampleRate: 44100, samplesPerFrame: 1152},
+			size:   128000 / 8 * 10,
+			length: 441000,
+		},
+		{
+			name:   "behind an ID3v2 tag",
+			prefix: testMP3(1000, 2, 0),
+			want:   mp3Layout{dataStart: 1000, kbps: 128, sampleRate: 44100, samplesPerFrame: 1152},
+			size:   1000 + 128000/8*2,
+			length: 88200,
+		},
+		{
+			name:   "Xing header",
+			prefix: testMP3(0, 2, 500),
+			want:   mp3Layout{kbps: 128, sampleRate: 44100, samplesPerFrame: 1152, frames: 500},
+			size:   1 << 30,
+			length: 500 * 1152,
+		},
+		{
+			name:   "garbage before the first frame",
+			prefix: append([]byte{0xff, 0x00, 0x12}, testMP3(0, 1, 0)...),
+			want:   mp3Layout{dataStart: 3, kbps: 128, sampleRate: 44100, samplesPerFrame: 1152},
+			size:   3,
+			length: 0,
+		},
+		{
+			name:   "MPEG-2",
+			prefix: mpeg2,
+			want:   mp3Layout{kbps: 64, sampleRate: 22050, samplesPerFrame: 576},
+			size:   64000 / 8 * 3,
+			length: 66150,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readMP3Layout(tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("readMP3Layout() = %+v, want %+v", got, tt.want)
+			}
+			if length := got.length(tt.size); length != tt.length {
+				t.Errorf("length(%d) = %d, want %d", tt.size, length, tt.length)
+			}
+		})
+	}
+}
+
+func TestReadMP3LayoutNoFrame(t *testing.T) {
+	for _, prefix := range [][]byte{nil, []byte("not an mp3 file"), testMP3(4096, 0, 0)} {
+		if l, err := readMP3Layout(prefix); err == nil {
+			t.Errorf("readMP3Layout(%q...) = %+v, want an error", prefix[:min(len(prefix), 8)], l)
+		}
+	}
+}
+
+// TestOpenStreamMP3DoesNotDownloadAll plays an MP3 file served with
+// ranges, which the decoder would read in full to count its frames. The
+// server holds back the second half of the file until it has started.
+func TestOpenStreamMP3DoesNotDownloadAll(t *testing.T) {
+	const frames = 5000
+	file := testMP3(2048, frames, 0)
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := &heldReader{r: bytes.NewReader(file), limit: int64(len(file) / 2), open: started, done: r.Context().Done()}
+		http.ServeContent(w, r, "song.mp3", time.Time{}, content)
+	}))
+	defer server.Close()
+
+	opened := make(chan error, 1)
+	var lt *loadedTrack
+	go func() {
+		var err error
+		lt, err = openStream(codec.New(), server.URL+"/song.mp3", nil, nil)
+		opened <- err
+	}()
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		close(started)
+		t.Fatal("the stream didn't start before the whole file was downloaded")
+	}
+	close(started)
+	defer lt.streamer.Close()
+
+	// The frames are a byte short of the bitrate, as no padding is set
+	want := frames * 1152
+	if got := lt.streamer.Len(); got < want*99/100 || got > want*101/100 {
+		t.Errorf("Len() = %d, want %d within 1%%", got, want)
+	}
+
+	seekTo := lt.streamer.Len() / 2
+	if err := lt.streamer.Seek(seekTo); err != nil {
+		t.Fatal(err)
+	}
+	samples := make([][2]float64, 1152)
+	deadline := time.Now().Add(5 * time.Second)
+	for lt.streamer.Position() == seekTo && time.Now().Before(deadline) {
+		if _, ok := lt.streamer.Stream(samples); !ok {
+			t.Fatal("stream ended after seeking to the middle")
+		}
+	}
+	if pos := lt.streamer.Position(); pos <= seekTo {
+		t.Errorf("Position() = %d after seeking to %d and playing", pos, seekTo)
+	}
+}
+
+// heldReader reads r, holding reads past limit until open or done is
+// closed
+type heldReader struct {
+	r     *bytes.Reader
+	limit int64
+	open  <-chan struct{}
+	done  <-chan struct{}
+}
+
+func (h *heldReader) Read(p []byte) (int, error) {
+	if pos, _ := h.r.Seek(0, io.SeekCurrent); pos+int64(len(p)) > h.limit {
+		select {
+		case <-h.open:
+		case <-h.done:
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	return h.r.Read(p)
+}
+
+func (h *heldReader) Seek(offset int64, whence int) (int64, error) {
+	return h.r.Seek(offset, whence)
+}
@@ -0,0 +1,93 @@
+package player
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// fileTags is what readFileTags found: the tags, nil when the file has
+// none, and the chapters and lyrics. err is set when the tag reader
+// panicked on a malformed file.
+type fileTags struct {
+	tags     tag.Metadata
+	chapters []library.Chapter
+	lyrics   library.Lyrics
+	err      error
+}
+
+// readFileTags reads the tags, chapters and lyrics of a file through a
+// handle of its own, see openTrack. A file that can't be opened a second
+// time is taken to have no tags.
+func readFileTags(path string) (ft fileTags) {
+	defer func() {
+		if r := recover(); r != nil {
+			ft = fileTags{err: decoderPanic(r)}
+		}
+	}()
+
+	file, err := os.Open(fspath.Long(path))
+	if err == nil {
+		defer file.Close()
+		if tags, err := tag.ReadFrom(file); err == nil {
+			ft.tags = tags
+		}
+	}
+	ft.chapters = readChapters(path, file, ft.tags)
+	ft.lyrics = readLyrics(path, ft.tags)
+	return ft
+}
+
+// readChapters returns the chapters of a track from its tags, then from a
+// cue sheet next to it, e.g. album.cue or album.flac.cue, then from the
+// cue sheet block of a FLAC file. file is read from where it is.
+func readChapters(path string, file *os.File, tags tag.Metadata) []library.Chapter {
+	if tags != nil {
+		if chapters := library.ReadChapters(tags); chapters != nil {
+			return chapters
+		}
+	}
+
+	ext := filepath.Ext(path)
+	for _, cue := range []string{strings.TrimSuffix(path, ext) + ".cue", path + ".cue"} {
+		f, err := os.Open(fspath.Long(cue))
+		if err != nil {
+			continue
+		}
+		chapters, err := library.ParseCue(f, filepath.Base(path))
+		f.Close()
+		if err == nil && chapters != nil {
+			return chapters
+		}
+	}
+
+	if strings.EqualFold(ext, ".flac") {
+		if _, err := file.Seek(0, 0); err == nil {
+			chapters, _ := library.ReadFLACCueSheet(file)
+			return chapters
+		}
+	}
+	return nil
+}
+
+// readLyrics returns the lyrics of a track from an LRC file next to it,
+// e.g. song.lrc for song.flac, and otherwise from its tags: the ID3 USLT
+// frame or the LYRICS comment
+func readLyrics(path string, tags tag.Metadata) library.Lyrics {
+	if f, err := os.Open(fspath.Long(strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc")); err == nil {
+		lines, err := library.ParseLRC(f)
+		f.Close()
+		if err == nil && len(lines) > 0 {
+			return library.Lyrics{Lines: lines, Timed: true}
+		}
+	}
+	if tags == nil {
+		return library.Lyrics{}
+	}
+	return library.ParseLyrics(tags.Lyrics())
+}
@@ -0,0 +1,53 @@
+package player
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+
+	"github.com/punkscience/dirplay/internal/library"
+)
+
+// lyricsTags are tags holding only lyrics
+type lyricsTags struct {
+	tag.Metadata
+	lyrics string
+}
+
+func (t lyricsTags) Lyrics() string { return t.lyrics }
+
+func TestReadLyrics(t *testing.T) {
+	timed := library.Lyrics{Lines: []library.LyricLine{{At: time.Second, Text: "From the file"}}, Timed: true}
+	tests := []struct {
+		name string
+		// lrc is what song.lrc holds, "" for no file
+		lrc  string
+		tags tag.Metadata
+		want library.Lyrics
+	}{
+		{"nothing", "", nil, library.Lyrics{}},
+		{"no lyrics in the tags", "", lyricsTags{}, library.Lyrics{}},
+		{"file", "[00:01.00]From the file", nil, timed},
+		{"file over tags", "[00:01.00]From the file", lyricsTags{lyrics: "From the tags"}, timed},
+		{"file without stamps", "From the file", lyricsTags{lyrics: "From the tags"}, library.Lyrics{Lines: []library.LyricLine{{Text: "From the tags"}}}},
+		{"untimed tags", "", lyricsTags{lyrics: "One\nTwo"}, library.Lyrics{Lines: []library.LyricLine{{Text: "One"}, {Text: "Two"}}}},
+		{"timed tags", "", lyricsTags{lyrics: "[00:02]Timed"}, library.Lyrics{Lines: []library.LyricLine{{At: 2 * time.Second, Text: "Timed"}}, Timed: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.lrc != "" {
+				if err := os.WriteFile(filepath.Join(dir, "song.lrc"), []byte(tt.lrc), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := readLyrics(filepath.Join(dir, "song.flac"), tt.tags); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readLyrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
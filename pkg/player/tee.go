@@ -0,0 +1,24 @@
+package player
+
+import "github.com/gopxl/beep"
+
+// Tee receives a copy of the audio going to the speaker, e.g. to stream it
+// on. Send is called from the speaker's goroutine and must not block.
+type Tee interface {
+	Send(samples [][2]float64)
+}
+
+// teeTap passes audio to the speaker, copying it to the tee on the way.
+// It wraps the pause control, so a paused player streams silence and
+// listeners stay connected.
+type teeTap struct {
+	beep.Streamer
+	tee Tee
+}
+
+// Stream fills samples and copies them to the tee
+func (t *teeTap) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = t.Streamer.Stream(samples)
+	t.tee.Send(samples[:n])
+	return n, ok
+}
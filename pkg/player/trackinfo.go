@@ -0,0 +1,38 @@
+package player
+
+import (
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/tags"
+)
+
+// TrackInfo is the extended metadata of a track, as dirplay's info panel
+// shows it. Zero values mean the field is unknown.
+type TrackInfo struct {
+	Genre      string
+	Year       int
+	Disc       int
+	DiscTotal  int
+	Track      int
+	TrackTotal int
+	Composer   string
+	Format     string
+	SampleRate int
+	Channels   int
+	BitDepth   int // of the decoded samples
+	Bitrate    int // average, in kbps
+	Size       int64
+
+	// Length claimed by the tags (ID3 TLEN), compared with the decoded
+	// length to spot truncated files
+	TagDuration time.Duration
+
+	// Loudness adjustments from the ReplayGain tags
+	ReplayGain tags.ReplayGain
+
+	// Chapters or cue sheet tracks within the file, see readChapters
+	Chapters []tags.Chapter
+
+	// Lyrics from the tags or an LRC file, see readLyrics
+	Lyrics tags.Lyrics
+}
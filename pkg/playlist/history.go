@@ -0,0 +1,51 @@
+package playlist
+
+import "time"
+
+// HistoryEntry is a track that played, and when it started
+type HistoryEntry[T any] struct {
+	Track T
+	At    time.Time
+}
+
+// History keeps the tracks that played, up to a limit, dropping the
+// oldest. T is how the program identifies tracks.
+type History[T any] struct {
+	limit   int
+	entries []HistoryEntry[T]
+}
+
+// NewHistory returns an empty history of at most limit tracks
+func NewHistory[T any](limit int) *History[T] {
+	return &History[T]{limit: limit}
+}
+
+// Len returns the number of tracks in the history
+func (h *History[T]) Len() int {
+	return len(h.entries)
+}
+
+// Add records a track that started at at, dropping the oldest entry when
+// the history is full
+func (h *History[T]) Add(track T, at time.Time) {
+	h.entries = append(h.entries, HistoryEntry[T]{Track: track, At: at})
+	if len(h.entries) > h.limit {
+		h.entries = append(h.entries[:0], h.entries[len(h.entries)-h.limit:]...)
+	}
+}
+
+// Pop takes the newest entry off the history, reporting false when it is
+// empty
+func (h *History[T]) Pop() (HistoryEntry[T], bool) {
+	if len(h.entries) == 0 {
+		return HistoryEntry[T]{}, false
+	}
+	entry := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return entry, true
+}
+
+// Recent returns the entry i places back from the newest, which is 0
+func (h *History[T]) Recent(i int) HistoryEntry[T] {
+	return h.entries[len(h.entries)-1-i]
+}
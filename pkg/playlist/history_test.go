@@ -0,0 +1,32 @@
+package playlist
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHistory[int](3)
+	for track := 1; track <= 5; track++ {
+		h.Add(track, start.Add(time.Duration(track)*time.Minute))
+	}
+	if h.Len() != 3 {
+		t.Fatalf("Len() = %d, want the limit of 3", h.Len())
+	}
+	for row, want := range []int{5, 4, 3} {
+		if got := h.Recent(row); got.Track != want || !got.At.Equal(start.Add(time.Duration(want)*time.Minute)) {
+			t.Errorf("Recent(%d) = %+v, want track %d", row, got, want)
+		}
+	}
+
+	for _, want := range []int{5, 4, 3} {
+		entry, ok := h.Pop()
+		if !ok || entry.Track != want {
+			t.Errorf("Pop() = %+v, %v, want track %d", entry, ok, want)
+		}
+	}
+	if _, ok := h.Pop(); ok {
+		t.Error("Pop() = true on an empty history")
+	}
+}
@@ -0,0 +1,83 @@
+package playlist
+
+import (
+	"cmp"
+	"path/filepath"
+	"strings"
+)
+
+// DiscTrack is where a track sits on its album by its tags. Zero values
+// are unknown; a track without a disc number counts as on disc 1.
+type DiscTrack struct {
+	Disc  int
+	Track int
+}
+
+// AlbumTrackLess orders the tracks of an album: numbered tracks by disc,
+// then track number, before unnumbered ones, which follow in natural file
+// name order, as do tracks with the same numbers
+func AlbumTrackLess(a, b string, na, nb DiscTrack) bool {
+	if (na.Track == 0) != (nb.Track == 0) {
+		return nb.Track == 0
+	}
+	if da, db := max(na.Disc, 1), max(nb.Disc, 1); da != db {
+		return da < db
+	}
+	if na.Track != nb.Track {
+		return na.Track < nb.Track
+	}
+	return NaturalLess(filepath.Base(a), filepath.Base(b))
+}
+
+// NaturalLess compares file names the way people read them: ignoring
+// case, and with runs of digits compared as numbers, so "Track 2" comes
+// before "Track 10" and "01" ties with "1". Names that only differ in
+// case or leading zeros are ordered byte by byte.
+func NaturalLess(a, b string) bool {
+	if c := NaturalCompare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// NaturalCompare compares a and b as NaturalLess does, without the case
+// folding and the tie break, returning -1, 0 or +1
+func NaturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		if !isDigit(a[0]) || !isDigit(b[0]) {
+			if a[0] != b[0] {
+				return cmp.Compare(a[0], b[0])
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// Numbers compare by their digits without leading zeros, the
+		// longer one being the larger
+		var da, db string
+		da, a = digitRun(a)
+		db, b = digitRun(b)
+		da, db = strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if len(da) != len(db) {
+			return cmp.Compare(len(da), len(db))
+		}
+		if c := strings.Compare(da, db); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// digitRun splits the leading digits off s
+func digitRun(s string) (digits, rest string) {
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	return s[:end], s[end:]
+}
@@ -0,0 +1,123 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"a", "a", 0},
+		{"a", "b", -1},
+		{"track 2", "track 10", -1},
+		{"track 10", "track 2", 1},
+		{"01", "1", 0},
+		{"001", "01", 0},
+		{"007", "8", -1},
+		{"0", "00", 0},
+		{"a2b", "a10b", -1},
+		{"a10b", "a10c", -1},
+		{"x9y9", "x9y10", -1},
+		{"x09y9", "x9y10", -1},
+		{"track", "track 1", -1},
+		{"1", "a", -1},
+		{"12345678901234567890", "12345678901234567891", -1},
+		{"99999999999999999999", "100000000000000000000", -1},
+		{"Track 2", "track 1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := NaturalCompare(tt.a, tt.b); got != tt.want {
+				t.Errorf("NaturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			"numbers",
+			[]string{"Track 10.mp3", "Track 2.mp3", "Track 1.mp3"},
+			[]string{"Track 1.mp3", "Track 2.mp3", "Track 10.mp3"},
+		},
+		{
+			"case ignored",
+			[]string{"Track 10", "track 2", "Track 1"},
+			[]string{"Track 1", "track 2", "Track 10"},
+		},
+		{
+			"leading zeros",
+			[]string{"002 x", "01 b", "1 a"},
+			[]string{"1 a", "01 b", "002 x"},
+		},
+		{
+			"ties broken byte by byte",
+			[]string{"x9y9", "x09y9", "X9Y9"},
+			[]string{"X9Y9", "x09y9", "x9y9"},
+		},
+		{
+			"mixed segments",
+			[]string{"cd2 t1", "cd10 t1", "cd2 t10", "cd2 t2", "cd1"},
+			[]string{"cd1", "cd2 t1", "cd2 t2", "cd2 t10", "cd10 t1"},
+		},
+		{
+			"prefix first",
+			[]string{"intro 1", "intro", "Intro"},
+			[]string{"Intro", "intro", "intro 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Clone(tt.in)
+			slices.SortFunc(got, func(a, b string) int {
+				switch {
+				case NaturalLess(a, b):
+					return -1
+				case NaturalLess(b, a):
+					return 1
+				}
+				return 0
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sorted %q = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlbumTrackLess(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		na, nb DiscTrack
+		want   bool
+	}{
+		{"track number", "/m/b.mp3", "/m/a.mp3", DiscTrack{1, 1}, DiscTrack{1, 2}, true},
+		{"disc first", "/m/01.mp3", "/m/02.mp3", DiscTrack{2, 1}, DiscTrack{1, 2}, false},
+		{"disc 1 before 2", "/m/12.mp3", "/m/01.mp3", DiscTrack{1, 12}, DiscTrack{2, 1}, true},
+		{"no disc counts as 1", "/m/a.mp3", "/m/b.mp3", DiscTrack{0, 3}, DiscTrack{1, 2}, false},
+		{"no disc before disc 2", "/m/a.mp3", "/m/b.mp3", DiscTrack{0, 9}, DiscTrack{2, 1}, true},
+		{"numbered before unnumbered", "/m/z.mp3", "/m/a.mp3", DiscTrack{2, 9}, DiscTrack{}, true},
+		{"unnumbered after numbered", "/m/a.mp3", "/m/z.mp3", DiscTrack{}, DiscTrack{2, 9}, false},
+		{"unnumbered by name", "/m/bonus 9.mp3", "/m/bonus 10.mp3", DiscTrack{}, DiscTrack{}, true},
+		{"same numbers by name", "/m/Track 2.mp3", "/m/Track 10.mp3", DiscTrack{1, 1}, DiscTrack{1, 1}, true},
+		{"by base name", "/z/a.mp3", "/a/b.mp3", DiscTrack{}, DiscTrack{}, true},
+		{"equal", "/m/a.mp3", "/m/a.mp3", DiscTrack{1, 1}, DiscTrack{1, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AlbumTrackLess(tt.a, tt.b, tt.na, tt.nb); got != tt.want {
+				t.Errorf("AlbumTrackLess(%s %+v, %s %+v) = %v, want %v", tt.a, tt.na, tt.b, tt.nb, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package playlist
+
+import "slices"
+
+// Queue holds tracks picked to play next, ahead of the playlist order.
+// The track that was playing when the queue took over is kept to return
+// to; the playlist carries on after it once the queue is empty. T is how
+// the program identifies tracks, its zero value meaning none. The zero
+// Queue is empty.
+type Queue[T comparable] struct {
+	tracks   []T
+	returnTo T
+}
+
+// Len returns the number of tracks queued
+func (q *Queue[T]) Len() int {
+	return len(q.tracks)
+}
+
+// Tracks returns the queued tracks in the order they play. The slice
+// must not be changed.
+func (q *Queue[T]) Tracks() []T {
+	return q.tracks
+}
+
+// Position returns the 1-based queue position of a track, or 0
+func (q *Queue[T]) Position(track T) int {
+	return slices.Index(q.tracks, track) + 1
+}
+
+// Add queues tracks after those already queued
+func (q *Queue[T]) Add(tracks ...T) {
+	q.tracks = append(q.tracks, tracks...)
+}
+
+// Replace queues tracks in place of those queued
+func (q *Queue[T]) Replace(tracks []T) {
+	q.tracks = slices.Clone(tracks)
+}
+
+// Remove takes a track off the queue
+func (q *Queue[T]) Remove(track T) {
+	q.tracks = slices.DeleteFunc(q.tracks, func(t T) bool {
+		return t == track
+	})
+}
+
+// Toggle queues a track, or takes it off the queue if it is on it
+// already, and reports whether it is queued now
+func (q *Queue[T]) Toggle(track T) bool {
+	if i := slices.Index(q.tracks, track); i >= 0 {
+		q.tracks = slices.Delete(q.tracks, i, i+1)
+		return false
+	}
+	q.tracks = append(q.tracks, track)
+	return true
+}
+
+// Next takes the first queued track that playable accepts off the queue
+// and returns it, dropping those before it. When the queue takes over
+// from the playlist, current is kept as the track to return to. With no
+// playable track left it returns false and forgets that track.
+func (q *Queue[T]) Next(current T, playable func(T) bool) (T, bool) {
+	var none T
+	for len(q.tracks) > 0 {
+		track := q.tracks[0]
+		q.tracks = q.tracks[1:]
+		if playable(track) {
+			if q.returnTo == none {
+				q.returnTo = current
+			}
+			return track, true
+		}
+	}
+	q.returnTo = none
+	return none, false
+}
+
+// ReturnTo returns the playlist track to carry on after once the queue
+// is empty, or the zero T when the queue hasn't taken over
+func (q *Queue[T]) ReturnTo() T {
+	return q.returnTo
+}
+
+// Leave forgets the track to return to, for when playback moves
+// elsewhere in the playlist
+func (q *Queue[T]) Leave() {
+	var none T
+	q.returnTo = none
+}
+
+// Clone returns a copy of the queue that changes to q leave alone
+func (q *Queue[T]) Clone() Queue[T] {
+	return Queue[T]{tracks: slices.Clone(q.tracks), returnTo: q.returnTo}
+}
@@ -0,0 +1,95 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueueToggle(t *testing.T) {
+	var q Queue[int]
+	for _, track := range []int{3, 5, 7} {
+		if !q.Toggle(track) {
+			t.Fatalf("Toggle(%d) = false, want it queued", track)
+		}
+	}
+	if q.Toggle(5) {
+		t.Error("Toggle(5) = true for a queued track, want it taken off")
+	}
+	if got := q.Tracks(); !slices.Equal(got, []int{3, 7}) {
+		t.Errorf("Tracks() = %v, want [3 7]", got)
+	}
+	tests := []struct {
+		track, position int
+	}{
+		{3, 1},
+		{7, 2},
+		{5, 0},
+	}
+	for _, tt := range tests {
+		if got := q.Position(tt.track); got != tt.position {
+			t.Errorf("Position(%d) = %d, want %d", tt.track, got, tt.position)
+		}
+	}
+}
+
+func TestQueueAddReplaceRemove(t *testing.T) {
+	var q Queue[string]
+	q.Add("a", "b")
+	q.Add("c")
+	q.Remove("b")
+	if got := q.Tracks(); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("Tracks() = %v, want [a c]", got)
+	}
+
+	replacement := []string{"x", "y"}
+	q.Replace(replacement)
+	replacement[0] = "changed"
+	if got := q.Tracks(); !slices.Equal(got, []string{"x", "y"}) || q.Len() != 2 {
+		t.Errorf("Tracks() = %v after Replace, want [x y]", got)
+	}
+}
+
+// TestQueueNext plays the queue out from track 10 of the playlist,
+// passing over a track no longer playable
+func TestQueueNext(t *testing.T) {
+	var q Queue[int]
+	q.Add(1, 2, 3)
+	playable := func(track int) bool { return track != 2 }
+
+	steps := []struct {
+		current  int
+		want     int
+		ok       bool
+		returnTo int
+	}{
+		{10, 1, true, 10},
+		{1, 3, true, 10},
+		{3, 0, false, 0},
+		{11, 0, false, 0},
+	}
+	for i, step := range steps {
+		got, ok := q.Next(step.current, playable)
+		if got != step.want || ok != step.ok {
+			t.Errorf("step %d: Next(%d) = %d, %v, want %d, %v", i, step.current, got, ok, step.want, step.ok)
+		}
+		if q.ReturnTo() != step.returnTo {
+			t.Errorf("step %d: ReturnTo() = %d, want %d", i, q.ReturnTo(), step.returnTo)
+		}
+	}
+}
+
+func TestQueueLeaveAndClone(t *testing.T) {
+	var q Queue[int]
+	q.Add(1, 2)
+	q.Next(10, func(int) bool { return true })
+
+	saved := q.Clone()
+	q.Leave()
+	q.Add(3)
+	if q.ReturnTo() != 0 {
+		t.Errorf("ReturnTo() = %d after Leave, want 0", q.ReturnTo())
+	}
+	if saved.ReturnTo() != 10 || !slices.Equal(saved.Tracks(), []int{2}) {
+		t.Errorf("clone = %v returning to %d, want [2] returning to 10", saved.Tracks(), saved.ReturnTo())
+	}
+}
@@ -0,0 +1,101 @@
+// Package playlist orders tracks for playback: shuffled track by track or
+// album by album, or sorted by name or modification time. Tracks are file
+// paths, and the tracks of an album are those in the same directory.
+// Queue and History hold the tracks picked to play next and those that
+// played, identified however the program likes.
+package playlist
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Shuffle modes
+const (
+	ShuffleTrack = "track"
+	ShuffleSmart = "smart"
+	ShuffleAlbum = "album"
+)
+
+// AlbumKey returns the album a track belongs to: its directory
+func AlbumKey(track string) string {
+	return filepath.Dir(track)
+}
+
+// Shuffle shuffles tracks in place, each order being equally likely
+func Shuffle(tracks []string) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Fisher-Yates shuffle
+	for i := len(tracks) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	}
+}
+
+// ShuffleWeighted shuffles the order of albums in place, an album of
+// greater weight tending to come up sooner, and keeps the tracks of each
+// album together in natural file name order. Weights must be positive.
+func ShuffleWeighted(tracks []string, weight func(album string) float64) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	albums, order := groupAlbums(tracks)
+
+	// Weighted random order: each album draws u^(1/w) and the largest
+	// draws go first, so heavier albums tend to come up sooner
+	keys := make(map[string]float64, len(order))
+	for _, album := range order {
+		keys[album] = math.Pow(r.Float64(), 1/weight(album))
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return keys[order[i]] > keys[order[j]]
+	})
+
+	layOut(tracks, albums, order, func(a, b string) bool {
+		return NaturalLess(filepath.Base(a), filepath.Base(b))
+	})
+}
+
+// ShuffleAlbums shuffles the order of albums in place and keeps the
+// tracks of each album together, ordered by the disc and track numbers
+// given, see AlbumTrackLess
+func ShuffleAlbums(tracks []string, numbers map[string]DiscTrack) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	albums, order := groupAlbums(tracks)
+	r.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+
+	layOut(tracks, albums, order, func(a, b string) bool {
+		return AlbumTrackLess(a, b, numbers[a], numbers[b])
+	})
+}
+
+// groupAlbums groups tracks by album, returning the albums in the order
+// their first track came up
+func groupAlbums(tracks []string) (map[string][]string, []string) {
+	albums := make(map[string][]string)
+	var order []string
+	for _, track := range tracks {
+		key := AlbumKey(track)
+		if _, ok := albums[key]; !ok {
+			order = append(order, key)
+		}
+		albums[key] = append(albums[key], track)
+	}
+	return albums, order
+}
+
+// layOut writes the albums back into tracks in order, each sorted by less
+func layOut(tracks []string, albums map[string][]string, order []string, less func(a, b string) bool) {
+	i := 0
+	for _, album := range order {
+		group := albums[album]
+		sort.Slice(group, func(a, b int) bool {
+			return less(group[a], group[b])
+		})
+		i += copy(tracks[i:], group)
+	}
+}
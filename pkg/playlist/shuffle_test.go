@@ -0,0 +1,93 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+)
+
+// library is three albums of three tracks, named out of natural order
+var library = []string{
+	"/music/a/10 ten.mp3", "/music/a/2 two.mp3", "/music/a/1 one.mp3",
+	"/music/b/track 3.flac", "/music/b/track 1.flac", "/music/b/track 2.flac",
+	"/music/c/x.ogg", "/music/c/y.ogg", "/music/c/z.ogg",
+}
+
+// albumsTogether checks that each album's tracks come one after another
+// in the order want gives, and returns the albums in the order played
+func albumsTogether(t *testing.T, tracks []string, want map[string][]string) []string {
+	t.Helper()
+	var order []string
+	for i := 0; i < len(tracks); {
+		album := AlbumKey(tracks[i])
+		group := want[album]
+		if i+len(group) > len(tracks) || !slices.Equal(tracks[i:i+len(group)], group) {
+			t.Fatalf("album %s plays as %v, want %v together", album, tracks[i:], group)
+		}
+		order = append(order, album)
+		i += len(group)
+	}
+	return order
+}
+
+func TestShuffle(t *testing.T) {
+	tracks := slices.Clone(library)
+	Shuffle(tracks)
+	slices.Sort(tracks)
+	want := slices.Sorted(slices.Values(library))
+	if !slices.Equal(tracks, want) {
+		t.Errorf("Shuffle lost or added tracks: %v", tracks)
+	}
+}
+
+func TestShuffleAlbums(t *testing.T) {
+	numbers := map[string]DiscTrack{
+		"/music/b/track 3.flac": {Disc: 1, Track: 1},
+		"/music/b/track 1.flac": {Disc: 2, Track: 1},
+		"/music/b/track 2.flac": {Track: 2},
+	}
+	want := map[string][]string{
+		"/music/a": {"/music/a/1 one.mp3", "/music/a/2 two.mp3", "/music/a/10 ten.mp3"},
+		"/music/b": {"/music/b/track 3.flac", "/music/b/track 2.flac", "/music/b/track 1.flac"},
+		"/music/c": {"/music/c/x.ogg", "/music/c/y.ogg", "/music/c/z.ogg"},
+	}
+
+	firsts := make(map[string]bool)
+	for range 50 {
+		tracks := slices.Clone(library)
+		ShuffleAlbums(tracks, numbers)
+		firsts[albumsTogether(t, tracks, want)[0]] = true
+	}
+	if len(firsts) < 2 {
+		t.Errorf("50 shuffles all started with the same album")
+	}
+}
+
+// TestShuffleWeighted puts a heavy album first far more often than light
+// ones
+func TestShuffleWeighted(t *testing.T) {
+	want := map[string][]string{
+		"/music/a": {"/music/a/1 one.mp3", "/music/a/2 two.mp3", "/music/a/10 ten.mp3"},
+		"/music/b": {"/music/b/track 1.flac", "/music/b/track 2.flac", "/music/b/track 3.flac"},
+		"/music/c": {"/music/c/x.ogg", "/music/c/y.ogg", "/music/c/z.ogg"},
+	}
+	weight := func(album string) float64 {
+		if album == "/music/c" {
+			return 20
+		}
+		return 1
+	}
+
+	first := 0
+	const rounds = 500
+	for range rounds {
+		tracks := slices.Clone(library)
+		ShuffleWeighted(tracks, weight)
+		if albumsTogether(t, tracks, want)[0] == "/music/c" {
+			first++
+		}
+	}
+	// Album c comes first with probability 20/22
+	if first < rounds*8/10 {
+		t.Errorf("the heavy album came first %d times in %d, want about %d", first, rounds, rounds*20/22)
+	}
+}
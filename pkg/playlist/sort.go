@@ -0,0 +1,55 @@
+package playlist
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Sort modes. SortShuffle leaves the order to one of the shuffles.
+const (
+	SortShuffle   = "shuffle"
+	SortName      = "name"
+	SortMTime     = "mtime"
+	SortMTimeDesc = "mtime-desc"
+)
+
+// ValidateSort checks a sort mode
+func ValidateSort(mode string) error {
+	switch mode {
+	case SortShuffle, SortName, SortMTime, SortMTimeDesc:
+		return nil
+	}
+	return fmt.Errorf("invalid sort %q (want %s, %s, %s or %s)", mode, SortShuffle, SortName, SortMTime, SortMTimeDesc)
+}
+
+// File is a track with when it was last modified, for Sort
+type File struct {
+	Path    string
+	ModTime time.Time
+}
+
+// Sort orders files by mode and returns their paths. Files that sort the
+// same keep their order, and SortShuffle keeps them all as they are.
+func Sort(files []File, mode string) []string {
+	switch mode {
+	case SortName:
+		sort.SliceStable(files, func(a, b int) bool {
+			return NaturalLess(files[a].Path, files[b].Path)
+		})
+	case SortMTime:
+		sort.SliceStable(files, func(a, b int) bool {
+			return files[a].ModTime.Before(files[b].ModTime)
+		})
+	case SortMTimeDesc:
+		sort.SliceStable(files, func(a, b int) bool {
+			return files[a].ModTime.After(files[b].ModTime)
+		})
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
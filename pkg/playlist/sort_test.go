@@ -0,0 +1,46 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateSort(t *testing.T) {
+	for _, mode := range []string{SortShuffle, SortName, SortMTime, SortMTimeDesc} {
+		if err := ValidateSort(mode); err != nil {
+			t.Errorf("ValidateSort(%q) = %v", mode, err)
+		}
+	}
+	for _, mode := range []string{"", "Name", "size"} {
+		if err := ValidateSort(mode); err == nil {
+			t.Errorf("ValidateSort(%q) = nil, want an error", mode)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	files := []File{
+		{"/m/Track 10.mp3", day(2)},
+		{"/m/track 2.mp3", day(3)},
+		{"/m/Track 1.mp3", day(2)},
+		{"/m/intro.mp3", day(1)},
+	}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{SortShuffle, []string{"/m/Track 10.mp3", "/m/track 2.mp3", "/m/Track 1.mp3", "/m/intro.mp3"}},
+		{SortName, []string{"/m/intro.mp3", "/m/Track 1.mp3", "/m/track 2.mp3", "/m/Track 10.mp3"}},
+		{SortMTime, []string{"/m/intro.mp3", "/m/Track 10.mp3", "/m/Track 1.mp3", "/m/track 2.mp3"}},
+		{SortMTimeDesc, []string{"/m/track 2.mp3", "/m/Track 10.mp3", "/m/Track 1.mp3", "/m/intro.mp3"}},
+	}
+	for _, tt := range tests {
+		if got := Sort(slices.Clone(files), tt.mode); !slices.Equal(got, tt.want) {
+			t.Errorf("Sort(%s) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
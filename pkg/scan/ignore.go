@@ -0,0 +1,122 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+)
+
+// A directory holding either marker file is left out of scans with all
+// it holds, unless a .dirplayignore lists patterns, which then only leave
+// out the paths below it they match
+const (
+	noMediaFile = ".nomedia"
+	ignoreFile  = ".dirplayignore"
+)
+
+// ignoreRule is one line of a .dirplayignore, in the gitignore style:
+// "#" starts a comment, "!" takes a path back in, a trailing "/" only
+// matches directories, and a pattern with a "/" other than at its end is
+// anchored to the directory of the file, where one without matches at any
+// depth. "**" matches any number of directories.
+type ignoreRule struct {
+	base     string
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+// IgnoredError tells how much of a directory argument ignore markers left
+// out of the scan. It isn't a problem as such, but is passed to warn with
+// the other warnings so it can be reported.
+type IgnoredError struct {
+	Root  string
+	Dirs  int
+	Files int
+}
+
+func (e *IgnoredError) Error() string {
+	return fmt.Sprintf("ignored %d directories and %d audio files in %s, see %s and %s", e.Dirs, e.Files, e.Root, noMediaFile, ignoreFile)
+}
+
+// parseIgnoreRules returns the rules of a .dirplayignore in dir. Lines
+// that aren't valid patterns are passed over.
+func parseIgnoreRules(dir string, lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: dir}
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate = true
+			line = rest
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly = true
+			line = rest
+		}
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+
+		rule.segments = strings.Split(line, "/")
+		if !anchored {
+			rule.segments = append([]string{"**"}, rule.segments...)
+		}
+		if _, err := matchSegments(rule.segments, nil); err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// readIgnoreMarkers looks at the entries of dir for the marker files. skip
+// is true when dir is to be left out whole, and rules are the patterns of
+// its .dirplayignore otherwise.
+func readIgnoreMarkers(dir string, entries []os.DirEntry) (rules []ignoreRule, skip bool) {
+	for _, entry := range entries {
+		switch entry.Name() {
+		case noMediaFile:
+			return nil, true
+		case ignoreFile:
+			data, err := os.ReadFile(fspath.Long(filepath.Join(dir, ignoreFile)))
+			if err != nil {
+				// Unreadable, so there is no telling what it wants kept
+				return nil, true
+			}
+			rules = parseIgnoreRules(dir, strings.Split(string(data), "\n"))
+			if len(rules) == 0 {
+				return nil, true
+			}
+		}
+	}
+	return rules, false
+}
+
+// ignoredBy reports whether the rules leave path out. The last rule that
+// matches decides, as with gitignore.
+func ignoredBy(rules []ignoreRule, path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil {
+			continue
+		}
+		if ok, _ := matchSegments(rule.segments, strings.Split(filepath.ToSlash(rel), "/")); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
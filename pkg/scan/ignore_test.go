@@ -0,0 +1,134 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIgnoredBy(t *testing.T) {
+	root := filepath.FromSlash("/music")
+	inner := filepath.Join(root, "rock")
+	tests := []struct {
+		name string
+		// outer and nested are the lines of the .dirplayignore files in
+		// root and in rock below it
+		outer, nested string
+		path          string
+		isDir         bool
+		want          bool
+	}{
+		{"no rules", "", "", "rock/a.mp3", false, false},
+		{"name at any depth", "*.tmp", "", "rock/live/a.tmp", false, true},
+		{"name no match", "*.tmp", "", "rock/live/a.mp3", false, false},
+		{"directory name", "live", "", "rock/live", true, true},
+		{"comment", "# live", "", "rock/live", true, false},
+		{"surrounding spaces", "  live  ", "", "rock/live", true, true},
+		{"directory only, directory", "live/", "", "rock/live", true, true},
+		{"directory only, file", "live/", "", "rock/live", false, false},
+		{"anchored", "/rock", "", "rock", true, true},
+		{"anchored not deeper", "/rock", "", "jazz/rock", true, false},
+		{"path anchored", "rock/live", "", "rock/live", true, true},
+		{"path anchored not deeper", "rock/live", "", "old/rock/live", true, false},
+		{"double star", "rock/**/*.flac", "", "rock/a/b/c.flac", false, true},
+		{"double star none between", "rock/**/*.flac", "", "rock/c.flac", false, true},
+		{"negation", "*.mp3\n!keep.mp3", "", "rock/keep.mp3", false, false},
+		{"negation others", "*.mp3\n!keep.mp3", "", "rock/drop.mp3", false, true},
+		{"last match decides", "!keep.mp3\n*.mp3", "", "rock/keep.mp3", false, true},
+		{"nested adds", "*.tmp", "demo", "rock/demo", true, true},
+		{"nested relative to its directory", "", "/live", "rock/live", true, true},
+		{"nested not above", "", "/live", "live", true, false},
+		{"nested negates outer", "*.mp3", "!a.mp3", "rock/a.mp3", false, false},
+		{"outer applies below nested", "*.mp3", "!a.mp3", "rock/b.mp3", false, true},
+		{"invalid pattern passed over", "[a\nb.mp3", "", "rock/b.mp3", false, true},
+		{"invalid pattern matches nothing", "[a", "", "rock/[a", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseIgnoreRules(root, strings.Split(tt.outer, "\n"))
+			rules = append(rules, parseIgnoreRules(inner, strings.Split(tt.nested, "\n"))...)
+			path := filepath.Join(root, filepath.FromSlash(tt.path))
+			if got := ignoredBy(rules, path, tt.isDir); got != tt.want {
+				t.Errorf("ignoredBy(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCollectIgnore scans a tree with marker files in it
+func TestCollectIgnore(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"music/rock/a.mp3":                   "audio",
+		"music/rock/live/b.mp3":              "audio",
+		"music/rock/live/keep.mp3":           "audio",
+		"music/rock/.dirplayignore":          "# bootlegs\nlive/\n!keep.mp3\n*.wav\n",
+		"music/rock/c.wav":                   "audio",
+		"music/rock/wav/d.mp3":               "audio",
+		"music/ringtones/.nomedia":           "",
+		"music/ringtones/e.mp3":              "audio",
+		"music/ringtones/more/f.mp3":         "audio",
+		"music/samples/.dirplayignore":       "# nothing but comments\n",
+		"music/samples/g.mp3":                "audio",
+		"music/kids/h.mp3":                   "audio",
+		"music/kids/short/.dirplayignore":    "/clips/\n",
+		"music/kids/short/clips/i.mp3":       "audio",
+		"music/kids/short/songs/clips.mp3":   "audio",
+		"music/kids/short/songs/clips/j.mp3": "audio",
+	})
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    []string
+		ignored IgnoredError
+	}{
+		{
+			name: "markers",
+			want: []string{
+				"music/kids/h.mp3", "music/kids/short/songs/clips.mp3", "music/kids/short/songs/clips/j.mp3",
+				"music/rock/a.mp3", "music/rock/wav/d.mp3",
+			},
+			// ringtones, samples, rock/live and kids/short/clips, and
+			// c.wav
+			ignored: IgnoredError{Dirs: 4, Files: 1},
+		},
+		{
+			name:   "no ignore",
+			config: Config{NoIgnore: true},
+			want: []string{
+				"music/kids/h.mp3", "music/kids/short/clips/i.mp3", "music/kids/short/songs/clips.mp3",
+				"music/kids/short/songs/clips/j.mp3", "music/ringtones/e.mp3", "music/ringtones/more/f.mp3",
+				"music/rock/a.mp3", "music/rock/c.wav", "music/rock/live/b.mp3", "music/rock/live/keep.mp3",
+				"music/rock/wav/d.mp3", "music/samples/g.mp3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			music := filepath.Join(root, "music")
+			tracks, warnings := tt.config.Collect(context.Background(), []string{music})
+			if got := relTracks(t, root, tracks); !slices.Equal(got, tt.want) {
+				t.Errorf("tracks = %v, want %v", got, tt.want)
+			}
+
+			var got IgnoredError
+			for _, warning := range warnings {
+				var ignored *IgnoredError
+				if !errors.As(warning, &ignored) {
+					t.Errorf("warning %v", warning)
+					continue
+				}
+				got = *ignored
+			}
+			if tt.ignored.Dirs+tt.ignored.Files > 0 {
+				tt.ignored.Root = music
+			}
+			if got != tt.ignored {
+				t.Errorf("ignored %+v, want %+v", got, tt.ignored)
+			}
+		})
+	}
+}
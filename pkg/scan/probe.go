@@ -0,0 +1,211 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// DefaultProbeWorkers is how many files are probed at once unless
+// Config.ProbeWorkers says otherwise
+const DefaultProbeWorkers = 8
+
+// Reasons the probe leaves a file out of a scan, see SkippedFile
+const (
+	Unreadable  = "unreadable"
+	Duplicate   = "duplicate"
+	Empty       = "empty"
+	Unsupported = "unsupported"
+)
+
+// SkippedFile is a file the probe left out of a scan, and why
+type SkippedFile struct {
+	Path   string
+	Reason string
+	Err    error
+}
+
+// SkippedError tells what the probe left out of a scan: files that can't
+// be opened, including broken symlinks, ones reached before under another
+// path, empty ones, and ones in a container that isn't played, see
+// codec.Registry.UnsupportedError. Like IgnoredError it is passed to warn, once the
+// walk is done.
+type SkippedError struct {
+	Files []SkippedFile
+}
+
+// Count returns how many files were skipped for reason
+func (e *SkippedError) Count(reason string) int {
+	n := 0
+	for _, file := range e.Files {
+		if file.Reason == reason {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary counts the skipped files by reason, as in "skipped 37: 12
+// unreadable, 20 duplicate, 5 empty"
+func (e *SkippedError) Summary() string {
+	var counts []string
+	for _, reason := range []string{Unreadable, Duplicate, Empty, Unsupported} {
+		if n := e.Count(reason); n > 0 {
+			counts = append(counts, fmt.Sprintf("%s %s", library.FormatCount(n), reason))
+		}
+	}
+	return fmt.Sprintf("skipped %s: %s", library.FormatCount(len(e.Files)), strings.Join(counts, ", "))
+}
+
+func (e *SkippedError) Error() string {
+	return e.Summary()
+}
+
+// errNotRegular is why a probe skips a device, pipe or the like
+var errNotRegular = errors.New("not a regular file")
+
+// probe is a track found by a walk on its way to being emitted, or a
+// warning, kept in the order the walk came by them. Files are checked by
+// the probe workers, and done is closed once they are.
+type probe struct {
+	path    string
+	info    os.FileInfo
+	stream  bool
+	warning error
+
+	key  string
+	err  error
+	stat os.FileInfo
+	done chan struct{}
+}
+
+// check resolves the file to its canonical path and makes sure it can be
+// opened, without reading any of it
+func (p *probe) check(codecs *codec.Registry) {
+	defer close(p.done)
+	if p.stream || p.warning != nil {
+		return
+	}
+
+	p.key = CanonicalPath(p.path)
+	if p.err = codecs.UnsupportedError(p.path); p.err != nil {
+		return
+	}
+	info, err := os.Stat(fspath.Long(p.path))
+	if err != nil {
+		p.err = err
+		return
+	}
+	// Opening a named pipe would wait for a writer
+	if !info.Mode().IsRegular() {
+		p.err = errNotRegular
+		return
+	}
+	file, err := os.Open(fspath.Long(p.path))
+	if err != nil {
+		p.err = err
+		return
+	}
+	file.Close()
+	p.stat = info
+}
+
+// probeWorkers returns how many files are probed at once
+func (c Config) probeWorkers() int {
+	if c.ProbeWorkers > 0 {
+		return c.ProbeWorkers
+	}
+	return DefaultProbeWorkers
+}
+
+// probeWalk runs walk, which hands what it finds to add, and probes the
+// files it finds with a pool of workers, as the checks are mostly waiting
+// on the disk or the network. The probes are passed to collect in the
+// order the walk added them, on the calling goroutine.
+func (c Config) probeWalk(ctx context.Context, walk func(add func(*probe)), collect func(*probe)) {
+	workers := c.probeWorkers()
+	jobs := make(chan *probe)
+	// Enough to keep every worker busy while collect waits on the oldest
+	ordered := make(chan *probe, workers*4)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for p := range jobs {
+				p.check(c.Codecs)
+			}
+		})
+	}
+	defer wg.Wait()
+
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		walk(func(p *probe) {
+			p.done = make(chan struct{})
+			select {
+			case ordered <- p:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				close(p.done)
+			}
+		})
+	}()
+
+	for p := range ordered {
+		<-p.done
+		if ctx.Err() == nil {
+			collect(p)
+		}
+	}
+}
+
+// skipReason returns why a probed file is left out, or "" to keep it
+func (p *probe) skipReason() string {
+	switch {
+	case errors.Is(p.err, codec.ErrUnsupportedContainer):
+		return Unsupported
+	case p.err != nil:
+		return Unreadable
+	case p.stat.Size() == 0:
+		return Empty
+	}
+	return ""
+}
+
+// probeError describes why a file was skipped
+func (p *probe) probeError(reason string) error {
+	switch reason {
+	case Unreadable:
+		var pathErr *os.PathError
+		if errors.As(p.err, &pathErr) {
+			return pathErr.Err
+		}
+		return p.err
+	case Unsupported:
+		return p.err
+	}
+	return nil
+}
+
+// skippedWarning returns the files left out, sorted by path, as a
+// warning, or nil when there are none
+func skippedWarning(files []SkippedFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	slices.SortFunc(files, func(a, b SkippedFile) int { return strings.Compare(a.Path, b.Path) })
+	return &SkippedError{Files: files}
+}
@@ -0,0 +1,225 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// writeTree creates files under a temp directory, with content unless it
+// is "", and returns the directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestWalkSkipsUnsupportedContainers(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"album/01.mp3":      "audio",
+		"album/02.m4a":      "audio",
+		"books/book.M4B":    "audio",
+		"album/cover.jpg":   "image",
+		"album/03.flac":     "",
+		"album/notes.txt":   "text",
+		"other/04.opus":     "audio",
+		"other/song.aac":    "audio",
+		"other/video.mkv":   "video",
+		"other/05.ogg":      "audio",
+		"other/06.wav":      "audio",
+		"other/deep/07.mp3": "audio",
+	})
+
+	tracks, warnings := Config{}.Collect(context.Background(), []string{root})
+	if len(tracks) != 5 {
+		t.Errorf("found %d tracks, want 5: %v", len(tracks), tracks)
+	}
+
+	var skipped *SkippedError
+	if len(warnings) != 1 || !errors.As(warnings[0], &skipped) {
+		t.Fatalf("warnings = %v, want a SkippedError", warnings)
+	}
+	for reason, want := range map[string]int{Unsupported: 2, Empty: 1, Unreadable: 0, Duplicate: 0} {
+		if got := skipped.Count(reason); got != want {
+			t.Errorf("Count(%s) = %d, want %d", reason, got, want)
+		}
+	}
+	for _, file := range skipped.Files {
+		if file.Reason == Unsupported && !errors.Is(file.Err, codec.ErrUnsupportedContainer) {
+			t.Errorf("%s skipped with %v, want %v", file.Path, file.Err, codec.ErrUnsupportedContainer)
+		}
+	}
+	if got, want := skipped.Summary(), "skipped 3: 1 empty, 2 unsupported"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestWalkUnsupportedFileArgument(t *testing.T) {
+	root := writeTree(t, map[string]string{"book.m4b": "audio"})
+
+	tracks, warnings := Config{}.Collect(context.Background(), []string{filepath.Join(root, "book.m4b")})
+	if len(tracks) != 0 {
+		t.Errorf("tracks = %v, want none", tracks)
+	}
+	var skipped *SkippedError
+	if len(warnings) != 1 || !errors.As(warnings[0], &skipped) || skipped.Count(Unsupported) != 1 {
+		t.Errorf("warnings = %v, want the file skipped as unsupported", warnings)
+	}
+}
+
+func TestSkippedErrorSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		reasons map[string]int
+		want    string
+	}{
+		{"one", map[string]int{Empty: 1}, "skipped 1: 1 empty"},
+		{"in order", map[string]int{Empty: 5, Unreadable: 12, Duplicate: 20}, "skipped 37: 12 unreadable, 20 duplicate, 5 empty"},
+		{"thousands", map[string]int{Duplicate: 1234, Unsupported: 2}, "skipped 1,236: 1,234 duplicate, 2 unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e SkippedError
+			for reason, n := range tt.reasons {
+				for i := range n {
+					e.Files = append(e.Files, SkippedFile{Path: fmt.Sprintf("/music/%s/%d.mp3", reason, i), Reason: reason})
+				}
+			}
+			if got := e.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			for reason, n := range tt.reasons {
+				if got := e.Count(reason); got != n {
+					t.Errorf("Count(%s) = %d, want %d", reason, got, n)
+				}
+			}
+		})
+	}
+}
+
+// TestCollectProbe scans a tree with files that can't be played in it,
+// and files reached twice, with pools of different sizes
+func TestCollectProbe(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a/01.mp3":      "audio",
+		"a/02 é ü.mp3":  "audio",
+		"a/03.mp3":      "audio",
+		"a/empty.mp3":   "",
+		"b/04.mp3":      "audio",
+		"b/notes.txt":   "text",
+		"c/deep/05.mp3": "audio",
+	})
+	links := map[string]string{
+		"b/dup.mp3":    "../a/01.mp3",
+		"b/broken.mp3": "../a/missing.mp3",
+		"linked":       "c",
+	}
+	for name, target := range links {
+		if err := os.Symlink(filepath.FromSlash(target), filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+	arg := func(name string) string { return filepath.Join(root, filepath.FromSlash(name)) }
+	args := []string{arg("a"), arg("b"), arg("a"), arg("a/03.mp3"), "https://radio.example/stream.mp3", arg("c"), arg("linked")}
+
+	// The order the arguments and the files in them come in, whatever
+	// the order the probes finish in. Repeated arguments and the
+	// symlinked root, which is scanned from its target, reach files by
+	// the same path again, which isn't reported.
+	wantTracks := []string{
+		arg("a/01.mp3"), arg("a/02 é ü.mp3"), arg("a/03.mp3"), arg("b/04.mp3"),
+		"https://radio.example/stream.mp3", arg("c/deep/05.mp3"),
+	}
+	wantSkipped := []SkippedFile{
+		{Path: arg("a/empty.mp3"), Reason: Empty},
+		{Path: arg("b/broken.mp3"), Reason: Unreadable},
+		{Path: arg("b/dup.mp3"), Reason: Duplicate},
+	}
+	for _, workers := range []int{1, 3, 8, 64} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			tracks, warnings := Config{FollowSymlinks: true, ProbeWorkers: workers}.Collect(context.Background(), args)
+			if !slices.Equal(tracks, wantTracks) {
+				t.Errorf("tracks = %v, want %v", tracks, wantTracks)
+			}
+
+			var skipped *SkippedError
+			if len(warnings) != 1 || !errors.As(warnings[0], &skipped) {
+				t.Fatalf("warnings = %v, want a SkippedError", warnings)
+			}
+			if len(skipped.Files) != len(wantSkipped) {
+				t.Fatalf("skipped %v, want %v", skipped.Files, wantSkipped)
+			}
+			for i, file := range skipped.Files {
+				if file.Path != wantSkipped[i].Path || file.Reason != wantSkipped[i].Reason {
+					t.Errorf("skipped %s as %s, want %s as %s", file.Path, file.Reason, wantSkipped[i].Path, wantSkipped[i].Reason)
+				}
+			}
+			if got := skipped.Files[1].Err; !errors.Is(got, os.ErrNotExist) {
+				t.Errorf("broken link skipped with %v, want %v", got, os.ErrNotExist)
+			}
+		})
+	}
+}
+
+// TestWalkCancel cancels a scan of many files part way: it returns
+// promptly, having emitted the start of what a full scan does
+func TestWalkCancel(t *testing.T) {
+	files := make(map[string]string)
+	for i := range 3000 {
+		files[fmt.Sprintf("%02d/%04d.mp3", i/100, i)] = "audio"
+	}
+	root := writeTree(t, files)
+	full, _ := Config{}.Collect(context.Background(), []string{root})
+	if len(full) != 3000 {
+		t.Fatalf("found %d tracks, want 3000", len(full))
+	}
+
+	for _, workers := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var tracks []string
+			var warnings []error
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				Config{ProbeWorkers: workers}.Walk(ctx, []string{root},
+					func(path string, _ os.FileInfo) {
+						tracks = append(tracks, path)
+						if len(tracks) == 100 {
+							cancel()
+						}
+					},
+					func(err error) { warnings = append(warnings, err) })
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the scan didn't stop")
+			}
+
+			if len(tracks) != 100 || !slices.Equal(tracks, full[:100]) {
+				t.Errorf("emitted %d tracks, want the first 100 of the full scan", len(tracks))
+			}
+			if len(warnings) != 0 {
+				t.Errorf("warnings = %v after cancelling", warnings)
+			}
+		})
+	}
+}
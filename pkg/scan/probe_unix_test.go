@@ -0,0 +1,43 @@
+//go:build unix
+
+package scan
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCollectFIFO skips a named pipe called .mp3 rather than waiting on
+// it for a writer that never comes
+func TestCollectFIFO(t *testing.T) {
+	root := writeTree(t, map[string]string{"01.mp3": "audio"})
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe.mp3"), 0o644); err != nil {
+		t.Skipf("can't create a FIFO: %v", err)
+	}
+
+	var tracks []string
+	var warnings []error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracks, warnings = Config{}.Collect(context.Background(), []string{root})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the scan waited on the FIFO")
+	}
+
+	if want := []string{filepath.Join(root, "01.mp3")}; !slices.Equal(tracks, want) {
+		t.Errorf("tracks = %v, want %v", tracks, want)
+	}
+	var skipped *SkippedError
+	if len(warnings) != 1 || !errors.As(warnings[0], &skipped) || skipped.Count(Unreadable) != 1 || !errors.Is(skipped.Files[0].Err, errNotRegular) {
+		t.Errorf("warnings = %v, want the FIFO skipped as unreadable", warnings)
+	}
+}
@@ -0,0 +1,488 @@
+// Package scan finds the tracks that directories, files, M3U playlists,
+// globs and stream URLs name, the way dirplay does for its playlist.
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/punkscience/dirplay/internal/fspath"
+	"github.com/punkscience/dirplay/internal/library"
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// playlistExts are the playlist formats accepted as arguments
+var playlistExts = map[string]bool{
+	".m3u":  true,
+	".m3u8": true,
+}
+
+// Config holds the options deciding which files a scan finds. dirplay
+// plays, lists with --list, watches with --watch and checks with gc by
+// the same rules.
+type Config struct {
+	// FollowSymlinks descends into symlinked directories
+	FollowSymlinks bool
+	// MaxDepth limits how far below a directory argument the scan goes,
+	// 0 meaning no limit
+	MaxDepth int
+	// Excludes are globs of paths to skip, relative to the scanned root
+	Excludes []string
+	// Sandbox only accepts playlist entries inside the playlist's
+	// directory or one of AllowedRoots
+	Sandbox      bool
+	AllowedRoots []string
+	// AllowStreams plays the http(s) URLs in playlists, which are
+	// otherwise skipped whether sandboxed or not
+	AllowStreams bool
+	// NewerThan skips files modified longer ago than this, 0 meaning no
+	// limit
+	NewerThan time.Duration
+	// ModTimes has every file found passed on with its info, to sort by
+	ModTimes bool
+	// NoIgnore scans directories whatever .nomedia and .dirplayignore
+	// files they hold
+	NoIgnore bool
+	// Blocked are tracks never to play, by CanonicalPath, e.g. dirplay's
+	// blocklist
+	Blocked map[string]bool
+	// ProbeWorkers is how many files are probed at once,
+	// 0 meaning DefaultProbeWorkers
+	ProbeWorkers int
+	// Codecs decides which files are tracks, by extension, nil meaning
+	// those of codec.New
+	Codecs *codec.Registry
+}
+
+// Collect expands command line arguments into tracks, see Walk.
+// Arguments that can't be used are returned as warnings.
+func (c Config) Collect(ctx context.Context, args []string) ([]string, []error) {
+	var tracks []string
+	var warnings []error
+	c.Walk(ctx, args,
+		func(path string, _ os.FileInfo) { tracks = append(tracks, path) },
+		func(err error) { warnings = append(warnings, err) })
+	return tracks, warnings
+}
+
+// Walk calls emit for every track the arguments name, in scan order,
+// until ctx is cancelled. Each argument is a directory scanned
+// recursively, a single audio file, an M3U playlist, a glob where "**"
+// matches any number of directories, or an http(s) URL to stream.
+// Problems with an argument are passed to warn.
+//
+// Every file is probed on the way, see probeWalk. Files reached more than
+// once, through different spellings or symlinks, are only emitted the
+// first time, and files that can't be opened or are empty not at all;
+// what was left out is passed to warn at the end as a SkippedError. emit
+// and warn are called on the calling goroutine.
+//
+// emit gets the file's info when the walk came by it anyway or ModTimes
+// or NewerThan ask for it, and nil otherwise.
+func (c Config) Walk(ctx context.Context, args []string, emit func(path string, info os.FileInfo), warn func(error)) {
+	if c.Codecs == nil {
+		c.Codecs = codec.New()
+	}
+	seen := make(map[string]string)
+	var skips []SkippedFile
+
+	c.probeWalk(ctx, func(add func(*probe)) { c.walkArgs(ctx, args, add) }, func(p *probe) {
+		switch {
+		case p.warning != nil:
+			warn(p.warning)
+			return
+		case p.stream:
+			// A stream has no file to look at
+			if _, ok := seen[p.path]; !ok {
+				seen[p.path] = p.path
+				emit(p.path, nil)
+			}
+			return
+		}
+
+		info := p.info
+		if info == nil && c.needInfo() {
+			info = p.stat
+		}
+		if c.NewerThan > 0 && info != nil && time.Since(info.ModTime()) > c.NewerThan {
+			return
+		}
+		if c.Blocked[p.key] {
+			return
+		}
+		if first, ok := seen[p.key]; ok {
+			// Overlapping arguments reach files twice by the same path,
+			// which isn't worth a mention
+			if first != p.path {
+				skips = append(skips, SkippedFile{Path: p.path, Reason: Duplicate, Err: fmt.Errorf("same file as %s", first)})
+			}
+			return
+		}
+		seen[p.key] = p.path
+		if reason := p.skipReason(); reason != "" {
+			skips = append(skips, SkippedFile{Path: p.path, Reason: reason, Err: p.probeError(reason)})
+			return
+		}
+		emit(p.path, info)
+	})
+
+	if err := skippedWarning(skips); err != nil && ctx.Err() == nil {
+		warn(err)
+	}
+}
+
+// walkArgs expands the arguments for Walk, handing each track and
+// warning to add in order
+func (c Config) walkArgs(ctx context.Context, args []string, add func(*probe)) {
+	found := 0
+	warn := func(err error) { add(&probe{warning: err}) }
+	addFile := func(path string, info os.FileInfo) {
+		found++
+		add(&probe{path: path, info: info})
+	}
+
+	for _, arg := range args {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if IsStreamURL(arg) {
+			add(&probe{path: arg, stream: true})
+			continue
+		}
+
+		paths, err := ExpandArg(arg)
+		if err != nil {
+			warn(err)
+			continue
+		}
+
+		found = 0
+		for _, path := range paths {
+			info, err := os.Stat(fspath.Long(path))
+			if err != nil {
+				warn(err)
+				continue
+			}
+
+			if !info.IsDir() {
+				switch {
+				case c.Codecs.IsAudioFile(path), c.Codecs.UnsupportedError(path) != nil:
+					addFile(path, info)
+				case playlistExts[strings.ToLower(filepath.Ext(path))]:
+					entries, skipped, err := library.LoadM3U(path, c.AllowedRoots, c.Sandbox, c.AllowStreams)
+					if err != nil {
+						warn(fmt.Errorf("error reading playlist %s: %w", path, err))
+					}
+					for _, err := range skipped {
+						warn(err)
+					}
+					for _, entry := range entries {
+						switch {
+						case IsStreamURL(entry):
+							found++
+							add(&probe{path: entry, stream: true})
+						case c.Codecs.IsAudioFile(entry):
+							addFile(entry, nil)
+						}
+					}
+				}
+				continue
+			}
+
+			// The walk doesn't follow a symlinked root, so start from its target
+			if link, err := os.Lstat(path); err == nil && link.Mode()&os.ModeSymlink != 0 {
+				if resolved, err := filepath.EvalSymlinks(path); err == nil {
+					path = resolved
+				}
+			}
+
+			ignored, err := c.scanDirectory(ctx, path, addFile)
+			if err != nil && ctx.Err() == nil {
+				warn(fmt.Errorf("error scanning %s: %w", path, err))
+			}
+			if ignored.Dirs+ignored.Files > 0 {
+				ignored.Root = path
+				warn(&ignored)
+			}
+		}
+
+		if found == 0 && ctx.Err() == nil {
+			warn(fmt.Errorf("no audio files found in %s", arg))
+		}
+	}
+}
+
+// scanDirectory recursively scans a directory, calling found for each
+// audio file, and stops early when ctx is cancelled. It honors the
+// symlink, depth and exclude options. Unreadable directories are
+// skipped and the first such error is returned once the scan is done.
+// found gets the file's info when it was needed to follow a symlink or
+// for ModTimes or NewerThan, and nil otherwise.
+//
+// Unless NoIgnore is set, directories with a .nomedia or .dirplayignore
+// file are skipped or have its patterns applied, see ignoreRule; what was
+// left out is counted in ignored.
+func (c Config) scanDirectory(ctx context.Context, root string, found func(path string, info os.FileInfo)) (ignored IgnoredError, err error) {
+	visited := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		visited[real] = true
+	}
+
+	var firstErr error
+	var walk func(dir string, depth int, rules []ignoreRule)
+	walk = func(dir string, depth int, rules []ignoreRule) {
+		entries, err := os.ReadDir(fspath.Long(dir))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+
+		if !c.NoIgnore {
+			more, skip := readIgnoreMarkers(dir, entries)
+			if skip {
+				ignored.Dirs++
+				return
+			}
+			// Rules further down add to those of the directories above
+			rules = append(rules[:len(rules):len(rules)], more...)
+		}
+
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			if c.Excluded(root, path) {
+				continue
+			}
+
+			isDir := entry.IsDir()
+			var info os.FileInfo
+			if entry.Type()&os.ModeSymlink != 0 {
+				info, err = os.Stat(fspath.Long(path))
+				switch {
+				case err != nil && !c.Codecs.IsAudioFile(path):
+					continue
+				case err != nil:
+					// A broken link to a track is found, for the probe
+					// to report
+					info = nil
+				default:
+					isDir = info.IsDir()
+					if isDir && !c.FollowSymlinks {
+						continue
+					}
+				}
+			}
+
+			if len(rules) > 0 && ignoredBy(rules, path, isDir) {
+				if isDir {
+					ignored.Dirs++
+				} else if c.Codecs.IsAudioFile(path) {
+					ignored.Files++
+				}
+				continue
+			}
+
+			if !isDir {
+				// Check if file has supported audio extension, or is in a
+				// container the probe reports as unsupported
+				if c.Codecs.IsAudioFile(path) || c.Codecs.UnsupportedError(path) != nil {
+					// The directory listing has the times, on Windows
+					// without asking the file system again
+					if info == nil && c.needInfo() && entry.Type()&os.ModeSymlink == 0 {
+						info, _ = entry.Info()
+					}
+					found(path, info)
+				}
+				continue
+			}
+
+			if c.MaxDepth > 0 && depth >= c.MaxDepth {
+				continue
+			}
+
+			// A directory reached twice, e.g. through a symlink cycle, is
+			// only walked the first time
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil || visited[real] {
+				continue
+			}
+			visited[real] = true
+			walk(path, depth+1, rules)
+		}
+	}
+
+	walk(root, 0, nil)
+	if err := ctx.Err(); err != nil {
+		return ignored, err
+	}
+	return ignored, firstErr
+}
+
+// needInfo reports whether every file found needs its info
+func (c Config) needInfo() bool {
+	return c.ModTimes || c.NewerThan > 0
+}
+
+// ValidateExcludes checks the Excludes patterns
+func (c Config) ValidateExcludes() error {
+	for _, pattern := range c.Excludes {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := filepath.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid --exclude pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Excluded reports whether path matches an Excludes pattern. Patterns use
+// "/" separators and are matched against the path relative to the scanned
+// root, with "**" matching any number of directories.
+func (c Config) Excluded(root, path string) bool {
+	if len(c.Excludes) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for _, pattern := range c.Excludes {
+		if ok, _ := matchSegments(strings.Split(pattern, "/"), segments); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandArg returns the paths an argument names, expanding a leading "~/"
+// and glob patterns
+func ExpandArg(arg string) ([]string, error) {
+	if rest, ok := strings.CutPrefix(arg, "~/"); ok {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			arg = filepath.Join(homeDir, rest)
+		}
+	}
+
+	if !strings.ContainsAny(arg, "*?[") {
+		if _, err := os.Stat(fspath.Long(arg)); err != nil {
+			return nil, fmt.Errorf("%s does not exist", arg)
+		}
+		return []string{arg}, nil
+	}
+
+	var matches []string
+	var err error
+	if strings.Contains(arg, "**") {
+		matches, err = globRecursive(arg)
+	} else {
+		matches, err = filepath.Glob(arg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("nothing matches %s", arg)
+	}
+	return matches, nil
+}
+
+// globRecursive expands a pattern containing "**" by walking the directory
+// before its first wildcard
+func globRecursive(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	segments := strings.Split(pattern, string(filepath.Separator))
+
+	// The walk starts at the longest prefix without wildcards
+	fixed := 0
+	for fixed < len(segments) && !strings.ContainsAny(segments[fixed], "*?[") {
+		fixed++
+	}
+	root := strings.Join(segments[:fixed], string(filepath.Separator))
+	switch {
+	case root == "":
+		root = "."
+		if filepath.IsAbs(pattern) {
+			root = string(filepath.Separator)
+		}
+	case filepath.VolumeName(root) == root:
+		root += string(filepath.Separator)
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+
+		ok, err := matchSegments(segments[fixed:], strings.Split(rel, string(filepath.Separator)))
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches zero or more path segments
+func matchSegments(pattern, path []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(path) == 0, nil
+	}
+
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(path); skip++ {
+			if ok, err := matchSegments(pattern[1:], path[skip:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	if len(path) == 0 {
+		return false, nil
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	if !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+// IsStreamURL reports whether a track or argument is an http(s) URL rather
+// than a file
+func IsStreamURL(arg string) bool {
+	lower := strings.ToLower(arg)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// CanonicalPath returns the absolute path with symlinks resolved, used to
+// spot the same file reached twice
+func CanonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
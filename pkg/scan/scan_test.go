@@ -0,0 +1,293 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gopxl/beep"
+
+	"github.com/punkscience/dirplay/pkg/codec"
+)
+
+// relTracks returns tracks relative to root with "/" separators, sorted
+func relTracks(t *testing.T, root string, tracks []string) []string {
+	t.Helper()
+	rel := make([]string, len(tracks))
+	for i, track := range tracks {
+		r, err := filepath.Rel(root, track)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel[i] = filepath.ToSlash(r)
+	}
+	slices.Sort(rel)
+	return rel
+}
+
+func TestCollect(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"rock/a.mp3":           "audio",
+		"rock/live/b.flac":     "audio",
+		"rock/live/deep/c.ogg": "audio",
+		"jazz/d.wav":           "audio",
+		"jazz/demo/e.mp3":      "audio",
+		"jazz/f.xyz":           "audio",
+		"jazz/notes.txt":       "text",
+	})
+	custom := codec.New()
+	custom.Register(".xyz", func(io.ReadCloser) (beep.StreamSeekCloser, beep.Format, error) {
+		return nil, beep.Format{}, nil
+	})
+
+	tests := []struct {
+		name   string
+		config Config
+		args   []string
+		want   []string
+	}{
+		{
+			name: "directory",
+			args: []string{"rock"},
+			want: []string{"rock/a.mp3", "rock/live/b.flac", "rock/live/deep/c.ogg"},
+		},
+		{
+			name: "directories and a file reached twice",
+			args: []string{"jazz", "rock/a.mp3", "jazz/d.wav"},
+			want: []string{"jazz/d.wav", "jazz/demo/e.mp3", "rock/a.mp3"},
+		},
+		{
+			name: "recursive glob",
+			args: []string{"**/*.mp3"},
+			want: []string{"jazz/demo/e.mp3", "rock/a.mp3"},
+		},
+		{
+			name:   "max depth",
+			config: Config{MaxDepth: 1},
+			args:   []string{"rock"},
+			want:   []string{"rock/a.mp3", "rock/live/b.flac"},
+		},
+		{
+			name:   "excludes",
+			config: Config{Excludes: []string{"**/demo", "live/*.flac"}},
+			args:   []string{"rock", "jazz"},
+			want:   []string{"jazz/d.wav", "rock/a.mp3", "rock/live/deep/c.ogg"},
+		},
+		{
+			name:   "registered format",
+			config: Config{Codecs: custom},
+			args:   []string{"jazz"},
+			want:   []string{"jazz/d.wav", "jazz/demo/e.mp3", "jazz/f.xyz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = filepath.Join(root, filepath.FromSlash(arg))
+			}
+			tracks, warnings := tt.config.Collect(context.Background(), args)
+			if len(warnings) != 0 {
+				t.Errorf("warnings = %v", warnings)
+			}
+			if got := relTracks(t, root, tracks); !slices.Equal(got, tt.want) {
+				t.Errorf("tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCollectSymlinks walks a tree whose symlinks group albums, one of
+// them linked twice, and loop back to the root
+func TestCollectSymlinks(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"library/albums/one/a.mp3":  "audio",
+		"library/albums/two/b.mp3":  "audio",
+		"library/loose/c.mp3":       "audio",
+		"library/groups/README.txt": "text",
+	})
+	links := map[string]string{
+		"library/groups/favourites": "../albums/one",
+		"library/groups/again":      "../albums/one",
+		"library/groups/jazz":       "../albums/two",
+		"library/groups/loop":       "..",
+		"library/loose/self":        ".",
+	}
+	for name, target := range links {
+		if err := os.Symlink(filepath.FromSlash(target), filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		arg    string
+		want   []string
+	}{
+		{
+			name: "links not followed",
+			arg:  "library/groups",
+			want: []string{},
+		},
+		{
+			name:   "links followed",
+			config: Config{FollowSymlinks: true},
+			arg:    "library/groups",
+			want:   []string{"library/groups/again/a.mp3", "library/groups/jazz/b.mp3", "library/groups/loop/loose/c.mp3"},
+		},
+		{
+			name:   "cycle through the root",
+			config: Config{FollowSymlinks: true},
+			arg:    "library",
+			want:   []string{"library/albums/one/a.mp3", "library/albums/two/b.mp3", "library/loose/c.mp3"},
+		},
+		{
+			name:   "followed within the depth",
+			config: Config{FollowSymlinks: true, MaxDepth: 1},
+			arg:    "library/groups",
+			want:   []string{"library/groups/again/a.mp3", "library/groups/jazz/b.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			var tracks []string
+			var warnings []error
+			go func() {
+				defer close(done)
+				tracks, warnings = tt.config.Collect(context.Background(), []string{filepath.Join(root, filepath.FromSlash(tt.arg))})
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the scan didn't finish")
+			}
+
+			if len(tt.want) == 0 && len(warnings) != 1 {
+				t.Errorf("warnings = %v, want one for the directory without tracks", warnings)
+			} else if len(tt.want) > 0 && len(warnings) != 0 {
+				t.Errorf("warnings = %v", warnings)
+			}
+			if got := relTracks(t, root, tracks); !slices.Equal(got, tt.want) {
+				t.Errorf("tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectBlockedAndNewer(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"old.mp3":     "audio",
+		"new.mp3":     "audio",
+		"blocked.mp3": "audio",
+	})
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old.mp3"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		NewerThan: 24 * time.Hour,
+		Blocked:   map[string]bool{CanonicalPath(filepath.Join(root, "blocked.mp3")): true},
+	}
+
+	tracks, warnings := config.Collect(context.Background(), []string{root})
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if got := relTracks(t, root, tracks); !slices.Equal(got, []string{"new.mp3"}) {
+		t.Errorf("tracks = %v, want [new.mp3]", got)
+	}
+}
+
+func TestCollectWarnings(t *testing.T) {
+	root := writeTree(t, map[string]string{"empty/notes.txt": "text"})
+	tracks, warnings := Config{}.Collect(context.Background(), []string{
+		filepath.Join(root, "missing"),
+		filepath.Join(root, "empty"),
+		filepath.Join(root, "*.flac"),
+		"https://radio.example/stream.mp3",
+	})
+	if len(warnings) != 3 {
+		t.Errorf("warnings = %v, want one for each argument but the URL", warnings)
+	}
+	if !slices.Equal(tracks, []string{"https://radio.example/stream.mp3"}) {
+		t.Errorf("tracks = %v, want only the URL", tracks)
+	}
+}
+
+// TestCollectPlaylist plays an M3U playlist's entries in its order,
+// sandboxed to its directory, with its URLs only when streams are allowed
+func TestCollectPlaylist(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"list/b.mp3":   "audio",
+		"list/a.mp3":   "audio",
+		"outside.mp3":  "audio",
+		"list/mix.m3u": "#EXTM3U\nb.mp3\n../outside.mp3\nhttp://radio.example/live\na.mp3\n",
+	})
+	b, a := filepath.Join(root, "list", "b.mp3"), filepath.Join(root, "list", "a.mp3")
+	tests := []struct {
+		name     string
+		config   Config
+		want     []string
+		warnings int
+	}{
+		{"sandboxed", Config{Sandbox: true}, []string{b, a}, 2},
+		{"streams", Config{Sandbox: true, AllowStreams: true}, []string{b, "http://radio.example/live", a}, 1},
+		{"unsandboxed", Config{}, []string{b, filepath.Join(root, "outside.mp3"), a}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks, warnings := tt.config.Collect(context.Background(), []string{filepath.Join(root, "list", "mix.m3u")})
+			if !slices.Equal(tracks, tt.want) {
+				t.Errorf("tracks = %v, want %v", tracks, tt.want)
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("warnings = %v, want %d for the entries skipped", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestValidateExcludes(t *testing.T) {
+	if err := (Config{Excludes: []string{"**/live", "*.tmp"}}).ValidateExcludes(); err != nil {
+		t.Errorf("ValidateExcludes() = %v", err)
+	}
+	if err := (Config{Excludes: []string{"live/[a"}}).ValidateExcludes(); err == nil {
+		t.Error("ValidateExcludes() = nil for a broken pattern")
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	root := filepath.FromSlash("/music")
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"**/live/*", "rock/live/a.mp3", true},
+		{"**/live/*", "live/a.mp3", true},
+		{"**/live/*", "rock/band/live/a.mp3", true},
+		{"**/live/*", "rock/live", false},
+		{"**/live/*", "rock/live/deep/a.mp3", false},
+		{"live/*", "rock/live/a.mp3", false},
+		{"*.tmp", "a.tmp", true},
+		{"*.tmp", "rock/a.tmp", false},
+		{"**/*.tmp", "rock/a.tmp", true},
+		{"rock", "rock", true},
+		{"rock", "rock/a.mp3", false},
+		{"**", "rock/a.mp3", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			config := Config{Excludes: []string{tt.pattern}}
+			if got := config.Excluded(root, filepath.Join(root, filepath.FromSlash(tt.path))); got != tt.want {
+				t.Errorf("Excluded(%q) = %v with %q, want %v", tt.path, got, tt.pattern, tt.want)
+			}
+		})
+	}
+}
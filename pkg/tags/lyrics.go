@@ -0,0 +1,26 @@
+package tags
+
+import (
+	"sort"
+	"time"
+)
+
+// LyricLine is a line of lyrics and, for timed lyrics, when it is sung
+type LyricLine struct {
+	At   time.Duration
+	Text string
+}
+
+// Lyrics are the words of a track. Timed lyrics, from an LRC file, are
+// ordered by time; untimed ones are the text as the tag has it, line by
+// line.
+type Lyrics struct {
+	Lines []LyricLine
+	Timed bool
+}
+
+// LineAt returns the index of the timed line being sung at pos, found by
+// binary search so it is right after a seek, or -1 before the first
+func (l Lyrics) LineAt(pos time.Duration) int {
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].At > pos }) - 1
+}
@@ -0,0 +1,39 @@
+package tags
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineAt(t *testing.T) {
+	lyrics := Lyrics{Timed: true, Lines: []LyricLine{
+		{At: 10 * time.Second, Text: "A"},
+		{At: 20 * time.Second, Text: "B"},
+		{At: 20 * time.Second, Text: "B again"},
+		{At: 30 * time.Second, Text: "C"},
+	}}
+	tests := []struct {
+		pos  time.Duration
+		want int
+	}{
+		{0, -1},
+		{10*time.Second - time.Millisecond, -1},
+		{10 * time.Second, 0},
+		{15 * time.Second, 0},
+		{20 * time.Second, 2},
+		{29 * time.Second, 2},
+		{30 * time.Second, 3},
+		{time.Hour, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pos.String(), func(t *testing.T) {
+			if got := lyrics.LineAt(tt.pos); got != tt.want {
+				t.Errorf("LineAt(%v) = %d, want %d", tt.pos, got, tt.want)
+			}
+		})
+	}
+
+	if got := (Lyrics{}).LineAt(time.Minute); got != -1 {
+		t.Errorf("LineAt() without lyrics = %d, want -1", got)
+	}
+}
@@ -0,0 +1,34 @@
+package tags
+
+import "math"
+
+// ReplayGain holds the loudness adjustments tagged on a track, in dB, and
+// the peak sample levels they were computed against, where 1 is full
+// scale. Peaks are zero when untagged.
+type ReplayGain struct {
+	TrackGain float64
+	TrackPeak float64
+	AlbumGain float64
+	AlbumPeak float64
+	HasTrack  bool
+	HasAlbum  bool
+}
+
+// Gain returns the adjustment to play the track at, in dB, preferring the
+// album values when album is set and falling back to the track values.
+// The gain is lowered so the tagged peak doesn't go past full scale. ok
+// is false when the track carries no gain at all.
+func (rg ReplayGain) Gain(album bool) (db float64, ok bool) {
+	db, peak := rg.TrackGain, rg.TrackPeak
+	switch {
+	case album && rg.HasAlbum:
+		db, peak = rg.AlbumGain, rg.AlbumPeak
+	case !rg.HasTrack:
+		return 0, false
+	}
+
+	if peak > 0 {
+		db = min(db, -20*math.Log10(peak))
+	}
+	return db, true
+}
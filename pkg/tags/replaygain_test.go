@@ -0,0 +1,36 @@
+package tags
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReplayGainGain(t *testing.T) {
+	tagged := ReplayGain{TrackGain: -6, TrackPeak: 0.5, AlbumGain: -8, AlbumPeak: 0.9, HasTrack: true, HasAlbum: true}
+	tests := []struct {
+		name  string
+		rg    ReplayGain
+		album bool
+		want  float64
+		ok    bool
+	}{
+		{"track", tagged, false, -6, true},
+		{"album", tagged, true, -8, true},
+		{"album falls back to track", ReplayGain{TrackGain: -2, HasTrack: true}, true, -2, true},
+		{"untagged", ReplayGain{}, false, 0, false},
+		{"album only in track mode", ReplayGain{AlbumGain: -3, HasAlbum: true}, false, 0, false},
+		// A boost of 9 dB would take a peak of 0.5 past full scale, so it
+		// stops at 6.02 dB
+		{"boost held by the peak", ReplayGain{TrackGain: 9, TrackPeak: 0.5, HasTrack: true}, false, -20 * math.Log10(0.5), true},
+		{"boost under the peak", ReplayGain{TrackGain: 3, TrackPeak: 0.5, HasTrack: true}, false, 3, true},
+		{"boost without a peak", ReplayGain{TrackGain: 9, HasTrack: true}, false, 9, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rg.Gain(tt.album)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Gain(%v) = %v, %v, want %v, %v", tt.album, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// Package tags holds the metadata read from the tags of a track, beyond
+// the names and numbers: ReplayGain adjustments, chapters and lyrics, as
+// player.TrackInfo reports them.
+package tags
+
+import "time"
+
+// Chapter is a point within a file where a part of it starts: a track of
+// a single-file album rip or a chapter of a long mix
+type Chapter struct {
+	Start time.Duration
+	Title string
+}